@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/config"
+	"github.com/otoru/heimdall/internal/server"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// doctorTimeout bounds each group of checks (one bucket, one proxy).
+const doctorTimeout = 30 * time.Second
+
+// doctor prints one PASS/FAIL line per check.
+type doctor struct {
+	out    io.Writer
+	checks int
+	failed int
+}
+
+func (d *doctor) report(name string, err error, hints ...string) {
+	d.checks++
+	if err == nil {
+		fmt.Fprintf(d.out, "PASS  %s\n", name)
+		return
+	}
+	d.failed++
+	fmt.Fprintf(d.out, "FAIL  %s: %v\n", name, err)
+	for _, h := range hints {
+		if h != "" {
+			fmt.Fprintf(d.out, "      hint: %s\n", h)
+		}
+	}
+}
+
+// runDoctor is the "heimdall doctor" command: it validates the
+// configuration, runs every S3 operation heimdall needs against each bucket
+// and checks that each proxy upstream resolves and answers. It returns the
+// process exit code.
+func runDoctor(ctx context.Context, out io.Writer) int {
+	d := &doctor{out: out}
+	defer func() {
+		fmt.Fprintf(out, "\n%d checks, %d failed\n", d.checks, d.failed)
+	}()
+
+	cfg, err := config.Load()
+	d.report("config", err)
+	if err != nil {
+		return 1
+	}
+	d.report("config durations", checkDurations(cfg))
+	_, err = storage.ParseSidecarChecksums(cfg.ChecksumAlgorithms)
+	d.report("checksum algorithms", err)
+
+	base := storage.Options{
+		Bucket:            cfg.Bucket,
+		Prefix:            cfg.Prefix,
+		Region:            cfg.Region,
+		Endpoint:          cfg.Endpoint,
+		AccessKey:         cfg.AccessKey,
+		SecretKey:         cfg.SecretKey,
+		UsePathStyle:      cfg.UsePathStyle,
+		RetryMaxAttempts:  1,
+		PutMode:           cfg.S3PutMode,
+		ChecksumAlgorithm: cfg.S3ChecksumAlgorithm,
+	}
+	store := d.probeBucket(ctx, "s3 bucket "+cfg.Bucket, base)
+	for _, rb := range cfg.RepoBuckets {
+		d.probeBucket(ctx, "s3 bucket "+rb.Bucket+" (repository "+rb.Repo+")", repoStoreOptions(base, rb))
+	}
+	if store == nil {
+		return 1
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	proxies, err := server.NewProxyManager(store, zap.NewNop()).List(listCtx)
+	cancel()
+	d.report("proxy list", err)
+	if cfg.ProxiesFile != "" {
+		declared, err := server.ReadProxiesFile(cfg.ProxiesFile)
+		d.report("proxies file "+cfg.ProxiesFile, err)
+		// The file wins over stored proxies of the same name.
+		proxies = slices.DeleteFunc(proxies, func(pr server.Proxy) bool {
+			return slices.ContainsFunc(declared, func(dp server.Proxy) bool { return dp.Name == pr.Name })
+		})
+		proxies = append(proxies, declared...)
+	}
+	for _, pr := range proxies {
+		d.checkProxy(ctx, pr)
+	}
+
+	if d.failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkDurations parses every duration setting, like the server does at
+// startup, and reports all invalid ones together.
+func checkDurations(cfg config.Config) error {
+	var invalid []string
+	for env, value := range map[string]string{
+		"S3_RETRY_MAX_BACKOFF":           cfg.S3RetryMaxBackoff,
+		"S3_HEAD_TIMEOUT":                cfg.S3HeadTimeout,
+		"S3_LIST_TIMEOUT":                cfg.S3ListTimeout,
+		"S3_GET_TIMEOUT":                 cfg.S3GetTimeout,
+		"S3_PUT_TIMEOUT":                 cfg.S3PutTimeout,
+		"S3_DIAL_TIMEOUT":                cfg.S3DialTimeout,
+		"S3_KEEP_ALIVE":                  cfg.S3KeepAlive,
+		"S3_TLS_HANDSHAKE_TIMEOUT":       cfg.S3TLSHandshakeTimeout,
+		"S3_IDLE_CONN_TIMEOUT":           cfg.S3IdleConnTimeout,
+		"UPSTREAM_DIAL_TIMEOUT":          cfg.UpstreamDialTimeout,
+		"UPSTREAM_KEEP_ALIVE":            cfg.UpstreamKeepAlive,
+		"UPSTREAM_TLS_HANDSHAKE_TIMEOUT": cfg.UpstreamTLSHandshakeTimeout,
+		"UPSTREAM_IDLE_CONN_TIMEOUT":     cfg.UpstreamIdleConnTimeout,
+		"SLOW_REQUEST_THRESHOLD":         cfg.SlowRequestThreshold,
+		"PACKAGES_LAYOUT_TTL":            cfg.PackagesLayoutTTL,
+		"LEADER_LEASE_TTL":               cfg.LeaderLeaseTTL,
+		"METADATA_LOCK_TIMEOUT":          cfg.MetadataLockTimeout,
+		"CONFIG_WATCH_INTERVAL":          cfg.ConfigWatchInterval,
+		"PROXIES_FILE_INTERVAL":          cfg.ProxiesFileInterval,
+		"UPSTREAM_QUEUE_TIMEOUT":         cfg.UpstreamQueueTimeout,
+		"CDN_URL_TTL":                    cfg.CDNURLTTL,
+		"WORM_RETENTION":                 cfg.WORMRetention,
+		"USAGE_REPORT_INTERVAL":          cfg.UsageReportInterval,
+		"CHECKSUM_INDEX_INTERVAL":        cfg.ChecksumIndexInterval,
+		"DEPENDENCY_INDEX_INTERVAL":      cfg.DependencyIndexInterval,
+		"CAS_GC_INTERVAL":                cfg.CASGCInterval,
+		"WARMUP_INTERVAL":                cfg.WarmupInterval,
+		"MIRROR_INTERVAL":                cfg.MirrorInterval,
+		"LIFECYCLE_INTERVAL":             cfg.LifecycleInterval,
+		"SNAPSHOT_PRUNE_INTERVAL":        cfg.SnapshotPruneInterval,
+		"VERIFY_INTERVAL":                cfg.VerifyInterval,
+		"CHECKSUM_SCAN_INTERVAL":         cfg.ChecksumScanInterval,
+		"CACHE_EVICTION_INTERVAL":        cfg.CacheEvictionInterval,
+		"ARCHETYPE_CATALOG_INTERVAL":     cfg.ArchetypeInterval,
+		"POLICY_TIMEOUT":                 cfg.PolicyTimeout,
+		"CLAMAV_TIMEOUT":                 cfg.ClamAVTimeout,
+		"SIGNING_TIMEOUT":                cfg.SigningTimeout,
+		"TUS_EXPIRY":                     cfg.TusExpiry,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			invalid = append(invalid, fmt.Sprintf("%s=%q", env, value))
+		}
+	}
+	if len(invalid) > 0 {
+		slices.Sort(invalid)
+		return fmt.Errorf("invalid %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// probeBucket reports every step of storage.Store.Probe. It returns the
+// store, or nil when it could not be set up.
+func (d *doctor) probeBucket(ctx context.Context, name string, opts storage.Options) *storage.Store {
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	store, err := storage.New(ctx, opts)
+	if err != nil {
+		d.report(name, err)
+		return nil
+	}
+	for _, step := range store.Probe(ctx) {
+		if step.Skipped {
+			fmt.Fprintf(d.out, "SKIP  %s %s\n", name, step.Op)
+			continue
+		}
+		d.report(name+" "+step.Op, step.Err, bucketHint(opts, step))
+	}
+	return store
+}
+
+// bucketHint suggests the usual fixes for a failed probe step.
+func bucketHint(opts storage.Options, step storage.ProbeStep) string {
+	switch {
+	case step.Err == nil:
+		return ""
+	case step.Op == "HeadBucket" && opts.Endpoint != "" && !opts.UsePathStyle:
+		return "S3-compatible stores such as MinIO usually need S3_USE_PATH_STYLE=true; without it the bucket name is sent as part of the host name"
+	case storage.IsNotFound(step.Err) && step.Op == "HeadBucket":
+		return "check S3_BUCKET and S3_REGION, or set S3_CREATE_BUCKET=true"
+	case strings.Contains(step.Err.Error(), "AccessDenied") || strings.Contains(step.Err.Error(), "Forbidden"):
+		return "the credentials lack the s3:" + strings.TrimSuffix(step.Op, "V2") + " permission on this bucket"
+	}
+	return ""
+}
+
+// checkProxy resolves the upstream host and sends a HEAD to its URL. Client
+// errors still show the upstream is reachable; server errors do not.
+func (d *doctor) checkProxy(ctx context.Context, pr server.Proxy) {
+	u, err := url.Parse(pr.URL)
+	if err == nil && u.Hostname() == "" {
+		err = fmt.Errorf("no host in URL")
+	}
+	name := "proxy " + pr.Name
+	if err != nil {
+		d.report(name, err)
+		return
+	}
+	name += " (" + u.Redacted() + ")"
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+		d.report(name+" resolve", err)
+		return
+	}
+	d.report(name+" resolve", nil)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, pr.URL, nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 500 {
+				err = fmt.Errorf("upstream answered %s", resp.Status)
+			}
+		}
+	}
+	d.report(name+" HEAD", err)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/config"
+	"github.com/otoru/heimdall/internal/server"
+	"github.com/otoru/heimdall/internal/storage"
+)
+
+func TestDoctorConfigFailure(t *testing.T) {
+	t.Setenv("S3_BUCKET", "")
+	var out bytes.Buffer
+	if code := runDoctor(context.Background(), &out); code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(out.String(), "FAIL  config: S3_BUCKET is required") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+}
+
+func TestDoctorChecks(t *testing.T) {
+	if err := checkDurations(config.Config{S3HeadTimeout: "10s", CDNURLTTL: "soon"}); err == nil || !strings.Contains(err.Error(), `CDN_URL_TTL="soon"`) {
+		t.Fatalf("expected the invalid duration to be reported, got %v", err)
+	}
+
+	hint := bucketHint(storage.Options{Endpoint: "http://minio:9000"}, storage.ProbeStep{Op: "HeadBucket", Err: errors.New("no such host")})
+	if !strings.Contains(hint, "S3_USE_PATH_STYLE=true") {
+		t.Fatalf("expected the path-style hint, got %q", hint)
+	}
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer up.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	var out bytes.Buffer
+	d := &doctor{out: &out}
+	d.checkProxy(context.Background(), server.Proxy{Name: "central", URL: up.URL})
+	d.checkProxy(context.Background(), server.Proxy{Name: "broken", URL: broken.URL})
+	if d.checks != 4 || d.failed != 1 {
+		t.Fatalf("expected 4 checks with 1 failure, got %d/%d:\n%s", d.checks, d.failed, out.String())
+	}
+	if !strings.Contains(out.String(), "FAIL  proxy broken ("+broken.URL+") HEAD: upstream answered 502") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/otoru/heimdall/internal/config"
+	"github.com/otoru/heimdall/internal/server"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// importCommand holds the flags of "heimdall import".
+type importCommand struct {
+	from       string
+	url        string
+	repository string
+	user       string
+	password   string
+	token      string
+	dir        string
+	properties bool
+	opts       server.ImportOptions
+}
+
+// parseImport parses the arguments of "heimdall import". Passwords and
+// tokens can also come from IMPORT_PASSWORD and IMPORT_TOKEN, which keeps
+// them out of the process list.
+func parseImport(args []string, errOut io.Writer) (*importCommand, error) {
+	c := &importCommand{}
+	fs := flag.NewFlagSet("heimdall import", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.StringVar(&c.from, "from", "", "source: nexus, artifactory or dir")
+	fs.StringVar(&c.url, "url", "", "base URL of the Nexus or Artifactory instance")
+	fs.StringVar(&c.repository, "repository", "", "repository to import from")
+	fs.StringVar(&c.user, "user", "", "user name on the source")
+	fs.StringVar(&c.password, "password", os.Getenv("IMPORT_PASSWORD"), "password on the source (default $IMPORT_PASSWORD)")
+	fs.StringVar(&c.token, "token", os.Getenv("IMPORT_TOKEN"), "Artifactory access token (default $IMPORT_TOKEN)")
+	fs.StringVar(&c.dir, "dir", "", "exported repository directory, for -from dir")
+	fs.BoolVar(&c.properties, "properties", false, "also import Artifactory properties (one request per file)")
+	fs.StringVar(&c.opts.Target, "to", "", "Heimdall path to import into, e.g. releases")
+	fs.BoolVar(&c.opts.Overwrite, "overwrite", false, "replace files that already exist")
+	fs.BoolVar(&c.opts.DryRun, "dry-run", false, "only count what would be imported")
+	fs.IntVar(&c.opts.Workers, "workers", 4, "concurrent transfers")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if c.opts.Target == "" {
+		return nil, errors.New("-to is required")
+	}
+	switch c.from {
+	case "nexus", "artifactory":
+		if c.url == "" || c.repository == "" {
+			return nil, fmt.Errorf("-url and -repository are required for -from %s", c.from)
+		}
+	case "dir":
+		if c.dir == "" {
+			return nil, errors.New("-dir is required for -from dir")
+		}
+	default:
+		return nil, fmt.Errorf("unknown source %q (want nexus, artifactory or dir)", c.from)
+	}
+	return c, nil
+}
+
+func (c *importCommand) source() server.ImportSource {
+	switch c.from {
+	case "nexus":
+		return server.NewNexusSource(c.url, c.repository, c.user, c.password)
+	case "artifactory":
+		return server.NewArtifactorySource(c.url, c.repository, c.user, c.password, c.token, c.properties)
+	}
+	return server.DirSource{Root: c.dir}
+}
+
+// runImport is the "heimdall import" command: it copies a repository from
+// Nexus, Artifactory or an export directory into the configured bucket(s).
+// It returns the process exit code.
+func runImport(ctx context.Context, args []string, out, errOut io.Writer) int {
+	c, err := parseImport(args, errOut)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "heimdall import: %v\n", err)
+		return 2
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(errOut, "heimdall import: %v\n", err)
+		return 1
+	}
+	logger, err := zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(errOut, "heimdall import: %v\n", err)
+		return 1
+	}
+	defer func() { _ = logger.Sync() }()
+	c.opts.Logger = logger
+	if c.opts.Checksums, err = storage.ParseSidecarChecksums(cfg.ChecksumAlgorithms); err != nil {
+		fmt.Fprintf(errOut, "heimdall import: invalid CHECKSUM_ALGORITHMS: %v\n", err)
+		return 1
+	}
+
+	store, err := importStore(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(errOut, "heimdall import: %v\n", err)
+		return 1
+	}
+	stats, err := server.Import(ctx, store, c.source(), c.opts)
+	verb := "imported"
+	if c.opts.DryRun {
+		verb = "to import"
+	}
+	fmt.Fprintf(out, "%d assets: %d %s (%d bytes), %d already present, %d failed\n",
+		stats.Assets, stats.Imported, verb, stats.Bytes, stats.Skipped, stats.Failed)
+	if err != nil {
+		fmt.Fprintf(errOut, "heimdall import: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// importStore opens the bucket(s) the server would write to, with the same
+// write-once and content-addressed storage settings.
+func importStore(ctx context.Context, cfg config.Config) (server.Storage, error) {
+	var retention time.Duration
+	if cfg.WORMRetention != "" {
+		var err error
+		if retention, err = time.ParseDuration(cfg.WORMRetention); err != nil {
+			return nil, fmt.Errorf("invalid WORM_RETENTION: %w", err)
+		}
+	}
+	opts := storage.Options{
+		Bucket:              cfg.Bucket,
+		Prefix:              cfg.Prefix,
+		Region:              cfg.Region,
+		Endpoint:            cfg.Endpoint,
+		AccessKey:           cfg.AccessKey,
+		SecretKey:           cfg.SecretKey,
+		UsePathStyle:        cfg.UsePathStyle,
+		RetryMaxAttempts:    cfg.S3RetryMaxAttempts,
+		RetryMode:           cfg.S3RetryMode,
+		PutMode:             cfg.S3PutMode,
+		ChecksumAlgorithm:   cfg.S3ChecksumAlgorithm,
+		CAS:                 cfg.CASStorage,
+		CASMinSize:          int64(cfg.CASMinSize),
+		WORMPrefixes:        cfg.WORMPrefixes,
+		ObjectLock:          cfg.WORMObjectLock,
+		ObjectLockRetention: retention,
+	}
+	def, err := storage.New(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.RepoBuckets) == 0 {
+		return def, nil
+	}
+	routes := make(map[string]*storage.Store, len(cfg.RepoBuckets))
+	for _, rb := range cfg.RepoBuckets {
+		if routes[rb.Repo], err = storage.New(ctx, repoStoreOptions(opts, rb)); err != nil {
+			return nil, fmt.Errorf("repository %s: %w", rb.Repo, err)
+		}
+	}
+	return storage.NewRouter(def, routes), nil
+}
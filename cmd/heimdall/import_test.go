@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/server"
+)
+
+func TestParseImport(t *testing.T) {
+	t.Setenv("IMPORT_PASSWORD", "from-env")
+	c, err := parseImport([]string{"-from", "nexus", "-url", "https://nexus.example.com", "-repository", "maven-releases", "-user", "admin", "-to", "releases"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.password != "from-env" || c.opts.Workers != 4 || c.opts.Target != "releases" {
+		t.Fatalf("unexpected command %+v", c)
+	}
+	if _, ok := c.source().(*server.NexusSource); !ok {
+		t.Fatalf("unexpected source %T", c.source())
+	}
+
+	for args, want := range map[string]string{
+		"-from nexus -url https://x": "-to is required",
+		"-from nexus -to releases":   "-url and -repository are required",
+		"-from dir -to releases":     "-dir is required",
+		"-from gitlab -to releases":  "unknown source",
+	} {
+		if _, err := parseImport(strings.Fields(args), io.Discard); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", args, want, err)
+		}
+	}
+}
@@ -3,18 +3,22 @@ package main
 //go:generate swag init -g main.go -o ../internal/docs
 
 import (
+	"cmp"
 	"context"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/otoru/heimdall/internal/accesslog"
 	"github.com/otoru/heimdall/internal/config"
 	"github.com/otoru/heimdall/internal/docs"
 	"github.com/otoru/heimdall/internal/metrics"
 	"github.com/otoru/heimdall/internal/server"
 	"github.com/otoru/heimdall/internal/storage"
+	"github.com/otoru/heimdall/internal/version"
 	"go.uber.org/zap"
 )
 
@@ -24,19 +28,101 @@ import (
 // @BasePath /
 // @securityDefinitions.basic BasicAuth
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor(context.Background(), os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		panic(err)
 	}
 
-	logger, err := zap.NewProduction()
+	logLevel, err := zap.ParseAtomicLevel(cfg.LogLevel)
+	if err != nil {
+		panic(err)
+	}
+	zapCfg := zap.NewProductionConfig()
+	zapCfg.Level = logLevel
+	logger, err := zapCfg.Build()
 	if err != nil {
 		panic(err)
 	}
 	defer func() { _ = logger.Sync() }()
 
+	var buckets []float64
+	if cfg.MetricsBuckets != "" {
+		buckets, err = metrics.ParseBuckets(cfg.MetricsBuckets)
+		if err != nil {
+			logger.Fatal("invalid METRICS_DURATION_BUCKETS", zap.Error(err))
+		}
+	}
+	appMetrics := metrics.NewWithOptions(metrics.Options{
+		DurationBuckets:  buckets,
+		NativeHistograms: cfg.MetricsNativeHist,
+		MaxPrincipals:    cfg.MetricsMaxPrincipals,
+	})
+
+	var retryMaxBackoff time.Duration
+	if cfg.S3RetryMaxBackoff != "" {
+		retryMaxBackoff, err = time.ParseDuration(cfg.S3RetryMaxBackoff)
+		if err != nil {
+			logger.Fatal("invalid S3_RETRY_MAX_BACKOFF", zap.Error(err))
+		}
+	}
+
+	var wormRetention time.Duration
+	if cfg.WORMRetention != "" {
+		wormRetention, err = time.ParseDuration(cfg.WORMRetention)
+		if err != nil {
+			logger.Fatal("invalid WORM_RETENTION", zap.String("value", cfg.WORMRetention), zap.Error(err))
+		}
+	}
+
+	var timeouts storage.Timeouts
+	for env, t := range map[string]struct {
+		raw string
+		dst *time.Duration
+	}{
+		"S3_HEAD_TIMEOUT": {cfg.S3HeadTimeout, &timeouts.Head},
+		"S3_LIST_TIMEOUT": {cfg.S3ListTimeout, &timeouts.List},
+		"S3_GET_TIMEOUT":  {cfg.S3GetTimeout, &timeouts.Get},
+		"S3_PUT_TIMEOUT":  {cfg.S3PutTimeout, &timeouts.Put},
+	} {
+		if *t.dst, err = time.ParseDuration(t.raw); err != nil {
+			logger.Fatal("invalid "+env, zap.Error(err))
+		}
+	}
+
+	s3Transport := storage.Transport{MaxIdleConnsPerHost: cfg.S3MaxIdleConnsPerHost}
+	upstreamTransport := storage.Transport{MaxIdleConnsPerHost: cfg.UpstreamMaxIdleConnsPerHost}
+	for env, t := range map[string]struct {
+		raw string
+		dst *time.Duration
+	}{
+		"S3_DIAL_TIMEOUT":                {cfg.S3DialTimeout, &s3Transport.DialTimeout},
+		"S3_KEEP_ALIVE":                  {cfg.S3KeepAlive, &s3Transport.KeepAlive},
+		"S3_TLS_HANDSHAKE_TIMEOUT":       {cfg.S3TLSHandshakeTimeout, &s3Transport.TLSHandshakeTimeout},
+		"S3_IDLE_CONN_TIMEOUT":           {cfg.S3IdleConnTimeout, &s3Transport.IdleConnTimeout},
+		"UPSTREAM_DIAL_TIMEOUT":          {cfg.UpstreamDialTimeout, &upstreamTransport.DialTimeout},
+		"UPSTREAM_KEEP_ALIVE":            {cfg.UpstreamKeepAlive, &upstreamTransport.KeepAlive},
+		"UPSTREAM_TLS_HANDSHAKE_TIMEOUT": {cfg.UpstreamTLSHandshakeTimeout, &upstreamTransport.TLSHandshakeTimeout},
+		"UPSTREAM_IDLE_CONN_TIMEOUT":     {cfg.UpstreamIdleConnTimeout, &upstreamTransport.IdleConnTimeout},
+	} {
+		if *t.dst, err = time.ParseDuration(t.raw); err != nil {
+			logger.Fatal("invalid "+env, zap.Error(err))
+		}
+	}
+
+	checksums, err := storage.ParseSidecarChecksums(cfg.ChecksumAlgorithms)
+	if err != nil {
+		logger.Fatal("invalid CHECKSUM_ALGORITHMS", zap.Error(err))
+	}
+
 	ctx := context.Background()
-	store, err := storage.New(ctx, storage.Options{
+	storeOpts := storage.Options{
 		Bucket:       cfg.Bucket,
 		Prefix:       cfg.Prefix,
 		Region:       cfg.Region,
@@ -44,17 +130,279 @@ func main() {
 		AccessKey:    cfg.AccessKey,
 		SecretKey:    cfg.SecretKey,
 		UsePathStyle: cfg.UsePathStyle,
-	})
+
+		RetryMaxAttempts: cfg.S3RetryMaxAttempts,
+		RetryMode:        cfg.S3RetryMode,
+		RetryMaxBackoff:  retryMaxBackoff,
+		OnRetryExhausted: func(op string) {
+			appMetrics.S3RetriesExhausted.WithLabelValues(op).Inc()
+		},
+		Timeouts:  timeouts,
+		Transport: s3Transport,
+		PutMode:   cfg.S3PutMode,
+
+		ChecksumAlgorithm: cfg.S3ChecksumAlgorithm,
+
+		CAS:        cfg.CASStorage,
+		CASMinSize: int64(cfg.CASMinSize),
+
+		WORMPrefixes:        cfg.WORMPrefixes,
+		ObjectLock:          cfg.WORMObjectLock,
+		ObjectLockRetention: wormRetention,
+
+		SidecarChecksums: checksums,
+	}
+	defaultStore, err := storage.New(ctx, storeOpts)
 	if err != nil {
 		logger.Fatal("init storage", zap.Error(err))
 	}
 
-	appMetrics := metrics.New()
+	verify := func(st *storage.Store, bucket string) {
+		if !cfg.S3VerifyBucket {
+			return
+		}
+		vctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := st.Verify(vctx, cfg.S3CreateBucket); err != nil {
+			logger.Fatal("storage verification failed", zap.String("bucket", bucket), zap.Error(err))
+		}
+	}
+	verify(defaultStore, cfg.Bucket)
+
+	var store server.Storage = defaultStore
+	if len(cfg.RepoBuckets) > 0 {
+		routes := make(map[string]*storage.Store, len(cfg.RepoBuckets))
+		for _, rb := range cfg.RepoBuckets {
+			routes[rb.Repo], err = storage.New(ctx, repoStoreOptions(storeOpts, rb))
+			if err != nil {
+				logger.Fatal("init storage", zap.String("repo", rb.Repo), zap.Error(err))
+			}
+			verify(routes[rb.Repo], rb.Bucket)
+			logger.Info("repository mapped to bucket", zap.String("repo", rb.Repo), zap.String("bucket", rb.Bucket))
+		}
+		store = storage.NewRouter(defaultStore, routes)
+	}
+
 	docs.SwaggerInfo.BasePath = "/"
 	docs.SwaggerInfo.Title = "Heimdall API"
 	docs.SwaggerInfo.Version = "1.0"
+	buildInfo := version.Get()
+	logger.Info("heimdall starting", zap.String("version", buildInfo.Version), zap.String("commit", buildInfo.Commit), zap.String("date", buildInfo.Date))
+
+	if err := server.CheckPasswordHash(cfg.AuthPassword); err != nil {
+		logger.Fatal("invalid AUTH_PASSWORD", zap.Error(err))
+	}
+
+	blockList, err := server.ParseBlockList(cfg.BlockedArtifacts)
+	if err != nil {
+		logger.Fatal("parse BLOCKED_ARTIFACTS", zap.Error(err))
+	}
 
-	srv := server.New(store, logger, appMetrics, cfg.AuthUser, cfg.AuthPassword)
+	var policy *server.PolicyHook
+	if cfg.PolicyURL != "" {
+		timeout, err := time.ParseDuration(cfg.PolicyTimeout)
+		if err != nil {
+			logger.Fatal("invalid POLICY_TIMEOUT", zap.Error(err))
+		}
+		cacheTTL, err := time.ParseDuration(cfg.PolicyCacheTTL)
+		if err != nil {
+			logger.Fatal("invalid POLICY_CACHE_TTL", zap.Error(err))
+		}
+		policy = server.NewPolicyHook(cfg.PolicyURL, timeout, cacheTTL, cfg.PolicyFailOpen)
+	}
+
+	var errorReporter *server.ErrorReporter
+	switch {
+	case cfg.SentryDSN != "":
+		errorReporter, err = server.NewSentryErrorReporter(cfg.SentryDSN, cfg.SentryEnvironment, logger)
+		if err != nil {
+			logger.Fatal("parse SENTRY_DSN", zap.Error(err))
+		}
+	case cfg.ErrorWebhookURL != "":
+		errorReporter = server.NewWebhookErrorReporter(cfg.ErrorWebhookURL, logger)
+	}
+
+	var scans *server.ScanQueue
+	switch cfg.VulnScanner {
+	case "":
+	case "ossindex":
+		scans = server.NewScanQueue(server.NewOSSIndexScanner(cfg.VulnScannerURL, cfg.VulnScannerUser, cfg.VulnScannerToken), store, logger, cfg.VulnQuarantine, 0)
+	case "webhook":
+		if cfg.VulnScannerURL == "" {
+			logger.Fatal("VULN_SCANNER_URL is required for the webhook scanner")
+		}
+		scans = server.NewScanQueue(server.NewWebhookScanner(cfg.VulnScannerURL), store, logger, cfg.VulnQuarantine, 0)
+	default:
+		logger.Fatal("unknown VULN_SCANNER", zap.String("scanner", cfg.VulnScanner))
+	}
+
+	var clamav *server.ClamAV
+	if cfg.ClamAVAddr != "" {
+		timeout, err := time.ParseDuration(cfg.ClamAVTimeout)
+		if err != nil {
+			logger.Fatal("invalid CLAMAV_TIMEOUT", zap.Error(err))
+		}
+		clamav = server.NewClamAV(cfg.ClamAVAddr, timeout)
+	}
+
+	var signingHook *server.SigningHook
+	if cfg.SigningURL != "" {
+		timeout, err := time.ParseDuration(cfg.SigningTimeout)
+		if err != nil {
+			logger.Fatal("invalid SIGNING_TIMEOUT", zap.Error(err))
+		}
+		if signingHook, err = server.NewSigningHook(cfg.SigningURL, cfg.SigningPaths, timeout, cfg.SigningFailOpen); err != nil {
+			logger.Fatal("invalid SIGNING_PATHS", zap.Error(err))
+		}
+	}
+
+	tusExpiry, err := time.ParseDuration(cfg.TusExpiry)
+	if err != nil || tusExpiry <= 0 {
+		logger.Fatal("invalid TUS_EXPIRY", zap.String("value", cfg.TusExpiry), zap.Error(err))
+	}
+
+	var forwardAuth *server.ForwardAuth
+	if cfg.ForwardAuthProxies != "" {
+		forwardAuth, err = server.NewForwardAuth(cfg.ForwardAuthProxies, cfg.ForwardAuthHeaders)
+		if err != nil {
+			logger.Fatal("parse FORWARD_AUTH_TRUSTED_PROXIES", zap.Error(err))
+		}
+		forwardAuth.GrantAdmin(cfg.ForwardAuthAdmins, cfg.ForwardAuthAdminGroup)
+	}
+
+	if cfg.URLSigningKey == "" {
+		logger.Info("URL_SIGNING_KEY not set; signed URLs are only valid until restart")
+	}
+
+	var slowThreshold time.Duration
+	if cfg.SlowRequestThreshold != "" {
+		slowThreshold, err = time.ParseDuration(cfg.SlowRequestThreshold)
+		if err != nil {
+			logger.Fatal("invalid SLOW_REQUEST_THRESHOLD", zap.Error(err))
+		}
+	}
+
+	layoutTTL, err := time.ParseDuration(cfg.PackagesLayoutTTL)
+	if err != nil || layoutTTL <= 0 {
+		logger.Fatal("invalid PACKAGES_LAYOUT_TTL", zap.String("value", cfg.PackagesLayoutTTL), zap.Error(err))
+	}
+
+	switch cfg.SwaggerUI {
+	case server.SwaggerPublic, server.SwaggerAuth, server.SwaggerDisabled:
+	default:
+		logger.Fatal("invalid SWAGGER_UI", zap.String("value", cfg.SwaggerUI))
+	}
+	switch cfg.VerifyDownloads {
+	case server.VerifyDownloadsOff, server.VerifyDownloadsLog, server.VerifyDownloadsInvalidate:
+	default:
+		logger.Fatal("invalid VERIFY_DOWNLOADS", zap.String("value", cfg.VerifyDownloads))
+	}
+	leaseTTL, err := time.ParseDuration(cfg.LeaderLeaseTTL)
+	if err != nil || leaseTTL <= 0 {
+		logger.Fatal("invalid LEADER_LEASE_TTL", zap.String("value", cfg.LeaderLeaseTTL), zap.Error(err))
+	}
+	lockTimeout, err := time.ParseDuration(cfg.MetadataLockTimeout)
+	if err != nil || lockTimeout <= 0 {
+		logger.Fatal("invalid METADATA_LOCK_TIMEOUT", zap.String("value", cfg.MetadataLockTimeout), zap.Error(err))
+	}
+	configWatch, err := time.ParseDuration(cfg.ConfigWatchInterval)
+	if err != nil || configWatch < 0 {
+		logger.Fatal("invalid CONFIG_WATCH_INTERVAL", zap.String("value", cfg.ConfigWatchInterval), zap.Error(err))
+	}
+	proxiesFileInterval, err := time.ParseDuration(cfg.ProxiesFileInterval)
+	if err != nil || proxiesFileInterval <= 0 {
+		logger.Fatal("invalid PROXIES_FILE_INTERVAL", zap.String("value", cfg.ProxiesFileInterval), zap.Error(err))
+	}
+	queueTimeout, err := time.ParseDuration(cfg.UpstreamQueueTimeout)
+	if err != nil || queueTimeout < 0 {
+		logger.Fatal("invalid UPSTREAM_QUEUE_TIMEOUT", zap.String("value", cfg.UpstreamQueueTimeout), zap.Error(err))
+	}
+	var cdn *server.CDNSigner
+	if cfg.CDNURL != "" {
+		cdnTTL, err := time.ParseDuration(cfg.CDNURLTTL)
+		if err != nil || cdnTTL <= 0 {
+			logger.Fatal("invalid CDN_URL_TTL", zap.String("value", cfg.CDNURLTTL), zap.Error(err))
+		}
+		pemKey, err := os.ReadFile(cfg.CDNPrivateKeyFile)
+		if err != nil {
+			logger.Fatal("read CDN_PRIVATE_KEY_FILE", zap.Error(err))
+		}
+		if cdn, err = server.NewCDNSigner(cfg.CDNURL, cfg.CDNKeyPairID, pemKey, cdnTTL, cfg.Bucket); err != nil {
+			logger.Fatal("init CDN", zap.Error(err))
+		}
+	}
+
+	accessLogger, closeAccessLog, err := accesslog.New(accesslog.Options{
+		Target:     cfg.AccessLog,
+		MaxSizeMB:  cfg.AccessLogMaxSizeMB,
+		MaxBackups: cfg.AccessLogMaxBackups,
+		MaxAgeDays: cfg.AccessLogMaxAgeDays,
+	}, logger)
+	if err != nil {
+		logger.Fatal("init ACCESS_LOG", zap.Error(err))
+	}
+	defer func() { _ = closeAccessLog() }()
+
+	sampling, err := server.NewTelemetrySampling(cfg.TelemetrySampleRatio, cfg.TelemetryExclude)
+	if err != nil {
+		logger.Fatal("invalid telemetry sampling config", zap.Error(err))
+	}
+
+	var shadow *server.Shadow
+	if cfg.ShadowURL != "" {
+		if shadow, err = server.NewShadow(cfg.ShadowURL, cfg.ShadowSampleRatio, cfg.ShadowConcurrency, logger); err != nil {
+			logger.Fatal("invalid shadow config", zap.Error(err))
+		}
+	}
+
+	srv := server.NewWithOptions(store, logger, appMetrics, server.Options{
+		AuthUser:     cfg.AuthUser,
+		AuthPassword: cfg.AuthPassword,
+		BlockList:    blockList,
+		Policy:       policy,
+		StrictLayout: server.ParseLayoutPolicy(cfg.StrictLayoutRepos),
+		Scans:        scans,
+		ClamAV:       clamav,
+		SigningHook:  signingHook,
+		ForwardAuth:  forwardAuth,
+		SigningKey:   []byte(cfg.URLSigningKey),
+		Errors:       errorReporter,
+		LogLevel:     &logLevel,
+
+		ChecksumAlgorithms: checksums,
+		ResumableUploads:   server.ResumableUploads{MaxSize: int64(cfg.TusMaxSizeMB) << 20, Expiry: tusExpiry},
+
+		SlowRequestThreshold: slowThreshold,
+		AccessLogger:         accessLogger,
+		Sampling:             sampling,
+		RootLayoutTTL:        layoutTTL,
+		RaceProxies:          cfg.PackagesRace,
+		SwaggerUI:            cfg.SwaggerUI,
+		DisableLegacyAPI:     !cfg.LegacyAPIPaths,
+		VerifyDownloads:      cfg.VerifyDownloads,
+
+		IntegrityQuarantineAfter: cfg.VerifyQuarantineAfter,
+		LeaderElection:           cfg.LeaderElection,
+		LeaderID:                 cfg.LeaderID,
+		LeaderLeaseTTL:           leaseTTL,
+		MetadataLocks:            cfg.MetadataLocks,
+		MetadataLockTimeout:      lockTimeout,
+		EventsToken:              cfg.EventsToken,
+		CDN:                      cdn,
+		Shadow:                   shadow,
+		Throttle:                 server.NewThrottle(int64(cfg.DownloadRateLimit), int64(cfg.DownloadRateGlobal)),
+		TokenBudgets: server.TokenBudgets{
+			DailyRequests: int64(cfg.TokenDailyRequests),
+			DailyBytes:    int64(cfg.TokenDailyBytes),
+		},
+		UpstreamLimits: server.UpstreamLimits{
+			PerProxy:     cfg.UpstreamConcurrency,
+			Global:       cfg.UpstreamGlobal,
+			QueueTimeout: queueTimeout,
+		},
+		UpstreamTransport: upstreamTransport,
+		UploadMemoryMax:   int64(cfg.UploadMemoryMax),
+	})
 
 	httpServer := &http.Server{
 		Addr:    cfg.Addr,
@@ -64,6 +412,16 @@ func main() {
 		Addr:    cfg.MetricsAddr,
 		Handler: metrics.HandlerFor(appMetrics),
 	}
+	var grpcServer *http.Server
+	if cfg.GRPCAddr != "" {
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		grpcServer = &http.Server{
+			Addr:      cfg.GRPCAddr,
+			Handler:   srv.GRPCHandler(),
+			Protocols: &protocols,
+		}
+	}
 
 	idleConnsClosed := make(chan struct{})
 	go func() {
@@ -75,6 +433,9 @@ func main() {
 		defer cancel()
 
 		_ = metricsServer.Shutdown(ctx)
+		if grpcServer != nil {
+			_ = grpcServer.Shutdown(ctx)
+		}
 		if err := httpServer.Shutdown(ctx); err != nil {
 			logger.Error("shutdown error", zap.Error(err))
 		}
@@ -88,17 +449,122 @@ func main() {
 		}
 	}()
 
-	ctx, cancelScan := context.WithCancel(context.Background())
-	defer cancelScan()
-	intervalStr := cfg.ChecksumScanInterval
-	if intervalStr == "" {
-		intervalStr = "30m"
+	if grpcServer != nil {
+		go func() {
+			logger.Info("grpc admin server starting", zap.String("addr", cfg.GRPCAddr))
+			if err := grpcServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("grpc admin server failed", zap.Error(err))
+			}
+		}()
 	}
-	dur, err := time.ParseDuration(intervalStr)
-	if err != nil {
-		logger.Warn("invalid CHECKSUM_SCAN_INTERVAL, skipping scanner", zap.Error(err))
-	} else if dur > 0 {
-		go server.RunChecksumScanner(ctx, logger, store, cfg.ChecksumScanPrefix, dur)
+
+	ctx, cancelTasks := context.WithCancel(context.Background())
+	defer cancelTasks()
+
+	var lifecycleRules []server.LifecycleRule
+	if cfg.LifecycleRules != "" {
+		if lifecycleRules, err = server.ParseLifecycleRules(cfg.LifecycleRules); err != nil {
+			logger.Fatal("invalid LIFECYCLE_RULES", zap.Error(err))
+		}
+	}
+
+	// The *_INTERVAL settings give the default schedules; TASK_SCHEDULES
+	// overrides them and TASKS_DISABLED turns tasks off.
+	schedules := map[string]string{}
+	every := func(task, env, value string) {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			logger.Fatal("invalid "+env, zap.String("value", value), zap.Error(err))
+		}
+		if d > 0 {
+			schedules[task] = "@every " + d.String()
+		}
+	}
+	every("checksum-scan", "CHECKSUM_SCAN_INTERVAL", cmp.Or(cfg.ChecksumScanInterval, "30m"))
+	every("cache-eviction", "CACHE_EVICTION_INTERVAL", cmp.Or(cfg.CacheEvictionInterval, "1h"))
+	every("usage-report", "USAGE_REPORT_INTERVAL", cfg.UsageReportInterval)
+	every("checksum-index", "CHECKSUM_INDEX_INTERVAL", cfg.ChecksumIndexInterval)
+	every("dependency-index", "DEPENDENCY_INDEX_INTERVAL", cfg.DependencyIndexInterval)
+	if lifecycleRules != nil {
+		every("lifecycle", "LIFECYCLE_INTERVAL", cfg.LifecycleInterval)
+	}
+	if cfg.ArchetypeInterval != "" {
+		every("archetype-catalog", "ARCHETYPE_CATALOG_INTERVAL", cfg.ArchetypeInterval)
+	}
+	if cfg.SnapshotKeep > 0 {
+		every("snapshot-prune", "SNAPSHOT_PRUNE_INTERVAL", cfg.SnapshotPruneInterval)
+	}
+	if cfg.VerifyInterval != "" {
+		every("integrity-check", "VERIFY_INTERVAL", cfg.VerifyInterval)
+	}
+	if cfg.CASStorage {
+		every("cas-gc", "CAS_GC_INTERVAL", cfg.CASGCInterval)
+	}
+	if cfg.WarmupFile != "" || cfg.WarmupKey != "" {
+		every("cache-warmup", "WARMUP_INTERVAL", cfg.WarmupInterval)
+	}
+	if len(cfg.MirrorPaths) > 0 {
+		every("mirror", "MIRROR_INTERVAL", cfg.MirrorInterval)
+	}
+	schedules["upload-expiry"] = "@every 1h"
+	tokenRetention, err := time.ParseDuration(cfg.TokenRevokedRetention)
+	if err != nil || tokenRetention < 0 {
+		logger.Fatal("invalid TOKEN_REVOKED_RETENTION", zap.String("value", cfg.TokenRevokedRetention), zap.Error(err))
+	}
+	if tokenRetention > 0 {
+		schedules["token-prune"] = "@daily"
+	}
+
+	tasks := srv.Tasks(server.TaskSettings{
+		ChecksumPrefix:  cfg.ChecksumScanPrefix,
+		LifecycleRules:  lifecycleRules,
+		SnapshotPruning: server.SnapshotPruning{Keep: cfg.SnapshotKeep, WebhookURL: cfg.SnapshotPruneWebhook},
+		VerifyPrefix:    cfg.VerifyPrefix,
+		VerifyETag:      cfg.VerifyETag,
+		Warmup:          server.WarmupSource{File: cfg.WarmupFile, Key: cfg.WarmupKey},
+		MirrorPaths:     cfg.MirrorPaths,
+		TokenRetention:  tokenRetention,
+	})
+	for name, spec := range cfg.TaskSchedules {
+		schedules[name] = spec
+	}
+	for _, name := range cfg.TasksDisabled {
+		if _, ok := tasks[name]; !ok {
+			logger.Fatal("unknown task in TASKS_DISABLED", zap.String("task", name))
+		}
+		delete(schedules, name)
+	}
+	for name := range schedules {
+		if _, ok := tasks[name]; !ok {
+			logger.Fatal("unknown task in TASK_SCHEDULES", zap.String("task", name))
+		}
+	}
+	// Unscheduled tasks are still registered so they can be run via the API.
+	for name, task := range tasks {
+		task.Schedule = schedules[name]
+		if err := srv.ScheduleTask(task); err != nil {
+			logger.Fatal("invalid task schedule", zap.Error(err))
+		}
+	}
+	go srv.RunScheduler(ctx)
+	if configWatch > 0 {
+		go srv.WatchConfig(ctx, configWatch)
+	}
+	if cfg.ProxiesFile != "" {
+		if err := srv.ApplyProxiesFile(ctx, cfg.ProxiesFile); err != nil {
+			logger.Fatal("apply PROXIES_FILE", zap.String("path", cfg.ProxiesFile), zap.Error(err))
+		}
+		go srv.WatchProxiesFile(ctx, cfg.ProxiesFile, proxiesFileInterval)
+	}
+
+	if scans != nil {
+		go scans.Run(ctx)
+	}
+	if errorReporter != nil {
+		go errorReporter.Run(ctx)
+	}
+	if shadow != nil {
+		go shadow.Run(ctx)
 	}
 
 	logger.Info("server starting", zap.String("addr", cfg.Addr), zap.String("bucket", cfg.Bucket), zap.String("prefix", cfg.Prefix))
@@ -109,3 +575,24 @@ func main() {
 
 	<-idleConnsClosed
 }
+
+// repoStoreOptions applies the S3_REPO_<NAME>_* overrides of rb to the
+// default store options.
+func repoStoreOptions(opts storage.Options, rb config.RepoBucket) storage.Options {
+	worm := opts.WORMPrefixes
+	opts.Bucket = rb.Bucket
+	opts.Prefix = rb.Prefix
+	opts.Region = rb.Region
+	opts.Endpoint = rb.Endpoint
+	opts.AccessKey = rb.AccessKey
+	opts.SecretKey = rb.SecretKey
+	opts.UsePathStyle = rb.UsePathStyle
+	// The router strips the repository from keys.
+	opts.WORMPrefixes = nil
+	for _, p := range worm {
+		if rest, ok := strings.CutPrefix(p+"/", rb.Repo+"/"); ok {
+			opts.WORMPrefixes = append(opts.WORMPrefixes, rest)
+		}
+	}
+	return opts
+}
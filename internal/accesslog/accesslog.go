@@ -0,0 +1,87 @@
+// Package accesslog builds the logger used for per-request access logs, which
+// can be routed away from application logs to a rotating file or syslog.
+package accesslog
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+type Options struct {
+	// Target is "" (use the application logger), "stdout", "stderr",
+	// "file:///path/access.log" (or a bare path), "syslog" for the local
+	// daemon, or "syslog+udp://host:514" / "syslog+tcp://host:514".
+	Target string
+	// Rotation settings for file targets.
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	Tag        string
+}
+
+// New returns a JSON logger writing to opts.Target. It returns fallback when
+// Target is empty. The returned close function flushes and releases the sink.
+func New(opts Options, fallback *zap.Logger) (*zap.Logger, func() error, error) {
+	if opts.Target == "" {
+		return fallback, func() error { return nil }, nil
+	}
+	ws, closeFn, err := open(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	logger := zap.New(zapcore.NewCore(encoder, ws, zapcore.InfoLevel)).Named("access")
+	return logger, func() error {
+		_ = logger.Sync()
+		return closeFn()
+	}, nil
+}
+
+func open(opts Options) (zapcore.WriteSyncer, func() error, error) {
+	target := opts.Target
+	switch {
+	case target == "stdout", target == "stderr":
+		ws, closeFn, err := zap.Open(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ws, func() error { closeFn(); return nil }, nil
+	case target == "syslog" || strings.HasPrefix(target, "syslog+"):
+		network, addr := "", ""
+		if target != "syslog" {
+			u, err := url.Parse(target)
+			if err != nil || u.Host == "" {
+				return nil, nil, fmt.Errorf("invalid syslog target %q", target)
+			}
+			network, addr = strings.TrimPrefix(u.Scheme, "syslog+"), u.Host
+		}
+		tag := opts.Tag
+		if tag == "" {
+			tag = "heimdall-access"
+		}
+		w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_LOCAL0, tag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("connect syslog: %w", err)
+		}
+		return zapcore.AddSync(w), w.Close, nil
+	}
+
+	path := strings.TrimPrefix(target, "file://")
+	if path == "" || strings.Contains(path, "://") {
+		return nil, nil, fmt.Errorf("unsupported access log target %q", target)
+	}
+	lj := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    opts.MaxSizeMB,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAgeDays,
+		Compress:   true,
+	}
+	return zapcore.AddSync(lj), lj.Close, nil
+}
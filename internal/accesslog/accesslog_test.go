@@ -0,0 +1,44 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFileTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger, closeFn, err := New(Options{Target: "file://" + path, MaxSizeMB: 1}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	logger.Info("request", zap.String("path", "/releases/app.jar"))
+	if err := closeFn(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(data), `"path":"/releases/app.jar"`) {
+		t.Fatalf("unexpected log contents %q", data)
+	}
+}
+
+func TestEmptyTargetUsesFallback(t *testing.T) {
+	fallback := zap.NewNop()
+	logger, _, err := New(Options{}, fallback)
+	if err != nil || logger != fallback {
+		t.Fatalf("expected fallback logger, got %v (%v)", logger, err)
+	}
+}
+
+func TestInvalidTarget(t *testing.T) {
+	if _, _, err := New(Options{Target: "kafka://broker"}, zap.NewNop()); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -21,6 +21,138 @@ type Config struct {
 	AuthPassword string
 	ChecksumScanInterval string
 	ChecksumScanPrefix   string
+	CacheEvictionInterval string
+	BlockedArtifacts      string
+	PolicyURL             string
+	PolicyTimeout         string
+	PolicyCacheTTL        string
+	PolicyFailOpen        bool
+	VulnScanner           string
+	VulnScannerURL        string
+	VulnScannerUser       string
+	VulnScannerToken      string
+	VulnQuarantine        string
+	ClamAVAddr            string
+	ClamAVTimeout         string
+	ForwardAuthProxies    string
+	ForwardAuthHeaders    []string
+	ForwardAuthAdmins     []string
+	ForwardAuthAdminGroup string
+	URLSigningKey         string
+	SentryDSN             string
+	SentryEnvironment     string
+	ErrorWebhookURL       string
+	LogLevel              string
+	SlowRequestThreshold  string
+	AccessLog             string
+	AccessLogMaxSizeMB    int
+	AccessLogMaxBackups   int
+	AccessLogMaxAgeDays   int
+	MetricsBuckets        string
+	MetricsNativeHist     bool
+	MetricsMaxPrincipals  int
+	TelemetrySampleRatio  float64
+	TelemetryExclude      string
+	S3RetryMaxAttempts    int
+	S3RetryMode           string
+	S3RetryMaxBackoff     string
+	S3HeadTimeout         string
+	S3ListTimeout         string
+	S3GetTimeout          string
+	S3PutTimeout          string
+	RepoBuckets           []RepoBucket
+	S3VerifyBucket        bool
+	S3CreateBucket        bool
+	LifecycleRules        string
+	LifecycleInterval     string
+	S3PutMode             string
+	S3ChecksumAlgorithm   string
+	PackagesLayoutTTL     string
+	PackagesRace          bool
+	ArchetypeInterval     string
+	SnapshotKeep          int
+	SnapshotPruneInterval string
+	SnapshotPruneWebhook  string
+	StrictLayoutRepos     string
+	SwaggerUI             string
+	LegacyAPIPaths        bool
+	GRPCAddr              string
+	UsageReportInterval   string
+	VerifyInterval        string
+	VerifyPrefix          string
+	VerifyETag            bool
+	VerifyDownloads       string
+	VerifyQuarantineAfter int
+	TaskSchedules         map[string]string
+	TasksDisabled         []string
+	LeaderElection        bool
+	LeaderID              string
+	LeaderLeaseTTL        string
+	MetadataLocks         bool
+	MetadataLockTimeout   string
+	ConfigWatchInterval   string
+	ProxiesFile           string
+	ProxiesFileInterval   string
+	EventsToken           string
+	CDNURL                string
+	CDNKeyPairID          string
+	CDNPrivateKeyFile     string
+	CDNURLTTL             string
+	DownloadRateLimit     int
+	DownloadRateGlobal    int
+	TokenDailyRequests    int
+	TokenDailyBytes       int
+	TokenRevokedRetention string
+	UpstreamConcurrency   int
+	UpstreamGlobal        int
+	UpstreamQueueTimeout  string
+	CASStorage            bool
+	CASMinSize            int
+	CASGCInterval         string
+	ChecksumIndexInterval string
+	WORMPrefixes          []string
+	WORMObjectLock        string
+	WORMRetention         string
+	WarmupFile            string
+	WarmupKey             string
+	WarmupInterval        string
+	MirrorPaths           []string
+	MirrorInterval        string
+	SigningURL            string
+	SigningPaths          []string
+	SigningTimeout        string
+	SigningFailOpen       bool
+	ChecksumAlgorithms    []string
+	TusMaxSizeMB          int
+	TusExpiry             string
+	DependencyIndexInterval string
+	ShadowURL             string
+	ShadowSampleRatio     float64
+	ShadowConcurrency     int
+	S3MaxIdleConnsPerHost       int
+	S3DialTimeout               string
+	S3KeepAlive                 string
+	S3TLSHandshakeTimeout       string
+	S3IdleConnTimeout           string
+	UpstreamMaxIdleConnsPerHost int
+	UpstreamDialTimeout         string
+	UpstreamKeepAlive           string
+	UpstreamTLSHandshakeTimeout string
+	UpstreamIdleConnTimeout     string
+	UploadMemoryMax             int
+}
+
+// RepoBucket maps a repository (first path segment) to its own bucket.
+// Empty fields inherit the default S3_* settings.
+type RepoBucket struct {
+	Repo         string
+	Bucket       string
+	Prefix       string
+	Region       string
+	Endpoint     string
+	AccessKey    string
+	SecretKey    string
+	UsePathStyle bool
 }
 
 func Load() (Config, error) {
@@ -36,6 +168,97 @@ func Load() (Config, error) {
 		AuthPassword: os.Getenv("AUTH_PASSWORD"),
 		ChecksumScanInterval: os.Getenv("CHECKSUM_SCAN_INTERVAL"),
 		ChecksumScanPrefix:   strings.Trim(getenvDefault("CHECKSUM_SCAN_PREFIX", ""), "/"),
+		CacheEvictionInterval: os.Getenv("CACHE_EVICTION_INTERVAL"),
+		BlockedArtifacts:      os.Getenv("BLOCKED_ARTIFACTS"),
+		PolicyURL:             os.Getenv("POLICY_URL"),
+		PolicyTimeout:         getenvDefault("POLICY_TIMEOUT", "2s"),
+		PolicyCacheTTL:        getenvDefault("POLICY_CACHE_TTL", "1m"),
+		VulnScanner:           strings.ToLower(os.Getenv("VULN_SCANNER")),
+		VulnScannerURL:        os.Getenv("VULN_SCANNER_URL"),
+		VulnScannerUser:       os.Getenv("VULN_SCANNER_USER"),
+		VulnScannerToken:      os.Getenv("VULN_SCANNER_TOKEN"),
+		VulnQuarantine:        strings.ToLower(os.Getenv("VULN_QUARANTINE_SEVERITY")),
+		ClamAVAddr:            os.Getenv("CLAMAV_ADDR"),
+		ClamAVTimeout:         getenvDefault("CLAMAV_TIMEOUT", "1m"),
+		ForwardAuthProxies:    os.Getenv("FORWARD_AUTH_TRUSTED_PROXIES"),
+		ForwardAuthAdminGroup: strings.TrimSpace(os.Getenv("FORWARD_AUTH_ADMIN_GROUP")),
+		URLSigningKey:         os.Getenv("URL_SIGNING_KEY"),
+		SentryDSN:             os.Getenv("SENTRY_DSN"),
+		SentryEnvironment:     os.Getenv("SENTRY_ENVIRONMENT"),
+		ErrorWebhookURL:       os.Getenv("ERROR_WEBHOOK_URL"),
+		LogLevel:              getenvDefault("LOG_LEVEL", "info"),
+		SlowRequestThreshold:  os.Getenv("SLOW_REQUEST_THRESHOLD"),
+		AccessLog:             os.Getenv("ACCESS_LOG"),
+		MetricsBuckets:        os.Getenv("METRICS_DURATION_BUCKETS"),
+		MetricsMaxPrincipals:  100,
+		TelemetrySampleRatio:  1,
+		TelemetryExclude:      os.Getenv("TELEMETRY_EXCLUDE"),
+		S3VerifyBucket:        true,
+		LegacyAPIPaths:        true,
+		S3ChecksumAlgorithm:   strings.ToUpper(os.Getenv("S3_CHECKSUM_ALGORITHM")),
+		S3PutMode:             strings.ToLower(getenvDefault("S3_PUT_MODE", "direct")),
+		LifecycleRules:        os.Getenv("LIFECYCLE_RULES"),
+		LifecycleInterval:     getenvDefault("LIFECYCLE_INTERVAL", "24h"),
+		PackagesLayoutTTL:     getenvDefault("PACKAGES_LAYOUT_TTL", "1m"),
+		ArchetypeInterval:     os.Getenv("ARCHETYPE_CATALOG_INTERVAL"),
+		SnapshotPruneInterval: getenvDefault("SNAPSHOT_PRUNE_INTERVAL", "24h"),
+		SnapshotPruneWebhook:  os.Getenv("SNAPSHOT_PRUNE_WEBHOOK"),
+		StrictLayoutRepos:     os.Getenv("STRICT_LAYOUT_REPOS"),
+		SwaggerUI:             strings.ToLower(getenvDefault("SWAGGER_UI", "public")),
+		GRPCAddr:              os.Getenv("GRPC_ADDR"),
+		UsageReportInterval:   getenvDefault("USAGE_REPORT_INTERVAL", "1h"),
+		VerifyInterval:        os.Getenv("VERIFY_INTERVAL"),
+		VerifyPrefix:          strings.Trim(os.Getenv("VERIFY_PREFIX"), "/"),
+		VerifyETag:            true,
+		VerifyDownloads:       strings.ToLower(getenvDefault("VERIFY_DOWNLOADS", "off")),
+		LeaderID:              os.Getenv("LEADER_ID"),
+		LeaderLeaseTTL:        getenvDefault("LEADER_LEASE_TTL", "30s"),
+		MetadataLockTimeout:   getenvDefault("METADATA_LOCK_TIMEOUT", "30s"),
+		ConfigWatchInterval:   getenvDefault("CONFIG_WATCH_INTERVAL", "5s"),
+		ProxiesFile:           os.Getenv("PROXIES_FILE"),
+		ProxiesFileInterval:   getenvDefault("PROXIES_FILE_INTERVAL", "10s"),
+		EventsToken:           os.Getenv("S3_EVENTS_TOKEN"),
+		CDNURL:                os.Getenv("CDN_URL"),
+		CDNKeyPairID:          os.Getenv("CDN_KEY_PAIR_ID"),
+		CDNPrivateKeyFile:     os.Getenv("CDN_PRIVATE_KEY_FILE"),
+		CDNURLTTL:             getenvDefault("CDN_URL_TTL", "5m"),
+		UpstreamQueueTimeout:  getenvDefault("UPSTREAM_QUEUE_TIMEOUT", "30s"),
+		CASGCInterval:         getenvDefault("CAS_GC_INTERVAL", "24h"),
+		ChecksumIndexInterval: getenvDefault("CHECKSUM_INDEX_INTERVAL", "1h"),
+		WORMObjectLock:        strings.ToLower(os.Getenv("WORM_OBJECT_LOCK")),
+		WORMRetention:         os.Getenv("WORM_RETENTION"),
+		WarmupFile:            os.Getenv("WARMUP_FILE"),
+		WarmupKey:             strings.TrimPrefix(os.Getenv("WARMUP_KEY"), "/"),
+		WarmupInterval:        getenvDefault("WARMUP_INTERVAL", "24h"),
+		MirrorInterval:        getenvDefault("MIRROR_INTERVAL", "24h"),
+		SigningURL:            os.Getenv("SIGNING_URL"),
+		SigningTimeout:        getenvDefault("SIGNING_TIMEOUT", "2m"),
+		TusExpiry:             getenvDefault("TUS_EXPIRY", "24h"),
+		TokenRevokedRetention: getenvDefault("TOKEN_REVOKED_RETENTION", "2160h"),
+		S3RetryMode:           strings.ToLower(os.Getenv("S3_RETRY_MODE")),
+		S3RetryMaxBackoff:     os.Getenv("S3_RETRY_MAX_BACKOFF"),
+		S3HeadTimeout:         getenvDefault("S3_HEAD_TIMEOUT", "10s"),
+		S3ListTimeout:         getenvDefault("S3_LIST_TIMEOUT", "30s"),
+		S3GetTimeout:          getenvDefault("S3_GET_TIMEOUT", "30m"),
+		S3PutTimeout:          getenvDefault("S3_PUT_TIMEOUT", "30m"),
+		AccessLogMaxSizeMB:    100,
+		AccessLogMaxBackups:   7,
+		AccessLogMaxAgeDays:   30,
+		DependencyIndexInterval: getenvDefault("DEPENDENCY_INDEX_INTERVAL", "1h"),
+		ShadowURL:             os.Getenv("SHADOW_URL"),
+		ShadowSampleRatio:     1,
+		ShadowConcurrency:     4,
+		S3MaxIdleConnsPerHost:       100,
+		S3DialTimeout:               getenvDefault("S3_DIAL_TIMEOUT", "30s"),
+		S3KeepAlive:                 getenvDefault("S3_KEEP_ALIVE", "30s"),
+		S3TLSHandshakeTimeout:       getenvDefault("S3_TLS_HANDSHAKE_TIMEOUT", "10s"),
+		S3IdleConnTimeout:           getenvDefault("S3_IDLE_CONN_TIMEOUT", "90s"),
+		UpstreamMaxIdleConnsPerHost: 100,
+		UpstreamDialTimeout:         getenvDefault("UPSTREAM_DIAL_TIMEOUT", "30s"),
+		UpstreamKeepAlive:           getenvDefault("UPSTREAM_KEEP_ALIVE", "30s"),
+		UpstreamTLSHandshakeTimeout: getenvDefault("UPSTREAM_TLS_HANDSHAKE_TIMEOUT", "10s"),
+		UpstreamIdleConnTimeout:     getenvDefault("UPSTREAM_IDLE_CONN_TIMEOUT", "90s"),
+		UploadMemoryMax:             256 << 10,
 	}
 
 	bucket := os.Getenv("S3_BUCKET")
@@ -52,9 +275,214 @@ func Load() (Config, error) {
 		cfg.UsePathStyle = usePathStyle
 	}
 
+	for env, dst := range map[string]*bool{
+		"S3_VERIFY_BUCKET": &cfg.S3VerifyBucket,
+		"S3_CREATE_BUCKET": &cfg.S3CreateBucket,
+		"PACKAGES_RACE":    &cfg.PackagesRace,
+		"LEGACY_API_PATHS": &cfg.LegacyAPIPaths,
+		"VERIFY_ETAG":      &cfg.VerifyETag,
+		"LEADER_ELECTION":  &cfg.LeaderElection,
+		"METADATA_LOCKS":   &cfg.MetadataLocks,
+		"CAS_STORAGE":      &cfg.CASStorage,
+	} {
+		if v := os.Getenv(env); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			*dst = b
+		}
+	}
+
+	if v := os.Getenv("POLICY_FAIL_OPEN"); v != "" {
+		failOpen, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid POLICY_FAIL_OPEN: %w", err)
+		}
+		cfg.PolicyFailOpen = failOpen
+	}
+
+	if v := os.Getenv("SIGNING_FAIL_OPEN"); v != "" {
+		failOpen, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SIGNING_FAIL_OPEN: %w", err)
+		}
+		cfg.SigningFailOpen = failOpen
+	}
+
+	if v := os.Getenv("METRICS_NATIVE_HISTOGRAMS"); v != "" {
+		native, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid METRICS_NATIVE_HISTOGRAMS: %w", err)
+		}
+		cfg.MetricsNativeHist = native
+	}
+
+	if v := os.Getenv("TELEMETRY_SAMPLE_RATIO"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid TELEMETRY_SAMPLE_RATIO: %w", err)
+		}
+		cfg.TelemetrySampleRatio = ratio
+	}
+	if v := os.Getenv("SHADOW_SAMPLE_RATIO"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SHADOW_SAMPLE_RATIO: %w", err)
+		}
+		cfg.ShadowSampleRatio = ratio
+	}
+
+	for env, dst := range map[string]*int{
+		"ACCESS_LOG_MAX_SIZE_MB":      &cfg.AccessLogMaxSizeMB,
+		"ACCESS_LOG_MAX_BACKUPS":      &cfg.AccessLogMaxBackups,
+		"ACCESS_LOG_MAX_AGE_DAYS":     &cfg.AccessLogMaxAgeDays,
+		"S3_RETRY_MAX_ATTEMPTS":       &cfg.S3RetryMaxAttempts,
+		"SNAPSHOT_KEEP":               &cfg.SnapshotKeep,
+		"VERIFY_QUARANTINE_AFTER":     &cfg.VerifyQuarantineAfter,
+		"DOWNLOAD_RATE_LIMIT":         &cfg.DownloadRateLimit,
+		"DOWNLOAD_RATE_GLOBAL":        &cfg.DownloadRateGlobal,
+		"TOKEN_DAILY_REQUESTS":        &cfg.TokenDailyRequests,
+		"TOKEN_DAILY_BYTES":           &cfg.TokenDailyBytes,
+		"UPSTREAM_CONCURRENCY":        &cfg.UpstreamConcurrency,
+		"UPSTREAM_CONCURRENCY_GLOBAL": &cfg.UpstreamGlobal,
+		"CAS_MIN_SIZE":                &cfg.CASMinSize,
+		"TUS_MAX_SIZE_MB":             &cfg.TusMaxSizeMB,
+		"METRICS_MAX_PRINCIPALS":      &cfg.MetricsMaxPrincipals,
+		"SHADOW_CONCURRENCY":          &cfg.ShadowConcurrency,
+		"S3_MAX_IDLE_CONNS_PER_HOST":  &cfg.S3MaxIdleConnsPerHost,
+		"UPSTREAM_MAX_IDLE_CONNS_PER_HOST": &cfg.UpstreamMaxIdleConnsPerHost,
+		"UPLOAD_MEMORY_MAX":           &cfg.UploadMemoryMax,
+	} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return Config{}, fmt.Errorf("invalid %s: %q", env, v)
+			}
+			*dst = n
+		}
+	}
+
+	if v := os.Getenv("FORWARD_AUTH_HEADERS"); v != "" {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				cfg.ForwardAuthHeaders = append(cfg.ForwardAuthHeaders, h)
+			}
+		}
+	}
+
+	for _, user := range strings.Split(os.Getenv("FORWARD_AUTH_ADMINS"), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			cfg.ForwardAuthAdmins = append(cfg.ForwardAuthAdmins, user)
+		}
+	}
+
+	if v := os.Getenv("TASK_SCHEDULES"); v != "" {
+		cfg.TaskSchedules = map[string]string{}
+		for _, entry := range strings.Split(v, ";") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			name, spec, ok := strings.Cut(entry, "=")
+			name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+			if !ok || name == "" || spec == "" {
+				return Config{}, fmt.Errorf("invalid TASK_SCHEDULES entry %q, want name=schedule", entry)
+			}
+			cfg.TaskSchedules[name] = spec
+		}
+	}
+
+	for _, name := range strings.Split(os.Getenv("TASKS_DISABLED"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.TasksDisabled = append(cfg.TasksDisabled, name)
+		}
+	}
+
+	for _, prefix := range strings.Split(os.Getenv("WORM_PREFIXES"), ",") {
+		if prefix = strings.Trim(strings.TrimSpace(prefix), "/"); prefix != "" {
+			cfg.WORMPrefixes = append(cfg.WORMPrefixes, prefix)
+		}
+	}
+
+	for _, prefix := range strings.Split(os.Getenv("MIRROR_PATHS"), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			cfg.MirrorPaths = append(cfg.MirrorPaths, prefix)
+		}
+	}
+
+	for _, algo := range strings.Split(getenvDefault("CHECKSUM_ALGORITHMS", "sha1,md5"), ",") {
+		if algo = strings.ToLower(strings.TrimSpace(algo)); algo != "" {
+			cfg.ChecksumAlgorithms = append(cfg.ChecksumAlgorithms, algo)
+		}
+	}
+
+	for _, pattern := range strings.Split(getenvDefault("SIGNING_PATHS", "**/*.jar"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			cfg.SigningPaths = append(cfg.SigningPaths, pattern)
+		}
+	}
+
+	repos, err := loadRepoBuckets(cfg)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.RepoBuckets = repos
+
 	return cfg, nil
 }
 
+// loadRepoBuckets reads S3_REPOS (comma-separated repository names) and the
+// S3_REPO_<NAME>_* overrides for each of them.
+func loadRepoBuckets(cfg Config) ([]RepoBucket, error) {
+	var repos []RepoBucket
+	for _, repo := range strings.Split(os.Getenv("S3_REPOS"), ",") {
+		repo = strings.Trim(strings.TrimSpace(repo), "/")
+		if repo == "" {
+			continue
+		}
+		if strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("invalid S3_REPOS entry %q: must be a single path segment", repo)
+		}
+		env := "S3_REPO_" + envName(repo) + "_"
+		rb := RepoBucket{
+			Repo:         repo,
+			Bucket:       os.Getenv(env + "BUCKET"),
+			Prefix:       strings.Trim(os.Getenv(env+"PREFIX"), "/"),
+			Region:       getenvDefault(env+"REGION", cfg.Region),
+			Endpoint:     getenvDefault(env+"ENDPOINT", cfg.Endpoint),
+			AccessKey:    getenvDefault(env+"ACCESS_KEY", cfg.AccessKey),
+			SecretKey:    getenvDefault(env+"SECRET_KEY", cfg.SecretKey),
+			UsePathStyle: cfg.UsePathStyle,
+		}
+		if rb.Bucket == "" {
+			return nil, fmt.Errorf("%sBUCKET is required for repository %q", env, repo)
+		}
+		if v := os.Getenv(env + "USE_PATH_STYLE"); v != "" {
+			usePathStyle, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %sUSE_PATH_STYLE: %w", env, err)
+			}
+			rb.UsePathStyle = usePathStyle
+		}
+		repos = append(repos, rb)
+	}
+	return repos, nil
+}
+
+// envName turns a repository name into an env var fragment, e.g.
+// "legacy-releases" -> "LEGACY_RELEASES".
+func envName(repo string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, repo)
+}
+
 func getenvDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
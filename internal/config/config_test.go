@@ -54,3 +54,50 @@ func TestLoadWithOverrides(t *testing.T) {
 	// cleanup env overrides
 	os.Unsetenv("S3_USE_PATH_STYLE")
 }
+
+func TestLoadRepoBuckets(t *testing.T) {
+	t.Setenv("S3_BUCKET", "bucket")
+	t.Setenv("S3_ACCESS_KEY", "default-key")
+	t.Setenv("S3_REPOS", "legacy-releases")
+	t.Setenv("S3_REPO_LEGACY_RELEASES_BUCKET", "old-bucket")
+	t.Setenv("S3_REPO_LEGACY_RELEASES_ENDPOINT", "http://old-minio:9000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if len(cfg.RepoBuckets) != 1 {
+		t.Fatalf("expected one repo bucket, got %+v", cfg.RepoBuckets)
+	}
+	rb := cfg.RepoBuckets[0]
+	if rb.Repo != "legacy-releases" || rb.Bucket != "old-bucket" || rb.Endpoint != "http://old-minio:9000" || rb.AccessKey != "default-key" {
+		t.Fatalf("unexpected repo bucket: %+v", rb)
+	}
+
+	t.Setenv("S3_REPO_LEGACY_RELEASES_BUCKET", "")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error without bucket")
+	}
+}
+
+func TestLoadTaskSchedules(t *testing.T) {
+	t.Setenv("S3_BUCKET", "bucket")
+	t.Setenv("TASK_SCHEDULES", "checksum-scan=0 */6 * * *; usage-report=@every 15m;")
+	t.Setenv("TASKS_DISABLED", "cache-eviction, lifecycle")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.TaskSchedules["checksum-scan"] != "0 */6 * * *" || cfg.TaskSchedules["usage-report"] != "@every 15m" || len(cfg.TaskSchedules) != 2 {
+		t.Fatalf("unexpected schedules: %+v", cfg.TaskSchedules)
+	}
+	if len(cfg.TasksDisabled) != 2 || cfg.TasksDisabled[1] != "lifecycle" {
+		t.Fatalf("unexpected disabled tasks: %+v", cfg.TasksDisabled)
+	}
+
+	t.Setenv("TASK_SCHEDULES", "checksum-scan")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for entry without schedule")
+	}
+}
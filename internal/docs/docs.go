@@ -15,145 +15,225 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/catalog": {
+        "/api/v1/admin/integrity": {
             "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
+                "description": "Progress of the running integrity check or the result of the last one, listing up to 1000 mismatches.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "catalog"
-                ],
-                "summary": "List artifacts",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Path prefix (non-recursive); root by default",
-                        "name": "path",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 100,
-                        "description": "Max items",
-                        "name": "limit",
-                        "in": "query"
-                    }
+                    "admin"
                 ],
+                "summary": "Integrity check report",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/storage.Entry"
-                            }
+                            "$ref": "#/definitions/server.IntegrityReport"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
-            }
-        },
-        "/healthz": {
-            "get": {
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Recomputes the CHECKSUM_ALGORITHMS digests of every artifact under prefix in the background and compares them to the stored sidecars, and the MD5 to the S3 ETag (single-part uploads only; set etag=false for SSE-KMS/SSE-C buckets). Poll GET for the report.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "admin"
+                ],
+                "summary": "Start an integrity check",
+                "parameters": [
+                    {
+                        "description": "Prefix to verify (default: whole bucket)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/server.IntegrityRequest"
+                        }
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
-                    "200": {
-                        "description": "ok",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.IntegrityReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/packages/{artifactPath}": {
+        "/api/v1/admin/loglevel": {
             "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
+                "description": "GET returns the current level; PUT switches it at runtime (debug, info, warn, error). Changes are not persisted.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
-                    "packages"
+                    "admin"
+                ],
+                "summary": "Get or change the log level",
+                "parameters": [
+                    {
+                        "description": "New level (PUT only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/server.LogLevelPayload"
+                        }
+                    }
                 ],
-                "summary": "Group repository (packages) GET/HEAD",
                 "responses": {
-                    "404": {
-                        "description": "Not Found",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.LogLevelPayload"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
             },
-            "head": {
+            "put": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
+                "description": "GET returns the current level; PUT switches it at runtime (debug, info, warn, error). Changes are not persisted.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
-                    "packages"
+                    "admin"
+                ],
+                "summary": "Get or change the log level",
+                "parameters": [
+                    {
+                        "description": "New level (PUT only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/server.LogLevelPayload"
+                        }
+                    }
                 ],
-                "summary": "Group repository (packages) GET/HEAD",
                 "responses": {
-                    "404": {
-                        "description": "Not Found",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.LogLevelPayload"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/proxies": {
-            "get": {
+        "/api/v1/admin/metadata/rebuild": {
+            "post": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
+                "description": "Regenerates the artifact-level maven-metadata.xml (and checksums) of every artifact under path from the versions actually stored, to repair drift after manual bucket edits or partial migrations.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "proxies"
+                    "admin"
+                ],
+                "summary": "Rebuild maven-metadata.xml",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Artifact directory or prefix (e.g. releases/com/acme/app)",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
                 ],
-                "summary": "List proxy repositories",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/server.Proxy"
-                            }
+                            "$ref": "#/definitions/server.MetadataRebuildResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/v1/admin/relocations": {
             "post": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
+                "description": "Writes a relocation POM (with checksums) at the old coordinates pointing to the new ones and refreshes the artifact's maven-metadata.xml. Empty target fields keep the old value.",
                 "consumes": [
                     "application/json"
                 ],
@@ -161,17 +241,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "proxies"
+                    "admin"
                 ],
-                "summary": "Create proxy repository",
+                "summary": "Publish a relocation POM",
                 "parameters": [
                     {
-                        "description": "Proxy configuration",
-                        "name": "proxy",
+                        "description": "Old and new coordinates",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/server.Proxy"
+                            "$ref": "#/definitions/server.RelocationRequest"
                         }
                     }
                 ],
@@ -179,125 +259,158 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.RelocationResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/proxies/{name}": {
-            "put": {
+        "/api/v1/admin/tasks": {
+            "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "consumes": [
+                "description": "Every registered task with its schedule, next activation, the run in progress and the last finished run (status succeeded, failed, canceled or interrupted, duration and error).",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List background tasks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.TaskStatus"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/tasks/{id}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Cancels the running task run with this ID. The task stops at its next cancellation point and the run is recorded as canceled.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "proxies"
+                    "admin"
                 ],
-                "summary": "Update proxy repository",
+                "summary": "Cancel a task run",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Proxy name",
-                        "name": "name",
+                        "description": "Run ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Proxy configuration",
-                        "name": "proxy",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/server.Proxy"
-                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Updated",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.TaskRun"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/api/v1/admin/tasks/{name}/run": {
+            "post": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
+                "description": "Starts a run of the task in the background, also for tasks without a schedule. Poll GET /api/v1/admin/tasks for the outcome.",
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "proxies"
+                    "admin"
                 ],
-                "summary": "Delete proxy repository",
+                "summary": "Run a task now",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Proxy name",
+                        "description": "Task name",
                         "name": "name",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Deleted",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.TaskRun"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/{artifactPath}": {
+        "/api/v1/admin/tasks/{name}/runs": {
             "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
+                "description": "The run in progress, if any, followed by the last 20 finished runs of the task, newest first. History is kept in the bucket, so it survives restarts; runs cut short by a restart are listed as interrupted.",
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
-                    "artifacts"
+                    "admin"
                 ],
-                "summary": "Download artifact",
+                "summary": "Task run history",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Artifact path (maps to S3 key with optional prefix)",
-                        "name": "artifactPath",
+                        "description": "Task name",
+                        "name": "name",
                         "in": "path",
                         "required": true
                     }
@@ -306,113 +419,3663 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.TaskRun"
+                            }
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/api/v1/attestations": {
+            "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/octet-stream"
-                ],
+                "description": "Returns the in-toto attestations (SLSA provenance and others) stored for an artifact, optionally only those with the given predicateType. Deploy tokens need read scope for the path.",
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
                     "artifacts"
                 ],
-                "summary": "Upload artifact",
+                "summary": "List attestations",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Artifact path (maps to S3 key with optional prefix)",
-                        "name": "artifactPath",
-                        "in": "path",
+                        "description": "Artifact path",
+                        "name": "path",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this predicate type",
+                        "name": "predicateType",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.AttestationsResponse"
                         }
-                    }
-                }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
             },
-            "head": {
+            "post": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
+                "description": "Stores in-toto attestations for an artifact: a DSSE envelope or a bare statement, or several of them one per line (.intoto.jsonl). Each must name the artifact's SHA-256 or SHA-1 digest as a subject. They are appended to \u003cpath\u003e.intoto.jsonl, which is served like any file, and the artifact's attestation.predicateTypes and attestation.count properties are updated. Identical documents are stored once. Signatures are not verified. Deploy tokens need write scope for the path.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "artifacts"
                 ],
-                "summary": "Artifact metadata",
+                "summary": "Attach attestations",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Artifact path (maps to S3 key with optional prefix)",
-                        "name": "artifactPath",
-                        "in": "path",
+                        "description": "Artifact path",
+                        "name": "path",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "description": "DSSE envelope or in-toto statement",
+                        "name": "attestation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.AttestationsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "server.Proxy": {
-            "type": "object",
-            "properties": {
-                "name": {
-                    "type": "string"
-                },
-                "url": {
-                    "type": "string"
+        },
+        "/api/v1/bundle": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Packages one release version (path is its directory, e.g. releases/com/acme/lib/1.0) as a zip in the layout the Sonatype Central Portal accepts for upload: every file with its .asc signature and .md5/.sha1 checksums, computed from the stored content. Answers 422 and lists what is missing when the POM, the main artifact, the sources or javadoc jar, or a signature is absent; POM-packaged versions need only the POM. SNAPSHOT versions are rejected. Deploy tokens need read scope for the path.",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Export a Central bundle",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Version directory",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "storage.Entry": {
-            "type": "object",
-            "properties": {
-                "name": {
-                    "type": "string"
-                },
-                "path": {
-                    "type": "string"
-                },
-                "size": {
-                    "type": "integer"
-                },
-                "type": {
-                    "description": "file, dir, proxy",
+        "/api/v1/catalog": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Entries carry their size, last-modified time, ETag and source: \"local\" for hosted content, the proxy name for proxied content. Proxied entries get what the upstream index page shows, completed from the cached copy. HEAD answers with the headers only and skips upstream listings, for cheap availability probes.",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "text/csv"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "List artifacts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path prefix (non-recursive); root by default",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Max items",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Continue from the X-Next-Cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json, xml or csv; without it the Accept header decides, JSON by default",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fill sha1 of files from their listed .sha1 sidecars",
+                        "name": "checksums",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/storage.Entry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Entries carry their size, last-modified time, ETag and source: \"local\" for hosted content, the proxy name for proxied content. Proxied entries get what the upstream index page shows, completed from the cached copy. HEAD answers with the headers only and skips upstream listings, for cheap availability probes.",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "text/csv"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "List artifacts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path prefix (non-recursive); root by default",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Max items",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Continue from the X-Next-Cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json, xml or csv; without it the Accept header decides, JSON by default",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fill sha1 of files from their listed .sha1 sidecars",
+                        "name": "checksums",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/storage.Entry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/checksum/{algorithm}/{digest}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Returns every path whose content has the given SHA-1 or SHA-256, e.g. to locate a known-bad binary. Backed by the checksum index, which covers uploads as they happen and other artifacts (proxy caches, copies) once the checksum-index task has seen their .sha1/.sha256 sidecars.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Find artifacts by checksum",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "sha1 or sha256",
+                        "name": "algorithm",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hex digest",
+                        "name": "digest",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.ChecksumLookupResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/dependents/{groupId}/{artifactId}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Lists the hosted POMs that declare a dependency on groupId:artifactId, directly or in dependencyManagement, e.g. to find what must be rebuilt when a library has a vulnerability. version keeps only dependents asking for that version. With transitive=true the dependents of dependents are added too (up to 10 levels), following compile and runtime dependencies that are neither optional nor managed. Backed by the dependency index, which covers uploads as they happen and other POMs once the dependency-index task has seen them. Dependents a deploy token cannot read are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Find dependents of an artifact",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "groupId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Artifact ID",
+                        "name": "artifactId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Dependency version",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include indirect dependents",
+                        "name": "transitive",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.DependentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/diff": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Compares the entries of two stored archives (jar, war, ear, aar, zip, tar, tar.gz, tgz) by name, size and CRC-32, and lists what path2 added, removed and changed relative to path1. Timestamps are ignored, so rebuilding identical content reports no change. Directories are left out. Archives are limited to 2 GiB. Deploy tokens need read scope for both paths.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Compare two archives",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Archive to compare from",
+                        "name": "path1",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Archive to compare to",
+                        "name": "path2",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.ArchiveDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/events/s3": {
+            "post": {
+                "description": "Receives bucket notifications for objects written or deleted outside Heimdall (replication, manual fixes), either straight from MinIO webhook targets or through an SNS HTTP(S) subscription, which is confirmed automatically. Each event refreshes the lookup caches for the key and, shortly after, the usage gauges of its repository. Enabled by S3_EVENTS_TOKEN; the token goes in the token query parameter or as a Bearer token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Ingest S3 event notifications",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "S3_EVENTS_TOKEN",
+                        "name": "token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Processed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/exists": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Checks up to 1000 artifact paths in one request and returns, in request order, whether each exists with its size, last-modified time and stored SHA-1/MD5 checksums. Deploy tokens need read scope for each path.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Batch existence check",
+                "parameters": [
+                    {
+                        "description": "Artifact paths",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.ExistsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.ExistsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/exists/{artifactPath}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Answers 200 when the artifact is stored and 404 when it is not, both without a body, so monitoring probes and pipelines can check availability without downloading anything. HEAD answers the same. Last-Modified is set when it exists. Deploy tokens need read scope for the path.",
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Check that an artifact exists",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Artifact path",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Exists"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found"
+                    }
+                }
+            },
+            "head": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Answers 200 when the artifact is stored and 404 when it is not, both without a body, so monitoring probes and pipelines can check availability without downloading anything. HEAD answers the same. Last-Modified is set when it exists. Deploy tokens need read scope for the path.",
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Check that an artifact exists",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Artifact path",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Exists"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found"
+                    }
+                }
+            }
+        },
+        "/api/v1/feed.atom": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Lists the versions most recently published to hosted repositories under path, newest first, one entry per POM with its coordinates and upload time. SNAPSHOT versions are left out unless snapshots=true. The bucket is walked at most every 5 minutes per path. Versions a deploy token cannot read are left out.",
+                "produces": [
+                    "text/xml"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Atom feed of published versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path prefix, e.g. releases/com/acme",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Max entries (1-500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include SNAPSHOT versions",
+                        "name": "snapshots",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Atom feed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/graphql": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Queries repositories, artifacts, versions, files, sizes, download times and properties in one request. GET with ?query= or POST a GraphQL request; GET ?sdl returns the schema. Fragments, directives and introspection are not supported. POST bodies are limited to 1 MiB (413) and queries to 32 nesting levels.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Read-only GraphQL query",
+                "parameters": [
+                    {
+                        "description": "GraphQL request (POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/server.GraphQLRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "GraphQL query (GET)",
+                        "name": "query",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.GraphQLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Queries repositories, artifacts, versions, files, sizes, download times and properties in one request. GET with ?query= or POST a GraphQL request; GET ?sdl returns the schema. Fragments, directives and introspection are not supported. POST bodies are limited to 1 MiB (413) and queries to 32 nesting levels.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Read-only GraphQL query",
+                "parameters": [
+                    {
+                        "description": "GraphQL request (POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/server.GraphQLRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "GraphQL query (GET)",
+                        "name": "query",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.GraphQLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/history/{key}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Lists all S3 versions and delete markers of an object, newest first. Requires bucket versioning.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "List object versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Object path",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.HistoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/peek/{artifactPath}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Lists the files inside a stored archive (jar, war, ear, aar, zip, tar, tar.gz, tgz) with their size and CRC-32, sorted by name, so clients can check whether a class or resource is present without downloading it. q keeps only the entries whose name contains it. Directories are left out. Archives are limited to 2 GiB. Deploy tokens need read scope for the path.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "List the entries of an archive",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Archive path",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Substring the entry names must contain",
+                        "name": "q",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.ArchiveListing"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pom/{artifactPath}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Returns the coordinates, parent, dependencies, managed dependencies, licenses and SCM information of a stored POM. ${...} references are expanded from the POM's own properties and project coordinates; those that cannot be are returned as written. The parent POM is not read, so inherited dependencies and properties are missing. POMs are limited to 1 MiB. Deploy tokens need read scope for the path.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Parse a POM",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "POM path",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.POM"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/prefetch": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Fetches artifacts through the caching proxies in the background, so they are in the bucket before upstreams become unreachable. artifacts takes coordinates (groupId:artifactId[:extension[:classifier]]:version, fetching the file and its POM) or paths, starting with a proxy name or not; pom takes a POM whose dependencies, dependency management entries and parent to fetch; lockfile takes a Gradle lockfile. Poll GET /api/v1/prefetch/{id} for the outcome.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "Prefetch artifacts",
+                "parameters": [
+                    {
+                        "description": "Artifacts to fetch",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.PrefetchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/server.PrefetchJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/prefetch/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Progress of a prefetch job: done counts the artifacts handled so far; stats splits them into already cached, fetched, missing upstream and failed. The last 50 jobs are kept in memory.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "Prefetch status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.PrefetchJob"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/proxies": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Each proxy carries its revision, to be sent in If-Match when updating or deleting it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "List proxy repositories",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.Proxy"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "Create proxy repository",
+                "parameters": [
+                    {
+                        "description": "Proxy configuration",
+                        "name": "proxy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.Proxy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/proxies/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Returns the proxy with its revision, also sent as the ETag header.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "Get proxy repository",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Proxy name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.Proxy"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Requires the revision read from GET in If-Match (\"*\" overwrites whatever is stored); a proxy changed in the meantime is rejected with 409.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "Update proxy repository",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Proxy name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revision of the proxy",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Proxy configuration",
+                        "name": "proxy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.Proxy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Requires the revision read from GET in If-Match (\"*\" deletes whatever is stored); a proxy changed in the meantime is rejected with 409.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "Delete proxy repository",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Proxy name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revision of the proxy",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/proxies/{name}/invalidate": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Deletes cached objects (and their checksum sidecars) matching a path or a glob pattern relative to the proxy; \"**\" matches across directories.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "Invalidate cached proxy artifacts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Proxy name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Path or pattern to purge",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.InvalidateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.InvalidateResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Makes a previous version the current one. The checksum sidecars are restored to the versions written alongside it. A newer live version is only replaced when force is set; otherwise the request answers 409.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Restore object version",
+                "parameters": [
+                    {
+                        "description": "Object path and version",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.RestoreRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.RestoreResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sbom/{artifactPath}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Returns the SBOM stored for an artifact with its media type. format picks one when several are stored: cyclonedx-json, cyclonedx-xml, spdx-json or spdx-tag-value, or cyclonedx/spdx for either encoding; without it CycloneDX JSON is preferred. Deploy tokens need read scope for the path.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Download an artifact's SBOM",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Artifact path",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "SBOM format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Stores a CycloneDX (JSON or XML) or SPDX (JSON or tag-value) SBOM for an existing artifact as \u003cartifactPath\u003e.cdx.json, .cdx.xml, .spdx.json or .spdx, detected from the content, with checksum sidecars. The artifact's sbom.formats property lists the stored formats. Deploy tokens need write scope for the path.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Upload an artifact's SBOM",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Artifact path",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "CycloneDX or SPDX document",
+                        "name": "sbom",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/server.SBOMUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sign": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Returns a time-limited HMAC-signed URL for one artifact that can be downloaded (GET/HEAD) without credentials.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Create signed download URL",
+                "parameters": [
+                    {
+                        "description": "Artifact path and lifetime",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.SignRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.SignResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stats/cache": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Hit/miss counts, bytes served from cache vs upstream and estimated bandwidth saved per proxy since startup.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "proxies"
+                ],
+                "summary": "Proxy cache statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.CacheStatsReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tokens": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "List deploy tokens",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.DeployToken"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Creates a token scoped to a path prefix or glob and a set of verbs (read, write). The secret is only returned once; use the token id as Basic Auth username and the secret as password.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Create deploy token",
+                "parameters": [
+                    {
+                        "description": "Token scope",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.CreateTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/server.CreateTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tokens/revoked": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "List revoked deploy tokens",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.DeployToken"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tokens/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Marks the token revoked; it stays listed under /tokens/revoked.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Revoke deploy token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Revoked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tokens/{id}/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Issues a replacement token with the same scope; the old token keeps working for the grace period (default 15m).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Rotate deploy token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Grace period",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/server.RotateTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/server.CreateTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Token revoked or expired",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tus": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Creates a tus 1.0.0 upload of Upload-Length bytes. Upload-Metadata must carry path (the artifact path; one ending in / takes the filename entry) and may carry filetype. Send the content with PATCH to the returned Location; the artifact is stored like a PUT of path once the last byte arrives. Deploy tokens need write scope for the path.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Start a resumable upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Total size in bytes",
+                        "name": "Upload-Length",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "path and optional filename/filetype, base64-encoded",
+                        "name": "Upload-Metadata",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created; Location names the upload"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tus/{id}": {
+            "patch": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Appends the body to the upload at Upload-Offset, which must equal the bytes received so far (see HEAD). Bytes received before a dropped connection are kept. When the upload is complete the artifact is stored like a PUT of its path, with the same checks, and S3 joins the stored chunks with a multipart upload instead of receiving the artifact again; if that fails, the upload keeps its previous offset and the error is returned.",
+                "consumes": [
+                    "application/offset+octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Continue a resumable upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset of the body",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Upload-Offset holds the new offset"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/upload": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Stores the file field of a multipart/form-data body under path, like a PUT of the artifact path: the same layout, policy, antivirus and signing checks apply and the same JSON describes the stored file. A path ending in / takes the file name of the part. Each property field (name=value) is set on the artifact. Deploy tokens need write scope for the path.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Upload an artifact from a form",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Artifact content",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target path, e.g. releases/com/acme/lib/1.0/lib-1.0.jar",
+                        "name": "path",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Property as name=value; repeatable",
+                        "name": "property",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/server.DeployResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/usage": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Object count and total bytes per repository from the last scheduled walk (USAGE_REPORT_INTERVAL). Computed on demand when no report exists yet or with refresh=true.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Storage usage per repository",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Walk the bucket now instead of returning the cached report",
+                        "name": "refresh",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.UsageReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Checks the bucket (with the latency of a HEAD), the temp directory uploads are buffered in, every proxy upstream and the task scheduler. A failing bucket or temp directory makes the status \"down\" and the response 503; other failures make it \"degraded\" and keep 200. Reports are cached for 10s. Use /livez for liveness probes.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.HealthReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/livez": {
+            "get": {
+                "description": "Answers as long as the process serves requests, without checking dependencies.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness check",
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/packages/{artifactPath}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "packages"
+                ],
+                "summary": "Group repository (packages) GET/HEAD",
+                "responses": {
+                    "403": {
+                        "description": "Blocked by policy",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "packages"
+                ],
+                "summary": "Group repository (packages) GET/HEAD",
+                "responses": {
+                    "403": {
+                        "description": "Blocked by policy",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Build information",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/version.Info"
+                        }
+                    }
+                }
+            }
+        },
+        "/{artifactPath}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Download artifact",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Artifact path (maps to S3 key with optional prefix)",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "302": {
+                        "description": "Signed CDN URL (CDN_URL)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Blocked by policy",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Stores the body under the path. The 201 response carries a Location header and a JSON body with the stored key, size and SHA-1, MD5 and SHA-256, like Artifactory's deploy response.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Upload artifact",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Artifact path (maps to S3 key with optional prefix)",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/server.DeployResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Write-once path already holds different content",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Infected file or rejected by the signing service",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Virus scanner or signing service unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "tags": [
+                    "artifacts"
+                ],
+                "summary": "Artifact metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Artifact path (maps to S3 key with optional prefix)",
+                        "name": "artifactPath",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "server.ArchiveChange": {
+            "type": "object",
+            "properties": {
+                "crc32_1": {
+                    "type": "string",
+                    "example": "1c291ca3"
+                },
+                "crc32_2": {
+                    "type": "string",
+                    "example": "9e83486d"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "com/acme/lib/Util.class"
+                },
+                "size1": {
+                    "type": "integer",
+                    "example": 2048
+                },
+                "size2": {
+                    "type": "integer",
+                    "example": 2112
+                }
+            }
+        },
+        "server.ArchiveDiff": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.ArchiveEntry"
+                    }
+                },
+                "changed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.ArchiveChange"
+                    }
+                },
+                "path1": {
+                    "type": "string",
+                    "example": "releases/com/acme/lib/1.0-RC1/lib-1.0-RC1.jar"
+                },
+                "path2": {
+                    "type": "string",
+                    "example": "releases/com/acme/lib/1.0-RC2/lib-1.0-RC2.jar"
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.ArchiveEntry"
+                    }
+                },
+                "unchanged": {
+                    "type": "integer",
+                    "example": 112
+                }
+            }
+        },
+        "server.ArchiveEntry": {
+            "type": "object",
+            "properties": {
+                "crc32": {
+                    "type": "string",
+                    "example": "1c291ca3"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "com/acme/lib/Util.class"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 2048
+                }
+            }
+        },
+        "server.ArchiveListing": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.ArchiveEntry"
+                    }
+                },
+                "path": {
+                    "type": "string",
+                    "example": "releases/com/acme/lib/1.0/lib-1.0.jar"
+                }
+            }
+        },
+        "server.Attestation": {
+            "type": "object",
+            "properties": {
+                "document": {
+                    "type": "object"
+                },
+                "predicateType": {
+                    "type": "string",
+                    "example": "https://slsa.dev/provenance/v1"
+                },
+                "signed": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "server.AttestationsResponse": {
+            "type": "object",
+            "properties": {
+                "attestations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.Attestation"
+                    }
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.CacheStatsReport": {
+            "type": "object",
+            "properties": {
+                "proxies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.ProxyCacheStats"
+                    }
+                },
+                "since": {
+                    "type": "string"
+                },
+                "total": {
+                    "$ref": "#/definitions/server.ProxyCacheStats"
+                }
+            }
+        },
+        "server.ChecksumLookupResponse": {
+            "type": "object",
+            "properties": {
+                "algorithm": {
+                    "type": "string",
+                    "example": "sha1"
+                },
+                "digest": {
+                    "type": "string"
+                },
+                "matches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.ChecksumMatch"
+                    }
+                }
+            }
+        },
+        "server.ChecksumMatch": {
+            "type": "object",
+            "properties": {
+                "lastModified": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.Coordinates": {
+            "type": "object",
+            "properties": {
+                "artifactId": {
+                    "type": "string",
+                    "example": "app"
+                },
+                "groupId": {
+                    "type": "string",
+                    "example": "com.acme"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "1.0"
+                }
+            }
+        },
+        "server.CreateTokenRequest": {
+            "type": "object",
+            "properties": {
+                "dailyBytes": {
+                    "type": "integer"
+                },
+                "dailyRequests": {
+                    "description": "DailyRequests and DailyBytes override the default daily budgets\n(TOKEN_DAILY_REQUESTS, TOKEN_DAILY_BYTES); 0 lifts the limit.",
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "expiresIn": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "verbs": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "server.CreateTokenResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "dailyBytes": {
+                    "type": "integer"
+                },
+                "dailyRequests": {
+                    "description": "DailyRequests and DailyBytes override the instance's default daily\nbudgets for this token; 0 lifts the limit.",
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "rotatedTo": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "verbs": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "server.Dependent": {
+            "type": "object",
+            "properties": {
+                "artifactId": {
+                    "type": "string",
+                    "example": "app"
+                },
+                "dependencyVersion": {
+                    "description": "DependencyVersion is the version asked for, as written when it could\nnot be resolved; empty when it comes from dependency management.",
+                    "type": "string",
+                    "example": "1.4.2"
+                },
+                "depth": {
+                    "description": "Depth is 1 for direct dependents, 2 for their dependents, and so on.",
+                    "type": "integer",
+                    "example": 1
+                },
+                "groupId": {
+                    "type": "string",
+                    "example": "com.acme"
+                },
+                "managed": {
+                    "description": "Managed marks dependencyManagement entries, such as imported BOMs.",
+                    "type": "boolean"
+                },
+                "optional": {
+                    "type": "boolean"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "releases/com/acme/app/2.1/app-2.1.pom"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "compile"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "2.1"
+                }
+            }
+        },
+        "server.DependentsResponse": {
+            "type": "object",
+            "properties": {
+                "artifactId": {
+                    "type": "string",
+                    "example": "lib"
+                },
+                "dependents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.Dependent"
+                    }
+                },
+                "groupId": {
+                    "type": "string",
+                    "example": "com.acme"
+                }
+            }
+        },
+        "server.DeployChecksums": {
+            "type": "object",
+            "properties": {
+                "md5": {
+                    "type": "string"
+                },
+                "sha1": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.DeployResponse": {
+            "type": "object",
+            "properties": {
+                "checksums": {
+                    "$ref": "#/definitions/server.DeployChecksums"
+                },
+                "created": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string",
+                    "example": "ci"
+                },
+                "downloadUri": {
+                    "type": "string",
+                    "example": "https://repo.example.com/releases/com/acme/lib/1.0/lib-1.0.jar"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "releases/com/acme/lib/1.0/lib-1.0.jar"
+                },
+                "mimeType": {
+                    "type": "string",
+                    "example": "application/java-archive"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/com/acme/lib/1.0/lib-1.0.jar"
+                },
+                "repo": {
+                    "type": "string",
+                    "example": "releases"
+                },
+                "size": {
+                    "type": "string",
+                    "example": "1024"
+                }
+            }
+        },
+        "server.DeployToken": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "dailyBytes": {
+                    "type": "integer"
+                },
+                "dailyRequests": {
+                    "description": "DailyRequests and DailyBytes override the instance's default daily\nbudgets for this token; 0 lifts the limit.",
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "rotatedTo": {
+                    "type": "string"
+                },
+                "verbs": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "server.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "invalid json"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "invalid json"
+                },
+                "requestId": {
+                    "type": "string",
+                    "example": "6f1c0e2ab3d94c57"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Bad Request"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "server.ExistsRequest": {
+            "type": "object",
+            "properties": {
+                "paths": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "releases/com/acme/app/1.0/app-1.0.jar"
+                    ]
+                }
+            }
+        },
+        "server.ExistsResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.ExistsResult"
+                    }
+                }
+            }
+        },
+        "server.ExistsResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error is set when the path could not be checked (invalid, forbidden\nfor the token, storage failure); Exists is false then.",
+                    "type": "string"
+                },
+                "exists": {
+                    "type": "boolean"
+                },
+                "lastModified": {
+                    "type": "string"
+                },
+                "md5": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "sha1": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.GraphQLRequest": {
+            "type": "object",
+            "properties": {
+                "operationName": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "{ repositories { name type } }"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "server.GraphQLResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.gqlError"
+                    }
+                }
+            }
+        },
+        "server.HealthCheck": {
+            "type": "object",
+            "properties": {
+                "critical": {
+                    "type": "boolean"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "latencyMs": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "storage"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "server.HealthReport": {
+            "type": "object",
+            "properties": {
+                "checkedAt": {
+                    "type": "string"
+                },
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.HealthCheck"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "server.HistoryResponse": {
+            "type": "object",
+            "properties": {
+                "path": {
+                    "type": "string"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.ObjectVersion"
+                    }
+                }
+            }
+        },
+        "server.IntegrityMismatch": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "type": "string"
+                },
+                "expected": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.IntegrityReport": {
+            "type": "object",
+            "properties": {
+                "checkETag": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "mismatched": {
+                    "type": "integer"
+                },
+                "mismatches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.IntegrityMismatch"
+                    }
+                },
+                "objects": {
+                    "type": "integer"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "quarantined": {
+                    "description": "Quarantined lists artifacts moved to quarantine by this run.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "resumedFrom": {
+                    "description": "ResumedFrom is set when a scheduled run continues one interrupted by\na restart; artifacts up to this key were verified by that run.",
+                    "type": "string"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "startedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.IntegrityRequest": {
+            "type": "object",
+            "properties": {
+                "etag": {
+                    "description": "ETag defaults to true; disable it for buckets using SSE-KMS/SSE-C,\nwhose ETags are not MD5 digests.",
+                    "type": "boolean"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "releases"
+                }
+            }
+        },
+        "server.InvalidateRequest": {
+            "type": "object",
+            "properties": {
+                "path": {
+                    "type": "string"
+                },
+                "pattern": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.InvalidateResult": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "server.LogLevelPayload": {
+            "type": "object",
+            "properties": {
+                "level": {
+                    "type": "string",
+                    "example": "debug"
+                }
+            }
+        },
+        "server.MetadataRebuildResponse": {
+            "type": "object",
+            "properties": {
+                "rebuilt": {
+                    "description": "Rebuilt lists the maven-metadata.xml keys that were written.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "server.POM": {
+            "type": "object",
+            "properties": {
+                "artifactId": {
+                    "type": "string",
+                    "example": "lib"
+                },
+                "dependencies": {
+                    "description": "Dependencies and ManagedDependencies keep the POM's order.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.POMDependency"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "groupId": {
+                    "type": "string",
+                    "example": "com.acme"
+                },
+                "licenses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.POMLicense"
+                    }
+                },
+                "managedDependencies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.POMDependency"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Acme Lib"
+                },
+                "packaging": {
+                    "type": "string",
+                    "example": "jar"
+                },
+                "parent": {
+                    "$ref": "#/definitions/server.Coordinates"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "releases/com/acme/lib/1.0/lib-1.0.pom"
+                },
+                "scm": {
+                    "$ref": "#/definitions/server.POMSCM"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://acme.example.com/lib"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "1.0"
+                }
+            }
+        },
+        "server.POMDependency": {
+            "type": "object",
+            "properties": {
+                "artifactId": {
+                    "type": "string",
+                    "example": "slf4j-api"
+                },
+                "classifier": {
+                    "type": "string"
+                },
+                "groupId": {
+                    "type": "string",
+                    "example": "org.slf4j"
+                },
+                "optional": {
+                    "type": "boolean"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "compile"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "jar"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "2.0.13"
+                }
+            }
+        },
+        "server.POMLicense": {
+            "type": "object",
+            "properties": {
+                "distribution": {
+                    "type": "string",
+                    "example": "repo"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Apache-2.0"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://www.apache.org/licenses/LICENSE-2.0"
+                }
+            }
+        },
+        "server.POMSCM": {
+            "type": "object",
+            "properties": {
+                "connection": {
+                    "type": "string",
+                    "example": "scm:git:https://github.com/acme/lib.git"
+                },
+                "developerConnection": {
+                    "type": "string"
+                },
+                "tag": {
+                    "type": "string",
+                    "example": "v1.0"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://github.com/acme/lib"
+                }
+            }
+        },
+        "server.PrefetchJob": {
+            "type": "object",
+            "properties": {
+                "done": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "stats": {
+                    "$ref": "#/definitions/server.WarmupStats"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "unresolved": {
+                    "description": "Unresolved lists POM dependencies skipped because they have no version\nor use a property the POM does not define.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "server.PrefetchRequest": {
+            "type": "object",
+            "properties": {
+                "artifacts": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "org.apache.commons:commons-lang3:3.14.0"
+                    ]
+                },
+                "lockfile": {
+                    "type": "string"
+                },
+                "pom": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.Proxy": {
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "description": "Cache set to false streams upstream responses straight to the client\nwithout storing them in S3.",
+                    "type": "boolean"
+                },
+                "canaryUrl": {
+                    "description": "CanaryURL is a second upstream queried alongside URL for every\nartifact fetched into the cache; differences in status or content are\nlogged. Meant to prove two mirrors equivalent before switching.",
+                    "type": "string"
+                },
+                "declared": {
+                    "description": "Declared is set on proxies defined in the proxies file (PROXIES_FILE),\nwhich can only be changed by editing that file. Ignored on input.",
+                    "type": "boolean"
+                },
+                "maxAge": {
+                    "description": "MaxAge is a Go duration (e.g. \"24h\") after which cached artifacts are\nrevalidated against the upstream on access; empty disables expiry.",
+                    "type": "string"
+                },
+                "maxCacheBytes": {
+                    "description": "MaxCacheBytes caps the cached bytes kept for this proxy; least recently\ndownloaded artifacts are evicted by the background job. 0 means no cap.",
+                    "type": "integer"
+                },
+                "mirrors": {
+                    "description": "Mirrors are tried in order when URL, or the mirror before, fails with\na network error or a 5xx status.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revision": {
+                    "description": "Revision identifies the stored configuration and changes with every\nwrite; updates and deletes must send it in If-Match. Ignored on input.",
+                    "type": "string"
+                },
+                "updatePolicy": {
+                    "description": "UpdatePolicy follows Maven's updatePolicy for upstream misses and\ncached maven-metadata.xml: always, never, daily (default) or\ninterval:N minutes.",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.ProxyCacheStats": {
+            "type": "object",
+            "properties": {
+                "bytesFromCache": {
+                    "type": "integer"
+                },
+                "bytesFromUpstream": {
+                    "type": "integer"
+                },
+                "bytesSaved": {
+                    "description": "BytesSaved estimates upstream bandwidth avoided: every cache hit would\notherwise have been downloaded again.",
+                    "type": "integer"
+                },
+                "hitRatio": {
+                    "type": "number"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "passthrough": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.RelocationRequest": {
+            "type": "object",
+            "properties": {
+                "force": {
+                    "description": "Force replaces an existing POM at the old coordinates.",
+                    "type": "boolean"
+                },
+                "from": {
+                    "$ref": "#/definitions/server.Coordinates"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "app moved to com.acme.platform"
+                },
+                "repository": {
+                    "description": "Repository is the hosted repository (first path segment) to publish\ninto; empty publishes at the bucket root.",
+                    "type": "string",
+                    "example": "releases"
+                },
+                "to": {
+                    "description": "To may leave fields empty to keep the old value, as Maven does.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/server.Coordinates"
+                        }
+                    ]
+                }
+            }
+        },
+        "server.RelocationResponse": {
+            "type": "object",
+            "properties": {
+                "metadata": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.RepositoryUsage": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "objects": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.RestoreRequest": {
+            "type": "object",
+            "properties": {
+                "force": {
+                    "description": "Force replaces a newer live version. Without it the restore only\nproceeds when the object was deleted or the version is already current.",
+                    "type": "boolean"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "versionId": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.RestoreResponse": {
+            "type": "object",
+            "properties": {
+                "path": {
+                    "type": "string"
+                },
+                "sidecars": {
+                    "description": "Sidecars maps each restored sidecar key to the version it was restored from.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "versionId": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.RotateTokenRequest": {
+            "type": "object",
+            "properties": {
+                "gracePeriod": {
+                    "description": "GracePeriod keeps the old token valid for a while (default 15m).",
+                    "type": "string"
+                }
+            }
+        },
+        "server.SBOMUploadResponse": {
+            "type": "object",
+            "properties": {
+                "file": {
+                    "type": "string",
+                    "example": "releases/com/acme/lib/1.0/lib-1.0.jar.cdx.json"
+                },
+                "format": {
+                    "type": "string",
+                    "example": "cyclonedx-json"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "releases/com/acme/lib/1.0/lib-1.0.jar"
+                }
+            }
+        },
+        "server.SignRequest": {
+            "type": "object",
+            "properties": {
+                "expiresIn": {
+                    "description": "ExpiresIn is a duration such as 30m; defaults to 1h, capped at 168h.",
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.SignResponse": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.TaskRun": {
+            "type": "object",
+            "properties": {
+                "checkpoint": {
+                    "description": "Checkpoint is the progress last recorded by the task, see\ntaskCheckpoint; ResumedFrom is the checkpoint this run started after.",
+                    "type": "string"
+                },
+                "durationMs": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "resumedFrom": {
+                    "type": "string"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "task": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.TaskStatus": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "lastRun": {
+                    "$ref": "#/definitions/server.TaskRun"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nextRun": {
+                    "type": "string"
+                },
+                "running": {
+                    "$ref": "#/definitions/server.TaskRun"
+                },
+                "schedule": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.UsageReport": {
+            "type": "object",
+            "properties": {
+                "generatedAt": {
+                    "type": "string"
+                },
+                "repositories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.RepositoryUsage"
+                    }
+                },
+                "total": {
+                    "$ref": "#/definitions/server.RepositoryUsage"
+                }
+            }
+        },
+        "server.WarmupStats": {
+            "type": "object",
+            "properties": {
+                "cached": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "fetched": {
+                    "type": "integer"
+                },
+                "missing": {
+                    "type": "integer"
+                },
+                "requested": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.gqlError": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.Entry": {
+            "type": "object",
+            "properties": {
+                "etag": {
+                    "description": "ETag is the object ETag without quotes; SHA1 is the digest read from\nthe .sha1 sidecar, filled by the catalog on request.",
+                    "type": "string"
+                },
+                "lastModified": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "sha1": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Source tells where a catalog entry comes from: \"local\" for hosted\ncontent, or the name of the proxy it is (or is cached) from.",
+                    "type": "string"
+                },
+                "storageClass": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "file, dir, proxy",
+                    "type": "string"
+                }
+            }
+        },
+        "storage.ObjectVersion": {
+            "type": "object",
+            "properties": {
+                "deleteMarker": {
+                    "type": "boolean"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "isLatest": {
+                    "type": "boolean"
+                },
+                "lastModified": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "versionId": {
+                    "type": "string"
+                }
+            }
+        },
+        "version.Info": {
+            "type": "object",
+            "properties": {
+                "commit": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "goVersion": {
+                    "type": "string"
+                },
+                "version": {
                     "type": "string"
                 }
             }
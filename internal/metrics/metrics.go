@@ -1,8 +1,16 @@
 package metrics
 
 import (
+	"cmp"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/otoru/heimdall/internal/version"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -13,9 +21,68 @@ type Registry struct {
 	RequestCount    *prometheus.CounterVec
 	RequestDuration *prometheus.HistogramVec
 	InFlight        prometheus.Gauge
+
+	ChecksumScanObjects  prometheus.Counter
+	ChecksumsCreated     prometheus.Counter
+	BadChecksumsDeleted  prometheus.Counter
+	OrphanedChecksums    prometheus.Counter
+	InvalidChecksums     prometheus.Counter
+	ChecksumLastScan     prometheus.Gauge
+	ChecksumScanDuration prometheus.Histogram
+
+	S3RetriesExhausted *prometheus.CounterVec
+
+	RepoObjects *prometheus.GaugeVec
+	RepoBytes   *prometheus.GaugeVec
+
+	DownloadVerifications *prometheus.CounterVec
+
+	Leader        prometheus.Gauge
+	ConfigReloads prometheus.Counter
+	S3Events      *prometheus.CounterVec
+
+	UpstreamInFlight *prometheus.GaugeVec
+	UpstreamRejected *prometheus.CounterVec
+
+	PrincipalRequests *prometheus.CounterVec
+	PrincipalBytes    *prometheus.CounterVec
+
+	ShadowRequests    *prometheus.CounterVec
+	CanaryComparisons *prometheus.CounterVec
+
+	principalsMu  sync.Mutex
+	principals    map[string]bool
+	maxPrincipals int
+}
+
+type Options struct {
+	// DurationBuckets overrides prometheus.DefBuckets for
+	// heimdall_http_request_duration_seconds.
+	DurationBuckets []float64
+	// NativeHistograms additionally exposes the duration histogram as a
+	// Prometheus native (sparse) histogram.
+	NativeHistograms bool
+	// MaxPrincipals bounds the principal label of the per-principal
+	// metrics; 0 means DefaultMaxPrincipals.
+	MaxPrincipals int
 }
 
+// DefaultMaxPrincipals is the number of principals tracked by name when
+// Options.MaxPrincipals is not set.
+const DefaultMaxPrincipals = 100
+
+// Labels the per-principal metrics use for unauthenticated requests and
+// for principals beyond the limit.
+const (
+	PrincipalAnonymous = "anonymous"
+	PrincipalOther     = "other"
+)
+
 func New() *Registry {
+	return NewWithOptions(Options{})
+}
+
+func NewWithOptions(opts Options) *Registry {
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
@@ -30,14 +97,21 @@ func New() *Registry {
 		[]string{"code", "method"},
 	)
 
-	reqDuration := prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "heimdall_http_request_duration_seconds",
-			Help:    "Duração das requisições HTTP.",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"code", "method"},
-	)
+	buckets := opts.DurationBuckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	durationOpts := prometheus.HistogramOpts{
+		Name:    "heimdall_http_request_duration_seconds",
+		Help:    "Duração das requisições HTTP.",
+		Buckets: buckets,
+	}
+	if opts.NativeHistograms {
+		durationOpts.NativeHistogramBucketFactor = 1.1
+		durationOpts.NativeHistogramMaxBucketNumber = 160
+		durationOpts.NativeHistogramMinResetDuration = time.Hour
+	}
+	reqDuration := prometheus.NewHistogramVec(durationOpts, []string{"code", "method"})
 
 	inFlight := prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "heimdall_http_inflight_requests",
@@ -46,16 +120,197 @@ func New() *Registry {
 
 	reg.MustRegister(reqCount, reqDuration, inFlight)
 
+	scanObjects := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "heimdall_checksum_scan_objects_total",
+		Help: "Objetos verificados pelo scanner de checksums.",
+	})
+	checksumsCreated := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "heimdall_checksums_created_total",
+		Help: "Arquivos .sha1/.md5 gerados pelo scanner de checksums.",
+	})
+	badDeleted := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "heimdall_bad_checksums_deleted_total",
+		Help: "Checksums encadeados (ex.: .sha1.md5) removidos pelo scanner.",
+	})
+	orphaned := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "heimdall_orphaned_checksums_deleted_total",
+		Help: "Arquivos .sha1/.md5 sem artefato correspondente removidos pelo scanner.",
+	})
+	invalid := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "heimdall_invalid_checksums_deleted_total",
+		Help: "Arquivos .sha1/.md5 sem digest hexadecimal válido removidos pelo scanner.",
+	})
+	lastScan := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "heimdall_checksum_last_scan_timestamp_seconds",
+		Help: "Unix timestamp da última execução completa do scanner de checksums.",
+	})
+	scanDuration := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "heimdall_checksum_scan_duration_seconds",
+		Help:    "Duração das execuções do scanner de checksums.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 13),
+	})
+
+	reg.MustRegister(scanObjects, checksumsCreated, badDeleted, orphaned, invalid, lastScan, scanDuration)
+
+	retriesExhausted := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heimdall_s3_retries_exhausted_total",
+		Help: "Chamadas S3 que falharam após esgotar as tentativas de retry, por operação.",
+	}, []string{"operation"})
+	reg.MustRegister(retriesExhausted)
+
+	repoObjects := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "heimdall_repo_objects",
+		Help: "Objetos armazenados por repositório (checksums incluídos), atualizado pelo relatório de uso.",
+	}, []string{"repo", "type"})
+	repoBytes := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "heimdall_repo_bytes",
+		Help: "Bytes armazenados por repositório (checksums incluídos), atualizado pelo relatório de uso.",
+	}, []string{"repo", "type"})
+	reg.MustRegister(repoObjects, repoBytes)
+
+	downloadVerifications := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heimdall_download_verifications_total",
+		Help: "Downloads comparados ao .sha1 armazenado, por resultado (ok, mismatch, missing).",
+	}, []string{"result"})
+	reg.MustRegister(downloadVerifications)
+
+	leader := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "heimdall_leader",
+		Help: "1 se esta réplica detém a liderança e executa as tarefas agendadas, 0 caso contrário.",
+	})
+	reg.MustRegister(leader)
+
+	configReloads := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "heimdall_config_reloads_total",
+		Help: "Recargas da configuração de proxies após mudanças feitas por outra réplica.",
+	})
+	reg.MustRegister(configReloads)
+
+	s3Events := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heimdall_s3_events_total",
+		Help: "Notificações de eventos do bucket recebidas, por resultado (applied, ignored).",
+	}, []string{"result"})
+	reg.MustRegister(s3Events)
+
+	upstreamInFlight := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "heimdall_upstream_inflight",
+		Help: "Downloads em andamento de upstreams de proxy, por proxy.",
+	}, []string{"proxy"})
+	upstreamRejected := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heimdall_upstream_rejected_total",
+		Help: "Downloads de upstream recusados (503) por falta de vaga dentro de UPSTREAM_QUEUE_TIMEOUT, por proxy.",
+	}, []string{"proxy"})
+	reg.MustRegister(upstreamInFlight, upstreamRejected)
+
+	principalRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heimdall_principal_requests_total",
+		Help: "Requisições HTTP por principal autenticado (usuário ou token de deploy).",
+	}, []string{"principal"})
+	principalBytes := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heimdall_principal_bytes_total",
+		Help: "Bytes recebidos (in) e enviados (out) por principal autenticado.",
+	}, []string{"principal", "direction"})
+	reg.MustRegister(principalRequests, principalBytes)
+
+	shadowRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heimdall_shadow_requests_total",
+		Help: "Requisições de leitura reenviadas à instância secundária (SHADOW_URL), por resultado (2xx..5xx, error, dropped).",
+	}, []string{"result"})
+	canaryComparisons := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heimdall_canary_comparisons_total",
+		Help: "Comparações entre o upstream de um proxy e seu canaryUrl, por proxy e resultado (match, mismatch, error, skipped).",
+	}, []string{"proxy", "result"})
+	reg.MustRegister(shadowRequests, canaryComparisons)
+
+	info := version.Get()
+	buildInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "heimdall_build_info",
+		Help: "Informações de build (sempre 1); versão, commit e data nos labels.",
+	}, []string{"version", "commit", "date", "goversion"})
+	buildInfo.WithLabelValues(info.Version, info.Commit, info.Date, info.GoVersion).Set(1)
+	reg.MustRegister(buildInfo)
+
 	return &Registry{
 		Registry:        reg,
 		RequestCount:    reqCount,
 		RequestDuration: reqDuration,
 		InFlight:        inFlight,
+
+		ChecksumScanObjects:  scanObjects,
+		ChecksumsCreated:     checksumsCreated,
+		BadChecksumsDeleted:  badDeleted,
+		OrphanedChecksums:    orphaned,
+		InvalidChecksums:     invalid,
+		ChecksumLastScan:     lastScan,
+		ChecksumScanDuration: scanDuration,
+
+		S3RetriesExhausted: retriesExhausted,
+
+		RepoObjects: repoObjects,
+		RepoBytes:   repoBytes,
+
+		DownloadVerifications: downloadVerifications,
+
+		Leader:        leader,
+		ConfigReloads: configReloads,
+		S3Events:      s3Events,
+
+		UpstreamInFlight: upstreamInFlight,
+		UpstreamRejected: upstreamRejected,
+
+		PrincipalRequests: principalRequests,
+		PrincipalBytes:    principalBytes,
+
+		ShadowRequests:    shadowRequests,
+		CanaryComparisons: canaryComparisons,
+
+		principals:    map[string]bool{},
+		maxPrincipals: cmp.Or(opts.MaxPrincipals, DefaultMaxPrincipals),
 	}
 }
 
+// Principal returns the principal label for name: the name itself for the
+// first MaxPrincipals names seen, PrincipalOther after that, so a flood of
+// tokens cannot grow the series without bound.
+func (r *Registry) Principal(name string) string {
+	if name == "" {
+		return PrincipalAnonymous
+	}
+	r.principalsMu.Lock()
+	defer r.principalsMu.Unlock()
+	if r.principals[name] {
+		return name
+	}
+	if len(r.principals) >= r.maxPrincipals {
+		return PrincipalOther
+	}
+	r.principals[name] = true
+	return name
+}
+
 func HandlerFor(reg *Registry) http.Handler {
 	return promhttp.HandlerFor(reg.Registry, promhttp.HandlerOpts{
 		EnableOpenMetrics: true,
 	})
 }
+
+// ParseBuckets parses a comma-separated, strictly increasing list of bucket
+// upper bounds in seconds, e.g. "0.1,0.5,1,5,30,120,600".
+func ParseBuckets(spec string) ([]float64, error) {
+	var buckets []float64
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid bucket %q", part)
+		}
+		buckets = append(buckets, v)
+	}
+	if !slices.IsSorted(buckets) || len(slices.Compact(slices.Clone(buckets))) != len(buckets) {
+		return nil, fmt.Errorf("buckets must be strictly increasing")
+	}
+	return buckets, nil
+}
@@ -9,3 +9,66 @@ func TestHandlerFor(t *testing.T) {
 		t.Fatalf("expected handler")
 	}
 }
+
+func TestBuildInfoRegistered(t *testing.T) {
+	families, err := New().Registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() == "heimdall_build_info" {
+			if len(f.GetMetric()) != 1 || f.GetMetric()[0].GetGauge().GetValue() != 1 {
+				t.Fatalf("unexpected build info %v", f)
+			}
+			return
+		}
+	}
+	t.Fatal("heimdall_build_info not registered")
+}
+
+func TestParseBuckets(t *testing.T) {
+	buckets, err := ParseBuckets("0.5, 1,30,600")
+	if err != nil || len(buckets) != 4 || buckets[3] != 600 {
+		t.Fatalf("unexpected buckets %v (%v)", buckets, err)
+	}
+	for _, spec := range []string{"1,0.5", "1,1", "fast"} {
+		if _, err := ParseBuckets(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestNewWithOptionsNativeHistograms(t *testing.T) {
+	m := NewWithOptions(Options{DurationBuckets: []float64{1, 60, 600}, NativeHistograms: true})
+	m.RequestDuration.WithLabelValues("200", "get").Observe(90)
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != "heimdall_http_request_duration_seconds" {
+			continue
+		}
+		h := f.GetMetric()[0].GetHistogram()
+		if len(h.GetBucket()) != 3 || len(h.GetPositiveSpan()) == 0 {
+			t.Fatalf("unexpected histogram %v", h)
+		}
+		return
+	}
+	t.Fatal("duration histogram not found")
+}
+
+func TestPrincipalBounded(t *testing.T) {
+	m := NewWithOptions(Options{MaxPrincipals: 2})
+	for _, c := range []struct{ name, want string }{
+		{"", PrincipalAnonymous},
+		{"alice", "alice"},
+		{"tok-1", "tok-1"},
+		{"tok-2", PrincipalOther},
+		{"alice", "alice"},
+	} {
+		if got := m.Principal(c.name); got != c.want {
+			t.Fatalf("Principal(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
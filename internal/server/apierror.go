@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorResponse is the body of every error response, artifact paths
+// included: an RFC 9457 problem document sent as application/problem+json.
+// Type is about:blank unless one of the problem* types below applies, and
+// Error repeats Detail for clients written before problem documents.
+type ErrorResponse struct {
+	Type      string `json:"type" example:"about:blank"`
+	Title     string `json:"title" example:"Bad Request"`
+	Status    int    `json:"status" example:"400"`
+	Detail    string `json:"detail,omitempty" example:"invalid json"`
+	RequestID string `json:"requestId,omitempty" example:"6f1c0e2ab3d94c57"`
+	Error     string `json:"error" example:"invalid json"`
+}
+
+// Problem types of failures clients may want to tell apart from others with
+// the same status.
+const (
+	problemStorageTimeout     = "urn:heimdall:problem:storage-timeout"
+	problemStorageUnavailable = "urn:heimdall:problem:storage-unavailable"
+	problemChecksumMismatch   = "urn:heimdall:problem:checksum-mismatch"
+	problemUpstreamStatus     = "urn:heimdall:problem:upstream-status"
+	problemUpstreamBusy       = "urn:heimdall:problem:upstream-busy"
+	problemBlocked            = "urn:heimdall:problem:blocked"
+	problemQuarantined        = "urn:heimdall:problem:quarantined"
+	problemImmutable          = "urn:heimdall:problem:immutable"
+)
+
+// writeAPIError is http.Error with a problem document of type about:blank.
+func writeAPIError(w http.ResponseWriter, msg string, status int) {
+	writeProblem(w, "about:blank", status, msg)
+}
+
+// writeProblem writes a problem document. The request ID is taken from the
+// X-Request-ID response header set by loggingMiddleware.
+func writeProblem(w http.ResponseWriter, typ string, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{
+		Type:      typ,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		RequestID: w.Header().Get("X-Request-ID"),
+		Error:     detail,
+	})
+}
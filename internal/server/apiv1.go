@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// apiV1 is the prefix of the versioned JSON API. Artifact paths stay at the
+// root, so new endpoints must go under it to never collide with repository
+// keys.
+const apiV1 = "/api/v1"
+
+// legacyRoutes maps the pre-v1 endpoints to their /api/v1 successor. They
+// keep working, flagged with Deprecation/Link headers, until disabled with
+// Options.DisableLegacyAPI.
+var legacyRoutes = []struct{ old, new string }{
+	{"/catalog", apiV1 + "/catalog"},
+	{"/proxies", apiV1 + "/proxies"},
+	{"/proxies/", apiV1 + "/proxies/"},
+	{"/admin/loglevel", apiV1 + "/admin/loglevel"},
+	{"/admin/metadata/rebuild", apiV1 + "/admin/metadata/rebuild"},
+	{"/admin/relocations", apiV1 + "/admin/relocations"},
+	{"/stats/cache", apiV1 + "/stats/cache"},
+	{"/tokens", apiV1 + "/tokens"},
+	{"/tokens/", apiV1 + "/tokens/"},
+	{"/api/sign", apiV1 + "/sign"},
+	{"/api/history/", apiV1 + "/history/"},
+	{"/api/restore", apiV1 + "/restore"},
+}
+
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc(apiV1+"/catalog", s.authMiddleware(s.adminOnly(s.handleCatalog)))
+	mux.HandleFunc(apiV1+"/proxies", s.authMiddleware(s.adminOnly(s.routeProxies)))
+	mux.HandleFunc(apiV1+"/proxies/", s.authMiddleware(s.adminOnly(s.routeProxyByName)))
+	mux.HandleFunc(apiV1+"/admin/loglevel", s.authMiddleware(s.adminOnly(s.handleLogLevel)))
+	mux.HandleFunc(apiV1+"/admin/metadata/rebuild", s.authMiddleware(s.adminOnly(s.handleMetadataRebuild)))
+	mux.HandleFunc(apiV1+"/admin/relocations", s.authMiddleware(s.adminOnly(s.handleRelocation)))
+	mux.HandleFunc(apiV1+"/admin/integrity", s.authMiddleware(s.adminOnly(s.routeIntegrity)))
+	mux.HandleFunc(apiV1+"/admin/tasks", s.authMiddleware(s.adminOnly(s.handleListTasks)))
+	mux.HandleFunc(apiV1+"/admin/tasks/", s.authMiddleware(s.adminOnly(s.routeTaskAction)))
+	mux.HandleFunc(apiV1+"/stats/cache", s.authMiddleware(s.adminOnly(s.handleCacheStats)))
+	mux.HandleFunc(apiV1+"/usage", s.authMiddleware(s.adminOnly(s.handleUsage)))
+	mux.HandleFunc(apiV1+"/tokens", s.authMiddleware(s.adminOnly(s.routeTokens)))
+	mux.HandleFunc(apiV1+"/tokens/", s.authMiddleware(s.adminOnly(s.routeTokenByID)))
+	mux.HandleFunc(apiV1+"/sign", s.authMiddleware(s.handleSign))
+	mux.HandleFunc(apiV1+"/exists", s.authMiddleware(s.handleExists))
+	mux.HandleFunc(apiV1+"/exists/", s.authMiddleware(s.handleExistsPath))
+	mux.HandleFunc(apiV1+"/bundle", s.authMiddleware(s.handleBundle))
+	mux.HandleFunc(apiV1+"/attestations", s.authMiddleware(s.routeAttestations))
+	mux.HandleFunc(apiV1+"/sbom/", s.authMiddleware(s.routeSBOM))
+	mux.HandleFunc(apiV1+"/upload", s.authMiddleware(s.handleUpload))
+	mux.HandleFunc(apiV1+"/tus", s.authMiddleware(s.routeTus))
+	mux.HandleFunc(apiV1+"/tus/", s.authMiddleware(s.routeTus))
+	mux.HandleFunc(apiV1+"/feed.atom", s.authMiddleware(s.handleFeed))
+	mux.HandleFunc(apiV1+"/diff", s.authMiddleware(s.handleDiff))
+	mux.HandleFunc(apiV1+"/peek/", s.authMiddleware(s.handlePeek))
+	mux.HandleFunc(apiV1+"/pom/", s.authMiddleware(s.handlePOM))
+	mux.HandleFunc(apiV1+"/dependents/", s.authMiddleware(s.handleDependents))
+	mux.HandleFunc(apiV1+"/history/", s.authMiddleware(s.adminOnly(s.handleHistory)))
+	mux.HandleFunc(apiV1+"/restore", s.authMiddleware(s.adminOnly(s.handleRestore)))
+	mux.HandleFunc(apiV1+"/graphql", s.authMiddleware(s.adminOnly(s.handleGraphQL)))
+	mux.HandleFunc(apiV1+"/prefetch", s.authMiddleware(s.adminOnly(s.routePrefetch)))
+	mux.HandleFunc(apiV1+"/prefetch/", s.authMiddleware(s.adminOnly(s.routePrefetch)))
+	mux.HandleFunc(apiV1+"/checksum/", s.authMiddleware(s.adminOnly(s.handleChecksumLookup)))
+	if s.eventsToken != "" {
+		// Authenticated by the events token, not user credentials.
+		mux.HandleFunc(apiV1+"/events/s3", s.handleS3Events)
+	}
+	mux.HandleFunc(apiV1+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, "unknown API endpoint", http.StatusNotFound)
+	})
+
+	if s.disableLegacyAPI {
+		return
+	}
+	for _, route := range legacyRoutes {
+		mux.Handle(route.old, s.legacyAlias(mux, route.old, route.new))
+	}
+}
+
+// legacyAlias serves a pre-v1 path by rewriting it to its successor and
+// dispatching through mux again, so auth and handlers are shared.
+func (s *Server) legacyAlias(mux *http.ServeMux, old, successor string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := successor + strings.TrimPrefix(r.URL.Path, old)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+target+`>; rel="successor-version"`)
+		s.logger.Debug("deprecated API path", zap.String("path", r.URL.Path), zap.String("successor", target))
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = target
+		r2.URL.RawPath = ""
+		mux.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAPIV1Routes(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/proxies", nil)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("v1 proxies: status %d", rr.Code)
+	}
+	if rr.Header().Get("Deprecation") != "" {
+		t.Fatalf("v1 path flagged as deprecated")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/proxies/central/invalidate", strings.NewReader(`{"path":"x"}`))
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Header().Get("Deprecation") != "true" {
+		t.Fatalf("legacy path missing Deprecation header")
+	}
+	if link := rr.Header().Get("Link"); link != `</api/v1/proxies/central/invalidate>; rel="successor-version"` {
+		t.Fatalf("unexpected Link %q", link)
+	}
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"status":404`) {
+		t.Fatalf("legacy alias not dispatched to the proxy handler: %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/nope", nil)
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"status":404`) {
+		t.Fatalf("unknown v1 endpoint: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDisableLegacyAPI(t *testing.T) {
+	srv := NewWithOptions(newMemStore(), zaptest.NewLogger(t), metrics.New(), Options{DisableLegacyAPI: true})
+	req := httptest.NewRequest(http.MethodGet, "/proxies", nil)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound || rr.Header().Get("Deprecation") != "" {
+		t.Fatalf("legacy path still served: %d", rr.Code)
+	}
+}
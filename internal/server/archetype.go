@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+const archetypeCatalogFile = "archetype-catalog.xml"
+
+// pomProject is the subset of a POM needed to recognise archetypes.
+type pomProject struct {
+	GroupID     string `xml:"groupId"`
+	ArtifactID  string `xml:"artifactId"`
+	Version     string `xml:"version"`
+	Packaging   string `xml:"packaging"`
+	Description string `xml:"description"`
+	Parent      struct {
+		GroupID string `xml:"groupId"`
+		Version string `xml:"version"`
+	} `xml:"parent"`
+}
+
+type archetypeEntry struct {
+	GroupID     string `xml:"groupId"`
+	ArtifactID  string `xml:"artifactId"`
+	Version     string `xml:"version"`
+	Description string `xml:"description,omitempty"`
+}
+
+type archetypeCatalog struct {
+	XMLName    xml.Name         `xml:"archetype-catalog"`
+	Xmlns      string           `xml:"xmlns,attr"`
+	Archetypes []archetypeEntry `xml:"archetypes>archetype"`
+}
+
+// archetypeCatalogTask regenerates archetype-catalog.xml at the root of
+// every hosted repository; it is the "archetype-catalog" task.
+func (s *Server) archetypeCatalogTask(ctx context.Context) error {
+	n, err := s.generateArchetypeCatalogs(ctx)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("archetype catalog generated", zap.Int("archetypes", n))
+	return nil
+}
+
+// generateArchetypeCatalogs writes one catalog per hosted repository that
+// holds maven-archetype POMs and returns the number of archetypes found.
+func (s *Server) generateArchetypeCatalogs(ctx context.Context) (int, error) {
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	proxied := make(map[string]bool, len(proxies))
+	for _, pr := range proxies {
+		proxied[pr.Name] = true
+	}
+
+	roots, err := s.store.List(ctx, "", 1000)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, root := range roots {
+		repo := strings.TrimSuffix(root.Name, "/")
+		if root.Type != "dir" || isInternalPath(repo) || proxied[repo] {
+			continue
+		}
+
+		var entries []archetypeEntry
+		err := walkStore(ctx, s.store, repo, func(e storage.Entry) error {
+			if !strings.HasSuffix(e.Path, ".pom") {
+				return nil
+			}
+			entry, ok, err := s.readArchetype(ctx, e.Path)
+			if err != nil || !ok {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		if err := s.writeArchetypeCatalog(ctx, repo, entries); err != nil {
+			return total, err
+		}
+		total += len(entries)
+	}
+	return total, nil
+}
+
+func (s *Server) readArchetype(ctx context.Context, key string) (archetypeEntry, bool, error) {
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return archetypeEntry{}, false, nil
+		}
+		return archetypeEntry{}, false, err
+	}
+	defer resp.Body.Close()
+
+	var pom pomProject
+	if err := xml.NewDecoder(resp.Body).Decode(&pom); err != nil {
+		s.logger.Debug("archetype catalog: skip unparsable pom", zap.String("key", key), zap.Error(err))
+		return archetypeEntry{}, false, nil
+	}
+	if strings.TrimSpace(pom.Packaging) != "maven-archetype" {
+		return archetypeEntry{}, false, nil
+	}
+	entry := archetypeEntry{
+		GroupID:     strings.TrimSpace(pom.GroupID),
+		ArtifactID:  strings.TrimSpace(pom.ArtifactID),
+		Version:     strings.TrimSpace(pom.Version),
+		Description: strings.TrimSpace(pom.Description),
+	}
+	if entry.GroupID == "" {
+		entry.GroupID = strings.TrimSpace(pom.Parent.GroupID)
+	}
+	if entry.Version == "" {
+		entry.Version = strings.TrimSpace(pom.Parent.Version)
+	}
+	if entry.GroupID == "" || entry.ArtifactID == "" || entry.Version == "" {
+		return archetypeEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// writeArchetypeCatalog stores the catalog for repo unless it is unchanged.
+func (s *Server) writeArchetypeCatalog(ctx context.Context, repo string, entries []archetypeEntry) error {
+	slices.SortFunc(entries, func(a, b archetypeEntry) int {
+		if c := strings.Compare(a.GroupID, b.GroupID); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.ArtifactID, b.ArtifactID); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Version, b.Version)
+	})
+	body, err := xml.MarshalIndent(archetypeCatalog{
+		Xmlns:      "http://maven.apache.org/plugins/maven-archetype-plugin/archetype-catalog/1.0.0",
+		Archetypes: entries,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data := append([]byte(xml.Header), body...)
+	data = append(data, '\n')
+
+	key := repo + "/" + archetypeCatalogFile
+	if resp, err := s.store.Get(ctx, key); err == nil {
+		existing, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr == nil && bytes.Equal(existing, data) {
+			return nil
+		}
+	} else if !storage.IsNotFound(err) {
+		return err
+	}
+	return s.store.Put(ctx, key, bytes.NewReader(data), "application/xml", int64(len(data)))
+}
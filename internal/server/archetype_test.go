@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestGenerateArchetypeCatalogs(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	if err := srv.proxy.Add(ctx, Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2"}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+
+	poms := map[string]string{
+		"libs/com/mycorp/quickstart/1.0/quickstart-1.0.pom": `<project><groupId>com.mycorp</groupId><artifactId>quickstart</artifactId><version>1.0</version><packaging>maven-archetype</packaging><description>Service template</description></project>`,
+		"libs/com/mycorp/child/2.0/child-2.0.pom":           `<project><parent><groupId>com.mycorp</groupId><version>2.0</version></parent><artifactId>child</artifactId><packaging>maven-archetype</packaging></project>`,
+		"libs/com/mycorp/app/1.0/app-1.0.pom":               `<project><groupId>com.mycorp</groupId><artifactId>app</artifactId><version>1.0</version></project>`,
+		"central/org/apache/ext/1.0/ext-1.0.pom":            `<project><groupId>org.apache</groupId><artifactId>ext</artifactId><version>1.0</version><packaging>maven-archetype</packaging></project>`,
+	}
+	for key, pom := range poms {
+		if err := store.Put(ctx, key, strings.NewReader(pom), "application/xml", int64(len(pom))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+
+	n, err := srv.generateArchetypeCatalogs(ctx)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 archetypes, got %d", n)
+	}
+	if _, ok := store.data["central/"+archetypeCatalogFile]; ok {
+		t.Fatalf("catalog generated for a proxy")
+	}
+
+	var catalog archetypeCatalog
+	if err := xml.Unmarshal(store.data["libs/"+archetypeCatalogFile].body, &catalog); err != nil {
+		t.Fatalf("decode catalog: %v", err)
+	}
+	want := []archetypeEntry{
+		{GroupID: "com.mycorp", ArtifactID: "child", Version: "2.0"},
+		{GroupID: "com.mycorp", ArtifactID: "quickstart", Version: "1.0", Description: "Service template"},
+	}
+	if len(catalog.Archetypes) != len(want) {
+		t.Fatalf("unexpected archetypes %+v", catalog.Archetypes)
+	}
+	for i := range want {
+		if catalog.Archetypes[i] != want[i] {
+			t.Fatalf("archetype %d: got %+v want %+v", i, catalog.Archetypes[i], want[i])
+		}
+	}
+}
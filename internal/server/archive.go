@@ -0,0 +1,205 @@
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// archiveLimit bounds the archives read for diffs and listings. Zip
+// archives are copied to a temporary file first since zip needs random
+// access; tar archives are streamed.
+const archiveLimit = 2 << 30
+
+var (
+	errArchiveTooLarge = errors.New("archive exceeds 2 GiB")
+	errNotArchive      = errors.New("not a zip or tar archive (jar, war, ear, aar, zip, tar, tar.gz, tgz)")
+)
+
+// ArchiveEntry is a file inside an archive. For zip archives CRC32 comes
+// from the zip directory, so comparing entries does not need to decompress
+// them; tar entries are checksummed while reading.
+type ArchiveEntry struct {
+	Name  string `json:"name" example:"com/acme/lib/Util.class"`
+	Size  int64  `json:"size" example:"2048"`
+	CRC32 string `json:"crc32" example:"1c291ca3"`
+}
+
+// openArchive copies the zip archive stored at key to a temporary file and
+// opens it. cleanup removes the file.
+func (s *Server) openArchive(ctx context.Context, key string) (zr *zip.Reader, cleanup func(), err error) {
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength != nil && *resp.ContentLength > archiveLimit {
+		return nil, nil, errArchiveTooLarge
+	}
+	tmp, err := os.CreateTemp("", "heimdall-archive-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	n, err := io.Copy(tmp, io.LimitReader(resp.Body, archiveLimit+1))
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("read %s: %w", key, err)
+	}
+	if n > archiveLimit {
+		cleanup()
+		return nil, nil, errArchiveTooLarge
+	}
+	if zr, err = zip.NewReader(tmp, n); err != nil {
+		cleanup()
+		return nil, nil, errNotArchive
+	}
+	return zr, cleanup, nil
+}
+
+// listArchive lists the files of the archive stored at key, sorted by name.
+// Keys ending in .tar, .tar.gz or .tgz are read as tar, anything else as zip.
+func (s *Server) listArchive(ctx context.Context, key string) ([]ArchiveEntry, error) {
+	if !isTarArchive(key) {
+		zr, cleanup, err := s.openArchive(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		return archiveEntries(zr), nil
+	}
+
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength != nil && *resp.ContentLength > archiveLimit {
+		return nil, errArchiveTooLarge
+	}
+	body := &countingReader{r: io.LimitReader(resp.Body, archiveLimit+1)}
+	var r io.Reader = body
+	if !strings.HasSuffix(key, ".tar") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, errNotArchive
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	entries := []ArchiveEntry{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if body.n > archiveLimit {
+			return nil, errArchiveTooLarge
+		}
+		// A header cut short, like plain text shorter than one tar block, is
+		// not an archive either.
+		if errors.Is(err, tar.ErrHeader) || errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errNotArchive
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", key, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		h := crc32.NewIEEE()
+		if _, err := io.Copy(h, tr); err != nil {
+			return nil, fmt.Errorf("read %s: %w", key, err)
+		}
+		entries = append(entries, ArchiveEntry{
+			Name:  strings.TrimPrefix(hdr.Name, "./"),
+			Size:  hdr.Size,
+			CRC32: fmt.Sprintf("%08x", h.Sum32()),
+		})
+	}
+	if body.n > archiveLimit {
+		return nil, errArchiveTooLarge
+	}
+	slices.SortFunc(entries, func(a, b ArchiveEntry) int { return strings.Compare(a.Name, b.Name) })
+	return entries, nil
+}
+
+func isTarArchive(key string) bool {
+	return strings.HasSuffix(key, ".tar") || strings.HasSuffix(key, ".tar.gz") || strings.HasSuffix(key, ".tgz")
+}
+
+// archiveEntries lists the files of a zip archive by name; directories are
+// left out.
+func archiveEntries(zr *zip.Reader) []ArchiveEntry {
+	entries := make([]ArchiveEntry, 0, len(zr.File))
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		entries = append(entries, ArchiveEntry{
+			Name:  f.Name,
+			Size:  int64(f.UncompressedSize64),
+			CRC32: fmt.Sprintf("%08x", f.CRC32),
+		})
+	}
+	slices.SortFunc(entries, func(a, b ArchiveEntry) int { return strings.Compare(a.Name, b.Name) })
+	return entries
+}
+
+// archiveKey validates the archive path given as param and checks the
+// caller may read it, writing the error response when it returns "".
+func archiveKey(w http.ResponseWriter, r *http.Request, param, value string) string {
+	key := strings.TrimPrefix(value, "/")
+	if key == "" || strings.HasSuffix(key, "/") || strings.Contains(key, "..") || isInternalPath(key) {
+		writeAPIError(w, param+" must name an archive", http.StatusBadRequest)
+		return ""
+	}
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("read", key) {
+		writeAPIError(w, "token not permitted for "+key, http.StatusForbidden)
+		return ""
+	}
+	return key
+}
+
+// writeArchiveError maps listArchive errors to API errors.
+func (s *Server) writeArchiveError(w http.ResponseWriter, key string, err error) {
+	switch {
+	case storage.IsNotFound(err):
+		writeAPIError(w, key+" not found", http.StatusNotFound)
+	case errors.Is(err, errArchiveTooLarge):
+		writeAPIError(w, key+": "+err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errNotArchive):
+		writeAPIError(w, key+": "+err.Error(), http.StatusUnsupportedMediaType)
+	default:
+		s.logger.Error("open archive", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "read "+key+" failed", http.StatusInternalServerError)
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// attestationSuffix names the sidecar holding an artifact's
+	// attestations, one JSON document per line, as SLSA generators publish
+	// them.
+	attestationSuffix = ".intoto.jsonl"
+	// attestationLimit bounds one POST body.
+	attestationLimit = 4 << 20
+
+	inTotoPayloadType   = "application/vnd.in-toto+json"
+	inTotoStatementType = "https://in-toto.io/Statement/"
+)
+
+// Attestation is one stored in-toto statement, signed (a DSSE envelope)
+// or not.
+type Attestation struct {
+	PredicateType string          `json:"predicateType" example:"https://slsa.dev/provenance/v1"`
+	Signed        bool            `json:"signed"`
+	Document      json.RawMessage `json:"document" swaggertype:"object"`
+}
+
+type AttestationsResponse struct {
+	Path         string        `json:"path"`
+	Attestations []Attestation `json:"attestations"`
+}
+
+type inTotoStatement struct {
+	Type    string `json:"_type"`
+	Subject []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	PredicateType string `json:"predicateType"`
+}
+
+type dsseEnvelope struct {
+	PayloadType string            `json:"payloadType"`
+	Payload     string            `json:"payload"`
+	Signatures  []json.RawMessage `json:"signatures"`
+}
+
+// parseAttestation reads a DSSE envelope or a bare in-toto statement.
+func parseAttestation(doc []byte) (inTotoStatement, bool, error) {
+	var env dsseEnvelope
+	if err := json.Unmarshal(doc, &env); err != nil {
+		return inTotoStatement{}, false, err
+	}
+	signed := env.PayloadType != ""
+	if signed {
+		if env.PayloadType != inTotoPayloadType {
+			return inTotoStatement{}, false, fmt.Errorf("unsupported payloadType %q, want %s", env.PayloadType, inTotoPayloadType)
+		}
+		if len(env.Signatures) == 0 {
+			return inTotoStatement{}, false, errors.New("envelope has no signatures")
+		}
+		payload, err := base64.StdEncoding.DecodeString(env.Payload)
+		if err != nil {
+			return inTotoStatement{}, false, fmt.Errorf("envelope payload: %w", err)
+		}
+		doc = payload
+	}
+	var st inTotoStatement
+	if err := json.Unmarshal(doc, &st); err != nil {
+		return inTotoStatement{}, false, err
+	}
+	switch {
+	case !strings.HasPrefix(st.Type, inTotoStatementType):
+		return inTotoStatement{}, false, fmt.Errorf("_type %q is not an in-toto statement", st.Type)
+	case st.PredicateType == "":
+		return inTotoStatement{}, false, errors.New("predicateType is missing")
+	case len(st.Subject) == 0:
+		return inTotoStatement{}, false, errors.New("statement has no subject")
+	}
+	return st, signed, nil
+}
+
+// matches reports whether a subject of the statement has one of digests
+// (algorithm → lowercase hex).
+func (st inTotoStatement) matches(digests map[string]string) bool {
+	for _, sub := range st.Subject {
+		for algo, want := range digests {
+			if strings.EqualFold(sub.Digest[algo], want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// artifactDigests hashes the stored artifact for subject matching.
+func (s *Server) artifactDigests(ctx context.Context, key string) (map[string]string, error) {
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	sha1h, sha256h := sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(sha1h, sha256h), resp.Body); err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"sha1":   hex.EncodeToString(sha1h.Sum(nil)),
+		"sha256": hex.EncodeToString(sha256h.Sum(nil)),
+	}, nil
+}
+
+// loadAttestations returns the lines of key's attestation sidecar.
+func (s *Server) loadAttestations(ctx context.Context, key string) ([][]byte, error) {
+	resp, err := s.store.Get(ctx, key+attestationSuffix)
+	if storage.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var lines [][]byte
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64<<10), attestationLimit)
+	for sc.Scan() {
+		if line := bytes.TrimSpace(sc.Bytes()); len(line) > 0 {
+			lines = append(lines, bytes.Clone(line))
+		}
+	}
+	return lines, sc.Err()
+}
+
+func (s *Server) routeAttestations(w http.ResponseWriter, r *http.Request) {
+	key := strings.Trim(path.Clean("/"+r.URL.Query().Get("path")), "/")
+	if key == "" || isInternalPath(key) || isChecksumPath(key) || strings.HasSuffix(key, attestationSuffix) {
+		writeAPIError(w, "path must name an artifact", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGetAttestations(w, r, key)
+	case http.MethodPost:
+		s.handleAddAttestations(w, r, key)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary List attestations
+// @Description Returns the in-toto attestations (SLSA provenance and others) stored for an artifact, optionally only those with the given predicateType. Deploy tokens need read scope for the path.
+// @Tags artifacts
+// @Produce json
+// @Param path query string true "Artifact path"
+// @Param predicateType query string false "Only this predicate type"
+// @Success 200 {object} AttestationsResponse
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/attestations [get]
+func (s *Server) handleGetAttestations(w http.ResponseWriter, r *http.Request, key string) {
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("read", key) {
+		writeAPIError(w, "token not permitted for this path", http.StatusForbidden)
+		return
+	}
+	lines, err := s.loadAttestations(r.Context(), key)
+	if err != nil {
+		s.logger.Error("load attestations", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "load attestations failed", http.StatusInternalServerError)
+		return
+	}
+	want := r.URL.Query().Get("predicateType")
+	resp := AttestationsResponse{Path: key, Attestations: []Attestation{}}
+	for _, line := range lines {
+		st, signed, err := parseAttestation(line)
+		if err != nil {
+			// Written by a plain PUT rather than this API.
+			s.logger.Warn("skip invalid attestation", zap.String("path", key), zap.Error(err))
+			continue
+		}
+		if want == "" || st.PredicateType == want {
+			resp.Attestations = append(resp.Attestations, Attestation{PredicateType: st.PredicateType, Signed: signed, Document: line})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Warn("encode attestations", zap.Error(err))
+	}
+}
+
+// @Summary Attach attestations
+// @Description Stores in-toto attestations for an artifact: a DSSE envelope or a bare statement, or several of them one per line (.intoto.jsonl). Each must name the artifact's SHA-256 or SHA-1 digest as a subject. They are appended to <path>.intoto.jsonl, which is served like any file, and the artifact's attestation.predicateTypes and attestation.count properties are updated. Identical documents are stored once. Signatures are not verified. Deploy tokens need write scope for the path.
+// @Tags artifacts
+// @Accept json
+// @Produce json
+// @Param path query string true "Artifact path"
+// @Param attestation body object true "DSSE envelope or in-toto statement"
+// @Success 200 {object} AttestationsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/attestations [post]
+func (s *Server) handleAddAttestations(w http.ResponseWriter, r *http.Request, key string) {
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("write", key) {
+		writeAPIError(w, "token not permitted for this path", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, attestationLimit+1))
+	if err != nil {
+		writeAPIError(w, "read body failed", http.StatusBadRequest)
+		return
+	}
+	if len(body) > attestationLimit {
+		writeAPIError(w, "attestations exceed 4 MiB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	digests, err := s.artifactDigests(r.Context(), key)
+	if storage.IsNotFound(err) {
+		writeAPIError(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("hash artifact", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "read artifact failed", http.StatusInternalServerError)
+		return
+	}
+
+	var added [][]byte
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			writeAPIError(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		st, _, err := parseAttestation(raw)
+		if err != nil {
+			writeAPIError(w, "invalid attestation: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !st.matches(digests) {
+			writeAPIError(w, "no subject of the "+st.PredicateType+" attestation matches the artifact digest", http.StatusUnprocessableEntity)
+			return
+		}
+		var line bytes.Buffer
+		if err := json.Compact(&line, raw); err != nil {
+			writeAPIError(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		added = append(added, line.Bytes())
+	}
+	if len(added) == 0 {
+		writeAPIError(w, "no attestation in body", http.StatusBadRequest)
+		return
+	}
+
+	sidecar := key + attestationSuffix
+	unlock, err := s.lockMetadata(r.Context(), sidecar)
+	if errors.Is(err, errMetadataLocked) {
+		w.Header().Set("Retry-After", "1")
+		writeAPIError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		s.logger.Error("lock attestations", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "lock attestations failed", http.StatusInternalServerError)
+		return
+	}
+	defer unlock()
+
+	lines, err := s.loadAttestations(r.Context(), key)
+	if err != nil {
+		s.logger.Error("load attestations", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "load attestations failed", http.StatusInternalServerError)
+		return
+	}
+	stored := len(lines)
+	for _, line := range added {
+		if !slices.ContainsFunc(lines, func(l []byte) bool { return bytes.Equal(l, line) }) {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > stored {
+		data := append(bytes.Join(lines, []byte("\n")), '\n')
+		err := s.putWithChecksums(r.Context(), sidecar, "application/jsonl", data)
+		if storage.IsImmutable(err) {
+			writeAPIError(w, "attestations of write-once paths cannot be appended to", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			s.logger.Error("store attestations", zap.String("path", key), zap.Error(err))
+			writeAPIError(w, "store attestations failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := AttestationsResponse{Path: key}
+	var types []string
+	for _, line := range lines {
+		st, signed, err := parseAttestation(line)
+		if err != nil {
+			continue
+		}
+		resp.Attestations = append(resp.Attestations, Attestation{PredicateType: st.PredicateType, Signed: signed, Document: line})
+		if !slices.Contains(types, st.PredicateType) {
+			types = append(types, st.PredicateType)
+		}
+	}
+	slices.Sort(types)
+	if err := setProperties(r.Context(), s.store, key, map[string]string{
+		"attestation.predicateTypes": strings.Join(types, ","),
+		"attestation.count":          strconv.Itoa(len(resp.Attestations)),
+	}); err != nil {
+		s.logger.Warn("set attestation properties", zap.String("path", key), zap.Error(err))
+	}
+	s.audit(r, "attestation.added", zap.String("path", key), zap.Int("added", len(lines)-stored))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Warn("encode attestations", zap.Error(err))
+	}
+}
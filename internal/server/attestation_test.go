@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func testStatement(predicateType, sha256 string) string {
+	return `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"lib-1.0.jar","digest":{"sha256":"` + sha256 +
+		`"}}],"predicateType":"` + predicateType + `","predicate":{}}`
+}
+
+func testEnvelope(statement string) string {
+	env, _ := json.Marshal(map[string]any{
+		"payloadType": "application/vnd.in-toto+json",
+		"payload":     base64.StdEncoding.EncodeToString([]byte(statement)),
+		"signatures":  []map[string]string{{"keyid": "k", "sig": "c2ln"}},
+	})
+	return string(env)
+}
+
+func TestAttestations(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	key := "releases/com/acme/lib/1.0/lib-1.0.jar"
+	if err := store.Put(ctx, key, strings.NewReader("jar"), "application/java-archive", 3); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("jar"))
+	digest := hex.EncodeToString(sum[:])
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	do := func(method, query, body string) (*httptest.ResponseRecorder, AttestationsResponse) {
+		req := httptest.NewRequest(method, "/api/v1/attestations?"+query, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		var resp AttestationsResponse
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr, resp
+	}
+
+	provenance := testEnvelope(testStatement("https://slsa.dev/provenance/v1", digest))
+	rr, resp := do(http.MethodPost, "path="+key, provenance)
+	if rr.Code != http.StatusOK || len(resp.Attestations) != 1 || !resp.Attestations[0].Signed {
+		t.Fatalf("POST provenance: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// A bundle of two, one of them already stored.
+	vsa := testStatement("https://slsa.dev/verification_summary/v1", digest)
+	rr, resp = do(http.MethodPost, "path="+key, provenance+"\n"+vsa+"\n")
+	if rr.Code != http.StatusOK || len(resp.Attestations) != 2 {
+		t.Fatalf("POST bundle: %d %s", rr.Code, rr.Body.String())
+	}
+	if lines := strings.Count(string(store.data[key+".intoto.jsonl"].body), "\n"); lines != 2 {
+		t.Fatalf("sidecar holds %d lines, want 2", lines)
+	}
+	if _, ok := store.data[key+".intoto.jsonl.sha1"]; !ok {
+		t.Fatal("sidecar checksum missing")
+	}
+	props, err := loadProperties(ctx, store, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props["attestation.predicateTypes"] != "https://slsa.dev/provenance/v1,https://slsa.dev/verification_summary/v1" || props["attestation.count"] != "2" {
+		t.Fatalf("properties = %v", props)
+	}
+
+	rr, resp = do(http.MethodGet, "path="+key+"&predicateType=https://slsa.dev/provenance/v1", "")
+	if rr.Code != http.StatusOK || len(resp.Attestations) != 1 || resp.Attestations[0].PredicateType != "https://slsa.dev/provenance/v1" {
+		t.Fatalf("GET filtered: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr, _ := do(http.MethodPost, "path="+key, testStatement("https://slsa.dev/provenance/v1", strings.Repeat("0", 64))); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("foreign subject: expected 422, got %d", rr.Code)
+	}
+	if rr, _ := do(http.MethodPost, "path="+key, `{"_type":"x","predicateType":"p"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("not a statement: expected 400, got %d", rr.Code)
+	}
+	if rr, _ := do(http.MethodPost, "path=releases/com/acme/lib/1.0/missing.jar", provenance); rr.Code != http.StatusNotFound {
+		t.Fatalf("missing artifact: expected 404, got %d", rr.Code)
+	}
+	if rr, _ := do(http.MethodGet, "path="+key+".intoto.jsonl", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("sidecar path: expected 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// audit records security-relevant events on the "audit" logger so they can be
+// routed separately from request logs.
+func (s *Server) audit(r *http.Request, event string, fields ...zap.Field) {
+	if s.logger == nil {
+		return
+	}
+	user := principalName(r)
+	fields = append([]zap.Field{
+		zap.String("event", event),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("user", user),
+		zap.String("remoteAddr", r.RemoteAddr),
+	}, fields...)
+	s.logger.Named("audit").Warn("audit", fields...)
+}
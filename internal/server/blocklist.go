@@ -0,0 +1,280 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// BlockList denies downloads of Maven coordinates matching any rule, both for
+// hosted content and proxy fetches.
+type BlockList struct {
+	rules []blockRule
+}
+
+type blockRule struct {
+	raw      string
+	group    string
+	artifact string
+	ranges   []versionRange
+}
+
+// BlockedError is returned when an artifact matches a BlockList rule.
+type BlockedError struct {
+	Rule string
+}
+
+func (e BlockedError) Error() string {
+	return fmt.Sprintf("artifact blocked by policy rule %s", e.Rule)
+}
+
+// ParseBlockList parses rules of the form groupId:artifactId[:versionRange]
+// separated by ";" or newlines. groupId and artifactId accept "*" globs and
+// versionRange uses Maven syntax, e.g. org.apache.logging.log4j:log4j-core:[2.0,2.17.0).
+func ParseBlockList(spec string) (*BlockList, error) {
+	bl := &BlockList{}
+	for _, raw := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		parts := strings.SplitN(raw, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid block rule %q; expected groupId:artifactId[:versionRange]", raw)
+		}
+		rule := blockRule{raw: raw, group: parts[0], artifact: parts[1]}
+		if len(parts) == 3 && parts[2] != "" && parts[2] != "*" {
+			ranges, err := parseVersionRanges(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid block rule %q: %w", raw, err)
+			}
+			rule.ranges = ranges
+		}
+		bl.rules = append(bl.rules, rule)
+	}
+	return bl, nil
+}
+
+// Match reports the rule blocking the artifact at key, if any. A first
+// segment for which isRepo reports true names the repository or proxy
+// holding the artifact and is not part of the groupId; isRepo may be nil
+// for keys that never carry one.
+func (b *BlockList) Match(key string, isRepo func(string) bool) (string, bool) {
+	if b == nil || len(b.rules) == 0 {
+		return "", false
+	}
+	if repo, rest, ok := strings.Cut(strings.TrimPrefix(key, "/"), "/"); ok && isRepo != nil && isRepo(repo) {
+		key = rest
+	}
+	group, artifact, version, ok := parseCoordinates(key)
+	if !ok {
+		return "", false
+	}
+	for _, r := range b.rules {
+		if !groupMatches(r.group, group) {
+			continue
+		}
+		if ok, _ := path.Match(r.artifact, artifact); !ok {
+			continue
+		}
+		if len(r.ranges) == 0 {
+			return r.raw, true
+		}
+		for _, vr := range r.ranges {
+			if vr.contains(version) {
+				return r.raw, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseCoordinates splits a Maven layout path (group/path/artifact/version/file)
+// into its coordinates. Files not named artifact-version* are ignored so that
+// artifact-level metadata never matches.
+func parseCoordinates(key string) (group, artifact, version string, ok bool) {
+	segs := strings.Split(strings.Trim(key, "/"), "/")
+	if len(segs) < 4 {
+		return "", "", "", false
+	}
+	file := segs[len(segs)-1]
+	version = segs[len(segs)-2]
+	artifact = segs[len(segs)-3]
+	if !strings.HasPrefix(file, artifact+"-"+strings.TrimSuffix(version, "-SNAPSHOT")) {
+		return "", "", "", false
+	}
+	return strings.Join(segs[:len(segs)-3], "."), artifact, version, true
+}
+
+// groupMatches compares pattern against the whole of group, segment by
+// segment, so com.evil never matches org.com.evil.
+func groupMatches(pattern, group string) bool {
+	want := strings.Split(pattern, ".")
+	have := strings.Split(group, ".")
+	if len(have) != len(want) {
+		return false
+	}
+	for i := range want {
+		if ok, _ := path.Match(want[i], have[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type versionRange struct {
+	lower, upper         string
+	lowerIncl, upperIncl bool
+}
+
+func (vr versionRange) contains(v string) bool {
+	if vr.lower != "" {
+		c := compareVersions(v, vr.lower)
+		if c < 0 || (c == 0 && !vr.lowerIncl) {
+			return false
+		}
+	}
+	if vr.upper != "" {
+		c := compareVersions(v, vr.upper)
+		if c > 0 || (c == 0 && !vr.upperIncl) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseVersionRanges accepts Maven range syntax: "[1.0,2.0)", "(,1.5]",
+// unions such as "(,1.0],[1.2,)" and a bare version for an exact match.
+func parseVersionRanges(spec string) ([]versionRange, error) {
+	spec = strings.TrimSpace(spec)
+	if !strings.ContainsAny(spec, "[(") {
+		return []versionRange{{lower: spec, upper: spec, lowerIncl: true, upperIncl: true}}, nil
+	}
+	var ranges []versionRange
+	for spec != "" {
+		end := strings.IndexAny(spec, "])")
+		if end < 0 || (spec[0] != '[' && spec[0] != '(') {
+			return nil, fmt.Errorf("malformed version range %q", spec)
+		}
+		body := spec[1:end]
+		vr := versionRange{lowerIncl: spec[0] == '[', upperIncl: spec[end] == ']'}
+		if lo, hi, found := strings.Cut(body, ","); found {
+			vr.lower, vr.upper = strings.TrimSpace(lo), strings.TrimSpace(hi)
+		} else {
+			if !vr.lowerIncl || !vr.upperIncl {
+				return nil, fmt.Errorf("single version range %q must use brackets", spec[:end+1])
+			}
+			vr.lower, vr.upper = strings.TrimSpace(body), strings.TrimSpace(body)
+		}
+		ranges = append(ranges, vr)
+		spec = strings.TrimPrefix(strings.TrimSpace(spec[end+1:]), ",")
+	}
+	return ranges, nil
+}
+
+var qualifierOrder = map[string]int{
+	"alpha":     1,
+	"a":         1,
+	"beta":      2,
+	"b":         2,
+	"milestone": 3,
+	"m":         3,
+	"rc":        4,
+	"cr":        4,
+	"snapshot":  5,
+	"":          6,
+	"ga":        6,
+	"final":     6,
+	"release":   6,
+	"sp":        7,
+}
+
+// compareVersions orders versions roughly like Maven's ComparableVersion:
+// numeric parts compare numerically and pre-release qualifiers sort before the
+// release they qualify.
+func compareVersions(a, b string) int {
+	ta, tb := versionTokens(a), versionTokens(b)
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		var x, y string
+		if i < len(ta) {
+			x = ta[i]
+		}
+		if i < len(tb) {
+			y = tb[i]
+		}
+		if c := compareVersionToken(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func versionTokens(v string) []string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c == '.' || c == '-' || c == '_' {
+			flush()
+			continue
+		}
+		if cur.Len() > 0 {
+			prevDigit := cur.String()[cur.Len()-1] >= '0' && cur.String()[cur.Len()-1] <= '9'
+			if prevDigit != (c >= '0' && c <= '9') {
+				flush()
+			}
+		}
+		cur.WriteByte(c)
+	}
+	flush()
+	return tokens
+}
+
+func compareVersionToken(x, y string) int {
+	xn, xErr := strconv.Atoi(x)
+	yn, yErr := strconv.Atoi(y)
+	xNum, yNum := xErr == nil, yErr == nil
+	switch {
+	case xNum && yNum:
+		return compareInts(xn, yn)
+	case xNum && y == "":
+		return compareInts(xn, 0)
+	case x == "" && yNum:
+		return compareInts(0, yn)
+	case xNum:
+		// 1.0.1 > 1.0-rc1
+		return 1
+	case yNum:
+		return -1
+	}
+	xo, xKnown := qualifierOrder[x]
+	yo, yKnown := qualifierOrder[y]
+	if !xKnown {
+		xo = len(qualifierOrder)
+	}
+	if !yKnown {
+		yo = len(qualifierOrder)
+	}
+	if xo != yo {
+		return compareInts(xo, yo)
+	}
+	return strings.Compare(x, y)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package server
+
+import "testing"
+
+func TestBlockListMatch(t *testing.T) {
+	bl, err := ParseBlockList("org.apache.logging.log4j:log4j-core:[2.0,2.17.0); com.evil:*")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	cases := []struct {
+		key     string
+		blocked bool
+	}{
+		{"central/org/apache/logging/log4j/log4j-core/2.14.1/log4j-core-2.14.1.jar", true},
+		{"org/apache/logging/log4j/log4j-core/2.14.1/log4j-core-2.14.1.jar.sha1", true},
+		{"org/apache/logging/log4j/log4j-core/2.17.0-rc1/log4j-core-2.17.0-rc1.jar", true},
+		{"org/apache/logging/log4j/log4j-core/2.17.0/log4j-core-2.17.0.jar", false},
+		{"org/apache/logging/log4j/log4j-core/2.17.1/log4j-core-2.17.1.jar", false},
+		{"org/apache/logging/log4j/log4j-core/maven-metadata.xml", false},
+		{"org/apache/logging/log4j/log4j-api/2.14.1/log4j-api-2.14.1.jar", false},
+		{"releases/com/evil/tool/1.0/tool-1.0.jar", true},
+		{"com/evilcorp/tool/1.0/tool-1.0.jar", false},
+		{"releases/org/com/evil/tool/1.0/tool-1.0.jar", false},
+		{"central/net/acme/org/apache/logging/log4j/log4j-core/2.14.1/log4j-core-2.14.1.jar", false},
+		{"org/com/evil/tool/1.0/tool-1.0.jar", false},
+		{"unknown/com/evil/tool/1.0/tool-1.0.jar", false},
+	}
+	isRepo := func(name string) bool { return name == "central" || name == "releases" }
+	for _, c := range cases {
+		if _, blocked := bl.Match(c.key, isRepo); blocked != c.blocked {
+			t.Errorf("%s: expected blocked=%v", c.key, c.blocked)
+		}
+	}
+}
+
+func TestBlockListMatchesWholeGroup(t *testing.T) {
+	bl, err := ParseBlockList("apache.logging.log4j:log4j-core")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, key := range []string{
+		"org/apache/logging/log4j/log4j-core/2.14.1/log4j-core-2.14.1.jar",
+		"central/org/apache/logging/log4j/log4j-core/2.14.1/log4j-core-2.14.1.jar",
+	} {
+		if rule, blocked := bl.Match(key, func(name string) bool { return name == "central" }); blocked {
+			t.Errorf("%s: unexpectedly blocked by %s", key, rule)
+		}
+	}
+}
+
+func TestParseBlockListErrors(t *testing.T) {
+	for _, spec := range []string{"justgroup", "g:a:[1.0", "g:a:(1.0)"} {
+		if _, err := ParseBlockList(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"2.17.0", "2.17", 0},
+		{"2.9", "2.17", -1},
+		{"1.0-alpha-1", "1.0-beta", -1},
+		{"1.0-SNAPSHOT", "1.0", -1},
+		{"1.0.1", "1.0-rc1", 1},
+		{"1.0-sp1", "1.0", 1},
+	}
+	for _, c := range cases {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
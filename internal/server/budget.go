@@ -0,0 +1,128 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TokenBudgets are the default daily allowances of each deploy token; a
+// token's own DailyRequests and DailyBytes take precedence. Zero means
+// unlimited.
+type TokenBudgets struct {
+	DailyRequests int64
+	// DailyBytes counts uploaded request bodies; downloads only count as
+	// requests.
+	DailyBytes int64
+}
+
+// budgetTracker counts what each token used during the current UTC day.
+// Counts are kept in memory, so each replica enforces its own share and a
+// restart starts the day over.
+type budgetTracker struct {
+	defaults TokenBudgets
+
+	mu    sync.Mutex
+	day   time.Time
+	usage map[string]*tokenUsage
+}
+
+type tokenUsage struct {
+	requests int64
+	bytes    int64
+}
+
+func newBudgetTracker(defaults TokenBudgets) *budgetTracker {
+	return &budgetTracker{defaults: defaults, usage: make(map[string]*tokenUsage)}
+}
+
+// limits returns the budgets applying to tok.
+func (b *budgetTracker) limits(tok *DeployToken) (requests, bytes int64) {
+	requests, bytes = b.defaults.DailyRequests, b.defaults.DailyBytes
+	if tok.DailyRequests != nil {
+		requests = *tok.DailyRequests
+	}
+	if tok.DailyBytes != nil {
+		bytes = *tok.DailyBytes
+	}
+	return requests, bytes
+}
+
+// admit counts a request of tok at now, unless one of its budgets is used
+// up. The check happens before the request runs, so the request crossing
+// the byte budget still completes.
+func (b *budgetTracker) admit(tok *DeployToken, now time.Time) bool {
+	maxRequests, maxBytes := b.limits(tok)
+	if maxRequests <= 0 && maxBytes <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	u := b.today(tok.ID, now)
+	if (maxRequests > 0 && u.requests >= maxRequests) || (maxBytes > 0 && u.bytes >= maxBytes) {
+		return false
+	}
+	u.requests++
+	return true
+}
+
+// consume adds n bytes to what tok used at now.
+func (b *budgetTracker) consume(tok *DeployToken, n int64, now time.Time) {
+	if n <= 0 {
+		return
+	}
+	if maxRequests, maxBytes := b.limits(tok); maxRequests <= 0 && maxBytes <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.today(tok.ID, now).bytes += n
+}
+
+// today returns the usage of id for the UTC day of now, dropping all counts
+// when a new day started. Callers hold mu.
+func (b *budgetTracker) today(id string, now time.Time) *tokenUsage {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(b.day) {
+		b.day = day
+		clear(b.usage)
+	}
+	u, ok := b.usage[id]
+	if !ok {
+		u = &tokenUsage{}
+		b.usage[id] = u
+	}
+	return u
+}
+
+// budgetReset is when the budgets counted at now start over.
+func budgetReset(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// enforceBudget runs next for deploy tokens within their daily budgets and
+// counts the bytes it reads from the request body; other principals are not limited. Tokens over
+// budget get 429 until midnight UTC.
+func (s *Server) enforceBudget(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	tok := principalFrom(r.Context()).token
+	if tok == nil {
+		next(w, r)
+		return
+	}
+	now := time.Now()
+	if !s.budgets.admit(tok, now) {
+		s.logger.Info("token over daily budget", zap.String("token", tok.ID), zap.String("path", r.URL.Path))
+		w.Header().Set("Retry-After", strconv.Itoa(int(budgetReset(now).Sub(now).Seconds())+1))
+		writeAPIError(w, "daily budget of token "+tok.ID+" exceeded", http.StatusTooManyRequests)
+		return
+	}
+	body := &countingBody{ReadCloser: r.Body}
+	if r.Body != nil {
+		r.Body = body
+	}
+	next(w, r)
+	s.budgets.consume(tok, body.n, time.Now())
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestTokenBudgets(t *testing.T) {
+	srv := NewWithOptions(newMemStore(), zaptest.NewLogger(t), metrics.New(), Options{
+		AuthUser:     "admin",
+		AuthPassword: "secret",
+		TokenBudgets: TokenBudgets{DailyRequests: 2},
+	})
+	h := srv.Handler()
+
+	create := func(body string) CreateTokenResponse {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("create token: %d %s", rr.Code, rr.Body.String())
+		}
+		var tok CreateTokenResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &tok)
+		return tok
+	}
+	put := func(tok CreateTokenResponse, data string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/releases/com/acme/app/1.0/app-1.0.jar", strings.NewReader(data))
+		req.SetBasicAuth(tok.ID, tok.Secret)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	capped := create(`{"prefix":"releases","verbs":["write"]}`)
+	for i := range 2 {
+		if rr := put(capped, "data"); rr.Code != http.StatusCreated {
+			t.Fatalf("request %d within budget: %d", i+1, rr.Code)
+		}
+	}
+	rr := put(capped, "data")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After over budget, got %d", rr.Code)
+	}
+
+	// Per-token budgets override the default; the byte budget lets the
+	// request crossing it complete.
+	bytes := create(`{"prefix":"releases","verbs":["write"],"dailyRequests":0,"dailyBytes":10}`)
+	for i, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests} {
+		if rr := put(bytes, "abc"); rr.Code != want {
+			t.Fatalf("request %d: got %d, want %d", i+1, rr.Code, want)
+		}
+	}
+
+	// Downloads do not count against the byte budget.
+	reader := create(`{"prefix":"releases","verbs":["read"],"dailyRequests":0,"dailyBytes":1}`)
+	for i := range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/releases/com/acme/app/1.0/app-1.0.jar", nil)
+		req.SetBasicAuth(reader.ID, reader.Secret)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("download %d: got %d", i+1, rr.Code)
+		}
+	}
+
+	// Admin credentials are not limited.
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tokens", nil)
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("admin request: %d", rr.Code)
+		}
+	}
+}
+
+func TestBudgetResetsDaily(t *testing.T) {
+	b := newBudgetTracker(TokenBudgets{DailyRequests: 1})
+	tok := &DeployToken{ID: "tok-0123456789abcdef"}
+	day := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	if !b.admit(tok, day) || b.admit(tok, day) {
+		t.Fatal("expected one request per day")
+	}
+	if !b.admit(tok, day.Add(2*time.Minute)) {
+		t.Fatal("expected the budget to start over at midnight UTC")
+	}
+	if got := budgetReset(day); !got.Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("reset: %v", got)
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// pomLimit bounds how much of a POM is read when parsing it.
+const pomLimit = 1 << 20
+
+// bundleFiles lists the files of a release version directory that go into
+// a Central bundle: the artifacts themselves, without checksums (which are
+// written from the content), signatures (which are looked up per file) or
+// attestation and SBOM sidecars.
+// missing names what Central requires but the directory lacks.
+func (s *Server) bundleFiles(ctx context.Context, dir string) (files []string, missing []string, err error) {
+	segs := strings.Split(dir, "/")
+	artifact, version := segs[len(segs)-2], segs[len(segs)-1]
+	base := artifact + "-" + version
+
+	entries, err := s.store.List(ctx, dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	present := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if e.Type != "file" {
+			continue
+		}
+		present[e.Name] = true
+		if !strings.HasPrefix(e.Name, base) || isChecksumPath(e.Name) || strings.HasSuffix(e.Name, ".asc") ||
+			strings.HasSuffix(e.Name, attestationSuffix) || isSBOMPath(e.Name) {
+			continue
+		}
+		files = append(files, e.Name)
+	}
+	slices.Sort(files)
+
+	pom := base + ".pom"
+	if !present[pom] {
+		return files, []string{pom}, nil
+	}
+	required := []string{pom}
+	if packaging := s.bundlePackaging(ctx, dir+"/"+pom); packaging != "pom" {
+		ext := packaging
+		switch packaging {
+		case "", "jar", "bundle", "maven-plugin":
+			ext = "jar"
+		}
+		required = append(required, base+"."+ext, base+"-sources.jar", base+"-javadoc.jar")
+	}
+	for _, f := range required {
+		if !present[f] {
+			missing = append(missing, f)
+		}
+	}
+	for _, f := range files {
+		if !present[f+".asc"] {
+			missing = append(missing, f+".asc")
+		}
+	}
+	return files, missing, nil
+}
+
+// bundlePackaging returns the packaging declared by a POM, "" when it
+// cannot be read.
+func (s *Server) bundlePackaging(ctx context.Context, key string) string {
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	var p pomProject
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, pomLimit)).Decode(&p); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(p.Packaging)
+}
+
+// @Summary Export a Central bundle
+// @Description Packages one release version (path is its directory, e.g. releases/com/acme/lib/1.0) as a zip in the layout the Sonatype Central Portal accepts for upload: every file with its .asc signature and .md5/.sha1 checksums, computed from the stored content. Answers 422 and lists what is missing when the POM, the main artifact, the sources or javadoc jar, or a signature is absent; POM-packaged versions need only the POM. SNAPSHOT versions are rejected. Deploy tokens need read scope for the path.
+// @Tags artifacts
+// @Produce application/zip
+// @Param path query string true "Version directory"
+// @Success 200 {file} binary
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/bundle [get]
+func (s *Server) handleBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dir := strings.Trim(path.Clean("/"+r.URL.Query().Get("path")), "/")
+	segs := strings.Split(dir, "/")
+	switch {
+	case len(segs) < 4 || isInternalPath(dir):
+		writeAPIError(w, "path must be a version directory, e.g. releases/com/acme/lib/1.0", http.StatusBadRequest)
+		return
+	case strings.HasSuffix(dir, "-SNAPSHOT"):
+		writeAPIError(w, "SNAPSHOT versions cannot be published to Central", http.StatusBadRequest)
+		return
+	}
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("read", dir+"/") {
+		writeAPIError(w, "token not permitted for this path", http.StatusForbidden)
+		return
+	}
+
+	files, missing, err := s.bundleFiles(r.Context(), dir)
+	if err != nil {
+		s.logger.Warn("list bundle files", zap.String("path", dir), zap.Error(err))
+		writeAPIError(w, "list version directory failed", http.StatusInternalServerError)
+		return
+	}
+	if len(files) == 0 {
+		writeAPIError(w, "no artifacts in "+dir, http.StatusNotFound)
+		return
+	}
+	if len(missing) > 0 {
+		writeAPIError(w, "not publishable, missing: "+strings.Join(missing, ", "), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// The bundle mirrors the repository layout from the groupId down.
+	group := strings.Join(segs[1:], "/")
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s-bundle.zip"`, segs[len(segs)-2], segs[len(segs)-1]))
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		if err := s.addBundleFile(r.Context(), zw, dir+"/"+f, group+"/"+f); err != nil {
+			// The status is sent already; a truncated zip fails to open.
+			s.logger.Warn("write bundle", zap.String("path", dir), zap.String("file", f), zap.Error(err))
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		s.logger.Warn("write bundle", zap.String("path", dir), zap.Error(err))
+	}
+}
+
+// addBundleFile copies key and its signature into the zip as name and
+// name.asc, followed by name.md5 and name.sha1.
+func (s *Server) addBundleFile(ctx context.Context, zw *zip.Writer, key, name string) error {
+	sha1h, md5h := sha1.New(), md5.New()
+	if err := s.copyToZip(ctx, zw, key, name, sha1h, md5h); err != nil {
+		return err
+	}
+	if err := s.copyToZip(ctx, zw, key+".asc", name+".asc"); err != nil {
+		return err
+	}
+	for _, sum := range []struct {
+		ext string
+		h   hash.Hash
+	}{{".md5", md5h}, {".sha1", sha1h}} {
+		fw, err := zw.Create(name + sum.ext)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, hex.EncodeToString(sum.h.Sum(nil))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) copyToZip(ctx context.Context, zw *zip.Writer, key, name string, hashes ...hash.Hash) error {
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	writers := []io.Writer{fw}
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	_, err = io.Copy(io.MultiWriter(writers...), resp.Body)
+	return err
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCentralBundle(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	dir := "releases/com/acme/lib/1.0/"
+	for name, body := range map[string]string{
+		"lib-1.0.pom":              "<project><packaging>jar</packaging></project>",
+		"lib-1.0.jar":              "jar",
+		"lib-1.0-sources.jar":      "sources",
+		"lib-1.0-javadoc.jar":      "javadoc",
+		"lib-1.0.pom.asc":          "sig",
+		"lib-1.0.jar.asc":          "sig",
+		"lib-1.0-sources.jar.asc":  "sig",
+		"lib-1.0-javadoc.jar.asc":  "sig",
+		"lib-1.0.jar.sha1":         "stale",
+		"lib-1.0.jar.asc.sha1":     "not bundled",
+		"lib-1.0.jar.intoto.jsonl": "not bundled",
+		"../maven-metadata.xml":    "not bundled",
+	} {
+		if err := store.Put(ctx, dir+name, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	get := func(p string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/bundle?path="+p, nil)
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/releases/com/acme/lib/1.0/")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("expected a zip, got %d: %s", rr.Code, rr.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "com/acme/lib/1.0/lib-1.0.jar.sha1" {
+			rc, _ := f.Open()
+			b, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(b) != sha1Hex("jar") {
+				t.Fatalf("expected the sha1 computed from the content, got %q", b)
+			}
+		}
+	}
+	if len(names) != 16 || !slices.Contains(names, "com/acme/lib/1.0/lib-1.0-javadoc.jar.asc") || !slices.Contains(names, "com/acme/lib/1.0/lib-1.0.pom.md5") {
+		t.Fatalf("unexpected bundle entries %v", names)
+	}
+	if slices.ContainsFunc(names, func(n string) bool { return strings.Contains(n, "asc.sha1") || strings.Contains(n, "maven-metadata") }) {
+		t.Fatalf("unexpected bundle entries %v", names)
+	}
+
+	delete(store.data, dir+"lib-1.0-javadoc.jar.asc")
+	delete(store.data, dir+"lib-1.0-sources.jar")
+	if rr := get(dir); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "lib-1.0-sources.jar, lib-1.0-javadoc.jar.asc") {
+		t.Fatalf("expected the missing files to be listed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for p, want := range map[string]int{
+		"releases/com/acme/lib/1.1-SNAPSHOT": http.StatusBadRequest,
+		"releases/com":                       http.StatusBadRequest,
+		"releases/com/acme/lib/2.0":          http.StatusNotFound,
+	} {
+		if rr := get(p); rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", p, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ProxyCacheStats counts how one proxy's requests were served since startup.
+type ProxyCacheStats struct {
+	Name              string  `json:"name"`
+	Hits              int64   `json:"hits"`
+	Misses            int64   `json:"misses"`
+	Passthrough       int64   `json:"passthrough"`
+	HitRatio          float64 `json:"hitRatio"`
+	BytesFromCache    int64   `json:"bytesFromCache"`
+	BytesFromUpstream int64   `json:"bytesFromUpstream"`
+	// BytesSaved estimates upstream bandwidth avoided: every cache hit would
+	// otherwise have been downloaded again.
+	BytesSaved int64 `json:"bytesSaved"`
+}
+
+type CacheStatsReport struct {
+	Since   time.Time         `json:"since"`
+	Proxies []ProxyCacheStats `json:"proxies"`
+	Total   ProxyCacheStats   `json:"total"`
+}
+
+// CacheStats is an in-memory, per-proxy tally of cache hits and misses.
+type CacheStats struct {
+	mu      sync.Mutex
+	since   time.Time
+	proxies map[string]*ProxyCacheStats
+}
+
+func NewCacheStats() *CacheStats {
+	return &CacheStats{since: time.Now().UTC(), proxies: make(map[string]*ProxyCacheStats)}
+}
+
+type cacheOutcome int
+
+const (
+	cacheHit cacheOutcome = iota
+	cacheMiss
+	cachePassthrough
+)
+
+// record attributes a served object to the proxy owning key (its first path
+// segment). Non-proxy keys are filtered out when the report is built.
+func (c *CacheStats) record(key string, outcome cacheOutcome, bytes int64) {
+	if c == nil {
+		return
+	}
+	name, _, ok := strings.Cut(strings.TrimPrefix(key, "/"), "/")
+	if !ok || name == "" {
+		return
+	}
+	if bytes < 0 {
+		bytes = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.proxies[name]
+	if !ok {
+		st = &ProxyCacheStats{Name: name}
+		c.proxies[name] = st
+	}
+	switch outcome {
+	case cacheHit:
+		st.Hits++
+		st.BytesFromCache += bytes
+	case cacheMiss:
+		st.Misses++
+		st.BytesFromUpstream += bytes
+	case cachePassthrough:
+		st.Passthrough++
+		st.BytesFromUpstream += bytes
+	}
+}
+
+func objectSize(resp *s3.GetObjectOutput) int64 {
+	if resp == nil || resp.ContentLength == nil {
+		return 0
+	}
+	return *resp.ContentLength
+}
+
+// Report returns stats for the given proxy names, in order.
+func (c *CacheStats) Report(names []string) CacheStatsReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := CacheStatsReport{Since: c.since, Proxies: []ProxyCacheStats{}, Total: ProxyCacheStats{Name: "total"}}
+	slices.Sort(names)
+	for _, name := range names {
+		st := ProxyCacheStats{Name: name}
+		if cur, ok := c.proxies[name]; ok {
+			st = *cur
+		}
+		st.finish()
+		report.Proxies = append(report.Proxies, st)
+
+		report.Total.Hits += st.Hits
+		report.Total.Misses += st.Misses
+		report.Total.Passthrough += st.Passthrough
+		report.Total.BytesFromCache += st.BytesFromCache
+		report.Total.BytesFromUpstream += st.BytesFromUpstream
+	}
+	report.Total.finish()
+	return report
+}
+
+func (st *ProxyCacheStats) finish() {
+	if total := st.Hits + st.Misses + st.Passthrough; total > 0 {
+		st.HitRatio = float64(st.Hits) / float64(total)
+	}
+	st.BytesSaved = st.BytesFromCache
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// canaryConcurrency bounds the canary fetches running at once across
+	// all proxies; comparisons beyond it are skipped.
+	canaryConcurrency = 8
+	// canaryTimeout bounds a canary fetch, including reading its body.
+	canaryTimeout = 5 * time.Minute
+)
+
+// canaryCheck compares what a proxy's upstream answered for an artifact
+// with what its canary upstream answers, once the primary response was
+// read. It only ever logs and counts; clients get the primary's answer.
+type canaryCheck struct {
+	p            *ProxyManager
+	proxy        Proxy
+	artifactPath string
+	sum          hash.Hash
+}
+
+// canary returns the check for a fetch of artifactPath from proxy, hashing
+// resp's body as it is read, or nil when the proxy has no canary.
+// maven-metadata.xml files and their checksums are left out since mirrors
+// legitimately rebuild them at different times.
+func (p *ProxyManager) canary(proxy Proxy, artifactPath string, resp *http.Response) *canaryCheck {
+	if proxy.CanaryURL == "" || isMetadataPath(artifactPath) {
+		return nil
+	}
+	c := &canaryCheck{p: p, proxy: proxy, artifactPath: artifactPath, sum: sha256.New()}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(resp.Body, c.sum), resp.Body}
+	return c
+}
+
+// compare fetches the artifact from the canary in the background and
+// checks that it answers with the primary's status and, for 200, the same
+// content.
+func (c *canaryCheck) compare(status int) {
+	if c == nil {
+		return
+	}
+	select {
+	case c.p.canarySlots <- struct{}{}:
+	default:
+		c.p.countCanary(c.proxy.Name, "skipped")
+		return
+	}
+	var primary string
+	if status == http.StatusOK {
+		primary = hex.EncodeToString(c.sum.Sum(nil))
+	}
+	go func() {
+		defer func() { <-c.p.canarySlots }()
+		ctx, cancel := context.WithTimeout(context.Background(), canaryTimeout)
+		defer cancel()
+		c.p.countCanary(c.proxy.Name, c.fetch(ctx, status, primary))
+	}()
+}
+
+func (c *canaryCheck) fetch(ctx context.Context, status int, primary string) string {
+	log := c.p.logger.With(zap.String("proxy", c.proxy.Name), zap.String("path", c.artifactPath))
+	url := strings.TrimSuffix(c.proxy.CanaryURL, "/") + "/" + c.artifactPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		log.Warn("canary fetch failed", zap.Error(err))
+		return "error"
+	}
+	resp, err := c.p.httpClient.Do(req)
+	if err != nil {
+		log.Warn("canary fetch failed", zap.Error(err))
+		return "error"
+	}
+	defer resp.Body.Close()
+	var got string
+	if resp.StatusCode == http.StatusOK {
+		sum := sha256.New()
+		if _, err := io.Copy(sum, resp.Body); err != nil {
+			log.Warn("canary fetch failed", zap.Error(err))
+			return "error"
+		}
+		got = hex.EncodeToString(sum.Sum(nil))
+	}
+	if resp.StatusCode != status || got != primary {
+		log.Warn("canary upstream diverges",
+			zap.Int("status", status),
+			zap.Int("canaryStatus", resp.StatusCode),
+			zap.String("sha256", primary),
+			zap.String("canarySha256", got))
+		return "mismatch"
+	}
+	return "match"
+}
+
+func (p *ProxyManager) countCanary(proxy, result string) {
+	if p.metrics != nil {
+		p.metrics.CanaryComparisons.WithLabelValues(proxy, result).Inc()
+	}
+}
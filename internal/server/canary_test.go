@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestProxyCanaryComparesUpstreams(t *testing.T) {
+	files := map[string]string{
+		"/com/acme/app/1.0/app-1.0.jar": "JARCONTENT",
+		"/com/acme/app/1.0/app-1.0.pom": "<project/>",
+	}
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer primary.Close()
+	canaryRequests := make(chan string, 10)
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		canaryRequests <- r.URL.Path
+		switch r.URL.Path {
+		case "/mirror/com/acme/app/1.0/app-1.0.jar":
+			_, _ = w.Write([]byte("JARCONTENT"))
+		case "/mirror/com/acme/app/1.0/app-1.0.pom":
+			_, _ = w.Write([]byte("<project>changed</project>"))
+		case "/mirror/com/acme/app/2.0/app-2.0.jar":
+			_, _ = w.Write([]byte("only on the canary"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer canary.Close()
+
+	ctx := context.Background()
+	m := metrics.New()
+	pm := NewProxyManager(newMemStore(), zaptest.NewLogger(t))
+	pm.metrics = m
+	if err := pm.Add(ctx, Proxy{Name: "central", URL: primary.URL, CanaryURL: canary.URL + "/mirror/"}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	for _, key := range []string{
+		"central/com/acme/app/1.0/app-1.0.jar",
+		"central/com/acme/app/1.0/app-1.0.pom",
+		"central/com/acme/app/2.0/app-2.0.jar",
+		"central/com/acme/app/maven-metadata.xml",
+	} {
+		if _, err := pm.FetchAndCache(ctx, key); err != nil {
+			t.Fatalf("fetch %s: %v", key, err)
+		}
+	}
+
+	count := func(result string) float64 {
+		return testutil.ToFloat64(m.CanaryComparisons.WithLabelValues("central", result))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for count("match")+count("mismatch") < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count("match") != 1 || count("mismatch") != 2 {
+		t.Errorf("expected 1 match and 2 mismatches, got %v and %v", count("match"), count("mismatch"))
+	}
+	close(canaryRequests)
+	for p := range canaryRequests {
+		if isMetadataPath(p) {
+			t.Errorf("metadata compared: %s", p)
+		}
+	}
+}
+
+func TestProxyAddRejectsInvalidCanaryURL(t *testing.T) {
+	pm := NewProxyManager(newMemStore(), zaptest.NewLogger(t))
+	if err := pm.Add(context.Background(), Proxy{Name: "central", URL: "https://example.com", CanaryURL: "mirror.internal"}); err == nil {
+		t.Fatalf("expected invalid canaryUrl error")
+	}
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// casStorage is implemented by stores with a content-addressable mode, see
+// storage.Options.CAS.
+type casStorage interface {
+	CollectBlobs(ctx context.Context) (storage.CASStats, error)
+}
+
+// collectBlobsTask deletes blobs no artifact points to anymore; it is the
+// "cas-gc" task.
+func (s *Server) collectBlobsTask(ctx context.Context) error {
+	cs, ok := s.store.(casStorage)
+	if !ok {
+		return fmt.Errorf("storage backend does not support content-addressable mode")
+	}
+	stats, err := cs.CollectBlobs(ctx)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("unreferenced blobs collected",
+		zap.Int("pointers", stats.Pointers),
+		zap.Int("blobs", stats.Blobs),
+		zap.Int("deleted", stats.Deleted),
+		zap.Int64("freedBytes", stats.Freed))
+	return nil
+}
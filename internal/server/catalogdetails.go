@@ -0,0 +1,65 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/otoru/heimdall/internal/storage"
+)
+
+// fillFromCached completes an entry of an upstream listing with the
+// details of its cached copy, which the index page may not show.
+func fillFromCached(e *storage.Entry, cached storage.Entry) {
+	e.Size = cmp.Or(e.Size, cached.Size)
+	if e.LastModified == nil {
+		e.LastModified = cached.LastModified
+	}
+	e.ETag = cmp.Or(e.ETag, cached.ETag)
+	e.StorageClass = cmp.Or(e.StorageClass, cached.StorageClass)
+}
+
+// setCatalogSources marks entries under a proxy with its name and all
+// others as local. Entries that already carry a source keep it.
+func setCatalogSources(entries []storage.Entry, proxies []Proxy) {
+	names := make(map[string]bool, len(proxies))
+	for _, pr := range proxies {
+		names[pr.Name] = true
+	}
+	for i := range entries {
+		if entries[i].Source != "" {
+			continue
+		}
+		first, _, _ := strings.Cut(strings.TrimPrefix(entries[i].Path, "/"), "/")
+		if names[first] {
+			entries[i].Source = first
+		} else {
+			entries[i].Source = "local"
+		}
+	}
+}
+
+// fillCatalogChecksums sets the SHA-1 of files whose .sha1 sidecar is part
+// of the same listing, reading the sidecars concurrently.
+func (s *Server) fillCatalogChecksums(ctx context.Context, entries []storage.Entry) {
+	listed := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		listed[e.Path] = true
+	}
+	sem := make(chan struct{}, existsWorkers)
+	var wg sync.WaitGroup
+	for i := range entries {
+		e := &entries[i]
+		if e.Type != "file" || e.SHA1 != "" || isChecksumPath(e.Path) || !listed[e.Path+".sha1"] {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			e.SHA1 = s.readChecksumSidecar(ctx, e.Path+".sha1")
+		}()
+	}
+	wg.Wait()
+}
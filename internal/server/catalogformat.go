@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Catalog output formats.
+const (
+	catalogJSON = "json"
+	catalogXML  = "xml"
+	catalogCSV  = "csv"
+)
+
+// catalogFormat picks the catalog encoding from ?format= or, without it,
+// the first XML or CSV media type in Accept; JSON otherwise. ok is false
+// for an unknown ?format=.
+func catalogFormat(r *http.Request) (format string, ok bool) {
+	switch f := strings.ToLower(r.URL.Query().Get("format")); f {
+	case catalogJSON, catalogXML, catalogCSV:
+		return f, true
+	case "":
+	default:
+		return "", false
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return catalogJSON, true
+		case "application/xml", "text/xml":
+			return catalogXML, true
+		case "text/csv":
+			return catalogCSV, true
+		}
+	}
+	return catalogJSON, true
+}
+
+type catalogXMLDocument struct {
+	XMLName xml.Name          `xml:"catalog"`
+	Entries []catalogXMLEntry `xml:"entry"`
+}
+
+type catalogXMLEntry struct {
+	Name         string `xml:"name"`
+	Path         string `xml:"path"`
+	Type         string `xml:"type"`
+	Size         int64  `xml:"size,omitempty"`
+	LastModified string `xml:"lastModified,omitempty"`
+	StorageClass string `xml:"storageClass,omitempty"`
+	ETag         string `xml:"etag,omitempty"`
+	SHA1         string `xml:"sha1,omitempty"`
+	Source       string `xml:"source,omitempty"`
+}
+
+var catalogCSVHeader = []string{"name", "path", "type", "size", "lastModified", "storageClass", "etag", "sha1", "source"}
+
+// catalogContentType is the Content-Type of a catalog in format.
+func catalogContentType(format string) string {
+	switch format {
+	case catalogXML:
+		return "application/xml"
+	case catalogCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// writeCatalog encodes catalog entries as JSON, XML or CSV.
+func (s *Server) writeCatalog(w http.ResponseWriter, format string, entries []storage.Entry) {
+	lastModified := func(e storage.Entry) string {
+		if e.LastModified == nil {
+			return ""
+		}
+		return e.LastModified.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", catalogContentType(format))
+	var err error
+	switch format {
+	case catalogXML:
+		doc := catalogXMLDocument{Entries: make([]catalogXMLEntry, 0, len(entries))}
+		for _, e := range entries {
+			doc.Entries = append(doc.Entries, catalogXMLEntry{
+				Name:         e.Name,
+				Path:         e.Path,
+				Type:         e.Type,
+				Size:         e.Size,
+				LastModified: lastModified(e),
+				StorageClass: e.StorageClass,
+				ETag:         e.ETag,
+				SHA1:         e.SHA1,
+				Source:       e.Source,
+			})
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(xml.Header))
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		err = enc.Encode(doc)
+	case catalogCSV:
+		w.WriteHeader(http.StatusOK)
+		cw := csv.NewWriter(w)
+		_ = cw.Write(catalogCSVHeader)
+		for _, e := range entries {
+			size := ""
+			if e.Type == "file" {
+				size = strconv.FormatInt(e.Size, 10)
+			}
+			_ = cw.Write([]string{e.Name, e.Path, e.Type, size, lastModified(e), e.StorageClass, e.ETag, e.SHA1, e.Source})
+		}
+		cw.Flush()
+		err = cw.Error()
+	default:
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(entries)
+	}
+	if err != nil {
+		s.logger.Warn("encode catalog", zap.String("format", format), zap.Error(err))
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+const defaultCDNURLTTL = 5 * time.Minute
+
+// CDNSigner issues CloudFront signed URLs (canned policy) for objects of
+// the bucket behind a distribution.
+type CDNSigner struct {
+	baseURL   string
+	keyPairID string
+	key       *rsa.PrivateKey
+	ttl       time.Duration
+	// bucket is the bucket served by the distribution; keys stored
+	// elsewhere are not redirected. Empty matches any.
+	bucket string
+}
+
+// bucketKeyMapper is implemented by stores that can name the bucket and
+// object key behind a key, see storage.Store.BucketKey.
+type bucketKeyMapper interface {
+	BucketKey(key string) (string, string)
+}
+
+// NewCDNSigner signs URLs below baseURL with the PEM-encoded RSA key
+// (PKCS#1 or PKCS#8) of the CloudFront key pair or public key keyPairID.
+func NewCDNSigner(baseURL, keyPairID string, pemKey []byte, ttl time.Duration, bucket string) (*CDNSigner, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid CDN URL %q", baseURL)
+	}
+	if keyPairID == "" {
+		return nil, errors.New("CDN key pair id is required")
+	}
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("CDN private key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		parsed, err8 := x509.ParsePKCS8PrivateKey(block.Bytes)
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); err8 != nil || !ok {
+			return nil, errors.New("CDN private key must be an RSA key")
+		}
+	}
+	if ttl <= 0 {
+		ttl = defaultCDNURLTTL
+	}
+	return &CDNSigner{baseURL: strings.TrimSuffix(baseURL, "/"), keyPairID: keyPairID, key: key, ttl: ttl, bucket: bucket}, nil
+}
+
+// SignedURL returns the URL of objectKey valid until expires.
+func (c *CDNSigner) SignedURL(objectKey string, expires time.Time) (string, error) {
+	resource := c.baseURL + "/" + strings.TrimPrefix((&url.URL{Path: "/" + objectKey}).EscapedPath(), "/")
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	policy := `{"Statement":[{"Resource":"` + resource + `","Condition":{"DateLessThan":{"AWS:EpochTime":` + exp + `}}}]}`
+	digest := sha1.Sum([]byte(policy))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA1, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return resource + "?Expires=" + exp + "&Signature=" + cloudFrontBase64(sig) + "&Key-Pair-Id=" + url.QueryEscape(c.keyPairID), nil
+}
+
+// cloudFrontBase64 is base64 with the characters CloudFront expects in
+// query strings.
+func cloudFrontBase64(b []byte) string {
+	return strings.NewReplacer("+", "-", "=", "_", "/", "~").Replace(base64.StdEncoding.EncodeToString(b))
+}
+
+// redirectToCDN answers a GET with a redirect to a signed CDN URL when the
+// object is stored in the distribution's bucket. Metadata is always served
+// directly, since edges may cache it past updates, and so are downloads
+// that must be verified, and content-addressed objects, whose key only
+// holds an empty pointer. It reports false to let handleGet serve the
+// request.
+func (s *Server) redirectToCDN(w http.ResponseWriter, r *http.Request, key string) bool {
+	if isMetadataPath(key) || s.verifiesDownload(key) {
+		return false
+	}
+	bucket, objectKey := "", key
+	if m, ok := s.store.(bucketKeyMapper); ok {
+		bucket, objectKey = m.BucketKey(key)
+	}
+	if s.cdn.bucket != "" && bucket != s.cdn.bucket {
+		return false
+	}
+	head, err := s.store.Head(r.Context(), key)
+	if err == nil && s.revalidate(r.Context(), key, head.LastModified, head.Metadata) {
+		head, err = s.store.Head(r.Context(), key)
+	}
+	if err != nil || storage.IsCASPointer(head.Metadata) {
+		return false
+	}
+	target, err := s.cdn.SignedURL(objectKey, time.Now().Add(s.cdn.ttl))
+	if err != nil {
+		s.logger.Warn("sign cdn url", zap.String("key", key), zap.Error(err))
+		return false
+	}
+	s.cache.record(key, cacheHit, aws.ToInt64(head.ContentLength))
+	s.downloads.Record(key)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+	return true
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCDNRedirect(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	cdn, err := NewCDNSigner("https://cdn.example.com/", "K2JCJMDEHXQW5F", pemKey, time.Minute, "")
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+
+	store := newMemStore()
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{CDN: cdn})
+	ctx := context.Background()
+	const jar = "releases/com/acme/lib/1.0/lib 1.0.jar"
+	_ = store.Put(ctx, jar, bytes.NewReader([]byte("jar")), "application/java-archive", 3)
+	_ = store.Put(ctx, "releases/com/acme/lib/maven-metadata.xml", bytes.NewReader([]byte("<metadata/>")), "application/xml", 11)
+
+	get := func(p string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, (&url.URL{Path: "/" + p}).EscapedPath(), nil))
+		return rec
+	}
+
+	rec := get(jar)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected 302, got %d: %s", rec.Code, rec.Body.String())
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource := "https://cdn.example.com" + loc.EscapedPath()
+	if !strings.HasSuffix(resource, "/lib%201.0.jar") {
+		t.Fatalf("unexpected resource %s", resource)
+	}
+	q := loc.Query()
+	if q.Get("Key-Pair-Id") != "K2JCJMDEHXQW5F" {
+		t.Fatalf("unexpected key pair id %q", q.Get("Key-Pair-Id"))
+	}
+	policy := `{"Statement":[{"Resource":"` + resource + `","Condition":{"DateLessThan":{"AWS:EpochTime":` + q.Get("Expires") + `}}}]}`
+	sig, err := base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(q.Get("Signature")))
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	digest := sha1.Sum([]byte(policy))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, digest[:], sig); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+
+	if rec := get("releases/com/acme/lib/maven-metadata.xml"); rec.Code != http.StatusOK {
+		t.Fatalf("expected metadata to be served directly, got %d", rec.Code)
+	}
+	// with CAS_STORAGE the key holds an empty pointer the CDN would serve
+	const pointer = "releases/com/acme/lib/1.1/lib-1.1.jar"
+	store.data[pointer] = memObj{metadata: map[string]string{"heimdall-cas-sha256": strings.Repeat("ab", 32)}}
+	if rec := get(pointer); rec.Code == http.StatusFound {
+		t.Fatalf("content-addressed object redirected to the CDN: %s", rec.Header().Get("Location"))
+	}
+	if rec := get("releases/com/acme/lib/1.0/missing.jar"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing object, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// checksumIndexPrefix maps digests to paths with one empty marker object per
+// match, at checksumIndexPrefix+"<algorithm>/<digest>/<path>", so a lookup
+// is a single listing and writers never need to coordinate.
+const checksumIndexPrefix = "__checksums__/"
+
+// checksumIndexState records when the index task last finished.
+const checksumIndexState = checksumIndexPrefix + "state.json"
+
+// checksumAlgorithms are the indexed digests and their hex lengths.
+var checksumAlgorithms = map[string]int{"sha1": 40, "sha256": 64}
+
+// ChecksumMatch is a path whose content has the looked up digest.
+type ChecksumMatch struct {
+	Path         string     `json:"path"`
+	Size         int64      `json:"size"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+}
+
+type ChecksumLookupResponse struct {
+	Algorithm string          `json:"algorithm" example:"sha1"`
+	Digest    string          `json:"digest"`
+	Matches   []ChecksumMatch `json:"matches"`
+}
+
+type checksumIndexRun struct {
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+func checksumMarker(algo, digest, key string) string {
+	return checksumIndexPrefix + algo + "/" + digest + "/" + key
+}
+
+// indexChecksum records that key has the given digest.
+func (s *Server) indexChecksum(ctx context.Context, algo, digest, key string) {
+	if err := s.store.Put(ctx, checksumMarker(algo, digest, key), strings.NewReader(""), "application/octet-stream", 0); err != nil {
+		s.logger.Warn("index checksum", zap.String("key", key), zap.String("algorithm", algo), zap.Error(err))
+	}
+}
+
+// lookupChecksum returns the paths indexed under digest that still hold it.
+// Markers of deleted or overwritten artifacts are removed on the way.
+func (s *Server) lookupChecksum(ctx context.Context, algo, digest string) ([]ChecksumMatch, error) {
+	prefix := checksumIndexPrefix + algo + "/" + digest
+	matches := []ChecksumMatch{}
+	err := walkStore(ctx, s.store, prefix, func(e storage.Entry) error {
+		key := strings.TrimPrefix(e.Path, prefix+"/")
+		head, err := s.store.Head(ctx, key)
+		if err != nil && !storage.IsNotFound(err) {
+			return err
+		}
+		if err != nil || !s.stillMatches(ctx, algo, digest, key, head.LastModified, e.LastModified) {
+			if err := s.store.Delete(ctx, e.Path); err != nil && !storage.IsNotFound(err) {
+				s.logger.Warn("delete stale checksum marker", zap.String("key", e.Path), zap.Error(err))
+			}
+			return nil
+		}
+		m := ChecksumMatch{Path: key, LastModified: head.LastModified}
+		if head.ContentLength != nil {
+			m.Size = *head.ContentLength
+		}
+		matches = append(matches, m)
+		return nil
+	})
+	return matches, err
+}
+
+// stillMatches checks a marker against the artifact's sidecar or, without
+// one, against the artifact being rewritten after the marker.
+func (s *Server) stillMatches(ctx context.Context, algo, digest, key string, modified, indexed *time.Time) bool {
+	if sum := s.readChecksumSidecar(ctx, key+"."+algo); sum != "" {
+		return sum == digest
+	}
+	return modified == nil || indexed == nil || !modified.After(*indexed)
+}
+
+// @Summary Find artifacts by checksum
+// @Description Returns every path whose content has the given SHA-1 or SHA-256, e.g. to locate a known-bad binary. Backed by the checksum index, which covers uploads as they happen and other artifacts (proxy caches, copies) once the checksum-index task has seen their .sha1/.sha256 sidecars.
+// @Tags admin
+// @Produce json
+// @Param algorithm path string true "sha1 or sha256"
+// @Param digest path string true "Hex digest"
+// @Success 200 {object} ChecksumLookupResponse
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/checksum/{algorithm}/{digest} [get]
+func (s *Server) handleChecksumLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	algo, digest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, apiV1+"/checksum/"), "/")
+	digest = strings.ToLower(digest)
+	size, ok := checksumAlgorithms[algo]
+	if !ok {
+		writeAPIError(w, "algorithm must be sha1 or sha256", http.StatusBadRequest)
+		return
+	}
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != size {
+		writeAPIError(w, "invalid "+algo+" digest", http.StatusBadRequest)
+		return
+	}
+	matches, err := s.lookupChecksum(r.Context(), algo, digest)
+	if err != nil {
+		s.logger.Error("checksum lookup", zap.Error(err))
+		writeAPIError(w, "checksum lookup failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ChecksumLookupResponse{Algorithm: algo, Digest: digest, Matches: matches}); err != nil {
+		s.logger.Warn("encode checksum lookup", zap.Error(err))
+	}
+}
+
+// checksumIndexTask indexes the .sha1 and .sha256 sidecars written since
+// its last run, all of them the first time; it is the "checksum-index"
+// task.
+func (s *Server) checksumIndexTask(ctx context.Context) error {
+	var last checksumIndexRun
+	if resp, err := s.store.Get(ctx, checksumIndexState); err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&last)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	} else if !storage.IsNotFound(err) {
+		return err
+	}
+
+	// Sidecars written while the walk runs are seen by the next run.
+	started := time.Now()
+	indexed := 0
+	err := walkStore(ctx, s.store, "", func(e storage.Entry) error {
+		if isInternalPath(e.Path) || (e.LastModified != nil && e.LastModified.Before(last.FinishedAt)) {
+			return nil
+		}
+		ext := strings.TrimPrefix(path.Ext(e.Path), ".")
+		size, ok := checksumAlgorithms[ext]
+		if !ok {
+			return nil
+		}
+		digest := s.readChecksumSidecar(ctx, e.Path)
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != size {
+			return nil
+		}
+		s.indexChecksum(ctx, ext, digest, strings.TrimSuffix(e.Path, "."+ext))
+		indexed++
+		taskCheckpoint(ctx, e.Path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(checksumIndexRun{FinishedAt: started})
+	if err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, checksumIndexState, strings.NewReader(string(body)), "application/json", int64(len(body))); err != nil {
+		return err
+	}
+	s.logger.Info("checksum index updated", zap.Int("indexed", indexed))
+	return nil
+}
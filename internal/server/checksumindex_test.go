@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestChecksumLookup(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	body := []byte("known bad binary")
+	sha1sum := sha1.Sum(body)
+	digest := hex.EncodeToString(sha1sum[:])
+
+	req := httptest.NewRequest(http.MethodPut, "/releases/com/acme/evil/1.0/evil-1.0.jar", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("put: %d", rec.Code)
+	}
+	// A cached copy that only the index task knows about.
+	_ = store.Put(ctx, "central/org/other/2.0/other-2.0.jar", bytes.NewReader(body), "", int64(len(body)))
+	sidecar := digest + "  other-2.0.jar"
+	_ = store.Put(ctx, "central/org/other/2.0/other-2.0.jar.sha1", strings.NewReader(sidecar), "text/plain", int64(len(sidecar)))
+	if err := srv.checksumIndexTask(ctx); err != nil {
+		t.Fatalf("index task: %v", err)
+	}
+
+	lookup := func(algo, digest string) (int, ChecksumLookupResponse) {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/checksum/"+algo+"/"+digest, nil))
+		var resp ChecksumLookupResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	paths := func(resp ChecksumLookupResponse) []string {
+		var out []string
+		for _, m := range resp.Matches {
+			out = append(out, m.Path)
+		}
+		return out
+	}
+
+	code, resp := lookup("sha1", strings.ToUpper(digest))
+	if code != http.StatusOK || len(resp.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d %v", code, paths(resp))
+	}
+	if resp.Matches[0].Size != int64(len(body)) {
+		t.Fatalf("expected the artifact size, got %d", resp.Matches[0].Size)
+	}
+	sha256sum := sha256.Sum256(body)
+	if _, resp := lookup("sha256", hex.EncodeToString(sha256sum[:])); len(resp.Matches) != 1 || resp.Matches[0].Path != "releases/com/acme/evil/1.0/evil-1.0.jar" {
+		t.Fatalf("expected the uploaded artifact by sha256, got %v", paths(resp))
+	}
+
+	// Overwritten and deleted artifacts drop out of the index.
+	req = httptest.NewRequest(http.MethodPut, "/releases/com/acme/evil/1.0/evil-1.0.jar", strings.NewReader("fixed"))
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	_ = store.Delete(ctx, "central/org/other/2.0/other-2.0.jar")
+	if _, resp := lookup("sha1", digest); len(resp.Matches) != 0 {
+		t.Fatalf("expected no matches, got %v", paths(resp))
+	}
+	for key := range store.data {
+		if strings.HasPrefix(key, checksumIndexPrefix+"sha1/"+digest) {
+			t.Fatalf("expected stale marker %s to be removed", key)
+		}
+	}
+
+	if code, _ := lookup("md5", digest); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown algorithm, got %d", code)
+	}
+	if code, _ := lookup("sha1", "abc"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a short digest, got %d", code)
+	}
+}
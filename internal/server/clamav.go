@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const clamdChunkSize = 64 * 1024
+
+// ClamAV scans upload bodies through clamd's INSTREAM command.
+type ClamAV struct {
+	Network string
+	Address string
+	Timeout time.Duration
+}
+
+// NewClamAV accepts "unix:/path/to/clamd.sock", "tcp://host:port" or a bare
+// host:port.
+func NewClamAV(addr string, timeout time.Duration) *ClamAV {
+	network, address := "tcp", addr
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		network, address = "unix", strings.TrimPrefix(strings.TrimPrefix(addr, "unix:"), "//")
+	case strings.HasPrefix(addr, "tcp://"):
+		address = strings.TrimPrefix(addr, "tcp://")
+	case strings.HasPrefix(addr, "/"):
+		network = "unix"
+	}
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	return &ClamAV{Network: network, Address: address, Timeout: timeout}
+}
+
+// InfectedError is returned when clamd reports a signature match.
+type InfectedError struct {
+	Signature string
+}
+
+func (e InfectedError) Error() string {
+	return "infected file: " + e.Signature
+}
+
+// Scan streams r to clamd. It returns InfectedError when a virus is found.
+func (c *ClamAV) Scan(r io.Reader) error {
+	conn, err := net.DialTimeout(c.Network, c.Address, c.Timeout)
+	if err != nil {
+		return fmt.Errorf("connect clamd: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("clamd instream: %w", err)
+	}
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := conn.Write(size[:]); err != nil {
+				return fmt.Errorf("clamd stream: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return fmt.Errorf("clamd stream: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("clamd stream: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && !(err == io.EOF && len(reply) > 0) {
+		return fmt.Errorf("clamd reply: %w", err)
+	}
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+func parseClamdReply(reply string) error {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		return InfectedError{Signature: strings.TrimSuffix(result, " FOUND")}
+	}
+	return fmt.Errorf("clamd: %s", result)
+}
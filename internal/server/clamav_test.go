@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeClamd answers INSTREAM requests, flagging bodies containing "EICAR".
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if _, err := r.ReadBytes(0); err != nil {
+					return
+				}
+				var body bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&body, r, int64(size)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(body.String(), "EICAR") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestHandlePutRejectsInfectedUpload(t *testing.T) {
+	store := &mockStore{}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{ClamAV: NewClamAV(fakeClamd(t), time.Second)})
+
+	req := httptest.NewRequest(http.MethodPut, "/releases/evil.jar", strings.NewReader("X5O!P%@AP EICAR"))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+	if len(store.putKeys) != 0 {
+		t.Fatalf("infected upload must not be stored, got %v", store.putKeys)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/releases/good.jar", strings.NewReader("clean"))
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+}
+
+func TestHandlePutClamdUnavailable(t *testing.T) {
+	store := &mockStore{}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{ClamAV: NewClamAV("127.0.0.1:1", time.Second)})
+
+	req := httptest.NewRequest(http.MethodPut, "/releases/app.jar", strings.NewReader("data"))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+}
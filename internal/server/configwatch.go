@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// configVersionKey is rewritten with a new random value on every proxy
+// change, so replicas notice changes made elsewhere with one small read.
+// Proxy listings only load .json files and skip it.
+const configVersionKey = proxyConfigPrefix + "version"
+
+// configChanged drops the cached proxies and publishes a new configuration
+// version for the other replicas. Publishing failures are only logged: the
+// change itself is stored, other replicas just see it later.
+func (p *ProxyManager) configChanged(ctx context.Context) {
+	p.invalidateConfig()
+	version, err := randomHex(8)
+	if err == nil {
+		err = p.store.Put(context.WithoutCancel(ctx), configVersionKey, strings.NewReader(version), "text/plain", int64(len(version)))
+	}
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Warn("publish config version", zap.Error(err))
+		}
+		return
+	}
+	p.configMu.Lock()
+	p.version = version
+	p.configMu.Unlock()
+}
+
+// invalidateConfig forgets the cached proxy list and remembered upstream
+// misses, which may belong to an older configuration.
+func (p *ProxyManager) invalidateConfig() {
+	p.configMu.Lock()
+	p.cached = nil
+	p.generation++
+	p.configMu.Unlock()
+	p.misses.reset()
+}
+
+// configVersion reads the published configuration version; "" if none was
+// published yet.
+func (p *ProxyManager) configVersion(ctx context.Context) (string, error) {
+	resp, err := p.store.Get(ctx, configVersionKey)
+	if storage.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	return string(b), err
+}
+
+// WatchConfig caches the proxy configuration in memory and polls the
+// configuration version every interval until ctx is done, dropping the
+// cache when another replica changed it. Without it every lookup reads the
+// proxy list from the bucket.
+func (s *Server) WatchConfig(ctx context.Context, interval time.Duration) {
+	p := s.proxy
+	s.startConfigCache(ctx)
+	defer func() {
+		p.configMu.Lock()
+		p.caching = false
+		p.configMu.Unlock()
+		p.invalidateConfig()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.pollConfig(ctx)
+	}
+}
+
+// startConfigCache turns on proxy caching from the current version.
+func (s *Server) startConfigCache(ctx context.Context) {
+	p := s.proxy
+	version, err := p.configVersion(ctx)
+	if err != nil {
+		s.logger.Warn("read config version", zap.Error(err))
+	}
+	p.configMu.Lock()
+	p.caching, p.version = true, version
+	p.configMu.Unlock()
+}
+
+// pollConfig drops the cached configuration if its version changed.
+func (s *Server) pollConfig(ctx context.Context) {
+	p := s.proxy
+	version, err := p.configVersion(ctx)
+	if err != nil {
+		// Stale config is worse than a few extra reads.
+		s.logger.Warn("read config version", zap.Error(err))
+		p.invalidateConfig()
+		return
+	}
+	p.configMu.Lock()
+	changed := version != p.version
+	p.version = version
+	p.configMu.Unlock()
+	if !changed {
+		return
+	}
+	s.logger.Info("configuration changed on another replica; reloading", zap.String("version", version))
+	p.invalidateConfig()
+	if s.metrics != nil {
+		s.metrics.ConfigReloads.Inc()
+	}
+}
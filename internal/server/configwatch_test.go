@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestConfigChangesPropagate(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	ma := metrics.New()
+	a := New(store, zaptest.NewLogger(t), ma, "", "")
+	b := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	a.startConfigCache(ctx)
+	b.startConfigCache(ctx)
+
+	if list, err := a.proxy.List(ctx); err != nil || len(list) != 0 {
+		t.Fatalf("list: %v %v", list, err)
+	}
+	a.proxy.misses.record("central/com/acme/lib/1.0/lib-1.0.pom", time.Now())
+
+	// b adds a proxy: a keeps its cached list until it polls the version.
+	if err := b.proxy.Add(ctx, Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if list, _ := a.proxy.List(ctx); len(list) != 0 {
+		t.Fatalf("expected a cached list before polling, got %v", list)
+	}
+	a.pollConfig(ctx)
+	if list, _ := a.proxy.List(ctx); len(list) != 1 || list[0].Name != "central" {
+		t.Fatalf("expected the new proxy after polling, got %v", list)
+	}
+	if a.proxy.knownMiss(Proxy{Name: "central"}, "com/acme/lib/1.0/lib-1.0.pom") {
+		t.Fatal("expected remembered misses to be dropped")
+	}
+	if got := testutil.ToFloat64(ma.ConfigReloads); got != 1 {
+		t.Fatalf("expected 1 reload, got %v", got)
+	}
+
+	// b's own change is visible to b at once.
+	if err := b.proxy.Delete(ctx, "central"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if list, _ := b.proxy.List(ctx); len(list) != 0 {
+		t.Fatalf("expected b to see its own delete, got %v", list)
+	}
+	a.pollConfig(ctx)
+	b.pollConfig(ctx)
+	if list, _ := a.proxy.List(ctx); len(list) != 0 {
+		t.Fatalf("expected a to see the delete, got %v", list)
+	}
+}
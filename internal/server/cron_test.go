@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.UTC) // Wednesday
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * 0", time.Date(2024, 2, 4, 2, 30, 0, 0, time.UTC)},
+		{"30 2 * * 7", time.Date(2024, 2, 4, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2024, 1, 31, 13, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted.
+		{"0 0 15 * 5", time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		{"5,10 * * * *", time.Date(2024, 1, 31, 11, 5, 0, 0, time.UTC)},
+		{"@every 90m", from.Add(90 * time.Minute)},
+	}
+	for _, tc := range cases {
+		schedule, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.spec, err)
+		}
+		if got := schedule.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%s: next = %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+
+	never, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := never.Next(from); !got.IsZero() {
+		t.Fatalf("expected no activation, got %v", got)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every -1m", "@every soon", "@sometimes"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
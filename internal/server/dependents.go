@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// dependentsPrefix maps artifacts to the hosted POMs that depend on them,
+// with one marker per pair at dependentsPrefix+"<groupId>/<artifactId>/<pom>".
+// The marker holds the matching declarations as JSON, so a lookup is one
+// listing plus a read per dependent.
+const dependentsPrefix = "__dependents__/"
+
+// dependentsIndexState records when the index task last finished.
+const dependentsIndexState = dependentsPrefix + "state.json"
+
+// maxDependentsDepth bounds transitive lookups.
+const maxDependentsDepth = 10
+
+// Dependent is a hosted POM declaring a dependency on the looked up artifact.
+type Dependent struct {
+	Path       string `json:"path" example:"releases/com/acme/app/2.1/app-2.1.pom"`
+	GroupID    string `json:"groupId" example:"com.acme"`
+	ArtifactID string `json:"artifactId" example:"app"`
+	Version    string `json:"version" example:"2.1"`
+	// DependencyVersion is the version asked for, as written when it could
+	// not be resolved; empty when it comes from dependency management.
+	DependencyVersion string `json:"dependencyVersion,omitempty" example:"1.4.2"`
+	Scope             string `json:"scope,omitempty" example:"compile"`
+	Optional          bool   `json:"optional,omitempty"`
+	// Managed marks dependencyManagement entries, such as imported BOMs.
+	Managed bool `json:"managed,omitempty"`
+	// Depth is 1 for direct dependents, 2 for their dependents, and so on.
+	Depth int `json:"depth" example:"1"`
+}
+
+type DependentsResponse struct {
+	GroupID    string      `json:"groupId" example:"com.acme"`
+	ArtifactID string      `json:"artifactId" example:"lib"`
+	Dependents []Dependent `json:"dependents"`
+}
+
+type dependentsIndexRun struct {
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+func dependentsMarker(group, artifact, key string) string {
+	return dependentsPrefix + group + "/" + artifact + "/" + key
+}
+
+// indexDependencies records the dependencies declared by the POM stored at
+// key. POMs that cannot be parsed are skipped.
+func (s *Server) indexDependencies(ctx context.Context, key string, r io.Reader) {
+	p, err := parsePOM(io.LimitReader(r, pomLimit))
+	if err != nil {
+		s.logger.Debug("dependency index: skip unparsable pom", zap.String("key", key), zap.Error(err))
+		return
+	}
+	edges := map[[2]string][]Dependent{}
+	add := func(d POMDependency, managed bool) {
+		if d.GroupID == "" || d.ArtifactID == "" || strings.Contains(d.GroupID+d.ArtifactID, "${") {
+			return
+		}
+		ga := [2]string{d.GroupID, d.ArtifactID}
+		edges[ga] = append(edges[ga], Dependent{
+			GroupID:           p.GroupID,
+			ArtifactID:        p.ArtifactID,
+			Version:           p.Version,
+			DependencyVersion: d.Version,
+			Scope:             d.Scope,
+			Optional:          d.Optional,
+			Managed:           managed,
+		})
+	}
+	for _, d := range p.Dependencies {
+		add(d, false)
+	}
+	for _, d := range p.ManagedDependencies {
+		add(d, true)
+	}
+	for ga, deps := range edges {
+		body, err := json.Marshal(deps)
+		if err != nil {
+			continue
+		}
+		if err := s.store.Put(ctx, dependentsMarker(ga[0], ga[1], key), strings.NewReader(string(body)), "application/json", int64(len(body))); err != nil {
+			s.logger.Warn("index dependency", zap.String("key", key), zap.String("dependency", ga[0]+":"+ga[1]), zap.Error(err))
+		}
+	}
+}
+
+// directDependents returns the hosted POMs indexed as depending on
+// group:artifact. Markers of POMs deleted or rewritten since they were
+// indexed are removed on the way; the index task adds them back when the
+// new POM still declares the dependency.
+func (s *Server) directDependents(ctx context.Context, group, artifact string) ([]Dependent, error) {
+	prefix := dependentsPrefix + group + "/" + artifact + "/"
+	var out []Dependent
+	err := walkStore(ctx, s.store, prefix, func(e storage.Entry) error {
+		key := strings.TrimPrefix(e.Path, prefix)
+		head, err := s.store.Head(ctx, key)
+		if err != nil && !storage.IsNotFound(err) {
+			return err
+		}
+		if err != nil || (head.LastModified != nil && e.LastModified != nil && head.LastModified.After(*e.LastModified)) {
+			if err := s.store.Delete(ctx, e.Path); err != nil && !storage.IsNotFound(err) {
+				s.logger.Warn("delete stale dependency marker", zap.String("key", e.Path), zap.Error(err))
+			}
+			return nil
+		}
+		resp, err := s.store.Get(ctx, e.Path)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		var deps []Dependent
+		err = json.NewDecoder(resp.Body).Decode(&deps)
+		resp.Body.Close()
+		if err != nil {
+			s.logger.Warn("read dependency marker", zap.String("key", e.Path), zap.Error(err))
+			return nil
+		}
+		for _, d := range deps {
+			d.Path = key
+			out = append(out, d)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// dependents returns the direct dependents of group:artifact, only those
+// asking for version when it is set, and, when transitive is set, their
+// dependents in turn, breadth first. Only compile and runtime dependencies
+// that are neither optional nor managed are followed, since only those
+// reach the dependents' own consumers.
+func (s *Server) dependents(ctx context.Context, group, artifact, version string, transitive bool) ([]Dependent, error) {
+	type node struct {
+		group, artifact string
+		depth           int
+	}
+	queue := []node{{group, artifact, 1}}
+	seen := map[[2]string]bool{{group, artifact}: true}
+	out := []Dependent{}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		deps, err := s.directDependents(ctx, n.group, n.artifact)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deps {
+			if n.depth == 1 && version != "" && d.DependencyVersion != version {
+				continue
+			}
+			d.Depth = n.depth
+			out = append(out, d)
+			ga := [2]string{d.GroupID, d.ArtifactID}
+			follow := !d.Optional && !d.Managed && (d.Scope == "" || d.Scope == "compile" || d.Scope == "runtime")
+			if transitive && follow && !seen[ga] && n.depth < maxDependentsDepth {
+				seen[ga] = true
+				queue = append(queue, node{d.GroupID, d.ArtifactID, n.depth + 1})
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Dependent) int {
+		if a.Depth != b.Depth {
+			return a.Depth - b.Depth
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	return out, nil
+}
+
+// @Summary Find dependents of an artifact
+// @Description Lists the hosted POMs that declare a dependency on groupId:artifactId, directly or in dependencyManagement, e.g. to find what must be rebuilt when a library has a vulnerability. version keeps only dependents asking for that version. With transitive=true the dependents of dependents are added too (up to 10 levels), following compile and runtime dependencies that are neither optional nor managed. Backed by the dependency index, which covers uploads as they happen and other POMs once the dependency-index task has seen them. Dependents a deploy token cannot read are left out.
+// @Tags artifacts
+// @Produce json
+// @Param groupId path string true "Group ID"
+// @Param artifactId path string true "Artifact ID"
+// @Param version query string false "Dependency version"
+// @Param transitive query bool false "Include indirect dependents"
+// @Success 200 {object} DependentsResponse
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/dependents/{groupId}/{artifactId} [get]
+func (s *Server) handleDependents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	group, artifact, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, apiV1+"/dependents/"), "/")
+	if !ok || !coordinateRe.MatchString(group) || !coordinateRe.MatchString(artifact) {
+		writeAPIError(w, "path must be /dependents/{groupId}/{artifactId}", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	transitive, _ := strconv.ParseBool(q.Get("transitive"))
+
+	deps, err := s.dependents(r.Context(), group, artifact, q.Get("version"), transitive)
+	if err != nil {
+		s.logger.Error("dependents lookup", zap.String("artifact", group+":"+artifact), zap.Error(err))
+		writeAPIError(w, "dependents lookup failed", http.StatusInternalServerError)
+		return
+	}
+	if tok := principalFrom(r.Context()).token; tok != nil {
+		deps = slices.DeleteFunc(deps, func(d Dependent) bool { return !tok.Allows("read", d.Path) })
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(DependentsResponse{GroupID: group, ArtifactID: artifact, Dependents: deps}); err != nil {
+		s.logger.Warn("encode dependents", zap.Error(err))
+	}
+}
+
+// dependencyIndexTask indexes the hosted POMs written since its last run,
+// all of them the first time; it is the "dependency-index" task.
+func (s *Server) dependencyIndexTask(ctx context.Context) error {
+	var last dependentsIndexRun
+	if resp, err := s.store.Get(ctx, dependentsIndexState); err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&last)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	} else if !storage.IsNotFound(err) {
+		return err
+	}
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		return err
+	}
+	proxied := make(map[string]bool, len(proxies))
+	for _, pr := range proxies {
+		proxied[pr.Name] = true
+	}
+
+	// POMs written while the walk runs are seen by the next run.
+	started := time.Now()
+	indexed := 0
+	err = walkStore(ctx, s.store, "", func(e storage.Entry) error {
+		if !strings.HasSuffix(e.Path, ".pom") || isInternalPath(e.Path) || (e.LastModified != nil && e.LastModified.Before(last.FinishedAt)) {
+			return nil
+		}
+		if repo, _, _ := strings.Cut(e.Path, "/"); proxied[repo] {
+			return nil
+		}
+		resp, err := s.store.Get(ctx, e.Path)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		s.indexDependencies(ctx, e.Path, resp.Body)
+		resp.Body.Close()
+		indexed++
+		taskCheckpoint(ctx, e.Path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(dependentsIndexRun{FinishedAt: started})
+	if err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, dependentsIndexState, strings.NewReader(string(body)), "application/json", int64(len(body))); err != nil {
+		return err
+	}
+	s.logger.Info("dependency index updated", zap.Int("indexed", indexed))
+	return nil
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func testDependentPOM(gav string, deps ...string) string {
+	g, rest, _ := strings.Cut(gav, ":")
+	a, v, _ := strings.Cut(rest, ":")
+	var b strings.Builder
+	b.WriteString("<project><groupId>" + g + "</groupId><artifactId>" + a + "</artifactId><version>" + v + "</version><dependencies>")
+	for _, d := range deps {
+		parts := strings.Split(d, ":")
+		b.WriteString("<dependency><groupId>" + parts[0] + "</groupId><artifactId>" + parts[1] + "</artifactId><version>" + parts[2] + "</version>")
+		if len(parts) > 3 {
+			b.WriteString("<scope>" + parts[3] + "</scope>")
+		}
+		b.WriteString("</dependency>")
+	}
+	b.WriteString("</dependencies></project>")
+	return b.String()
+}
+
+func TestDependents(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+
+	// Uploads are indexed as they are written.
+	app := testDependentPOM("com.acme:app:2.1", "com.acme:lib:1.4.2", "junit:junit:4.13.2:test")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/releases/com/acme/app/2.1/app-2.1.pom", strings.NewReader(app)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("put: %d", rec.Code)
+	}
+	// POMs written behind Heimdall's back are found by the index task.
+	web := testDependentPOM("com.acme:web:3.0", "com.acme:app:2.1")
+	_ = store.Put(ctx, "releases/com/acme/web/3.0/web-3.0.pom", strings.NewReader(web), "application/xml", int64(len(web)))
+	bom := `<project><groupId>com.acme</groupId><artifactId>bom</artifactId><version>1</version><packaging>pom</packaging>
+<dependencyManagement><dependencies><dependency><groupId>com.acme</groupId><artifactId>lib</artifactId><version>1.5.0</version></dependency></dependencies></dependencyManagement></project>`
+	_ = store.Put(ctx, "releases/com/acme/bom/1/bom-1.pom", strings.NewReader(bom), "application/xml", int64(len(bom)))
+	if err := srv.dependencyIndexTask(ctx); err != nil {
+		t.Fatalf("index task: %v", err)
+	}
+
+	lookup := func(target string) (int, []Dependent) {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/dependents/"+target, nil))
+		var resp DependentsResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Dependents
+	}
+
+	code, deps := lookup("com.acme/lib")
+	if code != http.StatusOK || len(deps) != 2 {
+		t.Fatalf("expected 2 dependents, got %d %+v", code, deps)
+	}
+	if d := deps[0]; d.Path != "releases/com/acme/app/2.1/app-2.1.pom" || d.Version != "2.1" || d.DependencyVersion != "1.4.2" || d.Managed || d.Depth != 1 {
+		t.Errorf("app: %+v", d)
+	}
+	if d := deps[1]; d.ArtifactID != "bom" || !d.Managed || d.DependencyVersion != "1.5.0" {
+		t.Errorf("bom: %+v", d)
+	}
+	if _, deps := lookup("com.acme/lib?version=1.5.0"); len(deps) != 1 || deps[0].ArtifactID != "bom" {
+		t.Errorf("version filter: %+v", deps)
+	}
+	if _, deps := lookup("com.acme/lib?transitive=true"); len(deps) != 3 || deps[2].ArtifactID != "web" || deps[2].Depth != 2 {
+		t.Errorf("transitive: %+v", deps)
+	}
+	if _, deps := lookup("junit/junit?transitive=true"); len(deps) != 1 || deps[0].Scope != "test" {
+		t.Errorf("test scope is not followed: %+v", deps)
+	}
+
+	// Markers of deleted POMs are dropped on lookup.
+	_ = store.Delete(ctx, "releases/com/acme/app/2.1/app-2.1.pom")
+	if _, deps := lookup("com.acme/lib"); len(deps) != 1 {
+		t.Errorf("after delete: %+v", deps)
+	}
+	if _, err := store.Head(ctx, dependentsMarker("com.acme", "lib", "releases/com/acme/app/2.1/app-2.1.pom")); err == nil {
+		t.Error("stale marker kept")
+	}
+
+	if code, _ := lookup("com.acme"); code != http.StatusBadRequest {
+		t.Errorf("missing artifactId: got %d", code)
+	}
+}
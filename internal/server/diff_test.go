@@ -0,0 +1,86 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func testZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHandleDiff(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	rc1 := testZip(t, map[string]string{
+		"META-INF/":            "",
+		"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\n",
+		"com/acme/Util.class":  "v1",
+		"com/acme/Old.class":   "old",
+	})
+	rc2 := testZip(t, map[string]string{
+		"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\n",
+		"com/acme/Util.class":  "v2",
+		"com/acme/New.class":   "new",
+	})
+	for key, data := range map[string][]byte{
+		"releases/lib/1.0-RC1/lib-1.0-RC1.jar": rc1,
+		"releases/lib/1.0-RC2/lib-1.0-RC2.jar": rc2,
+		"releases/lib/1.0-RC2/lib-1.0-RC2.pom": []byte("<project/>"),
+	} {
+		if err := store.Put(ctx, key, bytes.NewReader(data), "application/java-archive", int64(len(data))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	get := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/diff?"+query, nil))
+		return rr
+	}
+
+	rr := get("path1=releases/lib/1.0-RC1/lib-1.0-RC1.jar&path2=releases/lib/1.0-RC2/lib-1.0-RC2.jar")
+	var d ArchiveDiff
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &d) != nil {
+		t.Fatalf("diff: %d %s", rr.Code, rr.Body.String())
+	}
+	if len(d.Added) != 1 || d.Added[0].Name != "com/acme/New.class" ||
+		len(d.Removed) != 1 || d.Removed[0].Name != "com/acme/Old.class" ||
+		len(d.Changed) != 1 || d.Changed[0].Name != "com/acme/Util.class" || d.Changed[0].CRC1 == d.Changed[0].CRC2 ||
+		d.Unchanged != 1 {
+		t.Fatalf("diff = %+v", d)
+	}
+
+	for query, want := range map[string]int{
+		"path1=releases/lib/1.0-RC1/lib-1.0-RC1.jar":                                            http.StatusBadRequest,
+		"path1=releases/lib/1.0-RC1/lib-1.0-RC1.jar&path2=releases/lib/missing.jar":             http.StatusNotFound,
+		"path1=releases/lib/1.0-RC1/lib-1.0-RC1.jar&path2=releases/lib/1.0-RC2/lib-1.0-RC2.pom": http.StatusUnsupportedMediaType,
+		"path1=__properties__/x.jar&path2=releases/lib/1.0-RC2/lib-1.0-RC2.jar":                 http.StatusBadRequest,
+	} {
+		if rr := get(query); rr.Code != want || !strings.Contains(rr.Header().Get("Content-Type"), "json") {
+			t.Errorf("%s: got %d, want %d", query, rr.Code, want)
+		}
+	}
+}
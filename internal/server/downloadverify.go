@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Download verification modes (Options.VerifyDownloads).
+const (
+	VerifyDownloadsOff        = "off"
+	VerifyDownloadsLog        = "log"
+	VerifyDownloadsInvalidate = "invalidate"
+)
+
+// verifiesDownload reports whether GET key should be hashed while streaming.
+func (s *Server) verifiesDownload(key string) bool {
+	if s.verifyDownloads == "" || s.verifyDownloads == VerifyDownloadsOff {
+		return false
+	}
+	return !isChecksumPath(key) && !isInternalPath(key)
+}
+
+// checkDownloadDigest compares the SHA-1 of a fully streamed object with its
+// .sha1 sidecar. On a mismatch in invalidate mode, a cached proxy artifact is
+// purged so the next request fetches it again; hosted artifacts are only
+// reported.
+func (s *Server) checkDownloadDigest(ctx context.Context, key, sum string) {
+	expected := s.readChecksumSidecar(ctx, key+".sha1")
+	result := "ok"
+	switch {
+	case expected == "":
+		result = "missing"
+	case expected != sum:
+		result = "mismatch"
+	}
+	if s.metrics != nil {
+		s.metrics.DownloadVerifications.WithLabelValues(result).Inc()
+	}
+	if result != "mismatch" {
+		return
+	}
+
+	s.logger.Warn("download checksum mismatch", zap.String("key", key), zap.String("expected", expected), zap.String("actual", sum))
+	if s.verifyDownloads != VerifyDownloadsInvalidate {
+		return
+	}
+	name, rel, ok := splitProxyKey(key)
+	if !ok {
+		return
+	}
+	proxy, found, err := s.proxy.findByName(ctx, name)
+	if err != nil || !found || !proxy.caches() {
+		return
+	}
+	if _, err := s.proxy.Invalidate(ctx, name, InvalidateRequest{Path: rel}); err != nil {
+		s.logger.Warn("invalidate corrupted cache entry", zap.String("key", key), zap.Error(err))
+		return
+	}
+	s.logger.Info("corrupted cache entry invalidated", zap.String("proxy", name), zap.String("key", key))
+}
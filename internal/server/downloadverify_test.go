@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestVerifyDownloads(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	const helloSHA1 = "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	for key, body := range map[string]string{
+		"releases/a/1.0/a-1.0.jar":      "hello",
+		"releases/a/1.0/a-1.0.jar.sha1": helloSHA1,
+		"releases/b/1.0/b-1.0.jar":      "corrupted",
+		"releases/b/1.0/b-1.0.jar.sha1": helloSHA1,
+		"releases/c/1.0/c-1.0.jar":      "no sidecar",
+		"central/d/1.0/d-1.0.jar":       "corrupted",
+		"central/d/1.0/d-1.0.jar.sha1":  helloSHA1,
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	m := metrics.New()
+	srv := NewWithOptions(store, zaptest.NewLogger(t), m, Options{VerifyDownloads: VerifyDownloadsInvalidate})
+	if err := srv.proxy.Add(ctx, Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2"}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+
+	for _, key := range []string{"releases/a/1.0/a-1.0.jar", "releases/b/1.0/b-1.0.jar", "releases/c/1.0/c-1.0.jar", "central/d/1.0/d-1.0.jar", "releases/a/1.0/a-1.0.jar.sha1"} {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+key, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", key, rr.Code)
+		}
+	}
+
+	for result, want := range map[string]float64{"ok": 1, "mismatch": 2, "missing": 1} {
+		if got := testutil.ToFloat64(m.DownloadVerifications.WithLabelValues(result)); got != want {
+			t.Fatalf("%s = %v, want %v", result, got, want)
+		}
+	}
+	if _, ok := store.data["releases/b/1.0/b-1.0.jar"]; !ok {
+		t.Fatal("hosted artifacts must never be invalidated")
+	}
+	for _, key := range []string{"central/d/1.0/d-1.0.jar", "central/d/1.0/d-1.0.jar.sha1"} {
+		if _, ok := store.data[key]; ok {
+			t.Fatalf("expected corrupted cache entry %s to be purged", key)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrorEvent describes a 5xx response or a failed background task.
+type ErrorEvent struct {
+	Time      time.Time         `json:"time"`
+	Source    string            `json:"source"`
+	Message   string            `json:"message"`
+	Error     string            `json:"error,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+	Method    string            `json:"method,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Status    int               `json:"status,omitempty"`
+	Context   map[string]string `json:"context,omitempty"`
+}
+
+// ErrorReporter forwards ErrorEvents to Sentry or a generic webhook from a
+// background worker. A nil *ErrorReporter discards events.
+type ErrorReporter struct {
+	send   func(ctx context.Context, ev ErrorEvent) error
+	logger *zap.Logger
+	queue  chan ErrorEvent
+}
+
+func newErrorReporter(logger *zap.Logger, send func(context.Context, ErrorEvent) error) *ErrorReporter {
+	return &ErrorReporter{send: send, logger: logger, queue: make(chan ErrorEvent, 100)}
+}
+
+// NewWebhookErrorReporter POSTs each event as JSON to url.
+func NewWebhookErrorReporter(url string, logger *zap.Logger) *ErrorReporter {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return newErrorReporter(logger, func(ctx context.Context, ev ErrorEvent) error {
+		return postJSON(ctx, client, url, nil, ev)
+	})
+}
+
+// NewSentryErrorReporter sends events to the Sentry store endpoint derived
+// from dsn (https://<key>@<host>/<project>).
+func NewSentryErrorReporter(dsn, environment string, logger *zap.Logger) (*ErrorReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid sentry dsn")
+	}
+	path := strings.Trim(u.Path, "/")
+	idx := strings.LastIndex(path, "/")
+	project, base := path[idx+1:], path[:idx+1]
+	if project == "" {
+		return nil, fmt.Errorf("invalid sentry dsn: missing project id")
+	}
+	endpoint := fmt.Sprintf("%s://%s/%sapi/%s/store/", u.Scheme, u.Host, base, project)
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=heimdall/1.0, sentry_key=%s", u.User.Username())
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return newErrorReporter(logger, func(ctx context.Context, ev ErrorEvent) error {
+		id := make([]byte, 16)
+		_, _ = rand.Read(id)
+		tags := map[string]string{"source": ev.Source}
+		if ev.RequestID != "" {
+			tags["request_id"] = ev.RequestID
+		}
+		if ev.Status != 0 {
+			tags["status"] = fmt.Sprint(ev.Status)
+		}
+		extra := map[string]any{"error": ev.Error, "method": ev.Method, "path": ev.Path}
+		for k, v := range ev.Context {
+			extra[k] = v
+		}
+		payload := map[string]any{
+			"event_id":    hex.EncodeToString(id),
+			"timestamp":   ev.Time.UTC().Format(time.RFC3339),
+			"level":       "error",
+			"logger":      "heimdall",
+			"platform":    "go",
+			"environment": environment,
+			"message":     map[string]string{"formatted": ev.Message},
+			"tags":        tags,
+			"extra":       extra,
+		}
+		return postJSON(ctx, client, endpoint, map[string]string{"X-Sentry-Auth": auth}, payload)
+	}), nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Report enqueues ev without blocking; events are dropped when the queue is
+// full so a broken backend never slows requests down.
+func (e *ErrorReporter) Report(ev ErrorEvent) {
+	if e == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	select {
+	case e.queue <- ev:
+	default:
+		e.logger.Warn("error report queue full; dropping event", zap.String("message", ev.Message))
+	}
+}
+
+// ReportTask reports a failed background task.
+func (e *ErrorReporter) ReportTask(source, message string, err error, context map[string]string) {
+	e.Report(ErrorEvent{Source: source, Message: message, Error: err.Error(), Context: context})
+}
+
+func (e *ErrorReporter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-e.queue:
+			if err := e.send(ctx, ev); err != nil {
+				e.logger.Warn("send error report", zap.Error(err))
+			}
+		}
+	}
+}
+
+type requestIDKey struct{}
+
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID honours a sane incoming X-Request-ID, otherwise generates one.
+func newRequestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" && len(id) <= 128 {
+		return id
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// errorCapture lets writeError attach the underlying error to the response
+// so 5xx reports carry more than a status code.
+type errorCapture struct {
+	http.ResponseWriter
+	status int
+	err    error
+	action string
+}
+
+func (c *errorCapture) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (s *Server) errorReporting(next http.Handler) http.Handler {
+	if s.errors == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := &errorCapture{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(c, r)
+		if c.status < 500 {
+			return
+		}
+		ev := ErrorEvent{
+			Source:    "http",
+			Message:   fmt.Sprintf("%s %s returned %d", r.Method, r.URL.Path, c.status),
+			RequestID: requestIDFrom(r.Context()),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    c.status,
+			Context:   map[string]string{"key": strings.TrimPrefix(r.URL.Path, "/")},
+		}
+		if c.err != nil {
+			ev.Error = c.err.Error()
+			ev.Context["action"] = c.action
+		}
+		s.errors.Report(ev)
+	})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestErrorReportingOn5xx(t *testing.T) {
+	received := make(chan ErrorEvent, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev ErrorEvent
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		received <- ev
+	}))
+	defer hook.Close()
+
+	logger := zaptest.NewLogger(t)
+	reporter := NewWebhookErrorReporter(hook.URL, logger)
+	go reporter.Run(t.Context())
+
+	store := &mockStore{getErr: errors.New("bucket unreachable")}
+	srv := NewWithOptions(store, logger, metrics.New(), Options{Errors: reporter})
+	req := httptest.NewRequest(http.MethodGet, "/releases/app.jar", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rr.Code)
+	}
+	if rr.Header().Get("X-Request-ID") != "req-123" {
+		t.Fatalf("expected request id to be echoed, got %q", rr.Header().Get("X-Request-ID"))
+	}
+
+	select {
+	case ev := <-received:
+		if ev.RequestID != "req-123" || ev.Status != 500 || ev.Error != "bucket unreachable" || ev.Context["key"] != "releases/app.jar" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no error report received")
+	}
+}
+
+func TestSentryDSNValidation(t *testing.T) {
+	if _, err := NewSentryErrorReporter("https://public@sentry.example.com/42", "", zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("valid dsn rejected: %v", err)
+	}
+	if _, err := NewSentryErrorReporter("https://sentry.example.com/42", "", zaptest.NewLogger(t)); err == nil {
+		t.Fatal("expected error for dsn without key")
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+const downloadStatsPrefix = proxyConfigPrefix + "stats/"
+
+// DownloadStats tracks when objects were last served. Hits are kept in memory
+// and merged into per-repository JSON files by the background jobs that need
+// them (cache eviction, lifecycle policies).
+type DownloadStats struct {
+	mu   sync.Mutex
+	hits map[string]time.Time
+
+	// persistMu serialises read-modify-write cycles on the stats files.
+	persistMu sync.Mutex
+}
+
+func NewDownloadStats() *DownloadStats {
+	return &DownloadStats{hits: make(map[string]time.Time)}
+}
+
+func (d *DownloadStats) Record(key string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.hits[strings.TrimPrefix(key, "/")] = time.Now()
+	d.mu.Unlock()
+}
+
+// lastHit returns the pending (not yet persisted) hit for key, if any.
+func (d *DownloadStats) lastHit(key string) time.Time {
+	if d == nil {
+		return time.Time{}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hits[key]
+}
+
+func (d *DownloadStats) drain() map[string]time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	hits := d.hits
+	d.hits = make(map[string]time.Time)
+	return hits
+}
+
+// persistDownloads merges the in-memory hits into the stats file of each
+// repository. Sidecar downloads count for their artifact. Callers hold
+// s.downloads.persistMu.
+func (s *Server) persistDownloads(ctx context.Context) error {
+	byRepo := map[string]map[string]time.Time{}
+	for key, at := range s.downloads.drain() {
+		repo, rel, ok := strings.Cut(key, "/")
+		if !ok || rel == "" || isInternalPath(key) {
+			continue
+		}
+		if isChecksumPath(rel) {
+			rel = strings.TrimSuffix(rel, path.Ext(rel))
+		}
+		if byRepo[repo] == nil {
+			byRepo[repo] = map[string]time.Time{}
+		}
+		if at.After(byRepo[repo][rel]) {
+			byRepo[repo][rel] = at
+		}
+	}
+
+	for repo, hits := range byRepo {
+		stats, err := s.proxy.loadDownloadStats(ctx, repo)
+		if err != nil {
+			return err
+		}
+		for rel, at := range hits {
+			if at.After(stats[rel]) {
+				stats[rel] = at
+			}
+		}
+		if err := s.proxy.saveDownloadStats(ctx, repo, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ProxyManager) loadDownloadStats(ctx context.Context, name string) (map[string]time.Time, error) {
+	resp, err := p.store.Get(ctx, downloadStatsPrefix+name+".json")
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return map[string]time.Time{}, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	stats := map[string]time.Time{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (p *ProxyManager) saveDownloadStats(ctx context.Context, name string, stats map[string]time.Time) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	return p.store.Put(ctx, downloadStatsPrefix+name+".json", strings.NewReader(string(data)), "application/json", int64(len(data)))
+}
+
+type cachedArtifact struct {
+	path       string
+	size       int64
+	lastAccess time.Time
+}
+
+// EvictCache deletes the least recently downloaded artifacts of a proxy until
+// its cache fits in MaxCacheBytes. Artifacts are grouped with their checksum
+// sidecars; ones never downloaded count from the first run that saw them.
+func (p *ProxyManager) EvictCache(ctx context.Context, proxy Proxy, hits map[string]time.Time) (int, int64, error) {
+	stats, err := p.loadDownloadStats(ctx, proxy.Name)
+	if err != nil {
+		return 0, 0, err
+	}
+	for key, at := range hits {
+		if rel, ok := strings.CutPrefix(key, proxy.Name+"/"); ok && at.After(stats[rel]) {
+			stats[rel] = at
+		}
+	}
+
+	now := time.Now()
+	artifacts := map[string]*cachedArtifact{}
+	var total int64
+	err = walkStore(ctx, p.store, proxy.Name, func(e storage.Entry) error {
+		rel := strings.TrimPrefix(e.Path, proxy.Name+"/")
+		base := rel
+		if isChecksumPath(rel) {
+			base = strings.TrimSuffix(rel, path.Ext(rel))
+		}
+		a, ok := artifacts[base]
+		if !ok {
+			a = &cachedArtifact{path: base}
+			artifacts[base] = a
+		}
+		a.size += e.Size
+		total += e.Size
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ordered := make([]*cachedArtifact, 0, len(artifacts))
+	seen := make(map[string]time.Time, len(artifacts))
+	for base, a := range artifacts {
+		at, ok := stats[base]
+		if !ok {
+			at = now
+		}
+		a.lastAccess = at
+		seen[base] = at
+		ordered = append(ordered, a)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].lastAccess.Equal(ordered[j].lastAccess) {
+			return ordered[i].path < ordered[j].path
+		}
+		return ordered[i].lastAccess.Before(ordered[j].lastAccess)
+	})
+
+	evicted := 0
+	var freed int64
+	for _, a := range ordered {
+		if total-freed <= proxy.MaxCacheBytes {
+			break
+		}
+		key := path.Join(proxy.Name, a.path)
+		if err := p.store.Delete(ctx, key); err != nil {
+			return evicted, freed, err
+		}
+		for _, ext := range storage.SidecarExtensions {
+			_ = p.store.Delete(ctx, key+ext)
+		}
+		delete(seen, a.path)
+		evicted++
+		freed += a.size
+	}
+
+	return evicted, freed, p.saveDownloadStats(ctx, proxy.Name, seen)
+}
+
+// evictCaches enforces the cache budget of every proxy that sets
+// maxCacheBytes.
+func (s *Server) evictCaches(ctx context.Context) error {
+	logger := s.logger
+	s.downloads.persistMu.Lock()
+	defer s.downloads.persistMu.Unlock()
+
+	if err := s.persistDownloads(ctx); err != nil {
+		return fmt.Errorf("persist download stats: %w", err)
+	}
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list proxies: %w", err)
+	}
+	var errs []error
+	for _, pr := range proxies {
+		if pr.MaxCacheBytes <= 0 {
+			continue
+		}
+		evicted, freed, err := s.proxy.EvictCache(ctx, pr, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("proxy %s: %w", pr.Name, err))
+			continue
+		}
+		if evicted > 0 {
+			logger.Info("cache evicted", zap.String("proxy", pr.Name), zap.Int("artifacts", evicted), zap.Int64("bytes", freed))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// maxExistsPaths bounds one batch; existsWorkers bounds concurrent HEADs.
+const (
+	maxExistsPaths = 1000
+	existsWorkers  = 16
+)
+
+type ExistsRequest struct {
+	Paths []string `json:"paths" example:"releases/com/acme/app/1.0/app-1.0.jar"`
+}
+
+type ExistsResult struct {
+	Path         string     `json:"path"`
+	Exists       bool       `json:"exists"`
+	Size         int64      `json:"size,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	SHA1         string     `json:"sha1,omitempty"`
+	MD5          string     `json:"md5,omitempty"`
+	// Error is set when the path could not be checked (invalid, forbidden
+	// for the token, storage failure); Exists is false then.
+	Error string `json:"error,omitempty"`
+}
+
+type ExistsResponse struct {
+	Results []ExistsResult `json:"results"`
+}
+
+// @Summary Batch existence check
+// @Description Checks up to 1000 artifact paths in one request and returns, in request order, whether each exists with its size, last-modified time and stored SHA-1/MD5 checksums. Deploy tokens need read scope for each path.
+// @Tags artifacts
+// @Accept json
+// @Produce json
+// @Param request body ExistsRequest true "Artifact paths"
+// @Success 200 {object} ExistsResponse
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/exists [post]
+func (s *Server) handleExists(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ExistsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if len(req.Paths) == 0 || len(req.Paths) > maxExistsPaths {
+		writeAPIError(w, "paths must contain between 1 and 1000 entries", http.StatusBadRequest)
+		return
+	}
+
+	tok := principalFrom(r.Context()).token
+	results := make([]ExistsResult, len(req.Paths))
+	sem := make(chan struct{}, existsWorkers)
+	var wg sync.WaitGroup
+	for i, p := range req.Paths {
+		key := strings.Trim(strings.TrimSpace(p), "/")
+		results[i].Path = key
+		switch {
+		case key == "" || isInternalPath(key):
+			results[i].Error = "invalid path"
+			continue
+		case tok != nil && !tok.Allows("read", key):
+			results[i].Error = "token not permitted for this path"
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(res *ExistsResult) {
+			defer func() { <-sem; wg.Done() }()
+			s.checkExists(r.Context(), res)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ExistsResponse{Results: results}); err != nil {
+		s.logger.Warn("encode exists response", zap.Error(err))
+	}
+}
+
+// @Summary Check that an artifact exists
+// @Description Answers 200 when the artifact is stored and 404 when it is not, both without a body, so monitoring probes and pipelines can check availability without downloading anything. HEAD answers the same. Last-Modified is set when it exists. Deploy tokens need read scope for the path.
+// @Tags artifacts
+// @Param artifactPath path string true "Artifact path"
+// @Success 200 "Exists"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 "Not found"
+// @Security BasicAuth
+// @Router /api/v1/exists/{artifactPath} [get]
+// @Router /api/v1/exists/{artifactPath} [head]
+func (s *Server) handleExistsPath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, apiV1+"/exists/"), "/")
+	if key == "" || strings.Contains(key, "..") || isInternalPath(key) {
+		writeAPIError(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("read", key) {
+		writeAPIError(w, "token not permitted for "+key, http.StatusForbidden)
+		return
+	}
+	head, err := s.store.Head(r.Context(), key)
+	if storage.IsNotFound(err) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.writeError(w, "exists head", err)
+		return
+	}
+	if head.LastModified != nil {
+		w.Header().Set("Last-Modified", head.LastModified.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) checkExists(ctx context.Context, res *ExistsResult) {
+	head, err := s.store.Head(ctx, res.Path)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			s.logger.Warn("exists head", zap.String("key", res.Path), zap.Error(err))
+			res.Error = "storage error"
+		}
+		return
+	}
+	res.Exists = true
+	res.LastModified = head.LastModified
+	if head.ContentLength != nil {
+		res.Size = *head.ContentLength
+	}
+	if isChecksumPath(res.Path) {
+		return
+	}
+	res.SHA1 = s.readChecksumSidecar(ctx, res.Path+".sha1")
+	res.MD5 = s.readChecksumSidecar(ctx, res.Path+".md5")
+}
+
+// readChecksumSidecar returns the hex digest stored in a .sha1/.md5 file,
+// tolerating the "digest  filename" form, or "" when it is missing.
+func (s *Server) readChecksumSidecar(ctx context.Context, key string) string {
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// storedSHA1 hashes the object at key, returning "" when it cannot be read.
+func (s *Server) storedSHA1(ctx context.Context, key string) string {
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBatchExists(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	for key, body := range map[string]string{
+		"releases/com/acme/app/1.0/app-1.0.jar":      "jar-bytes",
+		"releases/com/acme/app/1.0/app-1.0.jar.sha1": "ABCDEF0123456789abcdef0123456789abcdef01  app-1.0.jar\n",
+		"releases/com/acme/app/1.0/app-1.0.pom":      "<project/>",
+		"snapshots/com/acme/app/1.1-SNAPSHOT/x.jar":  "x",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	tok, err := srv.tokens.Create(ctx, CreateTokenRequest{Prefix: "releases/**", Verbs: []string{"read"}})
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+
+	check := func(user, pass, body string) (int, ExistsResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/exists", strings.NewReader(body))
+		req.SetBasicAuth(user, pass)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		var resp ExistsResponse
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rr.Code, resp
+	}
+
+	code, resp := check("admin", "secret", `{"paths":["/releases/com/acme/app/1.0/app-1.0.jar","releases/com/acme/app/1.0/app-1.0.pom","releases/missing.jar","__tokens__/x"]}`)
+	if code != http.StatusOK || len(resp.Results) != 4 {
+		t.Fatalf("status %d, results %+v", code, resp.Results)
+	}
+	jar := resp.Results[0]
+	if jar.Path != "releases/com/acme/app/1.0/app-1.0.jar" || !jar.Exists || jar.Size != 9 || jar.SHA1 != "abcdef0123456789abcdef0123456789abcdef01" || jar.MD5 != "" {
+		t.Fatalf("unexpected jar result %+v", jar)
+	}
+	if pom := resp.Results[1]; !pom.Exists || pom.SHA1 != "" {
+		t.Fatalf("unexpected pom result %+v", pom)
+	}
+	if missing := resp.Results[2]; missing.Exists || missing.Error != "" {
+		t.Fatalf("unexpected missing result %+v", missing)
+	}
+	if internal := resp.Results[3]; internal.Exists || internal.Error != "invalid path" {
+		t.Fatalf("unexpected internal result %+v", internal)
+	}
+
+	_, resp = check(tok.ID, tok.Secret, `{"paths":["releases/com/acme/app/1.0/app-1.0.pom","snapshots/com/acme/app/1.1-SNAPSHOT/x.jar"]}`)
+	if len(resp.Results) != 2 || !resp.Results[0].Exists || resp.Results[1].Exists || resp.Results[1].Error == "" {
+		t.Fatalf("unexpected token results %+v", resp.Results)
+	}
+
+	if code, _ := check("admin", "secret", `{"paths":[]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty batch, got %d", code)
+	}
+}
+
+func TestExistsPath(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	for _, key := range []string{"releases/com/acme/app/1.0/app-1.0.jar", "snapshots/com/acme/app/1.1-SNAPSHOT/x.jar"} {
+		if err := store.Put(ctx, key, strings.NewReader("jar-bytes"), "application/java-archive", 9); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	tok, err := srv.tokens.Create(ctx, CreateTokenRequest{Prefix: "releases/**", Verbs: []string{"read"}})
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	probe := func(method, user, pass, p string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/exists/"+p, nil)
+		req.SetBasicAuth(user, pass)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		rr := probe(method, "admin", "secret", "releases/com/acme/app/1.0/app-1.0.jar")
+		if rr.Code != http.StatusOK || rr.Body.Len() != 0 || rr.Header().Get("Last-Modified") == "" {
+			t.Fatalf("%s existing: %d %q %v", method, rr.Code, rr.Body.String(), rr.Header())
+		}
+		if rr := probe(method, "admin", "secret", "releases/com/acme/app/1.0/missing.jar"); rr.Code != http.StatusNotFound || rr.Body.Len() != 0 {
+			t.Fatalf("%s missing: %d %q", method, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := probe(http.MethodGet, "admin", "secret", "__tokens__/x"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("internal path: %d", rr.Code)
+	}
+	if rr := probe(http.MethodGet, tok.ID, tok.Secret, "releases/com/acme/app/1.0/app-1.0.jar"); rr.Code != http.StatusOK {
+		t.Fatalf("token in scope: %d", rr.Code)
+	}
+	if rr := probe(http.MethodGet, tok.ID, tok.Secret, "snapshots/com/acme/app/1.1-SNAPSHOT/x.jar"); rr.Code != http.StatusForbidden {
+		t.Fatalf("token out of scope: %d", rr.Code)
+	}
+	if rr := probe(http.MethodDelete, "admin", "secret", "releases/com/acme/app/1.0/app-1.0.jar"); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("delete: %d", rr.Code)
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// feedTTL is how long a walked feed is served before the bucket is
+	// walked again; feed readers poll far less often.
+	feedTTL = 5 * time.Minute
+	// feedLimit bounds the entries of a feed and feedMaxLimit what ?limit=
+	// may ask for.
+	feedLimit    = 50
+	feedMaxLimit = 500
+)
+
+// feedRelease is a published version, found by its POM.
+type feedRelease struct {
+	repo, group, artifact, version string
+	key                            string
+	published                      time.Time
+}
+
+// feedCache holds the releases found under a prefix, newest first.
+type feedCache struct {
+	mu      sync.Mutex
+	entries map[string]feedCacheEntry
+}
+
+type feedCacheEntry struct {
+	loaded   time.Time
+	releases []feedRelease
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID       string         `xml:"id"`
+	Title    string         `xml:"title"`
+	Updated  string         `xml:"updated"`
+	Link     atomLink       `xml:"link"`
+	Category []atomCategory `xml:"category"`
+	Summary  string         `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// releases returns the versions published under prefix, newest first,
+// walking the hosted repositories at most once per feedTTL.
+func (s *Server) releases(ctx context.Context, prefix string) ([]feedRelease, error) {
+	s.feeds.mu.Lock()
+	cached, ok := s.feeds.entries[prefix]
+	s.feeds.mu.Unlock()
+	if ok && time.Since(cached.loaded) < feedTTL {
+		return cached.releases, nil
+	}
+
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	proxied := make(map[string]bool, len(proxies))
+	for _, pr := range proxies {
+		proxied[pr.Name] = true
+	}
+	var releases []feedRelease
+	err = walkStore(ctx, s.store, prefix, func(e storage.Entry) error {
+		if !strings.HasSuffix(e.Path, ".pom") || e.LastModified == nil || isInternalPath(e.Path) {
+			return nil
+		}
+		repo, rest, ok := strings.Cut(e.Path, "/")
+		if !ok || proxied[repo] {
+			return nil
+		}
+		// Snapshot POMs carry a timestamp instead of the version; the
+		// newest one per version is kept below.
+		group, artifact, version, ok := parseCoordinates(rest)
+		if !ok || (!strings.HasSuffix(version, "-SNAPSHOT") && path.Base(e.Path) != artifact+"-"+version+".pom") {
+			return nil
+		}
+		releases = append(releases, feedRelease{
+			repo: repo, group: group, artifact: artifact, version: version,
+			key: e.Path, published: *e.LastModified,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(releases, func(a, b feedRelease) int { return b.published.Compare(a.published) })
+	seen := map[string]bool{}
+	releases = slices.DeleteFunc(releases, func(r feedRelease) bool {
+		id := r.repo + ":" + r.group + ":" + r.artifact + ":" + r.version
+		if seen[id] {
+			return true
+		}
+		seen[id] = true
+		return false
+	})
+	if len(releases) > feedMaxLimit {
+		releases = releases[:feedMaxLimit]
+	}
+
+	s.feeds.mu.Lock()
+	if s.feeds.entries == nil {
+		s.feeds.entries = map[string]feedCacheEntry{}
+	}
+	s.feeds.entries[prefix] = feedCacheEntry{loaded: time.Now(), releases: releases}
+	s.feeds.mu.Unlock()
+	return releases, nil
+}
+
+// @Summary Atom feed of published versions
+// @Description Lists the versions most recently published to hosted repositories under path, newest first, one entry per POM with its coordinates and upload time. SNAPSHOT versions are left out unless snapshots=true. The bucket is walked at most every 5 minutes per path. Versions a deploy token cannot read are left out.
+// @Tags catalog
+// @Produce xml
+// @Param path query string false "Path prefix, e.g. releases/com/acme"
+// @Param limit query int false "Max entries (1-500)" default(50)
+// @Param snapshots query bool false "Include SNAPSHOT versions"
+// @Success 200 {string} string "Atom feed"
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/feed.atom [get]
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	prefix := strings.Trim(q.Get("path"), "/")
+	if isInternalPath(prefix) || strings.Contains(prefix, "..") {
+		writeAPIError(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	limit := feedLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > feedMaxLimit {
+			writeAPIError(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	snapshots := q.Get("snapshots") == "true"
+
+	releases, err := s.releases(r.Context(), prefix)
+	if err != nil {
+		s.logger.Error("build feed", zap.String("path", prefix), zap.Error(err))
+		writeAPIError(w, "list releases failed", http.StatusInternalServerError)
+		return
+	}
+
+	base := requestBaseURL(r)
+	title := "Heimdall releases"
+	if prefix != "" {
+		title += " in " + prefix
+	}
+	self := base + apiV1 + "/feed.atom"
+	if prefix != "" {
+		self += "?path=" + url.QueryEscape(prefix)
+	}
+	feed := atomFeed{
+		ID:      self,
+		Title:   title,
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: "Heimdall"},
+		Links:   []atomLink{{Href: self, Rel: "self", Type: "application/atom+xml"}},
+		Entries: []atomEntry{},
+	}
+	tok := principalFrom(r.Context()).token
+	for _, rel := range releases {
+		if len(feed.Entries) == limit {
+			break
+		}
+		if (!snapshots && strings.HasSuffix(rel.version, "-SNAPSHOT")) || (tok != nil && !tok.Allows("read", rel.key)) {
+			continue
+		}
+		gav := rel.group + ":" + rel.artifact + ":" + rel.version
+		published := rel.published.UTC().Format(time.RFC3339)
+		if len(feed.Entries) == 0 {
+			feed.Updated = published
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:       base + "/" + rel.key,
+			Title:    gav,
+			Updated:  published,
+			Link:     atomLink{Href: base + "/" + path.Dir(rel.key) + "/"},
+			Category: []atomCategory{{Term: rel.repo}},
+			Summary:  gav + " published to " + rel.repo + " at " + published,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		s.logger.Warn("encode feed", zap.Error(err))
+	}
+}
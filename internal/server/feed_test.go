@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFeed(t *testing.T) {
+	store := newMemStore()
+	now := time.Now()
+	for key, age := range map[string]time.Duration{
+		"releases/com/acme/lib/1.0/lib-1.0.pom":                              3 * time.Hour,
+		"releases/com/acme/lib/1.0/lib-1.0.jar":                              3 * time.Hour,
+		"releases/com/acme/lib/1.1/lib-1.1.pom":                              time.Hour,
+		"releases/org/other/tool/2.0/tool-2.0.pom":                           2 * time.Hour,
+		"snapshots/com/acme/lib/1.2-SNAPSHOT/lib-1.2-20260101.101010-1.pom":  30 * time.Minute,
+		"snapshots/com/acme/lib/1.2-SNAPSHOT/lib-1.2-20260101.111111-2.pom":  10 * time.Minute,
+		"central/org/apache/commons/commons-lang3/3.0/commons-lang3-3.0.pom": time.Minute,
+		"releases/com/acme/lib/maven-metadata.xml":                           time.Minute,
+		"releases/com/acme/lib/1.1/lib-1.1.pom.sha1":                         time.Minute,
+		"__properties__/releases/com/acme/lib/1.1/lib-1.1.pom.json":          time.Minute,
+		"releases/com/acme/lib/1.1/lib-1.1-cyclonedx.json":                   time.Minute,
+		"releases/com/acme/lib/1.1/lib-1.1-tests.pom":                        time.Minute,
+	} {
+		store.data[key] = memObj{body: []byte("x"), modified: now.Add(-age)}
+	}
+	store.data["__proxycfg__/central.json"] = memObj{body: []byte(`{"name":"central","url":"https://repo.maven.apache.org/maven2"}`), modified: now}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+
+	get := func(query string) atomFeed {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/feed.atom"+query, nil))
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/atom+xml; charset=utf-8" {
+			t.Fatalf("GET %s: %d %s", query, rr.Code, rr.Body.String())
+		}
+		var feed atomFeed
+		if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+			t.Fatal(err)
+		}
+		return feed
+	}
+	titles := func(feed atomFeed) []string {
+		var out []string
+		for _, e := range feed.Entries {
+			out = append(out, e.Title)
+		}
+		return out
+	}
+
+	feed := get("")
+	want := []string{"com.acme:lib:1.1", "org.other:tool:2.0", "com.acme:lib:1.0"}
+	if got := titles(feed); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("entries = %v, want %v", got, want)
+	}
+	if feed.Entries[0].Link.Href != "http://example.com/releases/com/acme/lib/1.1/" || feed.Updated != feed.Entries[0].Updated {
+		t.Fatalf("first entry = %+v, feed updated %s", feed.Entries[0], feed.Updated)
+	}
+
+	if got := titles(get("?path=releases/com/acme&limit=1")); len(got) != 1 || got[0] != "com.acme:lib:1.1" {
+		t.Fatalf("prefix and limit: %v", got)
+	}
+	if got := titles(get("?path=snapshots&snapshots=true")); len(got) != 1 || got[0] != "com.acme:lib:1.2-SNAPSHOT" {
+		t.Fatalf("snapshots: %v", got)
+	}
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/feed.atom?limit=0", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0: expected 400, got %d", rr.Code)
+	}
+}
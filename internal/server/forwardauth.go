@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+var defaultForwardAuthHeaders = []string{"X-Forwarded-User", "X-Auth-Request-User", "X-Auth-Request-Email"}
+
+// forwardAuthGroupHeaders carry the comma-separated groups of the forwarded
+// user, as set by oauth2-proxy and Traefik forward-auth.
+var forwardAuthGroupHeaders = []string{"X-Forwarded-Groups", "X-Auth-Request-Groups"}
+
+// ForwardAuth trusts identity headers set by an authenticating reverse proxy
+// (oauth2-proxy, Traefik forward-auth) when the request comes from one of the
+// trusted proxy addresses. Forwarded users are not admins unless GrantAdmin
+// names them or their group.
+type ForwardAuth struct {
+	trusted    []netip.Prefix
+	headers    []string
+	admins     map[string]bool
+	adminGroup string
+}
+
+// NewForwardAuth parses a comma-separated list of IPs/CIDRs. headers are
+// checked in order; nil uses X-Forwarded-User, X-Auth-Request-User and
+// X-Auth-Request-Email.
+func NewForwardAuth(trustedProxies string, headers []string) (*ForwardAuth, error) {
+	fa := &ForwardAuth{headers: headers}
+	if len(fa.headers) == 0 {
+		fa.headers = defaultForwardAuthHeaders
+	}
+	for _, raw := range strings.Split(trustedProxies, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "/") {
+			addr, err := netip.ParseAddr(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", raw, err)
+			}
+			fa.trusted = append(fa.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", raw, err)
+		}
+		fa.trusted = append(fa.trusted, prefix.Masked())
+	}
+	if len(fa.trusted) == 0 {
+		return nil, fmt.Errorf("at least one trusted proxy is required")
+	}
+	return fa, nil
+}
+
+// GrantAdmin makes the forwarded users in users, and the members of group
+// when it is set, admins.
+func (f *ForwardAuth) GrantAdmin(users []string, group string) {
+	f.admins = make(map[string]bool, len(users))
+	for _, u := range users {
+		f.admins[u] = true
+	}
+	f.adminGroup = group
+}
+
+// User returns the forwarded identity, or "" when the request did not come
+// from a trusted proxy or carries no identity header.
+func (f *ForwardAuth) User(r *http.Request) string {
+	if f == nil || !f.trustedPeer(r.RemoteAddr) {
+		return ""
+	}
+	for _, h := range f.headers {
+		if v := strings.TrimSpace(r.Header.Get(h)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// IsAdmin reports whether the forwarded user of r was granted admin rights.
+// Only call it for requests User accepted.
+func (f *ForwardAuth) IsAdmin(r *http.Request, user string) bool {
+	if f.admins[user] {
+		return true
+	}
+	if f.adminGroup == "" {
+		return false
+	}
+	for _, h := range forwardAuthGroupHeaders {
+		for _, g := range strings.Split(r.Header.Get(h), ",") {
+			if strings.TrimSpace(g) == f.adminGroup {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *ForwardAuth) trustedPeer(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range f.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
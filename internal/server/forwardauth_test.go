@@ -0,0 +1,88 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestForwardAuthTrustsConfiguredProxies(t *testing.T) {
+	fa, err := NewForwardAuth("10.0.0.0/8, 192.168.1.5", nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	store := &mockStore{getResp: &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("jar"))}}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{AuthUser: "admin", AuthPassword: "secret", ForwardAuth: fa})
+	h := srv.Handler()
+
+	cases := []struct {
+		remote string
+		header string
+		want   int
+	}{
+		{"10.1.2.3:5555", "X-Forwarded-User", http.StatusOK},
+		{"192.168.1.5:5555", "X-Auth-Request-Email", http.StatusOK},
+		{"10.1.2.3:5555", "", http.StatusUnauthorized},
+		{"203.0.113.7:5555", "X-Forwarded-User", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/releases/app.jar", nil)
+		req.RemoteAddr = tc.remote
+		if tc.header != "" {
+			req.Header.Set(tc.header, "alice@example.com")
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s via %s: expected %d, got %d", tc.header, tc.remote, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestNewForwardAuthRejectsInvalidProxy(t *testing.T) {
+	if _, err := NewForwardAuth("not-an-ip", nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := NewForwardAuth("", nil); err == nil {
+		t.Fatal("expected error for empty list")
+	}
+}
+
+func TestForwardAuthAdminsAreExplicit(t *testing.T) {
+	fa, err := NewForwardAuth("10.0.0.0/8", nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	fa.GrantAdmin([]string{"root@example.com"}, "heimdall-admins")
+	srv := NewWithOptions(&mockStore{}, zaptest.NewLogger(t), metrics.New(), Options{AuthUser: "admin", AuthPassword: "secret", ForwardAuth: fa})
+	h := srv.Handler()
+
+	cases := []struct {
+		user, groups string
+		forbidden    bool
+	}{
+		{"alice@example.com", "", true},
+		{"alice@example.com", "developers, qa", true},
+		{"root@example.com", "", false},
+		{"bob@example.com", "developers,heimdall-admins", false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tokens", nil)
+		req.RemoteAddr = "10.1.2.3:5555"
+		req.Header.Set("X-Forwarded-User", tc.user)
+		if tc.groups != "" {
+			req.Header.Set("X-Forwarded-Groups", tc.groups)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if forbidden := rr.Code == http.StatusForbidden; forbidden != tc.forbidden {
+			t.Errorf("%s (%q): got %d", tc.user, tc.groups, rr.Code)
+		}
+	}
+}
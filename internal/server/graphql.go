@@ -0,0 +1,532 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A deliberately small GraphQL engine for the read-only query endpoint:
+// a single query operation with fields, aliases, arguments and variables.
+// Fragments, directives, mutations and introspection are not supported;
+// the schema is published as SDL instead.
+
+// gqlObject is an object type of the schema.
+type gqlObject interface {
+	gqlType() string
+	// gqlField resolves one field. Lists are returned as []any.
+	gqlField(ctx context.Context, name string, args map[string]any) (any, error)
+}
+
+type gqlField struct {
+	alias string
+	name  string
+	args  map[string]any // literals, or gqlVariable
+	sel   []gqlField
+}
+
+func (f gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type gqlVariable string
+
+type gqlQuery struct {
+	sel      []gqlField
+	defaults map[string]any
+}
+
+// gqlResult keeps response keys in selection order, as the spec requires.
+type gqlResult struct {
+	keys []string
+	vals []any
+}
+
+func (m *gqlResult) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		kb, _ := json.Marshal(k)
+		b.Write(kb)
+		b.WriteByte(':')
+		vb, err := json.Marshal(m.vals[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(vb)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type gqlError struct {
+	Message string `json:"message"`
+}
+
+// executeGraphQL parses and runs query against root.
+func executeGraphQL(ctx context.Context, root gqlObject, query string, vars map[string]any) (*gqlResult, []gqlError) {
+	q, err := parseGraphQL(query)
+	if err != nil {
+		return nil, []gqlError{{Message: err.Error()}}
+	}
+	merged := make(map[string]any, len(q.defaults)+len(vars))
+	for k, v := range q.defaults {
+		merged[k] = v
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	ex := &gqlExecutor{vars: merged}
+	data := ex.object(ctx, root, q.sel, "")
+	return data, ex.errors
+}
+
+type gqlExecutor struct {
+	vars   map[string]any
+	errors []gqlError
+}
+
+func (ex *gqlExecutor) fail(path, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	ex.errors = append(ex.errors, gqlError{Message: msg})
+}
+
+func (ex *gqlExecutor) object(ctx context.Context, obj gqlObject, sel []gqlField, path string) *gqlResult {
+	out := &gqlResult{}
+	for _, f := range sel {
+		fieldPath := strings.TrimPrefix(path+"."+f.key(), ".")
+		var v any
+		if f.name == "__typename" {
+			v = obj.gqlType()
+		} else {
+			args := make(map[string]any, len(f.args))
+			for k, a := range f.args {
+				if name, ok := a.(gqlVariable); ok {
+					a = ex.vars[string(name)]
+				}
+				if a != nil {
+					args[k] = a
+				}
+			}
+			resolved, err := obj.gqlField(ctx, f.name, args)
+			if err != nil {
+				ex.fail(fieldPath, "%v", err)
+			} else {
+				v = ex.complete(ctx, resolved, f, fieldPath)
+			}
+		}
+		out.keys = append(out.keys, f.key())
+		out.vals = append(out.vals, v)
+	}
+	return out
+}
+
+func (ex *gqlExecutor) complete(ctx context.Context, v any, f gqlField, path string) any {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case gqlObject:
+		if len(f.sel) == 0 {
+			ex.fail(path, "field %q of type %s must have a selection of subfields", f.name, val.gqlType())
+			return nil
+		}
+		return ex.object(ctx, val, f.sel, path)
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = ex.complete(ctx, item, f, path+"."+strconv.Itoa(i))
+		}
+		return out
+	default:
+		if len(f.sel) > 0 {
+			ex.fail(path, "field %q is a scalar and cannot have a selection", f.name)
+			return nil
+		}
+		return val
+	}
+}
+
+func gqlUnknownField(obj gqlObject, name string) error {
+	return fmt.Errorf("unknown field %q on type %s", name, obj.gqlType())
+}
+
+func gqlString(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+func gqlInt(args map[string]any, name string, def int) int {
+	switch n := args[name].(type) {
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return def
+}
+
+// --- parser ---
+
+type gqlToken struct {
+	kind byte // 'n' name, 's' string, 'i' int, 'f' float, 'p' punctuator, 0 EOF
+	val  string
+}
+
+// gqlMaxDepth bounds the nesting of selection sets, list values and list
+// types, so a crafted query cannot exhaust the stack.
+const gqlMaxDepth = 32
+
+type gqlParser struct {
+	src   string
+	pos   int
+	tok   gqlToken
+	depth int
+}
+
+// enter records one more nesting level; callers defer p.leave().
+func (p *gqlParser) enter() error {
+	p.depth++
+	if p.depth > gqlMaxDepth {
+		return fmt.Errorf("query nested deeper than %d levels at offset %d", gqlMaxDepth, p.pos)
+	}
+	return nil
+}
+
+func (p *gqlParser) leave() { p.depth-- }
+
+func parseGraphQL(src string) (*gqlQuery, error) {
+	p := &gqlParser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	q := &gqlQuery{defaults: map[string]any{}}
+	if p.tok.kind == 'n' {
+		switch p.tok.val {
+		case "query":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind == 'n' {
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+			}
+			if p.is("(") {
+				if err := p.variableDefinitions(q.defaults); err != nil {
+					return nil, err
+				}
+			}
+		case "mutation", "subscription":
+			return nil, fmt.Errorf("only queries are supported")
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			return nil, fmt.Errorf("unexpected %q", p.tok.val)
+		}
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != 0 {
+		return nil, fmt.Errorf("only one operation per document is supported")
+	}
+	q.sel = sel
+	return q, nil
+}
+
+func (p *gqlParser) is(punct string) bool {
+	return p.tok.kind == 'p' && p.tok.val == punct
+}
+
+func (p *gqlParser) expect(punct string) error {
+	if !p.is(punct) {
+		return fmt.Errorf("expected %q at offset %d", punct, p.pos)
+	}
+	return p.next()
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.tok.kind != 'n' {
+		return "", fmt.Errorf("expected name at offset %d", p.pos)
+	}
+	n := p.tok.val
+	return n, p.next()
+}
+
+func (p *gqlParser) variableDefinitions(defaults map[string]any) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for !p.is(")") {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if p.is("=") {
+			if err := p.next(); err != nil {
+				return err
+			}
+			v, err := p.value(true)
+			if err != nil {
+				return err
+			}
+			defaults[name] = v
+		}
+	}
+	return p.next()
+}
+
+func (p *gqlParser) skipType() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer p.leave()
+	if p.is("[") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.is("!") {
+		return p.next()
+	}
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlField, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []gqlField
+	for !p.is("}") {
+		if p.is("...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		if p.is("@") {
+			return nil, fmt.Errorf("directives are not supported")
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		f := gqlField{name: name}
+		if p.is(":") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			f.alias = name
+			if f.name, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if p.is("(") {
+			if f.args, err = p.arguments(); err != nil {
+				return nil, err
+			}
+		}
+		if p.is("@") {
+			return nil, fmt.Errorf("directives are not supported")
+		}
+		if p.is("{") {
+			if f.sel, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, p.next()
+}
+
+func (p *gqlParser) arguments() (map[string]any, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := map[string]any{}
+	for !p.is(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(false); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.next()
+}
+
+func (p *gqlParser) value(constant bool) (any, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == 'p' && tok.val == "$" && !constant:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return gqlVariable(name), err
+	case tok.kind == 's':
+		return tok.val, p.next()
+	case tok.kind == 'i':
+		n, err := strconv.ParseInt(tok.val, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.next()
+	case tok.kind == 'f':
+		f, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return nil, err
+		}
+		return f, p.next()
+	case tok.kind == 'n':
+		var v any
+		switch tok.val {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = tok.val // enum value
+		}
+		return v, p.next()
+	case tok.kind == 'p' && tok.val == "[":
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := []any{}
+		for !p.is("]") {
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.next()
+	}
+	return nil, fmt.Errorf("unexpected value at offset %d", p.pos)
+}
+
+func (p *gqlParser) next() error {
+	// skip ignored tokens: whitespace, commas, comments, BOM
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if strings.HasPrefix(p.src[p.pos:], "\uFEFF") {
+			p.pos += len("\uFEFF")
+			continue
+		}
+		break
+	}
+	if p.pos >= len(p.src) {
+		p.tok = gqlToken{}
+		return nil
+	}
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = gqlToken{'p', "..."}
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = gqlToken{'p', string(c)}
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && isGQLNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = gqlToken{'n', p.src[start:p.pos]}
+	case c == '-' || c >= '0' && c <= '9':
+		p.pos++
+		kind := byte('i')
+		for p.pos < len(p.src) {
+			d := p.src[p.pos]
+			if d == '.' || d == 'e' || d == 'E' || d == '+' || d == '-' {
+				kind = 'f'
+			} else if d < '0' || d > '9' {
+				break
+			}
+			p.pos++
+		}
+		p.tok = gqlToken{kind, p.src[start:p.pos]}
+	case c == '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			return fmt.Errorf("block strings are not supported")
+		}
+		end := p.pos + 1
+		for end < len(p.src) && p.src[end] != '"' {
+			if p.src[end] == '\\' {
+				end++
+			}
+			if end < len(p.src) && p.src[end] == '\n' {
+				break
+			}
+			end++
+		}
+		if end >= len(p.src) || p.src[end] != '"' {
+			return fmt.Errorf("unterminated string at offset %d", start)
+		}
+		s, err := strconv.Unquote(p.src[start : end+1])
+		if err != nil {
+			return fmt.Errorf("invalid string at offset %d", start)
+		}
+		p.pos = end + 1
+		p.tok = gqlToken{'s', s}
+	default:
+		return fmt.Errorf("unexpected character %q at offset %d", c, start)
+	}
+	return nil
+}
+
+func isGQLNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
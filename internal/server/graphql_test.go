@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	q, err := parseGraphQL(`query Q($repo: String! = "releases", $n: Int) {
+		latest: artifacts(repository: $repo, first: 2) { groupId __typename }
+		repositories { name }
+	}`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(q.sel) != 2 || q.sel[0].key() != "latest" || q.sel[0].name != "artifacts" || len(q.sel[0].sel) != 2 {
+		t.Fatalf("unexpected selection %+v", q.sel)
+	}
+	if q.sel[0].args["repository"] != gqlVariable("repo") || q.defaults["repo"] != "releases" {
+		t.Fatalf("unexpected args %+v defaults %+v", q.sel[0].args, q.defaults)
+	}
+
+	for _, bad := range []string{
+		`mutation { deleteAll }`,
+		`{ a { ...frag } }`,
+		`{ a @skip(if: true) }`,
+		`{ a } { b }`,
+		`{ a(x: ) }`,
+		`{ a`,
+		strings.Repeat("{ a ", 40) + strings.Repeat("}", 40),
+		`{ a(x: ` + strings.Repeat("[", 40) + strings.Repeat("]", 40) + `) }`,
+		`query($v: ` + strings.Repeat("[", 40) + "Int" + strings.Repeat("]", 40) + `) { a }`,
+	} {
+		if _, err := parseGraphQL(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestGraphQLEndpoint(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	for key, body := range map[string]string{
+		"releases/com/acme/app/1.0/app-1.0.jar":                         "jar-1.0",
+		"releases/com/acme/app/1.0/app-1.0.jar.sha1":                    "0123456789012345678901234567890123456789",
+		"releases/com/acme/app/1.10/app-1.10.jar":                       "jar-1.10!",
+		"releases/com/acme/lib/2.0/lib-2.0.jar":                         "lib",
+		propertiesPrefix + "releases/com/acme/app/1.0/app-1.0.jar.json": `{"build":"42"}`,
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	srv.downloads.Record("releases/com/acme/app/1.0/app-1.0.jar")
+
+	query := `query($g: String) {
+		repositories { name type }
+		artifacts(repository: "releases", groupId: $g, artifactId: "app") {
+			artifactId latest
+			versions(first: 1) { version size files { name lastDownloaded properties { key value } } }
+		}
+		missing: file(path: "releases/nope.jar") { size }
+	}`
+	body, _ := json.Marshal(GraphQLRequest{Query: query, Variables: map[string]any{"g": "com.acme"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(string(body)))
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp struct {
+		Data struct {
+			Repositories []repositoryInfo `json:"repositories"`
+			Artifacts    []struct {
+				ArtifactID string `json:"artifactId"`
+				Latest     string `json:"latest"`
+				Versions   []struct {
+					Version string `json:"version"`
+					Size    int64  `json:"size"`
+					Files   []struct {
+						Name           string  `json:"name"`
+						LastDownloaded *string `json:"lastDownloaded"`
+						Properties     []struct{ Key, Value string }
+					} `json:"files"`
+				} `json:"versions"`
+			} `json:"artifacts"`
+			Missing *struct{} `json:"missing"`
+		} `json:"data"`
+		Errors []gqlError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Errors) != 0 {
+		t.Fatalf("unexpected errors %+v", resp.Errors)
+	}
+	if len(resp.Data.Repositories) != 1 || resp.Data.Repositories[0].Name != "releases" {
+		t.Fatalf("unexpected repositories %+v", resp.Data.Repositories)
+	}
+	arts := resp.Data.Artifacts
+	if len(arts) != 1 || arts[0].Latest != "1.10" || len(arts[0].Versions) != 1 || arts[0].Versions[0].Version != "1.10" || arts[0].Versions[0].Size != 9 {
+		t.Fatalf("unexpected artifacts %+v", arts)
+	}
+	if resp.Data.Missing != nil {
+		t.Fatalf("expected null for missing file")
+	}
+
+	// Variables on the query string; the hit recorded above is not persisted
+	// yet but must still show up.
+	vars, _ := json.Marshal(map[string]any{"v": "releases/com/acme/app/1.0/app-1.0.jar"})
+	get := "/api/v1/graphql?query=" + url.QueryEscape(`query($v: String!) { file(path: $v) { size lastDownloaded properties { key value } } }`) + "&variables=" + url.QueryEscape(string(vars))
+	req = httptest.NewRequest(http.MethodGet, get, nil)
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `"size":7`) || !strings.Contains(rr.Body.String(), `{"key":"build","value":"42"}`) || strings.Contains(rr.Body.String(), `"lastDownloaded":null`) {
+		t.Fatalf("unexpected file response %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/graphql?query="+url.QueryEscape(`{ repositories { bogus } }`), nil)
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `unknown field \"bogus\" on type Repository`) {
+		t.Fatalf("expected field error, got %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/graphql?sdl", nil)
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "type Artifact {") {
+		t.Fatalf("expected schema, got %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(`{"query":"`+strings.Repeat(" ", graphQLMaxBody)+`{ repositories { name } }"}`))
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for an oversized body, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/graphql?query=%7Brepositories%7Bname%7D%7D", nil)
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", rr.Code)
+	}
+}
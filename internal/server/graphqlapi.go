@@ -0,0 +1,444 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// graphQLMaxBody bounds POSTed query documents.
+const graphQLMaxBody = 1 << 20
+
+// graphQLSchema documents what /api/v1/graphql resolves. Keep it in sync
+// with the gql* resolvers below.
+const graphQLSchema = `type Query {
+  repositories: [Repository!]!
+  repository(name: String!): Repository
+  "Artifacts of a repository, optionally narrowed to a group or artifact. first defaults to 100."
+  artifacts(repository: String!, groupId: String, artifactId: String, first: Int): [Artifact!]!
+  file(path: String!): File
+}
+
+type Repository {
+  name: String!
+  "hosted or proxy"
+  type: String!
+  url: String
+  artifacts(groupId: String, artifactId: String, first: Int): [Artifact!]!
+}
+
+type Artifact {
+  repository: String!
+  groupId: String!
+  artifactId: String!
+  latest: String!
+  "Newest first."
+  versions(first: Int): [Version!]!
+}
+
+type Version {
+  version: String!
+  "Bytes of all files except checksums."
+  size: Int!
+  files: [File!]!
+}
+
+type File {
+  path: String!
+  name: String!
+  size: Int!
+  "RFC 3339"
+  lastModified: String
+  "RFC 3339; null when never downloaded"
+  lastDownloaded: String
+  properties: [Property!]!
+  "SBOM formats stored for the file, e.g. cyclonedx-json"
+  sbom: [String!]!
+}
+
+type Property {
+  key: String!
+  value: String!
+}
+`
+
+// defaultGraphQLFirst bounds list fields when the query sets no "first".
+const defaultGraphQLFirst = 100
+
+type GraphQLRequest struct {
+	Query         string         `json:"query" example:"{ repositories { name type } }"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type GraphQLResponse struct {
+	Data   any        `json:"data" swaggertype:"object"`
+	Errors []gqlError `json:"errors,omitempty"`
+}
+
+// @Summary Read-only GraphQL query
+// @Description Queries repositories, artifacts, versions, files, sizes, download times and properties in one request. GET with ?query= or POST a GraphQL request; GET ?sdl returns the schema. Fragments, directives and introspection are not supported. POST bodies are limited to 1 MiB (413) and queries to 32 nesting levels.
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param request body GraphQLRequest false "GraphQL request (POST)"
+// @Param query query string false "GraphQL query (GET)"
+// @Success 200 {object} GraphQLResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/graphql [get]
+// @Router /api/v1/graphql [post]
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Has("sdl") {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(graphQLSchema))
+			return
+		}
+		req.Query = r.URL.Query().Get("query")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeAPIError(w, "invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, graphQLMaxBody)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeAPIError(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			writeAPIError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeAPIError(w, "query is required", http.StatusBadRequest)
+		return
+	}
+
+	root := &gqlQueryRoot{s: s, stats: map[string]map[string]time.Time{}}
+	data, errs := executeGraphQL(r.Context(), root, req.Query, req.Variables)
+	resp := GraphQLResponse{Errors: errs}
+	if data != nil {
+		resp.Data = data
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Warn("encode graphql response", zap.Error(err))
+	}
+}
+
+type gqlQueryRoot struct {
+	s *Server
+	// stats caches persisted download stats per repository for one query.
+	stats map[string]map[string]time.Time
+}
+
+func (q *gqlQueryRoot) gqlType() string { return "Query" }
+
+func (q *gqlQueryRoot) gqlField(ctx context.Context, name string, args map[string]any) (any, error) {
+	switch name {
+	case "repositories", "repository":
+		repos, err := q.s.listRepositories(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var out []any
+		for _, r := range repos {
+			repo := &gqlRepository{root: q, info: r}
+			if name == "repository" {
+				if r.Name == gqlString(args, "name") {
+					return repo, nil
+				}
+				continue
+			}
+			out = append(out, repo)
+		}
+		if name == "repository" {
+			return nil, nil
+		}
+		return out, nil
+	case "artifacts":
+		repo := strings.Trim(gqlString(args, "repository"), "/")
+		if repo == "" {
+			return nil, fmt.Errorf("repository is required")
+		}
+		return q.artifacts(ctx, repo, args)
+	case "file":
+		key := strings.Trim(gqlString(args, "path"), "/")
+		if key == "" || isInternalPath(key) {
+			return nil, fmt.Errorf("invalid path")
+		}
+		head, err := q.s.store.Head(ctx, key)
+		if storage.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		f := &gqlFile{root: q, entry: storage.Entry{Name: path.Base(key), Path: key, Type: "file", LastModified: head.LastModified}}
+		if head.ContentLength != nil {
+			f.entry.Size = *head.ContentLength
+		}
+		return f, nil
+	}
+	return nil, gqlUnknownField(q, name)
+}
+
+// artifacts walks repo (narrowed by groupId/artifactId) and groups files by
+// coordinates.
+func (q *gqlQueryRoot) artifacts(ctx context.Context, repo string, args map[string]any) (any, error) {
+	prefix := repo
+	group, artifactID := gqlString(args, "groupId"), gqlString(args, "artifactId")
+	if group != "" {
+		prefix += "/" + strings.ReplaceAll(group, ".", "/")
+		if artifactID != "" {
+			prefix += "/" + artifactID
+		}
+	}
+	if isInternalPath(prefix) || strings.Contains(prefix, "..") {
+		return nil, fmt.Errorf("invalid repository or group")
+	}
+
+	byKey := map[string]*gqlArtifact{}
+	err := walkStore(ctx, q.s.store, prefix+"/", func(e storage.Entry) error {
+		rel := strings.TrimPrefix(e.Path, repo+"/")
+		g, a, v, ok := parseCoordinates(rel)
+		if !ok || (artifactID != "" && a != artifactID) || (group != "" && g != group) {
+			return nil
+		}
+		key := g + ":" + a
+		art := byKey[key]
+		if art == nil {
+			art = &gqlArtifact{root: q, repo: repo, group: g, artifact: a, versions: map[string][]storage.Entry{}}
+			byKey[key] = art
+		}
+		art.versions[v] = append(art.versions[v], e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	arts := make([]*gqlArtifact, 0, len(byKey))
+	for _, a := range byKey {
+		arts = append(arts, a)
+	}
+	slices.SortFunc(arts, func(a, b *gqlArtifact) int {
+		return strings.Compare(a.group+":"+a.artifact, b.group+":"+b.artifact)
+	})
+	var out []any
+	for _, a := range arts {
+		if len(out) == gqlInt(args, "first", defaultGraphQLFirst) {
+			break
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// lastDownloaded merges persisted download stats with hits not yet flushed.
+func (q *gqlQueryRoot) lastDownloaded(ctx context.Context, key string) (time.Time, error) {
+	repo, rel, _ := strings.Cut(key, "/")
+	stats, ok := q.stats[repo]
+	if !ok {
+		var err error
+		if stats, err = q.s.proxy.loadDownloadStats(ctx, repo); err != nil {
+			return time.Time{}, err
+		}
+		q.stats[repo] = stats
+	}
+	at := stats[rel]
+	if pending := q.s.downloads.lastHit(key); pending.After(at) {
+		at = pending
+	}
+	return at, nil
+}
+
+type gqlRepository struct {
+	root *gqlQueryRoot
+	info repositoryInfo
+}
+
+func (r *gqlRepository) gqlType() string { return "Repository" }
+
+func (r *gqlRepository) gqlField(ctx context.Context, name string, args map[string]any) (any, error) {
+	switch name {
+	case "name":
+		return r.info.Name, nil
+	case "type":
+		return r.info.Type, nil
+	case "url":
+		if r.info.URL == "" {
+			return nil, nil
+		}
+		return r.info.URL, nil
+	case "artifacts":
+		return r.root.artifacts(ctx, r.info.Name, args)
+	}
+	return nil, gqlUnknownField(r, name)
+}
+
+type gqlArtifact struct {
+	root     *gqlQueryRoot
+	repo     string
+	group    string
+	artifact string
+	versions map[string][]storage.Entry
+}
+
+func (a *gqlArtifact) gqlType() string { return "Artifact" }
+
+func (a *gqlArtifact) sortedVersions() []string {
+	versions := make([]string, 0, len(a.versions))
+	for v := range a.versions {
+		versions = append(versions, v)
+	}
+	slices.SortFunc(versions, func(x, y string) int { return compareVersions(y, x) })
+	return versions
+}
+
+func (a *gqlArtifact) gqlField(_ context.Context, name string, args map[string]any) (any, error) {
+	switch name {
+	case "repository":
+		return a.repo, nil
+	case "groupId":
+		return a.group, nil
+	case "artifactId":
+		return a.artifact, nil
+	case "latest":
+		return a.sortedVersions()[0], nil
+	case "versions":
+		var out []any
+		for _, v := range a.sortedVersions() {
+			if len(out) == gqlInt(args, "first", defaultGraphQLFirst) {
+				break
+			}
+			out = append(out, &gqlVersion{root: a.root, version: v, files: a.versions[v]})
+		}
+		return out, nil
+	}
+	return nil, gqlUnknownField(a, name)
+}
+
+type gqlVersion struct {
+	root    *gqlQueryRoot
+	version string
+	files   []storage.Entry
+}
+
+func (v *gqlVersion) gqlType() string { return "Version" }
+
+func (v *gqlVersion) gqlField(_ context.Context, name string, _ map[string]any) (any, error) {
+	switch name {
+	case "version":
+		return v.version, nil
+	case "size":
+		var total int64
+		for _, f := range v.files {
+			if !isChecksumPath(f.Path) {
+				total += f.Size
+			}
+		}
+		return total, nil
+	case "files":
+		out := make([]any, len(v.files))
+		for i, f := range v.files {
+			out[i] = &gqlFile{root: v.root, entry: f}
+		}
+		return out, nil
+	}
+	return nil, gqlUnknownField(v, name)
+}
+
+type gqlFile struct {
+	root  *gqlQueryRoot
+	entry storage.Entry
+}
+
+func (f *gqlFile) gqlType() string { return "File" }
+
+func (f *gqlFile) gqlField(ctx context.Context, name string, _ map[string]any) (any, error) {
+	switch name {
+	case "path":
+		return f.entry.Path, nil
+	case "name":
+		return f.entry.Name, nil
+	case "size":
+		return f.entry.Size, nil
+	case "lastModified":
+		if f.entry.LastModified == nil {
+			return nil, nil
+		}
+		return f.entry.LastModified.UTC().Format(time.RFC3339), nil
+	case "lastDownloaded":
+		at, err := f.root.lastDownloaded(ctx, f.entry.Path)
+		if err != nil || at.IsZero() {
+			return nil, err
+		}
+		return at.UTC().Format(time.RFC3339), nil
+	case "properties":
+		props, err := loadProperties(ctx, f.root.s.store, f.entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(props))
+		for k := range props {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		out := make([]any, len(keys))
+		for i, k := range keys {
+			out[i] = &gqlProperty{key: k, value: props[k]}
+		}
+		return out, nil
+	case "sbom":
+		props, err := loadProperties(ctx, f.root.s.store, f.entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		out := []any{}
+		for _, format := range strings.Split(props["sbom.formats"], ",") {
+			if format != "" {
+				out = append(out, format)
+			}
+		}
+		return out, nil
+	}
+	return nil, gqlUnknownField(f, name)
+}
+
+type gqlProperty struct {
+	key, value string
+}
+
+func (p *gqlProperty) gqlType() string { return "Property" }
+
+func (p *gqlProperty) gqlField(_ context.Context, name string, _ map[string]any) (any, error) {
+	switch name {
+	case "key":
+		return p.key, nil
+	case "value":
+		return p.value, nil
+	}
+	return nil, gqlUnknownField(p, name)
+}
@@ -0,0 +1,492 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// grpcService is the fully-qualified service name from
+// api/heimdall/admin/v1/admin.proto.
+const grpcService = "heimdall.admin.v1.AdminService"
+
+// grpcMaxMessage bounds request messages, like grpc-go's default.
+const grpcMaxMessage = 4 << 20
+
+// gRPC status codes used by the admin service.
+const (
+	grpcOK                 = 0
+	grpcInvalidArgument    = 3
+	grpcNotFound           = 5
+	grpcPermissionDenied   = 7
+	grpcFailedPrecondition = 9
+	grpcAborted            = 10
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnauthenticated    = 16
+)
+
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e grpcError) Error() string { return e.msg }
+
+type grpcMethod func(ctx context.Context, req []byte) ([]byte, error)
+
+// GRPCHandler serves the AdminService over HTTP/2 (gRPC wire protocol,
+// unary calls, identity encoding). Mount it on a server that accepts
+// unencrypted HTTP/2 or TLS.
+func (s *Server) GRPCHandler() http.Handler {
+	methods := map[string]grpcMethod{
+		"ListRepositories": s.grpcListRepositories,
+		"ListProxies":      s.grpcListProxies,
+		"CreateProxy":      s.grpcCreateProxy,
+		"UpdateProxy":      s.grpcUpdateProxy,
+		"DeleteProxy":      s.grpcDeleteProxy,
+		"InvalidateProxy":  s.grpcInvalidateProxy,
+		"ListTasks":        s.grpcListTasks,
+		"ListTaskRuns":     s.grpcListTaskRuns,
+		"RunTask":          s.grpcRunTask,
+		"CancelTask":       s.grpcCancelTask,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			writeAPIError(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+			return
+		}
+		name, ok := strings.CutPrefix(r.URL.Path, "/"+grpcService+"/")
+		method := methods[name]
+		if !ok || method == nil {
+			writeGRPCError(w, grpcError{grpcUnimplemented, "unknown method " + r.URL.Path})
+			return
+		}
+
+		ctx, ok := s.authenticate(r)
+		if !ok {
+			writeGRPCError(w, grpcError{grpcUnauthenticated, "unauthorized"})
+			return
+		}
+		if !principalFrom(ctx).isAdmin() {
+			writeGRPCError(w, grpcError{grpcPermissionDenied, "forbidden"})
+			return
+		}
+
+		req, err := readGRPCMessage(r.Body)
+		if err != nil {
+			writeGRPCError(w, err)
+			return
+		}
+		resp, err := method(ctx, req)
+		if err != nil {
+			var ge grpcError
+			if !errors.As(err, &ge) {
+				s.logger.Error("grpc call failed", zap.String("method", name), zap.Error(err))
+				err = grpcError{grpcInternal, "internal error"}
+			}
+			writeGRPCError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.WriteHeader(http.StatusOK)
+		frame := make([]byte, 5, 5+len(resp))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(resp)))
+		_, _ = w.Write(append(frame, resp...))
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(grpcOK))
+	})
+}
+
+// readGRPCMessage reads the single length-prefixed message of a unary call.
+func readGRPCMessage(body io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(body, hdr[:]); err != nil {
+		return nil, grpcError{grpcInvalidArgument, "missing request message"}
+	}
+	if hdr[0] != 0 {
+		return nil, grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > grpcMaxMessage {
+		return nil, grpcError{grpcInvalidArgument, "request message too large"}
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, grpcError{grpcInvalidArgument, "truncated request message"}
+	}
+	return msg, nil
+}
+
+// writeGRPCError sends a trailers-only response.
+func writeGRPCError(w http.ResponseWriter, err error) {
+	ge := grpcError{grpcInternal, err.Error()}
+	errors.As(err, &ge)
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Grpc-Status", strconv.Itoa(ge.code))
+	w.Header().Set("Grpc-Message", url.PathEscape(ge.msg))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) grpcListRepositories(ctx context.Context, _ []byte) ([]byte, error) {
+	repos, err := s.listRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	for _, r := range repos {
+		var m []byte
+		m = appendProtoString(m, 1, r.Name)
+		m = appendProtoString(m, 2, r.Type)
+		m = appendProtoString(m, 3, r.URL)
+		out = appendProtoBytes(out, 1, m)
+	}
+	return out, nil
+}
+
+func (s *Server) grpcListProxies(ctx context.Context, _ []byte) ([]byte, error) {
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	for _, pr := range proxies {
+		out = appendProtoBytes(out, 1, encodeProtoProxy(pr))
+	}
+	return out, nil
+}
+
+func (s *Server) grpcCreateProxy(ctx context.Context, req []byte) ([]byte, error) {
+	var pr Proxy
+	err := decodeProto(req, func(num protowire.Number, v []byte, _ uint64) (err error) {
+		if num == 1 {
+			pr, err = decodeProtoProxy(v)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.proxy.Add(ctx, pr); err != nil {
+		return nil, grpcError{grpcInvalidArgument, err.Error()}
+	}
+	return nil, nil
+}
+
+func (s *Server) grpcUpdateProxy(ctx context.Context, req []byte) ([]byte, error) {
+	var name, revision string
+	var pr Proxy
+	err := decodeProto(req, func(num protowire.Number, v []byte, _ uint64) (err error) {
+		switch num {
+		case 1:
+			name = string(v)
+		case 2:
+			pr, err = decodeProtoProxy(v)
+		case 3:
+			revision = string(v)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if revision == "" {
+		return nil, errGRPCRevisionRequired
+	}
+	newRevision, err := s.proxy.UpdateIfMatch(ctx, name, pr, revision)
+	if err != nil {
+		return nil, grpcProxyChangeError(err)
+	}
+	return appendProtoString(nil, 1, newRevision), nil
+}
+
+func (s *Server) grpcDeleteProxy(ctx context.Context, req []byte) ([]byte, error) {
+	var name, revision string
+	err := decodeProto(req, func(num protowire.Number, v []byte, _ uint64) error {
+		switch num {
+		case 1:
+			name = string(v)
+		case 2:
+			revision = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if revision == "" {
+		return nil, errGRPCRevisionRequired
+	}
+	if err := s.proxy.DeleteIfMatch(ctx, name, revision); err != nil {
+		return nil, grpcProxyChangeError(err)
+	}
+	return nil, nil
+}
+
+// errGRPCRevisionRequired mirrors the 428 of the HTTP API: updates and
+// deletes must name the revision they were based on.
+var errGRPCRevisionRequired = grpcError{grpcFailedPrecondition, `revision is required ("*" matches any)`}
+
+// grpcProxyChangeError maps errors of conditional proxy changes to status
+// codes, like writeProxyChangeError does for HTTP.
+func grpcProxyChangeError(err error) error {
+	switch {
+	case errors.Is(err, errProxyNotFound):
+		return grpcError{grpcNotFound, err.Error()}
+	case errors.Is(err, errProxyConflict):
+		return grpcError{grpcAborted, err.Error()}
+	case errors.Is(err, errProxyDeclared):
+		return grpcError{grpcFailedPrecondition, err.Error()}
+	default:
+		return grpcError{grpcInvalidArgument, err.Error()}
+	}
+}
+
+func (s *Server) grpcInvalidateProxy(ctx context.Context, req []byte) ([]byte, error) {
+	var name string
+	var inv InvalidateRequest
+	err := decodeProto(req, func(num protowire.Number, v []byte, _ uint64) error {
+		switch num {
+		case 1:
+			name = string(v)
+		case 2:
+			inv.Path = string(v)
+		case 3:
+			inv.Pattern = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.proxy.Invalidate(ctx, name, inv)
+	var invalid invalidRequestError
+	switch {
+	case errors.Is(err, errProxyNotFound):
+		return nil, grpcError{grpcNotFound, err.Error()}
+	case errors.As(err, &invalid):
+		return nil, grpcError{grpcInvalidArgument, err.Error()}
+	case err != nil:
+		return nil, err
+	}
+	s.logger.Info("proxy cache invalidated", zap.String("proxy", name), zap.Int("deleted", len(deleted)))
+	var out []byte
+	for _, key := range deleted {
+		out = appendProtoString(out, 1, key)
+	}
+	return out, nil
+}
+
+func (s *Server) grpcListTasks(ctx context.Context, _ []byte) ([]byte, error) {
+	var out []byte
+	for _, st := range s.taskStatuses() {
+		var b []byte
+		b = appendProtoString(b, 1, st.Name)
+		b = appendProtoString(b, 2, st.Schedule)
+		if st.Enabled {
+			b = protowire.AppendTag(b, 3, protowire.VarintType)
+			b = protowire.AppendVarint(b, protowire.EncodeBool(true))
+		}
+		if st.NextRun != nil {
+			b = appendProtoString(b, 4, st.NextRun.Format(time.RFC3339Nano))
+		}
+		if st.Running != nil {
+			b = appendProtoBytes(b, 5, encodeProtoTaskRun(*st.Running))
+		}
+		if st.LastRun != nil {
+			b = appendProtoBytes(b, 6, encodeProtoTaskRun(*st.LastRun))
+		}
+		out = appendProtoBytes(out, 1, b)
+	}
+	return out, nil
+}
+
+func (s *Server) grpcListTaskRuns(ctx context.Context, req []byte) ([]byte, error) {
+	name, err := decodeProtoName(req)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := s.taskHistory(name)
+	if err != nil {
+		return nil, grpcError{grpcNotFound, err.Error()}
+	}
+	var out []byte
+	for _, run := range runs {
+		out = appendProtoBytes(out, 1, encodeProtoTaskRun(run))
+	}
+	return out, nil
+}
+
+func (s *Server) grpcRunTask(ctx context.Context, req []byte) ([]byte, error) {
+	name, err := decodeProtoName(req)
+	if err != nil {
+		return nil, err
+	}
+	run, err := s.startTask(ctx, name)
+	switch {
+	case errors.Is(err, errTaskUnknown):
+		return nil, grpcError{grpcNotFound, err.Error()}
+	case errors.Is(err, errTaskRunning):
+		return nil, grpcError{grpcFailedPrecondition, err.Error()}
+	}
+	s.logger.Info("task run started", zap.String("task", name), zap.String("run", run.ID))
+	return appendProtoBytes(nil, 1, encodeProtoTaskRun(run)), nil
+}
+
+func (s *Server) grpcCancelTask(ctx context.Context, req []byte) ([]byte, error) {
+	id, err := decodeProtoName(req)
+	if err != nil {
+		return nil, err
+	}
+	run, ok := s.cancelTaskRun(id)
+	if !ok {
+		return nil, grpcError{grpcNotFound, "no running task with this id"}
+	}
+	s.logger.Info("task run canceled", zap.String("task", run.Task), zap.String("run", id))
+	return appendProtoBytes(nil, 1, encodeProtoTaskRun(run)), nil
+}
+
+// decodeProtoName reads field 1 of requests that only name their target.
+func decodeProtoName(b []byte) (string, error) {
+	var name string
+	err := decodeProto(b, func(num protowire.Number, v []byte, _ uint64) error {
+		if num == 1 {
+			name = string(v)
+		}
+		return nil
+	})
+	return name, err
+}
+
+func encodeProtoTaskRun(run TaskRun) []byte {
+	var b []byte
+	b = appendProtoString(b, 1, run.ID)
+	b = appendProtoString(b, 2, run.Task)
+	b = appendProtoString(b, 3, run.Trigger)
+	b = appendProtoString(b, 4, run.Status)
+	b = appendProtoString(b, 5, run.StartedAt.Format(time.RFC3339Nano))
+	if run.FinishedAt != nil {
+		b = appendProtoString(b, 6, run.FinishedAt.Format(time.RFC3339Nano))
+	}
+	if run.DurationMs != 0 {
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(run.DurationMs))
+	}
+	b = appendProtoString(b, 8, run.Error)
+	b = appendProtoString(b, 9, run.Checkpoint)
+	b = appendProtoString(b, 10, run.ResumedFrom)
+	return b
+}
+
+func encodeProtoProxy(pr Proxy) []byte {
+	var b []byte
+	b = appendProtoString(b, 1, pr.Name)
+	b = appendProtoString(b, 2, pr.URL)
+	b = appendProtoString(b, 3, pr.MaxAge)
+	if pr.MaxCacheBytes != 0 {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(pr.MaxCacheBytes))
+	}
+	if pr.Cache != nil {
+		b = protowire.AppendTag(b, 5, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(*pr.Cache))
+	}
+	b = appendProtoString(b, 6, pr.UpdatePolicy)
+	b = appendProtoString(b, 7, pr.CanaryURL)
+	for _, m := range pr.Mirrors {
+		b = appendProtoString(b, 8, m)
+	}
+	if pr.Declared {
+		b = protowire.AppendTag(b, 9, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(true))
+	}
+	b = appendProtoString(b, 10, pr.Revision)
+	return b
+}
+
+func decodeProtoProxy(b []byte) (Proxy, error) {
+	var pr Proxy
+	err := decodeProto(b, func(num protowire.Number, v []byte, n uint64) error {
+		switch num {
+		case 1:
+			pr.Name = string(v)
+		case 2:
+			pr.URL = string(v)
+		case 3:
+			pr.MaxAge = string(v)
+		case 4:
+			pr.MaxCacheBytes = int64(n)
+		case 5:
+			cache := protowire.DecodeBool(n)
+			pr.Cache = &cache
+		case 6:
+			pr.UpdatePolicy = string(v)
+		case 7:
+			pr.CanaryURL = string(v)
+		case 8:
+			pr.Mirrors = append(pr.Mirrors, string(v))
+		case 10:
+			pr.Revision = string(v)
+		}
+		return nil
+	})
+	return pr, err
+}
+
+// decodeProto walks the fields of a message, passing length-delimited
+// values as v and varints as n; other wire types are skipped.
+func decodeProto(b []byte, fn func(num protowire.Number, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return grpcError{grpcInvalidArgument, fmt.Sprintf("malformed message: %v", protowire.ParseError(n))}
+		}
+		b = b[n:]
+		var (
+			v   []byte
+			val uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			val, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return grpcError{grpcInvalidArgument, fmt.Sprintf("malformed message: %v", protowire.ParseError(n))}
+		}
+		b = b[n:]
+		if typ != protowire.BytesType && typ != protowire.VarintType {
+			continue
+		}
+		if err := fn(num, v, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendProtoString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendProtoBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// grpcCall performs a unary call over unencrypted HTTP/2 and returns the
+// response message, grpc-status and grpc-message.
+func grpcCall(t *testing.T, url, method, user, pass string, msg []byte) ([]byte, string, string) {
+	t.Helper()
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url+"/"+grpcService+"/"+method, bytes.NewReader(append(frame, msg...)))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if user != "" {
+		req.SetBasicAuth(user, pass)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("call %s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("expected HTTP/2, got %s", resp.Proto)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	status, message := resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	}
+	if len(body) >= 5 {
+		body = body[5:]
+	}
+	return body, status, message
+}
+
+func TestGRPCAdminService(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	ts := httptest.NewUnstartedServer(srv.GRPCHandler())
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+
+	if _, status, _ := grpcCall(t, ts.URL, "ListProxies", "", "", nil); status != "16" {
+		t.Fatalf("expected UNAUTHENTICATED, got %s", status)
+	}
+
+	proxy := encodeProtoProxy(Proxy{Name: "central", URL: "https://repo1.maven.org/maven2", MaxCacheBytes: 1024})
+	if _, status, msg := grpcCall(t, ts.URL, "CreateProxy", "admin", "secret", appendProtoBytes(nil, 1, proxy)); status != "0" {
+		t.Fatalf("create proxy: %s %s", status, msg)
+	}
+	if _, status, _ := grpcCall(t, ts.URL, "CreateProxy", "admin", "secret", appendProtoBytes(nil, 1, encodeProtoProxy(Proxy{Name: "bad name"}))); status != "3" {
+		t.Fatalf("expected INVALID_ARGUMENT, got %s", status)
+	}
+
+	body, status, msg := grpcCall(t, ts.URL, "ListProxies", "admin", "secret", nil)
+	if status != "0" {
+		t.Fatalf("list proxies: %s %s", status, msg)
+	}
+	var proxies []Proxy
+	if err := decodeProto(body, func(num protowire.Number, v []byte, _ uint64) error {
+		pr, err := decodeProtoProxy(v)
+		proxies = append(proxies, pr)
+		return err
+	}); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(proxies) != 1 || proxies[0].Name != "central" || proxies[0].MaxCacheBytes != 1024 {
+		t.Fatalf("unexpected proxies %+v", proxies)
+	}
+
+	update := func(revision string) ([]byte, string) {
+		msg := appendProtoString(nil, 1, "central")
+		msg = appendProtoBytes(msg, 2, encodeProtoProxy(Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2"}))
+		msg = appendProtoString(msg, 3, revision)
+		body, status, _ := grpcCall(t, ts.URL, "UpdateProxy", "admin", "secret", msg)
+		return body, status
+	}
+	if _, status := update(""); status != "9" {
+		t.Fatalf("update without revision: expected FAILED_PRECONDITION, got %s", status)
+	}
+	if _, status := update("stale"); status != "10" {
+		t.Fatalf("update with stale revision: expected ABORTED, got %s", status)
+	}
+	body, status = update(proxies[0].Revision)
+	var revision string
+	_ = decodeProto(body, func(num protowire.Number, v []byte, _ uint64) error {
+		if num == 1 {
+			revision = string(v)
+		}
+		return nil
+	})
+	if status != "0" || revision == "" || revision == proxies[0].Revision {
+		t.Fatalf("update: status %s, revision %q", status, revision)
+	}
+	deleteMsg := func(revision string) []byte {
+		return appendProtoString(appendProtoString(nil, 1, "central"), 2, revision)
+	}
+	if _, status, _ := grpcCall(t, ts.URL, "DeleteProxy", "admin", "secret", deleteMsg(proxies[0].Revision)); status != "10" {
+		t.Fatalf("delete with stale revision: expected ABORTED, got %s", status)
+	}
+	if _, status, msg := grpcCall(t, ts.URL, "DeleteProxy", "admin", "secret", deleteMsg(revision)); status != "0" {
+		t.Fatalf("delete: %s %s", status, msg)
+	}
+	if _, status, msg := grpcCall(t, ts.URL, "CreateProxy", "admin", "secret", appendProtoBytes(nil, 1, proxy)); status != "0" {
+		t.Fatalf("recreate proxy: %s %s", status, msg)
+	}
+
+	if err := store.Put(context.Background(), "releases/com/acme/app.jar", bytes.NewReader([]byte("x")), "application/octet-stream", 1); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	body, status, _ = grpcCall(t, ts.URL, "ListRepositories", "admin", "secret", nil)
+	if status != "0" {
+		t.Fatalf("list repositories: %s", status)
+	}
+	var repos []string
+	_ = decodeProto(body, func(_ protowire.Number, v []byte, _ uint64) error {
+		return decodeProto(v, func(num protowire.Number, v []byte, _ uint64) error {
+			if num == 2 {
+				repos = append(repos, string(v))
+			}
+			return nil
+		})
+	})
+	if len(repos) != 2 || repos[0] != "proxy" || repos[1] != "hosted" {
+		t.Fatalf("unexpected repository types %v", repos)
+	}
+
+	if _, status, _ := grpcCall(t, ts.URL, "Nope", "admin", "secret", nil); status != "12" {
+		t.Fatalf("expected UNIMPLEMENTED, got %s", status)
+	}
+}
+
+func TestGRPCTasks(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	if err := srv.ScheduleTask(Task{Name: "slow", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(srv.GRPCHandler())
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+
+	// runField returns string field num of the TaskRun in field 1 of msg.
+	runField := func(msg []byte, num protowire.Number) string {
+		var out string
+		_ = decodeProto(msg, func(_ protowire.Number, v []byte, _ uint64) error {
+			return decodeProto(v, func(n protowire.Number, v []byte, _ uint64) error {
+				if n == num {
+					out = string(v)
+				}
+				return nil
+			})
+		})
+		return out
+	}
+	name := appendProtoString(nil, 1, "slow")
+
+	if _, status, _ := grpcCall(t, ts.URL, "RunTask", "admin", "secret", appendProtoString(nil, 1, "nope")); status != "5" {
+		t.Fatalf("run unknown task: expected NOT_FOUND, got %s", status)
+	}
+	body, status, msg := grpcCall(t, ts.URL, "RunTask", "admin", "secret", name)
+	if status != "0" {
+		t.Fatalf("run task: %s %s", status, msg)
+	}
+	id := runField(body, 1)
+	if id == "" || runField(body, 4) != TaskRunning || runField(body, 3) != "manual" {
+		t.Fatalf("unexpected run %q", body)
+	}
+	if _, status, _ := grpcCall(t, ts.URL, "RunTask", "admin", "secret", name); status != "9" {
+		t.Fatalf("run while running: expected FAILED_PRECONDITION, got %s", status)
+	}
+
+	body, status, _ = grpcCall(t, ts.URL, "ListTasks", "admin", "secret", nil)
+	if status != "0" || runField(body, 1) != "slow" {
+		t.Fatalf("list tasks: %s %q", status, body)
+	}
+	var running string
+	_ = decodeProto(body, func(_ protowire.Number, v []byte, _ uint64) error {
+		return decodeProto(v, func(n protowire.Number, v []byte, _ uint64) error {
+			if n == 5 {
+				running = runField(appendProtoBytes(nil, 1, v), 1)
+			}
+			return nil
+		})
+	})
+	if running != id {
+		t.Fatalf("expected run %s in progress, got %q", id, running)
+	}
+
+	if _, status, _ := grpcCall(t, ts.URL, "CancelTask", "admin", "secret", appendProtoString(nil, 1, "missing")); status != "5" {
+		t.Fatalf("cancel unknown run: expected NOT_FOUND, got %s", status)
+	}
+	if _, status, msg := grpcCall(t, ts.URL, "CancelTask", "admin", "secret", appendProtoString(nil, 1, id)); status != "0" {
+		t.Fatalf("cancel: %s %s", status, msg)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		body, status, _ = grpcCall(t, ts.URL, "ListTaskRuns", "admin", "secret", name)
+		if status != "0" {
+			t.Fatalf("list task runs: %s", status)
+		}
+		if runField(body, 4) == TaskCanceled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the run to be canceled, got %q", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, status, _ := grpcCall(t, ts.URL, "ListTaskRuns", "admin", "secret", appendProtoString(nil, 1, "nope")); status != "5" {
+		t.Fatalf("runs of unknown task: expected NOT_FOUND, got %s", status)
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Health statuses. A failed critical check makes the report healthDown,
+// any other failure healthDegraded.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthDown     = "down"
+)
+
+const (
+	// healthCacheTTL is how long a report is served before the checks run
+	// again, so frequent probes do not turn into S3 and upstream traffic.
+	healthCacheTTL = 10 * time.Second
+	healthTimeout  = 5 * time.Second
+	// healthTaskGrace is how late a scheduled task may be before the
+	// scheduler is reported as stuck.
+	healthTaskGrace = time.Minute
+	// healthProbeKey is read to measure storage latency; it need not exist.
+	healthProbeKey = "__health__"
+)
+
+// HealthCheck is the result of one dependency check. Details stay short
+// and never include upstream URLs or raw errors, since /healthz is public.
+type HealthCheck struct {
+	Name      string `json:"name" example:"storage"`
+	Status    string `json:"status" example:"ok"`
+	Critical  bool   `json:"critical"`
+	LatencyMS int64  `json:"latencyMs"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+type HealthReport struct {
+	Status    string        `json:"status" example:"ok"`
+	CheckedAt time.Time     `json:"checkedAt"`
+	Checks    []HealthCheck `json:"checks"`
+}
+
+// @Summary Health check
+// @Description Checks the bucket (with the latency of a HEAD), the temp directory uploads are buffered in, every proxy upstream and the task scheduler. A failing bucket or temp directory makes the status "down" and the response 503; other failures make it "degraded" and keep 200. Reports are cached for 10s. Use /livez for liveness probes.
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthReport
+// @Failure 503 {object} HealthReport
+// @Router /healthz [get]
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	report := s.healthReport(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == healthDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		s.logger.Warn("encode health report", zap.Error(err))
+	}
+}
+
+// healthReport returns the cached report or runs the checks. Concurrent
+// callers wait for one run instead of starting their own.
+func (s *Server) healthReport(ctx context.Context) HealthReport {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	if s.health != nil && time.Since(s.health.CheckedAt) < healthCacheTTL {
+		return *s.health
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), healthTimeout)
+	defer cancel()
+	checks := []func(context.Context) HealthCheck{s.checkStorage, s.checkTempDir}
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		s.logger.Warn("health: list proxies", zap.Error(err))
+	}
+	for _, pr := range proxies {
+		checks = append(checks, func(ctx context.Context) HealthCheck { return s.checkProxy(ctx, pr) })
+	}
+
+	report := HealthReport{Status: healthOK, CheckedAt: time.Now().UTC(), Checks: make([]HealthCheck, len(checks), len(checks)+1)}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+	report.Checks = append(report.Checks, s.checkScheduler())
+
+	for _, c := range report.Checks {
+		switch {
+		case c.Status == healthOK:
+		case c.Critical:
+			report.Status = healthDown
+		case report.Status == healthOK:
+			report.Status = healthDegraded
+		}
+	}
+	s.health = &report
+	return report
+}
+
+// checkStorage times a HEAD of a key that usually does not exist; a 404
+// proves the bucket answers just as well.
+func (s *Server) checkStorage(ctx context.Context) HealthCheck {
+	c := HealthCheck{Name: "storage", Status: healthOK, Critical: true}
+	start := time.Now()
+	_, err := s.store.Head(ctx, healthProbeKey)
+	c.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil && !storage.IsNotFound(err) {
+		s.logger.Warn("health: storage", zap.Error(err))
+		c.Status, c.Detail = healthDown, "bucket not reachable"
+		if storage.IsTimeout(err) || ctx.Err() != nil {
+			c.Detail = "timeout"
+		}
+	}
+	return c
+}
+
+// checkTempDir writes a file where uploads are buffered.
+func (s *Server) checkTempDir(context.Context) HealthCheck {
+	c := HealthCheck{Name: "tempdir", Status: healthOK, Critical: true}
+	start := time.Now()
+	f, err := os.CreateTemp("", "heimdall-health-*")
+	if err == nil {
+		_, err = f.Write([]byte("ok"))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		os.Remove(f.Name())
+	}
+	c.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		s.logger.Warn("health: temp dir", zap.Error(err))
+		c.Status, c.Detail = healthDown, "not writable"
+	}
+	return c
+}
+
+// checkProxy sends a HEAD to the upstream's base URL. Any HTTP answer, even
+// an error status, shows the upstream is reachable.
+func (s *Server) checkProxy(ctx context.Context, pr Proxy) HealthCheck {
+	c := HealthCheck{Name: "proxy:" + pr.Name, Status: healthOK}
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, pr.URL, nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = s.proxy.httpClient.Do(req); err == nil {
+			resp.Body.Close()
+			c.Detail = "HTTP " + strconv.Itoa(resp.StatusCode)
+		}
+	}
+	c.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		s.logger.Warn("health: proxy", zap.String("proxy", pr.Name), zap.Error(err))
+		c.Status, c.Detail = healthDegraded, "unreachable"
+		if ctx.Err() != nil {
+			c.Detail = "timeout"
+		}
+	}
+	return c
+}
+
+// checkScheduler reports a scheduler that is not running while tasks are
+// scheduled, or a task that should have started a while ago.
+func (s *Server) checkScheduler() HealthCheck {
+	c := HealthCheck{Name: "scheduler", Status: healthOK}
+	s.tasksMu.Lock()
+	defer s.tasksMu.Unlock()
+	now := time.Now()
+	for _, t := range s.tasks {
+		if t.schedule == nil {
+			continue
+		}
+		if !s.schedulerRunning.Load() {
+			c.Status, c.Detail = healthDegraded, "not running"
+			return c
+		}
+		if t.running == nil && !t.next.IsZero() && now.Sub(t.next) > healthTaskGrace {
+			c.Status, c.Detail = healthDegraded, "task "+t.Name+" overdue since "+t.next.UTC().Format(time.RFC3339)
+			return c
+		}
+	}
+	return c
+}
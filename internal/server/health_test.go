@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHealthReport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "user", "pass")
+	if err := srv.proxy.Add(context.Background(), Proxy{Name: "central", URL: upstream.URL}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+
+	health := func() (int, HealthReport) {
+		srv.health = nil
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var report HealthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, report
+	}
+	check := func(report HealthReport, name string) HealthCheck {
+		for _, c := range report.Checks {
+			if c.Name == name {
+				return c
+			}
+		}
+		t.Fatalf("no %s check in %+v", name, report.Checks)
+		return HealthCheck{}
+	}
+
+	code, report := health()
+	if code != http.StatusOK || report.Status != healthOK {
+		t.Fatalf("expected ok without authentication, got %d %+v", code, report)
+	}
+	for _, name := range []string{"storage", "tempdir", "proxy:central", "scheduler"} {
+		if c := check(report, name); c.Status != healthOK {
+			t.Fatalf("expected %s to be ok, got %+v", name, c)
+		}
+	}
+
+	// An unreachable upstream or a stopped scheduler only degrades.
+	if err := srv.proxy.Add(context.Background(), Proxy{Name: "gone", URL: down.URL}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	if err := srv.ScheduleTask(Task{Name: "job", Schedule: "@hourly", Run: func(context.Context) error { return nil }}); err != nil {
+		t.Fatal(err)
+	}
+	code, report = health()
+	if code != http.StatusOK || report.Status != healthDegraded {
+		t.Fatalf("expected degraded, got %d %+v", code, report)
+	}
+	if c := check(report, "proxy:gone"); c.Status != healthDegraded || c.Detail != "unreachable" {
+		t.Fatalf("unexpected proxy check %+v", c)
+	}
+	if c := check(report, "scheduler"); c.Status != healthDegraded {
+		t.Fatalf("expected the idle scheduler to be reported, got %+v", c)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Fatalf("unexpected liveness answer %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHealthStorageDown(t *testing.T) {
+	srv := New(&mockStore{headErr: errors.New("connection refused")}, zaptest.NewLogger(t), metrics.New(), "", "")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var report HealthReport
+	_ = json.Unmarshal(rec.Body.Bytes(), &report)
+	if rec.Code != http.StatusServiceUnavailable || report.Status != healthDown {
+		t.Fatalf("expected 503 down, got %d %+v", rec.Code, report)
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// versionedStorage is implemented by stores that can list and restore S3
+// object versions.
+type versionedStorage interface {
+	Versions(ctx context.Context, key string) ([]storage.ObjectVersion, error)
+	RestoreVersion(ctx context.Context, key, versionID string) error
+}
+
+type HistoryResponse struct {
+	Path     string                  `json:"path"`
+	Versions []storage.ObjectVersion `json:"versions"`
+}
+
+type RestoreRequest struct {
+	Path      string `json:"path"`
+	VersionID string `json:"versionId"`
+	// Force replaces a newer live version. Without it the restore only
+	// proceeds when the object was deleted or the version is already current.
+	Force bool `json:"force,omitempty"`
+}
+
+type RestoreResponse struct {
+	Path      string `json:"path"`
+	VersionID string `json:"versionId"`
+	// Sidecars maps each restored sidecar key to the version it was restored from.
+	Sidecars map[string]string `json:"sidecars,omitempty"`
+}
+
+func (s *Server) versioned(w http.ResponseWriter) (versionedStorage, bool) {
+	vs, ok := s.store.(versionedStorage)
+	if !ok {
+		writeAPIError(w, "storage backend does not support versions", http.StatusNotImplemented)
+	}
+	return vs, ok
+}
+
+func (s *Server) writeVersionError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, storage.ErrVersioningDisabled) {
+		writeAPIError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.writeError(w, action, err)
+}
+
+// @Summary List object versions
+// @Description Lists all S3 versions and delete markers of an object, newest first. Requires bucket versioning.
+// @Tags artifacts
+// @Produce json
+// @Param key path string true "Object path"
+// @Success 200 {object} HistoryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/history/{key} [get]
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, apiV1+"/history/"), "/")
+	if key == "" || isInternalPath(key) {
+		writeAPIError(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	vs, ok := s.versioned(w)
+	if !ok {
+		return
+	}
+	versions, err := vs.Versions(r.Context(), key)
+	if err != nil {
+		s.writeVersionError(w, "list versions", err)
+		return
+	}
+	if versions == nil {
+		versions = []storage.ObjectVersion{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(HistoryResponse{Path: key, Versions: versions}); err != nil {
+		s.logger.Warn("encode history", zap.Error(err))
+	}
+}
+
+// @Summary Restore object version
+// @Description Makes a previous version the current one. The checksum sidecars are restored to the versions written alongside it. A newer live version is only replaced when force is set; otherwise the request answers 409.
+// @Tags artifacts
+// @Accept json
+// @Produce json
+// @Param request body RestoreRequest true "Object path and version"
+// @Success 200 {object} RestoreResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/restore [post]
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	key := strings.Trim(strings.TrimSpace(req.Path), "/")
+	if key == "" || isInternalPath(key) || req.VersionID == "" {
+		writeAPIError(w, "path and versionId are required", http.StatusBadRequest)
+		return
+	}
+	vs, ok := s.versioned(w)
+	if !ok {
+		return
+	}
+
+	versions, err := vs.Versions(r.Context(), key)
+	if err != nil {
+		s.writeVersionError(w, "list versions", err)
+		return
+	}
+	target, next, found := findVersion(versions, req.VersionID)
+	if !found {
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if target.DeleteMarker {
+		writeAPIError(w, "cannot restore a delete marker", http.StatusBadRequest)
+		return
+	}
+	// Restoring over a newer upload would silently discard it, so that needs
+	// an explicit force. Restoring a deleted object is always allowed.
+	if latest := versions[0]; !req.Force && !latest.DeleteMarker && latest.VersionID != target.VersionID {
+		writeAPIError(w, "a newer version "+latest.VersionID+" is live; set force to replace it", http.StatusConflict)
+		return
+	}
+
+	if err := vs.RestoreVersion(r.Context(), key, target.VersionID); err != nil {
+		s.writeError(w, "restore version", err)
+		return
+	}
+
+	resp := RestoreResponse{Path: key, VersionID: target.VersionID}
+	// The configured sidecars are restored alongside the artifact they
+	// describe.
+	for _, name := range s.checksums {
+		sidecar := key + "." + name
+		sideVersions, err := vs.Versions(r.Context(), sidecar)
+		if err != nil {
+			s.writeError(w, "list sidecar versions", err)
+			return
+		}
+		match, ok := sidecarVersion(sideVersions, target.LastModified, next)
+		if !ok {
+			s.logger.Warn("no sidecar version matches restored object", zap.String("key", sidecar), zap.String("versionId", target.VersionID))
+			continue
+		}
+		if err := vs.RestoreVersion(r.Context(), sidecar, match.VersionID); err != nil {
+			s.writeError(w, "restore sidecar version", err)
+			return
+		}
+		if resp.Sidecars == nil {
+			resp.Sidecars = make(map[string]string)
+		}
+		resp.Sidecars[sidecar] = match.VersionID
+	}
+
+	s.audit(r, "object.restored", zap.String("key", key), zap.String("versionId", target.VersionID))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Warn("encode restore", zap.Error(err))
+	}
+}
+
+// findVersion returns the version with id and the time the following version
+// was written (zero if it is the newest). versions must be newest first.
+func findVersion(versions []storage.ObjectVersion, id string) (storage.ObjectVersion, time.Time, bool) {
+	for i, v := range versions {
+		if v.VersionID != id {
+			continue
+		}
+		var next time.Time
+		if i > 0 {
+			next = versions[i-1].LastModified
+		}
+		return v, next, true
+	}
+	return storage.ObjectVersion{}, time.Time{}, false
+}
+
+// sidecarVersion picks the last sidecar written after the object version at
+// from and before the next object version (if any). Sidecars are uploaded
+// right after their artifact, so that is the one describing it.
+func sidecarVersion(versions []storage.ObjectVersion, from, next time.Time) (storage.ObjectVersion, bool) {
+	for _, v := range versions {
+		if v.DeleteMarker || v.LastModified.Before(from) {
+			continue
+		}
+		if !next.IsZero() && !v.LastModified.Before(next) {
+			continue
+		}
+		return v, true
+	}
+	return storage.ObjectVersion{}, false
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+type versionedMemStore struct {
+	*memStore
+	versions map[string][]storage.ObjectVersion
+	restored map[string]string
+}
+
+func (v *versionedMemStore) Versions(ctx context.Context, key string) ([]storage.ObjectVersion, error) {
+	if v.versions == nil {
+		return nil, storage.ErrVersioningDisabled
+	}
+	return v.versions[key], nil
+}
+
+func (v *versionedMemStore) RestoreVersion(ctx context.Context, key, versionID string) error {
+	v.restored[key] = versionID
+	return nil
+}
+
+func TestRestoreVersionWithSidecars(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &versionedMemStore{
+		memStore: newMemStore(),
+		restored: map[string]string{},
+		versions: map[string][]storage.ObjectVersion{
+			"snapshots/app.jar": {
+				{VersionID: "a3", LastModified: t0.Add(2 * time.Hour), IsLatest: true},
+				{VersionID: "a2", LastModified: t0.Add(time.Hour)},
+				{VersionID: "a1", LastModified: t0},
+			},
+			"snapshots/app.jar.sha1": {
+				{VersionID: "s3", LastModified: t0.Add(2*time.Hour + time.Second), IsLatest: true},
+				{VersionID: "s2", LastModified: t0.Add(time.Hour + time.Second)},
+				{VersionID: "s1", LastModified: t0.Add(time.Second)},
+			},
+		},
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/history/snapshots/app.jar", nil)
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("history: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var history HistoryResponse
+	if err := json.NewDecoder(rr.Body).Decode(&history); err != nil || len(history.Versions) != 3 {
+		t.Fatalf("unexpected history %+v (%v)", history, err)
+	}
+
+	// a3 is live, so replacing it needs force.
+	req = httptest.NewRequest(http.MethodPost, "/api/restore", strings.NewReader(`{"path":"snapshots/app.jar","versionId":"a2"}`))
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict || len(store.restored) != 0 {
+		t.Fatalf("restore without force: expected 409, got %d (%v)", rr.Code, store.restored)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/restore", strings.NewReader(`{"path":"snapshots/app.jar","versionId":"a2","force":true}`))
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if store.restored["snapshots/app.jar"] != "a2" || store.restored["snapshots/app.jar.sha1"] != "s2" {
+		t.Fatalf("unexpected restores: %v", store.restored)
+	}
+	if _, ok := store.restored["snapshots/app.jar.md5"]; ok {
+		t.Fatalf("md5 has no versions and must not be restored")
+	}
+}
+
+func TestRestoreDeletedObjectWithoutForce(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &versionedMemStore{
+		memStore: newMemStore(),
+		restored: map[string]string{},
+		versions: map[string][]storage.ObjectVersion{
+			"releases/app.jar": {
+				{VersionID: "d1", LastModified: t0.Add(time.Hour), IsLatest: true, DeleteMarker: true},
+				{VersionID: "a1", LastModified: t0},
+			},
+		},
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/restore", strings.NewReader(`{"path":"releases/app.jar","versionId":"a1"}`))
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || store.restored["releases/app.jar"] != "a1" {
+		t.Fatalf("expected deleted object to be restored, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHistoryRequiresVersioning(t *testing.T) {
+	srv := New(&versionedMemStore{memStore: newMemStore()}, zaptest.NewLogger(t), metrics.New(), "", "")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/history/releases/app.jar", nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+
+	srv = New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/history/releases/app.jar", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d", rr.Code)
+	}
+}
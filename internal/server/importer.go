@@ -0,0 +1,470 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// ImportAsset is one file of a repository being imported.
+type ImportAsset struct {
+	// Path is the file's path in the repository, without a leading "/".
+	Path string
+	// Location tells the source where to read the content from: a URL or a
+	// local file.
+	Location    string
+	Size        int64
+	SHA1        string
+	MD5         string
+	ContentType string
+	Properties  map[string]string
+}
+
+// ImportSource lists and reads the files of a repository on another
+// repository manager.
+type ImportSource interface {
+	Walk(ctx context.Context, fn func(ImportAsset) error) error
+	Open(ctx context.Context, asset ImportAsset) (io.ReadCloser, error)
+}
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// Target is the path the repository is imported to, e.g. "releases".
+	Target string
+	// Overwrite replaces files that already exist; they are skipped
+	// otherwise, so an interrupted import can be run again.
+	Overwrite bool
+	// DryRun lists what would be imported without writing anything.
+	DryRun  bool
+	Workers int
+	Logger  *zap.Logger
+	// Checksums are the sidecar digests written for each file
+	// (storage.ParseSidecarChecksums); sha1 and md5 when empty.
+	Checksums []string
+}
+
+// ImportStats summarises an import. Checksum sidecars of the source are
+// not counted: they are written again from the verified content.
+type ImportStats struct {
+	Assets   int   `json:"assets"`
+	Imported int   `json:"imported"`
+	Skipped  int   `json:"skipped"`
+	Failed   int   `json:"failed"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// Import copies every file of src below opts.Target, keeping the repository
+// layout. Content is checked against the checksums the source reports, and
+// checksum sidecars and properties are written like a deploy would.
+func Import(ctx context.Context, store Storage, src ImportSource, opts ImportOptions) (ImportStats, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	workers := max(opts.Workers, 1)
+	target := strings.Trim(opts.Target, "/")
+
+	var (
+		stats    ImportStats
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	assets := make(chan ImportAsset)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range assets {
+				key := path.Join(target, a.Path)
+				imported, n, err := importAsset(ctx, store, src, a, key, opts)
+				mu.Lock()
+				switch {
+				case err != nil:
+					stats.Failed++
+					if firstErr == nil {
+						firstErr = fmt.Errorf("%s: %w", a.Path, err)
+					}
+					logger.Warn("import failed", zap.String("path", a.Path), zap.Error(err))
+				case imported:
+					stats.Imported++
+					stats.Bytes += n
+					logger.Debug("imported", zap.String("key", key), zap.Int64("bytes", n))
+				default:
+					stats.Skipped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	walkErr := src.Walk(ctx, func(a ImportAsset) error {
+		a.Path = strings.TrimPrefix(a.Path, "/")
+		if isChecksumPath(a.Path) {
+			return nil
+		}
+		mu.Lock()
+		stats.Assets++
+		mu.Unlock()
+		select {
+		case assets <- a:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(assets)
+	wg.Wait()
+	if walkErr != nil {
+		return stats, walkErr
+	}
+	if firstErr != nil {
+		return stats, fmt.Errorf("%d of %d assets failed, first: %w", stats.Failed, stats.Assets, firstErr)
+	}
+	return stats, nil
+}
+
+// importAsset copies one asset to key. It reports whether the asset was
+// imported (or would be, in a dry run) and how many bytes were written.
+func importAsset(ctx context.Context, store Storage, src ImportSource, a ImportAsset, key string, opts ImportOptions) (bool, int64, error) {
+	if a.Path == "" || strings.Contains(a.Path, "..") || isInternalPath(key) {
+		return false, 0, fmt.Errorf("invalid path")
+	}
+	if !opts.Overwrite {
+		if _, err := store.Head(ctx, key); err == nil {
+			return false, 0, nil
+		} else if !storage.IsNotFound(err) {
+			return false, 0, err
+		}
+	}
+	if opts.DryRun {
+		return true, 0, nil
+	}
+
+	rc, err := src.Open(ctx, a)
+	if err != nil {
+		return false, 0, err
+	}
+	defer rc.Close()
+	tmp, err := os.CreateTemp("", "heimdall-import-*")
+	if err != nil {
+		return false, 0, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	checksums := opts.Checksums
+	if len(checksums) == 0 {
+		checksums = storage.DefaultSidecarChecksums
+	}
+	// The source's digests are verified whatever is written.
+	sums := storage.NewChecksums(append([]string{"sha1", "md5"}, checksums...)...)
+	n, err := io.Copy(io.MultiWriter(tmp, sums), rc)
+	if err != nil {
+		return false, 0, err
+	}
+	sha1sum, md5sum := sums.Sum("sha1"), sums.Sum("md5")
+	if a.SHA1 != "" && !strings.EqualFold(a.SHA1, sha1sum) {
+		return false, 0, fmt.Errorf("sha1 mismatch: source reports %s, content has %s", a.SHA1, sha1sum)
+	}
+	if a.MD5 != "" && !strings.EqualFold(a.MD5, md5sum) {
+		return false, 0, fmt.Errorf("md5 mismatch: source reports %s, content has %s", a.MD5, md5sum)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return false, 0, err
+	}
+
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if err := store.Put(ctx, key, tmp, contentType, n); err != nil {
+		return false, 0, err
+	}
+	for _, name := range checksums {
+		sum := sums.Sum(name)
+		if err := store.Put(ctx, key+"."+name, strings.NewReader(sum), "text/plain", int64(len(sum))); err != nil {
+			return false, 0, err
+		}
+	}
+	if len(a.Properties) > 0 {
+		if err := setProperties(ctx, store, key, a.Properties); err != nil {
+			return false, 0, err
+		}
+	}
+	return true, n, nil
+}
+
+// importHTTP holds what the REST API sources share.
+type importHTTP struct {
+	URL        string
+	Repository string
+	User       string
+	Password   string
+	// Token is sent as a bearer token instead of basic credentials.
+	Token  string
+	Client *http.Client
+}
+
+func (h importHTTP) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case h.Token != "":
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	case h.User != "":
+		req.SetBasicAuth(h.User, h.Password)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, importStatusError{URL: redactURL(rawURL), Status: resp.Status, Code: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+type importStatusError struct {
+	URL    string
+	Status string
+	Code   int
+}
+
+func (e importStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.URL, e.Status)
+}
+
+func (h importHTTP) getJSON(ctx context.Context, rawURL string, v any) error {
+	resp, err := h.get(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (h importHTTP) Open(ctx context.Context, a ImportAsset) (io.ReadCloser, error) {
+	resp, err := h.get(ctx, a.Location)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// NexusSource reads a Nexus Repository 3 repository through the
+// /service/rest/v1/assets API.
+type NexusSource struct {
+	importHTTP
+}
+
+func NewNexusSource(baseURL, repository, user, password string) *NexusSource {
+	return &NexusSource{importHTTP{URL: strings.TrimSuffix(baseURL, "/"), Repository: repository, User: user, Password: password}}
+}
+
+type nexusAssetPage struct {
+	Items []struct {
+		Path        string            `json:"path"`
+		DownloadURL string            `json:"downloadUrl"`
+		ContentType string            `json:"contentType"`
+		FileSize    int64             `json:"fileSize"`
+		Checksum    map[string]string `json:"checksum"`
+	} `json:"items"`
+	ContinuationToken string `json:"continuationToken"`
+}
+
+func (n *NexusSource) Walk(ctx context.Context, fn func(ImportAsset) error) error {
+	token := ""
+	for {
+		q := url.Values{"repository": {n.Repository}}
+		if token != "" {
+			q.Set("continuationToken", token)
+		}
+		var page nexusAssetPage
+		if err := n.getJSON(ctx, n.URL+"/service/rest/v1/assets?"+q.Encode(), &page); err != nil {
+			return err
+		}
+		for _, it := range page.Items {
+			err := fn(ImportAsset{
+				Path:        it.Path,
+				Location:    it.DownloadURL,
+				Size:        it.FileSize,
+				SHA1:        it.Checksum["sha1"],
+				MD5:         it.Checksum["md5"],
+				ContentType: it.ContentType,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if page.ContinuationToken == "" {
+			return nil
+		}
+		token = page.ContinuationToken
+	}
+}
+
+// ArtifactorySource reads an Artifactory repository through the storage
+// API. With Properties set, each file's properties are fetched as well,
+// one request per file.
+type ArtifactorySource struct {
+	importHTTP
+	Properties bool
+}
+
+func NewArtifactorySource(baseURL, repository, user, password, token string, properties bool) *ArtifactorySource {
+	return &ArtifactorySource{
+		importHTTP: importHTTP{URL: strings.TrimSuffix(baseURL, "/"), Repository: repository, User: user, Password: password, Token: token},
+		Properties: properties,
+	}
+}
+
+type artifactoryFileList struct {
+	Files []struct {
+		URI    string `json:"uri"`
+		Size   int64  `json:"size"`
+		Folder bool   `json:"folder"`
+		SHA1   string `json:"sha1"`
+	} `json:"files"`
+}
+
+func (a *ArtifactorySource) Walk(ctx context.Context, fn func(ImportAsset) error) error {
+	var list artifactoryFileList
+	if err := a.getJSON(ctx, a.URL+"/api/storage/"+a.Repository+"?list&deep=1&listFolders=0", &list); err != nil {
+		return err
+	}
+	for _, f := range list.Files {
+		if f.Folder {
+			continue
+		}
+		asset := ImportAsset{
+			Path:     strings.TrimPrefix(f.URI, "/"),
+			Location: a.URL + "/" + a.Repository + f.URI,
+			Size:     f.Size,
+			SHA1:     f.SHA1,
+		}
+		if a.Properties {
+			props, err := a.properties(ctx, f.URI)
+			if err != nil {
+				return err
+			}
+			asset.Properties = props
+		}
+		if err := fn(asset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// properties returns the properties of a file, joining multiple values
+// with ",". Artifactory answers 404 for files without properties.
+func (a *ArtifactorySource) properties(ctx context.Context, uri string) (map[string]string, error) {
+	var doc struct {
+		Properties map[string][]string `json:"properties"`
+	}
+	err := a.getJSON(ctx, a.URL+"/api/storage/"+a.Repository+uri+"?properties", &doc)
+	var se importStatusError
+	if errors.As(err, &se) && se.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]string, len(doc.Properties))
+	for k, v := range doc.Properties {
+		props[k] = strings.Join(v, ",")
+	}
+	return props, nil
+}
+
+// DirSource reads a repository exported to a directory tree, like an
+// Artifactory repository export or a copy of a Maven repository. A sibling
+// .sha1/.md5 file, if present, is used to verify the content.
+type DirSource struct {
+	Root string
+}
+
+func (d DirSource) Walk(ctx context.Context, fn func(ImportAsset) error) error {
+	return filepath.WalkDir(d.Root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Exports keep their own bookkeeping (.index, *.artifactory-metadata)
+		// next to the artifacts.
+		if e.IsDir() {
+			if p != d.Root && (strings.HasPrefix(e.Name(), ".") || strings.HasSuffix(e.Name(), ".artifactory-metadata")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Root, p)
+		if err != nil {
+			return err
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		return fn(ImportAsset{
+			Path:     filepath.ToSlash(rel),
+			Location: p,
+			Size:     info.Size(),
+			SHA1:     readChecksumFile(p + ".sha1"),
+			MD5:      readChecksumFile(p + ".md5"),
+		})
+	})
+}
+
+func (d DirSource) Open(ctx context.Context, a ImportAsset) (io.ReadCloser, error) {
+	return os.Open(a.Location)
+}
+
+// readChecksumFile returns the digest in a sidecar file, which may be
+// followed by a file name, or "" if there is none.
+func readChecksumFile(name string) string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
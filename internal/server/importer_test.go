@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestImportNexus(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); u != "admin" || p != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/service/rest/v1/assets":
+			if r.URL.Query().Get("repository") != "maven-releases" {
+				http.NotFound(w, r)
+				return
+			}
+			item := func(p, sha1 string) map[string]any {
+				return map[string]any{"path": p, "downloadUrl": srv.URL + "/repository/maven-releases/" + p, "contentType": "application/java-archive", "checksum": map[string]string{"sha1": sha1}}
+			}
+			if r.URL.Query().Get("continuationToken") == "" {
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"items":             []any{item("com/acme/lib/1.0/lib-1.0.jar", sha1Hex("jar")), item("com/acme/lib/1.0/lib-1.0.jar.sha1", sha1Hex("x"))},
+					"continuationToken": "next",
+				})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"items": []any{
+				item("com/acme/lib/1.0/lib-1.0.pom", sha1Hex("pom")),
+				item("com/acme/bad/1.0/bad-1.0.jar", sha1Hex("something else")),
+			}})
+		default:
+			fmt.Fprint(w, map[string]string{
+				"/repository/maven-releases/com/acme/lib/1.0/lib-1.0.jar": "jar",
+				"/repository/maven-releases/com/acme/lib/1.0/lib-1.0.pom": "pom",
+				"/repository/maven-releases/com/acme/bad/1.0/bad-1.0.jar": "corrupt",
+			}[r.URL.Path])
+		}
+	}))
+	defer srv.Close()
+
+	store := newMemStore()
+	ctx := context.Background()
+	_ = store.Put(ctx, "releases/com/acme/lib/1.0/lib-1.0.pom", strings.NewReader("already here"), "text/xml", 12)
+	stats, err := Import(ctx, store, NewNexusSource(srv.URL+"/", "maven-releases", "admin", "pw"), ImportOptions{Target: "/releases/", Workers: 2})
+	if err == nil || !strings.Contains(err.Error(), "sha1 mismatch") {
+		t.Fatalf("expected the corrupt asset to fail, got %v", err)
+	}
+	if stats != (ImportStats{Assets: 3, Imported: 1, Skipped: 1, Failed: 1, Bytes: 3}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if got := string(store.data["releases/com/acme/lib/1.0/lib-1.0.jar.sha1"].body); got != sha1Hex("jar") {
+		t.Fatalf("unexpected sha1 sidecar %q", got)
+	}
+	if string(store.data["releases/com/acme/lib/1.0/lib-1.0.pom"].body) != "already here" {
+		t.Fatal("expected an existing file to be kept without -overwrite")
+	}
+	if _, ok := store.data["releases/com/acme/bad/1.0/bad-1.0.jar"]; ok {
+		t.Fatal("expected the corrupt asset not to be stored")
+	}
+}
+
+func TestImportArtifactory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/storage/libs-release" && r.URL.Query().Has("list"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"files": []any{
+				map[string]any{"uri": "/org/x/1/x-1.jar", "size": 3, "sha1": sha1Hex("jar")},
+				map[string]any{"uri": "/org/x/maven-metadata.xml", "size": 4},
+			}})
+		case r.URL.Path == "/api/storage/libs-release/org/x/1/x-1.jar" && r.URL.Query().Has("properties"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"properties": map[string][]string{"build.number": {"42"}, "team": {"a", "b"}}})
+		case r.URL.Path == "/libs-release/org/x/1/x-1.jar":
+			fmt.Fprint(w, "jar")
+		case r.URL.Path == "/libs-release/org/x/maven-metadata.xml":
+			fmt.Fprint(w, "meta")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	store := newMemStore()
+	ctx := context.Background()
+	stats, err := Import(ctx, store, NewArtifactorySource(srv.URL, "libs-release", "", "", "tok", true), ImportOptions{Target: "releases"})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if stats.Imported != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	props, err := loadProperties(ctx, store, "releases/org/x/1/x-1.jar")
+	if err != nil || props["build.number"] != "42" || props["team"] != "a,b" {
+		t.Fatalf("unexpected properties %v %v", props, err)
+	}
+	if string(store.data["releases/org/x/maven-metadata.xml"].body) != "meta" {
+		t.Fatal("expected the metadata to be imported as is")
+	}
+}
+
+func TestImportDir(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"org/x/1/x-1.jar":                                "jar",
+		"org/x/1/x-1.jar.sha1":                           sha1Hex("jar") + "  x-1.jar\n",
+		"org/x/1/x-1.pom":                                "pom",
+		"org/x/1/x-1.pom.sha1":                           sha1Hex("not the pom"),
+		".index/nexus-maven-repository-index.gz":         "index",
+		"org/x/1/x-1.jar.artifactory-metadata/props.xml": "<properties/>",
+	}
+	for name, body := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := newMemStore()
+	stats, err := Import(context.Background(), store, DirSource{Root: root}, ImportOptions{Target: "releases"})
+	if err == nil || !strings.Contains(err.Error(), "x-1.pom") {
+		t.Fatalf("expected the pom with a wrong sidecar to fail, got %v", err)
+	}
+	if stats != (ImportStats{Assets: 2, Imported: 1, Failed: 1, Bytes: 3}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	for key := range store.data {
+		if strings.Contains(key, ".index") || strings.Contains(key, "artifactory-metadata") {
+			t.Fatalf("expected export bookkeeping to be skipped, got %s", key)
+		}
+	}
+
+	store = newMemStore()
+	stats, _ = Import(context.Background(), store, DirSource{Root: root}, ImportOptions{Target: "releases", DryRun: true})
+	if stats.Imported != 2 || len(store.data) != 0 {
+		t.Fatalf("expected a dry run to write nothing, got %+v and %d objects", stats, len(store.data))
+	}
+}
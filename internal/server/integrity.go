@@ -0,0 +1,317 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// maxIntegrityMismatches bounds the mismatches kept in one report; the
+// counter keeps counting past it.
+const maxIntegrityMismatches = 1000
+
+// integrityFailuresProperty counts consecutive failed verifications of an
+// artifact when Options.IntegrityQuarantineAfter is set.
+const integrityFailuresProperty = "integrity.failures"
+
+// plainETag matches ETags that are the MD5 of the object (single-part
+// uploads without SSE-KMS/SSE-C); other ETags cannot be compared.
+var plainETag = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// IntegrityMismatch is one digest that does not match the stored content.
+// Kind is "sha1" or "md5" (sidecar) or "etag" (S3 ETag).
+type IntegrityMismatch struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+type IntegrityReport struct {
+	Prefix     string              `json:"prefix"`
+	CheckETag  bool                `json:"checkETag"`
+	Running    bool                `json:"running"`
+	StartedAt  time.Time           `json:"startedAt"`
+	FinishedAt *time.Time          `json:"finishedAt,omitempty"`
+	Objects    int                 `json:"objects"`
+	Mismatched int                 `json:"mismatched"`
+	Mismatches []IntegrityMismatch `json:"mismatches"`
+	// Quarantined lists artifacts moved to quarantine by this run.
+	Quarantined []string `json:"quarantined"`
+	// ResumedFrom is set when a scheduled run continues one interrupted by
+	// a restart; artifacts up to this key were verified by that run.
+	ResumedFrom string `json:"resumedFrom,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type IntegrityRequest struct {
+	Prefix string `json:"prefix" example:"releases"`
+	// ETag defaults to true; disable it for buckets using SSE-KMS/SSE-C,
+	// whose ETags are not MD5 digests.
+	ETag *bool `json:"etag,omitempty"`
+}
+
+// integrityTask verifies prefix once; it is the "integrity-check" task.
+func (s *Server) integrityTask(ctx context.Context, prefix string, checkETag bool) error {
+	if !s.startIntegrityCheck(prefix, checkETag) {
+		s.logger.Warn("integrity check skipped; previous run still in progress")
+		return nil
+	}
+	return s.verifyIntegrity(ctx, prefix, checkETag)
+}
+
+// startIntegrityCheck installs a new running report unless one is running.
+func (s *Server) startIntegrityCheck(prefix string, checkETag bool) bool {
+	s.integrityMu.Lock()
+	defer s.integrityMu.Unlock()
+	if s.integrity != nil && s.integrity.Running {
+		return false
+	}
+	s.integrity = &IntegrityReport{
+		Prefix:      prefix,
+		CheckETag:   checkETag,
+		Running:     true,
+		StartedAt:   time.Now().UTC(),
+		Mismatches:  []IntegrityMismatch{},
+		Quarantined: []string{},
+	}
+	return true
+}
+
+// integrityReport returns a copy of the current or last report.
+func (s *Server) integrityReport() *IntegrityReport {
+	s.integrityMu.Lock()
+	defer s.integrityMu.Unlock()
+	if s.integrity == nil {
+		return nil
+	}
+	report := *s.integrity
+	report.Mismatches = slices.Clone(s.integrity.Mismatches)
+	report.Quarantined = slices.Clone(s.integrity.Quarantined)
+	return &report
+}
+
+// verifyIntegrity recomputes the configured digests of every artifact under
+// prefix and compares them to their sidecars and, with checkETag, the MD5 to
+// plain MD5 ETags. Missing sidecars are not mismatches; the checksum scanner
+// creates them. It fails when the walk fails or any digest does not match.
+// As a task it checkpoints every verified key and, after a restart, skips
+// the keys the interrupted run already covered (listings are sorted).
+func (s *Server) verifyIntegrity(ctx context.Context, prefix string, checkETag bool) error {
+	walkPrefix := prefix
+	if walkPrefix != "" {
+		walkPrefix += "/"
+	}
+	resume := taskResumePoint(ctx)
+	if resume != "" {
+		s.integrityMu.Lock()
+		s.integrity.ResumedFrom = resume
+		s.integrityMu.Unlock()
+	}
+	err := walkStore(ctx, s.store, walkPrefix, func(e storage.Entry) error {
+		if e.Type != "file" || isInternalPath(e.Path) || isChecksumPath(e.Path) {
+			return nil
+		}
+		if resume != "" && e.Path <= resume {
+			return nil
+		}
+		defer taskCheckpoint(ctx, e.Path)
+		mismatches, err := s.verifyObject(ctx, e.Path, checkETag)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				// Deleted while walking.
+				return nil
+			}
+			return err
+		}
+		quarantined, err := s.trackIntegrityFailures(ctx, e.Path, mismatches)
+		if err != nil {
+			return err
+		}
+
+		s.integrityMu.Lock()
+		if quarantined {
+			s.integrity.Quarantined = append(s.integrity.Quarantined, e.Path)
+		}
+		s.integrity.Objects++
+		s.integrity.Mismatched += len(mismatches)
+		for _, m := range mismatches {
+			if len(s.integrity.Mismatches) < maxIntegrityMismatches {
+				s.integrity.Mismatches = append(s.integrity.Mismatches, m)
+			}
+		}
+		s.integrityMu.Unlock()
+		for _, m := range mismatches {
+			s.logger.Warn("integrity mismatch", zap.String("key", m.Path), zap.String("kind", m.Kind),
+				zap.String("expected", m.Expected), zap.String("actual", m.Actual))
+		}
+		return nil
+	})
+
+	s.integrityMu.Lock()
+	finished := time.Now().UTC()
+	s.integrity.Running = false
+	s.integrity.FinishedAt = &finished
+	if err != nil {
+		s.integrity.Error = err.Error()
+	}
+	report := *s.integrity
+	s.integrityMu.Unlock()
+
+	if err != nil {
+		s.logger.Warn("integrity check failed", zap.Error(err))
+		return err
+	}
+	s.logger.Info("integrity check finished", zap.String("prefix", prefix), zap.Int("objects", report.Objects),
+		zap.Int("mismatched", report.Mismatched), zap.Int("quarantined", len(report.Quarantined)))
+	if report.Mismatched > 0 {
+		return fmt.Errorf("%d digests under %q do not match stored content", report.Mismatched, prefix)
+	}
+	return nil
+}
+
+// trackIntegrityFailures counts consecutive failed verifications of key in
+// its properties and quarantines it once the count reaches
+// integrityQuarantineAfter. A clean verification resets the count.
+func (s *Server) trackIntegrityFailures(ctx context.Context, key string, mismatches []IntegrityMismatch) (bool, error) {
+	if s.integrityQuarantineAfter <= 0 {
+		return false, nil
+	}
+	props, err := loadProperties(ctx, s.store, key)
+	if err != nil {
+		return false, err
+	}
+	failures, _ := strconv.Atoi(props[integrityFailuresProperty])
+	if len(mismatches) == 0 {
+		if failures == 0 {
+			return false, nil
+		}
+		return false, setProperties(ctx, s.store, key, map[string]string{integrityFailuresProperty: ""})
+	}
+
+	failures++
+	if failures < s.integrityQuarantineAfter {
+		return false, setProperties(ctx, s.store, key, map[string]string{integrityFailuresProperty: strconv.Itoa(failures)})
+	}
+	kinds := make([]string, 0, len(mismatches))
+	for _, m := range mismatches {
+		kinds = append(kinds, m.Kind)
+	}
+	reason := fmt.Sprintf("failed integrity verification %d times (%s mismatch)", failures, strings.Join(kinds, ", "))
+	if err := quarantine(ctx, s.store, key, reason); err != nil {
+		return false, err
+	}
+	s.logger.Warn("artifact quarantined", zap.String("key", key), zap.String("reason", reason))
+	return true, nil
+}
+
+func (s *Server) verifyObject(ctx context.Context, key string, checkETag bool) ([]IntegrityMismatch, error) {
+	resp, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// md5 is also what plain S3 ETags hold.
+	sums := storage.NewChecksums(append(slices.Clone(s.checksums), "md5")...)
+	if _, err := io.Copy(sums, resp.Body); err != nil {
+		return nil, fmt.Errorf("read %s: %w", key, err)
+	}
+
+	var mismatches []IntegrityMismatch
+	for _, kind := range s.checksums {
+		if expected := s.readChecksumSidecar(ctx, key+"."+kind); expected != "" && expected != sums.Sum(kind) {
+			mismatches = append(mismatches, IntegrityMismatch{Path: key, Kind: kind, Expected: expected, Actual: sums.Sum(kind)})
+		}
+	}
+	if etag := strings.ToLower(strings.Trim(aws.ToString(resp.ETag), `"`)); checkETag && plainETag.MatchString(etag) && etag != sums.Sum("md5") {
+		mismatches = append(mismatches, IntegrityMismatch{Path: key, Kind: "etag", Expected: etag, Actual: sums.Sum("md5")})
+	}
+	return mismatches, nil
+}
+
+func (s *Server) routeIntegrity(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGetIntegrity(w, r)
+	case http.MethodPost:
+		s.handleStartIntegrity(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary Start an integrity check
+// @Description Recomputes the CHECKSUM_ALGORITHMS digests of every artifact under prefix in the background and compares them to the stored sidecars, and the MD5 to the S3 ETag (single-part uploads only; set etag=false for SSE-KMS/SSE-C buckets). Poll GET for the report.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body IntegrityRequest false "Prefix to verify (default: whole bucket)"
+// @Success 202 {object} IntegrityReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/admin/integrity [post]
+func (s *Server) handleStartIntegrity(w http.ResponseWriter, r *http.Request) {
+	var req IntegrityRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAPIError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	prefix := strings.Trim(strings.TrimSpace(req.Prefix), "/")
+	if isInternalPath(prefix) || strings.Contains(prefix, "..") {
+		writeAPIError(w, "invalid prefix", http.StatusBadRequest)
+		return
+	}
+	checkETag := req.ETag == nil || *req.ETag
+	if !s.startIntegrityCheck(prefix, checkETag) {
+		writeAPIError(w, "an integrity check is already running", http.StatusConflict)
+		return
+	}
+	s.audit(r, "integrity.started", zap.String("prefix", prefix))
+	// Detached from the request so the check outlives it.
+	go func() {
+		if err := s.verifyIntegrity(context.WithoutCancel(r.Context()), prefix, checkETag); err != nil {
+			s.errors.ReportTask("integrity-check", "integrity check failed", err, map[string]string{"prefix": prefix})
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(s.integrityReport()); err != nil {
+		s.logger.Warn("encode integrity report", zap.Error(err))
+	}
+}
+
+// @Summary Integrity check report
+// @Description Progress of the running integrity check or the result of the last one, listing up to 1000 mismatches.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} IntegrityReport
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/admin/integrity [get]
+func (s *Server) handleGetIntegrity(w http.ResponseWriter, r *http.Request) {
+	report := s.integrityReport()
+	if report == nil {
+		writeAPIError(w, "no integrity check has run yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		s.logger.Warn("encode integrity report", zap.Error(err))
+	}
+}
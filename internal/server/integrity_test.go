@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+// etagStore reports fixed ETags, as S3 would for single-part uploads.
+type etagStore struct {
+	*memStore
+	etags map[string]string
+}
+
+func (e etagStore) Get(ctx context.Context, key string) (*s3.GetObjectOutput, error) {
+	out, err := e.memStore.Get(ctx, key)
+	if err == nil && e.etags[key] != "" {
+		out.ETag = aws.String(`"` + e.etags[key] + `"`)
+	}
+	return out, err
+}
+
+func TestIntegrityCheck(t *testing.T) {
+	ctx := context.Background()
+	store := etagStore{memStore: newMemStore(), etags: map[string]string{
+		// md5("hello")
+		"releases/a/1.0/a-1.0.jar": "5d41402abc4b2a76b9719d911017c592",
+		// multipart ETag: not comparable
+		"releases/b/1.0/b-1.0.jar": "0123-2",
+		"releases/c/1.0/c-1.0.jar": "ffffffffffffffffffffffffffffffff",
+	}}
+	for key, body := range map[string]string{
+		"releases/a/1.0/a-1.0.jar":      "hello",
+		"releases/a/1.0/a-1.0.jar.sha1": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		"releases/a/1.0/a-1.0.jar.md5":  "5d41402abc4b2a76b9719d911017c592",
+		"releases/b/1.0/b-1.0.jar":      "tampered",
+		"releases/b/1.0/b-1.0.jar.sha1": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		"releases/c/1.0/c-1.0.jar":      "hello",
+		"releases-old/x.jar":            "not under the prefix",
+		"releases-old/x.jar.sha1":       "0000000000000000000000000000000000000000",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/admin/integrity", strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodGet, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before any run, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, `{"prefix":"releases"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var report IntegrityReport
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rr := do(http.MethodGet, "")
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !report.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("integrity check did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if report.Objects != 3 || report.Mismatched != 2 || report.Error != "" || report.FinishedAt == nil {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	kinds := map[string]string{}
+	for _, m := range report.Mismatches {
+		kinds[m.Path] = m.Kind
+	}
+	if kinds["releases/b/1.0/b-1.0.jar"] != "sha1" || kinds["releases/c/1.0/c-1.0.jar"] != "etag" {
+		t.Fatalf("unexpected mismatches %+v", report.Mismatches)
+	}
+
+	if rr := do(http.MethodPost, `{"prefix":"__tokens__"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for internal prefix, got %d", rr.Code)
+	}
+}
+
+func TestIntegrityCheckSingleRun(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+	if !srv.startIntegrityCheck("", true) {
+		t.Fatal("expected first run to start")
+	}
+	if srv.startIntegrityCheck("", true) {
+		t.Fatal("expected concurrent run to be refused")
+	}
+}
+
+func TestIntegrityQuarantineAfterRepeatedFailures(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	for key, body := range map[string]string{
+		"releases/b/1.0/b-1.0.jar":      "tampered",
+		"releases/b/1.0/b-1.0.jar.sha1": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		"releases/a/1.0/a-1.0.jar":      "hello",
+		"releases/a/1.0/a-1.0.jar.sha1": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	// a-1.0.jar failed once before and is clean now.
+	if err := setProperties(ctx, store, "releases/a/1.0/a-1.0.jar", map[string]string{integrityFailuresProperty: "1"}); err != nil {
+		t.Fatalf("set properties: %v", err)
+	}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{IntegrityQuarantineAfter: 2})
+	run := func() *IntegrityReport {
+		t.Helper()
+		if !srv.startIntegrityCheck("releases", true) {
+			t.Fatal("integrity check already running")
+		}
+		_ = srv.verifyIntegrity(ctx, "releases", true)
+		return srv.integrityReport()
+	}
+
+	if report := run(); len(report.Quarantined) != 0 {
+		t.Fatalf("quarantined after one failure: %+v", report)
+	}
+	if props, _ := loadProperties(ctx, store, "releases/b/1.0/b-1.0.jar"); props[integrityFailuresProperty] != "1" {
+		t.Fatalf("expected failure count 1, got %+v", props)
+	}
+	if props, _ := loadProperties(ctx, store, "releases/a/1.0/a-1.0.jar"); props[integrityFailuresProperty] != "" {
+		t.Fatalf("expected clean run to reset the count, got %+v", props)
+	}
+
+	report := run()
+	if len(report.Quarantined) != 1 || report.Quarantined[0] != "releases/b/1.0/b-1.0.jar" {
+		t.Fatalf("expected b-1.0.jar quarantined, got %+v", report)
+	}
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/releases/b/1.0/b-1.0.jar", nil))
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "failed integrity verification 2 times (sha1 mismatch)") {
+		t.Fatalf("expected 409 with reason, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// LayoutPolicy rejects uploads to hosted repositories whose path is not a
+// Maven layout path (group/path/artifactId/version/artifactId-version*.ext).
+// A nil *LayoutPolicy accepts everything.
+type LayoutPolicy struct {
+	all   bool
+	repos map[string]bool
+}
+
+// ParseLayoutPolicy takes a comma-separated list of repositories (first path
+// segment) to enforce, or "*" for every path. An empty spec returns nil.
+func ParseLayoutPolicy(spec string) *LayoutPolicy {
+	var lp *LayoutPolicy
+	for _, repo := range strings.Split(spec, ",") {
+		repo = strings.Trim(strings.TrimSpace(repo), "/")
+		if repo == "" {
+			continue
+		}
+		if lp == nil {
+			lp = &LayoutPolicy{repos: make(map[string]bool)}
+		}
+		if repo == "*" {
+			lp.all = true
+			continue
+		}
+		lp.repos[repo] = true
+	}
+	return lp
+}
+
+// Check returns why key breaks the Maven layout, or nil when it is valid or
+// not covered by the policy.
+func (lp *LayoutPolicy) Check(key string) error {
+	if lp == nil {
+		return nil
+	}
+	rel := key
+	if repo, rest, ok := strings.Cut(key, "/"); ok && lp.repos[repo] {
+		rel = rest
+	} else if !lp.all {
+		return nil
+	}
+	return checkMavenLayout(rel)
+}
+
+func checkMavenLayout(p string) error {
+	segs := strings.Split(p, "/")
+	for _, seg := range segs {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("empty or relative path segment")
+		}
+	}
+	base := segs[len(segs)-1]
+	switch {
+	case strings.HasPrefix(base, "maven-metadata"):
+		if len(segs) < 2 {
+			return fmt.Errorf("maven-metadata.xml must live in a group, artifact or version directory")
+		}
+		return nil
+	case base == archetypeCatalogFile && len(segs) == 1:
+		return nil
+	}
+
+	if len(segs) < 4 {
+		return fmt.Errorf("expected groupId/artifactId/version/filename")
+	}
+	artifact, version := segs[len(segs)-3], segs[len(segs)-2]
+	prefix := artifact + "-" + strings.TrimSuffix(version, "-SNAPSHOT")
+	if !strings.HasPrefix(base, prefix) {
+		return fmt.Errorf("file name must start with %s", artifact+"-"+version)
+	}
+	rest := strings.TrimPrefix(base, prefix)
+	if !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "-") {
+		return fmt.Errorf("file name must start with %s", artifact+"-"+version)
+	}
+	if path.Ext(base) == "" {
+		return fmt.Errorf("file name has no extension")
+	}
+	return nil
+}
+
+func (s *Server) enforceLayout(w http.ResponseWriter, key string) bool {
+	if err := s.layout.Check(key); err != nil {
+		s.logger.Info("upload rejected by strict layout", zap.String("key", key), zap.Error(err))
+		writeAPIError(w, "path does not follow the Maven layout: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
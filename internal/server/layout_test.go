@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestLayoutPolicyCheck(t *testing.T) {
+	lp := ParseLayoutPolicy("releases, snapshots")
+	cases := []struct {
+		key string
+		ok  bool
+	}{
+		{"releases/com/acme/app/1.0/app-1.0.jar", true},
+		{"releases/com/acme/app/1.0/app-1.0-sources.jar", true},
+		{"releases/com/acme/app/1.0/app-1.0.jar.sha1", true},
+		{"releases/com/acme/app/maven-metadata.xml", true},
+		{"releases/com/acme/maven-metadata.xml.md5", true},
+		{"releases/archetype-catalog.xml", true},
+		{"snapshots/com/acme/app/1.0-SNAPSHOT/app-1.0-20240101.100000-1.jar", true},
+		{"snapshots/com/acme/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.pom", true},
+		{"releases/app-1.0.jar", false},
+		{"releases/com/acme/app/1.0/other-1.0.jar", false},
+		{"releases/com/acme/app/1.0/app-1.01.jar", false},
+		{"releases/com/acme/app/1.0/app-1.0", false},
+		{"releases/com//app/1.0/app-1.0.jar", false},
+		{"releases/maven-metadata.xml", false},
+		{"scratch/anything/goes", true},
+	}
+	for _, tc := range cases {
+		if err := lp.Check(tc.key); (err == nil) != tc.ok {
+			t.Fatalf("%s: ok=%v, got err=%v", tc.key, tc.ok, err)
+		}
+	}
+
+	if ParseLayoutPolicy("") != nil {
+		t.Fatalf("empty spec should disable the policy")
+	}
+	if err := ParseLayoutPolicy("*").Check("scratch/anything/goes"); err == nil {
+		t.Fatalf("* should enforce every path")
+	}
+}
+
+func TestStrictLayoutRejectsPut(t *testing.T) {
+	store := &mockStore{}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{StrictLayout: ParseLayoutPolicy("releases")})
+
+	req := httptest.NewRequest(http.MethodPut, "/releases/app.jar", strings.NewReader("data"))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if len(store.putKeys) != 0 {
+		t.Fatalf("rejected upload was stored: %v", store.putKeys)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/releases/com/acme/app/1.0/app-1.0.jar", strings.NewReader("data"))
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// leaderLeaseKey is the lease object replicas compete for.
+const leaderLeaseKey = taskHistoryPrefix + "leader.json"
+
+// conditionalStorage is implemented by stores supporting conditional writes
+// (S3 If-Match/If-None-Match).
+type conditionalStorage interface {
+	PutIfMatch(ctx context.Context, key string, body []byte, contentType, etag string) (string, error)
+}
+
+type leaderLease struct {
+	Holder    string    `json:"holder"`
+	RenewedAt time.Time `json:"renewedAt"`
+}
+
+// leaderElection is this replica's view of the lease. Only the election
+// loop touches the fields besides leader.
+type leaderElection struct {
+	id     string
+	ttl    time.Duration
+	leader atomic.Bool
+
+	// validUntil is when our lease lapses if it cannot be renewed.
+	validUntil time.Time
+	// observed is the ETag of a lease held by another replica and when it
+	// was first seen; it is taken over once unchanged for a whole TTL, so
+	// no two clocks are ever compared.
+	observedETag string
+	observedAt   time.Time
+}
+
+// instanceID names this process in lease and lock objects: the hostname
+// plus a random suffix, so restarts and replicas on one host differ.
+func instanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+func newLeaderElection(id string, ttl time.Duration) *leaderElection {
+	if id == "" {
+		id = instanceID()
+	}
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &leaderElection{id: id, ttl: ttl}
+}
+
+// isLeader reports whether this replica runs scheduled tasks. Without
+// leader election every replica does.
+func (s *Server) isLeader() bool {
+	return s.election == nil || s.election.leader.Load()
+}
+
+// runLeaderElection renews or competes for the lease every third of its TTL
+// until ctx is done.
+func (s *Server) runLeaderElection(ctx context.Context) {
+	ticker := time.NewTicker(s.election.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		was := s.isLeader()
+		s.campaign(ctx)
+		if !was && s.isLeader() {
+			// Pick up the history written by the previous leader.
+			s.loadTaskHistory(ctx)
+		}
+	}
+}
+
+// campaign makes one attempt to take or renew the lease. The lease is free
+// when missing, released, ours, or unchanged for a whole TTL; it is then
+// written with If-None-Match/If-Match so only one replica can win.
+func (s *Server) campaign(ctx context.Context) {
+	e := s.election
+	cs, ok := s.store.(conditionalStorage)
+	if !ok {
+		s.logger.Warn("leader election needs conditional writes; this replica will not run scheduled tasks")
+		s.setLeader(false, "")
+		return
+	}
+	now := time.Now()
+
+	var lease leaderLease
+	etag := ""
+	resp, err := s.store.Get(ctx, leaderLeaseKey)
+	switch {
+	case err == nil:
+		etag = aws.ToString(resp.ETag)
+		// An unreadable lease counts as released.
+		_ = json.NewDecoder(resp.Body).Decode(&lease)
+		resp.Body.Close()
+	case !storage.IsNotFound(err):
+		s.leaseError(err)
+		return
+	}
+
+	free := etag == "" || lease.Holder == "" || lease.Holder == e.id
+	if !free {
+		if etag != e.observedETag {
+			e.observedETag, e.observedAt = etag, now
+		}
+		if now.Sub(e.observedAt) < e.ttl {
+			s.setLeader(false, lease.Holder)
+			return
+		}
+		s.logger.Info("leader lease expired, taking over", zap.String("holder", lease.Holder))
+	}
+
+	body, err := json.Marshal(leaderLease{Holder: e.id, RenewedAt: now.UTC()})
+	if err != nil {
+		s.leaseError(err)
+		return
+	}
+	if _, err := cs.PutIfMatch(ctx, leaderLeaseKey, body, "application/json", etag); err != nil {
+		if storage.IsPreconditionFailed(err) {
+			// Another replica won the race.
+			s.setLeader(false, "")
+			return
+		}
+		s.leaseError(err)
+		return
+	}
+	e.validUntil = now.Add(e.ttl)
+	e.observedETag = ""
+	s.setLeader(true, e.id)
+}
+
+// leaseError keeps leadership until the lease would lapse, so a transient S3
+// error does not stop tasks, but never beyond the point where another
+// replica may take over.
+func (s *Server) leaseError(err error) {
+	s.logger.Warn("leader election failed", zap.Error(err))
+	if s.election.leader.Load() && time.Now().After(s.election.validUntil) {
+		s.setLeader(false, "")
+	}
+}
+
+func (s *Server) setLeader(leader bool, holder string) {
+	if s.election.leader.Swap(leader) != leader {
+		if leader {
+			s.logger.Info("acquired leadership; running scheduled tasks", zap.String("id", s.election.id))
+		} else {
+			s.logger.Info("not the leader; scheduled tasks run elsewhere", zap.String("id", s.election.id), zap.String("leader", holder))
+		}
+	}
+	if s.metrics != nil {
+		v := 0.0
+		if leader {
+			v = 1
+		}
+		s.metrics.Leader.Set(v)
+	}
+}
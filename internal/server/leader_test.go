@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+// condStore adds S3-style ETags and conditional writes to memStore.
+type condStore struct {
+	*memStore
+}
+
+func memETag(body []byte) string {
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (c condStore) Get(ctx context.Context, key string) (*s3.GetObjectOutput, error) {
+	out, err := c.memStore.Get(ctx, key)
+	if err == nil {
+		out.ETag = aws.String(memETag(c.data[key].body))
+	}
+	return out, err
+}
+
+func (c condStore) PutIfMatch(ctx context.Context, key string, body []byte, contentType, etag string) (string, error) {
+	current, exists := c.data[key]
+	if (etag == "" && exists) || (etag != "" && (!exists || memETag(current.body) != etag)) {
+		return "", &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	}
+	c.data[key] = memObj{body: body, contentType: contentType, modified: time.Now()}
+	return memETag(body), nil
+}
+
+func (c condStore) DeleteIfMatch(ctx context.Context, key, etag string) error {
+	current, exists := c.data[key]
+	if !exists {
+		return errors.New("NotFound")
+	}
+	if memETag(current.body) != etag {
+		return &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	}
+	delete(c.data, key)
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	ctx := context.Background()
+	store := condStore{newMemStore()}
+	const ttl = 50 * time.Millisecond
+	mA, mB := metrics.New(), metrics.New()
+	a := NewWithOptions(store, zaptest.NewLogger(t), mA, Options{LeaderElection: true, LeaderID: "a", LeaderLeaseTTL: ttl})
+	b := NewWithOptions(store, zaptest.NewLogger(t), mB, Options{LeaderElection: true, LeaderID: "b", LeaderLeaseTTL: ttl})
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if !a.isLeader() || b.isLeader() {
+		t.Fatalf("expected a to lead, a=%t b=%t", a.isLeader(), b.isLeader())
+	}
+	if testutil.ToFloat64(mA.Leader) != 1 || testutil.ToFloat64(mB.Leader) != 0 {
+		t.Fatal("unexpected leader gauges")
+	}
+
+	// Renewals keep the lease even after the TTL has passed.
+	time.Sleep(ttl)
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if !a.isLeader() || b.isLeader() {
+		t.Fatalf("expected a to keep the lease, a=%t b=%t", a.isLeader(), b.isLeader())
+	}
+
+	// a stops renewing: b takes over once the lease is unchanged for a TTL.
+	time.Sleep(ttl)
+	b.campaign(ctx)
+	if !b.isLeader() {
+		t.Fatal("expected b to take over the stale lease")
+	}
+	a.campaign(ctx)
+	if a.isLeader() {
+		t.Fatal("expected a to step down")
+	}
+
+	// Followers skip scheduled activations.
+	ran := false
+	if err := a.ScheduleTask(Task{Name: "job", Schedule: "@hourly", Run: func(ctx context.Context) error {
+		ran = true
+		return nil
+	}}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	a.runTask(ctx, a.tasks[0])
+	if ran {
+		t.Fatal("follower ran a scheduled task")
+	}
+}
+
+func TestLeaderElectionDisabled(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+	if !srv.isLeader() {
+		t.Fatal("without leader election every replica runs tasks")
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// LifecycleRule moves artifacts matching Pattern that have not been
+// downloaded for Idle to StorageClass and/or tags them (e.g. for a bucket
+// expiry rule keyed on the tag).
+type LifecycleRule struct {
+	Pattern      string
+	Idle         time.Duration
+	StorageClass string
+	Tags         map[string]string
+
+	re *regexp.Regexp
+}
+
+// lifecycleStorage is implemented by stores that can change storage classes
+// and object tags.
+type lifecycleStorage interface {
+	SetStorageClass(ctx context.Context, key, class string) error
+	AddTags(ctx context.Context, key string, tags map[string]string) (bool, error)
+}
+
+// ParseLifecycleRules parses rules separated by ";", each written as
+// "glob|idle|actions" where actions is a comma-separated list of
+// "class=STORAGE_CLASS" and "tag=key=value", e.g.
+// "central/**|720h|class=STANDARD_IA;snapshots/**|2160h|class=GLACIER_IR,tag=lifecycle=expire".
+func ParseLifecycleRules(spec string) ([]LifecycleRule, error) {
+	var rules []LifecycleRule
+	for _, raw := range strings.Split(spec, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		parts := strings.Split(raw, "|")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid lifecycle rule %q: expected glob|idle|actions", raw)
+		}
+		pattern := strings.Trim(strings.TrimSpace(parts[0]), "/")
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lifecycle rule %q: %w", raw, err)
+		}
+		idle, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || idle <= 0 {
+			return nil, fmt.Errorf("invalid lifecycle rule %q: idle must be a positive duration", raw)
+		}
+		rule := LifecycleRule{Pattern: pattern, Idle: idle, re: re}
+		for _, action := range strings.Split(parts[2], ",") {
+			kind, value, _ := strings.Cut(strings.TrimSpace(action), "=")
+			switch kind {
+			case "class":
+				rule.StorageClass = strings.ToUpper(value)
+			case "tag":
+				k, v, ok := strings.Cut(value, "=")
+				if !ok || k == "" {
+					return nil, fmt.Errorf("invalid lifecycle rule %q: tag must be key=value", raw)
+				}
+				if rule.Tags == nil {
+					rule.Tags = map[string]string{}
+				}
+				rule.Tags[k] = v
+			default:
+				return nil, fmt.Errorf("invalid lifecycle rule %q: unknown action %q", raw, action)
+			}
+		}
+		if rule.StorageClass == "" && len(rule.Tags) == 0 {
+			return nil, fmt.Errorf("invalid lifecycle rule %q: no action", raw)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matchLifecycleRule returns the matching rule with the longest idle period
+// already reached, so "30 days -> IA, 90 days -> Glacier" tiers work
+// regardless of rule order.
+func matchLifecycleRule(rules []LifecycleRule, key string, idle time.Duration) (LifecycleRule, bool) {
+	var best LifecycleRule
+	found := false
+	for _, r := range rules {
+		if idle < r.Idle || !r.re.MatchString(key) {
+			continue
+		}
+		if !found || r.Idle > best.Idle {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+type lifecycleResult struct {
+	Transitioned int
+	Tagged       int
+}
+
+// lifecycleTask applies rules once; it is the "lifecycle" task.
+func (s *Server) lifecycleTask(ctx context.Context, rules []LifecycleRule) error {
+	res, err := s.applyLifecycle(ctx, rules, time.Now())
+	if err != nil {
+		return err
+	}
+	s.logger.Info("lifecycle policies applied", zap.Int("transitioned", res.Transitioned), zap.Int("tagged", res.Tagged))
+	return nil
+}
+
+// applyLifecycle runs one pass. An artifact's idle time counts from its last
+// download, or from when it was first seen if it was never downloaded;
+// sidecars follow their artifact.
+func (s *Server) applyLifecycle(ctx context.Context, rules []LifecycleRule, now time.Time) (lifecycleResult, error) {
+	var res lifecycleResult
+	ls, ok := s.store.(lifecycleStorage)
+	if !ok {
+		return res, fmt.Errorf("storage backend does not support lifecycle actions")
+	}
+
+	s.downloads.persistMu.Lock()
+	defer s.downloads.persistMu.Unlock()
+	if err := s.persistDownloads(ctx); err != nil {
+		return res, fmt.Errorf("persist download stats: %w", err)
+	}
+
+	roots, err := s.store.List(ctx, "", 1000)
+	if err != nil {
+		return res, err
+	}
+	for _, root := range roots {
+		repo := strings.TrimSuffix(root.Name, "/")
+		if root.Type != "dir" || isInternalPath(repo) {
+			continue
+		}
+		stats, err := s.proxy.loadDownloadStats(ctx, repo)
+		if err != nil {
+			return res, err
+		}
+		firstSeen := false
+
+		err = walkStore(ctx, s.store, repo, func(e storage.Entry) error {
+			rel := strings.TrimPrefix(e.Path, repo+"/")
+			base := rel
+			if isChecksumPath(rel) {
+				base = strings.TrimSuffix(rel, path.Ext(rel))
+			}
+			last, ok := stats[base]
+			if !ok {
+				last = now
+				if e.LastModified != nil {
+					last = *e.LastModified
+				}
+				stats[base] = last
+				firstSeen = true
+			}
+			rule, ok := matchLifecycleRule(rules, path.Join(repo, base), now.Sub(last))
+			if !ok {
+				return nil
+			}
+
+			current := e.StorageClass
+			if current == "" {
+				current = "STANDARD"
+			}
+			if rule.StorageClass != "" && current != rule.StorageClass {
+				if err := ls.SetStorageClass(ctx, e.Path, rule.StorageClass); err != nil {
+					return fmt.Errorf("transition %s: %w", e.Path, err)
+				}
+				res.Transitioned++
+			}
+			if len(rule.Tags) > 0 {
+				changed, err := ls.AddTags(ctx, e.Path, rule.Tags)
+				if err != nil {
+					return fmt.Errorf("tag %s: %w", e.Path, err)
+				}
+				if changed {
+					res.Tagged++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+		if firstSeen {
+			if err := s.proxy.saveDownloadStats(ctx, repo, stats); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, nil
+}
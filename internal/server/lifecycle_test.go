@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+type lifecycleMemStore struct {
+	*memStore
+	classes map[string]string
+	tags    map[string]map[string]string
+}
+
+func (l *lifecycleMemStore) SetStorageClass(ctx context.Context, key, class string) error {
+	l.classes[key] = class
+	return nil
+}
+
+func (l *lifecycleMemStore) AddTags(ctx context.Context, key string, tags map[string]string) (bool, error) {
+	l.tags[key] = tags
+	return true, nil
+}
+
+func TestParseLifecycleRules(t *testing.T) {
+	rules, err := ParseLifecycleRules("central/**|720h|class=standard_ia; snapshots/**|2160h|tag=lifecycle=expire,class=GLACIER_IR")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rules) != 2 || rules[0].StorageClass != "STANDARD_IA" || rules[1].Tags["lifecycle"] != "expire" || rules[1].Idle != 2160*time.Hour {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+	for _, bad := range []string{"central/**|720h", "central/**|soon|class=IA", "central/**|1h|move=IA", "central/**|1h|tag=novalue"} {
+		if _, err := ParseLifecycleRules(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestApplyLifecycleUsesLastDownload(t *testing.T) {
+	store := &lifecycleMemStore{memStore: newMemStore(), classes: map[string]string{}, tags: map[string]map[string]string{}}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	for _, k := range []string{"central/old/a.jar", "central/old/a.jar.sha1", "central/mid/b.jar", "central/fresh/c.jar"} {
+		store.data[k] = memObj{body: []byte("x")}
+	}
+
+	now := time.Now()
+	day := 24 * time.Hour
+	if err := srv.proxy.saveDownloadStats(context.Background(), "central", map[string]time.Time{
+		"old/a.jar":   now.Add(-100 * day),
+		"mid/b.jar":   now.Add(-40 * day),
+		"fresh/c.jar": now.Add(-100 * day),
+	}); err != nil {
+		t.Fatalf("save stats: %v", err)
+	}
+	srv.downloads.Record("central/fresh/c.jar.sha1")
+
+	rules, err := ParseLifecycleRules("central/**|2160h|class=GLACIER_IR,tag=lifecycle=expire;central/**|720h|class=STANDARD_IA")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	res, err := srv.applyLifecycle(context.Background(), rules, now)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if res.Transitioned != 3 || res.Tagged != 2 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if store.classes["central/old/a.jar"] != "GLACIER_IR" || store.classes["central/old/a.jar.sha1"] != "GLACIER_IR" {
+		t.Fatalf("old artifact and sidecar should move to GLACIER_IR: %v", store.classes)
+	}
+	if store.classes["central/mid/b.jar"] != "STANDARD_IA" {
+		t.Fatalf("mid artifact should move to STANDARD_IA: %v", store.classes)
+	}
+	if _, ok := store.classes["central/fresh/c.jar"]; ok {
+		t.Fatalf("recently downloaded artifact must stay put")
+	}
+}
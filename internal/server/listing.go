@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/otoru/heimdall/internal/storage"
+)
+
+// handleDirectory serves a Maven-repository-style HTML index for GET/HEAD on
+// a path ending in "/", which Coursier/SBT scrape to discover versions.
+func (s *Server) handleDirectory(w http.ResponseWriter, r *http.Request, dir string) {
+	if isInternalPath(dir + "/") {
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	entries, handled, err := s.maybeListProxy(r.Context(), dir, 1000)
+	if err != nil {
+		s.writeError(w, "list proxy directory", err)
+		return
+	}
+	if !handled {
+		entries, err = s.listDirectory(r.Context(), dir)
+		if err != nil {
+			s.writeError(w, "list directory", err)
+			return
+		}
+	}
+	if len(entries) == 0 {
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	writeDirectoryHTML(w, "/"+dir+"/", entries)
+}
+
+// handlePackageDirectory is handleDirectory for the /packages group view,
+// merging hosted and proxy listings.
+func (s *Server) handlePackageDirectory(w http.ResponseWriter, r *http.Request, dir string) {
+	entries, err := s.listPackages(r.Context(), dir, 1000)
+	if err != nil {
+		s.writeError(w, "list packages directory", err)
+		return
+	}
+	if len(entries) == 0 {
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	writeDirectoryHTML(w, "/packages/"+dir+"/", entries)
+}
+
+// listDirectory returns every immediate child of dir, following pagination.
+func (s *Server) listDirectory(ctx context.Context, dir string) ([]storage.Entry, error) {
+	var entries []storage.Entry
+	token := ""
+	for {
+		page, err := s.store.ListPage(ctx, dir, token, 1000)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page.Entries...)
+		if page.NextToken == "" {
+			return entries, nil
+		}
+		token = page.NextToken
+	}
+}
+
+// writeDirectoryHTML renders entries like the Maven Central index pages:
+// relative hrefs, directories with a trailing slash and a "../" parent link.
+func writeDirectoryHTML(w http.ResponseWriter, title string, entries []storage.Entry) {
+	entries = slices.Clone(entries)
+	slices.SortFunc(entries, func(a, b storage.Entry) int { return strings.Compare(a.Name, b.Name) })
+
+	var b strings.Builder
+	title = html.EscapeString(title)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head><title>Index of %s</title></head>\n<body>\n<h1>Index of %s</h1>\n<hr/>\n<pre><a href=\"../\">../</a>\n", title, title)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name, "/")
+		if name == "" || isInternalPath(name+"/") {
+			continue
+		}
+		href := url.PathEscape(name)
+		size := "-"
+		if e.Type == "file" {
+			size = fmt.Sprint(e.Size)
+		} else {
+			name += "/"
+			href += "/"
+		}
+		modified := "-"
+		if e.LastModified != nil {
+			modified = e.LastModified.UTC().Format("2006-01-02 15:04")
+		}
+		label := html.EscapeString(name)
+		pad := max(1, 50-len(name))
+		fmt.Fprintf(&b, "<a href=\"%s\" title=\"%s\">%s</a>%s%s%*s\n", href, label, label, strings.Repeat(" ", pad), modified, 16, size)
+	}
+	b.WriteString("</pre>\n<hr/>\n</body>\n</html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDirectoryListingHTML(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	for _, key := range []string{
+		"releases/com/acme/app/1.0/app-1.0.jar",
+		"releases/com/acme/app/1.1/app-1.1.jar",
+		"releases/com/acme/app/maven-metadata.xml",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader("data"), "application/octet-stream", 4); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+
+	for _, target := range []string{"/releases/com/acme/app/", "/packages/releases/com/acme/app/"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", target, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Fatalf("%s: unexpected content type %q", target, ct)
+		}
+		body := rr.Body.String()
+		for _, want := range []string{`<a href="../">../</a>`, `<a href="1.0/" title="1.0/">1.0/</a>`, `<a href="1.1/"`, `<a href="maven-metadata.xml"`} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("%s: listing missing %s:\n%s", target, want, body)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/releases/com/acme/none/", nil)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for empty directory, got %d", rr.Code)
+	}
+}
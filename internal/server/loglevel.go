@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type LogLevelPayload struct {
+	Level string `json:"level" example:"debug"`
+}
+
+// @Summary Get or change the log level
+// @Description GET returns the current level; PUT switches it at runtime (debug, info, warn, error). Changes are not persisted.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body LogLevelPayload false "New level (PUT only)"
+// @Success 200 {object} LogLevelPayload
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/admin/loglevel [get]
+// @Router /api/v1/admin/loglevel [put]
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	if s.level == nil {
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "GET, PUT")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	before := s.level.Level()
+	s.level.ServeHTTP(w, r)
+	if after := s.level.Level(); after != before {
+		s.audit(r, "loglevel.changed", zap.String("from", before.String()), zap.String("to", after.String()))
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestLogLevelEndpoint(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	srv := NewWithOptions(newMemStore(), zaptest.NewLogger(t), metrics.New(), Options{AuthUser: "admin", AuthPassword: "secret", LogLevel: &level})
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"debug"}`))
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if level.Level() != zapcore.DebugLevel {
+		t.Fatalf("expected debug level, got %s", level.Level())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"loud"}`))
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown level, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// artifactMetadata is the artifact-level maven-metadata.xml listing every
+// version of one artifact.
+type artifactMetadata struct {
+	XMLName    xml.Name           `xml:"metadata"`
+	GroupID    string             `xml:"groupId"`
+	ArtifactID string             `xml:"artifactId"`
+	Versioning artifactVersioning `xml:"versioning"`
+}
+
+type artifactVersioning struct {
+	Latest      string   `xml:"latest,omitempty"`
+	Release     string   `xml:"release,omitempty"`
+	Versions    []string `xml:"versions>version"`
+	LastUpdated string   `xml:"lastUpdated"`
+}
+
+type MetadataRebuildResponse struct {
+	// Rebuilt lists the maven-metadata.xml keys that were written.
+	Rebuilt []string `json:"rebuilt"`
+}
+
+// @Summary Rebuild maven-metadata.xml
+// @Description Regenerates the artifact-level maven-metadata.xml (and checksums) of every artifact under path from the versions actually stored, to repair drift after manual bucket edits or partial migrations.
+// @Tags admin
+// @Produce json
+// @Param path query string true "Artifact directory or prefix (e.g. releases/com/acme/app)"
+// @Success 200 {object} MetadataRebuildResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/admin/metadata/rebuild [post]
+func (s *Server) handleMetadataRebuild(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	prefix := strings.Trim(r.URL.Query().Get("path"), "/")
+	if prefix == "" || isInternalPath(prefix) {
+		writeAPIError(w, "path is required", http.StatusBadRequest)
+		return
+	}
+
+	rebuilt, err := s.rebuildMetadata(r.Context(), prefix, time.Now())
+	if err != nil {
+		s.writeError(w, "rebuild metadata", err)
+		return
+	}
+	if len(rebuilt) == 0 {
+		writeAPIError(w, "no artifacts found under path", http.StatusNotFound)
+		return
+	}
+	s.audit(r, "metadata.rebuilt", zap.String("prefix", prefix), zap.Strings("keys", rebuilt))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MetadataRebuildResponse{Rebuilt: rebuilt}); err != nil {
+		s.logger.Warn("encode metadata rebuild", zap.Error(err))
+	}
+}
+
+// rebuildMetadata rewrites maven-metadata.xml for every artifact directory
+// below prefix and returns the keys written.
+func (s *Server) rebuildMetadata(ctx context.Context, prefix string, now time.Time) ([]string, error) {
+	versions := make(map[string]map[string]bool)
+	err := walkStore(ctx, s.store, prefix, func(e storage.Entry) error {
+		base := path.Base(e.Path)
+		if isChecksumPath(base) || strings.HasSuffix(base, ".asc") || strings.HasPrefix(base, "maven-metadata") {
+			return nil
+		}
+		if _, _, version, ok := parseCoordinates(e.Path); ok {
+			dir := path.Dir(path.Dir(e.Path))
+			if versions[dir] == nil {
+				versions[dir] = make(map[string]bool)
+			}
+			versions[dir][version] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make([]string, 0, len(versions))
+	for dir := range versions {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+
+	var rebuilt []string
+	for _, dir := range dirs {
+		meta := artifactMetadata{ArtifactID: path.Base(dir)}
+		for v := range versions[dir] {
+			meta.Versioning.Versions = append(meta.Versioning.Versions, v)
+		}
+		slices.SortFunc(meta.Versioning.Versions, compareVersions)
+		for _, v := range meta.Versioning.Versions {
+			meta.Versioning.Latest = v
+			if !strings.HasSuffix(v, "-SNAPSHOT") {
+				meta.Versioning.Release = v
+			}
+		}
+		meta.Versioning.LastUpdated = now.UTC().Format("20060102150405")
+
+		key := dir + "/" + mavenMetadataFile
+		unlock, err := s.lockMetadata(ctx, key)
+		if err != nil {
+			return rebuilt, err
+		}
+		meta.GroupID = s.metadataGroupID(ctx, dir, meta.Versioning.Latest)
+		body, err := xml.MarshalIndent(meta, "", "  ")
+		if err == nil {
+			err = s.putXML(ctx, key, body)
+		}
+		unlock()
+		if err != nil {
+			return rebuilt, err
+		}
+		rebuilt = append(rebuilt, key)
+	}
+	return rebuilt, nil
+}
+
+// metadataGroupID reads the groupId from the POM of version, then from the
+// current metadata, and falls back to the directory layout, which may still
+// include a repository name.
+func (s *Server) metadataGroupID(ctx context.Context, dir, version string) string {
+	artifact := path.Base(dir)
+	if resp, err := s.store.Get(ctx, path.Join(dir, version, artifact+"-"+version+".pom")); err == nil {
+		var pom pomProject
+		err := xml.NewDecoder(resp.Body).Decode(&pom)
+		resp.Body.Close()
+		if err == nil {
+			if g := strings.TrimSpace(pom.GroupID); g != "" {
+				return g
+			}
+			if g := strings.TrimSpace(pom.Parent.GroupID); g != "" {
+				return g
+			}
+		}
+	}
+	if resp, err := s.store.Get(ctx, dir+"/"+mavenMetadataFile); err == nil {
+		var meta artifactMetadata
+		err := xml.NewDecoder(resp.Body).Decode(&meta)
+		resp.Body.Close()
+		if err == nil && meta.GroupID != "" {
+			return meta.GroupID
+		}
+	}
+	return strings.ReplaceAll(path.Dir(dir), "/", ".")
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestMetadataRebuild(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	files := map[string]string{
+		"releases/com/acme/app/1.0/app-1.0.jar":                     "jar",
+		"releases/com/acme/app/1.0/app-1.0.pom":                     `<project><groupId>com.acme</groupId><artifactId>app</artifactId><version>1.0</version></project>`,
+		"releases/com/acme/app/1.10/app-1.10.jar":                   "jar",
+		"releases/com/acme/app/1.2/app-1.2.jar":                     "jar",
+		"releases/com/acme/app/1.2/app-1.2.jar.sha1":                "abc",
+		"releases/com/acme/app/2.0-SNAPSHOT/app-2.0-20240101.1.jar": "jar",
+		"releases/com/acme/app/maven-metadata.xml":                  `<metadata><groupId>com.acme</groupId><artifactId>app</artifactId><versioning><versions><version>0.9</version></versions></versioning></metadata>`,
+		"releases/com/acme/lib/3.0/lib-3.0.jar":                     "jar",
+	}
+	for key, body := range files {
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/metadata/rebuild?path=releases/com/acme", nil)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp MetadataRebuildResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !slices.Equal(resp.Rebuilt, []string{"releases/com/acme/app/maven-metadata.xml", "releases/com/acme/lib/maven-metadata.xml"}) {
+		t.Fatalf("unexpected rebuilt keys %v", resp.Rebuilt)
+	}
+
+	var meta artifactMetadata
+	if err := xml.Unmarshal(store.data["releases/com/acme/app/maven-metadata.xml"].body, &meta); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if meta.GroupID != "com.acme" || meta.ArtifactID != "app" {
+		t.Fatalf("unexpected coordinates %+v", meta)
+	}
+	if !slices.Equal(meta.Versioning.Versions, []string{"1.0", "1.2", "1.10", "2.0-SNAPSHOT"}) {
+		t.Fatalf("unexpected versions %v", meta.Versioning.Versions)
+	}
+	if meta.Versioning.Latest != "2.0-SNAPSHOT" || meta.Versioning.Release != "1.10" {
+		t.Fatalf("unexpected latest/release %+v", meta.Versioning)
+	}
+	if _, ok := store.data["releases/com/acme/app/maven-metadata.xml.sha1"]; !ok {
+		t.Fatalf("metadata checksum not written")
+	}
+
+	var lib artifactMetadata
+	if err := xml.Unmarshal(store.data["releases/com/acme/lib/maven-metadata.xml"].body, &lib); err != nil {
+		t.Fatalf("decode lib metadata: %v", err)
+	}
+	if lib.GroupID != "releases.com.acme" {
+		t.Fatalf("expected layout-derived groupId, got %q", lib.GroupID)
+	}
+}
+
+func TestMetadataRebuildNotFound(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/metadata/rebuild?path=releases/none", nil)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// metadataLockPrefix holds one lock object per metadata key being written.
+const metadataLockPrefix = "__locks__/"
+
+// metadataLockPoll is how often a busy lock is checked again.
+const metadataLockPoll = 100 * time.Millisecond
+
+// errMetadataLocked is returned when a lock could not be taken in time.
+var errMetadataLocked = errors.New("metadata is being updated by another writer")
+
+type metadataLock struct {
+	Owner      string    `json:"owner"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+}
+
+// metadataLocker takes locks in the bucket with conditional writes. Holders
+// only write a few small objects; a lock left unchanged for ttl belongs to
+// a writer that died and is taken over.
+type metadataLocker struct {
+	owner   string
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+func newMetadataLocker(timeout time.Duration) *metadataLocker {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &metadataLocker{owner: instanceID(), ttl: 10 * time.Second, timeout: timeout}
+}
+
+// lockMetadata takes the lock of key and returns the function releasing it.
+// Without Options.MetadataLocks, or on a store without conditional writes,
+// it does nothing.
+func (s *Server) lockMetadata(ctx context.Context, key string) (func(), error) {
+	l := s.metaLocks
+	cs, ok := s.store.(conditionalStorage)
+	if l == nil || !ok {
+		return func() {}, nil
+	}
+	lockKey := metadataLockPrefix + key
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	var observedETag string
+	var observedAt time.Time
+	match := ""
+	for {
+		body, err := json.Marshal(metadataLock{Owner: l.owner, AcquiredAt: time.Now().UTC()})
+		if err != nil {
+			return nil, err
+		}
+		etag, err := cs.PutIfMatch(ctx, lockKey, body, "application/json", match)
+		if err == nil {
+			return func() { s.unlockMetadata(lockKey, etag) }, nil
+		}
+		if !storage.IsPreconditionFailed(err) {
+			return nil, s.metadataLockError(key, err)
+		}
+
+		// Busy: retry at once if the lock was released or went stale,
+		// otherwise after a short wait. Staleness compares ETags over time
+		// rather than the holder's clock.
+		match = ""
+		resp, err := s.store.Get(ctx, lockKey)
+		switch {
+		case err == nil:
+			current := aws.ToString(resp.ETag)
+			resp.Body.Close()
+			if current != observedETag {
+				observedETag, observedAt = current, time.Now()
+			} else if time.Since(observedAt) >= l.ttl {
+				s.logger.Warn("taking over stale metadata lock", zap.String("key", key))
+				match = current
+				continue
+			}
+		case storage.IsNotFound(err):
+			continue
+		default:
+			return nil, s.metadataLockError(key, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, s.metadataLockError(key, ctx.Err())
+		case <-time.After(metadataLockPoll):
+		}
+	}
+}
+
+func (s *Server) metadataLockError(key string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errMetadataLocked
+	}
+	return fmt.Errorf("lock %s: %w", key, err)
+}
+
+// conditionalDeleter is implemented by stores that can delete an object only
+// while it still has a given ETag (S3 DeleteObject with If-Match).
+type conditionalDeleter interface {
+	DeleteIfMatch(ctx context.Context, key, etag string) error
+}
+
+// unlockMetadata deletes the lock object unless another writer took it
+// over in the meantime. With a conditional delete the check and the delete
+// are one request; otherwise a takeover between them can still be lost.
+func (s *Server) unlockMetadata(lockKey, etag string) {
+	ctx := context.Background()
+	if cd, ok := s.store.(conditionalDeleter); ok {
+		err := cd.DeleteIfMatch(ctx, lockKey, etag)
+		if err != nil && !storage.IsPreconditionFailed(err) && !storage.IsNotFound(err) {
+			s.logger.Warn("release metadata lock", zap.String("key", lockKey), zap.Error(err))
+		}
+		return
+	}
+	resp, err := s.store.Get(ctx, lockKey)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			s.logger.Warn("release metadata lock", zap.String("key", lockKey), zap.Error(err))
+		}
+		return
+	}
+	current := aws.ToString(resp.ETag)
+	resp.Body.Close()
+	if current != etag {
+		return
+	}
+	if err := s.store.Delete(ctx, lockKey); err != nil && !storage.IsNotFound(err) {
+		s.logger.Warn("release metadata lock", zap.String("key", lockKey), zap.Error(err))
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestMetadataLock(t *testing.T) {
+	ctx := context.Background()
+	store := condStore{newMemStore()}
+	a := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{MetadataLocks: true, MetadataLockTimeout: 50 * time.Millisecond})
+	b := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{MetadataLocks: true, MetadataLockTimeout: 50 * time.Millisecond})
+	const key = "releases/com/acme/lib/maven-metadata.xml"
+
+	unlock, err := a.lockMetadata(ctx, key)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if _, ok := store.data[metadataLockPrefix+key]; !ok {
+		t.Fatal("expected a lock object in the bucket")
+	}
+	if _, err := b.lockMetadata(ctx, key); !errors.Is(err, errMetadataLocked) {
+		t.Fatalf("expected the second writer to time out, got %v", err)
+	}
+
+	unlock()
+	if _, ok := store.data[metadataLockPrefix+key]; ok {
+		t.Fatal("expected the lock object to be deleted")
+	}
+	unlockB, err := b.lockMetadata(ctx, key)
+	if err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+
+	// b dies holding the lock: a takes it over once it is stale, and b's
+	// late release leaves a's lock alone.
+	a.metaLocks.ttl = 20 * time.Millisecond
+	a.metaLocks.timeout = time.Second
+	unlockA, err := a.lockMetadata(ctx, key)
+	if err != nil {
+		t.Fatalf("take over stale lock: %v", err)
+	}
+	unlockB()
+	if _, ok := store.data[metadataLockPrefix+key]; !ok {
+		t.Fatal("stale holder released the new lock")
+	}
+	unlockA()
+}
+
+func TestPutMetadataWhileLocked(t *testing.T) {
+	store := condStore{newMemStore()}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{MetadataLocks: true, MetadataLockTimeout: 50 * time.Millisecond})
+	const key = "releases/com/acme/lib/maven-metadata.xml"
+
+	put := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/"+key, bytes.NewReader([]byte("<metadata/>")))
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	unlock, err := srv.lockMetadata(context.Background(), key)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if rec := put(); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After while locked, got %d", rec.Code)
+	}
+	unlock()
+	if rec := put(); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := store.data[key+".sha1"]; !ok {
+		t.Fatal("expected the checksum to be written")
+	}
+	if _, ok := store.data[metadataLockPrefix+key]; ok {
+		t.Fatal("expected the lock to be released")
+	}
+}
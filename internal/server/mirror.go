@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// mirrorMaxDepth bounds how deep the mirror task descends below a prefix,
+// in case an upstream listing links back into itself.
+const mirrorMaxDepth = 32
+
+// mirrorRun is the state of one run of the mirror task.
+type mirrorRun struct {
+	server *Server
+	// resume is the last directory a previous, interrupted run finished.
+	resume      string
+	directories int
+	stats       WarmupStats
+	failures    int
+	firstErr    error
+}
+
+// parseMirrorPath splits "proxy/path/**" into the proxy name and the
+// directory below it ("" for the whole upstream).
+func parseMirrorPath(spec string) (name, dir string, err error) {
+	spec = strings.TrimSuffix(strings.TrimSuffix(strings.Trim(spec, "/"), "**"), "*")
+	name, dir, _ = strings.Cut(strings.Trim(spec, "/"), "/")
+	if name == "" || strings.Contains(dir, "..") || strings.ContainsAny(dir, "*?") {
+		return "", "", fmt.Errorf("invalid mirror path %q, want <proxy>/<path>[/**]", spec)
+	}
+	if dir != "" {
+		dir += "/"
+	}
+	return name, dir, nil
+}
+
+// mirrorTask crawls the upstream directory listings below each of paths
+// ("<proxy>/<path>/**") and caches every file in them; it is the "mirror"
+// task. Directories are visited in sorted order and recorded as
+// checkpoints, so a run interrupted by a restart resumes after the last
+// finished one.
+func (s *Server) mirrorTask(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no mirror paths configured (MIRROR_PATHS)")
+	}
+	roots := make([]string, 0, len(paths))
+	for _, spec := range paths {
+		name, dir, err := parseMirrorPath(spec)
+		if err != nil {
+			return err
+		}
+		pr, found, err := s.proxy.findByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !found || !pr.caches() {
+			return fmt.Errorf("mirror path %q: no caching proxy named %q", spec, name)
+		}
+		roots = append(roots, name+"/"+dir)
+	}
+	sort.Strings(roots)
+
+	m := &mirrorRun{server: s, resume: taskResumePoint(ctx)}
+	for _, root := range roots {
+		if err := m.crawl(ctx, root, 0); err != nil {
+			return err
+		}
+	}
+	s.logger.Info("mirror finished",
+		zap.Strings("paths", roots),
+		zap.Int("directories", m.directories),
+		zap.Int("requested", m.stats.Requested),
+		zap.Int("cached", m.stats.Cached),
+		zap.Int("fetched", m.stats.Fetched),
+		zap.Int("missing", m.stats.Missing),
+		zap.Int("failed", m.stats.Failed))
+	if m.firstErr != nil {
+		return fmt.Errorf("%d directories or files failed, first: %w", m.failures, m.firstErr)
+	}
+	return nil
+}
+
+// crawl caches the files listed in dir (proxy-prefixed, ending with "/")
+// and descends into its subdirectories. Listing and fetch failures are
+// recorded and the crawl goes on; only cancellation stops it.
+func (m *mirrorRun) crawl(ctx context.Context, dir string, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s := m.server
+	entries, _, err := s.proxy.ListPath(ctx, dir, 0)
+	if err != nil {
+		m.fail(fmt.Errorf("list %s: %w", dir, err), 1)
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	var files, dirs []string
+	for _, e := range entries {
+		if e.Name == "./" || strings.Contains(e.Name, "..") {
+			continue
+		}
+		switch {
+		case e.Type == "dir":
+			dirs = append(dirs, dir+e.Name)
+		case !isChecksumPath(e.Name):
+			// .sha1 and .md5 are written by the cache next to each file.
+			files = append(files, dir+e.Name)
+		}
+	}
+
+	if m.resume == "" || dir > m.resume {
+		m.directories++
+		var stats WarmupStats
+		if len(files) > 0 {
+			stats, err = s.warmCache(ctx, files, nil)
+		}
+		m.stats.Requested += stats.Requested
+		m.stats.Cached += stats.Cached
+		m.stats.Fetched += stats.Fetched
+		m.stats.Missing += stats.Missing
+		m.stats.Failed += stats.Failed
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			m.fail(fmt.Errorf("%s: %w", dir, err), stats.Failed)
+		}
+		taskCheckpoint(ctx, dir)
+	}
+
+	for _, sub := range dirs {
+		if depth+1 > mirrorMaxDepth {
+			s.logger.Warn("mirror depth limit reached", zap.String("dir", sub), zap.Int("limit", mirrorMaxDepth))
+			continue
+		}
+		if err := m.crawl(ctx, sub, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mirrorRun) fail(err error, n int) {
+	m.failures += n
+	if m.firstErr == nil {
+		m.firstErr = err
+	}
+	m.server.logger.Warn("mirror", zap.Error(err))
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestParseMirrorPath(t *testing.T) {
+	for spec, want := range map[string]string{
+		"central/org/springframework/**": "central org/springframework/",
+		"/central/org/acme/":             "central org/acme/",
+		"central":                        "central ",
+	} {
+		name, dir, err := parseMirrorPath(spec)
+		if err != nil || name+" "+dir != want {
+			t.Fatalf("%s: got %q %q %v", spec, name, dir, err)
+		}
+	}
+	for _, spec := range []string{"", "**", "central/../x", "central/org/*/lib"} {
+		if _, _, err := parseMirrorPath(spec); err == nil {
+			t.Fatalf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestMirrorTask(t *testing.T) {
+	files := map[string]string{
+		"/org/acme/lib/1.0/lib-1.0.jar":      "jar",
+		"/org/acme/lib/1.0/lib-1.0.jar.sha1": "upstream sha1",
+		"/org/acme/lib/1.0/lib-1.0.pom":      "pom",
+		"/org/acme/lib/maven-metadata.xml":   "metadata",
+		"/org/acme/tool/2.0/tool-2.0.jar":    "jar",
+		"/org/other/x/1/x-1.jar":             "not mirrored",
+	}
+	var listed []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, ok := files[r.URL.Path]; ok {
+			fmt.Fprint(w, body)
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		listed = append(listed, r.URL.Path)
+		children := map[string]bool{}
+		for p := range files {
+			if rest, ok := strings.CutPrefix(p, r.URL.Path); ok {
+				if dir, _, nested := strings.Cut(rest, "/"); nested {
+					children[dir+"/"] = true
+				} else {
+					children[rest] = true
+				}
+			}
+		}
+		if len(children) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><body><a href="../">../</a>`)
+		for c := range children {
+			fmt.Fprintf(w, `<a href="%s">%s</a>`, c, c)
+		}
+		fmt.Fprint(w, `</body></html>`)
+	}))
+	defer upstream.Close()
+
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	ctx := context.Background()
+	if err := srv.proxy.Add(ctx, Proxy{Name: "central", URL: upstream.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.mirrorTask(ctx, []string{"central/org/acme/**"}); err != nil {
+		t.Fatalf("mirror: %v", err)
+	}
+	for _, key := range []string{
+		"central/org/acme/lib/1.0/lib-1.0.jar",
+		"central/org/acme/lib/1.0/lib-1.0.pom",
+		"central/org/acme/lib/maven-metadata.xml",
+		"central/org/acme/tool/2.0/tool-2.0.jar",
+	} {
+		if _, ok := store.data[key]; !ok {
+			t.Fatalf("expected %s to be mirrored", key)
+		}
+	}
+	if sha1 := string(store.data["central/org/acme/lib/1.0/lib-1.0.jar.sha1"].body); sha1 == "upstream sha1" {
+		t.Fatal("expected the sidecar to be computed by the cache, not fetched")
+	}
+	if _, ok := store.data["central/org/other/x/1/x-1.jar"]; ok {
+		t.Fatal("expected paths outside the prefix to be left alone")
+	}
+	if listed[0] != "/org/acme/" {
+		t.Fatalf("expected the crawl to start at the prefix, got %v", listed)
+	}
+
+	if err := srv.mirrorTask(ctx, []string{"nope/org"}); err == nil {
+		t.Fatal("expected an error for an unknown proxy")
+	}
+}
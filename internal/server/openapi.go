@@ -0,0 +1,287 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	_ "github.com/otoru/heimdall/internal/docs"
+	httpSwagger "github.com/swaggo/http-swagger"
+	"github.com/swaggo/swag"
+	"go.uber.org/zap"
+)
+
+// openAPIVersion is the version of the document served at /openapi.json.
+const openAPIVersion = "3.0.3"
+
+// Exposure modes for the Swagger UI and the OpenAPI document.
+const (
+	SwaggerPublic   = "public"
+	SwaggerAuth     = "auth"
+	SwaggerDisabled = "disabled"
+)
+
+// registerDocs mounts /swagger/ and /openapi.json according to the
+// configured SwaggerUI mode.
+func (s *Server) registerDocs(mux *http.ServeMux) {
+	ui := httpSwagger.Handler(httpSwagger.URL("/openapi.json"))
+	switch s.swaggerUI {
+	case SwaggerDisabled:
+		return
+	case SwaggerAuth:
+		mux.HandleFunc("/swagger/", s.authMiddleware(ui.ServeHTTP))
+		mux.HandleFunc("/openapi.json", s.authMiddleware(s.handleOpenAPI))
+	default:
+		mux.Handle("/swagger/", ui)
+		mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	}
+}
+
+// handleOpenAPI serves the API description as OpenAPI 3, converted from the
+// Swagger 2 document generated by swag so handler annotations stay the single
+// source of truth.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	doc, err := swag.ReadDoc()
+	if err == nil {
+		var out []byte
+		if out, err = convertToOpenAPI3([]byte(doc)); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(out)
+			return
+		}
+	}
+	s.logger.Error("render openapi document", zap.Error(err))
+	writeAPIError(w, "openapi document unavailable", http.StatusInternalServerError)
+}
+
+// convertToOpenAPI3 rewrites a Swagger 2.0 document as OpenAPI 3.0: body and
+// formData parameters become requestBody, response schemas move under
+// content, definitions become components.schemas and basic auth becomes an
+// http security scheme.
+func convertToOpenAPI3(doc []byte) ([]byte, error) {
+	var v2 map[string]any
+	if err := json.Unmarshal(doc, &v2); err != nil {
+		return nil, err
+	}
+
+	basePath, _ := v2["basePath"].(string)
+	if basePath == "" {
+		basePath = "/"
+	}
+	out := map[string]any{
+		"openapi": openAPIVersion,
+		"info":    v2["info"],
+		"servers": []any{map[string]any{"url": basePath}},
+	}
+	if tags, ok := v2["tags"]; ok {
+		out["tags"] = tags
+	}
+	if security, ok := v2["security"]; ok {
+		out["security"] = security
+	}
+
+	consumes := stringList(v2["consumes"], "application/json")
+	produces := stringList(v2["produces"], "application/json")
+	paths := map[string]any{}
+	for path, rawItem := range asMap(v2["paths"]) {
+		item := map[string]any{}
+		for method, rawOp := range asMap(rawItem) {
+			op := asMap(rawOp)
+			if op == nil {
+				// path-level parameters
+				item[method] = convertParameters(rawOp)
+				continue
+			}
+			item[method] = convertOperation(op, consumes, produces)
+		}
+		paths[path] = item
+	}
+	out["paths"] = paths
+
+	components := map[string]any{}
+	if defs := asMap(v2["definitions"]); len(defs) > 0 {
+		components["schemas"] = defs
+	}
+	if secDefs := asMap(v2["securityDefinitions"]); len(secDefs) > 0 {
+		schemes := map[string]any{}
+		for name, raw := range secDefs {
+			schemes[name] = convertSecurityScheme(asMap(raw))
+		}
+		components["securitySchemes"] = schemes
+	}
+	if len(components) > 0 {
+		out["components"] = components
+	}
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.ReplaceAll(b, []byte(`"#/definitions/`), []byte(`"#/components/schemas/`)), nil
+}
+
+func convertOperation(op map[string]any, consumes, produces []string) map[string]any {
+	consumes = stringList(op["consumes"], consumes...)
+	produces = stringList(op["produces"], produces...)
+
+	out := map[string]any{}
+	for k, v := range op {
+		switch k {
+		case "consumes", "produces", "parameters", "responses":
+		default:
+			out[k] = v
+		}
+	}
+
+	var params []any
+	form := map[string]any{}
+	var formRequired []string
+	for _, raw := range asSlice(op["parameters"]) {
+		p := asMap(raw)
+		switch p["in"] {
+		case "body":
+			body := map[string]any{"content": mediaTypes(consumes, p["schema"])}
+			if d, ok := p["description"]; ok {
+				body["description"] = d
+			}
+			if req, ok := p["required"].(bool); ok && req {
+				body["required"] = true
+			}
+			out["requestBody"] = body
+		case "formData":
+			name, _ := p["name"].(string)
+			form[name] = parameterSchema(p)
+			if req, ok := p["required"].(bool); ok && req {
+				formRequired = append(formRequired, name)
+			}
+		default:
+			params = append(params, convertParameter(p))
+		}
+	}
+	if len(form) > 0 {
+		schema := map[string]any{"type": "object", "properties": form}
+		if len(formRequired) > 0 {
+			schema["required"] = formRequired
+		}
+		out["requestBody"] = map[string]any{"content": mediaTypes(consumes, schema)}
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	responses := map[string]any{}
+	for code, raw := range asMap(op["responses"]) {
+		resp := asMap(raw)
+		r := map[string]any{}
+		desc, _ := resp["description"].(string)
+		if desc == "" {
+			if n, err := strconv.Atoi(code); err == nil {
+				desc = http.StatusText(n)
+			}
+		}
+		r["description"] = desc
+		if schema, ok := resp["schema"]; ok {
+			types := produces
+			// Every error is a problem document, see writeProblem.
+			if n, _ := strconv.Atoi(code); n >= 400 {
+				types = []string{"application/problem+json"}
+			}
+			r["content"] = mediaTypes(types, schema)
+		}
+		if headers := asMap(resp["headers"]); len(headers) > 0 {
+			h := map[string]any{}
+			for name, rawHeader := range headers {
+				header := asMap(rawHeader)
+				conv := map[string]any{"schema": parameterSchema(header)}
+				if d, ok := header["description"]; ok {
+					conv["description"] = d
+				}
+				h[name] = conv
+			}
+			r["headers"] = h
+		}
+		responses[code] = r
+	}
+	out["responses"] = responses
+	return out
+}
+
+func convertParameters(raw any) []any {
+	var out []any
+	for _, p := range asSlice(raw) {
+		out = append(out, convertParameter(asMap(p)))
+	}
+	return out
+}
+
+func convertParameter(p map[string]any) map[string]any {
+	out := map[string]any{"schema": parameterSchema(p)}
+	for _, k := range []string{"name", "in", "description", "required"} {
+		if v, ok := p[k]; ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// parameterSchema collects the schema keywords Swagger 2 puts directly on
+// non-body parameters and headers.
+func parameterSchema(p map[string]any) map[string]any {
+	schema := map[string]any{}
+	for _, k := range []string{"type", "format", "items", "enum", "default", "minimum", "maximum", "pattern", "example"} {
+		if v, ok := p[k]; ok {
+			schema[k] = v
+		}
+	}
+	return schema
+}
+
+func convertSecurityScheme(def map[string]any) map[string]any {
+	switch def["type"] {
+	case "basic":
+		out := map[string]any{"type": "http", "scheme": "basic"}
+		if d, ok := def["description"]; ok {
+			out["description"] = d
+		}
+		return out
+	default:
+		return def
+	}
+}
+
+func mediaTypes(types []string, schema any) map[string]any {
+	content := map[string]any{}
+	for _, t := range types {
+		content[t] = map[string]any{"schema": schema}
+	}
+	return content
+}
+
+func stringList(v any, fallback ...string) []string {
+	var out []string
+	for _, s := range asSlice(v) {
+		if str, ok := s.(string); ok {
+			out = append(out, str)
+		}
+	}
+	if len(out) == 0 {
+		return fallback
+	}
+	return out
+}
+
+func asMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
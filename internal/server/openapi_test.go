@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestConvertToOpenAPI3(t *testing.T) {
+	v2 := `{
+		"swagger": "2.0",
+		"info": {"title": "t", "version": "1"},
+		"basePath": "/",
+		"paths": {
+			"/things/{name}": {
+				"put": {
+					"consumes": ["application/json"],
+					"produces": ["application/json"],
+					"parameters": [
+						{"name": "name", "in": "path", "required": true, "type": "string"},
+						{"name": "body", "in": "body", "required": true, "schema": {"$ref": "#/definitions/server.Thing"}}
+					],
+					"responses": {
+						"200": {"description": "OK", "schema": {"$ref": "#/definitions/server.Thing"}},
+						"400": {"description": "Bad Request", "schema": {"$ref": "#/definitions/server.ErrorResponse"}}
+					}
+				}
+			}
+		},
+		"definitions": {"server.Thing": {"type": "object"}, "server.ErrorResponse": {"type": "object"}},
+		"securityDefinitions": {"BasicAuth": {"type": "basic"}}
+	}`
+	out, err := convertToOpenAPI3([]byte(v2))
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if strings.Contains(string(out), "#/definitions/") {
+		t.Fatalf("definitions refs left in %s", out)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				In     string         `json:"in"`
+				Schema map[string]any `json:"schema"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Required bool                      `json:"required"`
+				Content  map[string]map[string]any `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]struct {
+				Content map[string]map[string]any `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas         map[string]any               `json:"schemas"`
+			SecuritySchemes map[string]map[string]string `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	op := doc.Paths["/things/{name}"]["put"]
+	if doc.OpenAPI != openAPIVersion {
+		t.Fatalf("unexpected version %q", doc.OpenAPI)
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].In != "path" || op.Parameters[0].Schema["type"] != "string" {
+		t.Fatalf("unexpected parameters %+v", op.Parameters)
+	}
+	if !op.RequestBody.Required || op.RequestBody.Content["application/json"]["schema"] == nil {
+		t.Fatalf("body parameter not converted: %+v", op.RequestBody)
+	}
+	errSchema, _ := op.Responses["400"].Content["application/problem+json"]["schema"].(map[string]any)
+	if errSchema["$ref"] != "#/components/schemas/server.ErrorResponse" {
+		t.Fatalf("unexpected error schema %v", errSchema)
+	}
+	if doc.Components.Schemas["server.Thing"] == nil || doc.Components.SecuritySchemes["BasicAuth"]["scheme"] != "basic" {
+		t.Fatalf("unexpected components %+v", doc.Components)
+	}
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	srv := New(&mockStore{}, zaptest.NewLogger(t), metrics.New(), "", "")
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+	var doc struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.OpenAPI != openAPIVersion {
+		t.Fatalf("unexpected version %q", doc.OpenAPI)
+	}
+	for _, p := range []string{"/api/v1/proxies", "/api/v1/catalog", "/packages/{artifactPath}", "/api/v1/admin/relocations"} {
+		if doc.Paths[p] == nil {
+			t.Fatalf("path %s missing", p)
+		}
+	}
+	if doc.Components.Schemas["server.ErrorResponse"] == nil {
+		t.Fatalf("ErrorResponse schema missing")
+	}
+}
+
+func TestAPIErrorsAreJSON(t *testing.T) {
+	srv := New(&mockStore{}, zaptest.NewLogger(t), metrics.New(), "", "")
+	req := httptest.NewRequest(http.MethodPost, "/proxies", strings.NewReader("{"))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	var body ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body is not JSON: %q", rr.Body.String())
+	}
+	if body.Status != http.StatusBadRequest || body.Error != "invalid json" {
+		t.Fatalf("unexpected error body %+v", body)
+	}
+	if rr.Header().Get("Content-Type") != "application/problem+json" || body.Type != "about:blank" || body.Title != "Bad Request" || body.Detail != "invalid json" {
+		t.Fatalf("not a problem document: %q %+v", rr.Header().Get("Content-Type"), body)
+	}
+	if body.RequestID == "" || body.RequestID != rr.Header().Get("X-Request-ID") {
+		t.Fatalf("request id %q, header %q", body.RequestID, rr.Header().Get("X-Request-ID"))
+	}
+}
+
+// timeoutStore times out every read.
+type timeoutStore struct{ *memStore }
+
+func (timeoutStore) Get(context.Context, string) (*s3.GetObjectOutput, error) {
+	return nil, storage.TimeoutError{Op: "GetObject"}
+}
+
+func (timeoutStore) Head(context.Context, string) (*s3.HeadObjectOutput, error) {
+	return nil, storage.TimeoutError{Op: "HeadObject"}
+}
+
+func TestArtifactErrorsAreProblems(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+	get := func(target string) (*httptest.ResponseRecorder, ErrorResponse) {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		var body ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: error body is not JSON: %q", target, rr.Body.String())
+		}
+		return rr, body
+	}
+
+	rr, body := get("/releases/com/acme/app/1.0/app-1.0.jar")
+	if rr.Code != http.StatusNotFound || body.Status != http.StatusNotFound || body.Title != "Not Found" || rr.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("missing artifact: %d %+v", rr.Code, body)
+	}
+
+	srv = New(timeoutStore{newMemStore()}, zaptest.NewLogger(t), metrics.New(), "", "")
+	rr, body = get("/releases/com/acme/app/1.0/app-1.0.jar")
+	if rr.Code != http.StatusGatewayTimeout || body.Type != problemStorageTimeout {
+		t.Fatalf("storage timeout: %d %+v", rr.Code, body)
+	}
+}
+
+func TestSwaggerUIModes(t *testing.T) {
+	cases := []struct {
+		mode   string
+		auth   bool
+		status int
+	}{
+		{SwaggerPublic, false, http.StatusOK},
+		{SwaggerAuth, false, http.StatusUnauthorized},
+		{SwaggerAuth, true, http.StatusOK},
+		{SwaggerDisabled, true, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		srv := NewWithOptions(newMemStore(), zaptest.NewLogger(t), metrics.New(), Options{AuthUser: "u", AuthPassword: "p", SwaggerUI: tc.mode})
+		for _, target := range []string{"/openapi.json", "/swagger/index.html"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tc.auth {
+				req.SetBasicAuth("u", "p")
+			}
+			rr := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rr, req)
+			if rr.Code != tc.status {
+				t.Fatalf("%s %s (auth=%v): expected %d, got %d", tc.mode, target, tc.auth, tc.status, rr.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordCacheTTL bounds how long a successful password check is reused, so
+// bcrypt and argon2 do not run again for every request of a build.
+const passwordCacheTTL = time.Minute
+
+// CheckPasswordHash reports whether stored, the configured admin password,
+// is plaintext or a hash verifyPassword can use. Malformed hashes fail here
+// at startup instead of rejecting every login.
+func CheckPasswordHash(stored string) error {
+	switch {
+	case isBcrypt(stored):
+		if _, err := bcrypt.Cost([]byte(stored)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+	case isArgon2(stored):
+		if _, err := parseArgon2(stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyPassword compares given against stored, which may be plaintext, a
+// bcrypt hash ($2a$/$2b$/$2y$) or an argon2 PHC string ($argon2id$/$argon2i$).
+func verifyPassword(stored, given string) bool {
+	switch {
+	case isBcrypt(stored):
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(given)) == nil
+	case isArgon2(stored):
+		ok, err := verifyArgon2(stored, given)
+		return err == nil && ok
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(given)) == 1
+}
+
+func isBcrypt(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+func isArgon2(stored string) bool {
+	return strings.HasPrefix(stored, "$argon2id$") || strings.HasPrefix(stored, "$argon2i$")
+}
+
+// passwordCache remembers recent successful verifications, keyed on a
+// SHA-256 of the stored and given passwords so no plaintext is kept.
+// Failures are never cached.
+type passwordCache struct {
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]time.Time
+}
+
+// verify is verifyPassword, answering from the cache for passwords that
+// matched within passwordCacheTTL.
+func (c *passwordCache) verify(stored, given string) bool {
+	key := sha256.Sum256([]byte(stored + "\x00" + given))
+	now := time.Now()
+	c.mu.Lock()
+	expires, ok := c.verified[key]
+	c.mu.Unlock()
+	if ok && now.Before(expires) {
+		return true
+	}
+	if !verifyPassword(stored, given) {
+		return false
+	}
+	c.mu.Lock()
+	if c.verified == nil {
+		c.verified = make(map[[sha256.Size]byte]time.Time)
+	}
+	for k, e := range c.verified {
+		if now.After(e) {
+			delete(c.verified, k)
+		}
+	}
+	c.verified[key] = now.Add(passwordCacheTTL)
+	c.mu.Unlock()
+	return true
+}
+
+// argon2Hash is a parsed argon2 PHC string.
+type argon2Hash struct {
+	id         bool
+	memory     uint32
+	iterations uint32
+	threads    uint8
+	salt       []byte
+	hash       []byte
+}
+
+// parseArgon2 parses a PHC-formatted hash such as
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>.
+func parseArgon2(encoded string) (argon2Hash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return argon2Hash{}, fmt.Errorf("invalid argon2 hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Hash{}, fmt.Errorf("unsupported argon2 version")
+	}
+	h := argon2Hash{id: parts[1] == "argon2id"}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.threads); err != nil {
+		return argon2Hash{}, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+	if h.memory == 0 || h.iterations == 0 || h.threads == 0 {
+		return argon2Hash{}, fmt.Errorf("invalid argon2 parameters: m, t and p must be positive")
+	}
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2Hash{}, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	if h.hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return argon2Hash{}, fmt.Errorf("invalid argon2 hash: %w", err)
+	}
+	if len(h.hash) == 0 {
+		return argon2Hash{}, fmt.Errorf("invalid argon2 hash: empty")
+	}
+	return h, nil
+}
+
+// verifyArgon2 checks password against a PHC-formatted argon2 hash.
+func verifyArgon2(encoded, password string) (bool, error) {
+	h, err := parseArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+	var got []byte
+	if h.id {
+		got = argon2.IDKey([]byte(password), h.salt, h.iterations, h.memory, h.threads, uint32(len(h.hash)))
+	} else {
+		got = argon2.Key([]byte(password), h.salt, h.iterations, h.memory, h.threads, uint32(len(h.hash)))
+	}
+	return subtle.ConstantTimeCompare(got, h.hash) == 1, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("s3cret"), salt, 1, 64*1024, 2, 32)
+	argonHash := fmt.Sprintf("$argon2id$v=%d$m=65536,t=1,p=2$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+
+	for name, stored := range map[string]string{
+		"plain":  "s3cret",
+		"bcrypt": string(bcryptHash),
+		"argon2": argonHash,
+	} {
+		if !verifyPassword(stored, "s3cret") {
+			t.Errorf("%s: expected match", name)
+		}
+		if verifyPassword(stored, "wrong") {
+			t.Errorf("%s: expected mismatch", name)
+		}
+	}
+}
+
+func TestCheckPasswordHashRejectsZeroArgon2Parameters(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	hash := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	for _, params := range []string{"m=65536,t=1,p=0", "m=0,t=1,p=2", "m=65536,t=0,p=2"} {
+		stored := fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params, salt, hash)
+		if err := CheckPasswordHash(stored); err == nil {
+			t.Errorf("%s: expected an error", params)
+		}
+		if verifyPassword(stored, "s3cret") {
+			t.Errorf("%s: expected mismatch", params)
+		}
+	}
+	for _, stored := range []string{"plain", "$2b$04$invalid"} {
+		if err := CheckPasswordHash(stored); (err == nil) != (stored == "plain") {
+			t.Errorf("%s: unexpected result %v", stored, err)
+		}
+	}
+}
+
+func TestPasswordCacheKeepsOnlySuccesses(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	var c passwordCache
+	if c.verify(string(bcryptHash), "wrong") || len(c.verified) != 0 {
+		t.Fatal("a failed verification must not be cached")
+	}
+	if !c.verify(string(bcryptHash), "s3cret") || len(c.verified) != 1 {
+		t.Fatal("expected the successful verification to be cached")
+	}
+	for k := range c.verified {
+		c.verified[k] = time.Now().Add(-time.Second)
+	}
+	if !c.verify(string(bcryptHash), "s3cret") {
+		t.Fatal("expected an expired entry to be verified again")
+	}
+	if c.verify(string(bcryptHash), "wrong") {
+		t.Fatal("a cached success must not admit another password")
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ArchiveListing is the content of a stored archive.
+type ArchiveListing struct {
+	Path    string         `json:"path" example:"releases/com/acme/lib/1.0/lib-1.0.jar"`
+	Entries []ArchiveEntry `json:"entries"`
+}
+
+// @Summary List the entries of an archive
+// @Description Lists the files inside a stored archive (jar, war, ear, aar, zip, tar, tar.gz, tgz) with their size and CRC-32, sorted by name, so clients can check whether a class or resource is present without downloading it. q keeps only the entries whose name contains it. Directories are left out. Archives are limited to 2 GiB. Deploy tokens need read scope for the path.
+// @Tags artifacts
+// @Produce json
+// @Param artifactPath path string true "Archive path"
+// @Param q query string false "Substring the entry names must contain"
+// @Success 200 {object} ArchiveListing
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/peek/{artifactPath} [get]
+func (s *Server) handlePeek(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := archiveKey(w, r, "path", strings.TrimPrefix(r.URL.Path, apiV1+"/peek/"))
+	if key == "" {
+		return
+	}
+	entries, err := s.listArchive(r.Context(), key)
+	if err != nil {
+		s.writeArchiveError(w, key, err)
+		return
+	}
+	if q := r.URL.Query().Get("q"); q != "" {
+		entries = slices.DeleteFunc(entries, func(e ArchiveEntry) bool { return !strings.Contains(e.Name, q) })
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ArchiveListing{Path: key, Entries: entries}); err != nil {
+		s.logger.Warn("encode archive listing", zap.Error(err))
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHandlePeek(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	_ = tw.WriteHeader(&tar.Header{Name: "./bin/", Typeflag: tar.TypeDir, Mode: 0o755})
+	_ = tw.WriteHeader(&tar.Header{Name: "./bin/tool", Typeflag: tar.TypeReg, Mode: 0o755, Size: 2})
+	_, _ = tw.Write([]byte("v1"))
+	_ = tw.Close()
+	_ = gz.Close()
+
+	for key, data := range map[string][]byte{
+		"releases/lib/1.0/lib-1.0.jar": testZip(t, map[string]string{
+			"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\n",
+			"com/acme/Util.class":  "v1",
+			"com/acme/Old.class":   "old",
+		}),
+		"releases/tool/1.0/tool-1.0.tgz": tgz.Bytes(),
+		"releases/tool/1.0/tool-1.0.tar": []byte("not a tar archive, just text that is long enough"),
+	} {
+		if err := store.Put(ctx, key, bytes.NewReader(data), "application/octet-stream", int64(len(data))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	get := func(target string) (*httptest.ResponseRecorder, ArchiveListing) {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/peek/"+target, nil))
+		var l ArchiveListing
+		_ = json.Unmarshal(rr.Body.Bytes(), &l)
+		return rr, l
+	}
+
+	rr, l := get("releases/lib/1.0/lib-1.0.jar")
+	if rr.Code != http.StatusOK || len(l.Entries) != 3 || l.Entries[0].Name != "META-INF/MANIFEST.MF" || l.Entries[1].CRC32 == "" {
+		t.Fatalf("jar: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr, l := get("releases/lib/1.0/lib-1.0.jar?q=Util"); rr.Code != http.StatusOK || len(l.Entries) != 1 || l.Entries[0].Size != 2 {
+		t.Fatalf("q=Util: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr, l := get("releases/tool/1.0/tool-1.0.tgz"); rr.Code != http.StatusOK || len(l.Entries) != 1 || l.Entries[0].Name != "bin/tool" || l.Entries[0].CRC32 != "6962ccb5" {
+		t.Fatalf("tgz: %d %s", rr.Code, rr.Body.String())
+	}
+
+	for target, want := range map[string]int{
+		"releases/tool/1.0/tool-1.0.tar": http.StatusUnsupportedMediaType,
+		"releases/lib/1.0/missing.jar":   http.StatusNotFound,
+		"__properties__/lib.jar":         http.StatusBadRequest,
+		"releases/lib/1.0/":              http.StatusBadRequest,
+	} {
+		if rr, _ := get(target); rr.Code != want {
+			t.Errorf("%s: got %d, want %d", target, rr.Code, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/otoru/heimdall/internal/storage"
+)
+
+const (
+	pluginDescriptorPath = "META-INF/maven/plugin.xml"
+	mavenMetadataFile    = "maven-metadata.xml"
+)
+
+// pluginDescriptor is the subset of META-INF/maven/plugin.xml needed to map a
+// goal prefix to a plugin.
+type pluginDescriptor struct {
+	Name       string `xml:"name"`
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	GoalPrefix string `xml:"goalPrefix"`
+}
+
+type pluginEntry struct {
+	Name       string `xml:"name,omitempty"`
+	Prefix     string `xml:"prefix"`
+	ArtifactID string `xml:"artifactId"`
+}
+
+// groupMetadata is the groupId-level maven-metadata.xml Maven reads to
+// resolve `mvn prefix:goal` invocations.
+type groupMetadata struct {
+	XMLName xml.Name      `xml:"metadata"`
+	Plugins []pluginEntry `xml:"plugins>plugin"`
+}
+
+// readPluginDescriptor returns the plugin descriptor of a Maven plugin jar;
+// ok is false for any other file.
+func readPluginDescriptor(r io.ReaderAt, size int64) (pluginDescriptor, bool) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return pluginDescriptor{}, false
+	}
+	f, err := zr.Open(pluginDescriptorPath)
+	if err != nil {
+		return pluginDescriptor{}, false
+	}
+	defer f.Close()
+
+	var desc pluginDescriptor
+	if err := xml.NewDecoder(f).Decode(&desc); err != nil {
+		return pluginDescriptor{}, false
+	}
+	if desc.GroupID == "" || desc.ArtifactID == "" || desc.GoalPrefix == "" {
+		return pluginDescriptor{}, false
+	}
+	return desc, true
+}
+
+// pluginMetadataKey is the group-level metadata key for a plugin jar stored at
+// key, or "" when key does not follow the layout the descriptor declares.
+func pluginMetadataKey(key string, desc pluginDescriptor) string {
+	artifactDir := path.Dir(path.Dir(key))
+	if path.Base(artifactDir) != desc.ArtifactID {
+		return ""
+	}
+	groupDir := path.Dir(artifactDir)
+	if !strings.HasSuffix("/"+groupDir, "/"+strings.ReplaceAll(desc.GroupID, ".", "/")) {
+		return ""
+	}
+	return groupDir + "/" + mavenMetadataFile
+}
+
+// updatePluginMetadata registers the goal prefix of an uploaded Maven plugin
+// jar in its groupId-level maven-metadata.xml.
+func (s *Server) updatePluginMetadata(ctx context.Context, key string, jar io.ReaderAt, size int64) error {
+	if !strings.HasSuffix(key, ".jar") {
+		return nil
+	}
+	desc, ok := readPluginDescriptor(jar, size)
+	if !ok {
+		return nil
+	}
+	metaKey := pluginMetadataKey(key, desc)
+	if metaKey == "" {
+		return nil
+	}
+
+	s.pluginMetaMu.Lock()
+	defer s.pluginMetaMu.Unlock()
+	unlock, err := s.lockMetadata(ctx, metaKey)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var meta groupMetadata
+	resp, err := s.store.Get(ctx, metaKey)
+	switch {
+	case err == nil:
+		err = xml.NewDecoder(resp.Body).Decode(&meta)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	case !storage.IsNotFound(err):
+		return err
+	}
+
+	entry := pluginEntry{Name: desc.Name, Prefix: desc.GoalPrefix, ArtifactID: desc.ArtifactID}
+	i := slices.IndexFunc(meta.Plugins, func(p pluginEntry) bool { return p.ArtifactID == desc.ArtifactID })
+	switch {
+	case i < 0:
+		meta.Plugins = append(meta.Plugins, entry)
+	case meta.Plugins[i] == entry:
+		return nil
+	default:
+		meta.Plugins[i] = entry
+	}
+	slices.SortFunc(meta.Plugins, func(a, b pluginEntry) int { return strings.Compare(a.ArtifactID, b.ArtifactID) })
+
+	body, err := xml.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.putXML(ctx, metaKey, body)
+}
+
+// putXML stores a marshalled XML document (metadata, POMs) with its .sha1
+// and .md5 sidecars.
+func (s *Server) putXML(ctx context.Context, key string, body []byte) error {
+	data := append([]byte(xml.Header), body...)
+	data = append(data, '\n')
+	return s.putWithChecksums(ctx, key, "application/xml", data)
+}
+
+// putWithChecksums writes a file the server generates together with its
+// checksum sidecars.
+func (s *Server) putWithChecksums(ctx context.Context, key, contentType string, data []byte) error {
+	if err := s.store.Put(ctx, key, bytes.NewReader(data), contentType, int64(len(data))); err != nil {
+		return err
+	}
+
+	sums := storage.NewChecksums(s.checksums...)
+	_, _ = sums.Write(data)
+	for _, name := range s.checksums {
+		sum := sums.Sum(name)
+		if err := s.store.Put(ctx, key+"."+name, strings.NewReader(sum), "text/plain", int64(len(sum))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func pluginJar(t *testing.T, descriptor string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create(pluginDescriptorPath)
+	if err != nil {
+		t.Fatalf("zip create: %v", err)
+	}
+	if _, err := f.Write([]byte(descriptor)); err != nil {
+		t.Fatalf("zip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func putPlugin(t *testing.T, srv *Server, key, artifactID, prefix string) {
+	t.Helper()
+	jar := pluginJar(t, `<plugin><name>`+artifactID+`</name><groupId>com.mycorp</groupId><artifactId>`+artifactID+`</artifactId><version>1.0</version><goalPrefix>`+prefix+`</goalPrefix></plugin>`)
+	req := httptest.NewRequest(http.MethodPut, "/"+key, bytes.NewReader(jar))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("put %s: status %d", key, rr.Code)
+	}
+}
+
+func TestPutPluginUpdatesGroupMetadata(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+
+	putPlugin(t, srv, "libs/com/mycorp/my-maven-plugin/1.0/my-maven-plugin-1.0.jar", "my-maven-plugin", "my")
+	putPlugin(t, srv, "libs/com/mycorp/deploy-maven-plugin/1.0/deploy-maven-plugin-1.0.jar", "deploy-maven-plugin", "deploy")
+	putPlugin(t, srv, "libs/com/mycorp/my-maven-plugin/1.1/my-maven-plugin-1.1.jar", "my-maven-plugin", "mine")
+
+	obj, ok := store.data["libs/com/mycorp/maven-metadata.xml"]
+	if !ok {
+		t.Fatalf("group metadata not written")
+	}
+	var meta groupMetadata
+	if err := xml.Unmarshal(obj.body, &meta); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	want := []pluginEntry{
+		{Name: "deploy-maven-plugin", Prefix: "deploy", ArtifactID: "deploy-maven-plugin"},
+		{Name: "my-maven-plugin", Prefix: "mine", ArtifactID: "my-maven-plugin"},
+	}
+	if len(meta.Plugins) != len(want) {
+		t.Fatalf("unexpected plugins %+v", meta.Plugins)
+	}
+	for i := range want {
+		if meta.Plugins[i] != want[i] {
+			t.Fatalf("plugin %d: got %+v want %+v", i, meta.Plugins[i], want[i])
+		}
+	}
+	if _, ok := store.data["libs/com/mycorp/maven-metadata.xml.sha1"]; !ok {
+		t.Fatalf("metadata checksum not written")
+	}
+}
+
+func TestPutPlainJarSkipsGroupMetadata(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+
+	req := httptest.NewRequest(http.MethodPut, "/libs/com/mycorp/app/1.0/app-1.0.jar", bytes.NewReader([]byte("not a zip")))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status %d", rr.Code)
+	}
+	if _, ok := store.data["libs/com/mycorp/maven-metadata.xml"]; ok {
+		t.Fatalf("unexpected group metadata for a plain jar")
+	}
+}
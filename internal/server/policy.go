@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PolicyInput describes an artifact request submitted to the policy engine.
+// It is sent wrapped as {"input": ...}, the shape OPA's data API expects.
+type PolicyInput struct {
+	Action     string `json:"action"` // download, upload
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Repository string `json:"repository,omitempty"`
+	GroupID    string `json:"groupId,omitempty"`
+	ArtifactID string `json:"artifactId,omitempty"`
+	Version    string `json:"version,omitempty"`
+	User       string `json:"user,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// Size and the checksums of the uploaded content, set for uploads.
+	Size   int64  `json:"size,omitempty"`
+	SHA1   string `json:"sha1,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type PolicyDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PolicyHook asks an external engine (OPA or any webhook) whether an artifact
+// request is allowed. Decisions are cached for CacheTTL.
+type PolicyHook struct {
+	URL      string
+	FailOpen bool
+	CacheTTL time.Duration
+
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]cachedDecision
+}
+
+type cachedDecision struct {
+	decision PolicyDecision
+	expires  time.Time
+}
+
+func NewPolicyHook(url string, timeout, cacheTTL time.Duration, failOpen bool) *PolicyHook {
+	return &PolicyHook{
+		URL:      url,
+		FailOpen: failOpen,
+		CacheTTL: cacheTTL,
+		client:   &http.Client{Timeout: timeout},
+		cache:    make(map[string]cachedDecision),
+	}
+}
+
+func (h *PolicyHook) Decide(ctx context.Context, in PolicyInput) (PolicyDecision, error) {
+	cacheKey := strings.Join([]string{in.Action, in.User, in.Path, in.SHA256}, "\x00")
+	if h.CacheTTL > 0 {
+		h.mu.Lock()
+		c, ok := h.cache[cacheKey]
+		h.mu.Unlock()
+		if ok && time.Now().Before(c.expires) {
+			return c.decision, nil
+		}
+	}
+
+	body, err := json.Marshal(map[string]PolicyInput{"input": in})
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return PolicyDecision{}, fmt.Errorf("policy request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return PolicyDecision{}, fmt.Errorf("policy request: status %d", resp.StatusCode)
+	}
+
+	decision, err := decodePolicyDecision(resp)
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+
+	if h.CacheTTL > 0 {
+		h.mu.Lock()
+		now := time.Now()
+		for k, c := range h.cache {
+			if now.After(c.expires) {
+				delete(h.cache, k)
+			}
+		}
+		h.cache[cacheKey] = cachedDecision{decision: decision, expires: now.Add(h.CacheTTL)}
+		h.mu.Unlock()
+	}
+	return decision, nil
+}
+
+// decodePolicyDecision accepts a plain {"allow","reason"} webhook answer as
+// well as OPA's {"result": {...}} or {"result": true}.
+func decodePolicyDecision(resp *http.Response) (PolicyDecision, error) {
+	var raw struct {
+		PolicyDecision
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return PolicyDecision{}, fmt.Errorf("decode policy response: %w", err)
+	}
+	if len(raw.Result) == 0 || string(raw.Result) == "null" {
+		return raw.PolicyDecision, nil
+	}
+	var allow bool
+	if err := json.Unmarshal(raw.Result, &allow); err == nil {
+		return PolicyDecision{Allow: allow}, nil
+	}
+	var decision PolicyDecision
+	if err := json.Unmarshal(raw.Result, &decision); err != nil {
+		return PolicyDecision{}, fmt.Errorf("decode policy result: %w", err)
+	}
+	return decision, nil
+}
+
+func policyInputFor(r *http.Request, key, action string) PolicyInput {
+	in := PolicyInput{
+		Action:     action,
+		Method:     r.Method,
+		Path:       key,
+		RemoteAddr: r.RemoteAddr,
+	}
+	if repo, _, ok := strings.Cut(key, "/"); ok {
+		in.Repository = repo
+	}
+	if group, artifact, version, ok := parseCoordinates(key); ok {
+		in.GroupID, in.ArtifactID, in.Version = group, artifact, version
+	}
+	in.User = principalName(r)
+	return in
+}
+
+// enforcePolicy writes a 403 (or 503 when the engine is unreachable and the
+// hook fails closed) and returns false when the request must not proceed.
+func (s *Server) enforcePolicy(w http.ResponseWriter, r *http.Request, in PolicyInput) bool {
+	if s.policy == nil {
+		return true
+	}
+	key, action := in.Path, in.Action
+	decision, err := s.policy.Decide(r.Context(), in)
+	if err != nil {
+		if s.policy.FailOpen {
+			s.logger.Warn("policy engine unavailable; allowing request", zap.String("key", key), zap.Error(err))
+			return true
+		}
+		s.logger.Error("policy engine unavailable", zap.String("key", key), zap.Error(err))
+		writeAPIError(w, "policy engine unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+	if !decision.Allow {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "denied by policy"
+		}
+		s.logger.Info("policy denied request", zap.String("key", key), zap.String("action", action), zap.String("reason", reason))
+		writeAPIError(w, reason, http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPolicyHookDenies(t *testing.T) {
+	var calls int
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body struct {
+			Input PolicyInput `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		allow := body.Input.ArtifactID != "forbidden"
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": map[string]any{"allow": allow, "reason": "license not approved"}})
+	}))
+	defer engine.Close()
+
+	store := &mockStore{getResp: &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("jar"))}}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{
+		Policy: NewPolicyHook(engine.URL, time.Second, time.Minute, false),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/releases/com/acme/forbidden/1.0/forbidden-1.0.jar", nil)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "license not approved") {
+		t.Fatalf("expected 403 with reason, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// cached decision
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/releases/com/acme/forbidden/1.0/forbidden-1.0.jar", nil))
+	if calls != 1 {
+		t.Fatalf("expected decision to be cached, engine called %d times", calls)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/releases/com/acme/ok/1.0/ok-1.0.jar", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestPolicyHookFailClosed(t *testing.T) {
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer engine.Close()
+
+	srv := NewWithOptions(&mockStore{}, zaptest.NewLogger(t), metrics.New(), Options{
+		Policy: NewPolicyHook(engine.URL, time.Second, 0, false),
+	})
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/releases/a.jar", strings.NewReader("x")))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+
+	srv.policy.FailOpen = true
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/releases/a.jar", strings.NewReader("x")))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected fail-open upload to succeed, got %d", rr.Code)
+	}
+}
+
+func TestPolicyUploadSeesChecksums(t *testing.T) {
+	// sha256 of "evil"
+	const banned = "b5c1fb2efc6d6b4674c2fdcc48ce01b43a3b7c03763c0c3355de0099ee0f8c73"
+	var got PolicyInput
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input PolicyInput `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got = body.Input
+		_ = json.NewEncoder(w).Encode(map[string]any{"allow": body.Input.SHA256 != banned})
+	}))
+	defer engine.Close()
+
+	srv := NewWithOptions(newMemStore(), zaptest.NewLogger(t), metrics.New(), Options{
+		Policy: NewPolicyHook(engine.URL, time.Second, time.Minute, false),
+	})
+	put := func(body string) int {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/releases/a.jar", strings.NewReader(body)))
+		return rr.Code
+	}
+
+	// sha1 of "jar"
+	const jarSHA1 = "f92e777f4341930bad9b2422283c4680d00dbc06"
+	if code := put("jar"); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if got.Action != "upload" || got.Size != 3 || got.SHA1 != jarSHA1 || len(got.SHA256) != 64 {
+		t.Fatalf("unexpected policy input %+v", got)
+	}
+	if code := put("evil"); code != http.StatusForbidden {
+		t.Fatalf("expected banned content to be denied, got %d", code)
+	}
+}
+
+func TestDecodePolicyDecisionShapes(t *testing.T) {
+	for body, want := range map[string]bool{
+		`{"allow":true}`:                  true,
+		`{"allow":false,"reason":"no"}`:   false,
+		`{"result":true}`:                 true,
+		`{"result":{"allow":false}}`:      false,
+		`{"result":{"allow":true,"x":1}}`: true,
+	} {
+		d, err := decodePolicyDecision(&http.Response{Body: io.NopCloser(strings.NewReader(body))})
+		if err != nil || d.Allow != want {
+			t.Errorf("%s: got %+v err=%v", body, d, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// POM is the structured content of a stored POM. ${...} references are
+// expanded from the POM's own properties; those that cannot be are kept
+// as written. The parent POM is not read, so inherited dependencies and
+// properties are missing.
+type POM struct {
+	Path        string       `json:"path" example:"releases/com/acme/lib/1.0/lib-1.0.pom"`
+	GroupID     string       `json:"groupId" example:"com.acme"`
+	ArtifactID  string       `json:"artifactId" example:"lib"`
+	Version     string       `json:"version" example:"1.0"`
+	Packaging   string       `json:"packaging" example:"jar"`
+	Name        string       `json:"name,omitempty" example:"Acme Lib"`
+	Description string       `json:"description,omitempty"`
+	URL         string       `json:"url,omitempty" example:"https://acme.example.com/lib"`
+	Parent      *Coordinates `json:"parent,omitempty"`
+	// Dependencies and ManagedDependencies keep the POM's order.
+	Dependencies        []POMDependency `json:"dependencies"`
+	ManagedDependencies []POMDependency `json:"managedDependencies"`
+	Licenses            []POMLicense    `json:"licenses"`
+	SCM                 *POMSCM         `json:"scm,omitempty"`
+}
+
+type POMDependency struct {
+	GroupID    string `json:"groupId" example:"org.slf4j"`
+	ArtifactID string `json:"artifactId" example:"slf4j-api"`
+	Version    string `json:"version,omitempty" example:"2.0.13"`
+	Type       string `json:"type,omitempty" example:"jar"`
+	Classifier string `json:"classifier,omitempty"`
+	Scope      string `json:"scope,omitempty" example:"compile"`
+	Optional   bool   `json:"optional,omitempty"`
+}
+
+type POMLicense struct {
+	Name         string `json:"name,omitempty" example:"Apache-2.0"`
+	URL          string `json:"url,omitempty" example:"https://www.apache.org/licenses/LICENSE-2.0"`
+	Distribution string `json:"distribution,omitempty" example:"repo"`
+}
+
+type POMSCM struct {
+	Connection          string `json:"connection,omitempty" example:"scm:git:https://github.com/acme/lib.git"`
+	DeveloperConnection string `json:"developerConnection,omitempty"`
+	URL                 string `json:"url,omitempty" example:"https://github.com/acme/lib"`
+	Tag                 string `json:"tag,omitempty" example:"v1.0"`
+}
+
+// pomDocument is the subset of a POM returned by GET /api/v1/pom.
+type pomDocument struct {
+	XMLName     xml.Name        `xml:"project"`
+	GroupID     string          `xml:"groupId"`
+	ArtifactID  string          `xml:"artifactId"`
+	Version     string          `xml:"version"`
+	Packaging   string          `xml:"packaging"`
+	Name        string          `xml:"name"`
+	Description string          `xml:"description"`
+	URL         string          `xml:"url"`
+	Parent      pomDependency   `xml:"parent"`
+	Properties  pomProperties   `xml:"properties"`
+	Deps        []pomDependency `xml:"dependencies>dependency"`
+	Managed     []pomDependency `xml:"dependencyManagement>dependencies>dependency"`
+	Licenses    []struct {
+		Name         string `xml:"name"`
+		URL          string `xml:"url"`
+		Distribution string `xml:"distribution"`
+	} `xml:"licenses>license"`
+	SCM struct {
+		Connection          string `xml:"connection"`
+		DeveloperConnection string `xml:"developerConnection"`
+		URL                 string `xml:"url"`
+		Tag                 string `xml:"tag"`
+	} `xml:"scm"`
+}
+
+// parsePOM reads a POM into its JSON form. groupId and version fall back
+// to the parent's, and packaging defaults to jar, as in Maven.
+func parsePOM(r io.Reader) (POM, error) {
+	var d pomDocument
+	if err := xml.NewDecoder(r).Decode(&d); err != nil {
+		return POM{}, err
+	}
+	props := map[string]string{}
+	for k, v := range d.Properties {
+		props[k] = v
+	}
+	props["project.groupId"] = strings.TrimSpace(cmp.Or(d.GroupID, d.Parent.GroupID))
+	props["project.artifactId"] = strings.TrimSpace(d.ArtifactID)
+	props["project.version"] = strings.TrimSpace(cmp.Or(d.Version, d.Parent.Version))
+	props["project.parent.version"] = strings.TrimSpace(d.Parent.Version)
+	resolve := pomResolver(props)
+	expand := func(v string) string {
+		v = strings.TrimSpace(v)
+		if res, ok := resolve(v); ok {
+			return res
+		}
+		return v
+	}
+
+	p := POM{
+		GroupID:             expand(cmp.Or(d.GroupID, d.Parent.GroupID)),
+		ArtifactID:          expand(d.ArtifactID),
+		Version:             expand(cmp.Or(d.Version, d.Parent.Version)),
+		Packaging:           cmp.Or(expand(d.Packaging), "jar"),
+		Name:                expand(d.Name),
+		Description:         strings.TrimSpace(d.Description),
+		URL:                 expand(d.URL),
+		Dependencies:        []POMDependency{},
+		ManagedDependencies: []POMDependency{},
+		Licenses:            []POMLicense{},
+	}
+	if d.Parent.ArtifactID != "" {
+		p.Parent = &Coordinates{
+			GroupID:    strings.TrimSpace(d.Parent.GroupID),
+			ArtifactID: strings.TrimSpace(d.Parent.ArtifactID),
+			Version:    strings.TrimSpace(d.Parent.Version),
+		}
+	}
+	dep := func(pd pomDependency) POMDependency {
+		return POMDependency{
+			GroupID:    expand(pd.GroupID),
+			ArtifactID: expand(pd.ArtifactID),
+			Version:    expand(pd.Version),
+			Type:       expand(pd.Type),
+			Classifier: expand(pd.Classifier),
+			Scope:      expand(pd.Scope),
+			Optional:   expand(pd.Optional) == "true",
+		}
+	}
+	for _, pd := range d.Deps {
+		p.Dependencies = append(p.Dependencies, dep(pd))
+	}
+	for _, pd := range d.Managed {
+		p.ManagedDependencies = append(p.ManagedDependencies, dep(pd))
+	}
+	for _, l := range d.Licenses {
+		p.Licenses = append(p.Licenses, POMLicense{
+			Name:         strings.TrimSpace(l.Name),
+			URL:          strings.TrimSpace(l.URL),
+			Distribution: strings.TrimSpace(l.Distribution),
+		})
+	}
+	if scm := (POMSCM{
+		Connection:          expand(d.SCM.Connection),
+		DeveloperConnection: expand(d.SCM.DeveloperConnection),
+		URL:                 expand(d.SCM.URL),
+		Tag:                 expand(d.SCM.Tag),
+	}); scm != (POMSCM{}) {
+		p.SCM = &scm
+	}
+	return p, nil
+}
+
+// @Summary Parse a POM
+// @Description Returns the coordinates, parent, dependencies, managed dependencies, licenses and SCM information of a stored POM. ${...} references are expanded from the POM's own properties and project coordinates; those that cannot be are returned as written. The parent POM is not read, so inherited dependencies and properties are missing. POMs are limited to 1 MiB. Deploy tokens need read scope for the path.
+// @Tags artifacts
+// @Produce json
+// @Param artifactPath path string true "POM path"
+// @Success 200 {object} POM
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/pom/{artifactPath} [get]
+func (s *Server) handlePOM(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, apiV1+"/pom/")
+	if !strings.HasSuffix(key, ".pom") || strings.Contains(key, "..") || isInternalPath(key) {
+		writeAPIError(w, "path must name a .pom file", http.StatusBadRequest)
+		return
+	}
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("read", key) {
+		writeAPIError(w, "token not permitted for "+key, http.StatusForbidden)
+		return
+	}
+
+	resp, err := s.store.Get(r.Context(), key)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			writeAPIError(w, key+" not found", http.StatusNotFound)
+			return
+		}
+		s.logger.Error("read pom", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "read "+key+" failed", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+	p, err := parsePOM(io.LimitReader(resp.Body, pomLimit))
+	if err != nil {
+		writeAPIError(w, key+" is not a valid POM: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	p.Path = key
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		s.logger.Warn("encode pom", zap.String("path", key), zap.Error(err))
+	}
+}
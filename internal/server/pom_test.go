@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+const testParsePOM = `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <modelVersion>4.0.0</modelVersion>
+  <parent>
+    <groupId>com.acme</groupId>
+    <artifactId>acme-parent</artifactId>
+    <version>7</version>
+  </parent>
+  <artifactId>lib</artifactId>
+  <version>1.0</version>
+  <name>Acme Lib</name>
+  <properties>
+    <slf4j.version>2.0.13</slf4j.version>
+  </properties>
+  <licenses>
+    <license>
+      <name>Apache-2.0</name>
+      <url>https://www.apache.org/licenses/LICENSE-2.0</url>
+    </license>
+  </licenses>
+  <scm>
+    <url>https://github.com/acme/lib</url>
+    <tag>v${project.version}</tag>
+  </scm>
+  <dependencyManagement>
+    <dependencies>
+      <dependency>
+        <groupId>com.acme</groupId>
+        <artifactId>bom</artifactId>
+        <version>${project.version}</version>
+        <type>pom</type>
+        <scope>import</scope>
+      </dependency>
+    </dependencies>
+  </dependencyManagement>
+  <dependencies>
+    <dependency>
+      <groupId>org.slf4j</groupId>
+      <artifactId>slf4j-api</artifactId>
+      <version>${slf4j.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>com.acme</groupId>
+      <artifactId>extras</artifactId>
+      <version>${extras.version}</version>
+      <scope>test</scope>
+      <optional>true</optional>
+    </dependency>
+  </dependencies>
+</project>`
+
+func TestHandlePOM(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	for key, data := range map[string]string{
+		"releases/com/acme/lib/1.0/lib-1.0.pom":   testParsePOM,
+		"releases/com/acme/bad/1.0/bad-1.0.pom":   "not xml",
+		"releases/com/acme/lib/1.0/lib-1.0.jar":   "jar",
+		"__properties__/com/acme/lib/1.0/lib.pom": testParsePOM,
+	} {
+		if err := store.Put(ctx, key, bytes.NewReader([]byte(data)), "application/xml", int64(len(data))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	get := func(target string) (*httptest.ResponseRecorder, POM) {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/pom/"+target, nil))
+		var p POM
+		_ = json.Unmarshal(rr.Body.Bytes(), &p)
+		return rr, p
+	}
+
+	rr, p := get("releases/com/acme/lib/1.0/lib-1.0.pom")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rr.Code, rr.Body.String())
+	}
+	if p.GroupID != "com.acme" || p.ArtifactID != "lib" || p.Version != "1.0" || p.Packaging != "jar" || p.Name != "Acme Lib" {
+		t.Errorf("coordinates: %+v", p)
+	}
+	if p.Parent == nil || p.Parent.ArtifactID != "acme-parent" || p.Parent.Version != "7" {
+		t.Errorf("parent: %+v", p.Parent)
+	}
+	if len(p.Dependencies) != 2 || p.Dependencies[0].Version != "2.0.13" {
+		t.Fatalf("dependencies: %+v", p.Dependencies)
+	}
+	if d := p.Dependencies[1]; d.Version != "${extras.version}" || d.Scope != "test" || !d.Optional {
+		t.Errorf("unresolved dependency: %+v", d)
+	}
+	if len(p.ManagedDependencies) != 1 || p.ManagedDependencies[0].Version != "1.0" || p.ManagedDependencies[0].Scope != "import" {
+		t.Errorf("managed dependencies: %+v", p.ManagedDependencies)
+	}
+	if len(p.Licenses) != 1 || p.Licenses[0].Name != "Apache-2.0" {
+		t.Errorf("licenses: %+v", p.Licenses)
+	}
+	if p.SCM == nil || p.SCM.Tag != "v1.0" || p.SCM.URL != "https://github.com/acme/lib" {
+		t.Errorf("scm: %+v", p.SCM)
+	}
+
+	for target, want := range map[string]int{
+		"releases/com/acme/bad/1.0/bad-1.0.pom":   http.StatusUnprocessableEntity,
+		"releases/com/acme/lib/2.0/lib-2.0.pom":   http.StatusNotFound,
+		"releases/com/acme/lib/1.0/lib-1.0.jar":   http.StatusBadRequest,
+		"__properties__/com/acme/lib/1.0/lib.pom": http.StatusBadRequest,
+	} {
+		if rr, _ := get(target); rr.Code != want {
+			t.Errorf("%s: got %d, want %d", target, rr.Code, want)
+		}
+	}
+}
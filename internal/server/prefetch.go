@@ -0,0 +1,357 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// prefetchMaxArtifacts bounds the paths of one prefetch request.
+	prefetchMaxArtifacts = 10000
+	// prefetchJobsKept is how many prefetch jobs GET can still report.
+	prefetchJobsKept = 50
+)
+
+// PrefetchRequest lists what to fetch: coordinates or paths as in a
+// warm-up list, a POM whose dependencies to fetch, and/or a Gradle lockfile.
+type PrefetchRequest struct {
+	Artifacts []string `json:"artifacts" example:"org.apache.commons:commons-lang3:3.14.0"`
+	POM       string   `json:"pom,omitempty"`
+	Lockfile  string   `json:"lockfile,omitempty"`
+}
+
+// PrefetchJob is a prefetch started through the API.
+type PrefetchJob struct {
+	ID         string      `json:"id"`
+	Status     string      `json:"status"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Done       int         `json:"done"`
+	Stats      WarmupStats `json:"stats"`
+	// Unresolved lists POM dependencies skipped because they have no version
+	// or use a property the POM does not define.
+	Unresolved []string `json:"unresolved,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// prefetchJobs holds the running and recent prefetch jobs, oldest first.
+type prefetchJobs struct {
+	mu   sync.Mutex
+	jobs []*PrefetchJob
+}
+
+func (p *prefetchJobs) add(job *PrefetchJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs = append(p.jobs, job)
+	for len(p.jobs) > prefetchJobsKept {
+		i := slices.IndexFunc(p.jobs, func(j *PrefetchJob) bool { return j.Status != TaskRunning })
+		if i < 0 {
+			break
+		}
+		p.jobs = slices.Delete(p.jobs, i, i+1)
+	}
+}
+
+// get returns a copy of the job with the given ID.
+func (p *prefetchJobs) get(id string) (PrefetchJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, j := range p.jobs {
+		if j.ID == id {
+			return *j, true
+		}
+	}
+	return PrefetchJob{}, false
+}
+
+// update applies fn to job under the lock.
+func (p *prefetchJobs) update(job *PrefetchJob, fn func(*PrefetchJob)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(job)
+}
+
+func (s *Server) routePrefetch(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, apiV1+"/prefetch"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		s.handleStartPrefetch(w, r)
+	case id != "" && r.Method == http.MethodGet:
+		s.handleGetPrefetch(w, r, id)
+	case id == "":
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary Prefetch artifacts
+// @Description Fetches artifacts through the caching proxies in the background, so they are in the bucket before upstreams become unreachable. artifacts takes coordinates (groupId:artifactId[:extension[:classifier]]:version, fetching the file and its POM) or paths, starting with a proxy name or not; pom takes a POM whose dependencies, dependency management entries and parent to fetch; lockfile takes a Gradle lockfile. Poll GET /api/v1/prefetch/{id} for the outcome.
+// @Tags proxies
+// @Accept json
+// @Produce json
+// @Param request body PrefetchRequest true "Artifacts to fetch"
+// @Success 202 {object} PrefetchJob
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/prefetch [post]
+func (s *Server) handleStartPrefetch(w http.ResponseWriter, r *http.Request) {
+	var req PrefetchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	paths, unresolved, err := prefetchPaths(req)
+	if err != nil {
+		writeAPIError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(paths) == 0 {
+		writeAPIError(w, "nothing to prefetch", http.StatusBadRequest)
+		return
+	}
+	if len(paths) > prefetchMaxArtifacts {
+		writeAPIError(w, fmt.Sprintf("too many artifacts (%d, at most %d)", len(paths), prefetchMaxArtifacts), http.StatusBadRequest)
+		return
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	job := &PrefetchJob{
+		ID:         hex.EncodeToString(id),
+		Status:     TaskRunning,
+		StartedAt:  time.Now().UTC(),
+		Stats:      WarmupStats{Requested: len(paths)},
+		Unresolved: unresolved,
+	}
+	s.prefetch.add(job)
+	started := *job
+	s.audit(r, "prefetch.started", zap.String("job", job.ID), zap.Int("artifacts", len(paths)))
+	// Detached from the request so the job outlives it.
+	go s.runPrefetch(context.WithoutCancel(r.Context()), job, paths)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", apiV1+"/prefetch/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(started); err != nil {
+		s.logger.Warn("encode prefetch job", zap.Error(err))
+	}
+}
+
+// @Summary Prefetch status
+// @Description Progress of a prefetch job: done counts the artifacts handled so far; stats splits them into already cached, fetched, missing upstream and failed. The last 50 jobs are kept in memory.
+// @Tags proxies
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} PrefetchJob
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/prefetch/{id} [get]
+func (s *Server) handleGetPrefetch(w http.ResponseWriter, r *http.Request, id string) {
+	job, ok := s.prefetch.get(id)
+	if !ok {
+		writeAPIError(w, "unknown prefetch job", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		s.logger.Warn("encode prefetch job", zap.Error(err))
+	}
+}
+
+func (s *Server) runPrefetch(ctx context.Context, job *PrefetchJob, paths []string) {
+	stats, err := s.warmCache(ctx, paths, func(string) {
+		s.prefetch.update(job, func(j *PrefetchJob) { j.Done++ })
+	})
+	s.prefetch.update(job, func(j *PrefetchJob) {
+		finished := time.Now().UTC()
+		j.FinishedAt = &finished
+		j.Stats = stats
+		j.Status = TaskSucceeded
+		if err != nil {
+			j.Status, j.Error = TaskFailed, err.Error()
+		}
+	})
+	s.logger.Info("prefetch finished",
+		zap.String("job", job.ID),
+		zap.Int("requested", stats.Requested),
+		zap.Int("cached", stats.Cached),
+		zap.Int("fetched", stats.Fetched),
+		zap.Int("missing", stats.Missing),
+		zap.Int("failed", stats.Failed),
+		zap.Error(err))
+}
+
+// prefetchPaths collects the repository paths named by req, rejecting
+// internal and traversing ones.
+func prefetchPaths(req PrefetchRequest) (paths, unresolved []string, err error) {
+	lists := []string{strings.Join(req.Artifacts, "\n")}
+	if strings.TrimSpace(req.POM) != "" {
+		coords, skipped, err := pomDependencies(req.POM)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid pom: %w", err)
+		}
+		lists = append(lists, strings.Join(coords, "\n"))
+		unresolved = skipped
+	}
+	if req.Lockfile != "" {
+		lists = append(lists, gradleLockfileCoordinates(req.Lockfile))
+	}
+	paths, err = parseWarmupList(strings.NewReader(strings.Join(lists, "\n")))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range paths {
+		if isInternalPath(p) || strings.Contains(p, "..") {
+			return nil, nil, fmt.Errorf("invalid path %q", p)
+		}
+	}
+	return paths, unresolved, nil
+}
+
+// gradleLockfileCoordinates turns the "group:artifact:version=configurations"
+// lines of a Gradle lockfile into a warm-up list.
+func gradleLockfileCoordinates(lockfile string) string {
+	var out []string
+	for line := range strings.Lines(lockfile) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "empty=") {
+			continue
+		}
+		coords, _, _ := strings.Cut(line, "=")
+		out = append(out, coords)
+	}
+	return strings.Join(out, "\n")
+}
+
+type pomDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	Type       string `xml:"type"`
+	Classifier string `xml:"classifier"`
+	Scope      string `xml:"scope"`
+	Optional   string `xml:"optional"`
+}
+
+// pomDependencyList is the subset of a POM needed to prefetch what it
+// depends on.
+type pomDependencyList struct {
+	GroupID    string          `xml:"groupId"`
+	Version    string          `xml:"version"`
+	Parent     pomDependency   `xml:"parent"`
+	Properties pomProperties   `xml:"properties"`
+	Deps       []pomDependency `xml:"dependencies>dependency"`
+	Managed    []pomDependency `xml:"dependencyManagement>dependencies>dependency"`
+}
+
+type pomProperties map[string]string
+
+func (p *pomProperties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*p = make(pomProperties)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var v string
+			if err := d.DecodeElement(&v, &t); err != nil {
+				return err
+			}
+			(*p)[t.Name.Local] = strings.TrimSpace(v)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+var pomPropertyRef = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// pomResolver returns a func expanding ${...} references from props. It
+// reports false when the result is empty or a reference is undefined.
+func pomResolver(props map[string]string) func(string) (string, bool) {
+	return func(v string) (string, bool) {
+		ok := true
+		// Properties may refer to other properties; a few rounds suffice.
+		for range 5 {
+			if !strings.Contains(v, "${") {
+				break
+			}
+			v = pomPropertyRef.ReplaceAllStringFunc(v, func(ref string) string {
+				if val, found := props[ref[2:len(ref)-1]]; found && val != "" {
+					return val
+				}
+				ok = false
+				return ref
+			})
+		}
+		return v, ok && v != "" && !strings.Contains(v, "${")
+	}
+}
+
+// pomDependencies returns the coordinates of the parent, dependencies and
+// managed dependencies of a POM, resolving ${...} from its properties and
+// project version. Dependencies without a version or with an undefined
+// property are returned as unresolved.
+func pomDependencies(pom string) (coords, unresolved []string, err error) {
+	var p pomDependencyList
+	if err := xml.Unmarshal([]byte(pom), &p); err != nil {
+		return nil, nil, err
+	}
+	props := map[string]string{}
+	for k, v := range p.Properties {
+		props[k] = v
+	}
+	props["project.version"] = strings.TrimSpace(cmp.Or(p.Version, p.Parent.Version))
+	props["project.groupId"] = strings.TrimSpace(cmp.Or(p.GroupID, p.Parent.GroupID))
+	props["project.parent.version"] = strings.TrimSpace(p.Parent.Version)
+	resolve := pomResolver(props)
+
+	deps := append([]pomDependency(nil), p.Deps...)
+	deps = append(deps, p.Managed...)
+	if p.Parent.ArtifactID != "" {
+		parent := p.Parent
+		parent.Type = "pom"
+		deps = append(deps, parent)
+	}
+	for _, d := range deps {
+		group, gok := resolve(strings.TrimSpace(d.GroupID))
+		artifact, aok := resolve(strings.TrimSpace(d.ArtifactID))
+		version, vok := resolve(strings.TrimSpace(d.Version))
+		if !gok || !aok || !vok {
+			unresolved = append(unresolved, strings.TrimSpace(d.GroupID)+":"+strings.TrimSpace(d.ArtifactID)+":"+strings.TrimSpace(d.Version))
+			continue
+		}
+		ext := cmp.Or(strings.TrimSpace(d.Type), "jar")
+		switch ext {
+		case "test-jar":
+			ext, d.Classifier = "jar", cmp.Or(d.Classifier, "tests")
+		case "bundle", "maven-plugin", "ejb":
+			ext = "jar"
+		}
+		c := group + ":" + artifact + ":" + ext
+		if cl := strings.TrimSpace(d.Classifier); cl != "" {
+			c += ":" + cl
+		}
+		coords = append(coords, c+":"+version)
+	}
+	return coords, unresolved, nil
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPomDependencies(t *testing.T) {
+	pom := `<project>
+  <parent><groupId>com.acme</groupId><artifactId>parent</artifactId><version>7</version></parent>
+  <artifactId>app</artifactId>
+  <version>1.0</version>
+  <properties><guava.version>33.0-jre</guava.version><lib.version>${project.version}</lib.version></properties>
+  <dependencyManagement><dependencies>
+    <dependency><groupId>org.junit</groupId><artifactId>junit-bom</artifactId><version>5.10.1</version><type>pom</type><scope>import</scope></dependency>
+  </dependencies></dependencyManagement>
+  <dependencies>
+    <dependency><groupId>com.google.guava</groupId><artifactId>guava</artifactId><version>${guava.version}</version></dependency>
+    <dependency><groupId>${project.groupId}</groupId><artifactId>lib</artifactId><version>${lib.version}</version><type>test-jar</type></dependency>
+    <dependency><groupId>org.x</groupId><artifactId>managed</artifactId></dependency>
+    <dependency><groupId>org.x</groupId><artifactId>y</artifactId><version>${undefined}</version></dependency>
+  </dependencies>
+</project>`
+	coords, unresolved, err := pomDependencies(pom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "com.google.guava:guava:jar:33.0-jre com.acme:lib:jar:tests:1.0 org.junit:junit-bom:pom:5.10.1 com.acme:parent:pom:7"
+	if strings.Join(coords, " ") != want {
+		t.Fatalf("unexpected coordinates %v", coords)
+	}
+	if strings.Join(unresolved, " ") != "org.x:managed: org.x:y:${undefined}" {
+		t.Fatalf("unexpected unresolved %v", unresolved)
+	}
+}
+
+func TestPrefetchAPI(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/com/acme/lib/1.0/lib-1.0.jar", "/com/acme/lib/1.0/lib-1.0.pom", "/org/x/y/2/y-2.jar":
+			_, _ = w.Write([]byte("content"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	if err := srv.proxy.Add(context.Background(), Proxy{Name: "central", URL: upstream.URL}); err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/api/v1/prefetch", `{"artifacts":["com.acme:lib:1.0"],"lockfile":"# Gradle lockfile\norg.x:y:2=runtimeClasspath\nempty=\n"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var job PrefetchJob
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil || job.ID == "" {
+		t.Fatalf("decode job: %v %s", err, rr.Body.String())
+	}
+	if loc := rr.Header().Get("Location"); loc != "/api/v1/prefetch/"+job.ID {
+		t.Fatalf("unexpected Location %q", loc)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status == TaskRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("prefetch did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+		rr := do(http.MethodGet, "/api/v1/prefetch/"+job.ID, "")
+		if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+			t.Fatalf("decode job: %v", err)
+		}
+	}
+	if job.Status != TaskSucceeded || job.Done != 4 || job.Stats != (WarmupStats{Requested: 4, Fetched: 3, Missing: 1}) {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	if _, ok := store.data["central/org/x/y/2/y-2.jar"]; !ok {
+		t.Fatal("expected the lockfile entry to be cached")
+	}
+
+	for _, body := range []string{`{}`, `{"artifacts":["__tokens__/x"]}`, `{"artifacts":["a:b"]}`, `{"pom":"<project"}`} {
+		if rr := do(http.MethodPost, "/api/v1/prefetch", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+	if rr := do(http.MethodGet, "/api/v1/prefetch/unknown", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
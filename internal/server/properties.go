@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/otoru/heimdall/internal/storage"
+)
+
+const (
+	propertiesPrefix = "__properties__/"
+	quarantinePrefix = "__quarantine__/"
+)
+
+// internalPrefixes hold Heimdall bookkeeping objects that must never show up
+// in catalog listings.
+var internalPrefixes = []string{proxyConfigPrefix, propertiesPrefix, quarantinePrefix, tokenPrefix, taskHistoryPrefix, metadataLockPrefix, storage.CASPrefix, storage.ProbePrefix, checksumIndexPrefix, dependentsPrefix, uploadPrefix}
+
+func isInternalPath(p string) bool {
+	p = strings.TrimPrefix(p, "/")
+	for _, prefix := range internalPrefixes {
+		if strings.HasPrefix(p, prefix) || p+"/" == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// loadProperties returns the key/value properties recorded for an artifact,
+// stored as JSON under __properties__/<key>.json.
+func loadProperties(ctx context.Context, store Storage, key string) (map[string]string, error) {
+	resp, err := store.Get(ctx, propertiesPrefix+strings.TrimPrefix(key, "/")+".json")
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	props := map[string]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&props); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// setProperties merges props into the artifact's properties; empty values
+// remove a property.
+func setProperties(ctx context.Context, store Storage, key string, props map[string]string) error {
+	current, err := loadProperties(ctx, store, key)
+	if err != nil {
+		return err
+	}
+	for k, v := range props {
+		if v == "" {
+			delete(current, k)
+			continue
+		}
+		current[k] = v
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, propertiesPrefix+strings.TrimPrefix(key, "/")+".json", strings.NewReader(string(data)), "application/json", int64(len(data)))
+}
+
+// QuarantinedError is returned for artifacts moved to the quarantine prefix.
+type QuarantinedError struct {
+	Key    string
+	Reason string
+}
+
+func (e QuarantinedError) Error() string {
+	return fmt.Sprintf("artifact %s is quarantined: %s", e.Key, e.Reason)
+}
+
+// quarantine moves an artifact and its checksum sidecars under
+// __quarantine__/ so it can no longer be served or re-fetched.
+func quarantine(ctx context.Context, store Storage, key, reason string) error {
+	key = strings.TrimPrefix(key, "/")
+	if err := store.Copy(ctx, key, quarantinePrefix+key); err != nil {
+		return err
+	}
+	for _, suffix := range storage.SidecarExtensions {
+		if err := store.Copy(ctx, key+suffix, quarantinePrefix+key+suffix); err == nil {
+			_ = store.Delete(ctx, key+suffix)
+		}
+	}
+	if err := setProperties(ctx, store, key, map[string]string{"quarantine.reason": reason}); err != nil {
+		return err
+	}
+	return store.Delete(ctx, key)
+}
+
+// checkQuarantine returns a QuarantinedError when key has been quarantined.
+func checkQuarantine(ctx context.Context, store Storage, key string) error {
+	key = strings.TrimPrefix(key, "/")
+	if _, err := store.Head(ctx, quarantinePrefix+key); err != nil {
+		if storage.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	reason := "quarantined"
+	if props, err := loadProperties(ctx, store, key); err == nil && props["quarantine.reason"] != "" {
+		reason = props["quarantine.reason"]
+	}
+	return QuarantinedError{Key: key, Reason: reason}
+}
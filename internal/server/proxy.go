@@ -2,10 +2,10 @@ package server
 
 import (
 	"context"
-	"crypto/md5"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,9 +13,14 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/otoru/heimdall/internal/metrics"
 	"github.com/otoru/heimdall/internal/storage"
 	"go.uber.org/zap"
 	"golang.org/x/net/html"
@@ -28,8 +33,74 @@ const proxyConfigPrefix = "__proxycfg__/"
 type Proxy struct {
 	Name string `json:"name"`
 	URL  string `json:"url"`
+	// MaxAge is a Go duration (e.g. "24h") after which cached artifacts are
+	// revalidated against the upstream on access; empty disables expiry.
+	MaxAge string `json:"maxAge,omitempty"`
+	// MaxCacheBytes caps the cached bytes kept for this proxy; least recently
+	// downloaded artifacts are evicted by the background job. 0 means no cap.
+	MaxCacheBytes int64 `json:"maxCacheBytes,omitempty"`
+	// Cache set to false streams upstream responses straight to the client
+	// without storing them in S3.
+	Cache *bool `json:"cache,omitempty"`
+	// UpdatePolicy follows Maven's updatePolicy for upstream misses and
+	// cached maven-metadata.xml: always, never, daily (default) or
+	// interval:N minutes.
+	UpdatePolicy string `json:"updatePolicy,omitempty"`
+	// Mirrors are tried in order when URL, or the mirror before, fails with
+	// a network error or a 5xx status.
+	Mirrors []string `json:"mirrors,omitempty"`
+	// CanaryURL is a second upstream queried alongside URL for every
+	// artifact fetched into the cache; differences in status or content are
+	// logged. Meant to prove two mirrors equivalent before switching.
+	CanaryURL string `json:"canaryUrl,omitempty"`
+	// Declared is set on proxies defined in the proxies file (PROXIES_FILE),
+	// which can only be changed by editing that file. Ignored on input.
+	Declared bool `json:"declared,omitempty"`
+	// Revision identifies the stored configuration and changes with every
+	// write; updates and deletes must send it in If-Match. Ignored on input.
+	Revision string `json:"revision,omitempty"`
 }
 
+func (pr Proxy) caches() bool {
+	return pr.Cache == nil || *pr.Cache
+}
+
+func (pr Proxy) maxAge() time.Duration {
+	if pr.MaxAge == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(pr.MaxAge)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+type InvalidateRequest struct {
+	Path    string `json:"path,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+type InvalidateResult struct {
+	Deleted []string `json:"deleted"`
+}
+
+var errProxyNotFound = errors.New("proxy not found")
+
+// errProxyConflict is returned when a proxy changed since the revision the
+// caller read.
+var errProxyConflict = errors.New("proxy was changed since it was read; reload it and retry")
+
+// errProxyDeclared is returned when the API tries to change a proxy defined
+// in the proxies file.
+var errProxyDeclared = errors.New("proxy is declared in the proxies file; change it there")
+
+// invalidRequestError is an invalidation request that is wrong as sent, as
+// opposed to a failure while carrying it out.
+type invalidRequestError string
+
+func (e invalidRequestError) Error() string { return string(e) }
+
 type ProxyStatusError struct {
 	Code int
 }
@@ -42,6 +113,29 @@ type ProxyManager struct {
 	store      Storage
 	logger     *zap.Logger
 	httpClient *http.Client
+	blocked    *BlockList
+	scans      *ScanQueue
+	// race queries all proxies concurrently on /packages misses and serves
+	// the first one that has the artifact.
+	race bool
+	// misses remembers upstream 404s for the proxy's update policy.
+	misses *missCache
+	// limits bounds concurrent upstream downloads; nil means unbounded.
+	limits *upstreamLimiter
+	// checksums are the sidecar digests written next to cached files.
+	checksums []string
+	metrics   *metrics.Registry
+	// canarySlots bounds the running canary comparisons.
+	canarySlots chan struct{}
+
+	// configMu guards the proxy list cached while Server.WatchConfig runs.
+	// generation counts invalidations so a listing that raced one is not
+	// cached; version is the last configVersionKey seen or written.
+	configMu   sync.Mutex
+	caching    bool
+	cached     []Proxy
+	generation uint64
+	version    string
 }
 
 func NewProxyManager(store Storage, logger *zap.Logger) *ProxyManager {
@@ -51,10 +145,33 @@ func NewProxyManager(store Storage, logger *zap.Logger) *ProxyManager {
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		misses:      newMissCache(),
+		checksums:   storage.DefaultSidecarChecksums,
+		canarySlots: make(chan struct{}, canaryConcurrency),
 	}
 }
 
 func (p *ProxyManager) List(ctx context.Context) ([]Proxy, error) {
+	p.configMu.Lock()
+	cached, generation := p.cached, p.generation
+	p.configMu.Unlock()
+	if cached != nil {
+		return slices.Clone(cached), nil
+	}
+
+	proxies, err := p.listStored(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.configMu.Lock()
+	if p.caching && p.generation == generation {
+		p.cached = append(make([]Proxy, 0, len(proxies)), proxies...)
+	}
+	p.configMu.Unlock()
+	return proxies, nil
+}
+
+func (p *ProxyManager) listStored(ctx context.Context) ([]Proxy, error) {
 	entries, err := p.store.List(ctx, proxyConfigPrefix, 1000)
 	if err != nil {
 		return nil, err
@@ -94,26 +211,110 @@ func (p *ProxyManager) load(ctx context.Context, cfgPath string) (Proxy, error)
 	if err := json.Unmarshal(body, &proxy); err != nil {
 		return Proxy{}, err
 	}
+	proxy.Revision = proxyRevision(body)
 	return proxy, nil
 }
 
+func proxyConfigKey(name string) string {
+	return path.Join(proxyConfigPrefix, name+".json")
+}
+
+// proxyRevision is derived from the stored JSON, so it is the same on every
+// replica and whatever the store's ETag format.
+func proxyRevision(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Get returns the proxy called name with its current revision.
+func (p *ProxyManager) Get(ctx context.Context, name string) (Proxy, error) {
+	if !proxyNameRe.MatchString(name) {
+		return Proxy{}, errProxyNotFound
+	}
+	proxy, err := p.load(ctx, proxyConfigKey(name))
+	if storage.IsNotFound(err) {
+		return Proxy{}, errProxyNotFound
+	}
+	return proxy, err
+}
+
 func (p *ProxyManager) Add(ctx context.Context, proxy Proxy) error {
+	proxy.Name = strings.TrimSpace(proxy.Name)
+	if err := p.checkNotDeclared(ctx, proxy.Name); err != nil {
+		return err
+	}
+	proxy.Declared = false
+	data, err := encodeProxy(proxy)
+	if err != nil {
+		return err
+	}
+	p.misses.forget(proxy.Name)
+	if err := p.store.Put(ctx, proxyConfigKey(proxy.Name), strings.NewReader(string(data)), "application/json", int64(len(data))); err != nil {
+		return err
+	}
+	p.configChanged(ctx)
+	return nil
+}
+
+// encodeProxy validates and normalises proxy and returns the JSON to store.
+func encodeProxy(proxy Proxy) ([]byte, error) {
 	proxy.Name = strings.TrimSpace(proxy.Name)
 	proxy.URL = strings.TrimSpace(proxy.URL)
 
 	if !proxyNameRe.MatchString(proxy.Name) {
-		return fmt.Errorf("invalid name; only letters, digits, dot, underscore, dash")
+		return nil, fmt.Errorf("invalid name; only letters, digits, dot, underscore, dash")
 	}
 	if proxy.URL == "" {
-		return fmt.Errorf("url is required")
+		return nil, fmt.Errorf("url is required")
+	}
+	proxy.Mirrors = slices.Clone(proxy.Mirrors)
+	for i, m := range proxy.Mirrors {
+		m = strings.TrimSpace(m)
+		if u, err := url.Parse(m); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid mirror %q; expected an absolute http(s) URL", m)
+		}
+		proxy.Mirrors[i] = m
+	}
+	proxy.CanaryURL = strings.TrimSpace(proxy.CanaryURL)
+	if proxy.CanaryURL != "" {
+		if u, err := url.Parse(proxy.CanaryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid canaryUrl; expected an absolute http(s) URL")
+		}
+	}
+	proxy.MaxAge = strings.TrimSpace(proxy.MaxAge)
+	if proxy.MaxAge != "" {
+		if d, err := time.ParseDuration(proxy.MaxAge); err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid maxAge; expected a duration such as 24h")
+		}
 	}
+	if proxy.MaxCacheBytes < 0 {
+		return nil, fmt.Errorf("maxCacheBytes must not be negative")
+	}
+	proxy.UpdatePolicy = strings.ToLower(strings.TrimSpace(proxy.UpdatePolicy))
+	if _, err := parseUpdatePolicy(proxy.UpdatePolicy); err != nil {
+		return nil, err
+	}
+	proxy.Revision = ""
+	return json.Marshal(proxy)
+}
 
-	data, err := json.Marshal(proxy)
+// checkNotDeclared fails with errProxyDeclared if the stored proxy called
+// name comes from the proxies file.
+func (p *ProxyManager) checkNotDeclared(ctx context.Context, name string) error {
+	if !proxyNameRe.MatchString(name) {
+		return nil
+	}
+	stored, err := p.load(ctx, proxyConfigKey(name))
+	if storage.IsNotFound(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	cfgKey := path.Join(proxyConfigPrefix, proxy.Name+".json")
-	return p.store.Put(ctx, cfgKey, strings.NewReader(string(data)), "application/json", int64(len(data)))
+	if stored.Declared {
+		return errProxyDeclared
+	}
+	return nil
 }
 
 func (p *ProxyManager) Delete(ctx context.Context, name string) error {
@@ -123,7 +324,22 @@ func (p *ProxyManager) Delete(ctx context.Context, name string) error {
 	if !proxyNameRe.MatchString(name) {
 		return fmt.Errorf("invalid name")
 	}
-	base := path.Join(proxyConfigPrefix, name+".json")
+	if err := p.checkNotDeclared(ctx, name); err != nil {
+		return err
+	}
+	if err := p.deleteStored(ctx, name); err != nil {
+		return err
+	}
+	p.configChanged(ctx)
+	return nil
+}
+
+// deleteStored removes the stored configuration of the proxy called name
+// and its download statistics.
+func (p *ProxyManager) deleteStored(ctx context.Context, name string) error {
+	base := proxyConfigKey(name)
+	p.misses.forget(name)
+	_ = p.store.Delete(ctx, downloadStatsPrefix+name+".json")
 	_ = p.store.Delete(ctx, base+".sha1")
 	_ = p.store.Delete(ctx, base+".md5")
 	return p.store.Delete(ctx, base)
@@ -134,30 +350,190 @@ func (p *ProxyManager) Update(ctx context.Context, name string, proxy Proxy) err
 	return p.Add(ctx, proxy)
 }
 
-func (p *ProxyManager) FetchFromAny(ctx context.Context, artifactPath string) (string, bool, error) {
+// UpdateIfMatch replaces the proxy called name only if it is still at
+// revision ("*" matches any) and returns the new revision. On stores with
+// conditional writes the check and the write are atomic.
+func (p *ProxyManager) UpdateIfMatch(ctx context.Context, name string, proxy Proxy, revision string) (string, error) {
+	proxy.Name = name
+	proxy.Declared = false
+	data, err := encodeProxy(proxy)
+	if err != nil {
+		return "", err
+	}
+	etag, err := p.checkRevision(ctx, name, revision)
+	if err != nil {
+		return "", err
+	}
+	key := proxyConfigKey(name)
+	if cs, ok := p.store.(conditionalStorage); ok && etag != "" {
+		if _, err := cs.PutIfMatch(ctx, key, data, "application/json", etag); err != nil {
+			if storage.IsPreconditionFailed(err) {
+				return "", errProxyConflict
+			}
+			return "", err
+		}
+	} else if err := p.store.Put(ctx, key, strings.NewReader(string(data)), "application/json", int64(len(data))); err != nil {
+		return "", err
+	}
+	p.misses.forget(name)
+	p.configChanged(ctx)
+	return proxyRevision(data), nil
+}
+
+// DeleteIfMatch deletes the proxy called name only if it is still at
+// revision ("*" matches any).
+func (p *ProxyManager) DeleteIfMatch(ctx context.Context, name, revision string) error {
+	if !proxyNameRe.MatchString(name) {
+		return fmt.Errorf("invalid name")
+	}
+	if _, err := p.checkRevision(ctx, name, revision); err != nil {
+		return err
+	}
+	return p.Delete(ctx, name)
+}
+
+// checkRevision compares the stored configuration of name with revision and
+// returns the storage ETag it was read at. Declared proxies are refused
+// whatever the revision.
+func (p *ProxyManager) checkRevision(ctx context.Context, name, revision string) (string, error) {
+	resp, err := p.store.Get(ctx, proxyConfigKey(name))
+	if storage.IsNotFound(err) {
+		return "", errProxyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var stored Proxy
+	if json.Unmarshal(data, &stored) == nil && stored.Declared {
+		return "", errProxyDeclared
+	}
+	if revision != "*" && revision != proxyRevision(data) {
+		return "", errProxyConflict
+	}
+	return aws.ToString(resp.ETag), nil
+}
+
+func (p *ProxyManager) Invalidate(ctx context.Context, name string, req InvalidateRequest) ([]string, error) {
+	if !proxyNameRe.MatchString(name) {
+		return nil, invalidRequestError("invalid name")
+	}
+	target := strings.Trim(strings.TrimSpace(req.Path), "/")
+	pattern := strings.Trim(strings.TrimSpace(req.Pattern), "/")
+	if target == "" && pattern == "" {
+		return nil, invalidRequestError("path or pattern is required")
+	}
+	if target != "" && pattern != "" {
+		return nil, invalidRequestError("path and pattern are mutually exclusive")
+	}
+	if slices.Contains(strings.Split(target, "/"), "..") {
+		return nil, invalidRequestError("path must not contain .. segments")
+	}
+
+	_, found, err := p.findByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errProxyNotFound
+	}
+	p.misses.forget(name)
+
+	deleted := []string{}
+	done := map[string]struct{}{}
+	remove := func(rel string) error {
+		if _, ok := done[rel]; ok {
+			return nil
+		}
+		key := path.Join(name, rel)
+		if !strings.HasPrefix(key, name+"/") {
+			// never leave the cache namespace of the proxy
+			return invalidRequestError("path is outside the proxy cache")
+		}
+		if err := p.store.Delete(ctx, key); err != nil {
+			return err
+		}
+		if !isChecksumPath(rel) {
+			for _, ext := range storage.SidecarExtensions {
+				_ = p.store.Delete(ctx, key+ext)
+				done[rel+ext] = struct{}{}
+			}
+		}
+		done[rel] = struct{}{}
+		deleted = append(deleted, rel)
+		return nil
+	}
+
+	if target != "" {
+		// a single cached artifact, otherwise everything below the directory
+		if _, err := p.store.Head(ctx, path.Join(name, target)); err == nil {
+			return deleted, remove(target)
+		} else if !storage.IsNotFound(err) {
+			return nil, err
+		}
+		err := walkStore(ctx, p.store, path.Join(name, target), func(e storage.Entry) error {
+			return remove(strings.TrimPrefix(e.Path, name+"/"))
+		})
+		return deleted, err
+	}
+
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, invalidRequestError(err.Error())
+	}
+	err = walkStore(ctx, p.store, name, func(e storage.Entry) error {
+		rel := strings.TrimPrefix(e.Path, name+"/")
+		if !re.MatchString(rel) {
+			return nil
+		}
+		return remove(rel)
+	})
+	return deleted, err
+}
+
+// FetchFromAny tries every proxy in order, or all at once in race mode. A
+// caching proxy stores the artifact and returns its cache key; a pass-through
+// proxy returns the upstream response for the caller to stream and close.
+func (p *ProxyManager) FetchFromAny(ctx context.Context, artifactPath string) (string, *http.Response, bool, error) {
 	proxies, err := p.List(ctx)
 	if err != nil {
-		return "", false, err
+		return "", nil, false, err
+	}
+	if p.race && len(proxies) > 1 {
+		return p.fetchRace(ctx, proxies, artifactPath)
 	}
 	var lastStatus ProxyStatusError
 	for _, pr := range proxies {
 		key := path.Join(pr.Name, artifactPath)
-		found, err := p.FetchAndCache(ctx, key)
+		var (
+			resp  *http.Response
+			found bool
+			err   error
+		)
+		if pr.caches() {
+			found, err = p.FetchAndCache(ctx, key)
+		} else {
+			resp, found, err = p.Passthrough(ctx, key)
+		}
 		if err != nil {
 			if se, ok := err.(ProxyStatusError); ok && (se.Code == http.StatusUnauthorized || se.Code == http.StatusForbidden || se.Code == http.StatusNotFound) {
 				lastStatus = se
 				continue
 			}
-			return "", false, err
+			return "", nil, false, err
 		}
 		if found {
-			return key, true, nil
+			return key, resp, true, nil
 		}
 	}
 	if lastStatus.Code != 0 {
-		return "", false, lastStatus
+		return "", nil, false, lastStatus
 	}
-	return "", false, nil
+	return "", nil, false, nil
 }
 
 func (p *ProxyManager) HeadFromAny(ctx context.Context, artifactPath string) (*http.Response, bool, error) {
@@ -165,6 +541,9 @@ func (p *ProxyManager) HeadFromAny(ctx context.Context, artifactPath string) (*h
 	if err != nil {
 		return nil, false, err
 	}
+	if p.race && len(proxies) > 1 {
+		return p.headRace(ctx, proxies, artifactPath)
+	}
 	var lastStatus ProxyStatusError
 	for _, pr := range proxies {
 		key := path.Join(pr.Name, artifactPath)
@@ -199,6 +578,29 @@ func (p *ProxyManager) findByName(ctx context.Context, name string) (Proxy, bool
 	return Proxy{}, false, nil
 }
 
+// lookup finds the proxy called name without listing the store: from the
+// cached list when there is one, otherwise by reading its configuration.
+// Revalidate runs on every cached GET and HEAD, so keys outside proxies
+// must stay cheap.
+func (p *ProxyManager) lookup(ctx context.Context, name string) (Proxy, bool, error) {
+	p.configMu.Lock()
+	cached := p.cached
+	p.configMu.Unlock()
+	if cached != nil {
+		for _, pr := range cached {
+			if pr.Name == name {
+				return pr, true, nil
+			}
+		}
+		return Proxy{}, false, nil
+	}
+	proxy, err := p.Get(ctx, name)
+	if errors.Is(err, errProxyNotFound) {
+		return Proxy{}, false, nil
+	}
+	return proxy, err == nil, err
+}
+
 func splitProxyKey(key string) (proxyName, artifactPath string, ok bool) {
 	parts := strings.SplitN(strings.TrimPrefix(key, "/"), "/", 2)
 	if len(parts) < 2 {
@@ -207,34 +609,74 @@ func splitProxyKey(key string) (proxyName, artifactPath string, ok bool) {
 	return parts[0], parts[1], true
 }
 
+func isChecksumPath(p string) bool {
+	return storage.IsChecksumSidecar(p)
+}
+
+// globToRegexp compiles a path glob where "*" and "?" stay within a path
+// segment and "**" spans any number of segments.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+					continue
+				}
+				b.WriteString(".*")
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// walkStore calls fn for every file below prefix, descending into directories.
+func walkStore(ctx context.Context, store Storage, prefix string, fn func(storage.Entry) error) error {
+	return store.Walk(ctx, prefix, fn)
+}
+
 func (p *ProxyManager) FetchAndCache(ctx context.Context, key string) (bool, error) {
 	name, artifactPath, ok := splitProxyKey(key)
 	if !ok {
 		return false, nil
 	}
 
-	isChecksum := strings.HasSuffix(strings.ToLower(artifactPath), ".sha1") || strings.HasSuffix(strings.ToLower(artifactPath), ".md5")
-
 	proxy, found, err := p.findByName(ctx, name)
 	if err != nil {
 		return false, err
 	}
-	if !found {
+	if !found || !proxy.caches() {
 		return false, nil
 	}
-
-	url := strings.TrimSuffix(proxy.URL, "/") + "/" + artifactPath
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
+	if err := checkQuarantine(ctx, p.store, key); err != nil {
 		return false, err
 	}
-	resp, err := p.httpClient.Do(req)
+	if p.knownMiss(proxy, artifactPath) {
+		return false, nil
+	}
+
+	resp, err := p.fetch(ctx, proxy, artifactPath, nil)
 	if err != nil {
 		return false, err
 	}
 	defer resp.Body.Close()
+	canary := p.canary(proxy, artifactPath, resp)
 
 	if resp.StatusCode == http.StatusNotFound {
+		p.recordMiss(proxy, artifactPath)
+		canary.compare(resp.StatusCode)
 		return false, nil
 	}
 	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
@@ -244,48 +686,215 @@ func (p *ProxyManager) FetchAndCache(ctx context.Context, key string) (bool, err
 		return false, ProxyStatusError{Code: resp.StatusCode}
 	}
 
-	tmp, err := os.CreateTemp("", "heimdall-proxy-*")
+	if err := p.cache(ctx, proxy, key, resp); err != nil {
+		return false, err
+	}
+	canary.compare(resp.StatusCode)
+	p.scans.Submit(proxy.Name, key)
+	return true, nil
+}
+
+// Passthrough fetches key from a proxy configured with cache=false. found is
+// false when the key does not belong to such a proxy or upstream has no such
+// artifact; otherwise the caller must close the response body.
+func (p *ProxyManager) Passthrough(ctx context.Context, key string) (*http.Response, bool, error) {
+	name, artifactPath, ok := splitProxyKey(key)
+	if !ok {
+		return nil, false, nil
+	}
+	proxy, found, err := p.findByName(ctx, name)
 	if err != nil {
+		return nil, false, err
+	}
+	if !found || proxy.caches() {
+		return nil, false, nil
+	}
+	if p.knownMiss(proxy, artifactPath) {
+		return nil, false, nil
+	}
+
+	resp, err := p.fetch(ctx, proxy, artifactPath, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		p.recordMiss(proxy, artifactPath)
+		return nil, false, nil
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, false, ProxyStatusError{Code: resp.StatusCode}
+	}
+	return resp, true, nil
+}
+
+// Revalidate checks a cached object that is older than the proxy's maxAge
+// against the upstream with a conditional GET. It reports whether the cached
+// copy was replaced with new content.
+func (p *ProxyManager) Revalidate(ctx context.Context, key string, cachedAt time.Time, etag string) (bool, error) {
+	name, artifactPath, ok := splitProxyKey(key)
+	if !ok {
+		return false, nil
+	}
+	proxy, found, err := p.lookup(ctx, name)
+	if err != nil || !found {
 		return false, err
 	}
+	if !proxy.stale(artifactPath, cachedAt, time.Now()) {
+		return false, nil
+	}
+
+	header := http.Header{}
+	header.Set("If-Modified-Since", cachedAt.UTC().Format(http.TimeFormat))
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	resp, err := p.fetch(ctx, proxy, artifactPath, header)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return false, p.store.Touch(ctx, key, cacheMetadata(etag))
+	case resp.StatusCode >= 300:
+		// keep serving the cached copy; the next access retries
+		return false, ProxyStatusError{Code: resp.StatusCode}
+	}
+
+	if err := p.cache(ctx, proxy, key, resp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *ProxyManager) fetch(ctx context.Context, proxy Proxy, artifactPath string, header http.Header) (*http.Response, error) {
+	if rule, blocked := p.blocked.Match(artifactPath, nil); blocked {
+		return nil, BlockedError{Rule: rule}
+	}
+	release, err := p.limits.acquire(ctx, proxy.Name)
+	if err != nil {
+		return nil, err
+	}
+	traceUpstream(ctx, proxy.Name)
+	resp, err := p.upstreamDo(ctx, proxy, http.MethodGet, artifactPath, header)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// upstreamDo sends method for artifactPath to the proxy's URL and, while
+// the answer is a network error or a 5xx status, to each mirror in turn.
+// The last upstream's answer is returned whatever it is.
+func (p *ProxyManager) upstreamDo(ctx context.Context, proxy Proxy, method, artifactPath string, header http.Header) (*http.Response, error) {
+	base := proxy.URL
+	resp, err := p.upstreamRequest(ctx, base, method, artifactPath, header)
+	for _, mirror := range proxy.Mirrors {
+		if ctx.Err() != nil || (err == nil && resp.StatusCode < 500) {
+			break
+		}
+		fields := []zap.Field{zap.String("proxy", proxy.Name), zap.String("upstream", base), zap.String("next", mirror)}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		} else {
+			resp.Body.Close()
+			fields = append(fields, zap.Int("status", resp.StatusCode))
+		}
+		p.logger.Warn("proxy upstream failed; trying next mirror", fields...)
+		base = mirror
+		resp, err = p.upstreamRequest(ctx, base, method, artifactPath, header)
+	}
+	return resp, err
+}
+
+func (p *ProxyManager) upstreamRequest(ctx context.Context, base, method, artifactPath string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+"/"+artifactPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range header {
+		req.Header[k] = vals
+	}
+	return p.httpClient.Do(req)
+}
+
+func (p *ProxyManager) cache(ctx context.Context, proxy Proxy, key string, resp *http.Response) error {
+	tmp, err := os.CreateTemp("", "heimdall-proxy-*")
+	if err != nil {
+		return err
+	}
 	defer func() {
 		tmp.Close()
 		os.Remove(tmp.Name())
 	}()
 
-	sha1h := sha1.New()
-	md5h := md5.New()
-	if _, err := io.Copy(io.MultiWriter(tmp, sha1h, md5h), resp.Body); err != nil {
-		return false, err
+	sums := storage.NewChecksums(p.checksums...)
+	if _, err := io.Copy(io.MultiWriter(tmp, sums), resp.Body); err != nil {
+		return err
 	}
 	info, err := tmp.Stat()
 	if err != nil {
-		return false, err
+		return err
 	}
 	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
-		return false, err
+		return err
 	}
 	contentType := resp.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	if err := p.store.Put(ctx, key, tmp, contentType, info.Size()); err != nil {
-		return false, err
+	var metadata map[string]string
+	if proxy.maxAge() > 0 || isMetadataPath(key) {
+		metadata = cacheMetadata(resp.Header.Get("ETag"))
 	}
-
-	if !isChecksum {
-		sha1sum := hex.EncodeToString(sha1h.Sum(nil))
-		md5sum := hex.EncodeToString(md5h.Sum(nil))
-		if err := p.store.Put(ctx, key+".sha1", strings.NewReader(sha1sum), "text/plain", int64(len(sha1sum))); err != nil {
-			return false, err
+	if ms, ok := p.store.(metadataStorage); ok {
+		if err := ms.PutWithMetadata(ctx, key, tmp, contentType, info.Size(), metadata); err != nil {
+			return err
 		}
-		if err := p.store.Put(ctx, key+".md5", strings.NewReader(md5sum), "text/plain", int64(len(md5sum))); err != nil {
-			return false, err
+	} else {
+		if err := p.store.Put(ctx, key, tmp, contentType, info.Size()); err != nil {
+			return err
+		}
+		if metadata != nil {
+			if err := p.store.Touch(ctx, key, metadata); err != nil {
+				return err
+			}
 		}
 	}
 
-	return true, nil
+	if !isChecksumPath(key) {
+		for _, name := range p.checksums {
+			sum := sums.Sum(name)
+			if err := p.store.Put(ctx, key+"."+name, strings.NewReader(sum), "text/plain", int64(len(sum))); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// metadataStorage is implemented by stores that write user metadata along
+// with the object, saving the copy Touch would need.
+type metadataStorage interface {
+	PutWithMetadata(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64, metadata map[string]string) error
+}
+
+// upstreamETagMeta is the object metadata key holding the upstream ETag of a
+// cached proxy artifact, used for conditional revalidation.
+const upstreamETagMeta = "upstream-etag"
+
+func cacheMetadata(etag string) map[string]string {
+	if etag == "" {
+		return nil
+	}
+	return map[string]string{upstreamETagMeta: etag}
 }
 
 func (p *ProxyManager) ListPath(ctx context.Context, key string, limit int32) ([]storage.Entry, bool, error) {
@@ -308,16 +917,10 @@ func (p *ProxyManager) ListPath(ctx context.Context, key string, limit int32) ([
 		return nil, false, nil
 	}
 
-	target := strings.TrimSuffix(proxy.URL, "/") + "/" + artifactPath
-	if !strings.HasSuffix(target, "/") {
-		target += "/"
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
-	if err != nil {
-		return nil, true, err
+	if artifactPath != "" && !strings.HasSuffix(artifactPath, "/") {
+		artifactPath += "/"
 	}
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.upstreamDo(ctx, proxy, http.MethodGet, artifactPath, nil)
 	if err != nil {
 		return nil, true, err
 	}
@@ -372,15 +975,17 @@ func (p *ProxyManager) ListPath(ctx context.Context, key string, limit int32) ([
 				if isDir {
 					name += "/"
 				}
-				etype := "file"
+				entry := storage.Entry{
+					Name:   name,
+					Path:   path.Join(name, ""),
+					Type:   "file",
+					Source: proxy.Name,
+				}
 				if isDir {
-					etype = "dir"
+					entry.Type = "dir"
 				}
-				entries = append(entries, storage.Entry{
-					Name: name,
-					Path: path.Join(name, ""),
-					Type: etype,
-				})
+				entry.Size, entry.LastModified = listingDetails(n)
+				entries = append(entries, entry)
 				if limit > 0 && int32(len(entries)) >= limit {
 					return
 				}
@@ -398,6 +1003,41 @@ func (p *ProxyManager) ListPath(ctx context.Context, key string, limit int32) ([
 	return entries, true, nil
 }
 
+// listingLayouts are the date formats of the "date size" text that Maven
+// Central, nginx and Apache put after each link of a plain index page.
+var listingLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"02-Jan-2006 15:04:05",
+	"02-Jan-2006 15:04",
+}
+
+// listingDetails reads the size and modification time printed after the
+// link a of an index page. Either is zero when the page does not show it,
+// as with table layouts or sizes such as "1.2K".
+func listingDetails(a *html.Node) (size int64, modified *time.Time) {
+	if a.NextSibling == nil || a.NextSibling.Type != html.TextNode {
+		return 0, nil
+	}
+	fields := strings.Fields(a.NextSibling.Data)
+	if len(fields) < 2 {
+		return 0, nil
+	}
+	stamp := fields[0] + " " + fields[1]
+	for _, layout := range listingLayouts {
+		if t, err := time.Parse(layout, stamp); err == nil {
+			modified = &t
+			break
+		}
+	}
+	if len(fields) > 2 {
+		if n, err := strconv.ParseInt(fields[2], 10, 64); err == nil && n >= 0 {
+			size = n
+		}
+	}
+	return size, modified
+}
+
 func (p *ProxyManager) Head(ctx context.Context, key string) (*http.Response, bool, error) {
 	name, artifactPath, ok := splitProxyKey(key)
 	if !ok {
@@ -410,18 +1050,17 @@ func (p *ProxyManager) Head(ctx context.Context, key string) (*http.Response, bo
 	if !found {
 		return nil, false, nil
 	}
-
-	url := strings.TrimSuffix(proxy.URL, "/") + "/" + artifactPath
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
-	if err != nil {
-		return nil, false, err
+	if p.knownMiss(proxy, artifactPath) {
+		return nil, false, nil
 	}
-	resp, err := p.httpClient.Do(req)
+
+	resp, err := p.upstreamDo(ctx, proxy, http.MethodHead, artifactPath, nil)
 	if err != nil {
 		return nil, false, err
 	}
 	if resp.StatusCode == http.StatusNotFound {
 		resp.Body.Close()
+		p.recordMiss(proxy, artifactPath)
 		return nil, false, nil
 	}
 	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
@@ -5,11 +5,16 @@ import (
 	"context"
 	"errors"
 	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"path"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -20,9 +25,14 @@ import (
 type memObj struct {
 	body        []byte
 	contentType string
+	metadata    map[string]string
+	modified    time.Time
 }
 
+// memStore is safe for concurrent use; tests read data directly once the
+// server is done with it.
 type memStore struct {
+	mu   sync.RWMutex
 	data map[string]memObj
 }
 
@@ -31,7 +41,9 @@ func newMemStore() *memStore {
 }
 
 func (m *memStore) Get(ctx context.Context, key string) (*s3.GetObjectOutput, error) {
+	m.mu.RLock()
 	obj, ok := m.data[key]
+	m.mu.RUnlock()
 	if !ok {
 		return nil, errors.New("NotFound")
 	}
@@ -39,17 +51,23 @@ func (m *memStore) Get(ctx context.Context, key string) (*s3.GetObjectOutput, er
 		Body:          io.NopCloser(bytes.NewReader(obj.body)),
 		ContentLength: aws.Int64(int64(len(obj.body))),
 		ContentType:   aws.String(obj.contentType),
+		LastModified:  aws.Time(obj.modified),
+		Metadata:      obj.metadata,
 	}, nil
 }
 
 func (m *memStore) Head(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+	m.mu.RLock()
 	obj, ok := m.data[key]
+	m.mu.RUnlock()
 	if !ok {
 		return nil, errors.New("NotFound")
 	}
 	return &s3.HeadObjectOutput{
 		ContentLength: aws.Int64(int64(len(obj.body))),
 		ContentType:   aws.String(obj.contentType),
+		LastModified:  aws.Time(obj.modified),
+		Metadata:      obj.metadata,
 	}, nil
 }
 
@@ -58,7 +76,20 @@ func (m *memStore) Put(ctx context.Context, key string, body io.ReadSeeker, cont
 	if err != nil {
 		return err
 	}
-	m.data[key] = memObj{body: b, contentType: contentType}
+	m.mu.Lock()
+	m.data[key] = memObj{body: b, contentType: contentType, modified: time.Now()}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memStore) PutWithMetadata(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64, metadata map[string]string) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.data[key] = memObj{body: b, contentType: contentType, metadata: metadata, modified: time.Now()}
+	m.mu.Unlock()
 	return nil
 }
 
@@ -66,6 +97,8 @@ func (m *memStore) List(ctx context.Context, prefix string, limit int32) ([]stor
 	if prefix != "" && !strings.HasSuffix(prefix, "/") {
 		prefix += "/"
 	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	seen := map[string]storage.Entry{}
 	for key, obj := range m.data {
 		if !strings.HasPrefix(key, prefix) {
@@ -106,13 +139,75 @@ func (m *memStore) List(ctx context.Context, prefix string, limit int32) ([]stor
 	return entries, nil
 }
 
-func (m *memStore) GenerateChecksums(ctx context.Context, prefix string) error { return nil }
-func (m *memStore) CleanupBadChecksums(ctx context.Context, prefix string) error {
+func (m *memStore) ListPage(ctx context.Context, prefix, token string, limit int32) (storage.Page, error) {
+	entries, err := m.List(ctx, prefix, 0)
+	return storage.Page{Entries: entries}, err
+}
+
+func (m *memStore) Walk(ctx context.Context, prefix string, fn func(storage.Entry) error) error {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.data))
+	for key := range m.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Strings(keys)
+	for _, key := range keys {
+		m.mu.RLock()
+		obj := m.data[key]
+		m.mu.RUnlock()
+		if err := fn(storage.Entry{Name: path.Base(key), Path: key, Type: "file", Size: int64(len(obj.body)), LastModified: &obj.modified}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (m *memStore) GenerateChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error) {
+	return storage.ChecksumStats{}, nil
+}
+func (m *memStore) CleanupBadChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error) {
+	return storage.ChecksumStats{}, nil
+}
+
 func (m *memStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
 	delete(m.data, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memStore) Copy(ctx context.Context, src, dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.data[src]
+	if !ok {
+		return errors.New("NotFound")
+	}
+	m.data[dst] = obj
+	return nil
+}
+
+func (m *memStore) Touch(ctx context.Context, key string, metadata map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.data[key]
+	if !ok {
+		return errors.New("NotFound")
+	}
+	merged := maps.Clone(obj.metadata)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	maps.Copy(merged, metadata)
+	obj.metadata = merged
+	obj.modified = time.Now()
+	m.data[key] = obj
 	return nil
 }
 
@@ -171,6 +266,30 @@ func TestProxyFetchAndCache(t *testing.T) {
 	}
 }
 
+func TestProxyCacheConfiguredChecksums(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("JARCONTENT"))
+	}))
+	defer remote.Close()
+
+	store := newMemStore()
+	pm := NewProxyManager(store, zaptest.NewLogger(t))
+	pm.checksums = []string{"sha1", "sha256"}
+	if err := pm.Add(context.Background(), Proxy{Name: "central", URL: remote.URL}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	key := "central/com/acme/app/1.0/app-1.0.jar"
+	if _, err := pm.FetchAndCache(context.Background(), key); err != nil {
+		t.Fatalf("fetch and cache: %v", err)
+	}
+	if got := string(store.data[key+".sha256"].body); len(got) != 64 {
+		t.Fatalf("sha256 sidecar = %q", got)
+	}
+	if _, ok := store.data[key+".md5"]; ok {
+		t.Fatal("md5 sidecar written although not configured")
+	}
+}
+
 func TestProxyFetchChecksumDoesNotChain(t *testing.T) {
 	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/com/acme/app/1.0/app-1.0.jar.sha1" {
@@ -204,3 +323,367 @@ func TestProxyFetchChecksumDoesNotChain(t *testing.T) {
 		t.Fatalf("unexpected chained md5 stored")
 	}
 }
+
+func TestProxyFetchFromAnyRace(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("JARCONTENT"))
+	}))
+	defer fast.Close()
+
+	store := newMemStore()
+	pm := NewProxyManager(store, zaptest.NewLogger(t))
+	pm.race = true
+	noCache := false
+	if err := pm.Add(context.Background(), Proxy{Name: "a-slow", URL: slow.URL, Cache: &noCache}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	if err := pm.Add(context.Background(), Proxy{Name: "b-fast", URL: fast.URL}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+
+	start := time.Now()
+	key, resp, found, err := pm.FetchFromAny(context.Background(), "com/acme/app/1.0/app-1.0.jar")
+	if err != nil || !found || resp != nil {
+		t.Fatalf("fetch: found=%v resp=%v err=%v", found, resp, err)
+	}
+	if key != "b-fast/com/acme/app/1.0/app-1.0.jar" {
+		t.Fatalf("unexpected winner %q", key)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("race waited for the slow proxy")
+	}
+	if string(store.data[key].body) != "JARCONTENT" {
+		t.Fatalf("winner not cached")
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("slow proxy request was not cancelled")
+	}
+}
+
+func TestProxyFetchFromAnyRaceNotFound(t *testing.T) {
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer missing.Close()
+
+	store := newMemStore()
+	pm := NewProxyManager(store, zaptest.NewLogger(t))
+	pm.race = true
+	for _, name := range []string{"one", "two"} {
+		if err := pm.Add(context.Background(), Proxy{Name: name, URL: missing.URL}); err != nil {
+			t.Fatalf("add proxy: %v", err)
+		}
+	}
+
+	_, _, found, err := pm.FetchFromAny(context.Background(), "com/acme/app/1.0/app-1.0.jar")
+	if err != nil || found {
+		t.Fatalf("expected clean miss, got found=%v err=%v", found, err)
+	}
+}
+
+func TestProxyInvalidatePattern(t *testing.T) {
+	store := newMemStore()
+	pm := NewProxyManager(store, zaptest.NewLogger(t))
+	if err := pm.Add(context.Background(), Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2"}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	for _, k := range []string{
+		"central/com/acme/app/1.0/app-1.0.jar",
+		"central/com/acme/app/1.0/app-1.0.jar.sha1",
+		"central/com/acme/app/1.0/app-1.0.jar.md5",
+		"central/com/acme/app/1.0/app-1.0.pom",
+		"central/org/other/lib/2.0/lib-2.0.jar",
+	} {
+		store.data[k] = memObj{body: []byte("x")}
+	}
+
+	deleted, err := pm.Invalidate(context.Background(), "central", InvalidateRequest{Pattern: "com/acme/**/*.jar"})
+	if err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "com/acme/app/1.0/app-1.0.jar" {
+		t.Fatalf("unexpected deleted list %v", deleted)
+	}
+	for _, k := range []string{"central/com/acme/app/1.0/app-1.0.jar", "central/com/acme/app/1.0/app-1.0.jar.sha1", "central/com/acme/app/1.0/app-1.0.jar.md5"} {
+		if _, ok := store.data[k]; ok {
+			t.Fatalf("expected %s to be deleted", k)
+		}
+	}
+	for _, k := range []string{"central/com/acme/app/1.0/app-1.0.pom", "central/org/other/lib/2.0/lib-2.0.jar"} {
+		if _, ok := store.data[k]; !ok {
+			t.Fatalf("expected %s to be kept", k)
+		}
+	}
+}
+
+func TestProxyInvalidatePath(t *testing.T) {
+	store := newMemStore()
+	pm := NewProxyManager(store, zaptest.NewLogger(t))
+	if err := pm.Add(context.Background(), Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2"}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	store.data["central/com/acme/app/1.0/app-1.0.jar"] = memObj{body: []byte("x")}
+	store.data["central/com/acme/app/1.0/app-1.0.pom"] = memObj{body: []byte("x")}
+	store.data["central/com/acme/app/2.0/app-2.0.jar"] = memObj{body: []byte("x")}
+
+	deleted, err := pm.Invalidate(context.Background(), "central", InvalidateRequest{Path: "com/acme/app/1.0/"})
+	if err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if len(deleted) != 2 {
+		t.Fatalf("expected 2 deleted, got %v", deleted)
+	}
+	if _, ok := store.data["central/com/acme/app/2.0/app-2.0.jar"]; !ok {
+		t.Fatalf("sibling version should be kept")
+	}
+
+	if _, err := pm.Invalidate(context.Background(), "missing", InvalidateRequest{Path: "a"}); !errors.Is(err, errProxyNotFound) {
+		t.Fatalf("expected errProxyNotFound, got %v", err)
+	}
+
+	store.data["releases/com/acme/lib/1.0/lib-1.0.jar"] = memObj{body: []byte("x")}
+	for _, target := range []string{"../releases/com/acme", "com/../../releases", "../__tokens__"} {
+		var invalid invalidRequestError
+		if _, err := pm.Invalidate(context.Background(), "central", InvalidateRequest{Path: target}); !errors.As(err, &invalid) {
+			t.Errorf("%s: expected invalid request, got %v", target, err)
+		}
+	}
+	if _, ok := store.data["releases/com/acme/lib/1.0/lib-1.0.jar"]; !ok {
+		t.Fatalf("hosted artifact deleted through the proxy cache")
+	}
+}
+
+func TestProxyRevalidateExpired(t *testing.T) {
+	content := "OLD"
+	var gotIMS, gotINM string
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIMS = r.Header.Get("If-Modified-Since")
+		gotINM = r.Header.Get("If-None-Match")
+		if content == "OLD" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		_, _ = w.Write([]byte(content))
+	}))
+	defer remote.Close()
+
+	store := newMemStore()
+	pm := NewProxyManager(store, zaptest.NewLogger(t))
+	if err := pm.Add(context.Background(), Proxy{Name: "central", URL: remote.URL, MaxAge: "1h"}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	key := "central/com/acme/app/1.0/app-1.0.jar"
+	cachedAt := time.Now().Add(-2 * time.Hour)
+	store.data[key] = memObj{body: []byte("OLD"), modified: cachedAt, metadata: map[string]string{upstreamETagMeta: `"v1"`}}
+
+	refreshed, err := pm.Revalidate(context.Background(), key, cachedAt, `"v1"`)
+	if err != nil || refreshed {
+		t.Fatalf("expected not-modified revalidation, got refreshed=%v err=%v", refreshed, err)
+	}
+	if gotINM != `"v1"` || gotIMS == "" {
+		t.Fatalf("conditional headers not sent: ims=%q inm=%q", gotIMS, gotINM)
+	}
+	if time.Since(store.data[key].modified) > time.Minute {
+		t.Fatalf("expected cached object to be touched")
+	}
+
+	content = "NEW"
+	refreshed, err = pm.Revalidate(context.Background(), key, cachedAt, `"v1"`)
+	if err != nil || !refreshed {
+		t.Fatalf("expected refresh, got refreshed=%v err=%v", refreshed, err)
+	}
+	if got := string(store.data[key].body); got != "NEW" {
+		t.Fatalf("unexpected cached body %q", got)
+	}
+	if store.data[key].metadata[upstreamETagMeta] != `"v2"` {
+		t.Fatalf("upstream etag not recorded: %+v", store.data[key].metadata)
+	}
+
+	// fresh entries are not revalidated
+	gotIMS = ""
+	if refreshed, err := pm.Revalidate(context.Background(), key, time.Now(), ""); err != nil || refreshed || gotIMS != "" {
+		t.Fatalf("fresh entry should not hit upstream")
+	}
+}
+
+func TestProxyFailsOverToMirrors(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+	var brokenStatus atomic.Int32
+	brokenStatus.Store(http.StatusBadGateway)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(brokenStatus.Load()))
+	}))
+	defer broken.Close()
+	var mirrorHits atomic.Int32
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+		if r.URL.Path != "/maven2/com/acme/app/1.0/app-1.0.jar" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("JARCONTENT"))
+	}))
+	defer mirror.Close()
+
+	ctx := context.Background()
+	store := newMemStore()
+	pm := NewProxyManager(store, zaptest.NewLogger(t))
+	if err := pm.Add(ctx, Proxy{Name: "central", URL: down.URL, Mirrors: []string{broken.URL, mirror.URL + "/maven2"}}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+
+	found, err := pm.FetchAndCache(ctx, "central/com/acme/app/1.0/app-1.0.jar")
+	if err != nil || !found {
+		t.Fatalf("fetch and cache: found=%v err=%v", found, err)
+	}
+	if obj, err := store.Get(ctx, "central/com/acme/app/1.0/app-1.0.jar"); err != nil {
+		t.Fatalf("cached get: %v", err)
+	} else {
+		body, _ := io.ReadAll(obj.Body)
+		obj.Body.Close()
+		if string(body) != "JARCONTENT" {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+
+	resp, found, err := pm.Head(ctx, "central/com/acme/app/1.0/app-1.0.jar")
+	if err != nil || !found {
+		t.Fatalf("head: found=%v err=%v", found, err)
+	}
+	resp.Body.Close()
+
+	// A 404 is an answer, not a failure: later mirrors are not asked.
+	hits := mirrorHits.Load()
+	brokenStatus.Store(http.StatusNotFound)
+	if found, err := pm.FetchAndCache(ctx, "central/com/acme/app/2.0/app-2.0.jar"); err != nil || found {
+		t.Fatalf("missing artifact: found=%v err=%v", found, err)
+	}
+	if mirrorHits.Load() != hits {
+		t.Errorf("mirror asked after a 404 from the one before")
+	}
+}
+
+func TestProxyAddRejectsInvalidMirror(t *testing.T) {
+	pm := NewProxyManager(newMemStore(), zaptest.NewLogger(t))
+	if err := pm.Add(context.Background(), Proxy{Name: "central", URL: "https://example.com", Mirrors: []string{"repo.example.com/maven2"}}); err == nil {
+		t.Fatalf("expected invalid mirror error")
+	}
+}
+
+func TestProxyAddRejectsInvalidMaxAge(t *testing.T) {
+	pm := NewProxyManager(newMemStore(), zaptest.NewLogger(t))
+	if err := pm.Add(context.Background(), Proxy{Name: "central", URL: "https://example.com", MaxAge: "soon"}); err == nil {
+		t.Fatalf("expected invalid maxAge error")
+	}
+}
+
+func TestProxyUpdateRequiresCurrentRevision(t *testing.T) {
+	for name, store := range map[string]Storage{"plain": newMemStore(), "conditional": condStore{newMemStore()}} {
+		t.Run(name, func(t *testing.T) {
+			srv := New(store, zaptest.NewLogger(t), nil, "", "")
+			if err := srv.proxy.Add(context.Background(), Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2"}); err != nil {
+				t.Fatalf("add proxy: %v", err)
+			}
+			do := func(method, ifMatch, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, "/api/v1/proxies/central", strings.NewReader(body))
+				if ifMatch != "" {
+					req.Header.Set("If-Match", ifMatch)
+				}
+				rr := httptest.NewRecorder()
+				srv.Handler().ServeHTTP(rr, req)
+				return rr
+			}
+
+			rr := do(http.MethodGet, "", "")
+			etag := rr.Header().Get("ETag")
+			if rr.Code != http.StatusOK || etag == "" || !strings.Contains(rr.Body.String(), `"revision":`) {
+				t.Fatalf("get proxy: %d %q %s", rr.Code, etag, rr.Body.String())
+			}
+
+			update := `{"url":"https://repo.maven.apache.org/maven2","maxAge":"24h"}`
+			if rr := do(http.MethodPut, "", update); rr.Code != http.StatusPreconditionRequired {
+				t.Fatalf("expected 428 without If-Match, got %d", rr.Code)
+			}
+			rr = do(http.MethodPut, etag, update)
+			if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+				t.Fatalf("update: %d %s", rr.Code, rr.Body.String())
+			}
+			// A second admin still holding the old revision is rejected.
+			if rr := do(http.MethodPut, etag, `{"url":"https://example.com"}`); rr.Code != http.StatusConflict {
+				t.Fatalf("expected 409 for a stale update, got %d", rr.Code)
+			}
+			if rr := do(http.MethodDelete, etag, ""); rr.Code != http.StatusConflict {
+				t.Fatalf("expected 409 for a stale delete, got %d", rr.Code)
+			}
+			pr, err := srv.proxy.Get(context.Background(), "central")
+			if err != nil || pr.MaxAge != "24h" {
+				t.Fatalf("expected the first update to stick: %+v %v", pr, err)
+			}
+
+			if rr := do(http.MethodDelete, "*", ""); rr.Code != http.StatusNoContent {
+				t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+			}
+			if rr := do(http.MethodPut, "*", update); rr.Code != http.StatusNotFound {
+				t.Fatalf("expected 404 after delete, got %d", rr.Code)
+			}
+		})
+	}
+}
+
+func TestProxyEvictCacheLeastRecentlyDownloaded(t *testing.T) {
+	store := newMemStore()
+	pm := NewProxyManager(store, zaptest.NewLogger(t))
+	pr := Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2", MaxCacheBytes: 15}
+	if err := pm.Add(context.Background(), pr); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	for _, k := range []string{"old/a.jar", "recent/b.jar", "new/c.jar"} {
+		store.data["central/"+k] = memObj{body: bytes.Repeat([]byte("x"), 10)}
+		store.data["central/"+k+".sha1"] = memObj{body: []byte("s")}
+	}
+
+	now := time.Now()
+	hits := map[string]time.Time{
+		"central/old/a.jar":    now.Add(-2 * time.Hour),
+		"central/recent/b.jar": now.Add(-time.Minute),
+		"central/new/c.jar":    now,
+		"hosted/ignored.jar":   now,
+	}
+	evicted, freed, err := pm.EvictCache(context.Background(), pr, hits)
+	if err != nil {
+		t.Fatalf("evict: %v", err)
+	}
+	if evicted != 2 || freed != 22 {
+		t.Fatalf("expected 2 artifacts / 22 bytes evicted, got %d / %d", evicted, freed)
+	}
+	if _, ok := store.data["central/new/c.jar"]; !ok {
+		t.Fatalf("most recently downloaded artifact was evicted")
+	}
+	for _, k := range []string{"central/old/a.jar", "central/old/a.jar.sha1", "central/recent/b.jar"} {
+		if _, ok := store.data[k]; ok {
+			t.Fatalf("expected %s to be evicted", k)
+		}
+	}
+
+	stats, err := pm.loadDownloadStats(context.Background(), "central")
+	if err != nil {
+		t.Fatalf("load stats: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected stats for the remaining artifact only, got %v", stats)
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ProxiesFile is the format of the proxies file (PROXIES_FILE), which
+// declares proxies next to the ones created through the API, e.g. from a
+// Kubernetes ConfigMap kept in git.
+type ProxiesFile struct {
+	Proxies []Proxy `json:"proxies"`
+}
+
+// ReadProxiesFile reads and validates the proxies file at path.
+func ReadProxiesFile(path string) ([]Proxy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseProxiesFile(data)
+}
+
+func parseProxiesFile(data []byte) ([]Proxy, error) {
+	var file ProxiesFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid proxies file: %w", err)
+	}
+	seen := make(map[string]bool, len(file.Proxies))
+	for i, pr := range file.Proxies {
+		pr.Name = strings.TrimSpace(pr.Name)
+		if _, err := encodeProxy(pr); err != nil {
+			return nil, fmt.Errorf("proxy %q: %w", pr.Name, err)
+		}
+		if seen[pr.Name] {
+			return nil, fmt.Errorf("proxy %q is declared twice", pr.Name)
+		}
+		seen[pr.Name] = true
+		pr.Declared = true
+		pr.Revision = ""
+		file.Proxies[i] = pr
+	}
+	return file.Proxies, nil
+}
+
+// applyDeclared makes the stored proxies match declared: declared proxies
+// are created or overwritten, taking over API-created proxies of the same
+// name, and proxies declared before but no longer are deleted. It returns
+// the names it wrote and deleted.
+func (p *ProxyManager) applyDeclared(ctx context.Context, declared []Proxy) (applied, removed []string, err error) {
+	encoded := make(map[string][]byte, len(declared))
+	for _, pr := range declared {
+		data, err := encodeProxy(pr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("proxy %q: %w", pr.Name, err)
+		}
+		encoded[pr.Name] = data
+	}
+	stored, err := p.listStored(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if len(applied)+len(removed) > 0 {
+			p.configChanged(ctx)
+		}
+	}()
+
+	for _, pr := range stored {
+		data, ok := encoded[pr.Name]
+		switch {
+		case ok && pr.Revision == proxyRevision(data):
+			delete(encoded, pr.Name)
+		case !ok && pr.Declared:
+			if err := p.deleteStored(ctx, pr.Name); err != nil {
+				return applied, removed, err
+			}
+			removed = append(removed, pr.Name)
+		}
+	}
+	for _, pr := range declared {
+		data, ok := encoded[pr.Name]
+		if !ok {
+			continue
+		}
+		p.misses.forget(pr.Name)
+		if err := p.store.Put(ctx, proxyConfigKey(pr.Name), strings.NewReader(string(data)), "application/json", int64(len(data))); err != nil {
+			return applied, removed, err
+		}
+		applied = append(applied, pr.Name)
+	}
+	return applied, removed, nil
+}
+
+// ApplyProxiesFile reconciles the stored proxies with the proxies file at
+// path; nothing is changed when the file is invalid.
+func (s *Server) ApplyProxiesFile(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return s.applyProxiesFile(ctx, path, data)
+}
+
+func (s *Server) applyProxiesFile(ctx context.Context, path string, data []byte) error {
+	declared, err := parseProxiesFile(data)
+	if err != nil {
+		return err
+	}
+	applied, removed, err := s.proxy.applyDeclared(ctx, declared)
+	if len(applied)+len(removed) > 0 {
+		s.logger.Info("proxies file applied", zap.String("path", path), zap.Strings("updated", applied), zap.Strings("deleted", removed))
+	}
+	return err
+}
+
+// WatchProxiesFile checks the proxies file at path every interval until
+// ctx is done and applies it whenever its content changed. Errors are
+// logged and the file is applied again on the next check.
+func (s *Server) WatchProxiesFile(ctx context.Context, path string, interval time.Duration) {
+	var applied string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.logger.Warn("read proxies file", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		if revision := proxyRevision(data); revision != applied {
+			if err := s.applyProxiesFile(ctx, path, data); err != nil {
+				s.logger.Error("apply proxies file", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			applied = revision
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestApplyProxiesFile(t *testing.T) {
+	ctx := context.Background()
+	srv := NewWithOptions(newMemStore(), zaptest.NewLogger(t), nil, Options{})
+	file := filepath.Join(t.TempDir(), "proxies.json")
+	write := func(data string) {
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func() map[string]Proxy {
+		proxies, err := srv.proxy.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]Proxy{}
+		for _, pr := range proxies {
+			out[pr.Name] = pr
+		}
+		return out
+	}
+
+	if err := srv.proxy.Add(ctx, Proxy{Name: "manual", URL: "https://manual.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	write(`{"proxies": [
+		{"name": "central", "url": "https://repo1.maven.org/maven2"},
+		{"name": "gradle", "url": "https://plugins.gradle.org/m2", "maxAge": "24h"}
+	]}`)
+	if err := srv.ApplyProxiesFile(ctx, file); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	got := names()
+	if len(got) != 3 || !got["central"].Declared || !got["gradle"].Declared || got["manual"].Declared {
+		t.Fatalf("after first apply: %+v", got)
+	}
+
+	if err := srv.proxy.Add(ctx, Proxy{Name: "central", URL: "https://other.example.com"}); !errors.Is(err, errProxyDeclared) {
+		t.Errorf("add over declared proxy: %v", err)
+	}
+	if err := srv.proxy.DeleteIfMatch(ctx, "gradle", "*"); !errors.Is(err, errProxyDeclared) {
+		t.Errorf("delete declared proxy: %v", err)
+	}
+
+	write(`{"proxies": [{"name": "central", "url": "https://mirror.example.com/maven2"}]}`)
+	if err := srv.ApplyProxiesFile(ctx, file); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	got = names()
+	if _, ok := got["gradle"]; ok || got["central"].URL != "https://mirror.example.com/maven2" || got["manual"].URL == "" {
+		t.Fatalf("after second apply: %+v", got)
+	}
+
+	write(`{"proxies": [{"name": "central", "url": ""}]}`)
+	if err := srv.ApplyProxiesFile(ctx, file); err == nil {
+		t.Fatal("expected invalid file error")
+	}
+	if names()["central"].URL != "https://mirror.example.com/maven2" {
+		t.Error("invalid file changed the stored proxies")
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+)
+
+// raceResult is the outcome of one proxy in a race. resp is nil when the
+// proxy does not have the artifact.
+type raceResult struct {
+	idx  int
+	resp *http.Response
+	err  error
+}
+
+// raceProxies calls do for every proxy concurrently and returns the index and
+// response of the first one that has the artifact; the others are cancelled.
+// The winner's response body cancels its request when closed. Without a
+// winner the errors are reduced in proxy order, like the sequential lookup.
+func raceProxies(ctx context.Context, proxies []Proxy, do func(context.Context, Proxy) (*http.Response, error)) (int, *http.Response, error) {
+	results := make(chan raceResult, len(proxies))
+	cancels := make([]context.CancelFunc, len(proxies))
+	for i, pr := range proxies {
+		rctx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		go func() {
+			resp, err := do(rctx, pr)
+			results <- raceResult{idx: i, resp: resp, err: err}
+		}()
+	}
+
+	errs := make([]error, len(proxies))
+	for pending := len(proxies); pending > 0; pending-- {
+		res := <-results
+		if res.err != nil || res.resp == nil {
+			errs[res.idx] = res.err
+			cancels[res.idx]()
+			continue
+		}
+		for i, cancel := range cancels {
+			if i != res.idx {
+				cancel()
+			}
+		}
+		go drainRace(results, pending-1)
+		res.resp.Body = &cancelBody{ReadCloser: res.resp.Body, cancel: cancels[res.idx]}
+		return res.idx, res.resp, nil
+	}
+
+	var lastStatus ProxyStatusError
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if se, ok := err.(ProxyStatusError); ok && (se.Code == http.StatusUnauthorized || se.Code == http.StatusForbidden || se.Code == http.StatusNotFound) {
+			lastStatus = se
+			continue
+		}
+		return -1, nil, err
+	}
+	if lastStatus.Code != 0 {
+		return -1, nil, lastStatus
+	}
+	return -1, nil, nil
+}
+
+// drainRace closes the responses of proxies that finished after the winner.
+func drainRace(results <-chan raceResult, n int) {
+	for ; n > 0; n-- {
+		if res := <-results; res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// fetchRace is FetchFromAny in race mode.
+func (p *ProxyManager) fetchRace(ctx context.Context, proxies []Proxy, artifactPath string) (string, *http.Response, bool, error) {
+	idx, resp, err := raceProxies(ctx, proxies, func(ctx context.Context, pr Proxy) (*http.Response, error) {
+		if pr.caches() {
+			if err := checkQuarantine(ctx, p.store, path.Join(pr.Name, artifactPath)); err != nil {
+				return nil, err
+			}
+		}
+		if p.knownMiss(pr, artifactPath) {
+			return nil, nil
+		}
+		resp, err := p.fetch(ctx, pr, artifactPath, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			p.recordMiss(pr, artifactPath)
+			return nil, nil
+		}
+		if resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return nil, ProxyStatusError{Code: resp.StatusCode}
+		}
+		return resp, nil
+	})
+	if err != nil || resp == nil {
+		return "", nil, false, err
+	}
+
+	pr := proxies[idx]
+	key := path.Join(pr.Name, artifactPath)
+	if !pr.caches() {
+		return key, resp, true, nil
+	}
+	defer resp.Body.Close()
+	if err := p.cache(ctx, pr, key, resp); err != nil {
+		return "", nil, false, err
+	}
+	p.scans.Submit(pr.Name, key)
+	return key, nil, true, nil
+}
+
+// headRace is HeadFromAny in race mode.
+func (p *ProxyManager) headRace(ctx context.Context, proxies []Proxy, artifactPath string) (*http.Response, bool, error) {
+	_, resp, err := raceProxies(ctx, proxies, func(ctx context.Context, pr Proxy) (*http.Response, error) {
+		resp, _, err := p.Head(ctx, path.Join(pr.Name, artifactPath))
+		return resp, err
+	})
+	if err != nil || resp == nil {
+		return nil, false, err
+	}
+	return resp, true, nil
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+var coordinateRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+var errRelocationExists = errors.New("a POM already exists for these coordinates")
+
+type Coordinates struct {
+	GroupID    string `json:"groupId" example:"com.acme"`
+	ArtifactID string `json:"artifactId" example:"app"`
+	Version    string `json:"version,omitempty" example:"1.0"`
+}
+
+type RelocationRequest struct {
+	// Repository is the hosted repository (first path segment) to publish
+	// into; empty publishes at the bucket root.
+	Repository string      `json:"repository,omitempty" example:"releases"`
+	From       Coordinates `json:"from"`
+	// To may leave fields empty to keep the old value, as Maven does.
+	To      Coordinates `json:"to"`
+	Message string      `json:"message,omitempty" example:"app moved to com.acme.platform"`
+	// Force replaces an existing POM at the old coordinates.
+	Force bool `json:"force,omitempty"`
+}
+
+type RelocationResponse struct {
+	Path     string   `json:"path"`
+	Metadata []string `json:"metadata"`
+}
+
+type relocationPOM struct {
+	XMLName      xml.Name `xml:"project"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	ModelVersion string   `xml:"modelVersion"`
+	GroupID      string   `xml:"groupId"`
+	ArtifactID   string   `xml:"artifactId"`
+	Version      string   `xml:"version"`
+	Packaging    string   `xml:"packaging"`
+	Relocation   struct {
+		GroupID    string `xml:"groupId,omitempty"`
+		ArtifactID string `xml:"artifactId,omitempty"`
+		Version    string `xml:"version,omitempty"`
+		Message    string `xml:"message,omitempty"`
+	} `xml:"distributionManagement>relocation"`
+}
+
+// @Summary Publish a relocation POM
+// @Description Writes a relocation POM (with checksums) at the old coordinates pointing to the new ones and refreshes the artifact's maven-metadata.xml. Empty target fields keep the old value.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body RelocationRequest true "Old and new coordinates"
+// @Success 201 {object} RelocationResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/admin/relocations [post]
+func (s *Server) handleRelocation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req RelocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if msg := validateRelocation(req); msg != "" {
+		writeAPIError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	resp, err := s.publishRelocation(r.Context(), req, time.Now())
+	if errors.Is(err, errRelocationExists) {
+		writeAPIError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.writeError(w, "publish relocation", err)
+		return
+	}
+	s.audit(r, "relocation.published",
+		zap.String("key", resp.Path),
+		zap.String("from", req.From.GroupID+":"+req.From.ArtifactID+":"+req.From.Version),
+		zap.String("to", req.To.GroupID+":"+req.To.ArtifactID+":"+req.To.Version),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Warn("encode relocation", zap.Error(err))
+	}
+}
+
+func validateRelocation(req RelocationRequest) string {
+	req.Repository = strings.Trim(req.Repository, "/")
+	if req.Repository != "" && (strings.Contains(req.Repository, "/") || isInternalPath(req.Repository) || !coordinateRe.MatchString(req.Repository)) {
+		return "invalid repository"
+	}
+	if req.From.GroupID == "" || req.From.ArtifactID == "" || req.From.Version == "" {
+		return "from.groupId, from.artifactId and from.version are required"
+	}
+	if req.To.GroupID == "" && req.To.ArtifactID == "" && req.To.Version == "" {
+		return "to must change at least one of groupId, artifactId or version"
+	}
+	for _, v := range []string{req.From.GroupID, req.From.ArtifactID, req.From.Version, req.To.GroupID, req.To.ArtifactID, req.To.Version} {
+		if v != "" && (!coordinateRe.MatchString(v) || strings.Contains(v, "..")) {
+			return "invalid coordinate " + v
+		}
+	}
+	return ""
+}
+
+func (s *Server) publishRelocation(ctx context.Context, req RelocationRequest, now time.Time) (RelocationResponse, error) {
+	from := req.From
+	artifactDir := path.Join(strings.Trim(req.Repository, "/"), strings.ReplaceAll(from.GroupID, ".", "/"), from.ArtifactID)
+	key := path.Join(artifactDir, from.Version, from.ArtifactID+"-"+from.Version+".pom")
+
+	if !req.Force {
+		if _, err := s.store.Head(ctx, key); err == nil {
+			return RelocationResponse{}, errRelocationExists
+		} else if !storage.IsNotFound(err) {
+			return RelocationResponse{}, err
+		}
+	}
+
+	pom := relocationPOM{
+		Xmlns:        "http://maven.apache.org/POM/4.0.0",
+		ModelVersion: "4.0.0",
+		GroupID:      from.GroupID,
+		ArtifactID:   from.ArtifactID,
+		Version:      from.Version,
+		Packaging:    "pom",
+	}
+	pom.Relocation.GroupID = req.To.GroupID
+	pom.Relocation.ArtifactID = req.To.ArtifactID
+	pom.Relocation.Version = req.To.Version
+	pom.Relocation.Message = req.Message
+
+	body, err := xml.MarshalIndent(pom, "", "  ")
+	if err != nil {
+		return RelocationResponse{}, err
+	}
+	if err := s.putXML(ctx, key, body); err != nil {
+		return RelocationResponse{}, err
+	}
+	s.roots.noteWrite(key)
+
+	metadata, err := s.rebuildMetadata(ctx, artifactDir, now)
+	if err != nil {
+		return RelocationResponse{}, err
+	}
+	return RelocationResponse{Path: key, Metadata: metadata}, nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPublishRelocation(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	body := `{"repository":"releases","from":{"groupId":"com.acme","artifactId":"app","version":"2.0"},"to":{"groupId":"com.acme.platform"},"message":"moved"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/relocations", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	key := "releases/com/acme/app/2.0/app-2.0.pom"
+	obj, ok := store.data[key]
+	if !ok {
+		t.Fatalf("relocation pom not written")
+	}
+	var pom relocationPOM
+	if err := xml.Unmarshal(obj.body, &pom); err != nil {
+		t.Fatalf("decode pom: %v", err)
+	}
+	if pom.Packaging != "pom" || pom.Relocation.GroupID != "com.acme.platform" || pom.Relocation.ArtifactID != "" || pom.Relocation.Message != "moved" {
+		t.Fatalf("unexpected pom %+v", pom)
+	}
+	for _, sidecar := range []string{key + ".sha1", key + ".md5", "releases/com/acme/app/maven-metadata.xml"} {
+		if _, ok := store.data[sidecar]; !ok {
+			t.Fatalf("%s not written", sidecar)
+		}
+	}
+	var meta artifactMetadata
+	if err := xml.Unmarshal(store.data["releases/com/acme/app/maven-metadata.xml"].body, &meta); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if meta.GroupID != "com.acme" || meta.Versioning.Release != "2.0" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+
+	// publishing again without force conflicts
+	req = httptest.NewRequest(http.MethodPost, "/admin/relocations", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestPublishRelocationValidation(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+	for _, body := range []string{
+		`{"from":{"groupId":"com.acme","artifactId":"app"},"to":{"groupId":"x"}}`,
+		`{"from":{"groupId":"com.acme","artifactId":"app","version":"1"},"to":{}}`,
+		`{"from":{"groupId":"../etc","artifactId":"app","version":"1"},"to":{"groupId":"x"}}`,
+		`{"repository":"__tokens__","from":{"groupId":"a","artifactId":"b","version":"1"},"to":{"groupId":"x"}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/relocations", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRootLayoutTTL = time.Minute
+	// maxLocatedKeys bounds the key → root index; it is reset when full.
+	maxLocatedKeys = 10000
+)
+
+// rootLayout caches the repository roots of the bucket and the top-level
+// directories inside each one, so the /packages fallback only probes roots
+// that can actually hold a key instead of every root on every miss.
+type rootLayout struct {
+	store Storage
+	ttl   time.Duration
+
+	// refreshMu keeps concurrent misses from listing the bucket at once.
+	refreshMu sync.Mutex
+
+	mu      sync.RWMutex
+	loaded  time.Time
+	roots   []string
+	top     map[string]map[string]bool
+	located map[string]string
+}
+
+func newRootLayout(store Storage, ttl time.Duration) *rootLayout {
+	if ttl <= 0 {
+		ttl = defaultRootLayoutTTL
+	}
+	return &rootLayout{store: store, ttl: ttl, located: make(map[string]string)}
+}
+
+// candidates returns the roots worth probing for key, the last root that
+// served it first.
+func (l *rootLayout) candidates(ctx context.Context, key string) ([]string, error) {
+	if err := l.refresh(ctx, false); err != nil {
+		return nil, err
+	}
+	first, _, _ := strings.Cut(key, "/")
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	known := l.located[key]
+	var out []string
+	if known != "" {
+		out = append(out, known)
+	}
+	for _, root := range l.roots {
+		if root != known && l.top[root][first] {
+			out = append(out, root)
+		}
+	}
+	return out, nil
+}
+
+// has reports whether root is a repository root of the bucket.
+func (l *rootLayout) has(ctx context.Context, root string) bool {
+	if err := l.refresh(ctx, false); err != nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.top[root]
+	return ok
+}
+
+func (l *rootLayout) refresh(ctx context.Context, force bool) error {
+	if !force && l.fresh() {
+		return nil
+	}
+	l.refreshMu.Lock()
+	defer l.refreshMu.Unlock()
+	if !force && l.fresh() {
+		return nil
+	}
+
+	entries, err := l.store.List(ctx, "", 1000)
+	if err != nil {
+		return err
+	}
+	var roots []string
+	top := make(map[string]map[string]bool)
+	for _, e := range entries {
+		root := strings.TrimSuffix(e.Name, "/")
+		if e.Type != "dir" || root == "" || isInternalPath(root) {
+			continue
+		}
+		children, err := l.store.List(ctx, root, 1000)
+		if err != nil {
+			return err
+		}
+		dirs := make(map[string]bool, len(children))
+		for _, c := range children {
+			dirs[strings.TrimSuffix(c.Name, "/")] = true
+		}
+		roots = append(roots, root)
+		top[root] = dirs
+	}
+
+	l.mu.Lock()
+	l.roots = roots
+	l.top = top
+	l.loaded = time.Now()
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *rootLayout) fresh() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return !l.loaded.IsZero() && time.Since(l.loaded) < l.ttl
+}
+
+// remember records that root served key.
+func (l *rootLayout) remember(key, root string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.located) >= maxLocatedKeys {
+		l.located = make(map[string]string)
+	}
+	l.located[key] = root
+}
+
+// forget drops key from the key → root index once it was deleted.
+func (l *rootLayout) forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.located, key)
+}
+
+// noteWrite adds the root and top-level directory of a newly stored object
+// so it is found before the next refresh.
+func (l *rootLayout) noteWrite(key string) {
+	root, rest, ok := strings.Cut(key, "/")
+	if !ok || root == "" || rest == "" || isInternalPath(key) {
+		return
+	}
+	first, _, _ := strings.Cut(rest, "/")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.loaded.IsZero() {
+		return
+	}
+	dirs, ok := l.top[root]
+	if !ok {
+		dirs = make(map[string]bool)
+		l.top[root] = dirs
+		l.roots = append(l.roots, root)
+	}
+	dirs[first] = true
+}
+
+// repositoryInfo describes a repository for the admin APIs.
+type repositoryInfo struct {
+	Name string
+	Type string // hosted or proxy
+	URL  string // proxies only
+}
+
+// listRepositories returns the proxies plus every top-level directory of
+// the store that is neither internal nor a proxy, sorted by name.
+func (s *Server) listRepositories(ctx context.Context) ([]repositoryInfo, error) {
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var repos []repositoryInfo
+	proxied := make(map[string]bool, len(proxies))
+	for _, pr := range proxies {
+		proxied[pr.Name] = true
+		repos = append(repos, repositoryInfo{Name: pr.Name, Type: "proxy", URL: pr.URL})
+	}
+	roots, err := s.store.List(ctx, "", 1000)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range roots {
+		name := strings.TrimSuffix(root.Name, "/")
+		if root.Type != "dir" || isInternalPath(name) || proxied[name] {
+			continue
+		}
+		repos = append(repos, repositoryInfo{Name: name, Type: "hosted"})
+	}
+	slices.SortFunc(repos, func(a, b repositoryInfo) int { return strings.Compare(a.Name, b.Name) })
+	return repos, nil
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+type countingStore struct {
+	*listStore
+	gets  []string
+	lists int
+}
+
+func (s *countingStore) Get(ctx context.Context, key string) (*s3.GetObjectOutput, error) {
+	s.gets = append(s.gets, key)
+	return s.listStore.Get(ctx, key)
+}
+
+func (s *countingStore) List(ctx context.Context, prefix string, limit int32) ([]storage.Entry, error) {
+	s.lists++
+	return s.listStore.List(ctx, prefix, limit)
+}
+
+func newLayoutStore() *countingStore {
+	store := &countingStore{listStore: newListStore()}
+	store.listByPrefix[""] = []storage.Entry{
+		{Name: "libs/", Path: "libs/", Type: "dir"},
+		{Name: "npm/", Path: "npm/", Type: "dir"},
+		{Name: "__tokens__/", Path: "__tokens__/", Type: "dir"},
+		{Name: "__probe__/", Path: "__probe__/", Type: "dir"},
+	}
+	store.listByPrefix["libs"] = []storage.Entry{{Name: "com/", Path: "libs/com/", Type: "dir"}}
+	store.listByPrefix["npm"] = []storage.Entry{{Name: "lodash/", Path: "npm/lodash/", Type: "dir"}}
+	store.objects["libs/com/acme/app/1.0/app-1.0.jar"] = []byte("LIBS")
+	return store
+}
+
+func TestPackagesGetProbesOnlyMatchingRoots(t *testing.T) {
+	store := newLayoutStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/packages/com/acme/app/1.0/app-1.0.jar", nil)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || rr.Body.String() != "LIBS" {
+			t.Fatalf("request %d: got %d %q", i, rr.Code, rr.Body.String())
+		}
+	}
+
+	for _, key := range store.gets {
+		if strings.HasPrefix(key, "npm/") || strings.HasPrefix(key, "__tokens__/") || strings.HasPrefix(key, "__probe__/") {
+			t.Fatalf("probed unrelated root: %s", key)
+		}
+	}
+	// one listing of the bucket root plus one per repository root
+	if store.lists != 3 {
+		t.Fatalf("expected layout to be listed once, got %d list calls", store.lists)
+	}
+}
+
+func TestRootLayoutNoteWrite(t *testing.T) {
+	store := newLayoutStore()
+	layout := newRootLayout(store, 0)
+	ctx := context.Background()
+
+	roots, err := layout.candidates(ctx, "org/acme/lib.jar")
+	if err != nil {
+		t.Fatalf("candidates: %v", err)
+	}
+	if len(roots) != 0 {
+		t.Fatalf("expected no candidates, got %v", roots)
+	}
+
+	layout.noteWrite("releases/org/acme/lib.jar")
+	roots, err = layout.candidates(ctx, "org/acme/lib.jar")
+	if err != nil {
+		t.Fatalf("candidates: %v", err)
+	}
+	if len(roots) != 1 || roots[0] != "releases" {
+		t.Fatalf("expected new root after write, got %v", roots)
+	}
+
+	layout.remember("com/acme/app.jar", "npm")
+	roots, _ = layout.candidates(ctx, "com/acme/app.jar")
+	if len(roots) != 2 || roots[0] != "npm" || roots[1] != "libs" {
+		t.Fatalf("expected remembered root first, got %v", roots)
+	}
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// s3EventUsageDelay batches the usage refreshes caused by a burst of
+	// events into one walk per repository.
+	s3EventUsageDelay = 10 * time.Second
+	maxS3EventBody    = 1 << 20
+)
+
+// s3EventNotification is the S3 bucket notification format, also sent by
+// MinIO webhook targets.
+type s3EventNotification struct {
+	Records []s3EventRecord `json:"Records"`
+}
+
+type s3EventRecord struct {
+	EventName string `json:"eventName"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// snsMessage is the envelope of SNS HTTP(S) deliveries.
+type snsMessage struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+	TopicArn     string `json:"TopicArn"`
+}
+
+// eventKeyMapper is implemented by stores that know their bucket and prefix,
+// see storage.Store.EventKey.
+type eventKeyMapper interface {
+	EventKey(bucket, key string) (string, bool)
+}
+
+// @Summary Ingest S3 event notifications
+// @Description Receives bucket notifications for objects written or deleted outside Heimdall (replication, manual fixes), either straight from MinIO webhook targets or through an SNS HTTP(S) subscription, which is confirmed automatically. Each event refreshes the lookup caches for the key and, shortly after, the usage gauges of its repository. Enabled by S3_EVENTS_TOKEN; the token goes in the token query parameter or as a Bearer token.
+// @Tags admin
+// @Accept json
+// @Param token query string false "S3_EVENTS_TOKEN"
+// @Success 204 {string} string "Processed"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/events/s3 [post]
+func (s *Server) handleS3Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.eventsToken)) != 1 {
+		writeAPIError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxS3EventBody))
+	if err != nil {
+		writeAPIError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if r.Header.Get("x-amz-sns-message-type") != "" {
+		var msg snsMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			writeAPIError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		switch msg.Type {
+		case "SubscriptionConfirmation":
+			if err := s.confirmSNSSubscription(r.Context(), msg.SubscribeURL); err != nil {
+				s.logger.Warn("confirm sns subscription", zap.String("topic", msg.TopicArn), zap.Error(err))
+				writeAPIError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.logger.Info("sns subscription confirmed", zap.String("topic", msg.TopicArn))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "Notification":
+			body = []byte(msg.Message)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	var n s3EventNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	for _, rec := range n.Records {
+		result := "ignored"
+		if s.applyS3Event(rec) {
+			result = "applied"
+		}
+		if s.metrics != nil {
+			s.metrics.S3Events.WithLabelValues(result).Inc()
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// confirmSNSSubscription visits the confirmation URL, which must point at
+// SNS so the endpoint cannot be used to make arbitrary requests.
+func (s *Server) confirmSNSSubscription(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || !strings.HasPrefix(u.Hostname(), "sns.") || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return errors.New("SubscribeURL must be an https URL of sns.*.amazonaws.com")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("confirm subscription: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// applyS3Event updates the caches for one event and reports whether it
+// concerned an artifact key.
+func (s *Server) applyS3Event(rec s3EventRecord) bool {
+	// Keys are URL-encoded in notifications, spaces as '+'.
+	key, err := url.QueryUnescape(rec.S3.Object.Key)
+	if err != nil {
+		return false
+	}
+	if m, ok := s.store.(eventKeyMapper); ok {
+		if key, ok = m.EventKey(rec.S3.Bucket.Name, key); !ok {
+			return false
+		}
+	}
+	if key == "" || isInternalPath(key) {
+		return false
+	}
+	switch {
+	case strings.Contains(rec.EventName, "ObjectCreated:"):
+		s.roots.noteWrite(key)
+		s.proxy.misses.drop(key)
+	case strings.Contains(rec.EventName, "ObjectRemoved:"):
+		s.roots.forget(key)
+	default:
+		return false
+	}
+	repo, _, _ := strings.Cut(key, "/")
+	s.markUsageDirty(repo)
+	return true
+}
+
+// markUsageDirty schedules a walk of repo to update the usage report and
+// gauges. Nothing is done before the first full report.
+func (s *Server) markUsageDirty(repo string) {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+	if s.usage == nil {
+		return
+	}
+	if s.usageDirty == nil {
+		s.usageDirty = make(map[string]bool)
+	}
+	s.usageDirty[repo] = true
+	if s.usageTimer == nil {
+		s.usageTimer = time.AfterFunc(s3EventUsageDelay, func() {
+			s.refreshDirtyUsage(context.Background())
+		})
+	}
+}
+
+// refreshDirtyUsage walks the repositories marked by events and replaces
+// their entries in the usage report.
+func (s *Server) refreshDirtyUsage(ctx context.Context) {
+	s.usageMu.Lock()
+	dirty := s.usageDirty
+	s.usageDirty, s.usageTimer = nil, nil
+	s.usageMu.Unlock()
+
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		s.logger.Warn("refresh repository usage", zap.Error(err))
+		return
+	}
+	for repo := range dirty {
+		info := repositoryInfo{Name: repo, Type: "hosted"}
+		if slices.ContainsFunc(proxies, func(pr Proxy) bool { return pr.Name == repo }) {
+			info.Type = "proxy"
+		}
+		u, err := s.repoUsage(ctx, info)
+		if err != nil {
+			s.logger.Warn("refresh repository usage", zap.String("repo", repo), zap.Error(err))
+			continue
+		}
+		s.usageMu.Lock()
+		if s.usage != nil {
+			s.usage = s.usage.withRepository(u)
+		}
+		s.usageMu.Unlock()
+		if s.metrics != nil {
+			s.metrics.RepoObjects.WithLabelValues(u.Name, u.Type).Set(float64(u.Objects))
+			s.metrics.RepoBytes.WithLabelValues(u.Name, u.Type).Set(float64(u.Bytes))
+		}
+	}
+}
+
+// withRepository returns a copy of r with u replacing (or added as) the
+// entry of its repository; reports are shared with readers, never changed.
+func (r *UsageReport) withRepository(u RepositoryUsage) *UsageReport {
+	next := &UsageReport{GeneratedAt: r.GeneratedAt, Total: RepositoryUsage{Name: "total"}}
+	found := false
+	for _, cur := range r.Repositories {
+		if cur.Name == u.Name {
+			cur, found = u, true
+		}
+		next.Repositories = append(next.Repositories, cur)
+	}
+	if !found {
+		next.Repositories = append(next.Repositories, u)
+		slices.SortFunc(next.Repositories, func(a, b RepositoryUsage) int { return strings.Compare(a.Name, b.Name) })
+	}
+	for _, cur := range next.Repositories {
+		next.Total.Objects += cur.Objects
+		next.Total.Bytes += cur.Bytes
+	}
+	return next
+}
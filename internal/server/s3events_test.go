@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestS3Events(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	put := func(key, body string) {
+		t.Helper()
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	put("releases/com/acme/app/1.0/app-1.0.jar", "12345")
+	m := metrics.New()
+	srv := NewWithOptions(store, zaptest.NewLogger(t), m, Options{AuthUser: "admin", AuthPassword: "secret", EventsToken: "s3cret"})
+	if _, err := srv.refreshUsage(ctx); err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+
+	post := func(target, body string, header map[string]string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr.Code
+	}
+	event := func(name, key string) string {
+		return `{"Records":[{"eventName":"` + name + `","s3":{"bucket":{"name":"bucket"},"object":{"key":"` + key + `","size":3}}}]}`
+	}
+
+	if code := post("/api/v1/events/s3", event("ObjectCreated:Put", "x"), nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", code)
+	}
+
+	// An object replicated into the bucket: a remembered upstream miss for
+	// the key is dropped and the repository usage is refreshed.
+	put("releases/com/acme/app/1.1/app+1.1.jar", "abc")
+	srv.proxy.misses.record("releases/com/acme/app/1.1/app+1.1.jar", time.Now())
+	if code := post("/api/v1/events/s3?token=s3cret", event("s3:ObjectCreated:Put", "releases/com/acme/app/1.1/app%2B1.1.jar"), nil); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if _, ok := srv.proxy.misses.entries["releases/com/acme/app/1.1/app+1.1.jar"]; ok {
+		t.Fatal("expected the miss to be dropped")
+	}
+	srv.refreshDirtyUsage(ctx)
+	if got := testutil.ToFloat64(m.RepoObjects.WithLabelValues("releases", "hosted")); got != 2 {
+		t.Fatalf("expected 2 objects after the event, got %v", got)
+	}
+	if srv.usage.Total.Bytes != 8 {
+		t.Fatalf("expected 8 bytes in total, got %d", srv.usage.Total.Bytes)
+	}
+
+	// The same event wrapped by SNS, sent with a Bearer token.
+	srv.roots.remember("releases/com/acme/app/1.0/app-1.0.jar", "releases")
+	envelope, _ := json.Marshal(snsMessage{Type: "Notification", Message: event("ObjectRemoved:Delete", "releases/com/acme/app/1.0/app-1.0.jar")})
+	headers := map[string]string{"Authorization": "Bearer s3cret", "x-amz-sns-message-type": "Notification"}
+	if code := post("/api/v1/events/s3", string(envelope), headers); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if _, ok := srv.roots.located["releases/com/acme/app/1.0/app-1.0.jar"]; ok {
+		t.Fatal("expected the deleted key to leave the root index")
+	}
+
+	// Internal objects are ignored.
+	post("/api/v1/events/s3?token=s3cret", event("ObjectCreated:Put", proxyConfigPrefix+"central.json"), nil)
+	if got := testutil.ToFloat64(m.S3Events.WithLabelValues("applied")); got != 2 {
+		t.Fatalf("expected 2 applied events, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.S3Events.WithLabelValues("ignored")); got != 1 {
+		t.Fatalf("expected 1 ignored event, got %v", got)
+	}
+
+	confirm, _ := json.Marshal(snsMessage{Type: "SubscriptionConfirmation", SubscribeURL: "http://169.254.169.254/latest"})
+	if code := post("/api/v1/events/s3?token=s3cret", string(confirm), map[string]string{"x-amz-sns-message-type": "SubscriptionConfirmation"}); code != http.StatusBadRequest {
+		t.Fatalf("expected non-SNS subscribe URLs to be refused, got %d", code)
+	}
+}
+
+func TestS3EventsDisabledWithoutToken(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), nil, "", "")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/events/s3", strings.NewReader(`{}`))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
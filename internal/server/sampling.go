@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// TelemetrySampling thins out per-request access log entries on busy
+// instances. Errors (status >= 400) and slow requests are always logged.
+type TelemetrySampling struct {
+	// Ratio of remaining requests that are logged, in [0, 1].
+	Ratio   float64
+	exclude []excludeRule
+}
+
+type excludeRule struct {
+	method string
+	path   *regexp.Regexp
+}
+
+// NewTelemetrySampling parses exclusions of the form "METHOD:glob" or "glob"
+// (any method), comma separated, e.g. "HEAD:**/*.sha1,HEAD:**/*.md5".
+func NewTelemetrySampling(ratio float64, exclude string) (*TelemetrySampling, error) {
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("sample ratio must be between 0 and 1")
+	}
+	ts := &TelemetrySampling{Ratio: ratio}
+	for _, raw := range strings.Split(exclude, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		method, pattern := "", raw
+		if m, p, ok := strings.Cut(raw, ":"); ok {
+			method, pattern = strings.ToUpper(m), p
+		}
+		re, err := globToRegexp(strings.TrimPrefix(pattern, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclusion %q: %w", raw, err)
+		}
+		ts.exclude = append(ts.exclude, excludeRule{method: method, path: re})
+	}
+	return ts, nil
+}
+
+// keep reports whether a completed, non-slow request should be logged.
+func (t *TelemetrySampling) keep(r *http.Request, status int) bool {
+	if t == nil || status >= 400 {
+		return true
+	}
+	p := strings.TrimPrefix(r.URL.Path, "/")
+	for _, rule := range t.exclude {
+		if (rule.method == "" || rule.method == r.Method) && rule.path.MatchString(p) {
+			return false
+		}
+	}
+	return t.Ratio >= 1 || rand.Float64() < t.Ratio
+}
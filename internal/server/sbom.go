@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// sbomLimit bounds an uploaded SBOM.
+const sbomLimit = 32 << 20
+
+// sbomFormat is an SBOM encoding Heimdall recognises, stored next to the
+// artifact as <artifact><suffix>.
+type sbomFormat struct {
+	name        string
+	suffix      string
+	contentType string
+}
+
+// sbomFormats in the order GET prefers them when no format is asked for.
+var sbomFormats = []sbomFormat{
+	{"cyclonedx-json", ".cdx.json", "application/vnd.cyclonedx+json"},
+	{"cyclonedx-xml", ".cdx.xml", "application/vnd.cyclonedx+xml"},
+	{"spdx-json", ".spdx.json", "application/spdx+json"},
+	{"spdx-tag-value", ".spdx", "text/spdx"},
+}
+
+type SBOMUploadResponse struct {
+	Path   string `json:"path" example:"releases/com/acme/lib/1.0/lib-1.0.jar"`
+	Format string `json:"format" example:"cyclonedx-json"`
+	File   string `json:"file" example:"releases/com/acme/lib/1.0/lib-1.0.jar.cdx.json"`
+}
+
+func isSBOMPath(key string) bool {
+	return slices.ContainsFunc(sbomFormats, func(f sbomFormat) bool { return strings.HasSuffix(key, f.suffix) })
+}
+
+// detectSBOM tells CycloneDX (JSON, XML) and SPDX (JSON, tag-value)
+// documents apart by content, since clients rarely send a precise
+// Content-Type.
+func detectSBOM(data []byte) (sbomFormat, bool) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var doc struct {
+			BOMFormat   string `json:"bomFormat"`
+			SPDXVersion string `json:"spdxVersion"`
+		}
+		if json.Unmarshal(trimmed, &doc) != nil {
+			return sbomFormat{}, false
+		}
+		if doc.BOMFormat == "CycloneDX" {
+			return sbomFormats[0], true
+		}
+		if strings.HasPrefix(doc.SPDXVersion, "SPDX-") {
+			return sbomFormats[2], true
+		}
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		dec := xml.NewDecoder(bytes.NewReader(trimmed))
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return sbomFormat{}, false
+			}
+			if el, ok := tok.(xml.StartElement); ok {
+				if el.Name.Local == "bom" && strings.HasPrefix(el.Name.Space, "http://cyclonedx.org/schema/bom") {
+					return sbomFormats[1], true
+				}
+				return sbomFormat{}, false
+			}
+		}
+	default:
+		for _, line := range strings.Split(string(trimmed), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "SPDXVersion: SPDX-") {
+				return sbomFormats[3], true
+			}
+		}
+	}
+	return sbomFormat{}, false
+}
+
+func (s *Server) routeSBOM(w http.ResponseWriter, r *http.Request) {
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, apiV1+"/sbom/"), "/")
+	if key == "" || isInternalPath(key) || isChecksumPath(key) || isSBOMPath(key) || strings.Contains(key, "..") {
+		writeAPIError(w, "path must name an artifact", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.handleGetSBOM(w, r, key)
+	case http.MethodPut:
+		s.handlePutSBOM(w, r, key)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary Download an artifact's SBOM
+// @Description Returns the SBOM stored for an artifact with its media type. format picks one when several are stored: cyclonedx-json, cyclonedx-xml, spdx-json or spdx-tag-value, or cyclonedx/spdx for either encoding; without it CycloneDX JSON is preferred. Deploy tokens need read scope for the path.
+// @Tags artifacts
+// @Produce json
+// @Param artifactPath path string true "Artifact path"
+// @Param format query string false "SBOM format"
+// @Success 200 {file} binary
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/sbom/{artifactPath} [get]
+func (s *Server) handleGetSBOM(w http.ResponseWriter, r *http.Request, key string) {
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("read", key) {
+		writeAPIError(w, "token not permitted for this path", http.StatusForbidden)
+		return
+	}
+	want := r.URL.Query().Get("format")
+	for _, f := range sbomFormats {
+		if want != "" && want != f.name && !strings.HasPrefix(f.name, want+"-") {
+			continue
+		}
+		resp, err := s.store.Get(r.Context(), key+f.suffix)
+		if storage.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("get sbom", zap.String("path", key), zap.Error(err))
+			writeAPIError(w, "read SBOM failed", http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Content-Type", f.contentType)
+		w.Header().Set("X-SBOM-Format", f.name)
+		if resp.ContentLength != nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(*resp.ContentLength, 10))
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			s.logger.Warn("stream sbom", zap.String("path", key), zap.Error(err))
+		}
+		return
+	}
+	writeAPIError(w, "no SBOM stored for "+key, http.StatusNotFound)
+}
+
+// @Summary Upload an artifact's SBOM
+// @Description Stores a CycloneDX (JSON or XML) or SPDX (JSON or tag-value) SBOM for an existing artifact as <artifactPath>.cdx.json, .cdx.xml, .spdx.json or .spdx, detected from the content, with checksum sidecars. The artifact's sbom.formats property lists the stored formats. Deploy tokens need write scope for the path.
+// @Tags artifacts
+// @Accept json
+// @Produce json
+// @Param artifactPath path string true "Artifact path"
+// @Param sbom body object true "CycloneDX or SPDX document"
+// @Success 201 {object} SBOMUploadResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/sbom/{artifactPath} [put]
+func (s *Server) handlePutSBOM(w http.ResponseWriter, r *http.Request, key string) {
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("write", key) {
+		writeAPIError(w, "token not permitted for this path", http.StatusForbidden)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, sbomLimit+1))
+	if err != nil {
+		writeAPIError(w, "read body failed", http.StatusBadRequest)
+		return
+	}
+	if len(data) > sbomLimit {
+		writeAPIError(w, "SBOM exceeds 32 MiB", http.StatusRequestEntityTooLarge)
+		return
+	}
+	format, ok := detectSBOM(data)
+	if !ok {
+		writeAPIError(w, "not a CycloneDX or SPDX document", http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := s.store.Head(r.Context(), key); err != nil {
+		if storage.IsNotFound(err) {
+			writeAPIError(w, "artifact not found", http.StatusNotFound)
+			return
+		}
+		s.logger.Error("head artifact", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "read artifact failed", http.StatusInternalServerError)
+		return
+	}
+
+	file := key + format.suffix
+	err = s.putWithChecksums(r.Context(), file, format.contentType, data)
+	if storage.IsImmutable(err) {
+		writeAPIError(w, "an SBOM in this format is already stored on a write-once path", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("store sbom", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "store SBOM failed", http.StatusInternalServerError)
+		return
+	}
+
+	props, err := loadProperties(r.Context(), s.store, key)
+	if err == nil {
+		formats := strings.Split(props["sbom.formats"], ",")
+		formats = slices.DeleteFunc(formats, func(f string) bool { return f == "" })
+		if !slices.Contains(formats, format.name) {
+			formats = append(formats, format.name)
+			slices.Sort(formats)
+		}
+		err = setProperties(r.Context(), s.store, key, map[string]string{"sbom.formats": strings.Join(formats, ",")})
+	}
+	if err != nil {
+		s.logger.Warn("set sbom properties", zap.String("path", key), zap.Error(err))
+	}
+	s.audit(r, "sbom.uploaded", zap.String("path", key), zap.String("format", format.name))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(SBOMUploadResponse{Path: key, Format: format.name, File: file}); err != nil {
+		s.logger.Warn("encode sbom upload", zap.Error(err))
+	}
+}
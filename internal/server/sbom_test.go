@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDetectSBOM(t *testing.T) {
+	for doc, want := range map[string]string{
+		`{"bomFormat":"CycloneDX","specVersion":"1.5"}`:                                "cyclonedx-json",
+		`<?xml version="1.0"?><bom xmlns="http://cyclonedx.org/schema/bom/1.5"></bom>`: "cyclonedx-xml",
+		`{"spdxVersion":"SPDX-2.3","SPDXID":"SPDXRef-DOCUMENT"}`:                       "spdx-json",
+		"SPDXVersion: SPDX-2.3\nDataLicense: CC0-1.0\n":                                "spdx-tag-value",
+		`{"name":"package.json"}`:                                                      "",
+		`<project/>`:                                                                   "",
+		"hello":                                                                        "",
+	} {
+		f, ok := detectSBOM([]byte(doc))
+		if ok != (want != "") || f.name != want {
+			t.Errorf("detectSBOM(%q) = %q, %v; want %q", doc, f.name, ok, want)
+		}
+	}
+}
+
+func TestSBOMUploadAndDownload(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	key := "releases/com/acme/lib/1.0/lib-1.0.jar"
+	if err := store.Put(ctx, key, strings.NewReader("jar"), "application/java-archive", 3); err != nil {
+		t.Fatal(err)
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodGet, "/api/v1/sbom/"+key, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("no SBOM yet: expected 404, got %d", rr.Code)
+	}
+	cdx := `{"bomFormat":"CycloneDX","specVersion":"1.5","components":[]}`
+	if rr := do(http.MethodPut, "/api/v1/sbom/"+key, cdx); rr.Code != http.StatusCreated {
+		t.Fatalf("PUT CycloneDX: %d %s", rr.Code, rr.Body.String())
+	}
+	spdx := "SPDXVersion: SPDX-2.3\nDataLicense: CC0-1.0\n"
+	if rr := do(http.MethodPut, "/api/v1/sbom/"+key, spdx); rr.Code != http.StatusCreated {
+		t.Fatalf("PUT SPDX: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, ok := store.data[key+".cdx.json.sha1"]; !ok {
+		t.Fatal("SBOM checksum missing")
+	}
+
+	rr := do(http.MethodGet, "/api/v1/sbom/"+key, "")
+	if rr.Code != http.StatusOK || rr.Body.String() != cdx || rr.Header().Get("Content-Type") != "application/vnd.cyclonedx+json" {
+		t.Fatalf("GET: %d %q %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	rr = do(http.MethodGet, "/api/v1/sbom/"+key+"?format=spdx", "")
+	if rr.Code != http.StatusOK || rr.Body.String() != spdx || rr.Header().Get("X-SBOM-Format") != "spdx-tag-value" {
+		t.Fatalf("GET spdx: %d %s", rr.Code, rr.Body.String())
+	}
+
+	query := url.QueryEscape(`{ file(path: "` + key + `") { sbom } }`)
+	rr = do(http.MethodGet, "/api/v1/graphql?query="+query, "")
+	if !strings.Contains(rr.Body.String(), `"sbom":["cyclonedx-json","spdx-tag-value"]`) {
+		t.Fatalf("GraphQL sbom field: %s", rr.Body.String())
+	}
+
+	if rr := do(http.MethodPut, "/api/v1/sbom/"+key, `{"name":"x"}`); rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("not an SBOM: expected 415, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPut, "/api/v1/sbom/releases/com/acme/lib/1.0/missing.jar", cdx); rr.Code != http.StatusNotFound {
+		t.Fatalf("missing artifact: expected 404, got %d", rr.Code)
+	}
+}
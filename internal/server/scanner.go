@@ -2,40 +2,44 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-func RunChecksumScanner(ctx context.Context, logger *zap.Logger, store Storage, prefix string, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// scanChecksums runs one cleanup + generation pass. The last-scan timestamp is
+// only advanced when both steps complete, so a stale gauge means trouble.
+func (s *Server) scanChecksums(ctx context.Context, prefix string) error {
+	start := time.Now()
 
-	running := make(chan struct{}, 1)
-
-	logger.Info("checksum scanner started", zap.Duration("interval", interval), zap.String("prefix", prefix))
-
-	for {
-		select {
-		case running <- struct{}{}:
-			go func() {
-				defer func() { <-running }()
-				if err := store.CleanupBadChecksums(ctx, prefix); err != nil {
-					logger.Warn("checksum cleanup failed", zap.Error(err))
-				}
-				if err := store.GenerateChecksums(ctx, prefix); err != nil {
-					logger.Warn("checksum scan failed", zap.Error(err))
-				}
-			}()
-		default:
-			logger.Warn("checksum scan skipped; previous run still in progress")
-		}
+	cleaned, cleanupErr := s.store.CleanupBadChecksums(ctx, prefix)
+	if cleanupErr != nil {
+		cleanupErr = fmt.Errorf("checksum cleanup: %w", cleanupErr)
+	}
+	if cleaned.Deleted+cleaned.Orphaned+cleaned.Invalid > 0 {
+		s.logger.Info("checksum cleanup removed sidecars",
+			zap.Int("chained", cleaned.Deleted),
+			zap.Int("orphaned", cleaned.Orphaned),
+			zap.Int("invalid", cleaned.Invalid))
+	}
+	generated, scanErr := s.store.GenerateChecksums(ctx, prefix)
+	if scanErr != nil {
+		scanErr = fmt.Errorf("checksum scan: %w", scanErr)
+	}
+	err := errors.Join(cleanupErr, scanErr)
 
-		select {
-		case <-ctx.Done():
-			logger.Info("checksum scanner stopped")
-			return
-		case <-ticker.C:
+	if s.metrics != nil {
+		s.metrics.ChecksumScanObjects.Add(float64(generated.Objects))
+		s.metrics.ChecksumsCreated.Add(float64(generated.Created))
+		s.metrics.BadChecksumsDeleted.Add(float64(cleaned.Deleted))
+		s.metrics.OrphanedChecksums.Add(float64(cleaned.Orphaned))
+		s.metrics.InvalidChecksums.Add(float64(cleaned.Invalid))
+		s.metrics.ChecksumScanDuration.Observe(time.Since(start).Seconds())
+		if err == nil {
+			s.metrics.ChecksumLastScan.SetToCurrentTime()
 		}
 	}
+	return err
 }
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/otoru/heimdall/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+type checksumStatsStore struct {
+	*memStore
+}
+
+func (checksumStatsStore) GenerateChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error) {
+	return storage.ChecksumStats{Objects: 5, Created: 2}, nil
+}
+
+func (checksumStatsStore) CleanupBadChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error) {
+	return storage.ChecksumStats{Objects: 9, Deleted: 1, Orphaned: 3, Invalid: 4}, nil
+}
+
+func TestScanChecksumsRecordsMetrics(t *testing.T) {
+	m := metrics.New()
+	srv := New(checksumStatsStore{newMemStore()}, zaptest.NewLogger(t), m, "", "")
+
+	if err := srv.scanChecksums(context.Background(), ""); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	if got := testutil.ToFloat64(m.ChecksumScanObjects); got != 5 {
+		t.Fatalf("objects = %v", got)
+	}
+	if got := testutil.ToFloat64(m.ChecksumsCreated); got != 2 {
+		t.Fatalf("created = %v", got)
+	}
+	if got := testutil.ToFloat64(m.BadChecksumsDeleted); got != 1 {
+		t.Fatalf("deleted = %v", got)
+	}
+	if got := testutil.ToFloat64(m.OrphanedChecksums); got != 3 {
+		t.Fatalf("orphaned = %v", got)
+	}
+	if got := testutil.ToFloat64(m.InvalidChecksums); got != 4 {
+		t.Fatalf("invalid = %v", got)
+	}
+	if got := testutil.ToFloat64(m.ChecksumLastScan); got == 0 {
+		t.Fatal("expected last scan timestamp to be set")
+	}
+}
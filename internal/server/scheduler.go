@@ -0,0 +1,297 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TaskFunc performs one run of a background task.
+type TaskFunc func(ctx context.Context) error
+
+// Task is a named background job run by the scheduler.
+type Task struct {
+	Name string
+	// Schedule is a cron expression or "@every <duration>", see ParseSchedule.
+	Schedule string
+	// RunAtStart also runs the task once when the scheduler starts.
+	RunAtStart bool
+	Run        TaskFunc
+}
+
+// TaskSettings configures the built-in tasks returned by Server.Tasks.
+type TaskSettings struct {
+	ChecksumPrefix  string
+	LifecycleRules  []LifecycleRule
+	SnapshotPruning SnapshotPruning
+	VerifyPrefix    string
+	VerifyETag      bool
+	Warmup          WarmupSource
+	MirrorPaths     []string
+	// TokenRetention is how long revoked tokens stay on the revocation list
+	// before token-prune deletes them; 0 keeps them forever.
+	TokenRetention time.Duration
+}
+
+// Tasks returns the built-in background tasks by name, without a schedule.
+func (s *Server) Tasks(cfg TaskSettings) map[string]Task {
+	tasks := []Task{
+		{Name: "checksum-scan", RunAtStart: true, Run: func(ctx context.Context) error {
+			return s.scanChecksums(ctx, cfg.ChecksumPrefix)
+		}},
+		{Name: "cache-eviction", Run: s.evictCaches},
+		{Name: "lifecycle", Run: func(ctx context.Context) error {
+			return s.lifecycleTask(ctx, cfg.LifecycleRules)
+		}},
+		{Name: "snapshot-prune", Run: func(ctx context.Context) error {
+			return s.snapshotPruneTask(ctx, cfg.SnapshotPruning)
+		}},
+		{Name: "archetype-catalog", Run: s.archetypeCatalogTask},
+		{Name: "integrity-check", Run: func(ctx context.Context) error {
+			return s.integrityTask(ctx, cfg.VerifyPrefix, cfg.VerifyETag)
+		}},
+		{Name: "usage-report", RunAtStart: true, Run: func(ctx context.Context) error {
+			_, err := s.refreshUsage(ctx)
+			return err
+		}},
+		{Name: "cas-gc", Run: s.collectBlobsTask},
+		{Name: "checksum-index", RunAtStart: true, Run: s.checksumIndexTask},
+		{Name: "dependency-index", RunAtStart: true, Run: s.dependencyIndexTask},
+		{Name: "cache-warmup", RunAtStart: true, Run: func(ctx context.Context) error {
+			return s.warmupTask(ctx, cfg.Warmup)
+		}},
+		{Name: "mirror", Run: func(ctx context.Context) error {
+			return s.mirrorTask(ctx, cfg.MirrorPaths)
+		}},
+		{Name: "upload-expiry", Run: s.expireUploads},
+		{Name: "token-prune", Run: func(ctx context.Context) error {
+			return s.pruneTokensTask(ctx, cfg.TokenRetention)
+		}},
+	}
+	byName := make(map[string]Task, len(tasks))
+	for _, t := range tasks {
+		byName[t.Name] = t
+	}
+	return byName
+}
+
+// Task run statuses (TaskRun.Status).
+const (
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+	TaskCanceled  = "canceled"
+	// TaskInterrupted marks a run that was still in progress when the
+	// server stopped.
+	TaskInterrupted = "interrupted"
+)
+
+// TaskRun is one execution of a task. Trigger is "schedule" or "manual".
+type TaskRun struct {
+	ID         string     `json:"id"`
+	Task       string     `json:"task"`
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	DurationMs int64      `json:"durationMs"`
+	Error      string     `json:"error,omitempty"`
+	// Checkpoint is the progress last recorded by the task, see
+	// taskCheckpoint; ResumedFrom is the checkpoint this run started after.
+	Checkpoint  string `json:"checkpoint,omitempty"`
+	ResumedFrom string `json:"resumedFrom,omitempty"`
+}
+
+type scheduledTask struct {
+	Task
+	schedule Schedule
+
+	// Guarded by Server.tasksMu. history holds finished runs, newest first.
+	next    time.Time
+	running *TaskRun
+	cancel  context.CancelFunc
+	history []TaskRun
+
+	// saveMu orders writes of the persisted history.
+	saveMu sync.Mutex
+}
+
+// last returns the last finished run; call it with Server.tasksMu held.
+func (t *scheduledTask) last() *TaskRun {
+	if len(t.history) == 0 {
+		return nil
+	}
+	return &t.history[0]
+}
+
+// ScheduleTask registers t with the scheduler; call it before RunScheduler.
+// An empty Schedule registers the task for manual runs only.
+func (s *Server) ScheduleTask(t Task) error {
+	var schedule Schedule
+	if t.Schedule != "" {
+		var err error
+		if schedule, err = ParseSchedule(t.Schedule); err != nil {
+			return fmt.Errorf("task %s: %w", t.Name, err)
+		}
+	}
+	s.tasksMu.Lock()
+	defer s.tasksMu.Unlock()
+	if s.findTask(t.Name) != nil {
+		return fmt.Errorf("task %s already scheduled", t.Name)
+	}
+	s.tasks = append(s.tasks, &scheduledTask{Task: t, schedule: schedule})
+	return nil
+}
+
+// findTask must be called with tasksMu held.
+func (s *Server) findTask(name string) *scheduledTask {
+	for _, t := range s.tasks {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// RunScheduler runs every scheduled task on its schedule until ctx is done.
+// Runs of one task never overlap: an activation missed while the previous
+// run is still going is skipped.
+//
+// With leader election only the lease holder runs scheduled activations;
+// the others keep their timers and skip them. Manual runs are not affected.
+func (s *Server) RunScheduler(ctx context.Context) {
+	s.schedulerRunning.Store(true)
+	defer s.schedulerRunning.Store(false)
+	if s.election != nil {
+		s.campaign(ctx)
+		go s.runLeaderElection(ctx)
+	} else if s.metrics != nil {
+		s.metrics.Leader.Set(1)
+	}
+	s.loadTaskHistory(ctx)
+	s.tasksMu.Lock()
+	tasks := append([]*scheduledTask(nil), s.tasks...)
+	s.tasksMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		if t.schedule == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runScheduledTask(ctx, t)
+		}()
+	}
+	wg.Wait()
+	s.logger.Info("task scheduler stopped")
+}
+
+func (s *Server) runScheduledTask(ctx context.Context, t *scheduledTask) {
+	s.logger.Info("task scheduled", zap.String("task", t.Name), zap.String("schedule", t.Schedule))
+	if t.RunAtStart {
+		s.runTask(ctx, t)
+	}
+	for {
+		next := t.schedule.Next(time.Now())
+		s.tasksMu.Lock()
+		t.next = next
+		s.tasksMu.Unlock()
+		if next.IsZero() {
+			s.logger.Warn("task schedule has no future activation", zap.String("task", t.Name), zap.String("schedule", t.Schedule))
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.runTask(ctx, t)
+	}
+}
+
+func (s *Server) runTask(ctx context.Context, t *scheduledTask) {
+	if !s.isLeader() {
+		s.logger.Debug("task skipped; not the leader", zap.String("task", t.Name))
+		return
+	}
+	runCtx, run, ok := s.beginTaskRun(ctx, t, "schedule")
+	if !ok {
+		s.logger.Warn("task skipped; previous run still in progress", zap.String("task", t.Name))
+		return
+	}
+	s.executeTaskRun(runCtx, t, run)
+}
+
+// beginTaskRun marks t as running unless a run is already in progress.
+func (s *Server) beginTaskRun(ctx context.Context, t *scheduledTask, trigger string) (context.Context, *TaskRun, bool) {
+	s.tasksMu.Lock()
+	defer s.tasksMu.Unlock()
+	if t.running != nil {
+		return nil, nil, false
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	run := &TaskRun{
+		ID:        hex.EncodeToString(id),
+		Task:      t.Name,
+		Trigger:   trigger,
+		Status:    TaskRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if last := t.last(); last != nil && last.Status == TaskInterrupted {
+		run.ResumedFrom = last.Checkpoint
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t.running, t.cancel = run, cancel
+	ctx = context.WithValue(ctx, taskRunKey{}, &taskRunHandle{server: s, task: t, run: run, saved: time.Now()})
+	return ctx, run, true
+}
+
+// executeTaskRun runs t and records the outcome of run. Canceled runs (API
+// or shutdown) are not reported as failures.
+func (s *Server) executeTaskRun(ctx context.Context, t *scheduledTask, run *TaskRun) {
+	s.saveTaskHistory(ctx, t)
+	err := t.Run(ctx)
+
+	s.tasksMu.Lock()
+	finished := time.Now().UTC()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	switch {
+	case ctx.Err() != nil:
+		run.Status = TaskCanceled
+	case err != nil:
+		run.Status = TaskFailed
+		run.Error = err.Error()
+	default:
+		run.Status = TaskSucceeded
+	}
+	t.cancel()
+	t.running, t.cancel = nil, nil
+	t.history = append([]TaskRun{*run}, t.history...)
+	if len(t.history) > taskHistoryRuns {
+		t.history = t.history[:taskHistoryRuns]
+	}
+	s.tasksMu.Unlock()
+	s.saveTaskHistory(context.WithoutCancel(ctx), t)
+
+	duration := time.Duration(run.DurationMs) * time.Millisecond
+	switch run.Status {
+	case TaskCanceled:
+		s.logger.Info("task canceled", zap.String("task", t.Name), zap.String("run", run.ID), zap.Duration("duration", duration))
+	case TaskFailed:
+		s.logger.Warn("task failed", zap.String("task", t.Name), zap.String("run", run.ID), zap.Duration("duration", duration), zap.Error(err))
+		s.errors.ReportTask(t.Name, t.Name+" failed", err, map[string]string{"run": run.ID, "trigger": run.Trigger})
+	default:
+		s.logger.Debug("task finished", zap.String("task", t.Name), zap.String("run", run.ID), zap.Duration("duration", duration))
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestScheduler(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+	var atStart, every atomic.Int32
+	started := make(chan struct{})
+	if err := srv.ScheduleTask(Task{Name: "at-start", Schedule: "@yearly", RunAtStart: true, Run: func(ctx context.Context) error {
+		atStart.Add(1)
+		close(started)
+		return nil
+	}}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if err := srv.ScheduleTask(Task{Name: "every", Schedule: "@every 10ms", Run: func(ctx context.Context) error {
+		every.Add(1)
+		return errors.New("boom")
+	}}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if err := srv.ScheduleTask(Task{Name: "every", Schedule: "@hourly"}); err == nil {
+		t.Fatal("expected duplicate task to be rejected")
+	}
+	if err := srv.ScheduleTask(Task{Name: "bad", Schedule: "* * *"}); err == nil {
+		t.Fatal("expected invalid schedule to be rejected")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.RunScheduler(ctx)
+		close(done)
+	}()
+	<-started
+	deadline := time.Now().Add(5 * time.Second)
+	for every.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("interval task did not keep running after failures")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if atStart.Load() != 1 {
+		t.Fatalf("expected one run at start, got %d", atStart.Load())
+	}
+}
+
+func TestBuiltinTasks(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "", "")
+	tasks := srv.Tasks(TaskSettings{})
+	for _, name := range []string{"checksum-scan", "cache-eviction", "lifecycle", "snapshot-prune", "archetype-catalog", "integrity-check", "usage-report"} {
+		task, ok := tasks[name]
+		if !ok || task.Run == nil {
+			t.Fatalf("missing task %s", name)
+		}
+	}
+	if err := tasks["usage-report"].Run(context.Background()); err != nil {
+		t.Fatalf("usage-report: %v", err)
+	}
+	srv.usageMu.Lock()
+	defer srv.usageMu.Unlock()
+	if srv.usage == nil {
+		t.Fatal("expected usage report to be refreshed")
+	}
+}
@@ -1,25 +1,28 @@
 package server
 
 import (
+	"bytes"
 	"context"
-	"crypto/md5"
 	"crypto/sha1"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
-	"os"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/otoru/heimdall/internal/metrics"
 	"github.com/otoru/heimdall/internal/storage"
+	"github.com/otoru/heimdall/internal/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	httpSwagger "github.com/swaggo/http-swagger"
 	"go.uber.org/zap"
 )
 
@@ -28,42 +31,251 @@ type Storage interface {
 	Head(ctx context.Context, key string) (*s3.HeadObjectOutput, error)
 	Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64) error
 	List(ctx context.Context, prefix string, limit int32) ([]storage.Entry, error)
+	ListPage(ctx context.Context, prefix, token string, limit int32) (storage.Page, error)
+	Walk(ctx context.Context, prefix string, fn func(storage.Entry) error) error
 	Delete(ctx context.Context, key string) error
-	GenerateChecksums(ctx context.Context, prefix string) error
-	CleanupBadChecksums(ctx context.Context, prefix string) error
+	Touch(ctx context.Context, key string, metadata map[string]string) error
+	Copy(ctx context.Context, src, dst string) error
+	GenerateChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error)
+	CleanupBadChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error)
 }
 
 type Server struct {
-	store   Storage
-	proxy   *ProxyManager
-	logger  *zap.Logger
-	metrics *metrics.Registry
-	user    string
-	pass    string
+	store     Storage
+	proxy     *ProxyManager
+	downloads *DownloadStats
+	cache     *CacheStats
+	roots     *rootLayout
+	blocked   *BlockList
+	policy    *PolicyHook
+	layout    *LayoutPolicy
+	scans     *ScanQueue
+	clamav    *ClamAV
+	tokens    *TokenManager
+	forward   *ForwardAuth
+	signer    *URLSigner
+	errors    *ErrorReporter
+	level     *zap.AtomicLevel
+	logger    *zap.Logger
+	metrics   *metrics.Registry
+	user      string
+	pass      string
+	passwords passwordCache
+
+	slowThreshold time.Duration
+	accessLogger  *zap.Logger
+	sampling      *TelemetrySampling
+	swaggerUI     string
+	signingHook   *SigningHook
+	// checksums are the sidecar digests written next to stored files.
+	checksums []string
+
+	disableLegacyAPI bool
+	verifyDownloads  string
+
+	integrityQuarantineAfter int
+	eventsToken              string
+	cdn                      *CDNSigner
+	throttle                 *Throttle
+	budgets                  *budgetTracker
+	shadow                   *Shadow
+	resumable                ResumableUploads
+	uploadMemoryMax          int64
+
+	// pluginMetaMu serialises updates of group-level plugin metadata.
+	pluginMetaMu sync.Mutex
+
+	// usage is the last storage usage report, see refreshUsage.
+	usageMu sync.Mutex
+	usage   *UsageReport
+	// usageDirty are repositories changed by S3 events, refreshed by
+	// usageTimer, see markUsageDirty.
+	usageDirty map[string]bool
+	usageTimer *time.Timer
+
+	// integrity is the running or last integrity check, see verifyIntegrity.
+	integrityMu sync.Mutex
+	integrity   *IntegrityReport
+
+	// prefetch are the jobs started through /api/v1/prefetch.
+	prefetch prefetchJobs
+
+	// feeds caches the releases listed by /api/v1/feed.atom.
+	feeds feedCache
+
+	// tasks are the background jobs run by RunScheduler.
+	tasksMu sync.Mutex
+	tasks   []*scheduledTask
+	// schedulerRunning is set while RunScheduler runs.
+	schedulerRunning atomic.Bool
+
+	// health is the last dependency report, see healthReport.
+	healthMu sync.Mutex
+	health   *HealthReport
+
+	// election is nil unless Options.LeaderElection is set.
+	election *leaderElection
+	// metaLocks is nil unless Options.MetadataLocks is set.
+	metaLocks *metadataLocker
+}
+
+type Options struct {
+	AuthUser     string
+	AuthPassword string
+	BlockList    *BlockList
+	Policy       *PolicyHook
+	StrictLayout *LayoutPolicy
+	Scans        *ScanQueue
+	ClamAV       *ClamAV
+	SigningHook  *SigningHook
+	ForwardAuth  *ForwardAuth
+	SigningKey   []byte
+	Errors       *ErrorReporter
+	LogLevel     *zap.AtomicLevel
+	// SlowRequestThreshold logs requests taking at least this long at WARN
+	// with extra detail; zero disables it.
+	SlowRequestThreshold time.Duration
+	// AccessLogger receives per-request logs; defaults to the app logger.
+	AccessLogger *zap.Logger
+	Sampling     *TelemetrySampling
+	// RootLayoutTTL is how long the cached repository layout used by the
+	// /packages fallback is trusted before it is listed again.
+	RootLayoutTTL time.Duration
+	// RaceProxies makes /packages misses query all proxies concurrently.
+	RaceProxies bool
+	// SwaggerUI controls /swagger/ and /openapi.json: SwaggerPublic (default),
+	// SwaggerAuth or SwaggerDisabled.
+	SwaggerUI string
+	// DisableLegacyAPI drops the pre-/api/v1 aliases (/proxies, /catalog, ...).
+	DisableLegacyAPI bool
+	// VerifyDownloads hashes GET responses and compares them to the stored
+	// .sha1: VerifyDownloadsOff (default), VerifyDownloadsLog or
+	// VerifyDownloadsInvalidate.
+	VerifyDownloads string
+	// IntegrityQuarantineAfter quarantines artifacts failing this many
+	// consecutive integrity checks; zero disables it.
+	IntegrityQuarantineAfter int
+	// LeaderElection makes replicas compete for a lease in the bucket so
+	// only the holder runs scheduled tasks. LeaderID names this replica
+	// (default: hostname plus a random suffix); the lease is taken over after
+	// LeaderLeaseTTL (default 30s) without renewal.
+	LeaderElection bool
+	LeaderID       string
+	LeaderLeaseTTL time.Duration
+	// MetadataLocks guards every write of a maven-metadata.xml with a lock
+	// object in the bucket, so replicas never interleave metadata and
+	// checksum writes. Waiting for a lock fails after MetadataLockTimeout
+	// (default 30s).
+	MetadataLocks       bool
+	MetadataLockTimeout time.Duration
+	// EventsToken enables POST /api/v1/events/s3 for bucket notifications
+	// and is the secret senders must present.
+	EventsToken string
+	// CDN redirects artifact downloads to signed URLs of a distribution in
+	// front of the bucket.
+	CDN *CDNSigner
+	// Throttle limits download bandwidth, see NewThrottle.
+	Throttle *Throttle
+	// Shadow replays a sample of read requests to a secondary instance.
+	Shadow *Shadow
+	// TokenBudgets are the default daily budgets of deploy tokens.
+	TokenBudgets TokenBudgets
+	// UpstreamLimits bounds concurrent downloads from proxy upstreams.
+	UpstreamLimits UpstreamLimits
+	// UpstreamTransport tunes the connections to proxy upstreams.
+	UpstreamTransport storage.Transport
+	// ChecksumAlgorithms are the sidecar digests written next to uploaded,
+	// cached and generated files (see storage.ParseSidecarChecksums); sha1
+	// and md5 when empty.
+	ChecksumAlgorithms []string
+	// ResumableUploads bounds uploads through the tus endpoint.
+	ResumableUploads ResumableUploads
+	// UploadMemoryMax is the largest upload, in bytes, buffered in memory
+	// rather than in a temp file; zero buffers every upload on disk.
+	UploadMemoryMax int64
 }
 
 func New(store Storage, logger *zap.Logger, m *metrics.Registry, user, pass string) *Server {
-	return &Server{
-		store:   store,
-		proxy:   NewProxyManager(store, logger),
-		logger:  logger,
-		metrics: m,
-		user:    user,
-		pass:    pass,
-	}
+	return NewWithOptions(store, logger, m, Options{AuthUser: user, AuthPassword: pass})
+}
+
+func NewWithOptions(store Storage, logger *zap.Logger, m *metrics.Registry, opts Options) *Server {
+	proxy := NewProxyManager(store, logger)
+	proxy.blocked = opts.BlockList
+	proxy.scans = opts.Scans
+	proxy.race = opts.RaceProxies
+	proxy.limits = newUpstreamLimiter(opts.UpstreamLimits, m)
+	proxy.metrics = m
+	proxy.httpClient.Transport = opts.UpstreamTransport.NewHTTPTransport()
+	if len(opts.ChecksumAlgorithms) > 0 {
+		proxy.checksums = opts.ChecksumAlgorithms
+	}
+	if opts.Scans != nil {
+		opts.Scans.errors = opts.Errors
+	}
+	if opts.Shadow != nil {
+		opts.Shadow.metrics = m
+	}
+	s := &Server{
+		store:     store,
+		proxy:     proxy,
+		downloads: NewDownloadStats(),
+		cache:     NewCacheStats(),
+		roots:     newRootLayout(store, opts.RootLayoutTTL),
+		blocked:   opts.BlockList,
+		policy:    opts.Policy,
+		layout:    opts.StrictLayout,
+		scans:     opts.Scans,
+		clamav:    opts.ClamAV,
+		tokens:    NewTokenManager(store),
+		forward:   opts.ForwardAuth,
+		signer:    NewURLSigner(opts.SigningKey),
+		errors:    opts.Errors,
+		level:     opts.LogLevel,
+		logger:    logger,
+		metrics:   m,
+		user:      opts.AuthUser,
+		pass:      opts.AuthPassword,
+
+		slowThreshold: opts.SlowRequestThreshold,
+		accessLogger:  opts.AccessLogger,
+		sampling:      opts.Sampling,
+		swaggerUI:     opts.SwaggerUI,
+		signingHook:   opts.SigningHook,
+		checksums:     proxy.checksums,
+
+		disableLegacyAPI: opts.DisableLegacyAPI,
+		verifyDownloads:  opts.VerifyDownloads,
+
+		integrityQuarantineAfter: opts.IntegrityQuarantineAfter,
+		eventsToken:              opts.EventsToken,
+		cdn:                      opts.CDN,
+		throttle:                 opts.Throttle,
+		budgets:                  newBudgetTracker(opts.TokenBudgets),
+		shadow:                   opts.Shadow,
+		resumable:                opts.ResumableUploads,
+		uploadMemoryMax:          opts.UploadMemoryMax,
+	}
+	if opts.LeaderElection {
+		s.election = newLeaderElection(opts.LeaderID, opts.LeaderLeaseTTL)
+	}
+	if opts.MetadataLocks {
+		s.metaLocks = newMetadataLocker(opts.MetadataLockTimeout)
+	}
+	return s
 }
 
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", s.handleHealth)
-	mux.Handle("/swagger/", httpSwagger.WrapHandler)
-	mux.HandleFunc("/catalog", s.authMiddleware(s.handleCatalog))
-	mux.HandleFunc("/proxies", s.authMiddleware(s.routeProxies))
-	mux.HandleFunc("/proxies/", s.authMiddleware(s.routeProxyByName))
-	mux.HandleFunc("/packages/", s.authMiddleware(s.handlePackages))
-	mux.HandleFunc("/", s.authMiddleware(s.handleObject))
-
-	var handler http.Handler = mux
+	mux.HandleFunc("/livez", s.handleLive)
+	mux.HandleFunc("/version", s.authMiddleware(s.handleVersion))
+	s.registerDocs(mux)
+	s.registerAPI(mux)
+	mux.HandleFunc("/packages/", s.authMiddleware(s.adminOnly(s.handlePackages)))
+	mux.HandleFunc("/", s.presigned(s.handleObject, s.authMiddleware(s.handleObject)))
+
+	var handler http.Handler = s.errorReporting(mux)
 	if s.metrics != nil {
 		handler = promhttp.InstrumentHandlerInFlight(
 			s.metrics.InFlight,
@@ -75,46 +287,121 @@ func (s *Server) Handler() http.Handler {
 				),
 			),
 		)
+		handler = s.countPrincipals(handler)
+	}
+	if s.shadow != nil {
+		handler = s.shadow.middleware(handler)
 	}
 
-	return loggingMiddleware(s.logger, handler)
+	accessLogger := s.accessLogger
+	if accessLogger == nil {
+		accessLogger = s.logger
+	}
+	return loggingMiddleware(accessLogger, s.slowThreshold, s.sampling, handler)
 }
 
 func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	if s.user == "" && s.pass == "" {
+	if s.user == "" && s.pass == "" && s.forward == nil {
 		return next
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		u, p, ok := r.BasicAuth()
-		if !ok || u != s.user || p != s.pass {
-			w.Header().Set("WWW-Authenticate", `Basic realm="heimdall"`)
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		if ctx, ok := s.authenticate(r); ok {
+			r = r.WithContext(ctx)
+			tracePrincipal(ctx, principalName(r))
+			s.enforceBudget(w, r, next)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="heimdall"`)
+		writeAPIError(w, "unauthorized", http.StatusUnauthorized)
+	}
+}
+
+// authenticate resolves the caller of r (forward auth, admin credentials or
+// a deploy token) and returns the request context carrying its principal.
+func (s *Server) authenticate(r *http.Request) (context.Context, bool) {
+	if s.user == "" && s.pass == "" && s.forward == nil {
+		return r.Context(), true
+	}
+	if user := s.forward.User(r); user != "" {
+		p := principal{name: user, forwarded: true, admin: s.forward.IsAdmin(r, user)}
+		return context.WithValue(r.Context(), principalKey{}, p), true
+	}
+	u, p, ok := r.BasicAuth()
+	if !ok {
+		return nil, false
+	}
+	if (s.user != "" || s.pass != "") && subtle.ConstantTimeCompare([]byte(u), []byte(s.user)) == 1 && s.passwords.verify(s.pass, p) {
+		return r.Context(), true
+	}
+	if tok, valid := s.tokens.Authenticate(r.Context(), u, p); valid {
+		return context.WithValue(r.Context(), principalKey{}, principal{name: tok.ID, token: &tok}), true
+	}
+	return nil, false
+}
+
+// adminOnly rejects deploy tokens, which are limited to artifact paths.
+func (s *Server) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !principalFrom(r.Context()).isAdmin() {
+			writeAPIError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 		next(w, r)
 	}
 }
 
-// @Summary Health check
+// @Summary Liveness check
+// @Description Answers as long as the process serves requests, without checking dependencies.
 // @Tags health
 // @Produce plain
 // @Success 200 {string} string "ok"
-// @Router /healthz [get]
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+// @Router /livez [get]
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
 }
 
+// @Summary Build information
+// @Tags health
+// @Produce json
+// @Success 200 {object} version.Info
+// @Security BasicAuth
+// @Router /version [get]
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(version.Get()); err != nil {
+		s.logger.Warn("encode version", zap.Error(err))
+	}
+}
+
 // @Summary List artifacts
+// @Description Entries carry their size, last-modified time, ETag and source: "local" for hosted content, the proxy name for proxied content. Proxied entries get what the upstream index page shows, completed from the cached copy. HEAD answers with the headers only and skips upstream listings, for cheap availability probes.
 // @Tags catalog
 // @Param path query string false "Path prefix (non-recursive); root by default"
 // @Param limit query int false "Max items" default(100)
+// @Param cursor query string false "Continue from the X-Next-Cursor of the previous page"
+// @Param format query string false "json, xml or csv; without it the Accept header decides, JSON by default"
+// @Param checksums query bool false "Fill sha1 of files from their listed .sha1 sidecars"
 // @Produce json
+// @Produce xml
+// @Produce text/csv
 // @Success 200 {array} storage.Entry
+// @Failure 400 {object} ErrorResponse
 // @Security BasicAuth
-// @Router /catalog [get]
+// @Router /api/v1/catalog [get]
+// @Router /api/v1/catalog [head]
 func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format, ok := catalogFormat(r)
+	if !ok {
+		writeAPIError(w, "format must be json, xml or csv", http.StatusBadRequest)
+		return
+	}
 	prefix := r.URL.Query().Get("path")
 	limit := int32(100)
 	if v := r.URL.Query().Get("limit"); v != "" {
@@ -122,6 +409,10 @@ func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
 			limit = int32(parsed)
 		}
 	}
+	if r.Method == http.MethodHead {
+		s.headCatalog(w, r, format, prefix, limit)
+		return
+	}
 
 	if strings.HasPrefix(strings.TrimPrefix(prefix, "/"), "packages") {
 		keys, err := s.listPackages(r.Context(), prefix, limit)
@@ -129,32 +420,33 @@ func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
 			s.writeError(w, "list packages", err)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(keys); err != nil {
-			s.logger.Warn("encode catalog", zap.Error(err))
-		}
+		s.writeCatalog(w, format, keys)
 		return
 	}
 
-	keys, err := s.store.List(r.Context(), prefix, limit)
+	page, err := s.store.ListPage(r.Context(), prefix, r.URL.Query().Get("cursor"), limit)
 	if err != nil {
 		s.writeError(w, "list objects", err)
 		return
 	}
+	keys := page.Entries
+	if page.NextToken != "" {
+		w.Header().Set("X-Next-Cursor", page.NextToken)
+	}
 
 	if prEntries, handled, err := s.maybeListProxy(r.Context(), prefix, limit); err == nil && handled {
 		// merge proxy entries with any cached local items for this prefix
 		merged := append([]storage.Entry{}, prEntries...)
-		existing := map[string]struct{}{}
-		for _, e := range merged {
-			existing[e.Name] = struct{}{}
+		existing := map[string]int{}
+		for i, e := range merged {
+			existing[e.Name] = i
 		}
 		for _, e := range keys {
-			if strings.HasPrefix(e.Path, proxyConfigPrefix) {
+			if isInternalPath(e.Path) {
 				continue
 			}
-			if _, ok := existing[e.Name]; ok {
+			if i, ok := existing[e.Name]; ok {
+				fillFromCached(&merged[i], e)
 				continue
 			}
 			merged = append(merged, e)
@@ -166,7 +458,7 @@ func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
 
 	var filtered []storage.Entry
 	for _, k := range keys {
-		if strings.HasPrefix(k.Path, proxyConfigPrefix) {
+		if isInternalPath(k.Path) {
 			continue
 		}
 		filtered = append(filtered, k)
@@ -176,30 +468,50 @@ func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
 		keys = []storage.Entry{}
 	}
 
+	proxies, err := s.proxy.List(r.Context())
+	if err != nil {
+		s.logger.Warn("list proxies for catalog", zap.Error(err))
+	}
+	setCatalogSources(keys, proxies)
+	if r.URL.Query().Get("checksums") == "true" {
+		s.fillCatalogChecksums(r.Context(), keys)
+	}
+
 	if prefix == "" || prefix == "/" {
 		keys = append(keys, storage.Entry{
 			Name: "packages/",
 			Path: "packages/",
 			Type: "group",
 		})
-		if proxies, err := s.proxy.List(r.Context()); err == nil {
-			for _, pr := range proxies {
-				keys = append(keys, storage.Entry{
-					Name: pr.Name + "/",
-					Path: pr.Name + "/",
-					Type: "proxy",
-				})
-			}
-		} else {
-			s.logger.Warn("list proxies for catalog", zap.Error(err))
+		for _, pr := range proxies {
+			keys = append(keys, storage.Entry{
+				Name:   pr.Name + "/",
+				Path:   pr.Name + "/",
+				Type:   "proxy",
+				Source: pr.Name,
+			})
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(keys); err != nil {
-		s.logger.Warn("encode catalog", zap.Error(err))
+	s.writeCatalog(w, format, keys)
+}
+
+// headCatalog answers HEAD /catalog with the headers of the listing but
+// without building it: one storage page is listed to check the bucket
+// answers, upstream listings and entry details are skipped.
+func (s *Server) headCatalog(w http.ResponseWriter, r *http.Request, format, prefix string, limit int32) {
+	if !strings.HasPrefix(strings.TrimPrefix(prefix, "/"), "packages") {
+		page, err := s.store.ListPage(r.Context(), prefix, r.URL.Query().Get("cursor"), limit)
+		if err != nil {
+			s.writeError(w, "list objects", err)
+			return
+		}
+		if page.NextToken != "" {
+			w.Header().Set("X-Next-Cursor", page.NextToken)
+		}
 	}
+	w.Header().Set("Content-Type", catalogContentType(format))
+	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) routeProxies(w http.ResponseWriter, r *http.Request) {
@@ -210,35 +522,52 @@ func (s *Server) routeProxies(w http.ResponseWriter, r *http.Request) {
 		s.handleCreateProxy(w, r)
 	default:
 		w.Header().Set("Allow", "GET, POST")
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) routeProxyByName(w http.ResponseWriter, r *http.Request) {
-	name := strings.TrimPrefix(r.URL.Path, "/proxies/")
+	name := strings.TrimPrefix(r.URL.Path, apiV1+"/proxies/")
 	name = strings.Trim(name, "/")
 	if name == "" {
-		http.NotFound(w, r)
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if proxyName, action, ok := strings.Cut(name, "/"); ok {
+		if action != "invalidate" {
+			writeAPIError(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleInvalidateProxy(w, r, proxyName)
 		return
 	}
 
 	switch r.Method {
+	case http.MethodGet:
+		s.handleGetProxy(w, r, name)
 	case http.MethodPut:
 		s.handleUpdateProxy(w, r, name)
 	case http.MethodDelete:
 		s.handleDeleteProxy(w, r, name)
 	default:
-		w.Header().Set("Allow", "PUT, DELETE")
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // @Summary List proxy repositories
+// @Description Each proxy carries its revision, to be sent in If-Match when updating or deleting it.
 // @Tags proxies
 // @Produce json
 // @Success 200 {array} server.Proxy
 // @Security BasicAuth
-// @Router /proxies [get]
+// @Router /api/v1/proxies [get]
 func (s *Server) handleListProxies(w http.ResponseWriter, r *http.Request) {
 	proxies, err := s.proxy.List(r.Context())
 	if err != nil {
@@ -257,72 +586,451 @@ func (s *Server) handleListProxies(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param proxy body Proxy true "Proxy configuration"
 // @Success 201 {string} string "Created"
-// @Failure 400 {string} string
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Security BasicAuth
-// @Router /proxies [post]
+// @Router /api/v1/proxies [post]
 func (s *Server) handleCreateProxy(w http.ResponseWriter, r *http.Request) {
 	var pr Proxy
 	if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
 		return
 	}
 	if err := s.proxy.Add(r.Context(), pr); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeProxyChangeError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
 }
 
+// @Summary Get proxy repository
+// @Description Returns the proxy with its revision, also sent as the ETag header.
+// @Tags proxies
+// @Produce json
+// @Param name path string true "Proxy name"
+// @Success 200 {object} server.Proxy
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/proxies/{name} [get]
+func (s *Server) handleGetProxy(w http.ResponseWriter, r *http.Request, name string) {
+	pr, err := s.proxy.Get(r.Context(), name)
+	if errors.Is(err, errProxyNotFound) {
+		writeAPIError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.writeError(w, "get proxy", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+pr.Revision+`"`)
+	if err := json.NewEncoder(w).Encode(pr); err != nil {
+		s.logger.Warn("encode proxy", zap.Error(err))
+	}
+}
+
+// ifMatchRevision returns the revision sent in If-Match, unquoted.
+func ifMatchRevision(r *http.Request) (string, bool) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		return "", false
+	}
+	return strings.Trim(strings.TrimPrefix(v, "W/"), `"`), true
+}
+
+// writeProxyChangeError maps errors of conditional proxy changes to statuses.
+func writeProxyChangeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errProxyNotFound):
+		writeAPIError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errProxyConflict), errors.Is(err, errProxyDeclared):
+		writeAPIError(w, err.Error(), http.StatusConflict)
+	default:
+		writeAPIError(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
 // @Summary Update proxy repository
+// @Description Requires the revision read from GET in If-Match ("*" overwrites whatever is stored); a proxy changed in the meantime is rejected with 409.
 // @Tags proxies
 // @Accept json
 // @Produce json
 // @Param name path string true "Proxy name"
+// @Param If-Match header string true "Revision of the proxy"
 // @Param proxy body Proxy true "Proxy configuration"
 // @Success 200 {string} string "Updated"
-// @Failure 400 {string} string
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 428 {object} ErrorResponse
 // @Security BasicAuth
-// @Router /proxies/{name} [put]
+// @Router /api/v1/proxies/{name} [put]
 func (s *Server) handleUpdateProxy(w http.ResponseWriter, r *http.Request, name string) {
+	revision, ok := ifMatchRevision(r)
+	if !ok {
+		writeAPIError(w, "If-Match with the proxy revision is required", http.StatusPreconditionRequired)
+		return
+	}
 	var pr Proxy
 	if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
 		return
 	}
-	if err := s.proxy.Update(r.Context(), name, pr); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	newRevision, err := s.proxy.UpdateIfMatch(r.Context(), name, pr, revision)
+	if err != nil {
+		writeProxyChangeError(w, err)
 		return
 	}
+	w.Header().Set("ETag", `"`+newRevision+`"`)
 	w.WriteHeader(http.StatusOK)
 }
 
 // @Summary Delete proxy repository
+// @Description Requires the revision read from GET in If-Match ("*" deletes whatever is stored); a proxy changed in the meantime is rejected with 409.
 // @Tags proxies
 // @Produce plain
 // @Param name path string true "Proxy name"
+// @Param If-Match header string true "Revision of the proxy"
 // @Success 204 {string} string "Deleted"
-// @Failure 400 {string} string
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 428 {object} ErrorResponse
 // @Security BasicAuth
-// @Router /proxies/{name} [delete]
+// @Router /api/v1/proxies/{name} [delete]
 func (s *Server) handleDeleteProxy(w http.ResponseWriter, r *http.Request, name string) {
-	if err := s.proxy.Delete(r.Context(), name); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	revision, ok := ifMatchRevision(r)
+	if !ok {
+		writeAPIError(w, "If-Match with the proxy revision is required", http.StatusPreconditionRequired)
+		return
+	}
+	if err := s.proxy.DeleteIfMatch(r.Context(), name, revision); err != nil {
+		writeProxyChangeError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (s *Server) routeTokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListTokens(w, r)
+	case http.MethodPost:
+		s.handleCreateToken(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) routeTokenByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, apiV1+"/tokens/"), "/")
+	if id == "" {
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if id == "revoked" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleListRevokedTokens(w, r)
+		return
+	}
+	if tokenID, action, ok := strings.Cut(id, "/"); ok {
+		if action != "rotate" {
+			writeAPIError(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleRotateToken(w, r, tokenID)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.handleDeleteToken(w, r, id)
+}
+
+// @Summary List deploy tokens
+// @Tags tokens
+// @Produce json
+// @Success 200 {array} server.DeployToken
+// @Security BasicAuth
+// @Router /api/v1/tokens [get]
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.tokens.List(r.Context())
+	if err != nil {
+		s.writeError(w, "list tokens", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		s.logger.Warn("encode tokens", zap.Error(err))
+	}
+}
+
+// @Summary Create deploy token
+// @Description Creates a token scoped to a path prefix or glob and a set of verbs (read, write). The secret is only returned once; use the token id as Basic Auth username and the secret as password.
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Param token body CreateTokenRequest true "Token scope"
+// @Success 201 {object} CreateTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/tokens [post]
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	created, err := s.tokens.Create(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.audit(r, "token.created", zap.String("token", created.ID), zap.String("prefix", created.Prefix), zap.Strings("verbs", created.Verbs))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		s.logger.Warn("encode token", zap.Error(err))
+	}
+}
+
+// @Summary List revoked deploy tokens
+// @Tags tokens
+// @Produce json
+// @Success 200 {array} server.DeployToken
+// @Security BasicAuth
+// @Router /api/v1/tokens/revoked [get]
+func (s *Server) handleListRevokedTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.tokens.Revoked(r.Context())
+	if err != nil {
+		s.writeError(w, "list revoked tokens", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		s.logger.Warn("encode tokens", zap.Error(err))
+	}
+}
+
+// @Summary Rotate deploy token
+// @Description Issues a replacement token with the same scope; the old token keeps working for the grace period (default 15m).
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Param id path string true "Token id"
+// @Param request body RotateTokenRequest false "Grace period"
+// @Success 201 {object} CreateTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Token revoked or expired"
+// @Security BasicAuth
+// @Router /api/v1/tokens/{id}/rotate [post]
+func (s *Server) handleRotateToken(w http.ResponseWriter, r *http.Request, id string) {
+	var req RotateTokenRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAPIError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	created, err := s.tokens.Rotate(r.Context(), id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errTokenMissing):
+			writeAPIError(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, errTokenRevoked):
+			writeAPIError(w, err.Error(), http.StatusConflict)
+		default:
+			writeAPIError(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	s.audit(r, "token.rotated", zap.String("token", id), zap.String("replacement", created.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		s.logger.Warn("encode token", zap.Error(err))
+	}
+}
+
+// @Summary Revoke deploy token
+// @Description Marks the token revoked; it stays listed under /tokens/revoked.
+// @Tags tokens
+// @Produce plain
+// @Param id path string true "Token id"
+// @Success 204 {string} string "Revoked"
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/tokens/{id} [delete]
+func (s *Server) handleDeleteToken(w http.ResponseWriter, r *http.Request, id string) {
+	if err := s.tokens.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, errTokenMissing) {
+			writeAPIError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.writeError(w, "delete token", err)
+		return
+	}
+	s.audit(r, "token.revoked", zap.String("token", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Create signed download URL
+// @Description Returns a time-limited HMAC-signed URL for one artifact that can be downloaded (GET/HEAD) without credentials.
+// @Tags artifacts
+// @Accept json
+// @Produce json
+// @Param request body SignRequest true "Artifact path and lifetime"
+// @Success 200 {object} SignResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/sign [post]
+func (s *Server) handleSign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req SignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	key := strings.Trim(strings.TrimSpace(req.Path), "/")
+	if key == "" || isInternalPath(key) {
+		writeAPIError(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	ttl := defaultSignedURLTTL
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 || d > maxSignedURLTTL {
+			writeAPIError(w, "invalid expiresIn; expected a duration up to 168h", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("read", key) {
+		writeAPIError(w, "token not permitted for this path", http.StatusForbidden)
+		return
+	}
+	if _, err := s.store.Head(r.Context(), key); err != nil {
+		s.writeError(w, "sign head", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	s.audit(r, "url.signed", zap.String("key", key), zap.Time("expiresAt", expiresAt))
+	w.Header().Set("Content-Type", "application/json")
+	resp := SignResponse{
+		URL:       requestBaseURL(r) + "/" + key + "?" + s.signer.Sign(key, expiresAt),
+		Path:      key,
+		ExpiresAt: expiresAt,
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Warn("encode signed url", zap.Error(err))
+	}
+}
+
+// @Summary Proxy cache statistics
+// @Description Hit/miss counts, bytes served from cache vs upstream and estimated bandwidth saved per proxy since startup.
+// @Tags proxies
+// @Produce json
+// @Success 200 {object} CacheStatsReport
+// @Security BasicAuth
+// @Router /api/v1/stats/cache [get]
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	proxies, err := s.proxy.List(r.Context())
+	if err != nil {
+		s.writeError(w, "list proxies", err)
+		return
+	}
+	names := make([]string, 0, len(proxies))
+	for _, pr := range proxies {
+		names = append(names, pr.Name)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.cache.Report(names)); err != nil {
+		s.logger.Warn("encode cache stats", zap.Error(err))
+	}
+}
+
+// @Summary Invalidate cached proxy artifacts
+// @Description Deletes cached objects (and their checksum sidecars) matching a path or a glob pattern relative to the proxy; "**" matches across directories.
+// @Tags proxies
+// @Accept json
+// @Produce json
+// @Param name path string true "Proxy name"
+// @Param request body InvalidateRequest true "Path or pattern to purge"
+// @Success 200 {object} InvalidateResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/proxies/{name}/invalidate [post]
+func (s *Server) handleInvalidateProxy(w http.ResponseWriter, r *http.Request, name string) {
+	var req InvalidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	deleted, err := s.proxy.Invalidate(r.Context(), name, req)
+	var invalid invalidRequestError
+	switch {
+	case errors.Is(err, errProxyNotFound):
+		writeAPIError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.As(err, &invalid):
+		writeAPIError(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		s.writeError(w, "invalidate proxy cache", err)
+		return
+	}
+	s.logger.Info("proxy cache invalidated", zap.String("proxy", name), zap.Int("deleted", len(deleted)))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(InvalidateResult{Deleted: deleted}); err != nil {
+		s.logger.Warn("encode invalidate result", zap.Error(err))
+	}
+}
+
 // @Summary Group repository (packages) GET/HEAD
 // @Tags packages
 // @Produce application/octet-stream
-// @Failure 404 {string} string "Not Found"
+// @Failure 403 {object} ErrorResponse "Blocked by policy"
+// @Failure 404 {object} ErrorResponse "Not Found"
 // @Security BasicAuth
 // @Router /packages/{artifactPath} [get]
 // @Router /packages/{artifactPath} [head]
 func (s *Server) handlePackages(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/packages/")
 	if key == "" || key == "packages" {
-		http.NotFound(w, r)
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && strings.HasSuffix(key, "/") {
+		s.handlePackageDirectory(w, r, strings.TrimSuffix(key, "/"))
+		return
+	}
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && !s.allowDownload(w, r, key) {
 		return
 	}
 	switch r.Method {
@@ -332,7 +1040,7 @@ func (s *Server) handlePackages(w http.ResponseWriter, r *http.Request) {
 		s.handlePackageHead(w, r, key)
 	default:
 		w.Header().Set("Allow", "GET, HEAD")
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -367,7 +1075,7 @@ func (s *Server) listPackages(ctx context.Context, prefix string, limit int32) (
 	seen := map[string]struct{}{}
 	add := func(e storage.Entry) {
 		trimmed := strings.TrimPrefix(e.Path, "packages/")
-		if strings.HasPrefix(trimmed, proxyConfigPrefix) || strings.HasPrefix(e.Name, proxyConfigPrefix) {
+		if isInternalPath(trimmed) || isInternalPath(e.Name) {
 			return
 		}
 		if e.Type == "dir" || e.Type == "proxy" || e.Type == "group" {
@@ -432,7 +1140,7 @@ func (s *Server) handlePackageGet(w http.ResponseWriter, r *http.Request, key st
 	// local direct
 	if resp, ok := s.tryLocalGet(r.Context(), key); ok {
 		defer resp.Body.Close()
-		s.writeObjectResponse(w, resp)
+		s.writeObjectResponse(w, r, resp)
 		return
 	}
 
@@ -443,10 +1151,20 @@ func (s *Server) handlePackageGet(w http.ResponseWriter, r *http.Request, key st
 		return
 	}
 	for _, pr := range proxies {
-		resp, err := s.store.Get(r.Context(), path.Join(pr.Name, key))
+		if !pr.caches() {
+			continue
+		}
+		cacheKey := path.Join(pr.Name, key)
+		resp, err := s.store.Get(r.Context(), cacheKey)
+		if err == nil && s.revalidate(r.Context(), cacheKey, resp.LastModified, resp.Metadata) {
+			resp.Body.Close()
+			resp, err = s.store.Get(r.Context(), cacheKey)
+		}
 		if err == nil {
 			defer resp.Body.Close()
-			s.writeObjectResponse(w, resp)
+			s.downloads.Record(cacheKey)
+			s.cache.record(cacheKey, cacheHit, objectSize(resp))
+			s.writeObjectResponse(w, r, resp)
 			return
 		}
 		if err != nil && !storage.IsNotFound(err) {
@@ -456,26 +1174,34 @@ func (s *Server) handlePackageGet(w http.ResponseWriter, r *http.Request, key st
 	}
 
 	// fetch from upstream
-	cacheKey, found, err := s.proxy.FetchFromAny(r.Context(), key)
+	cacheKey, presp, found, err := s.proxy.FetchFromAny(r.Context(), key)
 	if err != nil {
 		s.writeError(w, "proxy fetch", err)
 		return
 	}
 	if !found {
-		http.NotFound(w, r)
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if presp != nil {
+		defer presp.Body.Close()
+		s.cache.record(cacheKey, cachePassthrough, presp.ContentLength)
+		s.writeUpstreamResponse(w, r, presp)
 		return
 	}
 	resp, err = s.store.Get(r.Context(), cacheKey)
 	if err != nil {
 		if storage.IsNotFound(err) {
-			http.NotFound(w, r)
+			writeAPIError(w, "not found", http.StatusNotFound)
 			return
 		}
 		s.writeError(w, "fetch cached proxy object", err)
 		return
 	}
 	defer resp.Body.Close()
-	s.writeObjectResponse(w, resp)
+	s.downloads.Record(cacheKey)
+	s.cache.record(cacheKey, cacheMiss, objectSize(resp))
+	s.writeObjectResponse(w, r, resp)
 }
 
 func (s *Server) handlePackageHead(w http.ResponseWriter, r *http.Request, key string) {
@@ -490,7 +1216,11 @@ func (s *Server) handlePackageHead(w http.ResponseWriter, r *http.Request, key s
 		return
 	}
 	for _, pr := range proxies {
-		resp, err := s.store.Head(r.Context(), path.Join(pr.Name, key))
+		cacheKey := path.Join(pr.Name, key)
+		resp, err := s.store.Head(r.Context(), cacheKey)
+		if err == nil && s.revalidate(r.Context(), cacheKey, resp.LastModified, resp.Metadata) {
+			resp, err = s.store.Head(r.Context(), cacheKey)
+		}
 		if err == nil {
 			s.writeHeadResponse(w, resp)
 			return
@@ -508,20 +1238,26 @@ func (s *Server) handlePackageHead(w http.ResponseWriter, r *http.Request, key s
 	}
 	if found {
 		defer presp.Body.Close()
-		if cl := presp.Header.Get("Content-Length"); cl != "" {
-			w.Header().Set("Content-Length", cl)
-		}
-		if ct := presp.Header.Get("Content-Type"); ct != "" {
-			w.Header().Set("Content-Type", ct)
-		}
-		if lm := presp.Header.Get("Last-Modified"); lm != "" {
-			w.Header().Set("Last-Modified", lm)
-		}
+		copyUpstreamHeaders(w, presp)
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	http.NotFound(w, r)
+	writeAPIError(w, "not found", http.StatusNotFound)
+}
+
+// revalidate refreshes an expired proxy cache entry and reports whether the
+// object was replaced. Upstream failures keep the cached copy.
+func (s *Server) revalidate(ctx context.Context, key string, lastModified *time.Time, metadata map[string]string) bool {
+	if lastModified == nil {
+		return false
+	}
+	refreshed, err := s.proxy.Revalidate(ctx, key, *lastModified, metadata[upstreamETagMeta])
+	if err != nil {
+		s.logger.Warn("revalidate cached proxy object", zap.String("key", key), zap.Error(err))
+		return false
+	}
+	return refreshed
 }
 
 func (s *Server) tryLocalGet(ctx context.Context, key string) (*s3.GetObjectOutput, bool) {
@@ -533,16 +1269,14 @@ func (s *Server) tryLocalGet(ctx context.Context, key string) (*s3.GetObjectOutp
 		return nil, false
 	}
 
-	roots, err := s.store.List(ctx, "", 1000)
+	roots, err := s.roots.candidates(ctx, key)
 	if err != nil {
 		return nil, false
 	}
-	for _, e := range roots {
-		if e.Type != "dir" {
-			continue
-		}
-		resp, err := s.store.Get(ctx, path.Join(e.Name, key))
+	for _, root := range roots {
+		resp, err := s.store.Get(ctx, path.Join(root, key))
 		if err == nil {
+			s.roots.remember(key, root)
 			return resp, true
 		}
 	}
@@ -558,16 +1292,14 @@ func (s *Server) tryLocalHead(ctx context.Context, key string) (*s3.HeadObjectOu
 		return nil, false
 	}
 
-	roots, err := s.store.List(ctx, "", 1000)
+	roots, err := s.roots.candidates(ctx, key)
 	if err != nil {
 		return nil, false
 	}
-	for _, e := range roots {
-		if e.Type != "dir" {
-			continue
-		}
-		resp, err := s.store.Head(ctx, path.Join(e.Name, key))
+	for _, root := range roots {
+		resp, err := s.store.Head(ctx, path.Join(root, key))
 		if err == nil {
+			s.roots.remember(key, root)
 			return resp, true
 		}
 	}
@@ -590,7 +1322,7 @@ func (s *Server) writeHeadResponse(w http.ResponseWriter, resp *s3.HeadObjectOut
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) writeObjectResponse(w http.ResponseWriter, resp *s3.GetObjectOutput) {
+func (s *Server) writeObjectResponse(w http.ResponseWriter, r *http.Request, resp *s3.GetObjectOutput) {
 	if resp.ContentLength != nil && *resp.ContentLength >= 0 {
 		w.Header().Set("Content-Length", strconv.FormatInt(*resp.ContentLength, 10))
 	}
@@ -604,18 +1336,79 @@ func (s *Server) writeObjectResponse(w http.ResponseWriter, resp *s3.GetObjectOu
 		w.Header().Set("Last-Modified", resp.LastModified.UTC().Format(http.TimeFormat))
 	}
 	w.WriteHeader(http.StatusOK)
-	if _, err := io.Copy(w, resp.Body); err != nil {
+	if _, err := io.Copy(s.throttle.Writer(w, r), resp.Body); err != nil {
 		s.logger.Warn("stream object", zap.Error(err))
 	}
 }
 
+func copyUpstreamHeaders(w http.ResponseWriter, resp *http.Response) {
+	for _, h := range []string{"Content-Length", "Content-Type", "ETag", "Last-Modified"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+}
+
+func (s *Server) writeUpstreamResponse(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	copyUpstreamHeaders(w, resp)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(s.throttle.Writer(w, r), resp.Body); err != nil {
+		s.logger.Warn("stream upstream object", zap.Error(err))
+	}
+}
+
+func (s *Server) allowDownload(w http.ResponseWriter, r *http.Request, key string) bool {
+	isRepo := func(name string) bool { return s.isRepository(r.Context(), name) }
+	if rule, blocked := s.blocked.Match(key, isRepo); blocked {
+		s.logger.Info("blocked artifact request", zap.String("key", key), zap.String("rule", rule))
+		writeProblem(w, problemBlocked, http.StatusForbidden, BlockedError{Rule: rule}.Error())
+		return false
+	}
+	return s.enforcePolicy(w, r, policyInputFor(r, key, "download"))
+}
+
+// isRepository reports whether name is a proxy or a hosted repository (a
+// root of the bucket). Names that cannot be checked count as unknown.
+func (s *Server) isRepository(ctx context.Context, name string) bool {
+	if _, ok, _ := s.proxy.lookup(ctx, name); ok {
+		return true
+	}
+	return s.roots.has(ctx, name)
+}
+
 func (s *Server) handleObject(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/")
-	if key == "" || key == "healthz" {
-		http.NotFound(w, r)
+	if key == "" || key == "healthz" || key == "livez" {
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if tok := principalFrom(r.Context()).token; tok != nil {
+		verb := "read"
+		if r.Method == http.MethodPut {
+			verb = "write"
+		}
+		if !tok.Allows(verb, key) {
+			writeAPIError(w, "token not permitted for this path", http.StatusForbidden)
+			return
+		}
+	}
+
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && strings.HasSuffix(key, "/") {
+		s.handleDirectory(w, r, strings.TrimSuffix(key, "/"))
 		return
 	}
 
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !s.allowDownload(w, r, key) {
+			return
+		}
+	case http.MethodPut:
+		if !s.enforceLayout(w, key) {
+			return
+		}
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		s.handleGet(w, r, key)
@@ -625,7 +1418,7 @@ func (s *Server) handleObject(w http.ResponseWriter, r *http.Request) {
 		s.handlePut(w, r, key)
 	default:
 		w.Header().Set("Allow", "GET, HEAD, PUT")
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -634,13 +1427,38 @@ func (s *Server) handleObject(w http.ResponseWriter, r *http.Request) {
 // @Param artifactPath path string true "Artifact path (maps to S3 key with optional prefix)"
 // @Produce application/octet-stream
 // @Success 200 {file} file
-// @Failure 404 {string} string "Not Found"
+// @Success 302 {string} string "Signed CDN URL (CDN_URL)"
+// @Failure 403 {object} ErrorResponse "Blocked by policy"
+// @Failure 404 {object} ErrorResponse "Not Found"
 // @Security BasicAuth
 // @Router /{artifactPath} [get]
 func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, key string) {
+	if s.cdn != nil && s.redirectToCDN(w, r, key) {
+		return
+	}
 	resp, err := s.store.Get(r.Context(), key)
+	if err == nil && s.revalidate(r.Context(), key, resp.LastModified, resp.Metadata) {
+		resp.Body.Close()
+		resp, err = s.store.Get(r.Context(), key)
+	}
+	if err == nil {
+		s.cache.record(key, cacheHit, objectSize(resp))
+	}
 	if err != nil {
 		if storage.IsNotFound(err) {
+			if qerr := checkQuarantine(r.Context(), s.store, key); qerr != nil {
+				s.writeError(w, "check quarantine", qerr)
+				return
+			}
+			if presp, found, perr := s.proxy.Passthrough(r.Context(), key); perr != nil {
+				s.writeError(w, "proxy fetch", perr)
+				return
+			} else if found {
+				defer presp.Body.Close()
+				s.cache.record(key, cachePassthrough, presp.ContentLength)
+				s.writeUpstreamResponse(w, r, presp)
+				return
+			}
 			if found, perr := s.proxy.FetchAndCache(r.Context(), key); perr != nil {
 				s.writeError(w, "proxy fetch", perr)
 				return
@@ -651,8 +1469,9 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, key string) {
 					return
 				}
 				defer resp.Body.Close()
+				s.cache.record(key, cacheMiss, objectSize(resp))
 			} else {
-				http.NotFound(w, r)
+				writeAPIError(w, "not found", http.StatusNotFound)
 				return
 			}
 		} else {
@@ -661,6 +1480,7 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, key string) {
 		}
 	}
 	defer resp.Body.Close()
+	s.downloads.Record(key)
 
 	if resp.ContentLength != nil && *resp.ContentLength >= 0 {
 		w.Header().Set("Content-Length", strconv.FormatInt(*resp.ContentLength, 10))
@@ -676,40 +1496,49 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, key string) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-	if _, err := io.Copy(w, resp.Body); err != nil {
+	out := s.throttle.Writer(w, r)
+	if !s.verifiesDownload(key) {
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			s.logger.Warn("stream object", zap.String("key", key), zap.Error(err))
+		}
+		return
+	}
+	h := sha1.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), resp.Body); err != nil {
 		s.logger.Warn("stream object", zap.String("key", key), zap.Error(err))
+		return
 	}
+	s.checkDownloadDigest(context.WithoutCancel(r.Context()), key, hex.EncodeToString(h.Sum(nil)))
 }
 
 // @Summary Artifact metadata
 // @Tags artifacts
 // @Param artifactPath path string true "Artifact path (maps to S3 key with optional prefix)"
 // @Success 200 {string} string "OK"
-// @Failure 404 {string} string "Not Found"
+// @Failure 404 {object} ErrorResponse "Not Found"
 // @Security BasicAuth
 // @Router /{artifactPath} [head]
 func (s *Server) handleHead(w http.ResponseWriter, r *http.Request, key string) {
 	resp, err := s.store.Head(r.Context(), key)
+	if err == nil && s.revalidate(r.Context(), key, resp.LastModified, resp.Metadata) {
+		resp, err = s.store.Head(r.Context(), key)
+	}
 	if err != nil {
 		if storage.IsNotFound(err) {
+			if qerr := checkQuarantine(r.Context(), s.store, key); qerr != nil {
+				s.writeError(w, "check quarantine", qerr)
+				return
+			}
 			if presp, found, perr := s.proxy.Head(r.Context(), key); perr != nil {
 				s.writeError(w, "proxy head", perr)
 				return
 			} else if found {
 				defer presp.Body.Close()
-				if cl := presp.Header.Get("Content-Length"); cl != "" {
-					w.Header().Set("Content-Length", cl)
-				}
-				if ct := presp.Header.Get("Content-Type"); ct != "" {
-					w.Header().Set("Content-Type", ct)
-				}
-				if lm := presp.Header.Get("Last-Modified"); lm != "" {
-					w.Header().Set("Last-Modified", lm)
-				}
+				copyUpstreamHeaders(w, presp)
 				w.WriteHeader(http.StatusOK)
 				return
 			}
-			http.NotFound(w, r)
+			writeAPIError(w, "not found", http.StatusNotFound)
 			return
 		}
 		s.writeError(w, "head object", err)
@@ -732,82 +1561,216 @@ func (s *Server) handleHead(w http.ResponseWriter, r *http.Request, key string)
 	w.WriteHeader(http.StatusOK)
 }
 
+// DeployResponse describes a stored upload in the shape of Artifactory's
+// deploy response, which CI plugins parse for the checksums and download URI.
+type DeployResponse struct {
+	Repo        string          `json:"repo" example:"releases"`
+	Path        string          `json:"path" example:"/com/acme/lib/1.0/lib-1.0.jar"`
+	Key         string          `json:"key" example:"releases/com/acme/lib/1.0/lib-1.0.jar"`
+	DownloadURI string          `json:"downloadUri" example:"https://repo.example.com/releases/com/acme/lib/1.0/lib-1.0.jar"`
+	MimeType    string          `json:"mimeType" example:"application/java-archive"`
+	Size        int64           `json:"size,string" example:"1024"`
+	Created     time.Time       `json:"created"`
+	CreatedBy   string          `json:"createdBy,omitempty" example:"ci"`
+	Checksums   DeployChecksums `json:"checksums"`
+}
+
+type DeployChecksums struct {
+	SHA1   string `json:"sha1"`
+	MD5    string `json:"md5"`
+	SHA256 string `json:"sha256"`
+}
+
 // @Summary Upload artifact
+// @Description Stores the body under the path. The 201 response carries a Location header and a JSON body with the stored key, size and SHA-1, MD5 and SHA-256, like Artifactory's deploy response.
 // @Tags artifacts
 // @Param artifactPath path string true "Artifact path (maps to S3 key with optional prefix)"
 // @Accept application/octet-stream
-// @Produce plain
-// @Success 201 {string} string "Created"
+// @Produce json
+// @Success 201 {object} DeployResponse
+// @Failure 409 {object} ErrorResponse "Write-once path already holds different content"
+// @Failure 422 {object} ErrorResponse "Infected file or rejected by the signing service"
+// @Failure 503 {object} ErrorResponse "Virus scanner or signing service unavailable"
 // @Security BasicAuth
 // @Router /{artifactPath} [put]
 func (s *Server) handlePut(w http.ResponseWriter, r *http.Request, key string) {
 	defer r.Body.Close()
 
 	if r.ContentLength < 0 {
-		http.Error(w, "Content-Length required", http.StatusLengthRequired)
+		writeAPIError(w, "Content-Length required", http.StatusLengthRequired)
 		return
 	}
+	size := r.ContentLength
 
 	contentType := r.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	tmp, err := os.CreateTemp("", "heimdall-upload-*")
+	// sha1 and sha256 are indexed and md5 is reported whatever sidecars
+	// are written.
+	algorithms := append([]string{"sha1", "md5", "sha256"}, s.checksums...)
+	sums := storage.NewChecksums(algorithms...)
+	body, release, err := s.bufferUpload(r.Body, r.ContentLength, sums)
 	if err != nil {
 		s.writeError(w, "buffer upload", err)
 		return
 	}
-	defer func() {
-		tmp.Close()
-		os.Remove(tmp.Name())
-	}()
+	defer release()
 
-	if _, err := io.CopyN(tmp, r.Body, r.ContentLength); err != nil && !errors.Is(err, io.EOF) {
-		s.writeError(w, "buffer upload copy", err)
+	// The policy sees the checksums of the content, so it is asked once
+	// the body is read.
+	in := policyInputFor(r, key, "upload")
+	in.Size, in.SHA1, in.SHA256 = size, sums.Sum("sha1"), sums.Sum("sha256")
+	if !s.enforcePolicy(w, r, in) {
 		return
 	}
-	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
-		s.writeError(w, "buffer upload seek", err)
+	if s.signedSidecar(r.Context(), key) {
+		// Computed by the client over the unsigned artifact; the signed
+		// one has its own.
+		w.WriteHeader(http.StatusCreated)
 		return
 	}
 
-	sha1h := sha1.New()
-	md5h := md5.New()
-	if _, err := io.Copy(io.MultiWriter(sha1h, md5h), tmp); err != nil {
-		s.writeError(w, "compute checksum", err)
-		return
+	if s.clamav != nil {
+		if err := s.clamav.Scan(body); err != nil {
+			var infected InfectedError
+			if errors.As(err, &infected) {
+				s.audit(r, "upload.infected", zap.String("signature", infected.Signature))
+				writeAPIError(w, infected.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			s.logger.Error("clamav scan", zap.Error(err))
+			writeAPIError(w, "virus scanner unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			s.writeError(w, "buffer upload seek", err)
+			return
+		}
 	}
 
-	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
-		s.writeError(w, "buffer upload seek start", err)
-		return
+	var signed *SignedArtifact
+	if s.signingHook != nil && s.signingHook.Matches(key) {
+		if signed, err = s.signingHook.Sign(r.Context(), key, principalFrom(r.Context()).name, body, size); err != nil {
+			var rejected SigningRejectedError
+			switch {
+			case errors.As(err, &rejected):
+				s.audit(r, "upload.signing_rejected", zap.String("reason", rejected.Reason))
+				writeAPIError(w, rejected.Error(), http.StatusUnprocessableEntity)
+				return
+			case !s.signingHook.FailOpen:
+				s.logger.Error("sign artifact", zap.String("key", key), zap.Error(err))
+				writeAPIError(w, "signing service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			s.logger.Warn("sign artifact; storing it unsigned", zap.String("key", key), zap.Error(err))
+		}
+		if signed != nil && signed.Artifact != nil {
+			body = bytes.NewReader(signed.Artifact)
+			size = int64(len(signed.Artifact))
+			sums = storage.NewChecksums(algorithms...)
+			_, _ = sums.Write(signed.Artifact)
+		} else if _, err := body.Seek(0, io.SeekStart); err != nil {
+			s.writeError(w, "buffer upload seek", err)
+			return
+		}
 	}
 
-	err = s.store.Put(r.Context(), key, tmp, contentType, r.ContentLength)
+	sha1sum := sums.Sum("sha1")
+
+	// Metadata and its checksums are written as one unit so concurrent
+	// deploys through different replicas cannot mix them.
+	if path.Base(key) == mavenMetadataFile {
+		unlock, err := s.lockMetadata(r.Context(), key)
+		if errors.Is(err, errMetadataLocked) {
+			w.Header().Set("Retry-After", "1")
+			writeAPIError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			s.writeError(w, "lock metadata", err)
+			return
+		}
+		defer unlock()
+	}
+
+	// A signing service may have replaced the uploaded bytes.
+	err = s.putArtifact(r.Context(), key, body, contentType, size, signed == nil || signed.Artifact == nil)
+	if storage.IsImmutable(err) && s.storedSHA1(r.Context(), key) == sha1sum {
+		// Deploying the same bytes again, like the .sha1 clients upload after
+		// the server already wrote it, does not overwrite anything.
+		s.writeDeployResponse(w, r, key, contentType, size, sums)
+		return
+	}
 	if err != nil {
 		s.writeError(w, "store object", err)
 		return
 	}
+	s.roots.noteWrite(key)
 
-	sha1sum := hex.EncodeToString(sha1h.Sum(nil))
-	md5sum := hex.EncodeToString(md5h.Sum(nil))
+	for _, name := range s.checksums {
+		sum := sums.Sum(name)
+		if err := s.store.Put(r.Context(), key+"."+name, strings.NewReader(sum), "text/plain", int64(len(sum))); err != nil {
+			s.writeError(w, "store "+name, err)
+			return
+		}
+	}
+	if !isChecksumPath(key) && !isMetadataPath(key) {
+		s.indexChecksum(r.Context(), "sha1", sha1sum, key)
+		s.indexChecksum(r.Context(), "sha256", sums.Sum("sha256"), key)
+	}
+	if strings.HasSuffix(key, ".pom") {
+		s.indexDependencies(r.Context(), key, io.NewSectionReader(body, 0, size))
+	}
 
-	if err := s.store.Put(r.Context(), key+".sha1", strings.NewReader(sha1sum), "text/plain", int64(len(sha1sum))); err != nil {
-		s.writeError(w, "store sha1", err)
-		return
+	if signed != nil {
+		if err := s.storeSignatures(r.Context(), key, signed); err != nil {
+			s.writeError(w, "store signatures", err)
+			return
+		}
 	}
-	if err := s.store.Put(r.Context(), key+".md5", strings.NewReader(md5sum), "text/plain", int64(len(md5sum))); err != nil {
-		s.writeError(w, "store md5", err)
-		return
+
+	if err := s.updatePluginMetadata(r.Context(), key, body, size); err != nil {
+		s.logger.Warn("update plugin group metadata", zap.String("key", key), zap.Error(err))
 	}
 
+	repo, _, _ := strings.Cut(key, "/")
+	s.scans.Submit(repo, key)
+
+	s.writeDeployResponse(w, r, key, contentType, size, sums)
+}
+
+func (s *Server) writeDeployResponse(w http.ResponseWriter, r *http.Request, key, contentType string, size int64, sums *storage.Checksums) {
+	repo, rest, _ := strings.Cut(key, "/")
+	location := requestBaseURL(r) + "/" + key
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(DeployResponse{
+		Repo:        repo,
+		Path:        "/" + rest,
+		Key:         key,
+		DownloadURI: location,
+		MimeType:    contentType,
+		Size:        size,
+		Created:     time.Now().UTC(),
+		CreatedBy:   principalName(r),
+		Checksums:   DeployChecksums{SHA1: sums.Sum("sha1"), MD5: sums.Sum("md5"), SHA256: sums.Sum("sha256")},
+	})
+	if err != nil {
+		s.logger.Warn("encode deploy response", zap.Error(err))
+	}
 }
 
 func (s *Server) writeError(w http.ResponseWriter, action string, err error) {
 	if storage.IsNotFound(err) {
-		http.NotFound(w, nil)
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if storage.IsTimeout(err) {
+		s.logger.Warn(action, zap.Error(err))
+		writeProblem(w, problemStorageTimeout, http.StatusGatewayTimeout, "storage timeout")
 		return
 	}
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -816,16 +1779,61 @@ func (s *Server) writeError(w http.ResponseWriter, action string, err error) {
 	}
 	var se ProxyStatusError
 	if errors.As(err, &se) {
-		http.Error(w, http.StatusText(se.Code), se.Code)
+		if se.Code == http.StatusNotFound {
+			writeAPIError(w, "not found", http.StatusNotFound)
+			return
+		}
+		writeProblem(w, problemUpstreamStatus, se.Code, se.Error())
+		return
+	}
+	var be BlockedError
+	if errors.As(err, &be) {
+		writeProblem(w, problemBlocked, http.StatusForbidden, be.Error())
+		return
+	}
+	var qe QuarantinedError
+	if errors.As(err, &qe) {
+		writeProblem(w, problemQuarantined, http.StatusConflict, qe.Error())
+		return
+	}
+	if storage.IsImmutable(err) {
+		s.logger.Info(action, zap.Error(err))
+		writeProblem(w, problemImmutable, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, errUpstreamBusy) {
+		s.logger.Warn(action, zap.Error(err))
+		w.Header().Set("Retry-After", "1")
+		writeProblem(w, problemUpstreamBusy, http.StatusServiceUnavailable, err.Error())
 		return
 	}
 	s.logger.Error(action, zap.Error(err))
-	http.Error(w, "internal server error", http.StatusInternalServerError)
+	for u := w; u != nil; {
+		if c, ok := u.(*errorCapture); ok {
+			c.err, c.action = err, action
+			break
+		}
+		wrapped, ok := u.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		u = wrapped.Unwrap()
+	}
+	if storage.IsChecksumMismatch(err) {
+		writeProblem(w, problemChecksumMismatch, http.StatusBadGateway, "storage checksum mismatch")
+		return
+	}
+	if storage.IsRetryExhausted(err) {
+		writeProblem(w, problemStorageUnavailable, http.StatusServiceUnavailable, "storage unavailable: retries exhausted")
+		return
+	}
+	writeAPIError(w, "internal server error", http.StatusInternalServerError)
 }
 
 type responseWriter struct {
 	http.ResponseWriter
 	status int
+	bytes  int64
 }
 
 func (rw *responseWriter) WriteHeader(status int) {
@@ -833,18 +1841,122 @@ func (rw *responseWriter) WriteHeader(status int) {
 	rw.ResponseWriter.WriteHeader(status)
 }
 
-func loggingMiddleware(logger *zap.Logger, next http.Handler) http.Handler {
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// requestTrace collects details about a request that are only known once
+// inner handlers ran: who made it, and, for slow requests, the upstreams
+// involved.
+type requestTrace struct {
+	mu        sync.Mutex
+	upstreams []string
+	principal string
+}
+
+func (t *requestTrace) upstreamList() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.upstreams)
+}
+
+func (t *requestTrace) principalName() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.principal
+}
+
+type requestTraceKey struct{}
+
+// traceUpstream notes that serving the request involved a call to upstream.
+func traceUpstream(ctx context.Context, upstream string) {
+	if t, ok := ctx.Value(requestTraceKey{}).(*requestTrace); ok {
+		t.mu.Lock()
+		t.upstreams = append(t.upstreams, upstream)
+		t.mu.Unlock()
+	}
+}
+
+// tracePrincipal notes who the request was authenticated as.
+func tracePrincipal(ctx context.Context, name string) {
+	if t, ok := ctx.Value(requestTraceKey{}).(*requestTrace); ok {
+		t.mu.Lock()
+		t.principal = name
+		t.mu.Unlock()
+	}
+}
+
+func loggingMiddleware(logger *zap.Logger, slowThreshold time.Duration, sampling *TelemetrySampling, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		id := newRequestID(r)
+		w.Header().Set("X-Request-ID", id)
+		trace := &requestTrace{}
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		r = r.WithContext(context.WithValue(ctx, requestTraceKey{}, trace))
 		lrw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 		next.ServeHTTP(lrw, r)
-		if logger != nil {
-			logger.Info("request",
-				zap.String("method", r.Method),
-				zap.String("path", r.URL.Path),
-				zap.Int("status", lrw.status),
-				zap.Duration("duration", time.Since(start)),
+		if logger == nil {
+			return
+		}
+		duration := time.Since(start)
+		fields := []zap.Field{
+			zap.String("request_id", id),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", lrw.status),
+			zap.Duration("duration", duration),
+		}
+		if p := trace.principalName(); p != "" {
+			fields = append(fields, zap.String("principal", p))
+		}
+		if slowThreshold > 0 && duration >= slowThreshold {
+			fields = append(fields,
+				zap.String("key", strings.TrimPrefix(r.URL.Path, "/")),
+				zap.Int64("bytes", lrw.bytes),
+				zap.Strings("upstreams", trace.upstreamList()),
+				zap.Duration("threshold", slowThreshold),
 			)
+			logger.Warn("slow request", fields...)
+			return
+		}
+		if sampling.keep(r, lrw.status) {
+			logger.Info("request", fields...)
+		}
+	})
+}
+
+// countPrincipals counts requests and bytes per authenticated principal. It
+// runs inside loggingMiddleware, whose trace the auth middleware fills in.
+func (s *Server) countPrincipals(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		body := &countingBody{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		next.ServeHTTP(rw, r)
+		var name string
+		if t, ok := r.Context().Value(requestTraceKey{}).(*requestTrace); ok {
+			name = t.principalName()
 		}
+		label := s.metrics.Principal(name)
+		s.metrics.PrincipalRequests.WithLabelValues(label).Inc()
+		s.metrics.PrincipalBytes.WithLabelValues(label, "in").Add(float64(body.n))
+		s.metrics.PrincipalBytes.WithLabelValues(label, "out").Add(float64(rw.bytes))
 	})
 }
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
@@ -11,23 +11,30 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/otoru/heimdall/internal/metrics"
 	"github.com/otoru/heimdall/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 type mockStore struct {
-	getResp  *s3.GetObjectOutput
-	headResp *s3.HeadObjectOutput
-	getErr   error
-	headErr  error
-	putErr   error
-	listResp []storage.Entry
-	listErr  error
-	putKeys  []string
+	getResp   *s3.GetObjectOutput
+	headResp  *s3.HeadObjectOutput
+	getErr    error
+	headErr   error
+	putErr    error
+	listResp  []storage.Entry
+	listErr   error
+	nextToken string
+	putKeys   []string
 }
 
 func (m *mockStore) Get(ctx context.Context, key string) (*s3.GetObjectOutput, error) {
@@ -56,15 +63,41 @@ func (m *mockStore) List(ctx context.Context, prefix string, limit int32) ([]sto
 	return m.listResp, nil
 }
 
-func (m *mockStore) GenerateChecksums(ctx context.Context, prefix string) error {
+func (m *mockStore) ListPage(ctx context.Context, prefix, token string, limit int32) (storage.Page, error) {
+	if m.listErr != nil {
+		return storage.Page{}, m.listErr
+	}
+	return storage.Page{Entries: m.listResp, NextToken: m.nextToken}, nil
+}
+
+func (m *mockStore) Walk(ctx context.Context, prefix string, fn func(storage.Entry) error) error {
+	for _, e := range m.listResp {
+		if e.Type == "file" {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return m.listErr
+}
+
+func (m *mockStore) GenerateChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error) {
+	return storage.ChecksumStats{}, nil
+}
+
+func (m *mockStore) CleanupBadChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error) {
+	return storage.ChecksumStats{}, nil
+}
+
+func (m *mockStore) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-func (m *mockStore) CleanupBadChecksums(ctx context.Context, prefix string) error {
+func (m *mockStore) Touch(ctx context.Context, key string, metadata map[string]string) error {
 	return nil
 }
 
-func (m *mockStore) Delete(ctx context.Context, key string) error {
+func (m *mockStore) Copy(ctx context.Context, src, dst string) error {
 	return nil
 }
 
@@ -117,11 +150,44 @@ func (s *listStore) List(ctx context.Context, prefix string, limit int32) ([]sto
 	return nil, nil
 }
 
-func (s *listStore) GenerateChecksums(ctx context.Context, prefix string) error { return nil }
-func (s *listStore) CleanupBadChecksums(ctx context.Context, prefix string) error {
+func (s *listStore) ListPage(ctx context.Context, prefix, token string, limit int32) (storage.Page, error) {
+	entries, err := s.List(ctx, prefix, limit)
+	return storage.Page{Entries: entries}, err
+}
+
+func (s *listStore) Walk(ctx context.Context, prefix string, fn func(storage.Entry) error) error {
+	for _, e := range s.listByPrefix[prefix] {
+		var err error
+		if e.Type == "dir" {
+			err = s.Walk(ctx, e.Path, fn)
+		} else {
+			err = fn(e)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func (s *listStore) GenerateChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error) {
+	return storage.ChecksumStats{}, nil
+}
+func (s *listStore) CleanupBadChecksums(ctx context.Context, prefix string) (storage.ChecksumStats, error) {
+	return storage.ChecksumStats{}, nil
+}
 func (s *listStore) Delete(ctx context.Context, key string) error { delete(s.objects, key); return nil }
+func (s *listStore) Touch(ctx context.Context, key string, metadata map[string]string) error {
+	return nil
+}
+func (s *listStore) Copy(ctx context.Context, src, dst string) error {
+	b, ok := s.objects[src]
+	if !ok {
+		return fmt.Errorf("NotFound")
+	}
+	s.objects[dst] = b
+	return nil
+}
 
 func TestHandleGetOK(t *testing.T) {
 	store := &mockStore{
@@ -190,8 +256,110 @@ func TestHandlePutOK(t *testing.T) {
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("expected status 201, got %d", rr.Code)
 	}
-	if len(store.putKeys) != 3 {
-		t.Fatalf("expected 3 puts (artifact + checksums), got %d", len(store.putKeys))
+	var artifacts, markers int
+	for _, k := range store.putKeys {
+		if strings.HasPrefix(k, checksumIndexPrefix) {
+			markers++
+		} else {
+			artifacts++
+		}
+	}
+	if artifacts != 3 {
+		t.Fatalf("expected 3 puts (artifact + checksums), got %d", artifacts)
+	}
+	if markers != 2 {
+		t.Fatalf("expected sha1 and sha256 index markers, got %d", markers)
+	}
+}
+
+func TestHandlePutConfiguredChecksums(t *testing.T) {
+	store := newMemStore()
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{ChecksumAlgorithms: []string{"sha1", "sha512"}})
+	req := httptest.NewRequest(http.MethodPut, "/releases/a/1.0/a-1.0.jar", strings.NewReader("data"))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rr.Code)
+	}
+	if got := string(store.data["releases/a/1.0/a-1.0.jar.sha512"].body); len(got) != 128 {
+		t.Fatalf("sha512 sidecar = %q", got)
+	}
+	if _, ok := store.data["releases/a/1.0/a-1.0.jar.sha1"]; !ok {
+		t.Fatal("sha1 sidecar missing")
+	}
+	if _, ok := store.data["releases/a/1.0/a-1.0.jar.md5"]; ok {
+		t.Fatal("md5 sidecar written although not configured")
+	}
+}
+
+func TestHandlePutResponse(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	req := httptest.NewRequest(http.MethodPut, "http://repo.example.com/releases/a/1.0/a-1.0.jar", strings.NewReader("data"))
+	req.SetBasicAuth("admin", "secret")
+	req.Header.Set("Content-Type", "application/java-archive")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Location"); got != "http://repo.example.com/releases/a/1.0/a-1.0.jar" {
+		t.Fatalf("Location = %q", got)
+	}
+	var resp DeployResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %s: %v", rr.Body.String(), err)
+	}
+	want := DeployChecksums{
+		SHA1:   "a17c9aaa61e80a1bf71d0d850af4e5baa9800bbd",
+		MD5:    "8d777f385d3dfec8815d20f7496026dc",
+		SHA256: "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7",
+	}
+	if resp.Repo != "releases" || resp.Path != "/a/1.0/a-1.0.jar" || resp.Key != "releases/a/1.0/a-1.0.jar" ||
+		resp.Size != 4 || resp.MimeType != "application/java-archive" || resp.CreatedBy != "admin" || resp.Checksums != want {
+		t.Fatalf("response = %+v", resp)
+	}
+	if !strings.Contains(rr.Body.String(), `"size":"4"`) {
+		t.Fatalf("size not encoded as a string like Artifactory: %s", rr.Body.String())
+	}
+}
+
+// writeOnceStore refuses to replace existing objects below releases/, like
+// a storage.Store with WORM prefixes.
+type writeOnceStore struct {
+	*memStore
+}
+
+func (w writeOnceStore) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64) error {
+	if _, ok := w.data[key]; ok && strings.HasPrefix(key, "releases/") {
+		return storage.ImmutableError{Key: key}
+	}
+	return w.memStore.Put(ctx, key, body, contentType, contentLength)
+}
+
+func TestHandlePutWriteOnce(t *testing.T) {
+	store := writeOnceStore{newMemStore()}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	put := func(key, body string) int {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/"+key, strings.NewReader(body)))
+		return rr.Code
+	}
+
+	if code := put("releases/a/1.0/a-1.0.jar", "v1"); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	sidecar := string(store.data["releases/a/1.0/a-1.0.jar.sha1"].body)
+	if code := put("releases/a/1.0/a-1.0.jar.sha1", sidecar); code != http.StatusCreated {
+		t.Fatalf("expected the identical checksum upload to succeed, got %d", code)
+	}
+	if code := put("releases/a/1.0/a-1.0.jar", "v1"); code != http.StatusCreated {
+		t.Fatalf("expected an identical re-deploy to succeed, got %d", code)
+	}
+	if code := put("releases/a/1.0/a-1.0.jar", "v2"); code != http.StatusConflict {
+		t.Fatalf("expected 409 for different content, got %d", code)
+	}
+	if got := string(store.data["releases/a/1.0/a-1.0.jar"].body); got != "v1" {
+		t.Fatalf("expected the original artifact, got %q", got)
 	}
 }
 
@@ -208,7 +376,8 @@ func TestAuthRequired(t *testing.T) {
 }
 func TestHandleGetNotFound(t *testing.T) {
 	store := &mockStore{
-		getErr: errors.New("NotFound"),
+		getErr:  errors.New("NotFound"),
+		headErr: errors.New("NotFound"),
 	}
 	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
 	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
@@ -251,6 +420,24 @@ func TestWriteErrorCanceled(t *testing.T) {
 	}
 }
 
+func TestWriteErrorRetryExhausted(t *testing.T) {
+	rr := httptest.NewRecorder()
+	srv := New(&mockStore{}, zaptest.NewLogger(t), metrics.New(), "", "")
+	srv.writeError(rr, "fetch object", &retry.MaxAttemptsError{Attempt: 3, Err: errors.New("SlowDown")})
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+}
+
+func TestWriteErrorStorageTimeout(t *testing.T) {
+	rr := httptest.NewRecorder()
+	srv := New(&mockStore{}, zaptest.NewLogger(t), metrics.New(), "", "")
+	srv.writeError(rr, "fetch object", storage.TimeoutError{Op: "GetObject", Timeout: time.Second})
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", rr.Code)
+	}
+}
+
 func TestMetricsIncrement(t *testing.T) {
 	m := metrics.New()
 	store := &mockStore{
@@ -303,6 +490,91 @@ func TestCatalogOK(t *testing.T) {
 	}
 }
 
+func TestCatalogFormats(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := &mockStore{
+		listResp: []storage.Entry{
+			{Name: "a.jar", Path: "releases/a.jar", Type: "file", Size: 42, LastModified: &modified, ETag: "abc"},
+			{Name: "b/", Path: "releases/b/", Type: "dir"},
+		},
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	get := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/catalog?path=releases"+query, nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("&format=csv", "")
+	want := "name,path,type,size,lastModified,storageClass,etag,sha1,source\n" +
+		"a.jar,releases/a.jar,file,42,2026-01-02T03:04:05Z,,abc,,local\n" +
+		"b/,releases/b/,dir,,,,,,local\n"
+	if rr.Code != http.StatusOK || rr.Body.String() != want || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv: %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = get("", "text/xml, application/json;q=0.5")
+	if rr.Header().Get("Content-Type") != "application/xml" ||
+		!strings.Contains(rr.Body.String(), "<entry>\n    <name>a.jar</name>\n    <path>releases/a.jar</path>\n    <type>file</type>\n    <size>42</size>") {
+		t.Fatalf("xml: %s", rr.Body.String())
+	}
+
+	if rr := get("", "*/*"); !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("default: %s", rr.Header().Get("Content-Type"))
+	}
+	if rr := get("&format=yaml", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestCatalogNextCursor(t *testing.T) {
+	store := &mockStore{
+		listResp:  []storage.Entry{{Name: "a.jar", Path: "releases/a.jar", Type: "file"}},
+		nextToken: "releases/a.jar",
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/catalog?path=releases&limit=1", nil))
+
+	if got := rr.Header().Get("X-Next-Cursor"); got != "releases/a.jar" {
+		t.Fatalf("expected next cursor header, got %q", got)
+	}
+}
+
+func TestCatalogHead(t *testing.T) {
+	store := &mockStore{
+		listResp:  []storage.Entry{{Name: "a.jar", Path: "releases/a.jar", Type: "file"}},
+		nextToken: "releases/a.jar",
+	}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	head := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodHead, target, nil))
+		return rr
+	}
+
+	rr := head("/api/v1/catalog?path=releases&limit=1&format=csv")
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 {
+		t.Fatalf("head: %d %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Next-Cursor") != "releases/a.jar" || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("head headers: %v", rr.Header())
+	}
+
+	store.listErr = errors.New("bucket unreachable")
+	if rr := head("/api/v1/catalog?path=releases"); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("head with failing storage: %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/catalog", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("post: %d %v", rr.Code, rr.Header())
+	}
+}
+
 func TestCatalogRootShowsGroupAndFiltersProxyCfg(t *testing.T) {
 	store := newListStore()
 	store.listByPrefix[""] = []storage.Entry{
@@ -375,6 +647,60 @@ func TestCatalogPackagesFiltersProxyCfg(t *testing.T) {
 	}
 }
 
+func TestCatalogEntryDetails(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><body><pre><a href="../">../</a>
+<a href="1.0/">1.0/</a>                                              2024-03-05 10:12         -
+<a href="lib-1.0.jar">lib-1.0.jar</a>                               2024-03-05 10:12:30     381765
+<a href="lib-1.0.jar.sha1">lib-1.0.jar.sha1</a>
+</pre></body></html>`))
+	}))
+	defer upstream.Close()
+
+	ctx := context.Background()
+	store := newMemStore()
+	srv := NewWithOptions(store, zaptest.NewLogger(t), nil, Options{})
+	if err := srv.proxy.Add(ctx, Proxy{Name: "central", URL: upstream.URL}); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := "0123456789abcdef0123456789abcdef01234567  lib-1.0.jar\n"
+	_ = store.Put(ctx, "central/acme/lib-1.0.jar.sha1", strings.NewReader(sidecar), "text/plain", int64(len(sidecar)))
+	_ = store.Put(ctx, "releases/acme/app.jar", strings.NewReader("JAR"), "application/java-archive", 3)
+
+	get := func(query string) map[string]storage.Entry {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/catalog?"+query, nil))
+		var entries []storage.Entry
+		if err := json.NewDecoder(rr.Body).Decode(&entries); err != nil {
+			t.Fatalf("decode %s: %v", query, err)
+		}
+		out := map[string]storage.Entry{}
+		for _, e := range entries {
+			out[e.Name] = e
+		}
+		return out
+	}
+
+	entries := get("path=central/acme&checksums=true")
+	jar := entries["lib-1.0.jar"]
+	if jar.Size != 381765 || jar.LastModified == nil || !jar.LastModified.Equal(time.Date(2024, 3, 5, 10, 12, 30, 0, time.UTC)) {
+		t.Errorf("upstream details: %+v", jar)
+	}
+	if jar.Source != "central" || jar.SHA1 != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("source and sha1: %+v", jar)
+	}
+	if cached := entries["lib-1.0.jar.sha1"]; cached.Size != int64(len(sidecar)) || cached.Source != "central" {
+		t.Errorf("cached details: %+v", cached)
+	}
+	if dir := entries["1.0/"]; dir.Type != "dir" || dir.Size != 0 {
+		t.Errorf("dir: %+v", dir)
+	}
+
+	if app := get("path=releases/acme")["app.jar"]; app.Source != "local" || app.Size != 3 || app.SHA1 != "" {
+		t.Errorf("local entry: %+v", app)
+	}
+}
+
 func TestPackagesGetLocal(t *testing.T) {
 	store := newListStore()
 	store.objects["com/acme/app/1.0/app-1.0.jar"] = []byte("LOCAL")
@@ -440,3 +766,219 @@ func TestPackagesHeadLocal(t *testing.T) {
 		t.Fatalf("expected content-length header")
 	}
 }
+
+func TestInvalidateProxyRoute(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	if err := srv.proxy.Add(context.Background(), Proxy{Name: "central", URL: "https://repo.maven.apache.org/maven2"}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	store.data["central/com/acme/app/1.0/app-1.0.jar"] = memObj{body: []byte("x")}
+
+	req := httptest.NewRequest(http.MethodPost, "/proxies/central/invalidate", strings.NewReader(`{"path":"com/acme/app/1.0/app-1.0.jar"}`))
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res InvalidateResult
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(res.Deleted) != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/proxies/central/invalidate", nil)
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestPassthroughProxyDoesNotCache(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/big/dist/1.0/dist-1.0.zip" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		_, _ = w.Write([]byte("ZIPDATA"))
+	}))
+	defer remote.Close()
+
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	noCache := false
+	if err := srv.proxy.Add(context.Background(), Proxy{Name: "dist", URL: remote.URL, Cache: &noCache}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+
+	for _, target := range []string{"/dist/big/dist/1.0/dist-1.0.zip", "/packages/big/dist/1.0/dist-1.0.zip"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || rr.Body.String() != "ZIPDATA" {
+			t.Fatalf("%s: unexpected response %d %q", target, rr.Code, rr.Body.String())
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/zip" {
+			t.Fatalf("%s: unexpected content-type %s", target, ct)
+		}
+	}
+	for k := range store.data {
+		if strings.HasPrefix(k, "dist/") {
+			t.Fatalf("pass-through proxy stored %s", k)
+		}
+	}
+}
+
+func TestBlockedArtifactForbidden(t *testing.T) {
+	bl, err := ParseBlockList("org.apache.logging.log4j:log4j-core:(,2.17.0)")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	store := &mockStore{
+		getResp:  &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("jar"))},
+		listResp: []storage.Entry{{Name: "releases/", Type: "dir"}},
+	}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{BlockList: bl})
+
+	for _, target := range []string{
+		"/releases/org/apache/logging/log4j/log4j-core/2.14.1/log4j-core-2.14.1.jar",
+		"/packages/org/apache/logging/log4j/log4j-core/2.14.1/log4j-core-2.14.1.jar",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d", target, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), "log4j-core") {
+			t.Fatalf("%s: expected explanatory body, got %q", target, rr.Body.String())
+		}
+	}
+}
+
+func TestCacheStatsEndpoint(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("JARDATA"))
+	}))
+	defer remote.Close()
+
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	if err := srv.proxy.Add(context.Background(), Proxy{Name: "central", URL: remote.URL}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	h := srv.Handler()
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/central/com/acme/app/1.0/app-1.0.jar", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("download %d: status %d", i, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stats/cache", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var report CacheStatsReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(report.Proxies) != 1 {
+		t.Fatalf("expected 1 proxy, got %+v", report.Proxies)
+	}
+	st := report.Proxies[0]
+	if st.Hits != 2 || st.Misses != 1 || st.BytesFromUpstream != 7 || st.BytesSaved != 14 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestSlowRequestLoggedAtWarn(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceUpstream(r.Context(), "central")
+		time.Sleep(5 * time.Millisecond)
+		_, _ = w.Write([]byte("payload"))
+	})
+	h := loggingMiddleware(zap.New(core), time.Millisecond, nil, next)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/central/app.jar", nil))
+
+	entries := logs.FilterMessage("slow request").All()
+	if len(entries) != 1 || entries[0].Level != zapcore.WarnLevel {
+		t.Fatalf("expected one slow request warning, got %+v", logs.All())
+	}
+	ctx := entries[0].ContextMap()
+	if ctx["bytes"] != int64(7) || ctx["key"] != "central/app.jar" {
+		t.Fatalf("unexpected fields %v", ctx)
+	}
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	srv := New(&mockStore{}, zaptest.NewLogger(t), metrics.New(), "", "")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var info struct {
+		Version   string `json:"version"`
+		GoVersion string `json:"goVersion"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil || info.Version == "" || info.GoVersion == "" {
+		t.Fatalf("unexpected body %s (%v)", rr.Body.String(), err)
+	}
+}
+
+func TestPrincipalLoggedAndCounted(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	m := metrics.New()
+	srv := New(newMemStore(), zap.New(core), m, "admin", "secret")
+	h := srv.Handler()
+
+	req := httptest.NewRequest(http.MethodPut, "/releases/app/1.0/app-1.0.jar", strings.NewReader("payload"))
+	req.SetBasicAuth("admin", "secret")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest(http.MethodGet, "/releases/app/1.0/app-1.0.jar", nil)
+	req.SetBasicAuth("admin", "secret")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/releases/app/1.0/app-1.0.jar", nil))
+
+	entries := logs.FilterMessage("request").All()
+	if len(entries) != 3 || entries[0].ContextMap()["principal"] != "admin" {
+		t.Fatalf("expected the principal in the access log, got %+v", entries)
+	}
+	if _, ok := entries[2].ContextMap()["principal"]; ok {
+		t.Fatalf("unauthenticated request logged with a principal: %v", entries[2].ContextMap())
+	}
+	if got := testutil.ToFloat64(m.PrincipalRequests.WithLabelValues("admin")); got != 2 {
+		t.Fatalf("admin requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.PrincipalRequests.WithLabelValues(metrics.PrincipalAnonymous)); got != 1 {
+		t.Fatalf("anonymous requests = %v, want 1", got)
+	}
+	if in, out := testutil.ToFloat64(m.PrincipalBytes.WithLabelValues("admin", "in")), testutil.ToFloat64(m.PrincipalBytes.WithLabelValues("admin", "out")); in != 7 || out < 7 {
+		t.Fatalf("admin bytes in=%v out=%v", in, out)
+	}
+}
+
+func TestTelemetrySamplingExcludesChecksumHeads(t *testing.T) {
+	sampling, err := NewTelemetrySampling(1, "HEAD:**/*.sha1")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	core, logs := observer.New(zapcore.InfoLevel)
+	h := loggingMiddleware(zap.New(core), 0, sampling, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/releases/app/1.0/app-1.0.jar.sha1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/releases/app/1.0/app-1.0.jar.sha1", nil))
+	if n := logs.Len(); n != 1 {
+		t.Fatalf("expected only the GET to be logged, got %d entries", n)
+	}
+}
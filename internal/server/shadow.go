@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// shadowQueueSize bounds the replays waiting for a worker.
+	shadowQueueSize = 1000
+	// shadowTimeout bounds a replay, including reading the response.
+	shadowTimeout = 5 * time.Minute
+)
+
+// shadowHeaders are copied from the original request. Credentials are
+// passed on, so the secondary must accept the same users and tokens.
+var shadowHeaders = []string{"Authorization", "Accept", "Range", "If-None-Match", "If-Modified-Since", "User-Agent"}
+
+// Shadow replays a sample of read requests (GET and HEAD) to a secondary
+// instance, e.g. an upgraded release or one reading a migrated bucket, to
+// try it under real load before switching. Replays run in the background
+// and their responses are read and discarded; when the secondary falls
+// behind, replays are dropped rather than slowing down clients.
+type Shadow struct {
+	target  *url.URL
+	ratio   float64
+	workers int
+	client  *http.Client
+	logger  *zap.Logger
+	metrics *metrics.Registry
+	queue   chan *http.Request
+}
+
+// NewShadow replays ratio (in [0, 1]) of read requests to target with
+// workers concurrent requests (default 4).
+func NewShadow(target string, ratio float64, workers int, logger *zap.Logger) (*Shadow, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("shadow URL must be an absolute http(s) URL")
+	}
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("shadow sample ratio must be between 0 and 1")
+	}
+	if workers <= 0 {
+		workers = 4
+	}
+	return &Shadow{
+		target:  u,
+		ratio:   ratio,
+		workers: workers,
+		client:  &http.Client{Timeout: shadowTimeout},
+		logger:  logger,
+		queue:   make(chan *http.Request, shadowQueueSize),
+	}, nil
+}
+
+// middleware queues a replay of sampled read requests before serving them.
+func (sh *Shadow) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sh.submit(r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (sh *Shadow) submit(r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return
+	}
+	if r.URL.Path == "/healthz" || r.URL.Path == "/livez" {
+		return
+	}
+	if sh.ratio < 1 && rand.Float64() >= sh.ratio {
+		return
+	}
+	u := sh.target.JoinPath(r.URL.EscapedPath())
+	u.RawQuery = r.URL.RawQuery
+	req, err := http.NewRequest(r.Method, u.String(), nil)
+	if err != nil {
+		return
+	}
+	for _, h := range shadowHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	if id, _ := r.Context().Value(requestIDKey{}).(string); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	// Lets the secondary tell replays apart, e.g. in its access log.
+	req.Header.Set("X-Heimdall-Shadow", "1")
+
+	select {
+	case sh.queue <- req:
+	default:
+		sh.count("dropped")
+	}
+}
+
+// Run replays queued requests until ctx is done.
+func (sh *Shadow) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for range sh.workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case req := <-sh.queue:
+					sh.replay(req.WithContext(ctx))
+				}
+			}
+		}()
+	}
+	for range sh.workers {
+		<-done
+	}
+}
+
+func (sh *Shadow) replay(req *http.Request) {
+	resp, err := sh.client.Do(req)
+	if err != nil {
+		sh.logger.Debug("shadow request failed", zap.String("method", req.Method), zap.String("path", req.URL.Path), zap.Error(err))
+		sh.count("error")
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	sh.count(strconv.Itoa(resp.StatusCode/100) + "xx")
+}
+
+func (sh *Shadow) count(result string) {
+	if sh.metrics != nil {
+		sh.metrics.ShadowRequests.WithLabelValues(result).Inc()
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestShadowReplaysReads(t *testing.T) {
+	type replay struct {
+		method, uri, auth, shadow string
+	}
+	replays := make(chan replay, 10)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replays <- replay{r.Method, r.URL.RequestURI(), r.Header.Get("Authorization"), r.Header.Get("X-Heimdall-Shadow")}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer secondary.Close()
+
+	shadow, err := NewShadow(secondary.URL+"/staging", 1, 1, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := metrics.New()
+	srv := NewWithOptions(newMemStore(), zaptest.NewLogger(t), m, Options{Shadow: shadow})
+	go shadow.Run(t.Context())
+
+	do := func(method, target string) {
+		req := httptest.NewRequest(method, target, strings.NewReader("data"))
+		req.SetBasicAuth("ci", "secret")
+		srv.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	}
+	do(http.MethodPut, "/releases/com/acme/app/1.0/app-1.0.jar")
+	do(http.MethodGet, "/healthz")
+	do(http.MethodGet, "/releases/com/acme/app/1.0/app-1.0.jar?x=1")
+	do(http.MethodHead, "/releases/com/acme/app/")
+
+	want := []replay{
+		{http.MethodGet, "/staging/releases/com/acme/app/1.0/app-1.0.jar?x=1", "Basic Y2k6c2VjcmV0", "1"},
+		{http.MethodHead, "/staging/releases/com/acme/app/", "Basic Y2k6c2VjcmV0", "1"},
+	}
+	for _, w := range want {
+		select {
+		case got := <-replays:
+			if got != w {
+				t.Errorf("replay: got %+v, want %+v", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no replay of %s %s", w.method, w.uri)
+		}
+	}
+	select {
+	case got := <-replays:
+		t.Errorf("unexpected replay %+v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(m.ShadowRequests.WithLabelValues("4xx")) != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(m.ShadowRequests.WithLabelValues("4xx")); got != 2 {
+		t.Errorf("4xx replays counted: %v", got)
+	}
+}
+
+func TestNewShadowValidates(t *testing.T) {
+	for _, tc := range []struct {
+		url   string
+		ratio float64
+	}{
+		{"staging:8080", 1},
+		{"ftp://staging", 1},
+		{"http://staging", 1.5},
+	} {
+		if _, err := NewShadow(tc.url, tc.ratio, 0, zaptest.NewLogger(t)); err == nil {
+			t.Errorf("NewShadow(%q, %v): expected error", tc.url, tc.ratio)
+		}
+	}
+}
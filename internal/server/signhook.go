@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// signingMaxResponse bounds the JSON answer of a signing service; the
+	// signed artifact is base64 encoded in it.
+	signingMaxResponse = 1 << 30
+	// signedAtProperty marks artifacts whose stored content and signatures
+	// come from the signing service.
+	signedAtProperty = "signing.signedAt"
+)
+
+// signingSidecars are the files clients upload next to an artifact. After
+// the service signed the artifact they describe the unsigned upload, so
+// they are not stored.
+var signingSidecars = []string{".sha1", ".md5", ".sha256", ".sha512", ".asc"}
+
+var signatureExt = regexp.MustCompile(`^\.[a-z0-9]+(\.[a-z0-9]+)*$`)
+
+// SigningHook sends uploads to an external signing service before they are
+// stored. The service receives the artifact as the request body with
+// X-Heimdall-Path and X-Heimdall-User headers and answers:
+//
+//   - 200 with {"artifact": base64, "signatures": {".asc": base64}}; both
+//     fields are optional, so a service may only add detached signatures;
+//   - 204 to store the upload unchanged;
+//   - 4xx to reject the upload, with the reason as the body.
+type SigningHook struct {
+	URL      string
+	FailOpen bool
+
+	patterns []*regexp.Regexp
+	client   *http.Client
+}
+
+// SignedArtifact is a signing service's answer. A nil Artifact keeps the
+// uploaded content.
+type SignedArtifact struct {
+	Artifact   []byte            `json:"artifact"`
+	Signatures map[string][]byte `json:"signatures"`
+}
+
+// SigningRejectedError is returned when the service refuses an artifact.
+type SigningRejectedError struct {
+	Reason string
+}
+
+func (e SigningRejectedError) Error() string {
+	return "signing rejected: " + e.Reason
+}
+
+// NewSigningHook signs uploads whose path matches one of the globs, where
+// "**" spans directories (e.g. "releases/**/*.jar").
+func NewSigningHook(url string, paths []string, timeout time.Duration, failOpen bool) (*SigningHook, error) {
+	h := &SigningHook{URL: url, FailOpen: failOpen, client: &http.Client{Timeout: timeout}}
+	for _, p := range paths {
+		re, err := globToRegexp(strings.TrimPrefix(p, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("signing path %q: %w", p, err)
+		}
+		h.patterns = append(h.patterns, re)
+	}
+	return h, nil
+}
+
+// Matches reports whether uploads to key are signed.
+func (h *SigningHook) Matches(key string) bool {
+	for _, re := range h.patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign sends body to the service. It returns nil when the service keeps
+// the upload as it is.
+func (h *SigningHook) Sign(ctx context.Context, key, user string, body io.Reader, size int64) (*SignedArtifact, error) {
+	// The transport closes the request body; the caller still needs it.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, io.NopCloser(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Heimdall-Path", key)
+	if user != "" {
+		req.Header.Set("X-Heimdall-User", user)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("signing service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, SigningRejectedError{Reason: strings.TrimSpace(string(reason))}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("signing service: status %d", resp.StatusCode)
+	}
+	var signed SignedArtifact
+	if err := json.NewDecoder(io.LimitReader(resp.Body, signingMaxResponse)).Decode(&signed); err != nil {
+		return nil, fmt.Errorf("signing service: decode response: %w", err)
+	}
+	for ext := range signed.Signatures {
+		if !signatureExt.MatchString(ext) || isChecksumPath(ext) {
+			return nil, fmt.Errorf("signing service: invalid signature extension %q", ext)
+		}
+	}
+	return &signed, nil
+}
+
+// signedSidecar reports whether key is a checksum or signature a client
+// uploads next to an artifact the signing service has signed.
+func (s *Server) signedSidecar(ctx context.Context, key string) bool {
+	if s.signingHook == nil {
+		return false
+	}
+	ext := path.Ext(key)
+	found := false
+	for _, e := range signingSidecars {
+		found = found || strings.EqualFold(ext, e)
+	}
+	artifact := strings.TrimSuffix(key, ext)
+	if !found || !s.signingHook.Matches(artifact) {
+		return false
+	}
+	props, err := loadProperties(ctx, s.store, artifact)
+	return err == nil && props[signedAtProperty] != ""
+}
+
+// storeSignatures writes the detached signatures of key with their own
+// checksums and marks key as signed.
+func (s *Server) storeSignatures(ctx context.Context, key string, signed *SignedArtifact) error {
+	for ext, sig := range signed.Signatures {
+		if err := s.putWithChecksums(ctx, key+ext, "application/octet-stream", sig); err != nil {
+			return err
+		}
+	}
+	return setProperties(ctx, s.store, key, map[string]string{signedAtProperty: time.Now().UTC().Format(time.RFC3339)})
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func newSigningServer(t *testing.T, store Storage, url string, failOpen bool) *Server {
+	t.Helper()
+	hook, err := NewSigningHook(url, []string{"releases/**/*.jar"}, time.Second, failOpen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{SigningHook: hook})
+}
+
+func putArtifact(t *testing.T, srv *Server, key, body string) int {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/"+key, strings.NewReader(body)))
+	return rr.Code
+}
+
+func TestSigningHookReplacesArtifact(t *testing.T) {
+	var calls atomic.Int32
+	signer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if got := r.Header.Get("X-Heimdall-Path"); got != "releases/com/acme/lib/1.0/lib-1.0.jar" {
+			t.Errorf("X-Heimdall-Path = %q", got)
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.NewEncoder(w).Encode(SignedArtifact{
+			Artifact:   append(body, " signed"...),
+			Signatures: map[string][]byte{".asc": []byte("signature")},
+		})
+	}))
+	defer signer.Close()
+	store := newMemStore()
+	srv := newSigningServer(t, store, signer.URL, false)
+
+	key := "releases/com/acme/lib/1.0/lib-1.0.jar"
+	if code := putArtifact(t, srv, key, "jar"); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if got := string(store.data[key].body); got != "jar signed" {
+		t.Fatalf("stored %q, want the signed artifact", got)
+	}
+	if got := string(store.data[key+".sha1"].body); got != sha1Hex("jar signed") {
+		t.Fatalf("sha1 %q does not describe the signed artifact", got)
+	}
+	if got := string(store.data[key+".asc"].body); got != "signature" {
+		t.Fatalf("asc = %q", got)
+	}
+	if got := string(store.data[key+".asc.sha1"].body); got != sha1Hex("signature") {
+		t.Fatalf("asc.sha1 = %q", got)
+	}
+
+	// Maven uploads checksums and a signature of the unsigned jar next.
+	if code := putArtifact(t, srv, key+".sha1", sha1Hex("jar")); code != http.StatusCreated {
+		t.Fatalf("sidecar upload: expected 201, got %d", code)
+	}
+	if code := putArtifact(t, srv, key+".asc", "client signature"); code != http.StatusCreated {
+		t.Fatalf("sidecar upload: expected 201, got %d", code)
+	}
+	if got := string(store.data[key+".sha1"].body); got != sha1Hex("jar signed") {
+		t.Fatalf("client sha1 overwrote the signed one: %q", got)
+	}
+	if got := string(store.data[key+".asc"].body); got != "signature" {
+		t.Fatalf("client signature overwrote the service's: %q", got)
+	}
+
+	// Paths outside SIGNING_PATHS are not sent.
+	if code := putArtifact(t, srv, "releases/com/acme/lib/1.0/lib-1.0.pom", "pom"); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("signing service called %d times, want 1", n)
+	}
+}
+
+func TestSigningHookNoContentKeepsUpload(t *testing.T) {
+	signer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer signer.Close()
+	store := newMemStore()
+	srv := newSigningServer(t, store, signer.URL, false)
+
+	key := "releases/a/1.0/a-1.0.jar"
+	if code := putArtifact(t, srv, key, "jar"); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if got := string(store.data[key].body); got != "jar" {
+		t.Fatalf("stored %q", got)
+	}
+	// Not signed, so the client's signature is kept.
+	if code := putArtifact(t, srv, key+".asc", "client signature"); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if got := string(store.data[key+".asc"].body); got != "client signature" {
+		t.Fatalf("asc = %q", got)
+	}
+}
+
+func TestSigningHookRejects(t *testing.T) {
+	signer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown publisher", http.StatusForbidden)
+	}))
+	defer signer.Close()
+	store := newMemStore()
+	srv := newSigningServer(t, store, signer.URL, false)
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/releases/a/1.0/a-1.0.jar", strings.NewReader("jar")))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "unknown publisher") {
+		t.Fatalf("reason missing from %q", rr.Body.String())
+	}
+	if len(store.data) != 0 {
+		t.Fatalf("rejected upload stored: %v", store.data)
+	}
+}
+
+func TestSigningHookUnavailable(t *testing.T) {
+	store := newMemStore()
+	srv := newSigningServer(t, store, "http://127.0.0.1:1", false)
+	if code := putArtifact(t, srv, "releases/a/1.0/a-1.0.jar", "jar"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", code)
+	}
+	if len(store.data) != 0 {
+		t.Fatalf("upload stored while the signing service is down: %v", store.data)
+	}
+
+	srv = newSigningServer(t, store, "http://127.0.0.1:1", true)
+	if code := putArtifact(t, srv, "releases/a/1.0/a-1.0.jar", "jar"); code != http.StatusCreated {
+		t.Fatalf("fail open: expected 201, got %d", code)
+	}
+	if got := string(store.data["releases/a/1.0/a-1.0.jar"].body); got != "jar" {
+		t.Fatalf("stored %q", got)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSignedURLAllowsAnonymousDownload(t *testing.T) {
+	store := newMemStore()
+	if err := store.Put(t.Context(), "releases/app/1.0/app-1.0.jar", strings.NewReader("jar"), "application/java-archive", 3); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{AuthUser: "admin", AuthPassword: "secret", SigningKey: []byte("k")})
+	h := srv.Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sign", strings.NewReader(`{"path":"releases/app/1.0/app-1.0.jar","expiresIn":"10m"}`))
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var signed SignResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &signed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	u, err := url.Parse(signed.URL)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "jar" {
+		t.Fatalf("expected signed download to succeed, got %d", rr.Code)
+	}
+
+	tampered := strings.Replace(u.RequestURI(), "app-1.0.jar", "app-1.0.pom", 1)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tampered, nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected tampered URL to require auth, got %d", rr.Code)
+	}
+}
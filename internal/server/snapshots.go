@@ -0,0 +1,293 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// snapshotBuildRe matches the timestamp and build number Maven puts in place
+// of SNAPSHOT in unique snapshot file names.
+var snapshotBuildRe = regexp.MustCompile(`^(\d{8}\.\d{6})-(\d+)`)
+
+var snapshotWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// SnapshotPruning keeps the newest Keep timestamped builds of every snapshot
+// version in hosted repositories. Each pruned build is logged and, when
+// WebhookURL is set, POSTed as a SnapshotPrunedEvent.
+type SnapshotPruning struct {
+	Keep       int
+	WebhookURL string
+}
+
+type SnapshotPrunedEvent struct {
+	Event      string    `json:"event"`
+	Repository string    `json:"repository"`
+	GroupID    string    `json:"groupId"`
+	ArtifactID string    `json:"artifactId"`
+	Version    string    `json:"version"`
+	Build      string    `json:"build"`
+	Files      []string  `json:"files"`
+	PrunedAt   time.Time `json:"prunedAt"`
+}
+
+// snapshotMetadata is the version-level maven-metadata.xml of a snapshot.
+type snapshotMetadata struct {
+	XMLName      xml.Name           `xml:"metadata"`
+	ModelVersion string             `xml:"modelVersion,attr,omitempty"`
+	GroupID      string             `xml:"groupId"`
+	ArtifactID   string             `xml:"artifactId"`
+	Version      string             `xml:"version"`
+	Versioning   snapshotVersioning `xml:"versioning"`
+}
+
+type snapshotVersioning struct {
+	Snapshot struct {
+		Timestamp   string `xml:"timestamp"`
+		BuildNumber int    `xml:"buildNumber"`
+	} `xml:"snapshot"`
+	LastUpdated      string            `xml:"lastUpdated"`
+	SnapshotVersions []snapshotVersion `xml:"snapshotVersions>snapshotVersion"`
+}
+
+type snapshotVersion struct {
+	Classifier string `xml:"classifier,omitempty"`
+	Extension  string `xml:"extension"`
+	Value      string `xml:"value"`
+	Updated    string `xml:"updated"`
+}
+
+// snapshotBuild groups the files of one timestamped deploy.
+type snapshotBuild struct {
+	timestamp string
+	number    int
+	files     []string
+}
+
+func (b snapshotBuild) value(base string) string {
+	return base + "-" + b.timestamp + "-" + strconv.Itoa(b.number)
+}
+
+type snapshotPruneResult struct {
+	Versions int
+	Builds   int
+	Files    int
+}
+
+// snapshotPruneTask prunes snapshots once; it is the "snapshot-prune" task.
+func (s *Server) snapshotPruneTask(ctx context.Context, cfg SnapshotPruning) error {
+	res, err := s.pruneSnapshots(ctx, cfg, time.Now())
+	if err != nil {
+		return err
+	}
+	s.logger.Info("snapshot pruning finished", zap.Int("versions", res.Versions), zap.Int("builds", res.Builds), zap.Int("files", res.Files))
+	return nil
+}
+
+func (s *Server) pruneSnapshots(ctx context.Context, cfg SnapshotPruning, now time.Time) (snapshotPruneResult, error) {
+	var res snapshotPruneResult
+	if cfg.Keep <= 0 {
+		return res, nil
+	}
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		return res, err
+	}
+	proxied := make(map[string]bool, len(proxies))
+	for _, pr := range proxies {
+		proxied[pr.Name] = true
+	}
+
+	roots, err := s.store.List(ctx, "", 1000)
+	if err != nil {
+		return res, err
+	}
+	for _, root := range roots {
+		repo := strings.TrimSuffix(root.Name, "/")
+		if root.Type != "dir" || isInternalPath(repo) || proxied[repo] {
+			continue
+		}
+
+		versionDirs := make(map[string][]string)
+		err := walkStore(ctx, s.store, repo, func(e storage.Entry) error {
+			if dir := path.Dir(e.Path); strings.HasSuffix(dir, "-SNAPSHOT") {
+				versionDirs[dir] = append(versionDirs[dir], e.Path)
+			}
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+
+		dirs := make([]string, 0, len(versionDirs))
+		for dir := range versionDirs {
+			dirs = append(dirs, dir)
+		}
+		slices.Sort(dirs)
+		for _, dir := range dirs {
+			builds, files, err := s.pruneSnapshotVersion(ctx, cfg, repo, dir, versionDirs[dir], now)
+			if err != nil {
+				return res, err
+			}
+			if builds > 0 {
+				res.Versions++
+				res.Builds += builds
+				res.Files += files
+			}
+		}
+	}
+	return res, nil
+}
+
+// pruneSnapshotVersion rewrites the version metadata to reference only the
+// kept builds before deleting the others, so clients never resolve a pruned
+// timestamp.
+func (s *Server) pruneSnapshotVersion(ctx context.Context, cfg SnapshotPruning, repo, dir string, files []string, now time.Time) (int, int, error) {
+	version := path.Base(dir)
+	artifactDir := path.Dir(dir)
+	artifact := path.Base(artifactDir)
+	base := strings.TrimSuffix(version, "-SNAPSHOT")
+	prefix := artifact + "-" + base + "-"
+
+	byValue := make(map[string]*snapshotBuild)
+	for _, key := range files {
+		name := path.Base(key)
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		m := snapshotBuildRe.FindStringSubmatch(strings.TrimPrefix(name, prefix))
+		if m == nil {
+			continue
+		}
+		b, ok := byValue[m[0]]
+		if !ok {
+			n, _ := strconv.Atoi(m[2])
+			b = &snapshotBuild{timestamp: m[1], number: n}
+			byValue[m[0]] = b
+		}
+		b.files = append(b.files, key)
+	}
+	if len(byValue) <= cfg.Keep {
+		return 0, 0, nil
+	}
+
+	builds := make([]*snapshotBuild, 0, len(byValue))
+	for _, b := range byValue {
+		builds = append(builds, b)
+	}
+	slices.SortFunc(builds, func(a, b *snapshotBuild) int {
+		return cmp.Or(strings.Compare(a.timestamp, b.timestamp), cmp.Compare(a.number, b.number))
+	})
+	pruned, kept := builds[:len(builds)-cfg.Keep], builds[len(builds)-cfg.Keep:]
+
+	metaKey := dir + "/" + mavenMetadataFile
+	unlock, err := s.lockMetadata(ctx, metaKey)
+	if err != nil {
+		return 0, 0, err
+	}
+	meta := snapshotMetadata{
+		ModelVersion: "1.1.0",
+		GroupID:      s.snapshotGroupID(ctx, metaKey, artifactDir),
+		ArtifactID:   artifact,
+		Version:      version,
+	}
+	latest := kept[len(kept)-1]
+	meta.Versioning.Snapshot.Timestamp = latest.timestamp
+	meta.Versioning.Snapshot.BuildNumber = latest.number
+	meta.Versioning.LastUpdated = now.UTC().Format("20060102150405")
+	for _, b := range kept {
+		value := b.value(base)
+		for _, key := range b.files {
+			name := path.Base(key)
+			if isChecksumPath(name) {
+				continue
+			}
+			rest := strings.TrimPrefix(name, artifact+"-"+value)
+			var classifier string
+			if strings.HasPrefix(rest, "-") {
+				classifier, rest, _ = strings.Cut(rest[1:], ".")
+				rest = "." + rest
+			}
+			meta.Versioning.SnapshotVersions = append(meta.Versioning.SnapshotVersions, snapshotVersion{
+				Classifier: classifier,
+				Extension:  strings.TrimPrefix(rest, "."),
+				Value:      value,
+				Updated:    strings.Replace(b.timestamp, ".", "", 1),
+			})
+		}
+	}
+	slices.SortFunc(meta.Versioning.SnapshotVersions, func(a, b snapshotVersion) int {
+		return cmp.Or(strings.Compare(a.Value, b.Value), strings.Compare(a.Classifier, b.Classifier), strings.Compare(a.Extension, b.Extension))
+	})
+
+	body, err := xml.MarshalIndent(meta, "", "  ")
+	if err == nil {
+		err = s.putXML(ctx, metaKey, body)
+	}
+	unlock()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	deleted := 0
+	for _, b := range pruned {
+		for _, key := range b.files {
+			if err := s.store.Delete(ctx, key); err != nil && !storage.IsNotFound(err) {
+				return len(pruned), deleted, err
+			}
+			deleted++
+		}
+		s.snapshotPruned(ctx, cfg, SnapshotPrunedEvent{
+			Event:      "snapshot.pruned",
+			Repository: repo,
+			GroupID:    meta.GroupID,
+			ArtifactID: artifact,
+			Version:    version,
+			Build:      b.value(base),
+			Files:      b.files,
+			PrunedAt:   now.UTC(),
+		})
+	}
+	return len(pruned), deleted, nil
+}
+
+// snapshotGroupID prefers the current version metadata, then the artifact
+// metadata, then the directory layout.
+func (s *Server) snapshotGroupID(ctx context.Context, metaKey, artifactDir string) string {
+	if resp, err := s.store.Get(ctx, metaKey); err == nil {
+		var meta snapshotMetadata
+		err := xml.NewDecoder(resp.Body).Decode(&meta)
+		resp.Body.Close()
+		if err == nil && meta.GroupID != "" {
+			return meta.GroupID
+		}
+	}
+	return s.metadataGroupID(ctx, artifactDir, "")
+}
+
+func (s *Server) snapshotPruned(ctx context.Context, cfg SnapshotPruning, ev SnapshotPrunedEvent) {
+	s.logger.Info("snapshot build pruned",
+		zap.String("event", ev.Event),
+		zap.String("repository", ev.Repository),
+		zap.String("groupId", ev.GroupID),
+		zap.String("artifactId", ev.ArtifactID),
+		zap.String("build", ev.Build),
+		zap.Int("files", len(ev.Files)),
+	)
+	if cfg.WebhookURL == "" {
+		return
+	}
+	if err := postJSON(ctx, snapshotWebhookClient, cfg.WebhookURL, nil, ev); err != nil {
+		s.logger.Warn("snapshot pruning webhook", zap.String("build", ev.Build), zap.Error(err))
+	}
+}
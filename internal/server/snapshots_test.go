@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []SnapshotPrunedEvent
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev SnapshotPrunedEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	ctx := context.Background()
+	store := newMemStore()
+	dir := "snapshots/com/acme/app/1.0-SNAPSHOT/"
+	files := []string{
+		"app-1.0-20240101.100000-1.jar",
+		"app-1.0-20240101.100000-1.jar.sha1",
+		"app-1.0-20240101.100000-1.pom",
+		"app-1.0-20240102.100000-2.jar",
+		"app-1.0-20240102.100000-2.pom",
+		"app-1.0-20240103.100000-3.jar",
+		"app-1.0-20240103.100000-3-sources.jar",
+		"app-1.0-20240103.100000-3.pom",
+	}
+	for _, name := range files {
+		if err := store.Put(ctx, dir+name, strings.NewReader("x"), "application/octet-stream", 1); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	meta := `<metadata><groupId>com.acme</groupId><artifactId>app</artifactId><version>1.0-SNAPSHOT</version></metadata>`
+	if err := store.Put(ctx, dir+"maven-metadata.xml", strings.NewReader(meta), "application/xml", int64(len(meta))); err != nil {
+		t.Fatalf("put metadata: %v", err)
+	}
+
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	res, err := srv.pruneSnapshots(ctx, SnapshotPruning{Keep: 2, WebhookURL: hook.URL}, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if res.Versions != 1 || res.Builds != 1 || res.Files != 3 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	for _, name := range files[:3] {
+		if _, ok := store.data[dir+name]; ok {
+			t.Fatalf("%s should have been pruned", name)
+		}
+	}
+	for _, name := range files[3:] {
+		if _, ok := store.data[dir+name]; !ok {
+			t.Fatalf("%s should have been kept", name)
+		}
+	}
+
+	var got snapshotMetadata
+	if err := xml.Unmarshal(store.data[dir+"maven-metadata.xml"].body, &got); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if got.GroupID != "com.acme" || got.Versioning.Snapshot.Timestamp != "20240103.100000" || got.Versioning.Snapshot.BuildNumber != 3 {
+		t.Fatalf("unexpected snapshot metadata %+v", got)
+	}
+	if len(got.Versioning.SnapshotVersions) != 5 {
+		t.Fatalf("expected 5 snapshot versions, got %+v", got.Versioning.SnapshotVersions)
+	}
+	for _, sv := range got.Versioning.SnapshotVersions {
+		if strings.Contains(sv.Value, "20240101") {
+			t.Fatalf("metadata still references pruned build: %+v", sv)
+		}
+		if sv.Classifier == "sources" && (sv.Extension != "jar" || sv.Value != "1.0-20240103.100000-3") {
+			t.Fatalf("unexpected classifier entry %+v", sv)
+		}
+	}
+	if _, ok := store.data[dir+"maven-metadata.xml.sha1"]; !ok {
+		t.Fatalf("metadata checksum not written")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0].Build != "1.0-20240101.100000-1" || len(events[0].Files) != 3 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// taskHistoryPrefix holds one JSON document per task with its recent runs,
+// so history and checkpoints survive restarts.
+const taskHistoryPrefix = "__tasks__/"
+
+// taskHistoryRuns is how many finished runs are kept per task.
+const taskHistoryRuns = 20
+
+// taskCheckpointInterval bounds how often checkpoints are written to the
+// bucket; the latest one is always saved when the run ends.
+const taskCheckpointInterval = 30 * time.Second
+
+// taskHistoryDoc is stored at __tasks__/<name>.json. Runs are newest first;
+// a run still in progress comes first with status running.
+type taskHistoryDoc struct {
+	Runs []TaskRun `json:"runs"`
+}
+
+type taskRunKey struct{}
+
+type taskRunHandle struct {
+	server *Server
+	task   *scheduledTask
+	run    *TaskRun
+	saved  time.Time
+}
+
+// taskCheckpoint records the progress of the task run in ctx, e.g. the last
+// key it finished. If the server stops before the run ends, the next run of
+// the task gets it from taskResumePoint. It is a no-op outside task runs.
+func taskCheckpoint(ctx context.Context, checkpoint string) {
+	h, ok := ctx.Value(taskRunKey{}).(*taskRunHandle)
+	if !ok {
+		return
+	}
+	s := h.server
+	s.tasksMu.Lock()
+	h.run.Checkpoint = checkpoint
+	due := time.Since(h.saved) >= taskCheckpointInterval
+	if due {
+		h.saved = time.Now()
+	}
+	s.tasksMu.Unlock()
+	if due {
+		s.saveTaskHistory(ctx, h.task)
+	}
+}
+
+// taskResumePoint returns the checkpoint of the interrupted run this run
+// continues, or "" to start from the beginning.
+func taskResumePoint(ctx context.Context) string {
+	h, ok := ctx.Value(taskRunKey{}).(*taskRunHandle)
+	if !ok {
+		return ""
+	}
+	return h.run.ResumedFrom
+}
+
+// taskRuns returns the run in progress, if any, followed by the finished
+// runs, newest first. Call it with tasksMu held.
+func (t *scheduledTask) taskRuns() []TaskRun {
+	runs := make([]TaskRun, 0, len(t.history)+1)
+	if t.running != nil {
+		runs = append(runs, *t.running)
+	}
+	return append(runs, t.history...)
+}
+
+// saveTaskHistory writes the current runs of t to the bucket. Failures are
+// only logged: history is informational and rewritten on the next change.
+func (s *Server) saveTaskHistory(ctx context.Context, t *scheduledTask) {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+	s.tasksMu.Lock()
+	doc := taskHistoryDoc{Runs: t.taskRuns()}
+	s.tasksMu.Unlock()
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		s.logger.Warn("encode task history", zap.String("task", t.Name), zap.Error(err))
+		return
+	}
+	// Saved even when the run was canceled.
+	ctx = context.WithoutCancel(ctx)
+	if err := s.store.Put(ctx, taskHistoryPrefix+t.Name+".json", bytes.NewReader(data), "application/json", int64(len(data))); err != nil {
+		s.logger.Warn("save task history", zap.String("task", t.Name), zap.Error(err))
+	}
+}
+
+// loadTaskHistory restores the persisted runs of every registered task. On
+// the replica running scheduled tasks, runs recorded as running were cut
+// short by a restart (or a change of leader) and become interrupted; the
+// next run resumes from their checkpoint. Other replicas only read.
+func (s *Server) loadTaskHistory(ctx context.Context) {
+	interrupt := s.isLeader()
+	s.tasksMu.Lock()
+	tasks := append([]*scheduledTask(nil), s.tasks...)
+	s.tasksMu.Unlock()
+
+	for _, t := range tasks {
+		resp, err := s.store.Get(ctx, taskHistoryPrefix+t.Name+".json")
+		if err != nil {
+			if !storage.IsNotFound(err) {
+				s.logger.Warn("load task history", zap.String("task", t.Name), zap.Error(err))
+			}
+			continue
+		}
+		var doc taskHistoryDoc
+		err = json.NewDecoder(resp.Body).Decode(&doc)
+		resp.Body.Close()
+		if err != nil {
+			s.logger.Warn("decode task history", zap.String("task", t.Name), zap.Error(err))
+			continue
+		}
+
+		interrupted := false
+		for i := range doc.Runs {
+			if interrupt && doc.Runs[i].Status == TaskRunning {
+				doc.Runs[i].Status = TaskInterrupted
+				interrupted = true
+				s.logger.Warn("task run interrupted by restart", zap.String("task", t.Name),
+					zap.String("run", doc.Runs[i].ID), zap.String("checkpoint", doc.Runs[i].Checkpoint))
+			}
+		}
+		if len(doc.Runs) > taskHistoryRuns {
+			doc.Runs = doc.Runs[:taskHistoryRuns]
+		}
+		s.tasksMu.Lock()
+		if t.running == nil {
+			t.history = doc.Runs
+		}
+		s.tasksMu.Unlock()
+		if interrupted {
+			s.saveTaskHistory(ctx, t)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func runTaskNow(t *testing.T, srv *Server, name string) TaskRun {
+	t.Helper()
+	srv.tasksMu.Lock()
+	task := srv.findTask(name)
+	srv.tasksMu.Unlock()
+	ctx, run, ok := srv.beginTaskRun(context.Background(), task, "manual")
+	if !ok {
+		t.Fatalf("%s already running", name)
+	}
+	srv.executeTaskRun(ctx, task, run)
+	return *run
+}
+
+func TestTaskHistoryPersisted(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	if err := srv.ScheduleTask(Task{Name: "noop", Run: func(ctx context.Context) error { return nil }}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	for range taskHistoryRuns + 5 {
+		runTaskNow(t, srv, "noop")
+	}
+	last := runTaskNow(t, srv, "noop")
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/admin/tasks/noop/runs", nil))
+	var runs []TaskRun
+	if err := json.Unmarshal(rr.Body.Bytes(), &runs); err != nil {
+		t.Fatalf("decode: %v: %s", err, rr.Body.String())
+	}
+	if len(runs) != taskHistoryRuns || runs[0].ID != last.ID || runs[0].Status != TaskSucceeded {
+		t.Fatalf("expected %d runs newest first, got %d (%+v)", taskHistoryRuns, len(runs), runs[0])
+	}
+
+	obj, ok := store.data[taskHistoryPrefix+"noop.json"]
+	if !ok {
+		t.Fatal("expected history in the bucket")
+	}
+	var doc taskHistoryDoc
+	if err := json.Unmarshal(obj.body, &doc); err != nil || len(doc.Runs) != taskHistoryRuns || doc.Runs[0].ID != last.ID {
+		t.Fatalf("unexpected stored history (%v): %s", err, obj.body)
+	}
+
+	// A new replica/process picks the history up.
+	restarted := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	if err := restarted.ScheduleTask(Task{Name: "noop", Run: func(ctx context.Context) error { return nil }}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	restarted.loadTaskHistory(context.Background())
+	if st := restarted.taskStatuses(); st[0].LastRun == nil || st[0].LastRun.ID != last.ID {
+		t.Fatalf("expected last run restored, got %+v", st)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/admin/tasks/nope/runs", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown task, got %d", rr.Code)
+	}
+}
+
+func TestTaskResumesAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	for key, body := range map[string]string{
+		"releases/a/1.0/a-1.0.jar": "a",
+		"releases/b/1.0/b-1.0.jar": "b",
+		"releases/c/1.0/c-1.0.jar": "c",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	doc := `{"runs":[{"id":"old","task":"integrity-check","trigger":"schedule","status":"running","startedAt":"2024-01-01T00:00:00Z","durationMs":0,"checkpoint":"releases/a/1.0/a-1.0.jar"}]}`
+	if err := store.Put(ctx, taskHistoryPrefix+"integrity-check.json", strings.NewReader(doc), "application/json", int64(len(doc))); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	if err := srv.ScheduleTask(srv.Tasks(TaskSettings{VerifyPrefix: "releases", VerifyETag: true})["integrity-check"]); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	srv.loadTaskHistory(ctx)
+	if st := srv.taskStatuses()[0]; st.LastRun == nil || st.LastRun.Status != TaskInterrupted {
+		t.Fatalf("expected interrupted run, got %+v", st.LastRun)
+	}
+
+	run := runTaskNow(t, srv, "integrity-check")
+	if run.Status != TaskSucceeded || run.ResumedFrom != "releases/a/1.0/a-1.0.jar" || run.Checkpoint != "releases/c/1.0/c-1.0.jar" {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if report := srv.integrityReport(); report.Objects != 2 || report.ResumedFrom != run.ResumedFrom {
+		t.Fatalf("expected only b and c verified, got %+v", report)
+	}
+
+	// The next run starts over.
+	if run := runTaskNow(t, srv, "integrity-check"); run.ResumedFrom != "" {
+		t.Fatalf("expected a fresh run, got %+v", run)
+	}
+	if report := srv.integrityReport(); report.Objects != 3 {
+		t.Fatalf("expected full run, got %+v", report)
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	errTaskUnknown = errors.New("unknown task")
+	errTaskRunning = errors.New("task is already running")
+)
+
+// TaskStatus describes a registered task. Enabled is false for tasks
+// without a schedule, which only run when triggered through the API.
+type TaskStatus struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule,omitempty"`
+	Enabled  bool       `json:"enabled"`
+	NextRun  *time.Time `json:"nextRun,omitempty"`
+	Running  *TaskRun   `json:"running,omitempty"`
+	LastRun  *TaskRun   `json:"lastRun,omitempty"`
+}
+
+// taskStatuses returns a snapshot of every registered task, sorted by name.
+func (s *Server) taskStatuses() []TaskStatus {
+	s.tasksMu.Lock()
+	defer s.tasksMu.Unlock()
+	out := make([]TaskStatus, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		st := TaskStatus{Name: t.Name, Schedule: t.Schedule, Enabled: t.schedule != nil}
+		if !t.next.IsZero() {
+			next := t.next
+			st.NextRun = &next
+		}
+		if t.running != nil {
+			run := *t.running
+			st.Running = &run
+		}
+		if last := t.last(); last != nil {
+			run := *last
+			st.LastRun = &run
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// taskHistory returns the run in progress of the task called name, if any,
+// followed by its finished runs.
+func (s *Server) taskHistory(name string) ([]TaskRun, error) {
+	s.tasksMu.Lock()
+	defer s.tasksMu.Unlock()
+	t := s.findTask(name)
+	if t == nil {
+		return nil, errTaskUnknown
+	}
+	return t.taskRuns(), nil
+}
+
+// startTask starts a manual run of the task called name in the background.
+// The run is detached from ctx so it outlives the call that started it.
+func (s *Server) startTask(ctx context.Context, name string) (TaskRun, error) {
+	s.tasksMu.Lock()
+	t := s.findTask(name)
+	s.tasksMu.Unlock()
+	if t == nil {
+		return TaskRun{}, errTaskUnknown
+	}
+	runCtx, run, ok := s.beginTaskRun(context.WithoutCancel(ctx), t, "manual")
+	if !ok {
+		return TaskRun{}, errTaskRunning
+	}
+	started := *run
+	go s.executeTaskRun(runCtx, t, run)
+	return started, nil
+}
+
+// cancelTaskRun cancels the running run with this ID and returns it.
+func (s *Server) cancelTaskRun(id string) (TaskRun, bool) {
+	s.tasksMu.Lock()
+	defer s.tasksMu.Unlock()
+	for _, t := range s.tasks {
+		if t.running != nil && t.running.ID == id {
+			t.cancel()
+			return *t.running, true
+		}
+	}
+	return TaskRun{}, false
+}
+
+func (s *Server) routeTaskAction(w http.ResponseWriter, r *http.Request) {
+	target, action, ok := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, apiV1+"/admin/tasks/"), "/"), "/")
+	if !ok || target == "" || (action != "run" && action != "cancel" && action != "runs") {
+		writeAPIError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if action == "runs" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleTaskRuns(w, r, target)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if action == "run" {
+		s.handleRunTask(w, r, target)
+		return
+	}
+	s.handleCancelTaskRun(w, r, target)
+}
+
+// @Summary List background tasks
+// @Description Every registered task with its schedule, next activation, the run in progress and the last finished run (status succeeded, failed, canceled or interrupted, duration and error).
+// @Tags admin
+// @Produce json
+// @Success 200 {array} TaskStatus
+// @Security BasicAuth
+// @Router /api/v1/admin/tasks [get]
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.taskStatuses()); err != nil {
+		s.logger.Warn("encode tasks", zap.Error(err))
+	}
+}
+
+// @Summary Task run history
+// @Description The run in progress, if any, followed by the last 20 finished runs of the task, newest first. History is kept in the bucket, so it survives restarts; runs cut short by a restart are listed as interrupted.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Task name"
+// @Success 200 {array} TaskRun
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/admin/tasks/{name}/runs [get]
+func (s *Server) handleTaskRuns(w http.ResponseWriter, r *http.Request, name string) {
+	runs, err := s.taskHistory(name)
+	if err != nil {
+		writeAPIError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		s.logger.Warn("encode task runs", zap.Error(err))
+	}
+}
+
+// @Summary Run a task now
+// @Description Starts a run of the task in the background, also for tasks without a schedule. Poll GET /api/v1/admin/tasks for the outcome.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Task name"
+// @Success 202 {object} TaskRun
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/admin/tasks/{name}/run [post]
+func (s *Server) handleRunTask(w http.ResponseWriter, r *http.Request, name string) {
+	started, err := s.startTask(r.Context(), name)
+	switch {
+	case errors.Is(err, errTaskUnknown):
+		writeAPIError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, errTaskRunning):
+		writeAPIError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.audit(r, "task.run", zap.String("task", name), zap.String("run", started.ID))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(started); err != nil {
+		s.logger.Warn("encode task run", zap.Error(err))
+	}
+}
+
+// @Summary Cancel a task run
+// @Description Cancels the running task run with this ID. The task stops at its next cancellation point and the run is recorded as canceled.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Run ID"
+// @Success 202 {object} TaskRun
+// @Failure 404 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/admin/tasks/{id}/cancel [post]
+func (s *Server) handleCancelTaskRun(w http.ResponseWriter, r *http.Request, id string) {
+	run, found := s.cancelTaskRun(id)
+	if !found {
+		writeAPIError(w, "no running task with this id", http.StatusNotFound)
+		return
+	}
+	s.audit(r, "task.cancel", zap.String("task", run.Task), zap.String("run", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(run); err != nil {
+		s.logger.Warn("encode task run", zap.Error(err))
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestTaskAPI(t *testing.T) {
+	srv := New(newMemStore(), zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	if err := srv.ScheduleTask(Task{Name: "slow", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if err := srv.ScheduleTask(Task{Name: "broken", Schedule: "@daily", Run: func(ctx context.Context) error {
+		return errors.New("boom")
+	}}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	list := func() map[string]TaskStatus {
+		t.Helper()
+		rr := do(http.MethodGet, "/api/v1/admin/tasks")
+		var statuses []TaskStatus
+		if err := json.Unmarshal(rr.Body.Bytes(), &statuses); err != nil {
+			t.Fatalf("decode: %v: %s", err, rr.Body.String())
+		}
+		byName := map[string]TaskStatus{}
+		for _, st := range statuses {
+			byName[st.Name] = st
+		}
+		return byName
+	}
+	waitFor := func(name, status string) TaskStatus {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			st := list()[name]
+			if st.LastRun != nil && st.LastRun.Status == status {
+				return st
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: expected last run %s, got %+v", name, status, st)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if st := list(); st["slow"].Enabled || !st["broken"].Enabled || st["broken"].Schedule != "@daily" {
+		t.Fatalf("unexpected tasks %+v", st)
+	}
+
+	rr := do(http.MethodPost, "/api/v1/admin/tasks/slow/run")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var run TaskRun
+	if err := json.Unmarshal(rr.Body.Bytes(), &run); err != nil || run.ID == "" || run.Status != TaskRunning || run.Trigger != "manual" {
+		t.Fatalf("unexpected run %+v (%v)", run, err)
+	}
+	if rr := do(http.MethodPost, "/api/v1/admin/tasks/slow/run"); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while running, got %d", rr.Code)
+	}
+	if st := list()["slow"]; st.Running == nil || st.Running.ID != run.ID {
+		t.Fatalf("expected run in progress, got %+v", st)
+	}
+
+	if rr := do(http.MethodPost, "/api/v1/admin/tasks/"+run.ID+"/cancel"); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 on cancel, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if st := waitFor("slow", TaskCanceled); st.Running != nil || st.LastRun.ID != run.ID || st.LastRun.FinishedAt == nil {
+		t.Fatalf("unexpected status after cancel %+v", st)
+	}
+	if rr := do(http.MethodPost, "/api/v1/admin/tasks/"+run.ID+"/cancel"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for finished run, got %d", rr.Code)
+	}
+
+	if rr := do(http.MethodPost, "/api/v1/admin/tasks/broken/run"); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rr.Code)
+	}
+	if st := waitFor("broken", TaskFailed); st.LastRun.Error != "boom" {
+		t.Fatalf("expected error recorded, got %+v", st.LastRun)
+	}
+
+	for path, code := range map[string]int{
+		"/api/v1/admin/tasks/nope/run": http.StatusNotFound,
+		"/api/v1/admin/tasks/slow":     http.StatusNotFound,
+		"/api/v1/admin/tasks/slow/foo": http.StatusNotFound,
+	} {
+		if rr := do(http.MethodPost, path); rr.Code != code {
+			t.Fatalf("%s: expected %d, got %d", path, code, rr.Code)
+		}
+	}
+	if rr := do(http.MethodGet, "/api/v1/admin/tasks/slow/run"); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// throttleChunk bounds how much is written between waits, keeping the
+	// rate smooth for large objects.
+	throttleChunk = 32 << 10
+	// throttleIdle drops the bucket of a client after this long unused.
+	throttleIdle = 5 * time.Minute
+)
+
+// Throttle limits download bandwidth per client (token, or IP for other
+// requests) and across all clients. A zero rate disables that limit.
+type Throttle struct {
+	perClient int64
+	global    *byteBucket
+
+	mu      sync.Mutex
+	clients map[string]*byteBucket
+	swept   time.Time
+}
+
+// NewThrottle returns nil when both rates are zero.
+func NewThrottle(perClient, global int64) *Throttle {
+	if perClient <= 0 && global <= 0 {
+		return nil
+	}
+	t := &Throttle{perClient: perClient, clients: make(map[string]*byteBucket)}
+	if global > 0 {
+		t.global = newByteBucket(global)
+	}
+	return t
+}
+
+// byteBucket is a token bucket holding up to one second of bytes. Writers
+// take what they need up front and sleep off any debt, so concurrent
+// writers share the rate.
+type byteBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newByteBucket(rate int64) *byteBucket {
+	return &byteBucket{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// reserve takes n bytes and returns how long to wait before sending them.
+func (b *byteBucket) reserve(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *byteBucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.last) > throttleIdle
+}
+
+// clientKey identifies the client of r: its token if it used one, its IP
+// otherwise.
+func clientKey(r *http.Request) string {
+	if tok := principalFrom(r.Context()).token; tok != nil {
+		return "token:" + tok.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func (t *Throttle) client(key string) *byteBucket {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Sub(t.swept) > throttleIdle {
+		for k, b := range t.clients {
+			if b.idle(now) {
+				delete(t.clients, k)
+			}
+		}
+		t.swept = now
+	}
+	b, ok := t.clients[key]
+	if !ok {
+		b = newByteBucket(t.perClient)
+		t.clients[key] = b
+	}
+	return b
+}
+
+// Writer wraps w with the limits applying to r. Waits end early when the
+// request is cancelled.
+func (t *Throttle) Writer(w io.Writer, r *http.Request) io.Writer {
+	if t == nil {
+		return w
+	}
+	tw := &throttledWriter{w: w, ctx: r.Context()}
+	if t.perClient > 0 {
+		tw.buckets = append(tw.buckets, t.client(clientKey(r)))
+	}
+	if t.global != nil {
+		tw.buckets = append(tw.buckets, t.global)
+	}
+	return tw
+}
+
+type throttledWriter struct {
+	w       io.Writer
+	ctx     context.Context
+	buckets []*byteBucket
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), throttleChunk)]
+		var wait time.Duration
+		for _, b := range tw.buckets {
+			wait = max(wait, b.reserve(len(chunk)))
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-tw.ctx.Done():
+				timer.Stop()
+				return written, tw.ctx.Err()
+			case <-timer.C:
+			}
+		}
+		n, err := tw.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
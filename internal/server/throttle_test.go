@@ -0,0 +1,56 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestThrottleDownloads(t *testing.T) {
+	store := newMemStore()
+	const rate = 64 << 10
+	srv := NewWithOptions(store, zaptest.NewLogger(t), metrics.New(), Options{Throttle: NewThrottle(rate, 0)})
+	body := bytes.Repeat([]byte("x"), rate*3/2)
+	_ = store.Put(context.Background(), "releases/a.jar", bytes.NewReader(body), "application/java-archive", int64(len(body)))
+
+	get := func(remote string) time.Duration {
+		req := httptest.NewRequest(http.MethodGet, "/releases/a.jar", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		srv.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.Len() != len(body) {
+			t.Fatalf("unexpected response %d with %d bytes", rec.Code, rec.Body.Len())
+		}
+		return time.Since(start)
+	}
+
+	// The first download fits in the burst of one second plus half a
+	// second of debt; the second one from the same IP waits for the
+	// whole body.
+	if d := get("10.0.0.1:1234"); d < 400*time.Millisecond {
+		t.Fatalf("expected the first download to be throttled, took %v", d)
+	}
+	if d := get("10.0.0.1:5678"); d < 1200*time.Millisecond {
+		t.Fatalf("expected the same client to share its limit, took %v", d)
+	}
+	if d := get("10.0.0.2:1234"); d > 1200*time.Millisecond {
+		t.Fatalf("expected another client to have its own limit, took %v", d)
+	}
+}
+
+func TestNewThrottleDisabled(t *testing.T) {
+	if NewThrottle(0, 0) != nil {
+		t.Fatal("expected no throttle without limits")
+	}
+	var w bytes.Buffer
+	if (*Throttle)(nil).Writer(&w, httptest.NewRequest(http.MethodGet, "/", nil)) != &w {
+		t.Fatal("expected a nil throttle to return the writer")
+	}
+}
@@ -0,0 +1,474 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+const tokenPrefix = "__tokens__/"
+
+var (
+	tokenIDRe       = regexp.MustCompile(`^tok-[a-f0-9]{16}$`)
+	tokenVerbs      = []string{"read", "write"}
+	errTokenMissing = errors.New("token not found")
+	errTokenRevoked = errors.New("token revoked")
+)
+
+const defaultRotationGrace = 15 * time.Minute
+
+// tokenCacheTTL bounds how long Authenticate trusts a token record read from
+// the store. Changes made through this replica apply at once; revocations on
+// other replicas take effect within the TTL.
+const tokenCacheTTL = 10 * time.Second
+
+// DeployToken grants a set of verbs on artifact paths matching Prefix.
+// Prefix is either a plain path prefix or a glob ("**" spans directories).
+type DeployToken struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Verbs     []string   `json:"verbs"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	RotatedTo string     `json:"rotatedTo,omitempty"`
+	// DailyRequests and DailyBytes override the instance's default daily
+	// budgets for this token; 0 lifts the limit.
+	DailyRequests *int64 `json:"dailyRequests,omitempty"`
+	DailyBytes    *int64 `json:"dailyBytes,omitempty"`
+}
+
+// CreateTokenRequest accepts either an absolute ExpiresAt or a relative
+// ExpiresIn duration (e.g. 720h); both empty means the token never expires.
+type CreateTokenRequest struct {
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Verbs     []string   `json:"verbs"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	ExpiresIn string     `json:"expiresIn,omitempty"`
+	// DailyRequests and DailyBytes override the default daily budgets
+	// (TOKEN_DAILY_REQUESTS, TOKEN_DAILY_BYTES); 0 lifts the limit.
+	DailyRequests *int64 `json:"dailyRequests,omitempty"`
+	DailyBytes    *int64 `json:"dailyBytes,omitempty"`
+}
+
+type RotateTokenRequest struct {
+	// GracePeriod keeps the old token valid for a while (default 15m).
+	GracePeriod string `json:"gracePeriod,omitempty"`
+}
+
+// CreateTokenResponse carries the secret, which is only ever shown once.
+type CreateTokenResponse struct {
+	DeployToken
+	Secret string `json:"secret"`
+}
+
+type storedToken struct {
+	DeployToken
+	SecretHash string `json:"secretHash"`
+}
+
+// Active reports whether the token is neither revoked nor expired at now.
+func (t DeployToken) Active(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// Allows reports whether the token may perform verb on key.
+func (t DeployToken) Allows(verb, key string) bool {
+	if !slices.Contains(t.Verbs, verb) {
+		return false
+	}
+	key = strings.TrimPrefix(key, "/")
+	if isInternalPath(key) {
+		return false
+	}
+	if strings.ContainsAny(t.Prefix, "*?") {
+		re, err := globToRegexp(t.Prefix)
+		return err == nil && re.MatchString(key)
+	}
+	prefix := strings.TrimSuffix(t.Prefix, "/")
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
+type TokenManager struct {
+	store Storage
+
+	mu    sync.Mutex
+	cache map[string]cachedToken
+}
+
+type cachedToken struct {
+	token   storedToken
+	expires time.Time
+}
+
+func NewTokenManager(store Storage) *TokenManager {
+	return &TokenManager{store: store, cache: make(map[string]cachedToken)}
+}
+
+func (m *TokenManager) Create(ctx context.Context, req CreateTokenRequest) (CreateTokenResponse, error) {
+	prefix := strings.Trim(strings.TrimSpace(req.Prefix), "/")
+	if prefix == "" {
+		return CreateTokenResponse{}, fmt.Errorf("prefix is required")
+	}
+	if isInternalPath(prefix) {
+		return CreateTokenResponse{}, fmt.Errorf("prefix must not target internal paths")
+	}
+	if _, err := globToRegexp(prefix); err != nil {
+		return CreateTokenResponse{}, fmt.Errorf("invalid prefix: %w", err)
+	}
+	if len(req.Verbs) == 0 {
+		return CreateTokenResponse{}, fmt.Errorf("at least one verb is required")
+	}
+	verbs := make([]string, 0, len(req.Verbs))
+	for _, v := range req.Verbs {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if !slices.Contains(tokenVerbs, v) {
+			return CreateTokenResponse{}, fmt.Errorf("unknown verb %q; expected one of %s", v, strings.Join(tokenVerbs, ", "))
+		}
+		if !slices.Contains(verbs, v) {
+			verbs = append(verbs, v)
+		}
+	}
+
+	now := time.Now().UTC()
+	expiresAt := req.ExpiresAt
+	if req.ExpiresIn != "" {
+		if expiresAt != nil {
+			return CreateTokenResponse{}, fmt.Errorf("expiresAt and expiresIn are mutually exclusive")
+		}
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			return CreateTokenResponse{}, fmt.Errorf("invalid expiresIn; expected a duration such as 720h")
+		}
+		at := now.Add(d)
+		expiresAt = &at
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return CreateTokenResponse{}, fmt.Errorf("expiresAt must be in the future")
+	}
+	if (req.DailyRequests != nil && *req.DailyRequests < 0) || (req.DailyBytes != nil && *req.DailyBytes < 0) {
+		return CreateTokenResponse{}, fmt.Errorf("dailyRequests and dailyBytes must not be negative")
+	}
+
+	return m.issue(ctx, DeployToken{
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		Verbs:     verbs,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+
+		DailyRequests: req.DailyRequests,
+		DailyBytes:    req.DailyBytes,
+	})
+}
+
+func (m *TokenManager) issue(ctx context.Context, tok DeployToken) (CreateTokenResponse, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return CreateTokenResponse{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return CreateTokenResponse{}, err
+	}
+	tok.ID = "tok-" + id
+	if err := m.save(ctx, storedToken{DeployToken: tok, SecretHash: hashSecret(secret)}); err != nil {
+		return CreateTokenResponse{}, err
+	}
+	return CreateTokenResponse{DeployToken: tok, Secret: secret}, nil
+}
+
+// Rotate issues a replacement with the same scope and expiry and shortens the
+// old token's lifetime to the grace period.
+func (m *TokenManager) Rotate(ctx context.Context, id string, req RotateTokenRequest) (CreateTokenResponse, error) {
+	grace := defaultRotationGrace
+	if req.GracePeriod != "" {
+		d, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || d < 0 {
+			return CreateTokenResponse{}, fmt.Errorf("invalid gracePeriod; expected a duration such as 15m")
+		}
+		grace = d
+	}
+	old, err := m.find(ctx, id)
+	if err != nil {
+		return CreateTokenResponse{}, err
+	}
+	now := time.Now().UTC()
+	if !old.Active(now) {
+		return CreateTokenResponse{}, errTokenRevoked
+	}
+
+	created, err := m.issue(ctx, DeployToken{
+		Name:      old.Name,
+		Prefix:    old.Prefix,
+		Verbs:     old.Verbs,
+		CreatedAt: now,
+		ExpiresAt: old.ExpiresAt,
+
+		DailyRequests: old.DailyRequests,
+		DailyBytes:    old.DailyBytes,
+	})
+	if err != nil {
+		return CreateTokenResponse{}, err
+	}
+
+	cutoff := now.Add(grace)
+	if old.ExpiresAt == nil || cutoff.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &cutoff
+	}
+	old.RotatedTo = created.ID
+	if err := m.save(ctx, old); err != nil {
+		return CreateTokenResponse{}, err
+	}
+	return created, nil
+}
+
+// Revoke marks the token revoked. The record is kept so the revocation list
+// stays auditable, until PruneRevoked deletes it.
+func (m *TokenManager) Revoke(ctx context.Context, id string) error {
+	tok, err := m.find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if tok.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	tok.RevokedAt = &now
+	return m.save(ctx, tok)
+}
+
+// Revoked returns the revocation list.
+func (m *TokenManager) Revoked(ctx context.Context) ([]DeployToken, error) {
+	tokens, err := m.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revoked := []DeployToken{}
+	for _, tok := range tokens {
+		if tok.RevokedAt != nil {
+			revoked = append(revoked, tok)
+		}
+	}
+	return revoked, nil
+}
+
+// PruneRevoked deletes the tokens revoked before cutoff and returns how
+// many were removed.
+func (m *TokenManager) PruneRevoked(ctx context.Context, cutoff time.Time) (int, error) {
+	tokens, err := m.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, tok := range tokens {
+		if tok.RevokedAt == nil || !tok.RevokedAt.Before(cutoff) {
+			continue
+		}
+		err := m.store.Delete(ctx, tokenKey(tok.ID))
+		m.forget(tok.ID)
+		if err != nil && !storage.IsNotFound(err) {
+			return pruned, fmt.Errorf("delete token %s: %w", tok.ID, err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// List returns every token, walking all pages of __tokens__/.
+func (m *TokenManager) List(ctx context.Context) ([]DeployToken, error) {
+	tokens := []DeployToken{}
+	err := m.store.Walk(ctx, tokenPrefix, func(e storage.Entry) error {
+		if e.Type != "file" || !strings.HasSuffix(e.Path, ".json") {
+			return nil
+		}
+		tok, err := m.load(ctx, strings.TrimSuffix(path.Base(e.Path), ".json"))
+		if err != nil {
+			return nil
+		}
+		tokens = append(tokens, tok.DeployToken)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (m *TokenManager) find(ctx context.Context, id string) (storedToken, error) {
+	if !tokenIDRe.MatchString(id) {
+		return storedToken{}, errTokenMissing
+	}
+	tok, err := m.load(ctx, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return storedToken{}, errTokenMissing
+		}
+		return storedToken{}, err
+	}
+	return tok, nil
+}
+
+func (m *TokenManager) save(ctx context.Context, tok storedToken) error {
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	err = m.store.Put(ctx, tokenKey(tok.ID), strings.NewReader(string(data)), "application/json", int64(len(data)))
+	// Revoke and Rotate go through here; drop the cached record even if the
+	// write failed, so the next request rereads it.
+	m.forget(tok.ID)
+	return err
+}
+
+func (m *TokenManager) forget(id string) {
+	m.mu.Lock()
+	delete(m.cache, id)
+	m.mu.Unlock()
+}
+
+// Authenticate returns the token for id when secret matches and the token is
+// neither expired nor on the revocation list.
+func (m *TokenManager) Authenticate(ctx context.Context, id, secret string) (DeployToken, bool) {
+	if !tokenIDRe.MatchString(id) {
+		return DeployToken{}, false
+	}
+	tok, err := m.cached(ctx, id)
+	if err != nil {
+		return DeployToken{}, false
+	}
+	if subtle.ConstantTimeCompare([]byte(tok.SecretHash), []byte(hashSecret(secret))) != 1 {
+		return DeployToken{}, false
+	}
+	if !tok.Active(time.Now()) {
+		return DeployToken{}, false
+	}
+	return tok.DeployToken, true
+}
+
+// cached returns the record of id, reading the store at most once per
+// tokenCacheTTL so authenticated requests do not each cost a GET.
+func (m *TokenManager) cached(ctx context.Context, id string) (storedToken, error) {
+	now := time.Now()
+	m.mu.Lock()
+	c, ok := m.cache[id]
+	m.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.token, nil
+	}
+	tok, err := m.load(ctx, id)
+	if err != nil {
+		return storedToken{}, err
+	}
+	m.mu.Lock()
+	for k, c := range m.cache {
+		if now.After(c.expires) {
+			delete(m.cache, k)
+		}
+	}
+	m.cache[id] = cachedToken{token: tok, expires: now.Add(tokenCacheTTL)}
+	m.mu.Unlock()
+	return tok, nil
+}
+
+func (m *TokenManager) load(ctx context.Context, id string) (storedToken, error) {
+	resp, err := m.store.Get(ctx, tokenKey(id))
+	if err != nil {
+		return storedToken{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return storedToken{}, err
+	}
+	var tok storedToken
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return storedToken{}, err
+	}
+	return tok, nil
+}
+
+func tokenKey(id string) string {
+	return tokenPrefix + id + ".json"
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+type principalKey struct{}
+
+// principal is the authenticated caller: the configured admin, a user
+// asserted by a trusted forward-auth proxy, or a deploy token.
+type principal struct {
+	name  string
+	token *DeployToken
+	// forwarded is set for users identified by forward auth, who are admins
+	// only when admin is set too.
+	forwarded bool
+	admin     bool
+}
+
+func principalFrom(ctx context.Context) principal {
+	p, _ := ctx.Value(principalKey{}).(principal)
+	return p
+}
+
+// principalName returns the caller's identity for logs and policy input.
+func principalName(r *http.Request) string {
+	if p := principalFrom(r.Context()); p.name != "" {
+		return p.name
+	}
+	u, _, _ := r.BasicAuth()
+	return u
+}
+
+func (p principal) isAdmin() bool {
+	if p.forwarded {
+		return p.admin
+	}
+	return p.token == nil
+}
+
+// pruneTokensTask drops tokens revoked more than retention ago, so the
+// revocation list does not grow forever.
+func (s *Server) pruneTokensTask(ctx context.Context, retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+	pruned, err := s.tokens.PruneRevoked(ctx, time.Now().Add(-retention))
+	if pruned > 0 {
+		s.logger.Info("pruned revoked tokens", zap.Int("count", pruned))
+	}
+	return err
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDeployTokenScopes(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	h := srv.Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/tokens", strings.NewReader(`{"name":"team-a ci","prefix":"releases/com/mycorp/team-a/**","verbs":["write"]}`))
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created CreateTokenResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	do := func(method, target string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader("data"))
+		req.SetBasicAuth(created.ID, created.Secret)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := do(http.MethodPut, "/releases/com/mycorp/team-a/app/1.0/app-1.0.jar"); code != http.StatusCreated {
+		t.Fatalf("expected in-scope upload to succeed, got %d", code)
+	}
+	if code := do(http.MethodPut, "/releases/com/mycorp/team-b/app/1.0/app-1.0.jar"); code != http.StatusForbidden {
+		t.Fatalf("expected out-of-scope upload to be forbidden, got %d", code)
+	}
+	if code := do(http.MethodGet, "/releases/com/mycorp/team-a/app/1.0/app-1.0.jar"); code != http.StatusForbidden {
+		t.Fatalf("expected read with write-only token to be forbidden, got %d", code)
+	}
+	if code := do(http.MethodGet, "/proxies"); code != http.StatusForbidden {
+		t.Fatalf("expected admin API to be forbidden, got %d", code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/tokens/"+created.ID, nil)
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if code := do(http.MethodPut, "/releases/com/mycorp/team-a/app/1.0/app-1.0.jar"); code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked token to be rejected, got %d", code)
+	}
+}
+
+func TestCreateTokenRejectsUnknownVerb(t *testing.T) {
+	_, err := NewTokenManager(newMemStore()).Create(t.Context(), CreateTokenRequest{Prefix: "releases", Verbs: []string{"admin"}})
+	if err == nil {
+		t.Fatal("expected error for unknown verb")
+	}
+}
+
+func TestRotateTokenHonorsGracePeriod(t *testing.T) {
+	ctx := t.Context()
+	m := NewTokenManager(newMemStore())
+	old, err := m.Create(ctx, CreateTokenRequest{Prefix: "releases", Verbs: []string{"write"}, ExpiresIn: "720h"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	replacement, err := m.Rotate(ctx, old.ID, RotateTokenRequest{GracePeriod: "0s"})
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if replacement.Prefix != "releases" || replacement.ExpiresAt == nil || !replacement.ExpiresAt.Equal(*old.ExpiresAt) {
+		t.Fatalf("replacement should keep scope and expiry: %+v", replacement.DeployToken)
+	}
+	if _, ok := m.Authenticate(ctx, old.ID, old.Secret); ok {
+		t.Fatal("old token should expire once the grace period is over")
+	}
+	if _, ok := m.Authenticate(ctx, replacement.ID, replacement.Secret); !ok {
+		t.Fatal("replacement token should authenticate")
+	}
+
+	if err := m.Revoke(ctx, replacement.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	revoked, err := m.Revoked(ctx)
+	if err != nil || len(revoked) != 1 || revoked[0].ID != replacement.ID {
+		t.Fatalf("unexpected revocation list %+v (%v)", revoked, err)
+	}
+	if _, err := m.Rotate(ctx, replacement.ID, RotateTokenRequest{}); err == nil {
+		t.Fatal("rotating a revoked token should fail")
+	}
+}
+
+func TestAuthenticateCachesTokens(t *testing.T) {
+	ctx := t.Context()
+	store := newMemStore()
+	m := NewTokenManager(store)
+	tok, err := m.Create(ctx, CreateTokenRequest{Prefix: "releases", Verbs: []string{"read"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, ok := m.Authenticate(ctx, tok.ID, tok.Secret); !ok {
+		t.Fatal("token should authenticate")
+	}
+
+	// A record changed behind the manager's back is only reread after
+	// tokenCacheTTL.
+	key := tokenKey(tok.ID)
+	store.data[key] = memObj{body: []byte(`{}`)}
+	if _, ok := m.Authenticate(ctx, tok.ID, tok.Secret); !ok {
+		t.Fatal("expected the cached record to be used")
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	// Revoking through the manager applies at once.
+	tok, err = m.Create(ctx, CreateTokenRequest{Prefix: "releases", Verbs: []string{"read"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, ok := m.Authenticate(ctx, tok.ID, tok.Secret); !ok {
+		t.Fatal("token should authenticate")
+	}
+	if err := m.Revoke(ctx, tok.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, ok := m.Authenticate(ctx, tok.ID, tok.Secret); ok {
+		t.Fatal("revoked token must be rejected without waiting for the cache")
+	}
+}
+
+// pagedStore caps List at limit entries like S3, so callers relying on a
+// single List call miss the rest.
+type pagedStore struct {
+	*memStore
+}
+
+func (p pagedStore) List(ctx context.Context, prefix string, limit int32) ([]storage.Entry, error) {
+	entries, err := p.memStore.List(ctx, prefix, limit)
+	if limit > 0 && len(entries) > int(limit) {
+		entries = entries[:limit]
+	}
+	return entries, err
+}
+
+func TestListTokensWalksAllPages(t *testing.T) {
+	ctx := t.Context()
+	m := NewTokenManager(pagedStore{newMemStore()})
+	for range 1001 {
+		if _, err := m.Create(ctx, CreateTokenRequest{Prefix: "releases", Verbs: []string{"read"}}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	tokens, err := m.List(ctx)
+	if err != nil || len(tokens) != 1001 {
+		t.Fatalf("expected 1001 tokens, got %d (%v)", len(tokens), err)
+	}
+}
+
+func TestTokenPruneTaskDropsOldRevocations(t *testing.T) {
+	ctx := t.Context()
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	create := func() string {
+		tok, err := srv.tokens.Create(ctx, CreateTokenRequest{Prefix: "releases/**", Verbs: []string{"read"}})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		return tok.ID
+	}
+	active, revoked := create(), create()
+	if err := srv.tokens.Revoke(ctx, revoked); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	task := srv.Tasks(TaskSettings{TokenRetention: time.Hour})["token-prune"]
+	if err := task.Run(ctx); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if list, _ := srv.tokens.Revoked(ctx); len(list) != 1 {
+		t.Fatalf("expected a recent revocation to be kept, got %d", len(list))
+	}
+
+	pruned, err := srv.tokens.PruneRevoked(ctx, time.Now().Add(time.Minute))
+	if err != nil || pruned != 1 {
+		t.Fatalf("expected one pruned token, got %d (%v)", pruned, err)
+	}
+	tokens, err := srv.tokens.List(ctx)
+	if err != nil || len(tokens) != 1 || tokens[0].ID != active {
+		t.Fatalf("expected only the active token to remain, got %+v (%v)", tokens, err)
+	}
+}
@@ -0,0 +1,484 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// uploadPrefix holds the state and received chunks of unfinished resumable
+// uploads: <id>/info.json and one <id>/<offset> object per PATCH.
+const uploadPrefix = "__uploads__/"
+
+const tusVersion = "1.0.0"
+
+// ResumableUploads bounds tus uploads. Zero values take the defaults: 5 GiB,
+// the largest object S3 accepts in one PUT, and 24h.
+type ResumableUploads struct {
+	// MaxSize is the largest Upload-Length accepted.
+	MaxSize int64
+	// Expiry is how long an upload is kept after its last PATCH.
+	Expiry time.Duration
+}
+
+func (u ResumableUploads) maxSize() int64 {
+	if u.MaxSize <= 0 {
+		return 5 << 30
+	}
+	return u.MaxSize
+}
+
+func (u ResumableUploads) expiry() time.Duration {
+	if u.Expiry <= 0 {
+		return 24 * time.Hour
+	}
+	return u.Expiry
+}
+
+// tusUpload is the state of an unfinished upload. Chunks are the sizes of
+// the stored chunks, in order; each is stored under its starting offset.
+type tusUpload struct {
+	ID          string    `json:"id"`
+	Path        string    `json:"path"`
+	ContentType string    `json:"contentType,omitempty"`
+	Length      int64     `json:"length"`
+	Offset      int64     `json:"offset"`
+	Chunks      []int64   `json:"chunks,omitempty"`
+	Owner       string    `json:"owner"`
+	Expires     time.Time `json:"expires"`
+}
+
+func tusInfoKey(id string) string {
+	return uploadPrefix + id + "/info.json"
+}
+
+func tusChunkKey(id string, offset int64) string {
+	return fmt.Sprintf("%s%s/%020d", uploadPrefix, id, offset)
+}
+
+func (s *Server) loadUpload(ctx context.Context, id string) (*tusUpload, error) {
+	resp, err := s.store.Get(ctx, tusInfoKey(id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var u tusUpload
+	if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
+		return nil, fmt.Errorf("decode upload %s: %w", id, err)
+	}
+	return &u, nil
+}
+
+func (s *Server) saveUpload(ctx context.Context, u *tusUpload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(ctx, tusInfoKey(u.ID), strings.NewReader(string(data)), "application/json", int64(len(data)))
+}
+
+// deleteUpload removes the state and chunks of an upload.
+func (s *Server) deleteUpload(ctx context.Context, id string) error {
+	var keys []string
+	err := s.store.Walk(ctx, uploadPrefix+id+"/", func(e storage.Entry) error {
+		keys = append(keys, e.Path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil && !storage.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated
+// "key base64value" pairs, the value being optional.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	meta := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		meta[key] = string(value)
+	}
+	return meta, nil
+}
+
+func (s *Server) routeTus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && r.Method == http.MethodPost {
+		r.Method = strings.ToUpper(override)
+	}
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", "creation,termination,expiration")
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(s.resumable.maxSize(), 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		writeAPIError(w, "unsupported Tus-Resumable version", http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, apiV1+"/tus"), "/")
+	if id == "" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "OPTIONS, POST")
+			writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleTusCreate(w, r)
+		return
+	}
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		writeAPIError(w, "upload not found", http.StatusNotFound)
+		return
+	}
+	u, err := s.loadUpload(r.Context(), id)
+	if storage.IsNotFound(err) || (err == nil && u.Owner != principalName(r)) {
+		writeAPIError(w, "upload not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("load upload", zap.String("id", id), zap.Error(err))
+		writeAPIError(w, "read upload failed", http.StatusInternalServerError)
+		return
+	}
+	if time.Now().After(u.Expires) {
+		writeAPIError(w, "upload expired", http.StatusGone)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+		w.Header().Set("Upload-Expires", u.Expires.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		s.handleTusPatch(w, r, u)
+	case http.MethodDelete:
+		if err := s.deleteUpload(r.Context(), id); err != nil {
+			s.logger.Error("delete upload", zap.String("id", id), zap.Error(err))
+			writeAPIError(w, "delete upload failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "OPTIONS, HEAD, PATCH, DELETE")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary Start a resumable upload
+// @Description Creates a tus 1.0.0 upload of Upload-Length bytes. Upload-Metadata must carry path (the artifact path; one ending in / takes the filename entry) and may carry filetype. Send the content with PATCH to the returned Location; the artifact is stored like a PUT of path once the last byte arrives. Deploy tokens need write scope for the path.
+// @Tags artifacts
+// @Produce json
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Length header int true "Total size in bytes"
+// @Param Upload-Metadata header string true "path and optional filename/filetype, base64-encoded"
+// @Success 201 "Created; Location names the upload"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/tus [post]
+func (s *Server) handleTusCreate(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		writeAPIError(w, "Upload-Length required", http.StatusBadRequest)
+		return
+	}
+	if length > s.resumable.maxSize() {
+		writeAPIError(w, "Upload-Length exceeds Tus-Max-Size", http.StatusRequestEntityTooLarge)
+		return
+	}
+	meta, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		writeAPIError(w, "invalid Upload-Metadata: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := strings.TrimPrefix(meta["path"], "/")
+	if strings.HasSuffix(key, "/") && meta["filename"] != "" {
+		key += path.Base(meta["filename"])
+	}
+	if key == "" || strings.HasSuffix(key, "/") || strings.Contains(key, "..") || isInternalPath(key) || strings.HasPrefix(key, "api/") {
+		writeAPIError(w, "Upload-Metadata path must name an artifact", http.StatusBadRequest)
+		return
+	}
+	if tok := principalFrom(r.Context()).token; tok != nil && !tok.Allows("write", key) {
+		writeAPIError(w, "token not permitted for this path", http.StatusForbidden)
+		return
+	}
+
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	u := &tusUpload{
+		ID:          hex.EncodeToString(id),
+		Path:        key,
+		ContentType: meta["filetype"],
+		Length:      length,
+		Owner:       principalName(r),
+		Expires:     time.Now().Add(s.resumable.expiry()),
+	}
+	if err := s.saveUpload(r.Context(), u); err != nil {
+		s.logger.Error("create upload", zap.String("path", key), zap.Error(err))
+		writeAPIError(w, "create upload failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", requestBaseURL(r)+apiV1+"/tus/"+u.ID)
+	w.Header().Set("Upload-Expires", u.Expires.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// @Summary Continue a resumable upload
+// @Description Appends the body to the upload at Upload-Offset, which must equal the bytes received so far (see HEAD). Bytes received before a dropped connection are kept. When the upload is complete the artifact is stored like a PUT of its path, with the same checks, and S3 joins the stored chunks with a multipart upload instead of receiving the artifact again; if that fails, the upload keeps its previous offset and the error is returned.
+// @Tags artifacts
+// @Accept application/offset+octet-stream
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Offset header int true "Offset of the body"
+// @Success 204 "Upload-Offset holds the new offset"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/tus/{id} [patch]
+func (s *Server) handleTusPatch(w http.ResponseWriter, r *http.Request, u *tusUpload) {
+	defer r.Body.Close()
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		writeAPIError(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != u.Offset {
+		writeAPIError(w, "Upload-Offset does not match the upload offset "+strconv.FormatInt(u.Offset, 10), http.StatusConflict)
+		return
+	}
+
+	tmp, err := os.CreateTemp("", "heimdall-tus-*")
+	if err != nil {
+		s.writeError(w, "buffer chunk", err)
+		return
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	// A dropped connection still leaves the bytes that made it, which are
+	// kept so the client resumes after them. The request context is
+	// canceled along with the connection, so they are stored without it.
+	n, copyErr := io.Copy(tmp, io.LimitReader(r.Body, u.Length-u.Offset))
+	ctx := context.WithoutCancel(r.Context())
+	if copyErr != nil && n == 0 {
+		s.logger.Info("read chunk", zap.String("id", u.ID), zap.Error(copyErr))
+		writeAPIError(w, "read body failed", http.StatusBadRequest)
+		return
+	}
+	if n > 0 {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			s.writeError(w, "buffer chunk seek", err)
+			return
+		}
+		if err := s.store.Put(ctx, tusChunkKey(u.ID, u.Offset), tmp, "application/octet-stream", n); err != nil {
+			s.writeError(w, "store chunk", err)
+			return
+		}
+	}
+	chunks := u.Chunks
+	if n > 0 {
+		chunks = append(slices.Clone(u.Chunks), n)
+	}
+
+	if u.Offset+n == u.Length && copyErr == nil {
+		// The offset only moves once the artifact is stored, so a failed
+		// store is retried by sending the last chunk again.
+		if !s.completeUpload(w, r, u, chunks) {
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.Length, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	u.Offset += n
+	u.Chunks = chunks
+	u.Expires = time.Now().Add(s.resumable.expiry())
+	if err := s.saveUpload(ctx, u); err != nil {
+		s.writeError(w, "save upload", err)
+		return
+	}
+	if copyErr != nil {
+		s.logger.Info("chunk interrupted", zap.String("id", u.ID), zap.Int64("kept", n), zap.Error(copyErr))
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Expires", u.Expires.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// completeUpload stores the chunks of u as its artifact through the PUT
+// path and drops the upload. It writes the error response and returns false
+// when the artifact could not be stored.
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, u *tusUpload, chunks []int64) bool {
+	parts := make([]storage.Part, len(chunks))
+	var offset int64
+	for i, n := range chunks {
+		parts[i] = storage.Part{Key: tusChunkKey(u.ID, offset), Size: n}
+		offset += n
+	}
+	r = r.WithContext(context.WithValue(r.Context(), uploadPartsKey{}, parts))
+	body := &chunkReader{ctx: r.Context(), store: s.store, id: u.ID, chunks: chunks}
+	defer body.Close()
+	uw := s.storeUpload(w, r, u.Path, body, u.Length, u.ContentType)
+	if uw.status >= http.StatusBadRequest {
+		p := uw.problem()
+		if body.err != nil {
+			s.logger.Error("read chunks", zap.String("id", u.ID), zap.Error(body.err))
+			p.Type, p.Detail = "about:blank", "read upload chunks failed"
+		}
+		writeProblem(w, p.Type, uw.status, p.Detail)
+		return false
+	}
+	if err := s.deleteUpload(r.Context(), u.ID); err != nil {
+		s.logger.Warn("delete finished upload", zap.String("id", u.ID), zap.Error(err))
+	}
+	w.Header().Del("Content-Type")
+	w.Header().Del("Location")
+	return true
+}
+
+// uploadPartsKey carries the stored chunks of a finished resumable upload
+// from completeUpload to handlePut.
+type uploadPartsKey struct{}
+
+// composingStorage is implemented by stores that join stored objects into
+// one with a multipart upload (storage.Store.Compose).
+type composingStorage interface {
+	Compose(ctx context.Context, key string, parts []storage.Part, contentType string) error
+}
+
+// putArtifact stores body as key. When body holds the chunks of a finished
+// resumable upload as they were stored (asUploaded), the store joins the
+// chunks itself if it can, instead of body being uploaded again in one PUT.
+func (s *Server) putArtifact(ctx context.Context, key string, body io.ReadSeeker, contentType string, size int64, asUploaded bool) error {
+	parts, _ := ctx.Value(uploadPartsKey{}).([]storage.Part)
+	if cs, ok := s.store.(composingStorage); ok && asUploaded && len(parts) > 0 {
+		err := cs.Compose(ctx, key, parts, contentType)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+	return s.store.Put(ctx, key, body, contentType, size)
+}
+
+// chunkReader reads the chunks of an upload one after the other.
+type chunkReader struct {
+	ctx    context.Context
+	store  Storage
+	id     string
+	chunks []int64
+	offset int64
+	cur    io.ReadCloser
+	err    error
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if len(c.chunks) == 0 {
+				return 0, io.EOF
+			}
+			resp, err := c.store.Get(c.ctx, tusChunkKey(c.id, c.offset))
+			if err != nil {
+				c.err = err
+				return 0, err
+			}
+			c.cur = resp.Body
+			c.offset += c.chunks[0]
+			c.chunks = c.chunks[1:]
+		}
+		n, err := c.cur.Read(p)
+		if errors.Is(err, io.EOF) {
+			c.cur.Close()
+			c.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		if err != nil {
+			c.err = err
+		}
+		return n, err
+	}
+}
+
+func (c *chunkReader) Close() error {
+	if c.cur != nil {
+		return c.cur.Close()
+	}
+	return nil
+}
+
+// expireUploads deletes resumable uploads whose expiry has passed.
+func (s *Server) expireUploads(ctx context.Context) error {
+	var expired []string
+	err := s.store.Walk(ctx, uploadPrefix, func(e storage.Entry) error {
+		if path.Base(e.Path) != "info.json" {
+			return nil
+		}
+		id := path.Base(path.Dir(e.Path))
+		u, err := s.loadUpload(ctx, id)
+		if err != nil {
+			s.logger.Warn("read upload", zap.String("id", id), zap.Error(err))
+			return nil
+		}
+		if time.Now().After(u.Expires) {
+			expired = append(expired, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range expired {
+		if err := s.deleteUpload(ctx, id); err != nil {
+			return fmt.Errorf("delete upload %s: %w", id, err)
+		}
+	}
+	if len(expired) > 0 {
+		s.logger.Info("expired resumable uploads", zap.Int("count", len(expired)))
+	}
+	return nil
+}
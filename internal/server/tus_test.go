@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestTusUpload(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	do := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	patch := func(location string, offset int, body string) *httptest.ResponseRecorder {
+		return do(http.MethodPatch, location, body, map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": strconv.Itoa(offset),
+		})
+	}
+
+	if rr := do(http.MethodOptions, "/api/v1/tus", "", nil); rr.Code != http.StatusNoContent || rr.Header().Get("Tus-Version") != "1.0.0" {
+		t.Fatalf("OPTIONS: %d %v", rr.Code, rr.Header())
+	}
+
+	content := "0123456789"
+	rr := do(http.MethodPost, "/api/v1/tus", "", map[string]string{
+		"Upload-Length":   strconv.Itoa(len(content)),
+		"Upload-Metadata": "path " + base64.StdEncoding.EncodeToString([]byte("releases/com/acme/big/1.0/")) + ",filename " + base64.StdEncoding.EncodeToString([]byte("big-1.0.zip")),
+	})
+	location := strings.TrimPrefix(rr.Header().Get("Location"), "http://example.com")
+	if rr.Code != http.StatusCreated || !strings.HasPrefix(location, "/api/v1/tus/") {
+		t.Fatalf("POST: %d %q %s", rr.Code, location, rr.Body.String())
+	}
+
+	if rr := patch(location, 0, content[:4]); rr.Code != http.StatusNoContent || rr.Header().Get("Upload-Offset") != "4" {
+		t.Fatalf("first PATCH: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodHead, location, "", nil); rr.Header().Get("Upload-Offset") != "4" || rr.Header().Get("Upload-Length") != "10" {
+		t.Fatalf("HEAD: %d %v", rr.Code, rr.Header())
+	}
+	if rr := patch(location, 2, content[2:]); rr.Code != http.StatusConflict {
+		t.Fatalf("wrong offset: expected 409, got %d", rr.Code)
+	}
+	if rr := patch(location, 4, content[4:]); rr.Code != http.StatusNoContent || rr.Header().Get("Upload-Offset") != "10" {
+		t.Fatalf("last PATCH: %d %s", rr.Code, rr.Body.String())
+	}
+
+	key := "releases/com/acme/big/1.0/big-1.0.zip"
+	if got := string(store.data[key].body); got != content {
+		t.Fatalf("stored %q", got)
+	}
+	if _, ok := store.data[key+".sha1"]; !ok {
+		t.Fatal("sha1 sidecar missing")
+	}
+	for k := range store.data {
+		if strings.HasPrefix(k, uploadPrefix) {
+			t.Fatalf("upload state left behind: %s", k)
+		}
+	}
+	if rr := do(http.MethodHead, location, "", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("finished upload: expected 404, got %d", rr.Code)
+	}
+
+	if rr := do(http.MethodPost, "/api/v1/tus", "", map[string]string{"Upload-Length": "1", "Upload-Metadata": "path " + base64.StdEncoding.EncodeToString([]byte("__uploads__/x"))}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("internal path: expected 400, got %d", rr.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tus", nil)
+	req.SetBasicAuth("admin", "secret")
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("missing Tus-Resumable: expected 412, got %d", rr.Code)
+	}
+}
+
+func TestExpireUploads(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	for _, u := range []*tusUpload{
+		{ID: "aa", Path: "releases/a.zip", Length: 2, Offset: 1, Chunks: []int64{1}, Expires: time.Now().Add(-time.Minute)},
+		{ID: "bb", Path: "releases/b.zip", Length: 2, Expires: time.Now().Add(time.Hour)},
+	} {
+		if err := srv.saveUpload(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Put(ctx, tusChunkKey("aa", 0), strings.NewReader("x"), "application/octet-stream", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.expireUploads(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.data[tusInfoKey("aa")]; ok {
+		t.Fatal("expired upload kept")
+	}
+	if _, ok := store.data[tusChunkKey("aa", 0)]; ok {
+		t.Fatal("expired chunk kept")
+	}
+	if _, ok := store.data[tusInfoKey("bb")]; !ok {
+		t.Fatal("live upload deleted")
+	}
+}
+
+// cancelStore fails writes whose context is done, like the S3 client.
+type cancelStore struct {
+	*memStore
+}
+
+func (c cancelStore) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.memStore.Put(ctx, key, body, contentType, contentLength)
+}
+
+// droppedBody hands out data, then cancels the request as the server does
+// when the client goes away, and fails the read.
+type droppedBody struct {
+	data   string
+	cancel context.CancelFunc
+}
+
+func (d *droppedBody) Read(p []byte) (int, error) {
+	if d.data == "" {
+		d.cancel()
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, d.data)
+	d.data = d.data[n:]
+	return n, nil
+}
+
+func TestTusPatchKeepsBytesOfDroppedConnection(t *testing.T) {
+	store := cancelStore{newMemStore()}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	ctx := context.Background()
+	u := &tusUpload{ID: strings.Repeat("ab", 16), Path: "releases/a.zip", Length: 10, Expires: time.Now().Add(time.Hour)}
+	if err := srv.saveUpload(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/tus/"+u.ID, &droppedBody{data: "0123", cancel: cancel}).WithContext(reqCtx)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	got, err := srv.loadUpload(ctx, u.ID)
+	if err != nil || got.Offset != 4 {
+		t.Fatalf("expected the 4 bytes that arrived to be kept, got %+v (%v)", got, err)
+	}
+	if string(store.data[tusChunkKey(u.ID, 0)].body) != "0123" {
+		t.Fatal("chunk of the dropped PATCH not stored")
+	}
+}
+
+// composeStore joins parts like storage.Store.Compose and records the keys
+// it composed.
+type composeStore struct {
+	*memStore
+	composed []string
+}
+
+func (c *composeStore) Compose(ctx context.Context, key string, parts []storage.Part, contentType string) error {
+	var data []byte
+	for _, p := range parts {
+		data = append(data, c.data[p.Key].body...)
+	}
+	c.composed = append(c.composed, key)
+	return c.memStore.Put(ctx, key, bytes.NewReader(data), contentType, int64(len(data)))
+}
+
+func TestTusCompletesByComposingChunks(t *testing.T) {
+	store := &composeStore{memStore: newMemStore()}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	ctx := context.Background()
+	u := &tusUpload{ID: strings.Repeat("cd", 16), Path: "releases/a.zip", Length: 10, Expires: time.Now().Add(time.Hour)}
+	if err := srv.saveUpload(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range []struct {
+		offset int
+		data   string
+	}{{0, "0123"}, {4, "456789"}} {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/tus/"+u.ID, strings.NewReader(chunk.data))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+		req.Header.Set("Upload-Offset", strconv.Itoa(chunk.offset))
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("PATCH at %d: %d %s", chunk.offset, rr.Code, rr.Body.String())
+		}
+	}
+
+	if len(store.composed) != 1 || store.composed[0] != "releases/a.zip" {
+		t.Fatalf("expected the artifact to be composed from its chunks, composed %v", store.composed)
+	}
+	if got := string(store.data["releases/a.zip"].body); got != "0123456789" {
+		t.Fatalf("stored %q", got)
+	}
+	if _, ok := store.data["releases/a.zip.sha1"]; !ok {
+		t.Fatal("sha1 sidecar missing")
+	}
+}
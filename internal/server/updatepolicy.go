@@ -0,0 +1,150 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultUpdateInterval = 24 * time.Hour
+	// maxMissEntries bounds the negative cache; it is reset when full.
+	maxMissEntries = 100000
+)
+
+// updatePolicy mirrors Maven's repository updatePolicy: how long upstream
+// misses and cached metadata are trusted before upstream is asked again.
+type updatePolicy struct {
+	always   bool
+	never    bool
+	interval time.Duration
+}
+
+// parseUpdatePolicy accepts always, never, daily (the default) and
+// interval:N with N in minutes.
+func parseUpdatePolicy(s string) (updatePolicy, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); {
+	case s == "" || s == "daily":
+		return updatePolicy{interval: defaultUpdateInterval}, nil
+	case s == "always":
+		return updatePolicy{always: true}, nil
+	case s == "never":
+		return updatePolicy{never: true}, nil
+	case strings.HasPrefix(s, "interval:"):
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "interval:"))
+		if err != nil || n <= 0 {
+			return updatePolicy{}, fmt.Errorf("invalid updatePolicy interval %q", s)
+		}
+		return updatePolicy{interval: time.Duration(n) * time.Minute}, nil
+	}
+	return updatePolicy{}, fmt.Errorf("invalid updatePolicy %q; expected always, never, daily or interval:N", s)
+}
+
+// expired reports whether something fetched at the given time must be
+// checked against upstream again.
+func (u updatePolicy) expired(at, now time.Time) bool {
+	switch {
+	case u.always:
+		return true
+	case u.never:
+		return false
+	}
+	return now.Sub(at) >= u.interval
+}
+
+func (pr Proxy) updatePolicy() updatePolicy {
+	u, err := parseUpdatePolicy(pr.UpdatePolicy)
+	if err != nil {
+		return updatePolicy{interval: defaultUpdateInterval}
+	}
+	return u
+}
+
+// stale reports whether a cached copy of artifactPath taken at cachedAt must
+// be revalidated: metadata follows the update policy, everything else MaxAge.
+func (pr Proxy) stale(artifactPath string, cachedAt, now time.Time) bool {
+	if isMetadataPath(artifactPath) {
+		return pr.updatePolicy().expired(cachedAt, now)
+	}
+	maxAge := pr.maxAge()
+	return maxAge > 0 && now.Sub(cachedAt) >= maxAge
+}
+
+// isMetadataPath matches maven-metadata.xml files and their sidecars.
+func isMetadataPath(p string) bool {
+	return strings.HasPrefix(path.Base(p), "maven-metadata")
+}
+
+// missCache remembers upstream 404s per proxy key so repeated lookups of
+// missing artifacts do not hit upstream until the update policy expires.
+type missCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func newMissCache() *missCache {
+	return &missCache{entries: make(map[string]time.Time)}
+}
+
+func (c *missCache) known(key string, policy updatePolicy, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	at, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if policy.expired(at, now) {
+		delete(c.entries, key)
+		return false
+	}
+	return true
+}
+
+func (c *missCache) record(key string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxMissEntries {
+		c.entries = make(map[string]time.Time)
+	}
+	c.entries[key] = now
+}
+
+// forget drops every remembered miss of a proxy.
+func (c *missCache) forget(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, name+"/") {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// drop forgets the miss of one key, e.g. once it exists in the bucket.
+func (c *missCache) drop(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// reset drops every remembered miss.
+func (c *missCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]time.Time)
+}
+
+// knownMiss reports whether upstream recently answered 404 for artifactPath.
+func (p *ProxyManager) knownMiss(pr Proxy, artifactPath string) bool {
+	return p.misses.known(path.Join(pr.Name, artifactPath), pr.updatePolicy(), time.Now())
+}
+
+func (p *ProxyManager) recordMiss(pr Proxy, artifactPath string) {
+	if pr.updatePolicy().always {
+		return
+	}
+	p.misses.record(path.Join(pr.Name, artifactPath), time.Now())
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestParseUpdatePolicy(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		spec    string
+		age     time.Duration
+		expired bool
+	}{
+		{"", 23 * time.Hour, false},
+		{"daily", 25 * time.Hour, true},
+		{"always", 0, true},
+		{"never", 1000 * time.Hour, false},
+		{"interval:30", 20 * time.Minute, false},
+		{"interval:30", 31 * time.Minute, true},
+	}
+	for _, tc := range cases {
+		u, err := parseUpdatePolicy(tc.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.spec, err)
+		}
+		if got := u.expired(now.Add(-tc.age), now); got != tc.expired {
+			t.Fatalf("%q aged %s: expired=%v, want %v", tc.spec, tc.age, got, tc.expired)
+		}
+	}
+	for _, bad := range []string{"hourly", "interval:", "interval:0", "interval:x"} {
+		if _, err := parseUpdatePolicy(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestProxyCachesUpstreamMisses(t *testing.T) {
+	var calls atomic.Int32
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer remote.Close()
+
+	ctx := context.Background()
+	pm := NewProxyManager(newMemStore(), zaptest.NewLogger(t))
+	if err := pm.Add(ctx, Proxy{Name: "central", URL: remote.URL}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+	if err := pm.Add(ctx, Proxy{Name: "snapshots", URL: remote.URL, UpdatePolicy: "always"}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if found, err := pm.FetchAndCache(ctx, "central/com/acme/missing/1.0/missing-1.0.jar"); err != nil || found {
+			t.Fatalf("fetch: found=%v err=%v", found, err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected one upstream call for a cached miss, got %d", got)
+	}
+
+	calls.Store(0)
+	for i := 0; i < 3; i++ {
+		_, _ = pm.FetchAndCache(ctx, "snapshots/com/acme/missing/1.0/missing-1.0.jar")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("updatePolicy=always must not cache misses, got %d calls", got)
+	}
+
+	// invalidating a proxy forgets its misses
+	calls.Store(0)
+	if _, err := pm.Invalidate(ctx, "central", InvalidateRequest{Path: "com/acme/missing"}); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	_, _ = pm.FetchAndCache(ctx, "central/com/acme/missing/1.0/missing-1.0.jar")
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected upstream call after invalidate, got %d", got)
+	}
+}
+
+func TestProxyMetadataFollowsUpdatePolicy(t *testing.T) {
+	now := time.Now()
+	daily := Proxy{Name: "central"}
+	if daily.stale("com/acme/app/maven-metadata.xml", now.Add(-time.Hour), now) {
+		t.Fatalf("metadata younger than a day should be fresh")
+	}
+	if !daily.stale("com/acme/app/maven-metadata.xml.sha1", now.Add(-25*time.Hour), now) {
+		t.Fatalf("metadata older than a day should be revalidated")
+	}
+	if daily.stale("com/acme/app/1.0/app-1.0.jar", now.Add(-1000*time.Hour), now) {
+		t.Fatalf("artifacts without maxAge never expire")
+	}
+	never := Proxy{Name: "central", UpdatePolicy: "never", MaxAge: "1h"}
+	if never.stale("com/acme/app/maven-metadata.xml", now.Add(-1000*time.Hour), now) {
+		t.Fatalf("updatePolicy=never should keep metadata")
+	}
+	if !never.stale("com/acme/app/1.0/app-1.0.jar", now.Add(-2*time.Hour), now) {
+		t.Fatalf("maxAge still applies to artifacts")
+	}
+}
+
+func TestProxyAddRejectsInvalidUpdatePolicy(t *testing.T) {
+	pm := NewProxyManager(newMemStore(), zaptest.NewLogger(t))
+	if err := pm.Add(context.Background(), Proxy{Name: "central", URL: "https://example.com", UpdatePolicy: "hourly"}); err == nil {
+		t.Fatalf("expected invalid updatePolicy to be rejected")
+	}
+}
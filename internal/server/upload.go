@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// uploadMemory is how much of a multipart upload is kept in memory; the
+// rest is spooled to temporary files.
+const uploadMemory = 32 << 20
+
+// uploadWriter buffers the response of the artifact PUT path so a form
+// upload can set properties before answering and turn its plain-text
+// errors into JSON ones.
+type uploadWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (u *uploadWriter) WriteHeader(status int) {
+	if u.status == 0 {
+		u.status = status
+	}
+}
+
+func (u *uploadWriter) Write(b []byte) (int, error) {
+	u.WriteHeader(http.StatusOK)
+	return u.body.Write(b)
+}
+
+func (u *uploadWriter) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+// problem returns the problem document of a failed upload, or one built
+// from the status when the PUT path answered without a body.
+func (u *uploadWriter) problem() ErrorResponse {
+	var p ErrorResponse
+	if json.Unmarshal(u.body.Bytes(), &p) != nil || p.Status == 0 {
+		p = ErrorResponse{Type: "about:blank", Status: u.status, Detail: http.StatusText(u.status)}
+	}
+	return p
+}
+
+// @Summary Upload an artifact from a form
+// @Description Stores the file field of a multipart/form-data body under path, like a PUT of the artifact path: the same layout, policy, antivirus and signing checks apply and the same JSON describes the stored file. A path ending in / takes the file name of the part. Each property field (name=value) is set on the artifact. Deploy tokens need write scope for the path.
+// @Tags artifacts
+// @Accept mpfd
+// @Produce json
+// @Param file formData file true "Artifact content"
+// @Param path formData string true "Target path, e.g. releases/com/acme/lib/1.0/lib-1.0.jar"
+// @Param property formData string false "Property as name=value; repeatable"
+// @Success 201 {object} DeployResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/upload [post]
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
+		writeAPIError(w, "expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeAPIError(w, "file field is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	key := strings.TrimPrefix(r.FormValue("path"), "/")
+	if strings.HasSuffix(key, "/") {
+		key += path.Base(header.Filename)
+	}
+	if key == "" || strings.HasSuffix(key, "/") || strings.Contains(key, "..") || isInternalPath(key) || strings.HasPrefix(key, "api/") {
+		writeAPIError(w, "path must name an artifact", http.StatusBadRequest)
+		return
+	}
+	props := map[string]string{}
+	for _, p := range r.MultipartForm.Value["property"] {
+		name, value, ok := strings.Cut(p, "=")
+		if name = strings.TrimSpace(name); !ok || name == "" || value == "" {
+			writeAPIError(w, "property must be name=value: "+p, http.StatusBadRequest)
+			return
+		}
+		props[name] = value
+	}
+
+	uw := s.storeUpload(w, r, key, file, header.Size, header.Header.Get("Content-Type"))
+	if uw.status >= http.StatusBadRequest {
+		p := uw.problem()
+		writeProblem(w, p.Type, uw.status, p.Detail)
+		return
+	}
+	if len(props) > 0 {
+		if err := setProperties(r.Context(), s.store, key, props); err != nil {
+			s.logger.Warn("set upload properties", zap.String("path", key), zap.Error(err))
+		}
+	}
+	w.WriteHeader(uw.status)
+	_, _ = w.Write(uw.body.Bytes())
+}
+
+// storeUpload sends body through the artifact PUT path as if it had been
+// PUT to key by the caller of r, and returns the buffered response.
+func (s *Server) storeUpload(w http.ResponseWriter, r *http.Request, key string, body io.Reader, size int64, contentType string) *uploadWriter {
+	put := r.Clone(r.Context())
+	put.Method = http.MethodPut
+	put.URL.Path = "/" + key
+	put.URL.RawPath = ""
+	put.URL.RawQuery = ""
+	put.Body = io.NopCloser(body)
+	put.ContentLength = size
+	put.Header.Set("Content-Type", contentType)
+	put.Header.Del("Content-Length")
+
+	uw := &uploadWriter{ResponseWriter: w}
+	s.handleObject(uw, put)
+	if uw.status == 0 {
+		uw.status = http.StatusOK
+	}
+	return uw
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHandleUpload(t *testing.T) {
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "admin", "secret")
+	upload := func(path string, props ...string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("path", path)
+		for _, p := range props {
+			_ = mw.WriteField("property", p)
+		}
+		fw, _ := mw.CreateFormFile("file", "lib-1.0.jar")
+		_, _ = fw.Write([]byte("data"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := upload("releases/com/acme/lib/1.0/", "team=platform")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("upload: %d %s", rr.Code, rr.Body.String())
+	}
+	var resp DeployResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	key := "releases/com/acme/lib/1.0/lib-1.0.jar"
+	if resp.Key != key || resp.Size != 4 || rr.Header().Get("Location") == "" {
+		t.Fatalf("response = %+v", resp)
+	}
+	if got := string(store.data[key].body); got != "data" {
+		t.Fatalf("stored %q", got)
+	}
+	if _, ok := store.data[key+".sha1"]; !ok {
+		t.Fatal("sha1 sidecar missing")
+	}
+	props, err := loadProperties(context.Background(), store, key)
+	if err != nil || props["team"] != "platform" {
+		t.Fatalf("properties = %v, %v", props, err)
+	}
+
+	for _, path := range []string{"", "__properties__/x.jar", "releases/../x.jar", "api/v1/x"} {
+		if rr := upload(path); rr.Code != http.StatusBadRequest {
+			t.Fatalf("path %q: expected 400, got %d", path, rr.Code)
+		}
+	}
+	if rr := upload("releases/a.jar", "novalue"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad property: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestHandleUploadJSONErrors(t *testing.T) {
+	store := writeOnceStore{newMemStore()}
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	upload := func(content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("path", "releases/a/1.0/a-1.0.jar")
+		fw, _ := mw.CreateFormFile("file", "a-1.0.jar")
+		_, _ = fw.Write([]byte(content))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := upload("one"); rr.Code != http.StatusCreated {
+		t.Fatalf("first upload: %d %s", rr.Code, rr.Body.String())
+	}
+	rr := upload("two")
+	var resp ErrorResponse
+	if rr.Code != http.StatusConflict || json.Unmarshal(rr.Body.Bytes(), &resp) != nil || resp.Status != http.StatusConflict {
+		t.Fatalf("overwrite: %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// uploadBody is an upload read in full before it is stored, so it can be
+// scanned, signed and read again.
+type uploadBody interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// bufferUpload reads the n bytes of body, writing them to sums as they
+// come. Bodies of at most s.uploadMemoryMax bytes stay in memory, which
+// spares POMs, checksums and metadata a temp file; larger ones go to disk.
+// release frees the buffer. Like io.CopyN, a body shorter than n is not an
+// error.
+func (s *Server) bufferUpload(body io.Reader, n int64, sums io.Writer) (buf uploadBody, release func(), err error) {
+	body = io.TeeReader(body, sums)
+	if n <= s.uploadMemoryMax {
+		b := make([]byte, n)
+		read, err := io.ReadFull(body, b)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, err
+		}
+		return bytes.NewReader(b[:read]), func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "heimdall-upload-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	release = func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	if _, err := io.CopyN(tmp, body, n); err != nil && !errors.Is(err, io.EOF) {
+		release()
+		return nil, nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return tmp, release, nil
+}
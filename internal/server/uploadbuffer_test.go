@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBufferUploadKeepsSmallBodiesInMemory(t *testing.T) {
+	s := NewWithOptions(newMemStore(), zaptest.NewLogger(t), nil, Options{UploadMemoryMax: 8})
+	for _, tc := range []struct {
+		data   string
+		inFile bool
+	}{
+		{"<pom/>", false},
+		{"0123456789abcdef", true},
+	} {
+		sums := storage.NewChecksums("sha1")
+		body, release, err := s.bufferUpload(strings.NewReader(tc.data), int64(len(tc.data)), sums)
+		if err != nil {
+			t.Fatalf("buffer %q: %v", tc.data, err)
+		}
+		if _, ok := body.(*os.File); ok != tc.inFile {
+			t.Errorf("buffer %q: in temp file %v, want %v", tc.data, ok, tc.inFile)
+		}
+		got, _ := io.ReadAll(body)
+		if !bytes.Equal(got, []byte(tc.data)) {
+			t.Errorf("buffer %q: read back %q", tc.data, got)
+		}
+		if want := sha1Hex(tc.data); sums.Sum("sha1") != want {
+			t.Errorf("buffer %q: sha1 %s, want %s", tc.data, sums.Sum("sha1"), want)
+		}
+		release()
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+)
+
+// errUpstreamBusy is returned when no upstream slot freed up in time.
+var errUpstreamBusy = errors.New("too many concurrent upstream downloads")
+
+// UpstreamLimits bounds simultaneous upstream downloads per proxy and in
+// total. Fetches beyond a limit wait up to QueueTimeout for a slot and fail
+// with errUpstreamBusy after it; zero fails them at once.
+type UpstreamLimits struct {
+	PerProxy     int
+	Global       int
+	QueueTimeout time.Duration
+}
+
+type upstreamLimiter struct {
+	limits  UpstreamLimits
+	global  chan struct{}
+	metrics *metrics.Registry
+
+	mu      sync.Mutex
+	proxies map[string]chan struct{}
+}
+
+// newUpstreamLimiter returns nil when neither limit is set.
+func newUpstreamLimiter(limits UpstreamLimits, m *metrics.Registry) *upstreamLimiter {
+	if limits.PerProxy <= 0 && limits.Global <= 0 {
+		return nil
+	}
+	l := &upstreamLimiter{limits: limits, metrics: m, proxies: make(map[string]chan struct{})}
+	if limits.Global > 0 {
+		l.global = make(chan struct{}, limits.Global)
+	}
+	return l
+}
+
+func (l *upstreamLimiter) proxySlots(name string) chan struct{} {
+	if l.limits.PerProxy <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.proxies[name]
+	if !ok {
+		slots = make(chan struct{}, l.limits.PerProxy)
+		l.proxies[name] = slots
+	}
+	return slots
+}
+
+// acquire takes a slot of proxy, and a global one, and returns the function
+// giving them back. Per-proxy slots are taken first so a busy proxy does not
+// hold global slots while it waits.
+func (l *upstreamLimiter) acquire(ctx context.Context, proxy string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	var timeout <-chan time.Time
+	if l.limits.QueueTimeout > 0 {
+		timer := time.NewTimer(l.limits.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var held []chan struct{}
+	release := func() {
+		for _, slots := range held {
+			<-slots
+		}
+	}
+	for _, slots := range []chan struct{}{l.proxySlots(proxy), l.global} {
+		if slots == nil {
+			continue
+		}
+		if err := l.take(ctx, slots, timeout); err != nil {
+			release()
+			if errors.Is(err, errUpstreamBusy) && l.metrics != nil {
+				l.metrics.UpstreamRejected.WithLabelValues(proxy).Inc()
+			}
+			return nil, err
+		}
+		held = append(held, slots)
+	}
+	if l.metrics != nil {
+		l.metrics.UpstreamInFlight.WithLabelValues(proxy).Inc()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			release()
+			if l.metrics != nil {
+				l.metrics.UpstreamInFlight.WithLabelValues(proxy).Dec()
+			}
+		})
+	}, nil
+}
+
+func (l *upstreamLimiter) take(ctx context.Context, slots chan struct{}, timeout <-chan time.Time) error {
+	select {
+	case slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if timeout == nil {
+		return errUpstreamBusy
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return errUpstreamBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releasingBody gives the upstream slot back when the response body is
+// closed, so the slot covers the whole download.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestUpstreamConcurrencyLimit(t *testing.T) {
+	started := make(chan struct{}, 4)
+	unblock := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-unblock
+		_, _ = w.Write([]byte("jar"))
+	}))
+	defer upstream.Close()
+
+	m := metrics.New()
+	srv := NewWithOptions(newMemStore(), zaptest.NewLogger(t), m, Options{UpstreamLimits: UpstreamLimits{PerProxy: 1}})
+	if err := srv.proxy.Add(context.Background(), Proxy{Name: "central", URL: upstream.URL}); err != nil {
+		t.Fatalf("add proxy: %v", err)
+	}
+
+	get := func(p string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/central/"+p, nil))
+		return rec
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- get("com/acme/a/1.0/a-1.0.jar") }()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream was not called")
+	}
+
+	rec := get("com/acme/b/1.0/b-1.0.jar")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After while the slot is taken, got %d", rec.Code)
+	}
+	if got := testutil.ToFloat64(m.UpstreamRejected.WithLabelValues("central")); got != 1 {
+		t.Fatalf("expected 1 rejected fetch, got %v", got)
+	}
+
+	close(unblock)
+	if rec := <-first; rec.Code != http.StatusOK {
+		t.Fatalf("expected the first download to succeed, got %d", rec.Code)
+	}
+	if got := testutil.ToFloat64(m.UpstreamInFlight.WithLabelValues("central")); got != 0 {
+		t.Fatalf("expected the slot to be released, %v in flight", got)
+	}
+	if rec := get("com/acme/b/1.0/b-1.0.jar"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 once the slot is free, got %d", rec.Code)
+	}
+}
+
+func TestUpstreamLimiterQueues(t *testing.T) {
+	l := newUpstreamLimiter(UpstreamLimits{Global: 1, QueueTimeout: time.Second}, nil)
+	release, err := l.acquire(context.Background(), "central")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	release2, err := l.acquire(context.Background(), "other")
+	if err != nil {
+		t.Fatalf("expected the queued fetch to get the freed slot, got %v", err)
+	}
+	release2()
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// RepositoryUsage is the stored footprint of one repository, checksum
+// sidecars included.
+type RepositoryUsage struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Objects int64  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+type UsageReport struct {
+	GeneratedAt  time.Time         `json:"generatedAt"`
+	Repositories []RepositoryUsage `json:"repositories"`
+	Total        RepositoryUsage   `json:"total"`
+}
+
+// refreshUsage walks every repository and replaces the cached report and the
+// heimdall_repo_* gauges; it is the "usage-report" task.
+func (s *Server) refreshUsage(ctx context.Context) (*UsageReport, error) {
+	repos, err := s.listRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &UsageReport{GeneratedAt: time.Now().UTC(), Total: RepositoryUsage{Name: "total"}}
+	for _, repo := range repos {
+		u, err := s.repoUsage(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		report.Repositories = append(report.Repositories, u)
+		report.Total.Objects += u.Objects
+		report.Total.Bytes += u.Bytes
+	}
+
+	s.usageMu.Lock()
+	s.usage = report
+	s.usageMu.Unlock()
+	if s.metrics != nil {
+		// Reset so deleted repositories stop being exported.
+		s.metrics.RepoObjects.Reset()
+		s.metrics.RepoBytes.Reset()
+		for _, u := range report.Repositories {
+			s.metrics.RepoObjects.WithLabelValues(u.Name, u.Type).Set(float64(u.Objects))
+			s.metrics.RepoBytes.WithLabelValues(u.Name, u.Type).Set(float64(u.Bytes))
+		}
+	}
+	s.logger.Info("usage report generated", zap.Int("repositories", len(report.Repositories)), zap.Int64("bytes", report.Total.Bytes))
+	return report, nil
+}
+
+func (s *Server) repoUsage(ctx context.Context, repo repositoryInfo) (RepositoryUsage, error) {
+	u := RepositoryUsage{Name: repo.Name, Type: repo.Type}
+	err := walkStore(ctx, s.store, repo.Name+"/", func(e storage.Entry) error {
+		u.Objects++
+		u.Bytes += e.Size
+		return nil
+	})
+	return u, err
+}
+
+// @Summary Storage usage per repository
+// @Description Object count and total bytes per repository from the last scheduled walk (USAGE_REPORT_INTERVAL). Computed on demand when no report exists yet or with refresh=true.
+// @Tags catalog
+// @Produce json
+// @Param refresh query bool false "Walk the bucket now instead of returning the cached report"
+// @Success 200 {object} UsageReport
+// @Failure 400 {object} ErrorResponse
+// @Security BasicAuth
+// @Router /api/v1/usage [get]
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	refresh := false
+	if v := r.URL.Query().Get("refresh"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeAPIError(w, "invalid refresh", http.StatusBadRequest)
+			return
+		}
+		refresh = b
+	}
+
+	s.usageMu.Lock()
+	report := s.usage
+	s.usageMu.Unlock()
+	if report == nil || refresh {
+		var err error
+		if report, err = s.refreshUsage(r.Context()); err != nil {
+			s.writeError(w, "usage report", err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		s.logger.Warn("encode usage report", zap.Error(err))
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestUsageReport(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	put := func(key, body string) {
+		t.Helper()
+		if err := store.Put(ctx, key, strings.NewReader(body), "application/octet-stream", int64(len(body))); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	put("releases/com/acme/app/1.0/app-1.0.jar", "12345")
+	put("releases/com/acme/app/1.0/app-1.0.jar.sha1", "abc")
+	put("snapshots/com/acme/app/1.1-SNAPSHOT/app.jar", "1")
+	put(propertiesPrefix+"releases/com/acme/app/1.0/app-1.0.jar.json", "{}")
+	m := metrics.New()
+	srv := New(store, zaptest.NewLogger(t), m, "admin", "secret")
+
+	get := func(target string) UsageReport {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, rr.Code, rr.Body.String())
+		}
+		var report UsageReport
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return report
+	}
+
+	report := get("/api/v1/usage")
+	if len(report.Repositories) != 2 {
+		t.Fatalf("unexpected repositories %+v", report.Repositories)
+	}
+	if r := report.Repositories[0]; r.Name != "releases" || r.Type != "hosted" || r.Objects != 2 || r.Bytes != 8 {
+		t.Fatalf("unexpected releases usage %+v", r)
+	}
+	if report.Total.Objects != 3 || report.Total.Bytes != 9 {
+		t.Fatalf("unexpected total %+v", report.Total)
+	}
+
+	// Served from the cached report until refreshed.
+	put("snapshots/com/acme/app/1.1-SNAPSHOT/app.pom", "pom")
+	if report := get("/api/v1/usage"); report.Total.Objects != 3 {
+		t.Fatalf("expected cached report, got %+v", report.Total)
+	}
+	if report := get("/api/v1/usage?refresh=true"); report.Total.Objects != 4 || report.Total.Bytes != 12 {
+		t.Fatalf("expected refreshed report, got %+v", report.Total)
+	}
+
+	if got := testutil.ToFloat64(m.RepoBytes.WithLabelValues("snapshots", "hosted")); got != 4 {
+		t.Fatalf("expected snapshots bytes gauge 4, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.RepoObjects.WithLabelValues("releases", "hosted")); got != 2 {
+		t.Fatalf("expected releases objects gauge 2, got %v", got)
+	}
+}
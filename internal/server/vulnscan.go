@@ -0,0 +1,290 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ScanTarget identifies an artifact submitted to a vulnerability scanner.
+type ScanTarget struct {
+	Key        string `json:"path"`
+	GroupID    string `json:"groupId"`
+	ArtifactID string `json:"artifactId"`
+	Version    string `json:"version"`
+	PURL       string `json:"purl"`
+}
+
+type Vulnerability struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title,omitempty"`
+	Score    float64 `json:"score,omitempty"`
+	Severity string  `json:"severity,omitempty"`
+}
+
+type ScanReport struct {
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+func (r ScanReport) MaxSeverity() string {
+	max := ""
+	for _, v := range r.Vulnerabilities {
+		sev := v.Severity
+		if sev == "" {
+			sev = severityForScore(v.Score)
+		}
+		if severityRank(sev) > severityRank(max) {
+			max = sev
+		}
+	}
+	return max
+}
+
+// VulnScanner checks an artifact for known vulnerabilities.
+type VulnScanner interface {
+	Scan(ctx context.Context, target ScanTarget) (ScanReport, error)
+}
+
+var severityLevels = []string{"low", "medium", "high", "critical"}
+
+func severityRank(s string) int {
+	for i, level := range severityLevels {
+		if strings.EqualFold(s, level) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func severityForScore(score float64) string {
+	switch {
+	case score >= 9:
+		return "critical"
+	case score >= 7:
+		return "high"
+	case score >= 4:
+		return "medium"
+	case score > 0:
+		return "low"
+	}
+	return ""
+}
+
+// OSSIndexScanner queries Sonatype OSS Index by package URL.
+type OSSIndexScanner struct {
+	URL    string
+	User   string
+	Token  string
+	client *http.Client
+}
+
+func NewOSSIndexScanner(url, user, token string) *OSSIndexScanner {
+	if url == "" {
+		url = "https://ossindex.sonatype.org"
+	}
+	return &OSSIndexScanner{URL: strings.TrimSuffix(url, "/"), User: user, Token: token, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (o *OSSIndexScanner) Scan(ctx context.Context, target ScanTarget) (ScanReport, error) {
+	body, err := json.Marshal(map[string][]string{"coordinates": {target.PURL}})
+	if err != nil {
+		return ScanReport{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL+"/api/v3/component-report", bytes.NewReader(body))
+	if err != nil {
+		return ScanReport{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.User != "" {
+		req.SetBasicAuth(o.User, o.Token)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return ScanReport{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return ScanReport{}, fmt.Errorf("oss index: status %d", resp.StatusCode)
+	}
+
+	var reports []struct {
+		Vulnerabilities []struct {
+			ID        string  `json:"id"`
+			CVE       string  `json:"cve"`
+			Title     string  `json:"title"`
+			CVSSScore float64 `json:"cvssScore"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+		return ScanReport{}, fmt.Errorf("decode oss index report: %w", err)
+	}
+	var report ScanReport
+	for _, r := range reports {
+		for _, v := range r.Vulnerabilities {
+			id := v.CVE
+			if id == "" {
+				id = v.ID
+			}
+			report.Vulnerabilities = append(report.Vulnerabilities, Vulnerability{
+				ID:       id,
+				Title:    v.Title,
+				Score:    v.CVSSScore,
+				Severity: severityForScore(v.CVSSScore),
+			})
+		}
+	}
+	return report, nil
+}
+
+// WebhookScanner posts the ScanTarget as JSON and expects a ScanReport back,
+// which makes it easy to front Trivy or an in-house scanner.
+type WebhookScanner struct {
+	URL    string
+	client *http.Client
+}
+
+func NewWebhookScanner(url string) *WebhookScanner {
+	return &WebhookScanner{URL: url, client: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+func (h *WebhookScanner) Scan(ctx context.Context, target ScanTarget) (ScanReport, error) {
+	body, err := json.Marshal(target)
+	if err != nil {
+		return ScanReport{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return ScanReport{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return ScanReport{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return ScanReport{}, fmt.Errorf("scanner webhook: status %d", resp.StatusCode)
+	}
+	var report ScanReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return ScanReport{}, fmt.Errorf("decode scan report: %w", err)
+	}
+	return report, nil
+}
+
+// ScanQueue scans newly ingested artifacts asynchronously, records the result
+// as artifact properties and quarantines artifacts at or above Threshold.
+type ScanQueue struct {
+	scanner   VulnScanner
+	store     Storage
+	logger    *zap.Logger
+	threshold string
+	queue     chan scanJob
+	errors    *ErrorReporter
+}
+
+type scanJob struct {
+	key  string
+	repo string
+}
+
+func NewScanQueue(scanner VulnScanner, store Storage, logger *zap.Logger, threshold string, size int) *ScanQueue {
+	if size <= 0 {
+		size = 100
+	}
+	return &ScanQueue{
+		scanner:   scanner,
+		store:     store,
+		logger:    logger,
+		threshold: strings.ToLower(threshold),
+		queue:     make(chan scanJob, size),
+	}
+}
+
+// Submit enqueues key for scanning; repo is the leading repository or proxy
+// segment that is not part of the groupId. Non-artifact files are ignored and
+// a full queue drops the job rather than blocking the request.
+func (q *ScanQueue) Submit(repo, key string) {
+	if q == nil || !isScannable(key) {
+		return
+	}
+	select {
+	case q.queue <- scanJob{key: key, repo: repo}:
+	default:
+		q.logger.Warn("vulnerability scan queue full; skipping", zap.String("key", key))
+	}
+}
+
+func isScannable(key string) bool {
+	base := path.Base(key)
+	if isChecksumPath(base) || strings.HasSuffix(base, ".asc") || strings.HasPrefix(base, "maven-metadata") {
+		return false
+	}
+	_, _, _, ok := parseCoordinates(key)
+	return ok
+}
+
+func (q *ScanQueue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-q.queue:
+			if err := q.scan(ctx, job); err != nil {
+				q.logger.Warn("vulnerability scan failed", zap.String("key", job.key), zap.Error(err))
+				q.errors.ReportTask("vuln-scan", "vulnerability scan failed", err, map[string]string{"key": job.key})
+			}
+		}
+	}
+}
+
+func (q *ScanQueue) scan(ctx context.Context, job scanJob) error {
+	rel := strings.TrimPrefix(strings.TrimPrefix(job.key, job.repo), "/")
+	group, artifact, version, ok := parseCoordinates(rel)
+	if !ok {
+		return nil
+	}
+	target := ScanTarget{
+		Key:        job.key,
+		GroupID:    group,
+		ArtifactID: artifact,
+		Version:    version,
+		PURL:       fmt.Sprintf("pkg:maven/%s/%s@%s", group, artifact, version),
+	}
+	report, err := q.scanner.Scan(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(report.Vulnerabilities))
+	for _, v := range report.Vulnerabilities {
+		ids = append(ids, v.ID)
+	}
+	maxSeverity := report.MaxSeverity()
+	props := map[string]string{
+		"vuln.scannedAt":   time.Now().UTC().Format(time.RFC3339),
+		"vuln.count":       strconv.Itoa(len(report.Vulnerabilities)),
+		"vuln.maxSeverity": maxSeverity,
+		"vuln.ids":         strings.Join(ids, ","),
+	}
+	if err := setProperties(ctx, q.store, job.key, props); err != nil {
+		return err
+	}
+
+	if q.threshold != "" && severityRank(maxSeverity) >= severityRank(q.threshold) && severityRank(maxSeverity) > 0 {
+		reason := fmt.Sprintf("vulnerability scan found %s severity issues (%s)", maxSeverity, strings.Join(ids, ", "))
+		if err := quarantine(ctx, q.store, job.key, reason); err != nil {
+			return err
+		}
+		q.logger.Warn("artifact quarantined", zap.String("key", job.key), zap.String("reason", reason))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+type stubScanner struct {
+	report  ScanReport
+	targets []ScanTarget
+}
+
+func (s *stubScanner) Scan(_ context.Context, target ScanTarget) (ScanReport, error) {
+	s.targets = append(s.targets, target)
+	return s.report, nil
+}
+
+func TestScanQueueRecordsAndQuarantines(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	key := "releases/com/acme/app/1.0/app-1.0.jar"
+	if err := store.Put(ctx, key, strings.NewReader("jar"), "application/java-archive", 3); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	scanner := &stubScanner{report: ScanReport{Vulnerabilities: []Vulnerability{
+		{ID: "CVE-2021-44228", Score: 10},
+		{ID: "CVE-2020-0001", Score: 5.3},
+	}}}
+	q := NewScanQueue(scanner, store, zaptest.NewLogger(t), "high", 1)
+	if err := q.scan(ctx, scanJob{key: key, repo: "releases"}); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	if len(scanner.targets) != 1 || scanner.targets[0].PURL != "pkg:maven/com.acme/app@1.0" {
+		t.Fatalf("unexpected targets %+v", scanner.targets)
+	}
+	props, err := loadProperties(ctx, store, key)
+	if err != nil {
+		t.Fatalf("load properties: %v", err)
+	}
+	if props["vuln.count"] != "2" || props["vuln.maxSeverity"] != "critical" {
+		t.Fatalf("unexpected properties %v", props)
+	}
+	var qerr QuarantinedError
+	if err := checkQuarantine(ctx, store, key); !errors.As(err, &qerr) {
+		t.Fatalf("expected quarantined artifact, got %v", err)
+	}
+}
+
+func TestScanQueueSubmitSkipsSidecars(t *testing.T) {
+	q := NewScanQueue(&stubScanner{}, newMemStore(), zaptest.NewLogger(t), "", 4)
+	q.Submit("releases", "releases/com/acme/app/1.0/app-1.0.jar.sha1")
+	q.Submit("releases", "releases/com/acme/app/maven-metadata.xml")
+	q.Submit("releases", "releases/com/acme/app/1.0/app-1.0.jar")
+	if len(q.queue) != 1 {
+		t.Fatalf("expected 1 queued job, got %d", len(q.queue))
+	}
+}
+
+func TestOSSIndexScannerMapsSeverity(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/component-report" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]map[string]any{{
+			"coordinates":     "pkg:maven/com.acme/app@1.0",
+			"vulnerabilities": []map[string]any{{"id": "x", "cve": "CVE-1", "cvssScore": 7.5}},
+		}})
+	}))
+	defer srv.Close()
+
+	report, err := NewOSSIndexScanner(srv.URL, "", "").Scan(context.Background(), ScanTarget{PURL: "pkg:maven/com.acme/app@1.0"})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(report.Vulnerabilities) != 1 || report.Vulnerabilities[0].ID != "CVE-1" || report.MaxSeverity() != "high" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/otoru/heimdall/internal/storage"
+	"go.uber.org/zap"
+)
+
+// warmupWorkers bounds the concurrent fetches of a warm-up, which runs next
+// to client traffic and shares the upstream limits with it.
+const warmupWorkers = 4
+
+// WarmupSource names the lists of artifacts the cache-warmup task fetches:
+// a local file and/or an object in the bucket.
+type WarmupSource struct {
+	File string
+	Key  string
+}
+
+// WarmupStats summarises one warm-up. Cached artifacts were already in the
+// bucket; Missing ones no proxy has.
+type WarmupStats struct {
+	Requested int `json:"requested"`
+	Cached    int `json:"cached"`
+	Fetched   int `json:"fetched"`
+	Missing   int `json:"missing"`
+	Failed    int `json:"failed"`
+}
+
+// parseWarmupList reads one artifact per line, skipping blank lines and
+// "#" comments. A line is either a path, starting with a proxy name or not,
+// or Maven coordinates groupId:artifactId[:extension[:classifier]]:version,
+// which stand for the artifact file and its POM.
+func parseWarmupList(r io.Reader) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var entries []string
+		if strings.Contains(line, ":") && !strings.Contains(line, "/") {
+			var err error
+			if entries, err = coordinatePaths(line); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		} else {
+			entries = []string{strings.TrimPrefix(line, "/")}
+		}
+		for _, p := range entries {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths, sc.Err()
+}
+
+// coordinatePaths maps Maven coordinates to the repository paths of the
+// artifact and its POM.
+func coordinatePaths(coords string) ([]string, error) {
+	parts := strings.Split(coords, ":")
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("invalid coordinates %q", coords)
+		}
+	}
+	var ext, classifier string
+	switch len(parts) {
+	case 3:
+		ext = "jar"
+	case 4:
+		ext = parts[2]
+	case 5:
+		ext, classifier = parts[2], parts[3]
+	default:
+		return nil, fmt.Errorf("invalid coordinates %q, want groupId:artifactId[:extension[:classifier]]:version", coords)
+	}
+	group, artifact, version := parts[0], parts[1], parts[len(parts)-1]
+	dir := strings.ReplaceAll(group, ".", "/") + "/" + artifact + "/" + version + "/"
+	base := artifact + "-" + version
+	file := base
+	if classifier != "" {
+		file += "-" + classifier
+	}
+	paths := []string{dir + file + "." + ext}
+	if ext != "pom" || classifier != "" {
+		paths = append(paths, dir+base+".pom")
+	}
+	return paths, nil
+}
+
+// warmCache fetches paths through the caching proxies. A path starting with
+// a proxy name goes to that proxy; any other path goes to the first proxy
+// that has it, unless one already caches it. done, if set, is called after
+// each path.
+func (s *Server) warmCache(ctx context.Context, paths []string, done func(path string)) (WarmupStats, error) {
+	stats := WarmupStats{Requested: len(paths)}
+	proxies, err := s.proxy.List(ctx)
+	if err != nil {
+		return stats, err
+	}
+	var caching []Proxy
+	byName := make(map[string]Proxy)
+	for _, pr := range proxies {
+		if pr.caches() {
+			caching = append(caching, pr)
+			byName[pr.Name] = pr
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	work := make(chan string)
+	for range warmupWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				keys := make([]string, 0, len(caching))
+				if name, rest, ok := strings.Cut(p, "/"); ok && byName[name].Name != "" {
+					keys = append(keys, name+"/"+rest)
+				} else {
+					for _, pr := range caching {
+						keys = append(keys, pr.Name+"/"+p)
+					}
+				}
+				outcome, err := s.warmKey(ctx, keys)
+				mu.Lock()
+				switch {
+				case err != nil:
+					stats.Failed++
+					if firstErr == nil {
+						firstErr = fmt.Errorf("%s: %w", p, err)
+					}
+					s.logger.Warn("warm up artifact", zap.String("path", p), zap.Error(err))
+				case outcome == warmCached:
+					stats.Cached++
+				case outcome == warmFetched:
+					stats.Fetched++
+				default:
+					stats.Missing++
+				}
+				mu.Unlock()
+				if done != nil {
+					done(p)
+				}
+			}
+		}()
+	}
+	for _, p := range paths {
+		select {
+		case work <- p:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(work)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+	if firstErr != nil {
+		return stats, fmt.Errorf("%d of %d artifacts failed, first: %w", stats.Failed, stats.Requested, firstErr)
+	}
+	return stats, nil
+}
+
+const (
+	warmCached  = "cached"
+	warmFetched = "fetched"
+	warmMissing = "missing"
+)
+
+// warmKey fetches the first of keys (proxy-prefixed) an upstream has,
+// unless one of them is cached already.
+func (s *Server) warmKey(ctx context.Context, keys []string) (string, error) {
+	for _, key := range keys {
+		if _, err := s.store.Head(ctx, key); err == nil {
+			return warmCached, nil
+		} else if !storage.IsNotFound(err) {
+			return "", err
+		}
+	}
+	for _, key := range keys {
+		ok, err := s.proxy.FetchAndCache(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return warmFetched, nil
+		}
+	}
+	return warmMissing, nil
+}
+
+// warmupTask reads the warm-up lists and fetches what they name; it is the
+// "cache-warmup" task.
+func (s *Server) warmupTask(ctx context.Context, src WarmupSource) error {
+	if src.File == "" && src.Key == "" {
+		return fmt.Errorf("no warm-up list configured (WARMUP_FILE or WARMUP_KEY)")
+	}
+	var paths []string
+	if src.File != "" {
+		f, err := os.Open(src.File)
+		if err != nil {
+			return err
+		}
+		list, err := parseWarmupList(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.File, err)
+		}
+		paths = append(paths, list...)
+	}
+	if src.Key != "" {
+		resp, err := s.store.Get(ctx, src.Key)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", src.Key, err)
+		}
+		list, err := parseWarmupList(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.Key, err)
+		}
+		paths = append(paths, list...)
+	}
+
+	stats, err := s.warmCache(ctx, paths, func(p string) { taskCheckpoint(ctx, p) })
+	s.logger.Info("cache warm-up finished",
+		zap.Int("requested", stats.Requested),
+		zap.Int("cached", stats.Cached),
+		zap.Int("fetched", stats.Fetched),
+		zap.Int("missing", stats.Missing),
+		zap.Int("failed", stats.Failed))
+	return err
+}
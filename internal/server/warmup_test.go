@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/otoru/heimdall/internal/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestParseWarmupList(t *testing.T) {
+	list := `# nightly build dependencies
+com.acme:lib:1.0
+com.acme:lib:1.0
+com.acme:tool:war:2.0
+com.acme:lib:jar:sources:1.0
+
+central/org/x/x/1/x-1.jar
+/org/y/y/1/y-1.jar
+`
+	paths, err := parseWarmupList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"com/acme/lib/1.0/lib-1.0.jar", "com/acme/lib/1.0/lib-1.0.pom",
+		"com/acme/tool/2.0/tool-2.0.war", "com/acme/tool/2.0/tool-2.0.pom",
+		"com/acme/lib/1.0/lib-1.0-sources.jar",
+		"central/org/x/x/1/x-1.jar", "org/y/y/1/y-1.jar",
+	}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected paths:\n%v\nwant\n%v", paths, want)
+	}
+	if _, err := parseWarmupList(strings.NewReader("com.acme:lib\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected a parse error for line 1, got %v", err)
+	}
+}
+
+func TestWarmupTask(t *testing.T) {
+	serve := func(paths ...string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range paths {
+				if r.URL.Path == "/"+p {
+					_, _ = w.Write([]byte("bytes of " + p))
+					return
+				}
+			}
+			http.NotFound(w, r)
+		}))
+	}
+	central := serve("com/acme/lib/1.0/lib-1.0.jar", "com/acme/lib/1.0/lib-1.0.pom")
+	defer central.Close()
+	other := serve("org/y/y/1/y-1.jar")
+	defer other.Close()
+
+	store := newMemStore()
+	srv := New(store, zaptest.NewLogger(t), metrics.New(), "", "")
+	ctx := context.Background()
+	for _, pr := range []Proxy{{Name: "central", URL: central.URL}, {Name: "other", URL: other.URL}} {
+		if err := srv.proxy.Add(ctx, pr); err != nil {
+			t.Fatalf("add proxy: %v", err)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "warmup.txt")
+	if err := os.WriteFile(file, []byte("com.acme:lib:1.0\norg/y/y/1/y-1.jar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_ = store.Put(ctx, "lists/warmup.txt", strings.NewReader("central/org/gone/1/gone-1.jar\n"), "text/plain", 30)
+	if err := srv.warmupTask(ctx, WarmupSource{File: file, Key: "lists/warmup.txt"}); err != nil {
+		t.Fatalf("warm-up: %v", err)
+	}
+	for _, key := range []string{"central/com/acme/lib/1.0/lib-1.0.jar", "central/com/acme/lib/1.0/lib-1.0.pom", "other/org/y/y/1/y-1.jar"} {
+		if _, ok := store.data[key]; !ok {
+			t.Fatalf("expected %s to be cached", key)
+		}
+	}
+	if _, ok := store.data["central/org/y/y/1/y-1.jar"]; ok {
+		t.Fatal("expected the path to be cached by the proxy that has it only")
+	}
+
+	stats, err := srv.warmCache(ctx, []string{"com/acme/lib/1.0/lib-1.0.jar", "central/org/gone/1/gone-1.jar"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (WarmupStats{Requested: 2, Cached: 1, Missing: 1}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
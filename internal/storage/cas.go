@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// CASPrefix holds the blobs of content-addressable mode, one object per
+// sha256 at CASPrefix+"sha256/<2 hex>/<hex>".
+const CASPrefix = "__cas__/"
+
+// DefaultCASMinSize is the smallest upload stored as a blob; smaller ones
+// gain nothing from an extra object.
+const DefaultCASMinSize = 64 << 10
+
+// Pointer objects are empty and carry the blob in their user metadata.
+const (
+	casDigestMeta = "heimdall-cas-sha256"
+	casSizeMeta   = "heimdall-cas-size"
+	casETagMeta   = "heimdall-cas-etag"
+)
+
+const (
+	// casGrace protects blobs younger than this from CollectBlobs, so an
+	// upload that wrote its blob but not yet its pointer is not collected.
+	casGrace = 24 * time.Hour
+	// casRefreshAfter is when a reused blob gets a new LastModified, keeping
+	// it inside casGrace while its pointer is written.
+	casRefreshAfter = casGrace / 2
+	// casResolveWorkers bounds the HEADs resolving pointer sizes in listings.
+	casResolveWorkers = 8
+)
+
+// CASStats summarises one CollectBlobs pass.
+type CASStats struct {
+	Pointers int
+	Blobs    int
+	Deleted  int
+	// Freed is the size of the deleted blobs.
+	Freed int64
+}
+
+// usesCAS reports whether an upload to k (a full bucket key) is stored as a
+// blob. Sidecars and metadata are small or rewritten often, internal
+// objects are read with their raw body, and write-once objects keep their
+// bytes under their own key so Object Lock covers them.
+func (s *Store) usesCAS(k string, contentLength int64) bool {
+	if !s.cas || contentLength < s.casMinSize || s.writeOnce(k) {
+		return false
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(k, s.prefix), "/")
+	if strings.HasPrefix(rel, "__") {
+		return false
+	}
+	base := path.Base(rel)
+	if strings.HasPrefix(base, "maven-metadata") {
+		return false
+	}
+	for _, suf := range []string{".sha1", ".md5", ".sha256", ".sha512", ".asc"} {
+		if strings.HasSuffix(base, suf) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) blobKey(digest string) string {
+	return s.key(CASPrefix + "sha256/" + digest[:2] + "/" + digest)
+}
+
+// putCAS stores body as a blob, unless an identical one exists, and writes
+// the pointer at k.
+func (s *Store) putCAS(ctx context.Context, k string, body io.ReadSeeker, contentType string, contentLength int64, metadata map[string]string) error {
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek body: %w", err)
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, body)
+	if err != nil {
+		return fmt.Errorf("hash body: %w", err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	blob := s.blobKey(digest)
+
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(blob)})
+	switch {
+	case IsNotFound(err):
+		if err := s.putObject(ctx, blob, body, "application/octet-stream", size, nil); err != nil {
+			return err
+		}
+		if head, err = s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(blob)}); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case head.LastModified != nil && time.Since(*head.LastModified) > casRefreshAfter:
+		if err := s.refreshBlob(ctx, blob); err != nil {
+			return err
+		}
+	}
+
+	pointer := maps.Clone(metadata)
+	if pointer == nil {
+		pointer = make(map[string]string)
+	}
+	pointer[casDigestMeta] = digest
+	pointer[casSizeMeta] = strconv.FormatInt(size, 10)
+	pointer[casETagMeta] = aws.ToString(head.ETag)
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(k),
+		Body:          strings.NewReader(""),
+		ContentLength: aws.Int64(0),
+		Metadata:      pointer,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	s.lockPut(input)
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("upload pointer: %w", err)
+	}
+	return nil
+}
+
+// refreshBlob resets the LastModified of a blob with an in-place copy.
+func (s *Store) refreshBlob(ctx context.Context, blob string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(blob),
+		CopySource:        aws.String((&url.URL{Path: s.bucket + "/" + blob}).EscapedPath()),
+		MetadataDirective: types.MetadataDirectiveReplace,
+		ContentType:       aws.String("application/octet-stream"),
+	})
+	return err
+}
+
+// resolveGet replaces the body of a pointer with its blob, keeping the
+// pointer's content type, timestamps and metadata.
+func (s *Store) resolveGet(ctx context.Context, out *s3.GetObjectOutput) (*s3.GetObjectOutput, error) {
+	digest := out.Metadata[casDigestMeta]
+	if digest == "" || len(digest) < 2 {
+		return out, nil
+	}
+	out.Body.Close()
+	blob, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.blobKey(digest))})
+	if err != nil {
+		return nil, fmt.Errorf("get blob %s: %w", digest, err)
+	}
+	out.Body = blob.Body
+	out.ContentLength = blob.ContentLength
+	out.ETag = blob.ETag
+	return out, nil
+}
+
+// IsCASPointer reports whether metadata is that of a pointer, an empty
+// object whose content lives in a blob under CASPrefix.
+func IsCASPointer(metadata map[string]string) bool {
+	return metadata[casDigestMeta] != ""
+}
+
+// resolveHead reports the size and ETag of the blob behind a pointer.
+func resolveHead(out *s3.HeadObjectOutput) *s3.HeadObjectOutput {
+	if out == nil || out.Metadata[casDigestMeta] == "" {
+		return out
+	}
+	if size, err := strconv.ParseInt(out.Metadata[casSizeMeta], 10, 64); err == nil {
+		out.ContentLength = aws.Int64(size)
+	}
+	if etag := out.Metadata[casETagMeta]; etag != "" {
+		out.ETag = aws.String(etag)
+	}
+	return out
+}
+
+// resolveSizes replaces the size and ETag of listed pointers (empty
+// objects) with those of their blob. listed are the full bucket keys of
+// entries. It also runs with the mode off, so pointers written before keep
+// their size.
+func (s *Store) resolveSizes(ctx context.Context, entries []Entry, listed []string) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, casResolveWorkers)
+	for i := range entries {
+		if entries[i].Type != "file" || entries[i].Size != 0 || listed[i] == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(e *Entry, k string) {
+			defer func() { <-sem; wg.Done() }()
+			head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(k)})
+			if err == nil {
+				head = resolveHead(head)
+				e.Size = aws.ToInt64(head.ContentLength)
+				e.ETag = strings.Trim(aws.ToString(head.ETag), `"`)
+			}
+		}(&entries[i], listed[i])
+	}
+	wg.Wait()
+}
+
+// CollectBlobs deletes blobs no pointer refers to anymore. Blobs younger
+// than a day are kept, since their pointer may still be on its way.
+func (s *Store) CollectBlobs(ctx context.Context) (CASStats, error) {
+	var stats CASStats
+	casRoot := strings.TrimSuffix(s.key(CASPrefix), "/") + "/"
+	root := ""
+	if s.prefix != "" {
+		root = s.prefix + "/"
+	}
+
+	referenced := make(map[string]bool)
+	err := s.listAll(ctx, root, func(obj types.Object) error {
+		k := aws.ToString(obj.Key)
+		if aws.ToInt64(obj.Size) != 0 || strings.HasPrefix(strings.TrimPrefix(k, root), "__") {
+			return nil
+		}
+		head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(k)})
+		if err != nil {
+			if IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if digest := head.Metadata[casDigestMeta]; digest != "" {
+			referenced[digest] = true
+			stats.Pointers++
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	cutoff := time.Now().Add(-casGrace)
+	err = s.listAll(ctx, casRoot, func(obj types.Object) error {
+		stats.Blobs++
+		k := aws.ToString(obj.Key)
+		if referenced[path.Base(k)] || obj.LastModified == nil || obj.LastModified.After(cutoff) {
+			return nil
+		}
+		if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(k)}); err != nil {
+			return err
+		}
+		stats.Deleted++
+		stats.Freed += aws.ToInt64(obj.Size)
+		return nil
+	})
+	return stats, err
+}
+
+// listAll calls fn for every object below the bucket prefix p.
+func (s *Store) listAll(ctx context.Context, p string, fn func(types.Object) error) error {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(p)}
+	for {
+		var out *s3.ListObjectsV2Output
+		err := timed(ctx, "ListObjectsV2", s.timeouts.List, func(ctx context.Context) error {
+			var err error
+			out, err = s.client.ListObjectsV2(ctx, input)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, obj := range out.Contents {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+		if !aws.ToBool(out.IsTruncated) || out.NextContinuationToken == nil {
+			return nil
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCASStore(prefix string) (*Store, *fakeS3) {
+	store := newTestStore(prefix)
+	store.cas, store.casMinSize = true, 4
+	return store, store.client.(*fakeS3)
+}
+
+func TestCASDeduplicatesUploads(t *testing.T) {
+	ctx := context.Background()
+	store, fs := newCASStore("maven")
+	body := []byte("shaded bytes")
+	for _, key := range []string{"releases/a/1.0/a-1.0-sources.jar", "central/b/2.0/b-2.0-sources.jar"} {
+		if err := store.Put(ctx, key, bytes.NewReader(body), "application/java-archive", int64(len(body))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+	if err := store.Put(ctx, "releases/a/1.0/a-1.0-sources.jar.sha1", strings.NewReader("abc1"), "text/plain", 4); err != nil {
+		t.Fatalf("put sidecar: %v", err)
+	}
+
+	blobs := 0
+	for key, obj := range fs.objects {
+		switch {
+		case strings.HasPrefix(key, "maven/"+CASPrefix):
+			blobs++
+		case strings.HasSuffix(key, ".jar") && len(obj.body) != 0:
+			t.Fatalf("expected an empty pointer at %s", key)
+		case strings.HasSuffix(key, ".sha1") && string(obj.body) != "abc1":
+			t.Fatal("expected the sidecar to be stored as is")
+		}
+	}
+	if blobs != 1 {
+		t.Fatalf("expected one shared blob, got %d", blobs)
+	}
+
+	resp, err := store.Get(ctx, "central/b/2.0/b-2.0-sources.jar")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(got, body) || *resp.ContentLength != int64(len(body)) || *resp.ContentType != "application/java-archive" {
+		t.Fatalf("unexpected object %q (%d bytes, %s)", got, *resp.ContentLength, *resp.ContentType)
+	}
+	head, err := store.Head(ctx, "central/b/2.0/b-2.0-sources.jar")
+	if err != nil || *head.ContentLength != int64(len(body)) || *head.ETag != *resp.ETag {
+		t.Fatalf("unexpected head %+v: %v", head, err)
+	}
+
+	entries, err := store.List(ctx, "releases/a/1.0", 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for _, e := range entries {
+		if e.Name == "a-1.0-sources.jar" && e.Size != int64(len(body)) {
+			t.Fatalf("expected the listing to show the blob size, got %d", e.Size)
+		}
+	}
+
+	// Touching a pointer keeps its blob.
+	if err := store.Touch(ctx, "central/b/2.0/b-2.0-sources.jar", map[string]string{"upstream-etag": "x"}); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	if resp, err := store.Get(ctx, "central/b/2.0/b-2.0-sources.jar"); err != nil || *resp.ContentLength != int64(len(body)) {
+		t.Fatalf("expected the pointer to survive a touch: %v", err)
+	}
+}
+
+func TestCollectBlobs(t *testing.T) {
+	ctx := context.Background()
+	store, fs := newCASStore("")
+	for key, body := range map[string]string{"releases/a.jar": "kept blob", "releases/b.jar": "dropped blob", "releases/c.jar": "fresh blob"} {
+		if err := store.Put(ctx, key, strings.NewReader(body), "", int64(len(body))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+	_ = store.Delete(ctx, "releases/b.jar")
+	_ = store.Delete(ctx, "releases/c.jar")
+	old := time.Now().Add(-2 * casGrace)
+	for key, obj := range fs.objects {
+		if strings.HasPrefix(key, CASPrefix) && string(obj.body) != "fresh blob" {
+			obj.modified = old
+			fs.objects[key] = obj
+		}
+	}
+
+	stats, err := store.CollectBlobs(ctx)
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if stats.Pointers != 1 || stats.Blobs != 3 || stats.Deleted != 1 || stats.Freed != int64(len("dropped blob")) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if resp, err := store.Get(ctx, "releases/a.jar"); err != nil {
+		t.Fatalf("expected the referenced blob to be kept: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
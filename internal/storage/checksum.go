@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// ChecksumMismatchError reports an upload whose content did not arrive
+// intact, either rejected by S3 or echoed back with a different checksum.
+type ChecksumMismatchError struct {
+	Key      string
+	Expected string
+	Actual   string
+}
+
+func (e ChecksumMismatchError) Error() string {
+	if e.Actual == "" {
+		return fmt.Sprintf("checksum mismatch uploading %s", e.Key)
+	}
+	return fmt.Sprintf("checksum mismatch uploading %s: sent %s, stored %s", e.Key, e.Expected, e.Actual)
+}
+
+// IsChecksumMismatch reports whether err means the object was corrupted on
+// its way to S3.
+func IsChecksumMismatch(err error) bool {
+	var ce ChecksumMismatchError
+	if errors.As(err, &ce) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "BadDigest", "XAmzContentChecksumMismatch", "InvalidDigest":
+			return true
+		}
+	}
+	return false
+}
+
+func parseChecksumAlgorithm(name string) (types.ChecksumAlgorithm, error) {
+	switch strings.ToUpper(name) {
+	case "":
+		return "", nil
+	case "CRC32":
+		return types.ChecksumAlgorithmCrc32, nil
+	case "CRC32C":
+		return types.ChecksumAlgorithmCrc32c, nil
+	case "SHA1":
+		return types.ChecksumAlgorithmSha1, nil
+	case "SHA256":
+		return types.ChecksumAlgorithmSha256, nil
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm %q (want CRC32, CRC32C, SHA1 or SHA256)", name)
+	}
+}
+
+func newChecksumHash(algo types.ChecksumAlgorithm) hash.Hash {
+	switch algo {
+	case types.ChecksumAlgorithmCrc32:
+		return crc32.NewIEEE()
+	case types.ChecksumAlgorithmCrc32c:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case types.ChecksumAlgorithmSha1:
+		return sha1.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// addChecksum computes the full-object checksum of body locally and sets it
+// on the request, so S3 rejects the upload if the bytes it receives differ.
+// It returns the base64 value for comparing with the response.
+func addChecksum(input *s3.PutObjectInput, algo types.ChecksumAlgorithm, body io.ReadSeeker) (string, error) {
+	h := newChecksumHash(algo)
+	if _, err := io.Copy(h, body); err != nil {
+		return "", fmt.Errorf("checksum body: %w", err)
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("seek body: %w", err)
+	}
+	sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	input.ChecksumAlgorithm = algo
+	switch algo {
+	case types.ChecksumAlgorithmCrc32:
+		input.ChecksumCRC32 = aws.String(sum)
+	case types.ChecksumAlgorithmCrc32c:
+		input.ChecksumCRC32C = aws.String(sum)
+	case types.ChecksumAlgorithmSha1:
+		input.ChecksumSHA1 = aws.String(sum)
+	default:
+		input.ChecksumSHA256 = aws.String(sum)
+	}
+	return sum, nil
+}
+
+// storedChecksum returns the checksum S3 reports for the algorithm, if any.
+func storedChecksum(out *s3.PutObjectOutput, algo types.ChecksumAlgorithm) string {
+	switch algo {
+	case types.ChecksumAlgorithmCrc32:
+		return aws.ToString(out.ChecksumCRC32)
+	case types.ChecksumAlgorithmCrc32c:
+		return aws.ToString(out.ChecksumCRC32C)
+	case types.ChecksumAlgorithmSha1:
+		return aws.ToString(out.ChecksumSHA1)
+	default:
+		return aws.ToString(out.ChecksumSHA256)
+	}
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// MinPartSize is the smallest part S3 accepts in a multipart upload,
+	// except for the last one.
+	MinPartSize = 5 << 20
+	// maxCopyPartSize is the largest range UploadPartCopy copies at once.
+	maxCopyPartSize = 5 << 30
+)
+
+// Part is a stored object that makes up part of a composed one.
+type Part struct {
+	Key  string
+	Size int64
+}
+
+// Compose writes key as the concatenation of parts, in order, with one
+// multipart upload, so the bytes are not uploaded again. Stretches of at
+// least MinPartSize are copied inside S3 (UploadPartCopy); smaller parts are
+// read back and uploaded together until they reach MinPartSize. A failed
+// compose aborts the multipart upload and leaves key as it was.
+//
+// Keys stored through content-addressable storage, checksum-verified puts
+// (S3_CHECKSUM_ALGORITHM) and presigned puts are not composed; Compose
+// returns errors.ErrUnsupported for them and the caller uploads the object
+// instead.
+func (s *Store) Compose(ctx context.Context, key string, parts []Part, contentType string) error {
+	k, err := s.cleanKey(key)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, p := range parts {
+		total += p.Size
+	}
+	if len(parts) == 0 || s.usesCAS(k, total) || s.checksum != "" || s.putMode == PutModePresigned {
+		return errors.ErrUnsupported
+	}
+	sources := make([]string, len(parts))
+	for i, p := range parts {
+		if sources[i], err = s.cleanKey(p.Key); err != nil {
+			return err
+		}
+	}
+
+	err = timed(ctx, "MultipartUpload", s.timeouts.Put, func(ctx context.Context) error {
+		input := &s3.CreateMultipartUploadInput{
+			Bucket:      aws.String(s.bucket),
+			Key:         aws.String(k),
+			ContentType: aws.String(contentType),
+		}
+		s.lockMultipart(input)
+		created, err := s.client.CreateMultipartUpload(ctx, input)
+		if err != nil {
+			return fmt.Errorf("create multipart upload: %w", err)
+		}
+		m := &multipartUpload{store: s, ctx: ctx, key: k, id: created.UploadId}
+		if err := m.compose(sources, parts); err != nil {
+			return m.abort(err)
+		}
+		complete := &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(k),
+			UploadId:        created.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: m.done},
+		}
+		if s.writeOnce(k) {
+			complete.IfNoneMatch = aws.String("*")
+		}
+		if _, err := s.client.CompleteMultipartUpload(ctx, complete); err != nil {
+			return m.abort(fmt.Errorf("complete multipart upload: %w", err))
+		}
+		return nil
+	})
+	return s.immutable(k, err)
+}
+
+// multipartUpload is a multipart upload being filled by Compose.
+type multipartUpload struct {
+	store *Store
+	ctx   context.Context
+	key   string
+	id    *string
+	// pending collects parts smaller than MinPartSize until they make up
+	// one part.
+	pending bytes.Buffer
+	done    []types.CompletedPart
+}
+
+func (m *multipartUpload) compose(sources []string, parts []Part) error {
+	for i, p := range parts {
+		var off int64
+		if m.pending.Len() > 0 {
+			// Top the pending bytes up to a full part first.
+			n := min(p.Size, MinPartSize-int64(m.pending.Len()))
+			if err := m.read(sources[i], 0, n); err != nil {
+				return err
+			}
+			off = n
+			if m.pending.Len() >= MinPartSize {
+				if err := m.flush(); err != nil {
+					return err
+				}
+			}
+		}
+		rest := p.Size - off
+		switch {
+		case rest == 0:
+		case rest >= MinPartSize || i == len(parts)-1:
+			if err := m.copy(sources[i], off, rest); err != nil {
+				return err
+			}
+		default:
+			if err := m.read(sources[i], off, rest); err != nil {
+				return err
+			}
+		}
+	}
+	return m.flush()
+}
+
+// abort drops the upload and its parts after err. The caller's context may
+// be gone, but the abort still has to run or S3 keeps the parts.
+func (m *multipartUpload) abort(err error) error {
+	s := m.store
+	_, abortErr := s.client.AbortMultipartUpload(context.WithoutCancel(m.ctx), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(m.key),
+		UploadId: m.id,
+	})
+	return errors.Join(err, abortErr)
+}
+
+// read appends n bytes of source from off to the pending part.
+func (m *multipartUpload) read(source string, off, n int64) error {
+	s := m.store
+	out, err := s.client.GetObject(m.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(source),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+n-1)),
+	})
+	if err != nil {
+		return fmt.Errorf("read part %s: %w", source, err)
+	}
+	defer out.Body.Close()
+	if _, err := io.CopyN(&m.pending, out.Body, n); err != nil {
+		return fmt.Errorf("read part %s: %w", source, err)
+	}
+	return nil
+}
+
+// flush uploads the pending bytes as the next part.
+func (m *multipartUpload) flush() error {
+	if m.pending.Len() == 0 {
+		return nil
+	}
+	s := m.store
+	number := aws.Int32(int32(len(m.done) + 1))
+	out, err := s.client.UploadPart(m.ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(m.key),
+		UploadId:      m.id,
+		PartNumber:    number,
+		Body:          bytes.NewReader(m.pending.Bytes()),
+		ContentLength: aws.Int64(int64(m.pending.Len())),
+	})
+	if err != nil {
+		return fmt.Errorf("upload part %d: %w", *number, err)
+	}
+	m.pending.Reset()
+	m.done = append(m.done, types.CompletedPart{ETag: out.ETag, PartNumber: number})
+	return nil
+}
+
+// copy adds n bytes of source from off as parts copied inside S3.
+func (m *multipartUpload) copy(source string, off, n int64) error {
+	s := m.store
+	for n > 0 {
+		size := min(n, maxCopyPartSize)
+		if rest := n - size; rest > 0 && rest < MinPartSize {
+			// Leave a full part for the last copy.
+			size -= MinPartSize
+		}
+		number := aws.Int32(int32(len(m.done) + 1))
+		out, err := s.client.UploadPartCopy(m.ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(m.key),
+			UploadId:        m.id,
+			PartNumber:      number,
+			CopySource:      aws.String((&url.URL{Path: s.bucket + "/" + source}).EscapedPath()),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", off, off+size-1)),
+		})
+		if err != nil {
+			return fmt.Errorf("copy part %d from %s: %w", *number, source, err)
+		}
+		var etag *string
+		if out.CopyPartResult != nil {
+			etag = out.CopyPartResult.ETag
+		}
+		m.done = append(m.done, types.CompletedPart{ETag: etag, PartNumber: number})
+		off += size
+		n -= size
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestComposeJoinsParts(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore("maven")
+	fs := store.client.(*fakeS3)
+
+	// A small chunk, one large enough to be copied inside S3, two small
+	// ones that are uploaded together and a short last one.
+	sizes := []int{1000, 2 * MinPartSize, 3 << 20, 3 << 20, 7}
+	var want []byte
+	var parts []Part
+	for i, size := range sizes {
+		data := bytes.Repeat([]byte{byte('a' + i)}, size)
+		key := fmt.Sprintf("__uploads__/u1/%020d", len(want))
+		if err := store.Put(ctx, key, bytes.NewReader(data), "application/octet-stream", int64(size)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data...)
+		parts = append(parts, Part{Key: key, Size: int64(size)})
+	}
+
+	if err := store.Compose(ctx, "releases/a/1.0/a-1.0.jar", parts, "application/java-archive"); err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+	obj := fs.objects["maven/releases/a/1.0/a-1.0.jar"]
+	if !bytes.Equal(obj.body, want) || obj.contentType != "application/java-archive" {
+		t.Fatalf("composed object has %d bytes of %q, want %d", len(obj.body), obj.contentType, len(want))
+	}
+	if fs.copiedParts == 0 || fs.uploadedParts == 0 {
+		t.Errorf("expected both copied and uploaded parts, got %d copied and %d uploaded", fs.copiedParts, fs.uploadedParts)
+	}
+}
+
+func TestComposeRespectsWriteOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore("maven")
+	fs := store.client.(*fakeS3)
+	var err error
+	if store.worm, err = newWORMPolicy([]string{"/releases/"}, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "__uploads__/u1/0", bytes.NewReader([]byte("v2")), "application/octet-stream", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "releases/a/1.0/a-1.0.jar", bytes.NewReader([]byte("v1")), "application/octet-stream", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Compose(ctx, "releases/a/1.0/a-1.0.jar", []Part{{Key: "__uploads__/u1/0", Size: 2}}, "application/octet-stream")
+	if !IsImmutable(err) {
+		t.Fatalf("expected an overwrite to be refused, got %v", err)
+	}
+	if string(fs.objects["maven/releases/a/1.0/a-1.0.jar"].body) != "v1" || fs.aborted != 1 {
+		t.Fatalf("expected the original content and an aborted upload, got %q and %d aborts", fs.objects["maven/releases/a/1.0/a-1.0.jar"].body, fs.aborted)
+	}
+}
+
+func TestComposeAbortsOnMissingPart(t *testing.T) {
+	store := newTestStore("")
+	fs := store.client.(*fakeS3)
+	err := store.Compose(context.Background(), "releases/a.jar", []Part{{Key: "__uploads__/u1/0", Size: 2}}, "application/octet-stream")
+	if !IsNotFound(err) || fs.aborted != 1 || len(fs.uploads) != 0 {
+		t.Fatalf("expected not found and an aborted upload, got %v with %d aborts", err, fs.aborted)
+	}
+	if _, ok := fs.objects["releases/a.jar"]; ok {
+		t.Fatal("a failed compose must not write the object")
+	}
+}
+
+func TestComposeDeclinesCAS(t *testing.T) {
+	store := newTestStore("")
+	store.cas, store.casMinSize = true, 0
+	err := store.Compose(context.Background(), "releases/a.jar", []Part{{Key: "__uploads__/u1/0", Size: 2}}, "application/octet-stream")
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
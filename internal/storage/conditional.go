@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// PutIfMatch writes body to key only if the object's current ETag is etag
+// or, with an empty etag, only if key does not exist yet. It returns the ETag
+// of the new object. A lost race fails with an error for which
+// IsPreconditionFailed is true. Small bookkeeping objects only: the upload
+// always goes through the SDK client, whatever the put mode.
+func (s *Store) PutIfMatch(ctx context.Context, key string, body []byte, contentType, etag string) (string, error) {
+	k, err := s.cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(k),
+		Body:          bytes.NewReader(body),
+		ContentLength: aws.Int64(int64(len(body))),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+
+	var out *s3.PutObjectOutput
+	err = timed(ctx, "PutObject", s.timeouts.Put, func(ctx context.Context) error {
+		var err error
+		out, err = s.client.PutObject(ctx, input)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+// DeleteIfMatch deletes key only if its current ETag is etag, so a writer
+// never removes an object someone else replaced after it was read. A lost
+// race fails with an error for which IsPreconditionFailed is true.
+func (s *Store) DeleteIfMatch(ctx context.Context, key, etag string) error {
+	k, err := s.cleanKey(key)
+	if err != nil {
+		return err
+	}
+	if s.writeOnce(k) {
+		return ImmutableError{Key: strings.TrimPrefix(key, "/")}
+	}
+	return timed(ctx, "DeleteObject", s.timeouts.Put, func(ctx context.Context) error {
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket:  aws.String(s.bucket),
+			Key:     aws.String(k),
+			IfMatch: aws.String(etag),
+		})
+		return err
+	})
+}
+
+// IsPreconditionFailed reports whether a conditional write failed because
+// the object changed (or appeared) since it was read.
+func IsPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestPutIfMatch(t *testing.T) {
+	store := newTestStore("releases")
+	ctx := context.Background()
+
+	etag, err := store.PutIfMatch(ctx, "__tasks__/leader.json", []byte("a"), "application/json", "")
+	if err != nil || etag == "" {
+		t.Fatalf("create: etag=%q err=%v", etag, err)
+	}
+	if _, err := store.PutIfMatch(ctx, "__tasks__/leader.json", []byte("b"), "application/json", ""); !IsPreconditionFailed(err) {
+		t.Fatalf("expected precondition failure on existing key, got %v", err)
+	}
+	if _, err := store.PutIfMatch(ctx, "__tasks__/leader.json", []byte("b"), "application/json", `"stale"`); !IsPreconditionFailed(err) {
+		t.Fatalf("expected precondition failure on stale etag, got %v", err)
+	}
+	next, err := store.PutIfMatch(ctx, "__tasks__/leader.json", []byte("b"), "application/json", etag)
+	if err != nil || next == etag {
+		t.Fatalf("update: etag=%q err=%v", next, err)
+	}
+
+	out, err := store.Get(ctx, "__tasks__/leader.json")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	out.Body.Close()
+	if aws.ToString(out.ETag) != next {
+		t.Fatalf("expected stored etag %s, got %s", next, aws.ToString(out.ETag))
+	}
+	if err := store.DeleteIfMatch(ctx, "__tasks__/leader.json", etag); !IsPreconditionFailed(err) {
+		t.Fatalf("expected precondition failure deleting with a stale etag, got %v", err)
+	}
+	if err := store.DeleteIfMatch(ctx, "__tasks__/leader.json", next); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Head(ctx, "__tasks__/leader.json"); !IsNotFound(err) {
+		t.Fatalf("expected the object to be deleted, got %v", err)
+	}
+
+	if IsPreconditionFailed(errors.New("other")) {
+		t.Fatal("did not expect other error to be a precondition failure")
+	}
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"path"
+	"strings"
+)
+
+// EventKey maps the bucket and key of an S3 event notification to the key
+// this store exposes, reporting false for objects outside its bucket or
+// prefix.
+func (s *Store) EventKey(bucket, key string) (string, bool) {
+	if bucket != s.bucket {
+		return "", false
+	}
+	if s.prefix == "" {
+		return key, true
+	}
+	rest, ok := strings.CutPrefix(key, strings.Trim(s.prefix, "/")+"/")
+	return rest, ok && rest != ""
+}
+
+// EventKey maps an event of any routed bucket back to a repository key.
+// Mapped repositories are tried first, so a repository sharing the default
+// bucket under its own prefix is still attributed to it.
+func (r *Router) EventKey(bucket, key string) (string, bool) {
+	for repo, st := range r.routes {
+		if k, ok := st.EventKey(bucket, key); ok {
+			return repo + "/" + k, true
+		}
+	}
+	return r.def.EventKey(bucket, key)
+}
+
+// BucketKey returns the bucket and object key that hold key.
+func (s *Store) BucketKey(key string) (string, string) {
+	return s.bucket, s.key(strings.TrimPrefix(path.Clean("/"+key), "/"))
+}
+
+// BucketKey returns the bucket and object key that hold key in the routed
+// store.
+func (r *Router) BucketKey(key string) (string, string) {
+	st, k := r.route(key)
+	return st.BucketKey(k)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// SetStorageClass moves an object to another storage class with an in-place
+// copy that keeps its content type and metadata.
+func (s *Store) SetStorageClass(ctx context.Context, key, class string) error {
+	k, err := s.cleanKey(key)
+	if err != nil {
+		return err
+	}
+	return timed(ctx, "CopyObject", s.timeouts.Put, func(ctx context.Context) error {
+		input := &s3.CopyObjectInput{
+			Bucket:            aws.String(s.bucket),
+			Key:               aws.String(k),
+			CopySource:        aws.String((&url.URL{Path: s.bucket + "/" + k}).EscapedPath()),
+			MetadataDirective: types.MetadataDirectiveCopy,
+			StorageClass:      types.StorageClass(class),
+		}
+		s.lockCopy(input)
+		_, err := s.client.CopyObject(ctx, input)
+		return err
+	})
+}
+
+// AddTags merges tags into the object's tag set. It reports whether anything
+// had to be written.
+func (s *Store) AddTags(ctx context.Context, key string, tags map[string]string) (bool, error) {
+	k, err := s.cleanKey(key)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	err = timed(ctx, "PutObjectTagging", s.timeouts.Head, func(ctx context.Context) error {
+		out, err := s.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(k),
+		})
+		if err != nil {
+			return err
+		}
+		current := make(map[string]string, len(out.TagSet))
+		for _, t := range out.TagSet {
+			current[aws.ToString(t.Key)] = aws.ToString(t.Value)
+		}
+		for key, value := range tags {
+			if v, ok := current[key]; !ok || v != value {
+				current[key] = value
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		tagSet := make([]types.Tag, 0, len(current))
+		for key, value := range current {
+			tagSet = append(tagSet, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+		_, err = s.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+			Bucket:  aws.String(s.bucket),
+			Key:     aws.String(k),
+			Tagging: &types.Tagging{TagSet: tagSet},
+		})
+		return err
+	})
+	return changed, err
+}
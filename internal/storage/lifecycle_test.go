@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetStorageClassAndTags(t *testing.T) {
+	store := newTestStore("")
+	fs := store.client.(*fakeS3)
+	fs.objects["central/app.jar"] = fakeObj{body: []byte("jar"), contentType: "application/java-archive"}
+	ctx := context.Background()
+
+	if err := store.SetStorageClass(ctx, "central/app.jar", "STANDARD_IA"); err != nil {
+		t.Fatalf("set class: %v", err)
+	}
+	entries, err := store.List(ctx, "central", 10)
+	if err != nil || len(entries) != 1 || entries[0].StorageClass != "STANDARD_IA" {
+		t.Fatalf("unexpected entries %+v (%v)", entries, err)
+	}
+
+	changed, err := store.AddTags(ctx, "central/app.jar", map[string]string{"lifecycle": "expire"})
+	if err != nil || !changed {
+		t.Fatalf("expected tags to be written, changed=%t err=%v", changed, err)
+	}
+	if changed, err = store.AddTags(ctx, "central/app.jar", map[string]string{"lifecycle": "expire"}); err != nil || changed {
+		t.Fatalf("expected no-op, changed=%t err=%v", changed, err)
+	}
+}
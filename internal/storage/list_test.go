@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestListPagePagination(t *testing.T) {
+	store := newTestStore("releases")
+	fs := store.client.(*fakeS3)
+	for i := 0; i < 5; i++ {
+		fs.objects[fmt.Sprintf("releases/com/acme/lib%d.jar", i)] = fakeObj{body: []byte("x")}
+	}
+	fs.objects["releases/com/acme/sub/deep.jar"] = fakeObj{body: []byte("x")}
+
+	var paths []string
+	token := ""
+	pages := 0
+	for {
+		page, err := store.ListPage(context.Background(), "com/acme", token, 2)
+		if err != nil {
+			t.Fatalf("list page: %v", err)
+		}
+		pages++
+		for _, e := range page.Entries {
+			paths = append(paths, e.Path)
+		}
+		if page.NextToken == "" {
+			break
+		}
+		token = page.NextToken
+	}
+	if pages != 3 || len(paths) != 6 {
+		t.Fatalf("expected 6 entries over 3 pages, got %d over %d: %v", len(paths), pages, paths)
+	}
+	if paths[0] != "com/acme/lib0.jar" || !slices.Contains(paths, "com/acme/sub/") {
+		t.Fatalf("paths must be relative to the store prefix: %v", paths)
+	}
+}
+
+func TestWalkVisitsAllDepths(t *testing.T) {
+	store := newTestStore("")
+	fs := store.client.(*fakeS3)
+	for i := 0; i < 3; i++ {
+		fs.objects[fmt.Sprintf("repo/a/%d/x.jar", i)] = fakeObj{body: []byte("x")}
+	}
+	fs.objects["other/y.jar"] = fakeObj{body: []byte("y")}
+
+	var got []string
+	if err := store.Walk(context.Background(), "repo", func(e Entry) error {
+		got = append(got, e.Path)
+		return nil
+	}); err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	if len(got) != 3 || got[0] != "repo/a/0/x.jar" {
+		t.Fatalf("unexpected walk result: %v", got)
+	}
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestPutModes(t *testing.T) {
+	store := newTestStore("")
+	fs := store.client.(*fakeS3)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "a.txt", strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatalf("direct put: %v", err)
+	}
+	// base64(md5("hello"))
+	if fs.lastPutMD5 != "XUFAKrxLKna5cZ2REBfFkg==" || fs.presignedPuts != 0 {
+		t.Fatalf("expected direct upload with Content-MD5, got md5=%q presigned=%d", fs.lastPutMD5, fs.presignedPuts)
+	}
+
+	store.putMode = PutModePresigned
+	if err := store.Put(ctx, "b.txt", strings.NewReader("world"), "text/plain", 5); err != nil {
+		t.Fatalf("presigned put: %v", err)
+	}
+	if fs.presignedPuts != 1 || string(fs.objects["b.txt"].body) != "world" {
+		t.Fatalf("expected presigned upload, got %d", fs.presignedPuts)
+	}
+}
+
+func TestPutChecksum(t *testing.T) {
+	store := newTestStore("")
+	store.checksum = types.ChecksumAlgorithmCrc32c
+	fs := store.client.(*fakeS3)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "a.txt", strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	fs.corruptPuts = true
+	err := store.Put(ctx, "a.txt", strings.NewReader("hello"), "text/plain", 5)
+	if !IsChecksumMismatch(err) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	// base64 of crc32c("hello") = 0x9a71bb4c
+	if !strings.Contains(err.Error(), "mnG7TA==") {
+		t.Fatalf("expected sent checksum in error, got %v", err)
+	}
+}
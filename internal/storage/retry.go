@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// newRetryer builds the SDK retryer from Options. It returns nil when no
+// retry setting is configured so the SDK defaults apply.
+func newRetryer(opts Options) (func() aws.Retryer, error) {
+	mode := strings.ToLower(opts.RetryMode)
+	if mode == "" && opts.RetryMaxAttempts == 0 && opts.RetryMaxBackoff == 0 {
+		return nil, nil
+	}
+	if opts.RetryMaxAttempts < 0 {
+		return nil, fmt.Errorf("retry max attempts must not be negative")
+	}
+
+	standard := func(o *retry.StandardOptions) {
+		if opts.RetryMaxAttempts > 0 {
+			o.MaxAttempts = opts.RetryMaxAttempts
+		}
+		if opts.RetryMaxBackoff > 0 {
+			o.MaxBackoff = opts.RetryMaxBackoff
+			o.Backoff = retry.NewExponentialJitterBackoff(opts.RetryMaxBackoff)
+		}
+	}
+
+	switch mode {
+	case "", "standard":
+		return func() aws.Retryer { return retry.NewStandard(standard) }, nil
+	case "adaptive":
+		return func() aws.Retryer {
+			return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+				o.StandardOptions = append(o.StandardOptions, standard)
+			})
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown retry mode %q (want standard or adaptive)", opts.RetryMode)
+	}
+}
+
+// IsRetryExhausted reports whether err is the SDK giving up after using all
+// attempts or running out of retry quota, as opposed to a single failure.
+func IsRetryExhausted(err error) bool {
+	var maxAttempts *retry.MaxAttemptsError
+	if errors.As(err, &maxAttempts) {
+		return true
+	}
+	var quota ratelimit.QuotaExceededError
+	return errors.As(err, &quota)
+}
+
+// retryObserver calls onExhausted with the operation name whenever a call
+// fails because retries were exhausted.
+type retryObserver struct {
+	s3API
+	onExhausted func(op string)
+}
+
+func (o retryObserver) observe(op string, err error) {
+	if IsRetryExhausted(err) {
+		o.onExhausted(op)
+	}
+}
+
+func (o retryObserver) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := o.s3API.GetObject(ctx, params, optFns...)
+	o.observe("GetObject", err)
+	return out, err
+}
+
+func (o retryObserver) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	out, err := o.s3API.HeadObject(ctx, params, optFns...)
+	o.observe("HeadObject", err)
+	return out, err
+}
+
+func (o retryObserver) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	out, err := o.s3API.PutObject(ctx, params, optFns...)
+	o.observe("PutObject", err)
+	return out, err
+}
+
+func (o retryObserver) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	out, err := o.s3API.DeleteObject(ctx, params, optFns...)
+	o.observe("DeleteObject", err)
+	return out, err
+}
+
+func (o retryObserver) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	out, err := o.s3API.ListObjectsV2(ctx, params, optFns...)
+	o.observe("ListObjectsV2", err)
+	return out, err
+}
+
+func (o retryObserver) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	out, err := o.s3API.CopyObject(ctx, params, optFns...)
+	o.observe("CopyObject", err)
+	return out, err
+}
+
+var _ s3API = retryObserver{}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryExhaustionIsReported(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var exhausted []string
+	store, err := New(context.Background(), Options{
+		Bucket:           "bucket",
+		Region:           "us-east-1",
+		Endpoint:         srv.URL,
+		AccessKey:        "key",
+		SecretKey:        "secret",
+		UsePathStyle:     true,
+		RetryMaxAttempts: 2,
+		RetryMaxBackoff:  time.Millisecond,
+		OnRetryExhausted: func(op string) { exhausted = append(exhausted, op) },
+	})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	_, err = store.Head(context.Background(), "releases/app.jar")
+	if !IsRetryExhausted(err) {
+		t.Fatalf("expected retry exhaustion, got %v", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+	if len(exhausted) != 1 || exhausted[0] != "HeadObject" {
+		t.Fatalf("unexpected exhaustion callbacks: %v", exhausted)
+	}
+}
+
+func TestNewRetryer(t *testing.T) {
+	if r, err := newRetryer(Options{}); err != nil || r != nil {
+		t.Fatalf("expected SDK defaults without settings, got retryer=%t err=%v", r != nil, err)
+	}
+	r, err := newRetryer(Options{RetryMode: "adaptive", RetryMaxAttempts: 7})
+	if err != nil {
+		t.Fatalf("adaptive: %v", err)
+	}
+	if got := r().MaxAttempts(); got != 7 {
+		t.Fatalf("expected 7 attempts, got %d", got)
+	}
+	if _, err := newRetryer(Options{RetryMode: "aggressive"}); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+	if IsRetryExhausted(errors.New("boom")) {
+		t.Fatalf("plain error is not retry exhaustion")
+	}
+}
@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Router spreads repositories across several stores. Keys whose first path
+// segment names a mapped repository go to that store with the segment
+// stripped (the store's own prefix still applies); everything else goes to
+// the default store.
+type Router struct {
+	def    *Store
+	routes map[string]*Store
+}
+
+func NewRouter(def *Store, routes map[string]*Store) *Router {
+	return &Router{def: def, routes: routes}
+}
+
+// route returns the store for key and the key relative to that store.
+func (r *Router) route(key string) (*Store, string) {
+	clean := strings.TrimPrefix(path.Clean("/"+key), "/")
+	repo, rest, _ := strings.Cut(clean, "/")
+	if st, ok := r.routes[repo]; ok {
+		return st, rest
+	}
+	return r.def, key
+}
+
+func (r *Router) Get(ctx context.Context, key string) (*s3.GetObjectOutput, error) {
+	st, k := r.route(key)
+	return st.Get(ctx, k)
+}
+
+func (r *Router) Head(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+	st, k := r.route(key)
+	return st.Head(ctx, k)
+}
+
+func (r *Router) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64) error {
+	st, k := r.route(key)
+	return st.Put(ctx, k, body, contentType, contentLength)
+}
+
+func (r *Router) PutWithMetadata(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64, metadata map[string]string) error {
+	st, k := r.route(key)
+	return st.PutWithMetadata(ctx, k, body, contentType, contentLength, metadata)
+}
+
+// Compose joins parts within the store owning key; parts held by another
+// store are not composed (errors.ErrUnsupported).
+func (r *Router) Compose(ctx context.Context, key string, parts []Part, contentType string) error {
+	st, k := r.route(key)
+	routed := make([]Part, len(parts))
+	for i, p := range parts {
+		pst, pk := r.route(p.Key)
+		if pst != st {
+			return errors.ErrUnsupported
+		}
+		routed[i] = Part{Key: pk, Size: p.Size}
+	}
+	return st.Compose(ctx, k, routed, contentType)
+}
+
+func (r *Router) PutIfMatch(ctx context.Context, key string, body []byte, contentType, etag string) (string, error) {
+	st, k := r.route(key)
+	return st.PutIfMatch(ctx, k, body, contentType, etag)
+}
+
+func (r *Router) DeleteIfMatch(ctx context.Context, key, etag string) error {
+	st, k := r.route(key)
+	return st.DeleteIfMatch(ctx, k, etag)
+}
+
+func (r *Router) Delete(ctx context.Context, key string) error {
+	st, k := r.route(key)
+	return st.Delete(ctx, k)
+}
+
+func (r *Router) Touch(ctx context.Context, key string, metadata map[string]string) error {
+	st, k := r.route(key)
+	return st.Touch(ctx, k, metadata)
+}
+
+func (r *Router) Versions(ctx context.Context, key string) ([]ObjectVersion, error) {
+	st, k := r.route(key)
+	return st.Versions(ctx, k)
+}
+
+func (r *Router) RestoreVersion(ctx context.Context, key, versionID string) error {
+	st, k := r.route(key)
+	return st.RestoreVersion(ctx, k, versionID)
+}
+
+func (r *Router) SetStorageClass(ctx context.Context, key, class string) error {
+	st, k := r.route(key)
+	return st.SetStorageClass(ctx, k, class)
+}
+
+func (r *Router) AddTags(ctx context.Context, key string, tags map[string]string) (bool, error) {
+	st, k := r.route(key)
+	return st.AddTags(ctx, k, tags)
+}
+
+// Copy uses a server-side copy within one store and streams through a
+// temporary file across stores.
+func (r *Router) Copy(ctx context.Context, src, dst string) error {
+	srcStore, srcKey := r.route(src)
+	dstStore, dstKey := r.route(dst)
+	if srcStore == dstStore {
+		return srcStore.Copy(ctx, srcKey, dstKey)
+	}
+
+	obj, err := srcStore.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+
+	tmp, err := os.CreateTemp("", "heimdall-copy-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n, err := io.Copy(tmp, obj.Body)
+	if err != nil {
+		return fmt.Errorf("read source: %w", err)
+	}
+	contentType := ""
+	if obj.ContentType != nil {
+		contentType = *obj.ContentType
+	}
+	return dstStore.Put(ctx, dstKey, tmp, contentType, n)
+}
+
+// List lists a single store, except at the root where mapped repositories
+// show up as directories next to the default store's entries.
+func (r *Router) List(ctx context.Context, prefix string, limit int32) ([]Entry, error) {
+	clean := strings.Trim(path.Clean("/"+prefix), "/")
+	if clean == "" {
+		return r.listRoot(ctx, limit)
+	}
+
+	st, rest := r.route(clean)
+	if st == r.def {
+		return r.def.List(ctx, prefix, limit)
+	}
+	entries, err := st.List(ctx, rest, limit)
+	if err != nil {
+		return nil, err
+	}
+	r.rebase(clean, entries)
+	return entries, nil
+}
+
+// ListPage pages through a single store. At the root, mapped repositories
+// are appended to the default store's last page.
+func (r *Router) ListPage(ctx context.Context, prefix, token string, limit int32) (Page, error) {
+	clean := strings.Trim(path.Clean("/"+prefix), "/")
+	st, rest := r.route(clean)
+	if clean == "" || st == r.def {
+		page, err := r.def.ListPage(ctx, prefix, token, limit)
+		if err != nil || clean != "" || page.NextToken != "" {
+			return page, err
+		}
+		page.Entries = r.appendRepos(page.Entries)
+		return page, nil
+	}
+	page, err := st.ListPage(ctx, rest, token, limit)
+	if err != nil {
+		return Page{}, err
+	}
+	r.rebase(clean, page.Entries)
+	return page, nil
+}
+
+// Walk visits the store owning prefix, or every store for the root.
+func (r *Router) Walk(ctx context.Context, prefix string, fn func(Entry) error) error {
+	clean := strings.Trim(path.Clean("/"+prefix), "/")
+	st, rest := r.route(clean)
+	if clean != "" && st != r.def {
+		repo, _, _ := strings.Cut(clean, "/")
+		return st.Walk(ctx, rest, func(e Entry) error {
+			e.Path = path.Join(repo, e.Path)
+			return fn(e)
+		})
+	}
+	if err := r.def.Walk(ctx, prefix, fn); err != nil || clean != "" {
+		return err
+	}
+	for repo, st := range r.routes {
+		err := st.Walk(ctx, "", func(e Entry) error {
+			e.Path = path.Join(repo, e.Path)
+			return fn(e)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebase rewrites entry paths from a mapped store to the public key space.
+func (r *Router) rebase(prefix string, entries []Entry) {
+	for i, e := range entries {
+		entries[i].Path = path.Join(prefix, strings.TrimSuffix(e.Name, "/"))
+		if e.Type == "dir" {
+			entries[i].Path += "/"
+		}
+	}
+}
+
+func (r *Router) listRoot(ctx context.Context, limit int32) ([]Entry, error) {
+	entries, err := r.def.List(ctx, "", limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.appendRepos(entries), nil
+}
+
+// appendRepos adds mapped repositories missing from a root listing.
+func (r *Router) appendRepos(entries []Entry) []Entry {
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		seen[strings.TrimSuffix(e.Name, "/")] = true
+	}
+	repos := make([]string, 0, len(r.routes))
+	for repo := range r.routes {
+		if !seen[repo] {
+			repos = append(repos, repo)
+		}
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		entries = append(entries, Entry{Name: repo + "/", Path: repo + "/", Type: "dir"})
+	}
+	return entries
+}
+
+// GenerateChecksums runs against every store for a root scan, otherwise
+// against the store owning prefix.
+func (r *Router) GenerateChecksums(ctx context.Context, prefix string) (ChecksumStats, error) {
+	return r.eachStore(ctx, prefix, (*Store).GenerateChecksums)
+}
+
+func (r *Router) CleanupBadChecksums(ctx context.Context, prefix string) (ChecksumStats, error) {
+	return r.eachStore(ctx, prefix, (*Store).CleanupBadChecksums)
+}
+
+func (r *Router) eachStore(ctx context.Context, prefix string, fn func(*Store, context.Context, string) (ChecksumStats, error)) (ChecksumStats, error) {
+	if strings.Trim(path.Clean("/"+prefix), "/") != "" {
+		st, rest := r.route(prefix)
+		return fn(st, ctx, rest)
+	}
+
+	total, err := fn(r.def, ctx, prefix)
+	if err != nil {
+		return total, err
+	}
+	for _, st := range r.routes {
+		stats, err := fn(st, ctx, "")
+		total.Objects += stats.Objects
+		total.Created += stats.Created
+		total.Deleted += stats.Deleted
+		total.Orphaned += stats.Orphaned
+		total.Invalid += stats.Invalid
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// CollectBlobs collects the unreferenced blobs of every store.
+func (r *Router) CollectBlobs(ctx context.Context) (CASStats, error) {
+	total, err := r.def.CollectBlobs(ctx)
+	if err != nil {
+		return total, err
+	}
+	for _, st := range r.routes {
+		stats, err := st.CollectBlobs(ctx)
+		total.Pointers += stats.Pointers
+		total.Blobs += stats.Blobs
+		total.Deleted += stats.Deleted
+		total.Freed += stats.Freed
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRouterRoutesByRepository(t *testing.T) {
+	def := newTestStore("")
+	legacy := newTestStore("maven2")
+	r := NewRouter(def, map[string]*Store{"legacy": legacy})
+	ctx := context.Background()
+
+	body := bytes.NewReader([]byte("old"))
+	if err := r.Put(ctx, "legacy/com/acme/app.jar", body, "application/java-archive", int64(body.Len())); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if _, ok := legacy.client.(*fakeS3).objects["maven2/com/acme/app.jar"]; !ok {
+		t.Fatalf("expected object in legacy bucket under its prefix")
+	}
+	if len(def.client.(*fakeS3).objects) != 0 {
+		t.Fatalf("default bucket must stay untouched")
+	}
+
+	entries, err := r.List(ctx, "legacy/com/acme", 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "legacy/com/acme/app.jar" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	def.client.(*fakeS3).objects["releases/app.jar"] = fakeObj{body: []byte("new")}
+	roots, err := r.List(ctx, "", 10)
+	if err != nil {
+		t.Fatalf("list root: %v", err)
+	}
+	if len(roots) != 2 || roots[0].Name != "releases/" || roots[1].Name != "legacy/" {
+		t.Fatalf("unexpected roots: %+v", roots)
+	}
+}
+
+func TestRouterCopyAcrossStores(t *testing.T) {
+	def := newTestStore("")
+	legacy := newTestStore("")
+	legacy.client.(*fakeS3).objects["app.jar"] = fakeObj{body: []byte("payload"), contentType: "application/java-archive"}
+	r := NewRouter(def, map[string]*Store{"legacy": legacy})
+
+	if err := r.Copy(context.Background(), "legacy/app.jar", "releases/app.jar"); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	out, err := r.Get(context.Background(), "releases/app.jar")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	data, _ := io.ReadAll(out.Body)
+	if string(data) != "payload" {
+		t.Fatalf("unexpected body %q", data)
+	}
+}
+
+func TestRouterComposeWithinOneStore(t *testing.T) {
+	def := newTestStore("")
+	legacy := newTestStore("")
+	def.client.(*fakeS3).objects["__uploads__/u1/0"] = fakeObj{body: []byte("payload")}
+	r := NewRouter(def, map[string]*Store{"legacy": legacy})
+	ctx := context.Background()
+	parts := []Part{{Key: "__uploads__/u1/0", Size: 7}}
+
+	if err := r.Compose(ctx, "legacy/app.jar", parts, "application/java-archive"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("expected parts in another store to be declined, got %v", err)
+	}
+	if err := r.Compose(ctx, "releases/app.jar", parts, "application/java-archive"); err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+	if got := def.client.(*fakeS3).objects["releases/app.jar"].body; string(got) != "payload" {
+		t.Fatalf("unexpected body %q", got)
+	}
+}
+
+func TestRouterEventKey(t *testing.T) {
+	def := newTestStore("")
+	legacy := newTestStore("maven2")
+	legacy.bucket = "legacy-bucket"
+	r := NewRouter(def, map[string]*Store{"legacy": legacy})
+
+	for _, tc := range []struct {
+		bucket, key, want string
+		ok                bool
+	}{
+		{"bucket", "releases/com/acme/app.jar", "releases/com/acme/app.jar", true},
+		{"legacy-bucket", "maven2/com/acme/app.jar", "legacy/com/acme/app.jar", true},
+		{"legacy-bucket", "other/com/acme/app.jar", "", false},
+		{"unknown", "releases/app.jar", "", false},
+	} {
+		got, ok := r.EventKey(tc.bucket, tc.key)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("EventKey(%q, %q) = %q, %t; want %q, %t", tc.bucket, tc.key, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestRouterBucketKey(t *testing.T) {
+	def := newTestStore("")
+	legacy := newTestStore("maven2")
+	legacy.bucket = "legacy-bucket"
+	r := NewRouter(def, map[string]*Store{"legacy": legacy})
+
+	if b, k := r.BucketKey("releases/app.jar"); b != "bucket" || k != "releases/app.jar" {
+		t.Fatalf("default store: %s %s", b, k)
+	}
+	if b, k := r.BucketKey("legacy/com/acme/app.jar"); b != "legacy-bucket" || k != "maven2/com/acme/app.jar" {
+		t.Fatalf("routed store: %s %s", b, k)
+	}
+}
@@ -1,16 +1,22 @@
 package storage
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"crypto/md5"
-	"crypto/sha1"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/url"
 	"path"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -18,6 +24,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/smithy-go"
 )
 
@@ -29,14 +36,70 @@ type Options struct {
 	AccessKey    string
 	SecretKey    string
 	UsePathStyle bool
+
+	// RetryMaxAttempts, RetryMode ("standard" or "adaptive") and
+	// RetryMaxBackoff tune the SDK retryer; zero values keep SDK defaults.
+	RetryMaxAttempts int
+	RetryMode        string
+	RetryMaxBackoff  time.Duration
+	// OnRetryExhausted, if set, is called with the S3 operation name when
+	// a call fails after exhausting its retries.
+	OnRetryExhausted func(op string)
+
+	Timeouts Timeouts
+	// Transport tunes the connections to S3.
+	Transport Transport
+
+	// PutMode selects how uploads are sent: PutModeDirect (default) or
+	// PutModePresigned.
+	PutMode string
+	// ChecksumAlgorithm (CRC32, CRC32C, SHA1 or SHA256) adds an S3
+	// full-object integrity checksum to every upload.
+	ChecksumAlgorithm string
+
+	// CAS stores uploads of at least CASMinSize bytes (default
+	// DefaultCASMinSize) once per sha256 below CASPrefix, with an empty
+	// pointer object at the artifact key.
+	CAS        bool
+	CASMinSize int64
+
+	// WORMPrefixes are write-once: objects below them can be written once
+	// and never overwritten or deleted. ObjectLock (ObjectLockGovernance,
+	// ObjectLockCompliance or ObjectLockLegalHold) also locks them with S3
+	// Object Lock, for ObjectLockRetention in the first two modes.
+	WORMPrefixes        []string
+	ObjectLock          string
+	ObjectLockRetention time.Duration
+
+	// SidecarChecksums are the digests GenerateChecksums writes next to
+	// objects (see ParseSidecarChecksums); DefaultSidecarChecksums when
+	// empty.
+	SidecarChecksums []string
 }
 
+const (
+	// PutModeDirect uploads with the SDK's PutObject.
+	PutModeDirect = "direct"
+	// PutModePresigned presigns the upload and sends it with net/http.
+	PutModePresigned = "presigned"
+)
+
 type Store struct {
 	client     s3API
 	presign    presignAPI
 	httpClient *http.Client
 	bucket     string
+	region     string
 	prefix     string
+	timeouts   Timeouts
+	putMode    string
+	checksum   types.ChecksumAlgorithm
+	cas        bool
+	casMinSize int64
+	worm       wormPolicy
+	// sidecars are the digests ensureChecksums writes, the defaults when
+	// empty.
+	sidecars []string
 }
 
 type s3API interface {
@@ -45,6 +108,18 @@ type s3API interface {
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
 	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error)
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	UploadPartCopy(ctx context.Context, params *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
 }
 
 type presignAPI interface {
@@ -52,10 +127,19 @@ type presignAPI interface {
 }
 
 type Entry struct {
-	Name string `json:"name"`
-	Path string `json:"path"`
-	Type string `json:"type"` // file, dir, proxy
-	Size int64  `json:"size,omitempty"`
+	Name         string     `json:"name"`
+	Path         string     `json:"path"`
+	Type         string     `json:"type"` // file, dir, proxy
+	Size         int64      `json:"size,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	StorageClass string     `json:"storageClass,omitempty"`
+	// ETag is the object ETag without quotes; SHA1 is the digest read from
+	// the .sha1 sidecar, filled by the catalog on request.
+	ETag string `json:"etag,omitempty"`
+	SHA1 string `json:"sha1,omitempty"`
+	// Source tells where a catalog entry comes from: "local" for hosted
+	// content, or the name of the proxy it is (or is cached) from.
+	Source string `json:"source,omitempty"`
 }
 
 func New(ctx context.Context, opts Options) (*Store, error) {
@@ -85,6 +169,37 @@ func New(ctx context.Context, opts Options) (*Store, error) {
 		cfgLoaders = append(cfgLoaders, config.WithEndpointResolverWithOptions(resolver))
 	}
 
+	putMode := strings.ToLower(opts.PutMode)
+	switch putMode {
+	case "":
+		putMode = PutModeDirect
+	case PutModeDirect, PutModePresigned:
+	default:
+		return nil, fmt.Errorf("unknown put mode %q (want direct or presigned)", opts.PutMode)
+	}
+
+	checksum, err := parseChecksumAlgorithm(opts.ChecksumAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	worm, err := newWORMPolicy(opts.WORMPrefixes, opts.ObjectLock, opts.ObjectLockRetention)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Transport != (Transport{}) {
+		cfgLoaders = append(cfgLoaders, config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(opts.Transport.Apply)))
+	}
+
+	retryer, err := newRetryer(opts)
+	if err != nil {
+		return nil, err
+	}
+	if retryer != nil {
+		cfgLoaders = append(cfgLoaders, config.WithRetryer(retryer))
+	}
+
 	awsCfg, err := config.LoadDefaultConfig(ctx, cfgLoaders...)
 	if err != nil {
 		return nil, fmt.Errorf("load aws config: %w", err)
@@ -95,12 +210,25 @@ func New(ctx context.Context, opts Options) (*Store, error) {
 		o.DisableLogOutputChecksumValidationSkipped = true
 	})
 
+	var api s3API = client
+	if opts.OnRetryExhausted != nil {
+		api = retryObserver{s3API: client, onExhausted: opts.OnRetryExhausted}
+	}
+
 	return &Store{
-		client:     client,
+		client:     api,
 		presign:    s3.NewPresignClient(client),
-		httpClient: http.DefaultClient,
+		httpClient: &http.Client{Transport: opts.Transport.NewHTTPTransport()},
 		bucket:     opts.Bucket,
+		region:     opts.Region,
 		prefix:     strings.Trim(opts.Prefix, "/"),
+		timeouts:   opts.Timeouts,
+		putMode:    putMode,
+		checksum:   checksum,
+		cas:        opts.CAS,
+		casMinSize: cmp.Or(opts.CASMinSize, DefaultCASMinSize),
+		worm:       worm,
+		sidecars:   opts.SidecarChecksums,
 	}, nil
 }
 
@@ -129,10 +257,30 @@ func (s *Store) Get(ctx context.Context, key string) (*s3.GetObjectOutput, error
 	if err != nil {
 		return nil, err
 	}
-	return s.client.GetObject(ctx, &s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(k),
-	})
+	}
+	d := s.timeouts.Get
+	if d <= 0 {
+		out, err := s.client.GetObject(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return s.resolveGet(ctx, out)
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, d)
+	out, err := s.client.GetObject(opCtx, input)
+	if err == nil {
+		out, err = s.resolveGet(opCtx, out)
+	}
+	if err != nil {
+		cancel()
+		return nil, timeoutError(ctx, opCtx, "GetObject", d, err)
+	}
+	out.Body = &timedBody{ReadCloser: out.Body, parent: ctx, ctx: opCtx, cancel: cancel, timeout: d}
+	return out, nil
 }
 
 func (s *Store) Head(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
@@ -140,21 +288,42 @@ func (s *Store) Head(ctx context.Context, key string) (*s3.HeadObjectOutput, err
 	if err != nil {
 		return nil, err
 	}
-	return s.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(k),
+	var out *s3.HeadObjectOutput
+	err = timed(ctx, "HeadObject", s.timeouts.Head, func(ctx context.Context) error {
+		out, err = s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(k),
+		})
+		return err
 	})
+	return resolveHead(out), err
 }
 
 func (s *Store) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64) error {
+	return s.PutWithMetadata(ctx, key, body, contentType, contentLength, nil)
+}
+
+// PutWithMetadata is Put with user metadata stored in the same request, so
+// the object never exists without it.
+func (s *Store) PutWithMetadata(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64, metadata map[string]string) error {
 	k, err := s.cleanKey(key)
 	if err != nil {
 		return err
 	}
+	err = timed(ctx, "PutObject", s.timeouts.Put, func(ctx context.Context) error {
+		if s.usesCAS(k, contentLength) {
+			return s.putCAS(ctx, k, body, contentType, contentLength, metadata)
+		}
+		return s.putObject(ctx, k, body, contentType, contentLength, metadata)
+	})
+	return s.immutable(k, err)
+}
 
+func (s *Store) putObject(ctx context.Context, k string, body io.ReadSeeker, contentType string, contentLength int64, metadata map[string]string) error {
 	putInput := &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(k),
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(k),
+		Metadata: metadata,
 	}
 	if contentType != "" {
 		putInput.ContentType = aws.String(contentType)
@@ -162,12 +331,59 @@ func (s *Store) Put(ctx context.Context, key string, body io.ReadSeeker, content
 	if contentLength >= 0 {
 		putInput.ContentLength = aws.Int64(contentLength)
 	}
+	s.lockPut(putInput)
+
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek body: %w", err)
+	}
+
+	var sum string
+	if s.checksum != "" {
+		var err error
+		if sum, err = addChecksum(putInput, s.checksum, body); err != nil {
+			return err
+		}
+	}
+
+	if s.putMode == PutModePresigned {
+		return s.putPresigned(ctx, putInput, body, contentLength)
+	}
+	return s.putDirect(ctx, putInput, body, sum)
+}
 
+// putDirect uploads through the SDK client (so retries and timeouts apply)
+// with a Content-MD5 header the server verifies on receipt. With a checksum
+// algorithm configured, the checksum S3 stored is compared with ours.
+func (s *Store) putDirect(ctx context.Context, input *s3.PutObjectInput, body io.ReadSeeker, sum string) error {
+	h := md5.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return fmt.Errorf("hash body: %w", err)
+	}
 	if _, err := body.Seek(0, io.SeekStart); err != nil {
 		return fmt.Errorf("seek body: %w", err)
 	}
+	input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	input.Body = body
+
+	out, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		if IsChecksumMismatch(err) {
+			return fmt.Errorf("upload: %w", ChecksumMismatchError{Key: aws.ToString(input.Key), Expected: sum})
+		}
+		return fmt.Errorf("upload: %w", err)
+	}
+	if sum != "" {
+		if stored := storedChecksum(out, s.checksum); stored != "" && stored != sum {
+			return ChecksumMismatchError{Key: aws.ToString(input.Key), Expected: sum, Actual: stored}
+		}
+	}
+	return nil
+}
 
-	psReq, err := s.presign.PresignPutObject(ctx, putInput)
+// putPresigned presigns the PutObject request and sends it with the plain
+// HTTP client. Kept for S3-compatible stores that reject the SDK's upload.
+func (s *Store) putPresigned(ctx context.Context, input *s3.PutObjectInput, body io.ReadSeeker, contentLength int64) error {
+	psReq, err := s.presign.PresignPutObject(ctx, input)
 	if err != nil {
 		return fmt.Errorf("presign put: %w", err)
 	}
@@ -188,8 +404,14 @@ func (s *Store) Put(ctx context.Context, key string, body io.ReadSeeker, content
 		return fmt.Errorf("upload: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("upload: %w", &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "precondition failed"})
+	}
 	if resp.StatusCode >= 300 {
 		slurp, _ := io.ReadAll(resp.Body)
+		if bytes.Contains(slurp, []byte("BadDigest")) || bytes.Contains(slurp, []byte("XAmzContentChecksumMismatch")) {
+			return fmt.Errorf("upload: %w", ChecksumMismatchError{Key: aws.ToString(input.Key)})
+		}
 		return fmt.Errorf("upload failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(slurp)))
 	}
 
@@ -201,151 +423,281 @@ func (s *Store) Delete(ctx context.Context, key string) error {
 	if err != nil {
 		return err
 	}
-	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(k),
+	if s.writeOnce(k) {
+		return ImmutableError{Key: strings.TrimPrefix(key, "/")}
+	}
+	return timed(ctx, "DeleteObject", s.timeouts.Head, func(ctx context.Context) error {
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(k),
+		})
+		return err
 	})
-	return err
 }
 
-func (s *Store) putAbsolute(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64) error {
-	putInput := &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-	}
-	if contentType != "" {
-		putInput.ContentType = aws.String(contentType)
-	}
-	if contentLength >= 0 {
-		putInput.ContentLength = aws.Int64(contentLength)
-	}
-
-	if _, err := body.Seek(0, io.SeekStart); err != nil {
-		return fmt.Errorf("seek body: %w", err)
+// Touch sets metadata on the object in place (server-side copy), which also
+// resets its LastModified timestamp. Keys not in metadata keep their value.
+func (s *Store) Touch(ctx context.Context, key string, metadata map[string]string) error {
+	k, err := s.cleanKey(key)
+	if err != nil {
+		return err
 	}
+	return timed(ctx, "CopyObject", s.timeouts.Put, func(ctx context.Context) error {
+		head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(k),
+		})
+		if err != nil {
+			return err
+		}
+		// The copy replaces the metadata, so start from what is stored;
+		// pointers keep their blob whatever the caller passes.
+		merged := maps.Clone(head.Metadata)
+		if merged == nil {
+			merged = make(map[string]string)
+		}
+		maps.Copy(merged, metadata)
+		if digest := head.Metadata[casDigestMeta]; digest != "" {
+			for _, m := range []string{casDigestMeta, casSizeMeta, casETagMeta} {
+				merged[m] = head.Metadata[m]
+			}
+		}
+		source := (&url.URL{Path: s.bucket + "/" + k}).EscapedPath()
+		input := &s3.CopyObjectInput{
+			Bucket:            aws.String(s.bucket),
+			Key:               aws.String(k),
+			CopySource:        aws.String(source),
+			ContentType:       head.ContentType,
+			Metadata:          merged,
+			MetadataDirective: types.MetadataDirectiveReplace,
+			StorageClass:      types.StorageClass(head.StorageClass),
+		}
+		s.lockCopy(input)
+		_, err = s.client.CopyObject(ctx, input)
+		return err
+	})
+}
 
-	psReq, err := s.presign.PresignPutObject(ctx, putInput)
+// Copy duplicates an object server-side, keeping its content type and metadata.
+func (s *Store) Copy(ctx context.Context, src, dst string) error {
+	srcKey, err := s.cleanKey(src)
 	if err != nil {
-		return fmt.Errorf("presign put: %w", err)
+		return err
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, psReq.URL, io.NopCloser(body))
+	dstKey, err := s.cleanKey(dst)
 	if err != nil {
-		return fmt.Errorf("build put request: %w", err)
+		return err
 	}
-	req.ContentLength = contentLength
-	for k, vals := range psReq.SignedHeader {
-		for _, v := range vals {
-			req.Header.Add(k, v)
+	err = timed(ctx, "CopyObject", s.timeouts.Put, func(ctx context.Context) error {
+		input := &s3.CopyObjectInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(dstKey),
+			CopySource: aws.String((&url.URL{Path: s.bucket + "/" + srcKey}).EscapedPath()),
 		}
-	}
+		if s.writeOnce(dstKey) {
+			input.IfNoneMatch = aws.String("*")
+			s.lockCopy(input)
+		}
+		_, err := s.client.CopyObject(ctx, input)
+		return err
+	})
+	return s.immutable(dstKey, err)
+}
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("upload: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		slurp, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("upload failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(slurp)))
-	}
-	return nil
+func (s *Store) putAbsolute(ctx context.Context, key string, body io.ReadSeeker, contentType string, contentLength int64) error {
+	return s.putObject(ctx, key, body, contentType, contentLength, nil)
+}
+
+// Page is one page of a listing; NextToken is empty on the last page.
+type Page struct {
+	Entries   []Entry `json:"entries"`
+	NextToken string  `json:"nextToken,omitempty"`
 }
 
+// List returns up to limit entries directly under prefix.
 func (s *Store) List(ctx context.Context, prefix string, limit int32) ([]Entry, error) {
-	p := strings.TrimPrefix(path.Clean("/"+prefix), "/")
-	if p != "" && !strings.HasSuffix(p, "/") {
-		p += "/"
+	if limit <= 0 {
+		limit = 100
 	}
-	if s.prefix != "" {
-		p = strings.TrimPrefix(path.Join(s.prefix, p), "/")
-		if p != "" && !strings.HasSuffix(p, "/") {
-			p += "/"
+	var entries []Entry
+	err := timed(ctx, "ListObjectsV2", s.timeouts.List, func(ctx context.Context) error {
+		token := ""
+		for {
+			page, err := s.listPage(ctx, prefix, token, limit-int32(len(entries)))
+			if err != nil {
+				return err
+			}
+			entries = append(entries, page.Entries...)
+			if int32(len(entries)) >= limit || page.NextToken == "" {
+				return nil
+			}
+			token = page.NextToken
 		}
+	})
+	if int32(len(entries)) > limit {
+		entries = entries[:limit]
 	}
+	return entries, err
+}
 
+// ListPage returns one page of at most limit entries directly under prefix,
+// starting at token (empty for the first page).
+func (s *Store) ListPage(ctx context.Context, prefix, token string, limit int32) (Page, error) {
 	if limit <= 0 {
 		limit = 100
 	}
+	var page Page
+	err := timed(ctx, "ListObjectsV2", s.timeouts.List, func(ctx context.Context) error {
+		var err error
+		page, err = s.listPage(ctx, prefix, token, limit)
+		return err
+	})
+	return page, err
+}
 
-	var keys []Entry
-	basePath := strings.TrimSuffix(p, "/")
-	var token *string
+// listPrefix returns the bucket prefix for a listing and the path entries
+// are reported under.
+func (s *Store) listPrefix(prefix string) (string, string) {
+	basePath := strings.TrimPrefix(path.Clean("/"+prefix), "/")
+	p := basePath
+	if s.prefix != "" {
+		p = strings.TrimPrefix(path.Join(s.prefix, p), "/")
+	}
+	if p != "" && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p, basePath
+}
 
-	for {
-		pageLimit := limit
-		remaining := limit - int32(len(keys))
-		if remaining > 0 {
-			pageLimit = remaining
+func (s *Store) listPage(ctx context.Context, prefix, token string, limit int32) (Page, error) {
+	p, basePath := s.listPrefix(prefix)
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(p),
+		MaxKeys:   aws.Int32(limit),
+		Delimiter: aws.String("/"),
+	}
+	if token != "" {
+		input.ContinuationToken = aws.String(token)
+	}
+	out, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return Page{}, err
+	}
+
+	var page Page
+	var listed []string
+	for _, cp := range out.CommonPrefixes {
+		if cp.Prefix == nil {
+			continue
+		}
+		k := strings.TrimPrefix(*cp.Prefix, p)
+		k = strings.TrimSuffix(k, "/")
+		if k != "" {
+			page.Entries = append(page.Entries, Entry{
+				Name: k + "/",
+				Path: path.Join(basePath, k) + "/",
+				Type: "dir",
+			})
+			listed = append(listed, "")
+		}
+	}
+	for _, obj := range out.Contents {
+		if obj.Key == nil {
+			continue
+		}
+		if *obj.Key == p || *obj.Key == strings.TrimSuffix(p, "/") {
+			continue
 		}
+		k := strings.TrimPrefix(*obj.Key, p)
+		if strings.Contains(k, "/") {
+			// deeper levels ignored because of delimiter; should not happen
+			continue
+		}
+		if k != "" {
+			page.Entries = append(page.Entries, fileEntry(k, path.Join(basePath, k), obj))
+			listed = append(listed, *obj.Key)
+		}
+	}
+	s.resolveSizes(ctx, page.Entries, listed)
 
-		out, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-			Bucket:            aws.String(s.bucket),
-			Prefix:            aws.String(p),
-			MaxKeys:           aws.Int32(pageLimit),
-			Delimiter:         aws.String("/"),
-			ContinuationToken: token,
+	if aws.ToBool(out.IsTruncated) && out.NextContinuationToken != nil {
+		page.NextToken = *out.NextContinuationToken
+	}
+	return page, nil
+}
+
+// Walk calls fn for every object below prefix, at any depth, one S3 page at
+// a time so arbitrarily large trees can be visited in constant memory.
+// Returning an error from fn stops the walk.
+func (s *Store) Walk(ctx context.Context, prefix string, fn func(Entry) error) error {
+	p, basePath := s.listPrefix(prefix)
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(p),
+		MaxKeys: aws.Int32(1000),
+	}
+	for {
+		var out *s3.ListObjectsV2Output
+		err := timed(ctx, "ListObjectsV2", s.timeouts.List, func(ctx context.Context) error {
+			var err error
+			out, err = s.client.ListObjectsV2(ctx, input)
+			return err
 		})
 		if err != nil {
-			return nil, err
-		}
-
-		for _, cp := range out.CommonPrefixes {
-			if cp.Prefix == nil {
-				continue
-			}
-			k := strings.TrimPrefix(*cp.Prefix, p)
-			k = strings.TrimSuffix(k, "/")
-			if k != "" {
-				keys = append(keys, Entry{
-					Name: k + "/",
-					Path: path.Join(basePath, k) + "/",
-					Type: "dir",
-				})
-			}
+			return err
 		}
+		var entries []Entry
+		var listed []string
 		for _, obj := range out.Contents {
-			if obj.Key == nil {
-				continue
-			}
-			if *obj.Key == p || *obj.Key == strings.TrimSuffix(p, "/") {
+			rel := strings.TrimPrefix(aws.ToString(obj.Key), p)
+			if rel == "" || strings.HasSuffix(rel, "/") {
 				continue
 			}
-			k := strings.TrimPrefix(*obj.Key, p)
-			if strings.Contains(k, "/") {
-				// deeper levels ignored because of delimiter; should not happen
-				continue
-			}
-			if k != "" {
-				size := int64(0)
-				if obj.Size != nil {
-					size = *obj.Size
-				}
-				keys = append(keys, Entry{
-					Name: k,
-					Path: path.Join(basePath, k),
-					Type: "file",
-					Size: size,
-				})
+			entries = append(entries, fileEntry(path.Base(rel), path.Join(basePath, rel), obj))
+			listed = append(listed, aws.ToString(obj.Key))
+		}
+		s.resolveSizes(ctx, entries, listed)
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
 			}
 		}
-
-		if int32(len(keys)) >= limit {
-			keys = keys[:limit]
-			break
+		if !aws.ToBool(out.IsTruncated) || out.NextContinuationToken == nil {
+			return nil
 		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+}
 
-		if out.IsTruncated != nil && *out.IsTruncated && out.NextContinuationToken != nil {
-			token = out.NextContinuationToken
-			continue
-		}
-		break
+func fileEntry(name, p string, obj types.Object) Entry {
+	return Entry{
+		Name:         name,
+		Path:         p,
+		Type:         "file",
+		Size:         aws.ToInt64(obj.Size),
+		LastModified: obj.LastModified,
+		StorageClass: string(obj.StorageClass),
+		ETag:         strings.Trim(aws.ToString(obj.ETag), `"`),
 	}
+}
 
-	return keys, nil
+// maxChecksumFileSize bounds sidecars read by CleanupBadChecksums; anything
+// larger cannot be a digest (plus file name).
+const maxChecksumFileSize = 1024
+
+// ChecksumStats summarises one checksum scan pass. Deleted counts chained
+// checksums (.sha1.md5, ...); Orphaned and Invalid count sidecars removed
+// because their artifact is gone or they do not hold a hex digest.
+type ChecksumStats struct {
+	Objects  int
+	Created  int
+	Deleted  int
+	Orphaned int
+	Invalid  int
 }
 
-func (s *Store) GenerateChecksums(ctx context.Context, prefix string) error {
+func (s *Store) GenerateChecksums(ctx context.Context, prefix string) (ChecksumStats, error) {
 	p := strings.TrimPrefix(path.Clean("/"+prefix), "/")
 	if s.prefix != "" {
 		p = path.Join(s.prefix, p)
@@ -353,6 +705,8 @@ func (s *Store) GenerateChecksums(ctx context.Context, prefix string) error {
 	p = strings.TrimPrefix(p, "/")
 
 	var token *string
+	var stats ChecksumStats
+	casRoot := strings.TrimSuffix(s.key(CASPrefix), "/") + "/"
 
 	for {
 		out, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
@@ -361,7 +715,7 @@ func (s *Store) GenerateChecksums(ctx context.Context, prefix string) error {
 			ContinuationToken: token,
 		})
 		if err != nil {
-			return err
+			return stats, err
 		}
 
 		for _, obj := range out.Contents {
@@ -369,12 +723,15 @@ func (s *Store) GenerateChecksums(ctx context.Context, prefix string) error {
 				continue
 			}
 			key := *obj.Key
-			if strings.HasSuffix(key, "/") || strings.HasSuffix(key, ".sha1") || strings.HasSuffix(key, ".md5") {
+			if strings.HasSuffix(key, "/") || IsChecksumSidecar(key) || strings.HasPrefix(key, casRoot) {
 				continue
 			}
 
-			if err := s.ensureChecksums(ctx, key); err != nil {
-				return err
+			stats.Objects++
+			created, err := s.ensureChecksums(ctx, key)
+			stats.Created += created
+			if err != nil {
+				return stats, err
 			}
 		}
 
@@ -385,10 +742,13 @@ func (s *Store) GenerateChecksums(ctx context.Context, prefix string) error {
 		break
 	}
 
-	return nil
+	return stats, nil
 }
 
-func (s *Store) CleanupBadChecksums(ctx context.Context, prefix string) error {
+// CleanupBadChecksums deletes chained checksums, checksum sidecars whose
+// artifact no longer exists and sidecars that do not hold a hex digest of the
+// right length.
+func (s *Store) CleanupBadChecksums(ctx context.Context, prefix string) (ChecksumStats, error) {
 	p := strings.TrimPrefix(path.Clean("/"+prefix), "/")
 	if s.prefix != "" {
 		p = path.Join(s.prefix, p)
@@ -396,7 +756,28 @@ func (s *Store) CleanupBadChecksums(ctx context.Context, prefix string) error {
 	p = strings.TrimPrefix(p, "/")
 
 	var token *string
-	badSuffixes := []string{".sha1.sha1", ".sha1.md5", ".md5.sha1", ".md5.md5"}
+	var stats ChecksumStats
+	var badSuffixes []string
+	for _, outer := range SidecarExtensions {
+		for _, inner := range SidecarExtensions {
+			badSuffixes = append(badSuffixes, inner+outer)
+		}
+	}
+	// bases holds the listed keys that prefix the current one. Listings are
+	// sorted and a key sorts before everything it prefixes, so an artifact is
+	// always on this stack when its sidecars come up.
+	var bases []string
+
+	del := func(key string) bool {
+		if s.writeOnce(key) {
+			return false
+		}
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		return err == nil
+	}
 
 	for {
 		out, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
@@ -405,7 +786,7 @@ func (s *Store) CleanupBadChecksums(ctx context.Context, prefix string) error {
 			ContinuationToken: token,
 		})
 		if err != nil {
-			return err
+			return stats, err
 		}
 
 		for _, obj := range out.Contents {
@@ -413,14 +794,52 @@ func (s *Store) CleanupBadChecksums(ctx context.Context, prefix string) error {
 				continue
 			}
 			key := *obj.Key
-			for _, suf := range badSuffixes {
-				if strings.HasSuffix(key, suf) {
-					_, _ = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
-						Bucket: aws.String(s.bucket),
-						Key:    aws.String(key),
-					})
-					break
+			stats.Objects++
+			for len(bases) > 0 && !strings.HasPrefix(key, bases[len(bases)-1]) {
+				bases = bases[:len(bases)-1]
+			}
+
+			if slices.ContainsFunc(badSuffixes, func(suf string) bool { return strings.HasSuffix(key, suf) }) {
+				if del(key) {
+					stats.Deleted++
+				}
+				continue
+			}
+			digestLen := 0
+			switch {
+			case strings.HasSuffix(key, ".sha1"):
+				digestLen = 40
+			case strings.HasSuffix(key, ".md5"):
+				digestLen = 32
+			case strings.HasSuffix(key, ".sha256"):
+				digestLen = 64
+			case strings.HasSuffix(key, ".sha512"):
+				digestLen = 128
+			default:
+				bases = append(bases, key)
+				continue
+			}
+
+			base := key[:strings.LastIndex(key, ".")]
+			if !slices.Contains(bases, base) {
+				orphan, err := s.isOrphan(ctx, base)
+				if err != nil {
+					return stats, err
 				}
+				if orphan {
+					if del(key) {
+						stats.Orphaned++
+					}
+					continue
+				}
+			}
+
+			valid, err := s.validDigest(ctx, key, aws.ToInt64(obj.Size), digestLen)
+			if err != nil {
+				return stats, err
+			}
+			if !valid && del(key) {
+				stats.Invalid++
 			}
 		}
 
@@ -431,69 +850,100 @@ func (s *Store) CleanupBadChecksums(ctx context.Context, prefix string) error {
 		break
 	}
 
-	return nil
+	return stats, nil
 }
 
-func (s *Store) ensureChecksums(ctx context.Context, key string) error {
-	needsSha1 := false
-	needsMd5 := false
-
-	if _, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+// isOrphan confirms with a HEAD that base is gone; it is only reached when
+// base was not listed just before its sidecar (e.g. outside the scan prefix).
+func (s *Store) isOrphan(ctx context.Context, base string) (bool, error) {
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key + ".sha1"),
-	}); err != nil {
-		if IsNotFound(err) {
-			needsSha1 = true
-		} else {
-			return err
-		}
+		Key:    aws.String(base),
+	})
+	if IsNotFound(err) {
+		return true, nil
 	}
+	return false, err
+}
 
-	if _, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+// validDigest reports whether the sidecar at key starts with a hex digest of
+// digestLen characters ("digest" or "digest  filename").
+func (s *Store) validDigest(ctx context.Context, key string, size int64, digestLen int) (bool, error) {
+	if size < int64(digestLen) || size > maxChecksumFileSize {
+		return false, nil
+	}
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key + ".md5"),
-	}); err != nil {
+		Key:    aws.String(key),
+	})
+	if err != nil {
 		if IsNotFound(err) {
-			needsMd5 = true
-		} else {
-			return err
+			return true, nil
 		}
+		return false, err
+	}
+	defer obj.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(obj.Body, maxChecksumFileSize))
+	if err != nil {
+		return false, err
 	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 || len(fields[0]) != digestLen {
+		return false, nil
+	}
+	_, err = hex.DecodeString(fields[0])
+	return err == nil, nil
+}
 
-	if !needsSha1 && !needsMd5 {
-		return nil
+// ensureChecksums writes the missing sidecars of key and returns how many
+// it created.
+func (s *Store) ensureChecksums(ctx context.Context, key string) (int, error) {
+	names := s.sidecars
+	if len(names) == 0 {
+		names = DefaultSidecarChecksums
+	}
+	var missing []string
+	for _, name := range names {
+		if _, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key + "." + name),
+		}); err != nil {
+			if !IsNotFound(err) {
+				return 0, err
+			}
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
 	}
 
 	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
+	if err == nil {
+		obj, err = s.resolveGet(ctx, obj)
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer obj.Body.Close()
 
-	sha1h := sha1.New()
-	md5h := md5.New()
-	if _, err := io.Copy(io.MultiWriter(sha1h, md5h), obj.Body); err != nil {
-		return err
+	sums := NewChecksums(missing...)
+	if _, err := io.Copy(sums, obj.Body); err != nil {
+		return 0, err
 	}
 
-	if needsSha1 {
-		sum := hex.EncodeToString(sha1h.Sum(nil))
-		if err := s.putAbsolute(ctx, key+".sha1", strings.NewReader(sum), "text/plain", int64(len(sum))); err != nil {
-			return err
+	created := 0
+	for _, name := range missing {
+		sum := sums.Sum(name)
+		if err := s.putAbsolute(ctx, key+"."+name, strings.NewReader(sum), "text/plain", int64(len(sum))); err != nil {
+			return created, err
 		}
+		created++
 	}
-
-	if needsMd5 {
-		sum := hex.EncodeToString(md5h.Sum(nil))
-		if err := s.putAbsolute(ctx, key+".md5", strings.NewReader(sum), "text/plain", int64(len(sum))); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return created, nil
 }
 
 func IsNotFound(err error) bool {
@@ -3,10 +3,16 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
@@ -18,10 +24,27 @@ import (
 type fakeObj struct {
     body        []byte
     contentType string
+    metadata    map[string]string
+    tags         map[string]string
+    storageClass string
+    modified     time.Time
+    lockMode     types.ObjectLockMode
 }
 
 type fakeS3 struct {
     objects map[string]fakeObj
+
+    missingBucket bool
+    createdBucket string
+    versions      []types.ObjectVersion
+    lastCopySource string
+    lastPutMD5     string
+    presignedPuts  int
+    corruptPuts    bool
+    uploads        map[string]*fakeMultipart
+    copiedParts    int
+    uploadedParts  int
+    aborted        int
 }
 
 func newFakeS3() *fakeS3 {
@@ -34,10 +57,15 @@ func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFn
     if !ok {
         return nil, notFoundErr()
     }
+    if params.Range != nil {
+        obj.body = fakeRange(obj.body, aws.ToString(params.Range))
+    }
     return &s3.GetObjectOutput{
         Body:          io.NopCloser(bytes.NewReader(obj.body)),
         ContentLength: aws.Int64(int64(len(obj.body))),
         ContentType:   aws.String(obj.contentType),
+        ETag:          aws.String(fakeETag(obj.body)),
+        Metadata:      obj.metadata,
     }, nil
 }
 
@@ -50,6 +78,9 @@ func (f *fakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, opt
     return &s3.HeadObjectOutput{
         ContentLength: aws.Int64(int64(len(obj.body))),
         ContentType:   aws.String(obj.contentType),
+        ETag:          aws.String(fakeETag(obj.body)),
+        LastModified:  aws.Time(obj.modified),
+        Metadata:      obj.metadata,
     }, nil
 }
 
@@ -60,58 +91,122 @@ func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFn
         return nil, err
     }
     ct := aws.ToString(params.ContentType)
-    f.objects[key] = fakeObj{body: data, contentType: ct}
-    return &s3.PutObjectOutput{}, nil
+    current, exists := f.objects[key]
+    if (aws.ToString(params.IfNoneMatch) == "*" && exists) ||
+        (params.IfMatch != nil && (!exists || fakeETag(current.body) != aws.ToString(params.IfMatch))) {
+        return nil, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "precondition failed"}
+    }
+    f.lastPutMD5 = aws.ToString(params.ContentMD5)
+    f.objects[key] = fakeObj{body: data, contentType: ct, metadata: params.Metadata, modified: time.Now(), lockMode: params.ObjectLockMode}
+    out := &s3.PutObjectOutput{ChecksumCRC32C: params.ChecksumCRC32C, ChecksumSHA256: params.ChecksumSHA256, ETag: aws.String(fakeETag(data))}
+    if f.corruptPuts {
+        out.ChecksumCRC32C = aws.String("AAAAAA==")
+    }
+    return out, nil
 }
 
 func (f *fakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
     key := aws.ToString(params.Key)
+    if params.IfMatch != nil {
+        current, exists := f.objects[key]
+        if !exists {
+            return nil, notFoundErr()
+        }
+        if fakeETag(current.body) != aws.ToString(params.IfMatch) {
+            return nil, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "precondition failed"}
+        }
+    }
     delete(f.objects, key)
     return &s3.DeleteObjectOutput{}, nil
 }
 
 func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
-    prefix := aws.ToString(params.Prefix)
-    delim := aws.ToString(params.Delimiter)
-    max := int(aws.ToInt32(params.MaxKeys))
-    if max <= 0 {
-        max = 1000
-    }
+	prefix := aws.ToString(params.Prefix)
+	delim := aws.ToString(params.Delimiter)
+	after := aws.ToString(params.ContinuationToken)
+	max := int(aws.ToInt32(params.MaxKeys))
+	if max <= 0 {
+		max = 1000
+	}
 
-    commons := map[string]struct{}{}
-    contents := []types.Object{}
-    count := 0
-    for key, obj := range f.objects {
-        if !strings.HasPrefix(key, prefix) {
-            continue
-        }
-        rest := strings.TrimPrefix(key, prefix)
-        if delim != "" {
-            parts := strings.Split(rest, delim)
-            if len(parts) > 1 {
-                commons[prefix+parts[0]+delim] = struct{}{}
-                continue
-            }
-        }
-        contents = append(contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(obj.body)))})
-        count++
-        if count >= max {
-            break
-        }
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{}
+	seen := map[string]bool{}
+	count := 0
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		item := key
+		if delim != "" {
+			if i := strings.Index(strings.TrimPrefix(key, prefix), delim); i >= 0 {
+				item = prefix + strings.TrimPrefix(key, prefix)[:i+len(delim)]
+			}
+		}
+		if seen[item] || (after != "" && item <= after) {
+			continue
+		}
+		if count == max {
+			out.IsTruncated = aws.Bool(true)
+			break
+		}
+		seen[item] = true
+		count++
+		out.NextContinuationToken = aws.String(item)
+		if item != key {
+			out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(item)})
+			continue
+		}
+		obj := f.objects[key]
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(obj.body))), LastModified: aws.Time(obj.modified), StorageClass: types.ObjectStorageClass(obj.storageClass)})
+	}
+	if !aws.ToBool(out.IsTruncated) {
+		out.NextContinuationToken = nil
+	}
+	return out, nil
+}
+
+func (f *fakeS3) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+    f.lastCopySource = aws.ToString(params.CopySource)
+    source, err := url.PathUnescape(aws.ToString(params.CopySource))
+    if err != nil {
+        return nil, err
     }
-    var cps []types.CommonPrefix
-    for k := range commons {
-        cp := k
-        cps = append(cps, types.CommonPrefix{Prefix: aws.String(cp)})
+    source, _, _ = strings.Cut(source, "?")
+    _, srcKey, _ := strings.Cut(source, "/")
+    obj, ok := f.objects[srcKey]
+    if !ok {
+        return nil, notFoundErr()
+    }
+    if _, exists := f.objects[aws.ToString(params.Key)]; exists && aws.ToString(params.IfNoneMatch) == "*" {
+        return nil, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "precondition failed"}
     }
-    return &s3.ListObjectsV2Output{Contents: contents, CommonPrefixes: cps}, nil
+    obj.storageClass = string(params.StorageClass)
+    if params.MetadataDirective == types.MetadataDirectiveReplace {
+        obj.metadata = params.Metadata
+        if params.ContentType != nil {
+            obj.contentType = aws.ToString(params.ContentType)
+        }
+    }
+    obj.modified = time.Now()
+    f.objects[aws.ToString(params.Key)] = obj
+    return &s3.CopyObjectOutput{}, nil
 }
 
 type fakePresign struct{}
 
 func (fakePresign) PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
 	u := &url.URL{Scheme: "http", Host: "fake", Path: aws.ToString(params.Key)}
-	return &v4.PresignedHTTPRequest{URL: u.String(), Method: http.MethodPut, SignedHeader: http.Header{}}, nil
+	signed := http.Header{}
+	for k, v := range params.Metadata {
+		signed.Set("X-Amz-Meta-"+k, v)
+	}
+	return &v4.PresignedHTTPRequest{URL: u.String(), Method: http.MethodPut, SignedHeader: signed}, nil
 }
 
 type fakeTransport struct {
@@ -125,10 +220,159 @@ func (t fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
         return nil, err
     }
     ct := req.Header.Get("Content-Type")
-    t.store.objects[key] = fakeObj{body: data, contentType: ct}
+    var metadata map[string]string
+    for k := range req.Header {
+        if name, ok := strings.CutPrefix(k, "X-Amz-Meta-"); ok {
+            if metadata == nil {
+                metadata = make(map[string]string)
+            }
+            metadata[strings.ToLower(name)] = req.Header.Get(k)
+        }
+    }
+    t.store.presignedPuts++
+    t.store.objects[key] = fakeObj{body: data, contentType: ct, metadata: metadata}
     return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
 }
 
+func fakeETag(body []byte) string {
+    sum := md5.Sum(body)
+    return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
 func notFoundErr() error {
     return &smithy.GenericAPIError{Code: "NotFound", Message: "not found"}
 }
+
+func (f *fakeS3) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if f.missingBucket {
+		return nil, &smithy.GenericAPIError{Code: "NotFound", Message: "bucket not found"}
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (f *fakeS3) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	f.missingBucket = false
+	f.createdBucket = aws.ToString(params.Bucket)
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (f *fakeS3) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	if f.versions == nil {
+		return &s3.GetBucketVersioningOutput{}, nil
+	}
+	return &s3.GetBucketVersioningOutput{Status: types.BucketVersioningStatusEnabled}, nil
+}
+
+func (f *fakeS3) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	out := &s3.ListObjectVersionsOutput{}
+	for _, v := range f.versions {
+		if strings.HasPrefix(aws.ToString(v.Key), aws.ToString(params.Prefix)) {
+			out.Versions = append(out.Versions, v)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeS3) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	obj, ok := f.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, notFoundErr()
+	}
+	out := &s3.GetObjectTaggingOutput{}
+	for k, v := range obj.tags {
+		out.TagSet = append(out.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	key := aws.ToString(params.Key)
+	obj, ok := f.objects[key]
+	if !ok {
+		return nil, notFoundErr()
+	}
+	obj.tags = map[string]string{}
+	for _, t := range params.Tagging.TagSet {
+		obj.tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	f.objects[key] = obj
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+type fakeMultipart struct {
+	key         string
+	contentType string
+	lockMode    types.ObjectLockMode
+	parts       map[int32][]byte
+}
+
+// fakeRange cuts body to a "bytes=first-last" range.
+func fakeRange(body []byte, rng string) []byte {
+	first, last, _ := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+	from, _ := strconv.Atoi(first)
+	to, _ := strconv.Atoi(last)
+	return body[from : to+1]
+}
+
+func (f *fakeS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if f.uploads == nil {
+		f.uploads = make(map[string]*fakeMultipart)
+	}
+	id := strconv.Itoa(len(f.uploads) + 1)
+	f.uploads[id] = &fakeMultipart{key: aws.ToString(params.Key), contentType: aws.ToString(params.ContentType), lockMode: params.ObjectLockMode, parts: map[int32][]byte{}}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (f *fakeS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.uploadedParts++
+	f.uploads[aws.ToString(params.UploadId)].parts[aws.ToInt32(params.PartNumber)] = data
+	return &s3.UploadPartOutput{ETag: aws.String(fakeETag(data))}, nil
+}
+
+func (f *fakeS3) UploadPartCopy(ctx context.Context, params *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	source, err := url.PathUnescape(aws.ToString(params.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	_, srcKey, _ := strings.Cut(source, "/")
+	obj, ok := f.objects[srcKey]
+	if !ok {
+		return nil, notFoundErr()
+	}
+	data := fakeRange(obj.body, aws.ToString(params.CopySourceRange))
+	f.copiedParts++
+	f.uploads[aws.ToString(params.UploadId)].parts[aws.ToInt32(params.PartNumber)] = data
+	return &s3.UploadPartCopyOutput{CopyPartResult: &types.CopyPartResult{ETag: aws.String(fakeETag(data))}}, nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	id := aws.ToString(params.UploadId)
+	up := f.uploads[id]
+	if _, exists := f.objects[up.key]; exists && aws.ToString(params.IfNoneMatch) == "*" {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "precondition failed"}
+	}
+	var body []byte
+	for i, part := range params.MultipartUpload.Parts {
+		data := up.parts[aws.ToInt32(part.PartNumber)]
+		if aws.ToString(part.ETag) != fakeETag(data) {
+			return nil, &smithy.GenericAPIError{Code: "InvalidPart", Message: "invalid part"}
+		}
+		if i < len(params.MultipartUpload.Parts)-1 && len(data) < MinPartSize {
+			return nil, &smithy.GenericAPIError{Code: "EntityTooSmall", Message: "part too small"}
+		}
+		body = slices.Concat(body, data)
+	}
+	delete(f.uploads, id)
+	f.objects[up.key] = fakeObj{body: body, contentType: up.contentType, modified: time.Now(), lockMode: up.lockMode}
+	return &s3.CompleteMultipartUploadOutput{ETag: aws.String(fakeETag(body))}, nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.aborted++
+	delete(f.uploads, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
@@ -39,9 +39,13 @@ func TestGenerateChecksums(t *testing.T) {
 	store := newTestStore("")
 	store.client.(*fakeS3).objects["artifact.jar"] = fakeObj{body: []byte("hello"), contentType: "application/java-archive"}
 
-	if err := store.GenerateChecksums(context.Background(), ""); err != nil {
+	stats, err := store.GenerateChecksums(context.Background(), "")
+	if err != nil {
 		t.Fatalf("generate: %v", err)
 	}
+	if stats.Objects != 1 || stats.Created != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
 	if _, ok := store.client.(*fakeS3).objects["artifact.jar.sha1"]; !ok {
 		t.Fatalf("missing sha1")
 	}
@@ -50,6 +54,28 @@ func TestGenerateChecksums(t *testing.T) {
 	}
 }
 
+func TestGenerateConfiguredChecksums(t *testing.T) {
+	store := newTestStore("")
+	store.sidecars = []string{"sha1", "sha512"}
+	fs := store.client.(*fakeS3)
+	fs.objects["artifact.jar"] = fakeObj{body: []byte("hello")}
+	fs.objects["artifact.jar.sha256"] = fakeObj{body: []byte("uploaded by the client")}
+
+	stats, err := store.GenerateChecksums(context.Background(), "")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if stats.Objects != 1 || stats.Created != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if got := string(fs.objects["artifact.jar.sha512"].body); len(got) != 128 {
+		t.Fatalf("sha512 sidecar = %q", got)
+	}
+	if _, ok := fs.objects["artifact.jar.md5"]; ok {
+		t.Fatal("md5 written although not configured")
+	}
+}
+
 func TestCleanupBadChecksums(t *testing.T) {
 	store := newTestStore("")
 	fs := store.client.(*fakeS3)
@@ -57,10 +83,106 @@ func TestCleanupBadChecksums(t *testing.T) {
 	fs.objects["file.jar.sha1"] = fakeObj{body: []byte("good")}
 	fs.objects["file.jar.sha1.sha1"] = fakeObj{body: []byte("bad")}
 
-	if err := store.CleanupBadChecksums(context.Background(), ""); err != nil {
+	stats, err := store.CleanupBadChecksums(context.Background(), "")
+	if err != nil {
 		t.Fatalf("cleanup: %v", err)
 	}
+	if stats.Deleted != 1 {
+		t.Fatalf("expected 1 deleted checksum, got %+v", stats)
+	}
 	if _, ok := fs.objects["file.jar.sha1.sha1"]; ok {
 		t.Fatalf("expected bad checksum removed")
 	}
 }
+
+func TestCleanupOrphanedAndInvalidChecksums(t *testing.T) {
+	store := newTestStore("")
+	fs := store.client.(*fakeS3)
+	fs.objects["lib/app.jar"] = fakeObj{body: []byte("data")}
+	fs.objects["lib/app.jar.asc"] = fakeObj{body: []byte("sig")}
+	fs.objects["lib/app.jar.asc.sha1"] = fakeObj{body: []byte("0123456789abcdef0123456789abcdef01234567")}
+	fs.objects["lib/app.jar.md5"] = fakeObj{body: []byte("0123456789ABCDEF0123456789ABCDEF  app.jar\n")}
+	fs.objects["lib/app.jar.sha1"] = fakeObj{body: []byte("<html>error</html> not a digest at all....")}
+	fs.objects["lib/gone.jar.sha1"] = fakeObj{body: []byte("0123456789abcdef0123456789abcdef01234567")}
+	fs.objects["lib/gone.jar.md5"] = fakeObj{body: []byte("0123456789abcdef0123456789abcdef")}
+
+	stats, err := store.CleanupBadChecksums(context.Background(), "")
+	if err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if stats.Orphaned != 2 || stats.Invalid != 1 || stats.Deleted != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	for _, key := range []string{"lib/gone.jar.sha1", "lib/gone.jar.md5", "lib/app.jar.sha1"} {
+		if _, ok := fs.objects[key]; ok {
+			t.Fatalf("expected %s removed", key)
+		}
+	}
+	for _, key := range []string{"lib/app.jar", "lib/app.jar.md5", "lib/app.jar.asc.sha1"} {
+		if _, ok := fs.objects[key]; !ok {
+			t.Fatalf("expected %s kept", key)
+		}
+	}
+
+	// A prefix scan that excludes the artifact must not treat its sidecar as
+	// orphaned.
+	fs.objects["lib/app.jar.sha1"] = fakeObj{body: []byte("0123456789abcdef0123456789abcdef01234567")}
+	if stats, err := store.CleanupBadChecksums(context.Background(), "lib/app.jar.sha1"); err != nil || stats.Orphaned != 0 || stats.Invalid != 0 {
+		t.Fatalf("unexpected prefix scan result %+v (%v)", stats, err)
+	}
+}
+
+func TestTouchSetsMetadata(t *testing.T) {
+	store := newTestStore("releases")
+	fs := store.client.(*fakeS3)
+	fs.objects["releases/app.jar"] = fakeObj{body: []byte("data"), contentType: "application/java-archive"}
+
+	if err := store.Touch(context.Background(), "app.jar", map[string]string{"upstream-etag": "abc"}); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	obj := fs.objects["releases/app.jar"]
+	if string(obj.body) != "data" || obj.contentType != "application/java-archive" {
+		t.Fatalf("object content changed: %+v", obj)
+	}
+	if obj.metadata["upstream-etag"] != "abc" {
+		t.Fatalf("metadata not set: %+v", obj.metadata)
+	}
+}
+
+func TestPutWithMetadataAndTouch(t *testing.T) {
+	store := newTestStore("")
+	fs := store.client.(*fakeS3)
+	ctx := context.Background()
+
+	if err := store.PutWithMetadata(ctx, "a.txt", strings.NewReader("hello"), "text/plain", 5, map[string]string{"build": "42"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if fs.objects["a.txt"].metadata["build"] != "42" {
+		t.Fatalf("expected metadata on the put, got %v", fs.objects["a.txt"].metadata)
+	}
+
+	// Touch adds to the stored metadata instead of replacing it.
+	if err := store.Touch(ctx, "a.txt", map[string]string{"upstream-etag": `"v1"`}); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	if md := fs.objects["a.txt"].metadata; md["build"] != "42" || md["upstream-etag"] != `"v1"` {
+		t.Fatalf("unexpected metadata after touch %v", md)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	store := newTestStore("releases")
+	fs := store.client.(*fakeS3)
+	fs.objects["releases/com/acme/app.jar"] = fakeObj{body: []byte("data"), contentType: "application/java-archive"}
+
+	if err := store.Copy(context.Background(), "com/acme/app.jar", "__quarantine__/com/acme/app.jar"); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	obj, ok := fs.objects["releases/__quarantine__/com/acme/app.jar"]
+	if !ok || string(obj.body) != "data" || obj.contentType != "application/java-archive" {
+		t.Fatalf("unexpected copy result: %+v", obj)
+	}
+	if _, ok := fs.objects["releases/com/acme/app.jar"]; !ok {
+		t.Fatalf("source must be kept")
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+)
+
+// sidecarHashes are the digests that can be written next to stored files
+// as <key>.<name> sidecars.
+var sidecarHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// DefaultSidecarChecksums are written when nothing else is configured: the
+// pair every Maven and Gradle version checks.
+var DefaultSidecarChecksums = []string{"sha1", "md5"}
+
+// SidecarExtensions are the extensions of every checksum sidecar Heimdall
+// knows, written by itself or uploaded by clients, whatever is configured.
+var SidecarExtensions = []string{".sha1", ".md5", ".sha256", ".sha512"}
+
+// ParseSidecarChecksums validates a list of sidecar digests. sha1 is
+// required: it is what build tools verify by default and what write-once
+// redeploys are compared by.
+func ParseSidecarChecksums(names []string) ([]string, error) {
+	var out []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || slices.Contains(out, name) {
+			continue
+		}
+		if sidecarHashes[name] == nil {
+			return nil, fmt.Errorf("unknown checksum algorithm %q (want sha1, md5, sha256 or sha512)", name)
+		}
+		out = append(out, name)
+	}
+	if len(out) == 0 {
+		return DefaultSidecarChecksums, nil
+	}
+	if !slices.Contains(out, "sha1") {
+		return nil, fmt.Errorf("checksum algorithms must include sha1")
+	}
+	return out, nil
+}
+
+// IsChecksumSidecar reports whether key is a checksum sidecar of any known
+// algorithm.
+func IsChecksumSidecar(key string) bool {
+	lower := strings.ToLower(key)
+	return slices.ContainsFunc(SidecarExtensions, func(ext string) bool { return strings.HasSuffix(lower, ext) })
+}
+
+// Checksums computes several digests of one stream.
+type Checksums struct {
+	names  []string
+	hashes []hash.Hash
+}
+
+// NewChecksums hashes with the named algorithms, skipping unknown names
+// and duplicates.
+func NewChecksums(names ...string) *Checksums {
+	c := &Checksums{}
+	for _, name := range names {
+		if newHash := sidecarHashes[name]; newHash != nil && !slices.Contains(c.names, name) {
+			c.names = append(c.names, name)
+			c.hashes = append(c.hashes, newHash())
+		}
+	}
+	return c
+}
+
+func (c *Checksums) Write(p []byte) (int, error) {
+	for _, h := range c.hashes {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// Sum returns the hex digest of the named algorithm, "" when it is not
+// computed.
+func (c *Checksums) Sum(name string) string {
+	if i := slices.Index(c.names, name); i >= 0 {
+		return hex.EncodeToString(c.hashes[i].Sum(nil))
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSidecarChecksums(t *testing.T) {
+	got, err := ParseSidecarChecksums([]string{" SHA1", "sha512", "sha1", ""})
+	if err != nil || !slices.Equal(got, []string{"sha1", "sha512"}) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got, err := ParseSidecarChecksums(nil); err != nil || !slices.Equal(got, DefaultSidecarChecksums) {
+		t.Fatalf("empty list: got %v, %v", got, err)
+	}
+	for _, bad := range [][]string{{"sha256"}, {"sha1", "crc32"}} {
+		if _, err := ParseSidecarChecksums(bad); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	c := NewChecksums("sha1", "md5", "sha1", "nope")
+	_, _ = c.Write([]byte("hello"))
+	if got := c.Sum("sha1"); got != "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d" {
+		t.Fatalf("sha1 = %s", got)
+	}
+	if got := c.Sum("md5"); got != "5d41402abc4b2a76b9719d911017c592" {
+		t.Fatalf("md5 = %s", got)
+	}
+	if got := c.Sum("sha256"); got != "" {
+		t.Fatalf("sha256 not requested, got %s", got)
+	}
+}
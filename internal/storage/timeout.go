@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Timeouts bounds individual S3 operations so a hung call can't hold a
+// client connection indefinitely. Zero disables the limit for that class.
+type Timeouts struct {
+	// Head covers HeadObject and DeleteObject.
+	Head time.Duration
+	// List covers a full (paginated) listing.
+	List time.Duration
+	// Get covers GetObject including reading the body.
+	Get time.Duration
+	// Put covers uploads, server-side copies (Copy, Touch) and conditional
+	// deletes (DeleteIfMatch).
+	Put time.Duration
+}
+
+// TimeoutError is returned when an operation exceeds its configured timeout
+// (as opposed to the caller's context being canceled).
+type TimeoutError struct {
+	Op      string
+	Timeout time.Duration
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("s3 %s timed out after %s", e.Op, e.Timeout)
+}
+
+func (e TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// IsTimeout reports whether err is an operation hitting its own timeout.
+func IsTimeout(err error) bool {
+	var te TimeoutError
+	return errors.As(err, &te)
+}
+
+// timed runs fn with the given timeout and converts a deadline hit into a
+// TimeoutError.
+func timed(ctx context.Context, op string, d time.Duration, fn func(context.Context) error) error {
+	if d <= 0 {
+		return fn(ctx)
+	}
+	opCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	return timeoutError(ctx, opCtx, op, d, fn(opCtx))
+}
+
+func timeoutError(parent, opCtx context.Context, op string, d time.Duration, err error) error {
+	if err != nil && parent.Err() == nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+		return TimeoutError{Op: op, Timeout: d}
+	}
+	return err
+}
+
+// timedBody keeps the Get deadline alive while the body is streamed and
+// releases it on Close.
+type timedBody struct {
+	io.ReadCloser
+	parent, ctx context.Context
+	cancel      context.CancelFunc
+	timeout     time.Duration
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = timeoutError(b.parent, b.ctx, "GetObject", b.timeout, err)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
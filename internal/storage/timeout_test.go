@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type hangingS3 struct {
+	s3API
+}
+
+func (hangingS3) HeadObject(ctx context.Context, _ *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (hangingS3) GetObject(ctx context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: io.NopCloser(ctxReader{ctx})}, nil
+}
+
+// ctxReader blocks until its context ends, like a stalled body download.
+type ctxReader struct{ ctx context.Context }
+
+func (r ctxReader) Read([]byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+func TestHeadTimeout(t *testing.T) {
+	s := &Store{client: hangingS3{}, bucket: "b", timeouts: Timeouts{Head: 10 * time.Millisecond}}
+	_, err := s.Head(context.Background(), "a.jar")
+	if !IsTimeout(err) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.Head(ctx, "a.jar")
+	if IsTimeout(err) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("caller cancellation must not be reported as timeout, got %v", err)
+	}
+}
+
+func TestGetTimeoutCoversBody(t *testing.T) {
+	s := &Store{client: hangingS3{}, bucket: "b", timeouts: Timeouts{Get: 10 * time.Millisecond}}
+	out, err := s.Get(context.Background(), "a.jar")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer out.Body.Close()
+	if _, err := io.ReadAll(out.Body); !IsTimeout(err) {
+		t.Fatalf("expected timeout reading body, got %v", err)
+	}
+}
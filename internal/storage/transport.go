@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"cmp"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Transport tunes how HTTP connections are opened and reused, for S3
+// (Options.Transport) and for proxy upstreams. Zero values keep the
+// defaults of the underlying transport.
+type Transport struct {
+	// MaxIdleConnsPerHost is how many idle connections are kept per host.
+	// net/http keeps 2, so a burst of parallel requests to one endpoint
+	// ends up opening and closing connections all the time.
+	MaxIdleConnsPerHost int
+	// DialTimeout bounds establishing a TCP connection.
+	DialTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes.
+	KeepAlive time.Duration
+	// TLSHandshakeTimeout bounds the TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// IdleConnTimeout closes connections left idle this long.
+	IdleConnTimeout time.Duration
+}
+
+// Apply sets the configured values on tr. The overall idle pool grows to
+// at least MaxIdleConnsPerHost so the per-host setting takes effect.
+func (t Transport) Apply(tr *http.Transport) {
+	if t.MaxIdleConnsPerHost > 0 {
+		tr.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+		if tr.MaxIdleConns > 0 && tr.MaxIdleConns < t.MaxIdleConnsPerHost {
+			tr.MaxIdleConns = t.MaxIdleConnsPerHost
+		}
+	}
+	if t.DialTimeout != 0 || t.KeepAlive != 0 {
+		dialer := &net.Dialer{
+			Timeout:   cmp.Or(t.DialTimeout, 30*time.Second),
+			KeepAlive: cmp.Or(t.KeepAlive, 30*time.Second),
+		}
+		tr.DialContext = dialer.DialContext
+	}
+	if t.TLSHandshakeTimeout > 0 {
+		tr.TLSHandshakeTimeout = t.TLSHandshakeTimeout
+	}
+	if t.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = t.IdleConnTimeout
+	}
+}
+
+// NewHTTPTransport returns a copy of http.DefaultTransport with t applied.
+func (t Transport) NewHTTPTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	t.Apply(tr)
+	return tr
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTransportApply(t *testing.T) {
+	tr := Transport{
+		MaxIdleConnsPerHost: 200,
+		DialTimeout:         5 * time.Second,
+		TLSHandshakeTimeout: 3 * time.Second,
+		IdleConnTimeout:     time.Minute,
+	}.NewHTTPTransport()
+	if tr.MaxIdleConnsPerHost != 200 || tr.MaxIdleConns != 200 {
+		t.Errorf("idle pool: per host %d, total %d", tr.MaxIdleConnsPerHost, tr.MaxIdleConns)
+	}
+	if tr.TLSHandshakeTimeout != 3*time.Second || tr.IdleConnTimeout != time.Minute {
+		t.Errorf("timeouts: tls %v, idle %v", tr.TLSHandshakeTimeout, tr.IdleConnTimeout)
+	}
+	if tr.DialContext == nil {
+		t.Error("expected a dialer")
+	}
+}
+
+func TestTransportZeroKeepsDefaults(t *testing.T) {
+	def := http.DefaultTransport.(*http.Transport)
+	tr := Transport{}.NewHTTPTransport()
+	if tr.MaxIdleConnsPerHost != def.MaxIdleConnsPerHost || tr.MaxIdleConns != def.MaxIdleConns ||
+		tr.TLSHandshakeTimeout != def.TLSHandshakeTimeout || tr.IdleConnTimeout != def.IdleConnTimeout {
+		t.Errorf("zero Transport changed defaults")
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ProbePrefix holds the objects Verify writes and deletes again; one may be
+// left behind if the delete fails.
+const ProbePrefix = "__probe__/"
+
+// Verify checks that the bucket exists and accepts writes by uploading and
+// deleting a small probe object. With create set, a missing bucket is
+// created first.
+func (s *Store) Verify(ctx context.Context, create bool) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	switch {
+	case err == nil:
+	case IsNotFound(err) && create:
+		if err := s.createBucket(ctx); err != nil {
+			return fmt.Errorf("create bucket %q: %w", s.bucket, err)
+		}
+	case IsNotFound(err):
+		return fmt.Errorf("bucket %q does not exist (set S3_CREATE_BUCKET=true to create it)", s.bucket)
+	default:
+		return fmt.Errorf("bucket %q is not accessible: %w", s.bucket, err)
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("generate probe key: %w", err)
+	}
+	key := s.key(ProbePrefix + hex.EncodeToString(buf))
+	if err := s.putAbsolute(ctx, key, strings.NewReader("ok"), "text/plain", 2); err != nil {
+		return fmt.Errorf("bucket %q is not writable: %w", s.bucket, err)
+	}
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}); err != nil {
+		return fmt.Errorf("delete probe object %q from bucket %q: %w", key, s.bucket, err)
+	}
+	return nil
+}
+
+// ProbeStep is the outcome of one S3 operation run by Probe. Skipped steps
+// depend on an earlier one that failed.
+type ProbeStep struct {
+	Op      string
+	Err     error
+	Skipped bool
+}
+
+// Probe runs every operation heimdall needs against the bucket: HeadBucket,
+// a listing, and a put, get and delete of a probe object. Unlike Verify it
+// does not stop at the first failure, so all missing permissions show up at
+// once.
+func (s *Store) Probe(ctx context.Context) []ProbeStep {
+	var steps []ProbeStep
+	step := func(op string, err error) error {
+		steps = append(steps, ProbeStep{Op: op, Err: err})
+		return err
+	}
+
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	step("HeadBucket", err)
+	root := ""
+	if s.prefix != "" {
+		root = s.prefix + "/"
+	}
+	_, err = s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(root), MaxKeys: aws.Int32(1)})
+	step("ListObjectsV2", err)
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return append(steps, ProbeStep{Op: "PutObject", Err: fmt.Errorf("generate probe key: %w", err)})
+	}
+	key := s.key(ProbePrefix + hex.EncodeToString(buf))
+	if step("PutObject", s.putAbsolute(ctx, key, strings.NewReader("ok"), "text/plain", 2)) != nil {
+		return append(steps, ProbeStep{Op: "GetObject", Skipped: true}, ProbeStep{Op: "DeleteObject", Skipped: true})
+	}
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err == nil {
+		var body []byte
+		body, err = io.ReadAll(out.Body)
+		out.Body.Close()
+		if err == nil && string(body) != "ok" {
+			err = fmt.Errorf("probe object read back as %q", body)
+		}
+	}
+	step("GetObject", err)
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	step("DeleteObject", err)
+	return steps
+}
+
+func (s *Store) createBucket(ctx context.Context) error {
+	input := &s3.CreateBucketInput{Bucket: aws.String(s.bucket)}
+	// us-east-1 must not be sent as a location constraint.
+	if s.region != "" && s.region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(s.region),
+		}
+	}
+	_, err := s.client.CreateBucket(ctx, input)
+	return err
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestVerifyMissingBucket(t *testing.T) {
+	store := newTestStore("releases")
+	fs := store.client.(*fakeS3)
+	fs.missingBucket = true
+
+	if err := store.Verify(context.Background(), false); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing bucket error, got %v", err)
+	}
+
+	if err := store.Verify(context.Background(), true); err != nil {
+		t.Fatalf("verify with create: %v", err)
+	}
+	if fs.createdBucket != "bucket" {
+		t.Fatalf("expected bucket to be created, got %q", fs.createdBucket)
+	}
+	for key := range fs.objects {
+		if strings.Contains(key, ProbePrefix) {
+			t.Fatalf("probe object %q was left behind", key)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	store := newTestStore("releases")
+	fs := store.client.(*fakeS3)
+
+	var ops []string
+	for _, step := range store.Probe(context.Background()) {
+		if step.Err != nil || step.Skipped {
+			t.Fatalf("unexpected failure of %s: %v", step.Op, step.Err)
+		}
+		ops = append(ops, step.Op)
+	}
+	if got := strings.Join(ops, ","); got != "HeadBucket,ListObjectsV2,PutObject,GetObject,DeleteObject" {
+		t.Fatalf("unexpected steps %s", got)
+	}
+	if len(fs.objects) != 0 {
+		t.Fatalf("expected the probe object to be deleted, found %d objects", len(fs.objects))
+	}
+
+	fs.missingBucket = true
+	steps := store.Probe(context.Background())
+	if steps[0].Op != "HeadBucket" || steps[0].Err == nil {
+		t.Fatalf("expected HeadBucket to fail, got %+v", steps[0])
+	}
+}
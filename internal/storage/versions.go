@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrVersioningDisabled is returned by version operations on buckets that
+// never had versioning enabled.
+var ErrVersioningDisabled = errors.New("bucket versioning is not enabled")
+
+type ObjectVersion struct {
+	VersionID    string    `json:"versionId"`
+	LastModified time.Time `json:"lastModified"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	IsLatest     bool      `json:"isLatest"`
+	DeleteMarker bool      `json:"deleteMarker,omitempty"`
+}
+
+// VersioningEnabled reports whether the bucket keeps object versions.
+// Suspended versioning still counts since older versions remain readable.
+func (s *Store) VersioningEnabled(ctx context.Context) (bool, error) {
+	out, err := s.client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(s.bucket)})
+	if err != nil {
+		return false, err
+	}
+	return out.Status == types.BucketVersioningStatusEnabled || out.Status == types.BucketVersioningStatusSuspended, nil
+}
+
+// Versions lists every version and delete marker of key, newest first.
+func (s *Store) Versions(ctx context.Context, key string) ([]ObjectVersion, error) {
+	k, err := s.cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := s.VersioningEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrVersioningDisabled
+	}
+
+	var versions []ObjectVersion
+	err = timed(ctx, "ListObjectVersions", s.timeouts.List, func(ctx context.Context) error {
+		input := &s3.ListObjectVersionsInput{Bucket: aws.String(s.bucket), Prefix: aws.String(k)}
+		for {
+			out, err := s.client.ListObjectVersions(ctx, input)
+			if err != nil {
+				return err
+			}
+			for _, v := range out.Versions {
+				if aws.ToString(v.Key) != k {
+					continue
+				}
+				versions = append(versions, ObjectVersion{
+					VersionID:    aws.ToString(v.VersionId),
+					LastModified: aws.ToTime(v.LastModified),
+					Size:         aws.ToInt64(v.Size),
+					ETag:         aws.ToString(v.ETag),
+					IsLatest:     aws.ToBool(v.IsLatest),
+				})
+			}
+			for _, m := range out.DeleteMarkers {
+				if aws.ToString(m.Key) != k {
+					continue
+				}
+				versions = append(versions, ObjectVersion{
+					VersionID:    aws.ToString(m.VersionId),
+					LastModified: aws.ToTime(m.LastModified),
+					IsLatest:     aws.ToBool(m.IsLatest),
+					DeleteMarker: true,
+				})
+			}
+			if !aws.ToBool(out.IsTruncated) {
+				return nil
+			}
+			input.KeyMarker = out.NextKeyMarker
+			input.VersionIdMarker = out.NextVersionIdMarker
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions, nil
+}
+
+// RestoreVersion makes versionID the current version of key by copying it
+// over itself (the copy becomes a new, latest version).
+func (s *Store) RestoreVersion(ctx context.Context, key, versionID string) error {
+	k, err := s.cleanKey(key)
+	if err != nil {
+		return err
+	}
+	if s.writeOnce(k) {
+		return ImmutableError{Key: strings.TrimPrefix(key, "/")}
+	}
+	source := (&url.URL{Path: s.bucket + "/" + k}).EscapedPath() + "?versionId=" + url.QueryEscape(versionID)
+	return timed(ctx, "CopyObject", s.timeouts.Put, func(ctx context.Context) error {
+		_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(k),
+			CopySource: aws.String(source),
+		})
+		return err
+	})
+}
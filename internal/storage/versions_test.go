@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestVersions(t *testing.T) {
+	store := newTestStore("releases")
+	fs := store.client.(*fakeS3)
+	ctx := context.Background()
+
+	if _, err := store.Versions(ctx, "app.jar"); !errors.Is(err, ErrVersioningDisabled) {
+		t.Fatalf("expected versioning disabled, got %v", err)
+	}
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fs.versions = []types.ObjectVersion{
+		{Key: aws.String("releases/app.jar"), VersionId: aws.String("v1"), LastModified: aws.Time(t0)},
+		{Key: aws.String("releases/app.jar"), VersionId: aws.String("v2"), LastModified: aws.Time(t0.Add(time.Hour)), IsLatest: aws.Bool(true)},
+		{Key: aws.String("releases/app.jar.sha1"), VersionId: aws.String("s1"), LastModified: aws.Time(t0)},
+	}
+	versions, err := store.Versions(ctx, "app.jar")
+	if err != nil {
+		t.Fatalf("versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].VersionID != "v2" || !versions[0].IsLatest || versions[1].VersionID != "v1" {
+		t.Fatalf("unexpected versions: %+v", versions)
+	}
+
+	fs.objects["releases/app.jar"] = fakeObj{body: []byte("v2")}
+	if err := store.RestoreVersion(ctx, "app.jar", "v1"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if !strings.HasSuffix(fs.lastCopySource, "bucket/releases/app.jar?versionId=v1") {
+		t.Fatalf("unexpected copy source %q", fs.lastCopySource)
+	}
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Object Lock settings for objects written below WORM prefixes.
+const (
+	// ObjectLockGovernance and ObjectLockCompliance set a retention period
+	// of Options.ObjectLockRetention on every write.
+	ObjectLockGovernance = "governance"
+	ObjectLockCompliance = "compliance"
+	// ObjectLockLegalHold places a legal hold, which has no expiry.
+	ObjectLockLegalHold = "legal-hold"
+)
+
+// ImmutableError is returned for writes and deletes that would change an
+// object below a WORM prefix.
+type ImmutableError struct {
+	Key string
+}
+
+func (e ImmutableError) Error() string {
+	return fmt.Sprintf("%s is write-once and cannot be overwritten or deleted", e.Key)
+}
+
+// IsImmutable reports whether err was caused by a WORM prefix.
+func IsImmutable(err error) bool {
+	var ie ImmutableError
+	return errors.As(err, &ie)
+}
+
+// wormPolicy is the write-once part of a Store.
+type wormPolicy struct {
+	// prefixes end with "/"; an empty one covers the whole store.
+	prefixes  []string
+	lockMode  types.ObjectLockMode
+	legalHold bool
+	retention time.Duration
+}
+
+func newWORMPolicy(prefixes []string, lock string, retention time.Duration) (wormPolicy, error) {
+	var w wormPolicy
+	for _, p := range prefixes {
+		if p = strings.Trim(p, "/"); p != "" {
+			p += "/"
+		}
+		w.prefixes = append(w.prefixes, p)
+	}
+	switch strings.ToLower(lock) {
+	case "":
+	case ObjectLockGovernance, ObjectLockCompliance:
+		if retention <= 0 {
+			return w, fmt.Errorf("object lock mode %q needs a retention period", lock)
+		}
+		w.lockMode, w.retention = types.ObjectLockMode(strings.ToUpper(lock)), retention
+	case ObjectLockLegalHold:
+		w.legalHold = true
+	default:
+		return w, fmt.Errorf("unknown object lock mode %q (want governance, compliance or legal-hold)", lock)
+	}
+	return w, nil
+}
+
+// writeOnce reports whether k (a full bucket key) is below a WORM prefix.
+// Repository metadata is rewritten by every deploy and stays mutable.
+func (s *Store) writeOnce(k string) bool {
+	if len(s.worm.prefixes) == 0 {
+		return false
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(k, s.prefix), "/")
+	base := path.Base(rel)
+	if strings.HasPrefix(base, "maven-metadata") || strings.HasPrefix(base, "archetype-catalog.xml") {
+		return false
+	}
+	for _, p := range s.worm.prefixes {
+		if strings.HasPrefix(rel+"/", p) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockPut makes an upload to a WORM key fail if the key exists and applies
+// the Object Lock settings.
+func (s *Store) lockPut(input *s3.PutObjectInput) {
+	if !s.writeOnce(aws.ToString(input.Key)) {
+		return
+	}
+	input.IfNoneMatch = aws.String("*")
+	if s.worm.lockMode != "" {
+		input.ObjectLockMode = s.worm.lockMode
+		input.ObjectLockRetainUntilDate = aws.Time(time.Now().Add(s.worm.retention))
+	}
+	if s.worm.legalHold {
+		input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+}
+
+// lockCopy applies the Object Lock settings to a copy onto a WORM key, so
+// in-place copies (Touch, storage class changes) keep the new version locked.
+func (s *Store) lockCopy(input *s3.CopyObjectInput) {
+	if !s.writeOnce(aws.ToString(input.Key)) {
+		return
+	}
+	if s.worm.lockMode != "" {
+		input.ObjectLockMode = s.worm.lockMode
+		input.ObjectLockRetainUntilDate = aws.Time(time.Now().Add(s.worm.retention))
+	}
+	if s.worm.legalHold {
+		input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+}
+
+// lockMultipart applies the Object Lock settings to a multipart upload onto
+// a WORM key; Compose makes its completion fail if the key exists.
+func (s *Store) lockMultipart(input *s3.CreateMultipartUploadInput) {
+	if !s.writeOnce(aws.ToString(input.Key)) {
+		return
+	}
+	if s.worm.lockMode != "" {
+		input.ObjectLockMode = s.worm.lockMode
+		input.ObjectLockRetainUntilDate = aws.Time(time.Now().Add(s.worm.retention))
+	}
+	if s.worm.legalHold {
+		input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+}
+
+// immutable turns the precondition failure of a write to an existing WORM
+// key into an ImmutableError.
+func (s *Store) immutable(k string, err error) error {
+	if err != nil && s.writeOnce(k) && IsPreconditionFailed(err) {
+		return ImmutableError{Key: strings.TrimPrefix(strings.TrimPrefix(k, s.prefix), "/")}
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestWORMPrefixes(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore("maven")
+	fs := store.client.(*fakeS3)
+	var err error
+	if store.worm, err = newWORMPolicy([]string{"/releases/"}, "compliance", 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key, body string) error {
+		return store.Put(ctx, key, strings.NewReader(body), "text/plain", int64(len(body)))
+	}
+	if err := put("releases/a/1.0/a-1.0.jar", "v1"); err != nil {
+		t.Fatalf("first put: %v", err)
+	}
+	if obj := fs.objects["maven/releases/a/1.0/a-1.0.jar"]; obj.lockMode != types.ObjectLockModeCompliance {
+		t.Fatalf("expected a compliance lock, got %q", obj.lockMode)
+	}
+	if err := put("releases/a/1.0/a-1.0.jar", "v2"); !IsImmutable(err) {
+		t.Fatalf("expected an overwrite to be refused, got %v", err)
+	}
+	if string(fs.objects["maven/releases/a/1.0/a-1.0.jar"].body) != "v1" {
+		t.Fatal("expected the original content to be kept")
+	}
+	if err := store.Delete(ctx, "releases/a/1.0/a-1.0.jar"); !IsImmutable(err) {
+		t.Fatalf("expected a delete to be refused, got %v", err)
+	}
+	if err := store.Copy(ctx, "releases/a/1.0/a-1.0.jar", "releases/a/1.0/a-1.0.jar"); !IsImmutable(err) {
+		t.Fatalf("expected a copy onto the artifact to be refused, got %v", err)
+	}
+
+	// Metadata and other prefixes stay mutable.
+	for _, key := range []string{"releases/a/maven-metadata.xml", "releases/a/maven-metadata.xml.sha1", "snapshots/a/1.0-SNAPSHOT/a.jar", "releases-old/a.jar"} {
+		if err := put(key, "v1"); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+		if err := put(key, "v2"); err != nil {
+			t.Fatalf("overwrite %s: %v", key, err)
+		}
+		if err := store.Delete(ctx, key); err != nil {
+			t.Fatalf("delete %s: %v", key, err)
+		}
+	}
+}
+
+func TestWORMPolicyValidation(t *testing.T) {
+	if _, err := newWORMPolicy([]string{"releases"}, "governance", 0); err == nil {
+		t.Fatal("expected governance without retention to be rejected")
+	}
+	if _, err := newWORMPolicy([]string{"releases"}, "forever", 0); err == nil {
+		t.Fatal("expected an unknown mode to be rejected")
+	}
+	if w, err := newWORMPolicy([]string{"releases"}, "legal-hold", 0); err != nil || !w.legalHold {
+		t.Fatalf("expected a legal hold, got %+v %v", w, err)
+	}
+}
@@ -0,0 +1,413 @@
+// Package client is a Go client for the Heimdall HTTP API: artifact upload
+// and download with checksum verification, the catalog and proxy management.
+package client
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiPrefix is the versioned namespace of the JSON API.
+const apiPrefix = "/api/v1"
+
+// Client talks to one Heimdall server. It is safe for concurrent use.
+type Client struct {
+	base       *url.URL
+	httpClient *http.Client
+	user       string
+	pass       string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBasicAuth sets the credentials sent with every request: the admin
+// user/password or a deploy token id/secret.
+func WithBasicAuth(user, pass string) Option {
+	return func(c *Client) {
+		c.user, c.pass = user, pass
+	}
+}
+
+// WithHTTPClient replaces http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// New returns a client for the server at baseURL (e.g.
+// "https://maven.example.com").
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base url must be http or https: %q", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c := &Client{base: u, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Error is a non-2xx response. Message is the "detail" (or, from older
+// servers, "error") field of the problem document, or the plain text body
+// if there is none; Type is the problem type.
+type Error struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("heimdall: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("heimdall: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the server.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 from the server, e.g. a proxy
+// update based on a stale revision.
+func IsConflict(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusConflict
+}
+
+// ChecksumError is returned when content does not match the SHA-1 the
+// server reports for it.
+type ChecksumError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("heimdall: sha1 mismatch for %s: expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// Proxy is a proxy repository configuration.
+type Proxy struct {
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	MaxAge        string   `json:"maxAge,omitempty"`
+	MaxCacheBytes int64    `json:"maxCacheBytes,omitempty"`
+	Cache         *bool    `json:"cache,omitempty"`
+	UpdatePolicy  string   `json:"updatePolicy,omitempty"`
+	Mirrors       []string `json:"mirrors,omitempty"`
+	CanaryURL     string   `json:"canaryUrl,omitempty"`
+	// Declared proxies come from the server's proxies file and cannot be
+	// changed through the API.
+	Declared bool `json:"declared,omitempty"`
+	// Revision is set by the server; UpdateProxy sends it back so the
+	// update fails if someone else changed the proxy in the meantime.
+	Revision string `json:"revision,omitempty"`
+}
+
+// InvalidateRequest selects cached artifacts to purge, by exact path or glob.
+type InvalidateRequest struct {
+	Path    string `json:"path,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// InvalidateResult lists the purged keys.
+type InvalidateResult struct {
+	Deleted []string `json:"deleted"`
+}
+
+// Entry is one catalog entry.
+type Entry struct {
+	Name         string     `json:"name"`
+	Path         string     `json:"path"`
+	Type         string     `json:"type"` // file, dir, proxy
+	Size         int64      `json:"size,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	StorageClass string     `json:"storageClass,omitempty"`
+	ETag         string     `json:"etag,omitempty"`
+	SHA1         string     `json:"sha1,omitempty"`
+	Source       string     `json:"source,omitempty"` // local or the proxy name
+}
+
+// CatalogPage is one page of a catalog listing; pass NextCursor to Catalog
+// to fetch the next one. It is empty on the last page.
+type CatalogPage struct {
+	Entries    []Entry
+	NextCursor string
+}
+
+// Upload stores body at path. size must be the exact body length (the
+// server requires Content-Length). After the upload the SHA-1 generated by
+// the server is compared with the one computed while streaming.
+func (c *Client) Upload(ctx context.Context, path string, body io.Reader, size int64) error {
+	if size < 0 {
+		return errors.New("heimdall: upload size is required")
+	}
+	h := sha1.New()
+	req, err := c.newRequest(ctx, http.MethodPut, artifactPath(path), nil, io.TeeReader(body, h))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	expected, err := c.checksum(ctx, path)
+	if err != nil {
+		return fmt.Errorf("heimdall: read uploaded checksum: %w", err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); expected != actual {
+		return &ChecksumError{Path: path, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// Download writes the artifact at path to w and verifies it against its
+// .sha1 sidecar. Content is streamed, so on a *ChecksumError w already holds
+// the bad bytes and must be discarded. Artifacts without a sidecar are not
+// verified.
+func (c *Client) Download(ctx context.Context, path string, w io.Writer) error {
+	req, err := c.newRequest(ctx, http.MethodGet, artifactPath(path), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), resp.Body); err != nil {
+		return fmt.Errorf("heimdall: download %s: %w", path, err)
+	}
+
+	expected, err := c.checksum(ctx, path)
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("heimdall: read checksum: %w", err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); expected != actual {
+		return &ChecksumError{Path: path, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// checksum returns the hex SHA-1 stored next to path.
+func (c *Client) checksum(ctx context.Context, path string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, artifactPath(path)+".sha1", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	// sidecars may be "<sum>  <file name>"
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return "", errors.New("empty checksum file")
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// Exists reports whether an artifact is stored at path without downloading
+// it.
+func (c *Client) Exists(ctx context.Context, path string) (bool, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, apiPrefix+"/exists"+artifactPath(path), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Catalog lists the entries directly under path. limit <= 0 uses the server
+// default.
+func (c *Client) Catalog(ctx context.Context, path, cursor string, limit int) (CatalogPage, error) {
+	q := url.Values{}
+	q.Set("path", path)
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var page CatalogPage
+	resp, err := c.doJSON(ctx, http.MethodGet, apiPrefix+"/catalog", q, nil, nil, &page.Entries)
+	if err != nil {
+		return CatalogPage{}, err
+	}
+	page.NextCursor = resp.Header.Get("X-Next-Cursor")
+	return page, nil
+}
+
+// ListProxies returns every proxy repository.
+func (c *Client) ListProxies(ctx context.Context) ([]Proxy, error) {
+	var proxies []Proxy
+	_, err := c.doJSON(ctx, http.MethodGet, apiPrefix+"/proxies", nil, nil, nil, &proxies)
+	return proxies, err
+}
+
+// CreateProxy adds a proxy repository.
+func (c *Client) CreateProxy(ctx context.Context, p Proxy) error {
+	_, err := c.doJSON(ctx, http.MethodPost, apiPrefix+"/proxies", nil, nil, p, nil)
+	return err
+}
+
+// GetProxy returns the proxy called name with its current revision.
+func (c *Client) GetProxy(ctx context.Context, name string) (Proxy, error) {
+	var p Proxy
+	_, err := c.doJSON(ctx, http.MethodGet, apiPrefix+"/proxies/"+url.PathEscape(name), nil, nil, nil, &p)
+	return p, err
+}
+
+// UpdateProxy replaces the configuration of the proxy called name if it is
+// still at p.Revision (from ListProxies or GetProxy); otherwise it fails
+// with an error for which IsConflict is true. An empty Revision overwrites
+// unconditionally.
+func (c *Client) UpdateProxy(ctx context.Context, name string, p Proxy) error {
+	_, err := c.doJSON(ctx, http.MethodPut, apiPrefix+"/proxies/"+url.PathEscape(name), nil, ifMatch(p.Revision), p, nil)
+	return err
+}
+
+// DeleteProxy removes the proxy called name whatever its revision.
+func (c *Client) DeleteProxy(ctx context.Context, name string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, apiPrefix+"/proxies/"+url.PathEscape(name), nil, ifMatch(""), nil, nil)
+	return err
+}
+
+// ifMatch builds the If-Match header for revision; empty matches anything.
+func ifMatch(revision string) http.Header {
+	if revision == "" {
+		return http.Header{"If-Match": {"*"}}
+	}
+	return http.Header{"If-Match": {`"` + revision + `"`}}
+}
+
+// InvalidateProxy purges cached artifacts of the proxy called name.
+func (c *Client) InvalidateProxy(ctx context.Context, name string, req InvalidateRequest) (InvalidateResult, error) {
+	var res InvalidateResult
+	_, err := c.doJSON(ctx, http.MethodPost, apiPrefix+"/proxies/"+url.PathEscape(name)+"/invalidate", nil, nil, req, &res)
+	return res, err
+}
+
+// doJSON sends in (if non-nil) as JSON and decodes the response into out (if
+// non-nil).
+func (c *Client) doJSON(ctx context.Context, method, path string, q url.Values, h http.Header, in, out any) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := c.newRequest(ctx, method, path, q, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("heimdall: decode %s %s: %w", method, path, err)
+		}
+	}
+	return resp, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, q url.Values, body io.Reader) (*http.Request, error) {
+	u := *c.base
+	u.Path = c.base.Path + path
+	u.RawPath = ""
+	if q != nil {
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.user != "" || c.pass != "" {
+		req.SetBasicAuth(c.user, c.pass)
+	}
+	return req, nil
+}
+
+// do sends req and turns non-2xx responses into *Error.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	var body struct {
+		Type   string `json:"type"`
+		Detail string `json:"detail"`
+		Error  string `json:"error"`
+	}
+	if json.Unmarshal(b, &body) == nil && body.Detail+body.Error != "" {
+		apiErr.Type = body.Type
+		apiErr.Message = cmp.Or(body.Detail, body.Error)
+	} else {
+		apiErr.Message = strings.TrimSpace(string(b))
+	}
+	return nil, apiErr
+}
+
+func artifactPath(p string) string {
+	return "/" + strings.TrimPrefix(p, "/")
+}
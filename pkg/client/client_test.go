@@ -0,0 +1,169 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer mimics the artifact and proxy endpoints of heimdall.
+type fakeServer struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	proxies []Proxy
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.URL.Path == "/api/v1/proxies" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.proxies)
+	case r.URL.Path == "/api/v1/proxies" && r.Method == http.MethodPost:
+		var p Proxy
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Name == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"name is required","status":400}`))
+			return
+		}
+		p.Revision = "1"
+		f.proxies = append(f.proxies, p)
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(r.URL.Path, "/api/v1/proxies/") && r.Method == http.MethodPut:
+		var p Proxy
+		_ = json.NewDecoder(r.Body).Decode(&p)
+		for i, cur := range f.proxies {
+			if cur.Name != strings.TrimPrefix(r.URL.Path, "/api/v1/proxies/") {
+				continue
+			}
+			if m := r.Header.Get("If-Match"); m != "*" && m != `"`+cur.Revision+`"` {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":"proxy was changed since it was read; reload it and retry","status":409}`))
+				return
+			}
+			p.Name, p.Revision = cur.Name, cur.Revision+"1"
+			f.proxies[i] = p
+			return
+		}
+		http.NotFound(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/v1/exists/") && r.Method == http.MethodHead:
+		if _, ok := f.objects[strings.TrimPrefix(r.URL.Path, "/api/v1/exists")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.URL.Path == "/api/v1/catalog":
+		w.Header().Set("X-Next-Cursor", "next")
+		_ = json.NewEncoder(w).Encode([]Entry{{Name: "releases", Path: "releases/", Type: "dir"}})
+	case r.Method == http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		sum := sha1.Sum(b)
+		f.objects[r.URL.Path] = b
+		f.objects[r.URL.Path+".sha1"] = []byte(hex.EncodeToString(sum[:]))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		b, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(b)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeServer) {
+	t.Helper()
+	fake := &fakeServer{objects: map[string][]byte{}}
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	c, err := New(ts.URL+"/", WithBasicAuth("admin", "secret"))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return c, fake
+}
+
+func TestUploadDownload(t *testing.T) {
+	ctx := context.Background()
+	c, fake := newTestClient(t)
+	data := "jar-bytes"
+	if err := c.Upload(ctx, "releases/com/acme/app/1.0/app-1.0.jar", strings.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	if ok, err := c.Exists(ctx, "releases/com/acme/app/1.0/app-1.0.jar"); err != nil || !ok {
+		t.Fatalf("exists: %v %v", ok, err)
+	}
+	if ok, err := c.Exists(ctx, "releases/missing.jar"); err != nil || ok {
+		t.Fatalf("exists missing: %v %v", ok, err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Download(ctx, "/releases/com/acme/app/1.0/app-1.0.jar", &buf); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if buf.String() != data {
+		t.Fatalf("unexpected content %q", buf.String())
+	}
+
+	fake.objects["/releases/com/acme/app/1.0/app-1.0.jar"] = []byte("tampered")
+	var ce *ChecksumError
+	if err := c.Download(ctx, "releases/com/acme/app/1.0/app-1.0.jar", io.Discard); !errors.As(err, &ce) {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+
+	if err := c.Download(ctx, "releases/missing.jar", io.Discard); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestProxiesAndCatalog(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	if err := c.CreateProxy(ctx, Proxy{Name: "central", URL: "https://repo1.maven.org/maven2"}); err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	err := c.CreateProxy(ctx, Proxy{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "name is required" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	proxies, err := c.ListProxies(ctx)
+	if err != nil || len(proxies) != 1 || proxies[0].Name != "central" {
+		t.Fatalf("list proxies: %v %+v", err, proxies)
+	}
+
+	stale := proxies[0]
+	updated := stale
+	updated.MaxAge = "24h"
+	if err := c.UpdateProxy(ctx, "central", updated); err != nil {
+		t.Fatalf("update proxy: %v", err)
+	}
+	stale.UpdatePolicy = "always"
+	if err := c.UpdateProxy(ctx, "central", stale); !IsConflict(err) {
+		t.Fatalf("expected conflict for a stale revision, got %v", err)
+	}
+
+	page, err := c.Catalog(ctx, "", "", 10)
+	if err != nil {
+		t.Fatalf("catalog: %v", err)
+	}
+	if len(page.Entries) != 1 || page.Entries[0].Type != "dir" || page.NextCursor != "next" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+}